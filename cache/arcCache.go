@@ -0,0 +1,523 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// This is an implementation of the Adaptive Replacement Cache described in
+// "ARC: A Self-Tuning, Low Overhead Replacement Cache" by Megiddo and Modha.
+//
+// The cache tracks resident entries in two LRU lists: t1 holds entries which have been
+// seen once recently, and t2 holds entries which have been seen at least twice. Two
+// additional 'ghost' lists, b1 and b2, remember the keys (but not the values) recently
+// evicted from t1 and t2 respectively. A hit in a ghost list is a sign that the
+// corresponding resident list is too small, so the target size p of t1 is adjusted
+// accordingly. This makes the cache adapt between recency-heavy and frequency-heavy
+// access patterns without tuning.
+//
+// Just like for the LRU cache, entries also have an expiration time and are evicted
+// periodically once expired, and the same finalizer trickery is used to stop the
+// evicter goroutine once the cache is no longer referenced.
+
+// See use of SetFinalizer below for an explanation of this weird composition
+type arcWrapper[K comparable, V any] struct {
+	*arcCache[K, V]
+}
+
+type arcCache[K comparable, V any] struct {
+	sync.Mutex
+	// baseTimeNanos must be at start of struct to ensure 64bit alignment for atomics on
+	// 32bit architectures. See also: https://golang.org/pkg/sync/atomic/#pkg-note-BUG
+	baseTimeNanos     int64
+	size              int
+	p                 int // target size of t1
+	t1, t2            *list.List
+	b1, b2            *list.List
+	lookup            map[K]*list.Element
+	stats             Stats
+	defaultExpiration time.Duration
+	stopEvicter       chan bool
+	evicterTerminated sync.WaitGroup // used by unit tests to verify the finalizer ran
+	evictionNotifier[K, V]
+	clockHolder
+	refreshAhead[K, V]
+	statsWindow
+	loads    LoadGroup[K, V]
+	keyLocks KeyLocks[K]
+}
+
+// arcEntry is the value held by the elements of the ARC lists. Entries of the ghost lists have no value.
+type arcEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	expiration int64 // nanoseconds
+	list       *list.List
+}
+
+// NewARC creates a new cache with an adaptive replacement and time-based eviction model.
+//
+// Cache eviction is done on a periodic basis. Individual cache entries are evicted
+// after their expiration time has passed. The periodic nature of eviction means that
+// cache entries tend to survive around (expirationTime + (evictionInterval / 2))
+//
+// In addition, when the cache is full, adding a new item will displace either the item
+// that has been referenced least recently, or the item that has been referenced least
+// frequently, depending on which of the two has recently proven to be the better choice.
+// The cache also remembers the keys of up to maxEntries recently displaced items in order
+// to make that choice.
+//
+// defaultExpiration specifies the default minimum amount of time a cached
+// entry remains in the cache before eviction. This value is used with the
+// Set function. Explicit per-entry expiration times can be set with the
+// SetWithExpiration function instead.
+//
+// evictionInterval specifies the frequency at which eviction activities take
+// place. This should likely be >= 1 second.
+func NewARC(defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32) ExpiringCache {
+	return NewTypedARC[interface{}, interface{}](defaultExpiration, evictionInterval, maxEntries)
+}
+
+// NewTypedARC creates a new cache with an adaptive replacement and time-based eviction model,
+// holding keys of type K and values of type V. See also: NewARC.
+func NewTypedARC[K comparable, V any](defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32) Expiring[K, V] {
+	c := &arcCache[K, V]{
+		size:              int(maxEntries),
+		t1:                list.New(),
+		t2:                list.New(),
+		b1:                list.New(),
+		b2:                list.New(),
+		lookup:            make(map[K]*list.Element, 2*maxEntries),
+		defaultExpiration: defaultExpiration,
+	}
+
+	c.baseTimeNanos = time.Now().UnixNano()
+	if evictionInterval > 0 {
+		c.stopEvicter = make(chan bool, 1)
+		c.evicterTerminated.Add(1)
+		go c.evicter(evictionInterval)
+
+		// We return a 'see-through' wrapper for the real object such that
+		// the finalizer can trigger on the wrapper. We can't set a finalizer
+		// on the main cache object because it would never fire, since the
+		// evicter goroutine is keeping it alive
+		result := &arcWrapper[K, V]{c}
+		runtime.SetFinalizer(result, func(w *arcWrapper[K, V]) {
+			w.stopEvicter <- true
+			w.evicterTerminated.Wait()
+		})
+		return result
+	}
+
+	return c
+}
+
+func (c *arcCache[K, V]) evicter(evictionInterval time.Duration) {
+	// Wake up once in a while and evict stale items
+	ticker := time.NewTicker(evictionInterval)
+	for {
+		select {
+		case <-ticker.C:
+			c.evictExpired(c.now())
+		case <-c.stopEvicter:
+			ticker.Stop()
+			c.evicterTerminated.Done() // record this for the sake of unit tests
+			return
+		}
+	}
+}
+
+func (c *arcCache[K, V]) evictExpired(t time.Time) {
+	// We snapshot a base time here such that the time doesn't need to be
+	// sampled in the Set call as calling time.Now() is relatively expensive.
+	// Doing it here provides enough precision for our needs and tends to have
+	// much lower call frequency.
+	n := t.UnixNano()
+	atomic.StoreInt64(&c.baseTimeNanos, n)
+
+	c.Lock()
+	for _, l := range []*list.List{c.t1, c.t2} {
+		for elem := l.Front(); elem != nil; {
+			next := elem.Next()
+			if ent := elem.Value.(*arcEntry[K, V]); ent.expiration <= n {
+				c.evicted(ent.key, ent.value, ReasonExpired)
+				c.remove(elem)
+				c.stats.recordEviction(ReasonExpired)
+			}
+			elem = next
+		}
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *arcCache[K, V]) EvictExpired() {
+	c.evictExpired(c.now())
+}
+
+func (c *arcCache[K, V]) SetClock(clock Clock) {
+	c.setClock(clock)
+	atomic.StoreInt64(&c.baseTimeNanos, c.now().UnixNano())
+}
+
+func (c *arcCache[K, V]) Set(key K, value V) {
+	c.SetWithExpiration(key, value, c.defaultExpiration)
+}
+
+func (c *arcCache[K, V]) SetWithExpiration(key K, value V, expiration time.Duration) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + expiration.Nanoseconds()
+
+	c.Lock()
+	c.setWithExpiration(key, value, exp)
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *arcCache[K, V]) SetAll(entries map[K]V) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + c.defaultExpiration.Nanoseconds()
+
+	c.Lock()
+	for key, value := range entries {
+		c.setWithExpiration(key, value, exp)
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *arcCache[K, V]) setWithExpiration(key K, value V, exp int64) {
+	c.stats.Writes++
+
+	if elem, ok := c.lookup[key]; ok {
+		ent := elem.Value.(*arcEntry[K, V])
+		switch ent.list {
+		case c.t1, c.t2:
+			// a resident entry seen again is frequently used
+			ent.value = value
+			ent.expiration = exp
+			c.move(elem, c.t2)
+			return
+
+		case c.b1:
+			// t1 was too small to keep this entry, give it more room
+			delta := 1
+			if c.b2.Len() > c.b1.Len() {
+				delta = c.b2.Len() / c.b1.Len()
+			}
+			if c.p += delta; c.p > c.size {
+				c.p = c.size
+			}
+			if c.t1.Len()+c.t2.Len() >= c.size {
+				c.replace(false)
+			}
+
+		case c.b2:
+			// t2 was too small to keep this entry, give it more room
+			delta := 1
+			if c.b1.Len() > c.b2.Len() {
+				delta = c.b1.Len() / c.b2.Len()
+			}
+			if c.p -= delta; c.p < 0 {
+				c.p = 0
+			}
+			if c.t1.Len()+c.t2.Len() >= c.size {
+				c.replace(true)
+			}
+		}
+
+		// resurrect the ghost as a frequently used entry
+		ent.value = value
+		ent.expiration = exp
+		c.move(elem, c.t2)
+		return
+	}
+
+	// a brand new entry
+	if c.t1.Len()+c.t2.Len() >= c.size {
+		c.replace(false)
+	}
+
+	// keep the ghost lists trim
+	if c.b1.Len() > c.size-c.p {
+		c.dropOldest(c.b1)
+	}
+	if c.b2.Len() > c.p {
+		c.dropOldest(c.b2)
+	}
+
+	ent := &arcEntry[K, V]{key: key, value: value, expiration: exp, list: c.t1}
+	c.lookup[key] = c.t1.PushFront(ent)
+}
+
+// replace displaces a resident entry to its ghost list to make room for a new one.
+func (c *arcCache[K, V]) replace(inB2 bool) {
+	var elem *list.Element
+	var ghosts *list.List
+	if t1 := c.t1.Len(); t1 > 0 && (t1 > c.p || (t1 == c.p && inB2)) {
+		elem, ghosts = c.t1.Back(), c.b1
+	} else {
+		elem, ghosts = c.t2.Back(), c.b2
+	}
+	if elem == nil {
+		return
+	}
+
+	var zero V
+	ent := elem.Value.(*arcEntry[K, V])
+	c.evicted(ent.key, ent.value, ReasonCapacity)
+	ent.value = zero
+	c.move(elem, ghosts)
+	c.stats.recordEviction(ReasonCapacity)
+}
+
+// move unlinks elem from its current list and links it at the front of l.
+func (c *arcCache[K, V]) move(elem *list.Element, l *list.List) {
+	ent := elem.Value.(*arcEntry[K, V])
+	if ent.list == l {
+		l.MoveToFront(elem)
+		return
+	}
+	ent.list.Remove(elem)
+	ent.list = l
+	c.lookup[ent.key] = l.PushFront(ent)
+}
+
+// dropOldest forgets the least recently used entry of a ghost list.
+func (c *arcCache[K, V]) dropOldest(l *list.List) {
+	if elem := l.Back(); elem != nil {
+		c.remove(elem)
+	}
+}
+
+// remove forgets an entry entirely.
+func (c *arcCache[K, V]) remove(elem *list.Element) {
+	ent := elem.Value.(*arcEntry[K, V])
+	ent.list.Remove(elem)
+	delete(c.lookup, ent.key)
+}
+
+func (c *arcCache[K, V]) Get(key K) (V, bool) {
+	c.Lock()
+	defer c.Unlock()
+	return c.get(key)
+}
+
+func (c *arcCache[K, V]) GetAll(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+
+	c.Lock()
+	for _, key := range keys {
+		if value, ok := c.get(key); ok {
+			result[key] = value
+		}
+	}
+	c.Unlock()
+
+	return result
+}
+
+func (c *arcCache[K, V]) get(key K) (V, bool) {
+	if elem, ok := c.lookup[key]; ok {
+		ent := elem.Value.(*arcEntry[K, V])
+		if ent.list == c.t1 || ent.list == c.t2 {
+			c.move(elem, c.t2)
+			c.stats.Hits++
+			c.check(key, ent.expiration, atomic.LoadInt64(&c.baseTimeNanos), c.Set)
+			return ent.value, true
+		}
+	}
+
+	c.stats.Misses++
+	var zero V
+	return zero, false
+}
+
+func (c *arcCache[K, V]) Increment(key K, delta int64) (int64, error) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + c.defaultExpiration.Nanoseconds()
+
+	c.Lock()
+	var value V
+	found := false
+	if elem, ok := c.lookup[key]; ok {
+		if ent := elem.Value.(*arcEntry[K, V]); ent.list == c.t1 || ent.list == c.t2 {
+			value, exp, found = ent.value, ent.expiration, true
+		}
+	}
+	value, n, err := IncrementValue(value, found, delta)
+	if err != nil {
+		c.Unlock()
+		return 0, err
+	}
+	c.setWithExpiration(key, value, exp)
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+
+	return n, nil
+}
+
+func (c *arcCache[K, V]) LockKey(key K) func() {
+	return c.keyLocks.Lock(key)
+}
+
+func (c *arcCache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	return c.loads.GetOrLoad(c, key, loader)
+}
+
+func (c *arcCache[K, V]) GetOrLoadCtx(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error) {
+	return c.loads.GetOrLoadCtx(ctx, c, key, loader)
+}
+
+func (c *arcCache[K, V]) Remove(key K) {
+	c.Lock()
+
+	if elem, ok := c.lookup[key]; ok {
+		ent := elem.Value.(*arcEntry[K, V])
+		if ent.list == c.t1 || ent.list == c.t2 {
+			c.evicted(ent.key, ent.value, ReasonRemoved)
+			c.stats.Removals++
+		}
+		c.remove(elem)
+	}
+
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *arcCache[K, V]) RemoveAll() {
+	c.Lock()
+
+	c.stats.Removals += uint64(c.t1.Len() + c.t2.Len())
+	for _, l := range []*list.List{c.t1, c.t2} {
+		for elem := l.Front(); elem != nil; elem = elem.Next() {
+			ent := elem.Value.(*arcEntry[K, V])
+			c.evicted(ent.key, ent.value, ReasonRemoved)
+		}
+	}
+	c.t1.Init()
+	c.t2.Init()
+	c.b1.Init()
+	c.b2.Init()
+	c.p = 0
+	c.lookup = make(map[K]*list.Element, 2*c.size)
+
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *arcCache[K, V]) RemoveIf(predicate func(key K, value V) bool) int {
+	n := 0
+
+	c.Lock()
+	for _, l := range []*list.List{c.t1, c.t2} {
+		for elem := l.Front(); elem != nil; {
+			next := elem.Next()
+			if ent := elem.Value.(*arcEntry[K, V]); predicate(ent.key, ent.value) {
+				c.evicted(ent.key, ent.value, ReasonRemoved)
+				c.remove(elem)
+				c.stats.Removals++
+				n++
+			}
+			elem = next
+		}
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+
+	return n
+}
+
+func (c *arcCache[K, V]) Preload(entries map[K]V) {
+	c.SetAll(entries)
+}
+
+func (c *arcCache[K, V]) Snapshot() map[K]V {
+	c.Lock()
+	defer c.Unlock()
+
+	result := make(map[K]V, c.t1.Len()+c.t2.Len())
+	for _, l := range []*list.List{c.t1, c.t2} {
+		for elem := l.Front(); elem != nil; elem = elem.Next() {
+			ent := elem.Value.(*arcEntry[K, V])
+			result[ent.key] = ent.value
+		}
+	}
+	return result
+}
+
+func (c *arcCache[K, V]) ForEach(f func(key K, value V) bool) {
+	c.forEachTTL(func(key K, value V, _ time.Duration) bool {
+		return f(key, value)
+	})
+}
+
+// forEachTTL is like ForEach, also passing the time left before each entry expires to f.
+func (c *arcCache[K, V]) forEachTTL(f func(key K, value V, ttl time.Duration) bool) {
+	now := c.now().UnixNano()
+
+	c.Lock()
+	defer c.Unlock()
+
+	for _, l := range []*list.List{c.t1, c.t2} {
+		for elem := l.Front(); elem != nil; elem = elem.Next() {
+			if ent := elem.Value.(*arcEntry[K, V]); ent.expiration > now && !f(ent.key, ent.value, time.Duration(ent.expiration-now)) {
+				return
+			}
+		}
+	}
+}
+
+func (c *arcCache[K, V]) WriteTo(w io.Writer) (int64, error) {
+	return WriteEntries[K, V](w, c.forEachTTL)
+}
+
+func (c *arcCache[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	return ReadEntries[K, V](r, c.SetWithExpiration)
+}
+
+func (c *arcCache[K, V]) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.t1.Len() + c.t2.Len()
+}
+
+func (c *arcCache[K, V]) Stats() Stats {
+	c.Lock()
+	defer c.Unlock()
+	return c.stats
+}
+
+func (c *arcCache[K, V]) StatsDelta() Stats {
+	return c.delta(c.Stats())
+}
+
+func (c *arcCache[K, V]) ResetStats() {
+	c.Lock()
+	c.stats = Stats{}
+	c.Unlock()
+	c.reset()
+}
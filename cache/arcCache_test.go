@@ -0,0 +1,172 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestARCBasic(t *testing.T) {
+	arc := NewARC(5*time.Minute, 1*time.Millisecond, 500)
+	testCacheBasic(arc, t)
+}
+
+func TestARCConcurrent(t *testing.T) {
+	arc := NewARC(5*time.Minute, 1*time.Minute, 500)
+	testCacheConcurrent(arc, t)
+}
+
+func TestARCExpiration(t *testing.T) {
+	arc := NewARC(5*time.Second, 0, 500).(*arcCache[interface{}, interface{}])
+	testCacheExpiration(arc, arc.evictExpired, t)
+}
+
+func TestARCEvicter(t *testing.T) {
+	arc := NewARC(5*time.Second, 1*time.Millisecond, 500)
+	testCacheEvicter(arc)
+}
+
+func TestARCEvictExpired(t *testing.T) {
+	arc := NewARC(5*time.Second, 0, 500).(*arcCache[interface{}, interface{}])
+	testCacheEvictExpired(arc, t)
+}
+
+func TestARCSetEvictionCallback(t *testing.T) {
+	arc := NewARC(5*time.Second, 0, 500).(*arcCache[interface{}, interface{}])
+	testCacheEvictionCallback(arc, arc.evictExpired, t)
+
+	testCacheCapacityCallback(NewARC(5*time.Second, 0, 1), t)
+}
+
+func TestARCSetExpirationCallback(t *testing.T) {
+	arc := NewARC(5*time.Second, 0, 500).(*arcCache[interface{}, interface{}])
+	testCacheExpirationCallback(arc, arc.evictExpired, t)
+}
+
+func TestARCGetOrLoad(t *testing.T) {
+	testCacheGetOrLoad(NewARC(5*time.Minute, 1*time.Minute, 500), t)
+	testCacheGetOrLoadCtx(NewARC(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestARCBatch(t *testing.T) {
+	testCacheBatch(NewARC(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestARCForEach(t *testing.T) {
+	testCacheForEach(NewARC(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestARCIncrement(t *testing.T) {
+	testCacheIncrement(NewARC(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestARCLockKey(t *testing.T) {
+	testCacheLockKey(NewARC(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestARCClock(t *testing.T) {
+	testCacheClock(NewARC(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestARCRefreshAhead(t *testing.T) {
+	testCacheRefreshAhead(NewARC(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestARCWriteTo(t *testing.T) {
+	testCacheWriteTo(NewARC(5*time.Minute, 1*time.Minute, 500), NewARC(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestARCRemoveIf(t *testing.T) {
+	testCacheRemoveIf(NewARC(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestARCStatsDelta(t *testing.T) {
+	testCacheStatsDelta(NewARC(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestARCPreloadSnapshot(t *testing.T) {
+	testCachePreloadSnapshot(NewARC(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestARCFinalizer(t *testing.T) {
+	arc := NewARC(5*time.Second, 1*time.Millisecond, 500).(*arcWrapper[interface{}, interface{}])
+	testCacheFinalizer(&arc.evicterTerminated)
+}
+
+func TestARCBehavior(t *testing.T) {
+	arc := NewTypedARC[string, int](5*time.Minute, 0, 4)
+
+	// make a couple of entries frequently used
+	arc.Set("hot1", 1)
+	arc.Set("hot2", 2)
+	arc.Get("hot1")
+	arc.Get("hot2")
+
+	// a scan over many keys seen only once shouldn't displace them
+	for i := 0; i < 100; i++ {
+		arc.Set("scan"+strconv.Itoa(i), i)
+	}
+	if _, ok := arc.Get("hot1"); !ok {
+		t.Error("Got no entry for hot1, expecting it to survive the scan")
+	}
+	if _, ok := arc.Get("hot2"); !ok {
+		t.Error("Got no entry for hot2, expecting it to survive the scan")
+	}
+	if _, ok := arc.Get("scan99"); !ok {
+		t.Error("Got no entry for scan99, expecting the most recent entry to be present")
+	}
+	if _, ok := arc.Get("scan0"); ok {
+		t.Error("Got an entry for scan0, expecting it to have been displaced")
+	}
+
+	// the cache never holds more than its capacity
+	s := arc.Stats()
+	resident := s.Writes - s.Evictions - s.Removals
+	if resident != 4 {
+		t.Errorf("Got %d resident entries, expecting 4", resident)
+	}
+}
+
+func BenchmarkARCGet(b *testing.B) {
+	c := NewARC(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheGet(c, b)
+}
+
+func BenchmarkARCGetConcurrent(b *testing.B) {
+	c := NewARC(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheGetConcurrent(c, b)
+}
+
+func BenchmarkARCSet(b *testing.B) {
+	c := NewARC(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheSet(c, b)
+}
+
+func BenchmarkARCSetConcurrent(b *testing.B) {
+	c := NewARC(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheSetConcurrent(c, b)
+}
+
+func BenchmarkARCGetSetConcurrent(b *testing.B) {
+	c := NewARC(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheGetSetConcurrent(c, b)
+}
+
+func BenchmarkARCSetRemove(b *testing.B) {
+	c := NewARC(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheSetRemove(c, b)
+}
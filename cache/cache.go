@@ -18,6 +18,8 @@
 package cache
 
 import (
+	"context"
+	"io"
 	"time"
 )
 
@@ -37,9 +39,18 @@ type Stats struct {
 	// Misses captures the number of times a Get operation failed to find an entry in the cache.
 	Misses uint64
 
-	// Evictions captures the number of entries that have been evicted from the cache
+	// Evictions captures the number of entries that have been evicted from the cache, which is
+	// the sum of Expirations and CapacityEvictions
 	Evictions uint64
 
+	// Expirations captures the number of evictions of entries whose expiration time passed. A
+	// large share of expirations among evictions suggests the expiration time is too short.
+	Expirations uint64
+
+	// CapacityEvictions captures the number of evictions of entries displaced to make room for
+	// other entries. A large share of these among evictions suggests the cache is undersized.
+	CapacityEvictions uint64
+
 	// Removals captures the number of entries that have been explicitly removed from the
 	// cache
 	Removals uint64
@@ -62,10 +73,22 @@ type Stats struct {
 //   } else {
 //      fmt.Printf("Value was not found, must have been evicted")
 //   }
-type Cache interface {
+//
+// Cache is the untyped flavor of Typed, see Typed for the description of its methods.
+type Cache = Typed[interface{}, interface{}]
+
+// ExpiringCache is a cache with entries that are evicted over time. It is the untyped
+// flavor of Expiring.
+type ExpiringCache = Expiring[interface{}, interface{}]
+
+// Typed is a Cache holding keys of type K and values of type V, which saves callers from
+// boxing keys and values in interfaces and from type assertions when reading values back.
+//
+//	c := NewTypedLRU[string, int](5*time.Second, 5*time.Second, 500)
+//	c.Set("foo", 42)
+//	value, ok := c.Get("foo") // value is an int
+type Typed[K comparable, V any] interface {
 	// Ideas for the future:
-	//   - Return the number of entries in the cache in stats.
-	//   - Provide an eviction callback to know when entries are evicted.
 	//   - Have Set and Remove return the previous value for the key, if any.
 	//   - Have Get return the expiration time for entries.
 
@@ -73,33 +96,147 @@ type Cache interface {
 	// the same key that is already in the cache. The entry may be automatically
 	// expunged from the cache at some point, depending on the eviction policies
 	// of the cache and the options specified when the cache was created.
-	Set(key interface{}, value interface{})
+	Set(key K, value V)
 
 	// Get retrieves the value associated with the supplied key if the key
 	// is present in the cache.
-	Get(key interface{}) (value interface{}, ok bool)
+	Get(key K) (value V, ok bool)
 
 	// Remove synchronously deletes the given key from the cache. This has no effect if the key is not
 	// currently in the cache.
-	Remove(key interface{})
+	Remove(key K)
 
 	// RemoveAll synchronously deletes all entries from the cache.
 	RemoveAll()
 
+	// GetOrLoad returns the value associated with key, calling loader to produce the value and
+	// add it to the cache if it isn't present. Concurrent calls for the same key share a single
+	// call to loader. Errors returned by loader are passed on to all callers and not cached.
+	GetOrLoad(key K, loader func() (V, error)) (V, error)
+
+	// GetOrLoadCtx is like GetOrLoad, passing ctx on to loader so that it can honor cancellation
+	// and deadlines. Callers waiting on a load started by another caller return the context's
+	// error once ctx is done.
+	GetOrLoadCtx(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error)
+
+	// Increment atomically adds delta to the integer value associated with key, and returns the
+	// result. If the key isn't present, it is added with the value delta and the default expiration,
+	// otherwise the expiration of the entry is unchanged. An error is returned, leaving the entry
+	// unchanged, if the value isn't an integer.
+	Increment(key K, delta int64) (int64, error)
+
+	// LockKey locks a mutex associated with key, and returns the function unlocking it. This lets
+	// callers doing read-modify-write cycles on a cached value serialize per key rather than behind
+	// a global lock. The operations of the cache don't take this mutex. Keys share a fixed number of
+	// mutexes, so a caller shouldn't hold the locks of two keys at once, lest it deadlock.
+	LockKey(key K) func()
+
+	// SetAll adds or updates a batch of entries in the cache, using the default expiration
+	// time. This is cheaper than calling Set for each of the entries.
+	SetAll(entries map[K]V)
+
+	// GetAll retrieves the entries associated with a batch of keys. Keys which aren't found
+	// are absent from the result. This is cheaper than calling Get for each of the keys.
+	GetAll(keys []K) map[K]V
+
+	// RemoveIf removes all the entries of the cache for which predicate returns true, and
+	// returns the number of entries removed. Some implementations hold their lock while
+	// evaluating the predicate, so it shouldn't call methods of the cache.
+	RemoveIf(predicate func(key K, value V) bool) int
+
+	// Preload adds the given entries to the cache, as if each was added with Set. Together with
+	// Snapshot, this lets the contents of a cache be saved on shutdown and restored on startup.
+	Preload(entries map[K]V)
+
+	// ForEach calls f for each of the entries of the cache which haven't expired, until f
+	// returns false. Entries added or removed during the iteration may or may not be visited.
+	// Some implementations hold their lock during the iteration, so f shouldn't call methods
+	// of the cache.
+	ForEach(f func(key K, value V) bool)
+
+	// Snapshot returns a copy of the entries currently held in the cache. Entries which have
+	// expired but haven't been evicted yet may be included.
+	Snapshot() map[K]V
+
 	// Stats returns information about the efficiency of the cache.
 	Stats() Stats
+
+	// StatsDelta returns the stats accumulated since the previous call to StatsDelta or ResetStats,
+	// or since the cache was created. This suits reporting hit ratios per interval, which cumulative
+	// counters flatten out over the lifetime of the process.
+	StatsDelta() Stats
+
+	// ResetStats zeroes the counters returned by Stats and StatsDelta.
+	ResetStats()
+
+	// Len returns the number of entries currently held in the cache. Entries which have
+	// expired but haven't been evicted yet may be included.
+	Len() int
+
+	// SetEvictionCallback registers a function to be called whenever an entry leaves the cache,
+	// whether it expired, was displaced to make room for other entries, or was explicitly removed.
+	// No locks are held during the invocation of the callback, but it should not block for long.
+	// Passing nil unregisters the callback.
+	SetEvictionCallback(callback func(key K, value V, reason EvictionReason))
 }
 
-// ExpiringCache is a cache with entries that are evicted over time
-type ExpiringCache interface {
-	Cache
+// Pinnable is implemented by the caches which can exempt some of their entries from being displaced
+// to make room for other entries, such as the LRU caches. This suits critical entries, like root
+// certificates or bootstrap configuration, which must remain available however busy the cache.
+//
+//	c := NewTypedLRU[string, []byte](time.Hour, time.Minute, 500)
+//	c.Set("root-cert", cert)
+//	c.(Pinnable[string]).Pin("root-cert")
+type Pinnable[K comparable] interface {
+	// Pin exempts the entry for key from being displaced until it is unpinned, and returns false if
+	// key isn't in the cache. Pinned entries still expire and can be removed, which unpins them.
+	// When all the entries are pinned, new entries aren't added to the cache.
+	Pin(key K) bool
+
+	// Unpin lets the entry for key be displaced again.
+	Unpin(key K)
+}
+
+// Expiring is a Typed cache with entries that are evicted over time.
+type Expiring[K comparable, V any] interface {
+	Typed[K, V]
 
 	// SetWithExpiration inserts an entry in the cache with a requested expiration time.
 	// This will replace any entry with the same key that is already in the cache.
 	// The entry will be automatically expunged from the cache at or slightly after the
 	// requested expiration time.
-	SetWithExpiration(key interface{}, value interface{}, expiration time.Duration)
+	SetWithExpiration(key K, value V, expiration time.Duration)
 
 	// EvictExpired() synchronously evicts all expired entries from the cache
 	EvictExpired()
+
+	// SetClock replaces the clock the cache uses to tell the time, which is the wall clock by default.
+	// The periodic eviction still runs on the wall clock, but evicts entries based on the time told
+	// by the clock. Combined with a FakeClock and EvictExpired, this lets tests exercise expiration
+	// deterministically. Passing nil restores the wall clock.
+	SetClock(clock Clock)
+
+	// SetExpirationCallback registers a function to be called whenever an entry is evicted because
+	// its expiration time passed, as opposed to being displaced or explicitly removed. This is
+	// invoked in addition to the eviction callback, and is suitable for refetching data as soon as
+	// it goes stale. Passing nil unregisters the callback.
+	SetExpirationCallback(callback func(key K, value V))
+
+	// SetRefresher registers a function to reload entries when they are looked up within ahead of
+	// their expiration time. The reload happens in the background while callers keep getting the
+	// current value, and its result replaces the entry with the default expiration, such that hot
+	// entries stay warm without callers ever waiting on a load. At most one reload per key is in
+	// flight at a time, and entries are left alone when the reload fails. Passing nil unregisters
+	// the function.
+	SetRefresher(refresh func(key K) (V, error), ahead time.Duration)
+
+	// WriteTo serializes the entries of the cache along with the time left before they expire,
+	// such that a new instance can pick up where this one left off, for example during a rolling
+	// upgrade. Entries are encoded with encoding/gob, which for untyped caches requires the concrete
+	// types of keys and values to be registered with gob.Register unless they are basic types.
+	WriteTo(w io.Writer) (int64, error)
+
+	// ReadFrom adds the entries serialized by WriteTo to the cache, each expiring after the time it
+	// had left when it was written. Entries already in the cache are replaced.
+	ReadFrom(r io.Reader) (int64, error)
 }
@@ -15,9 +15,15 @@
 package cache
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -219,6 +225,9 @@ func testCacheExpiration(c ExpiringCache, evictExpired func(time.Time), t *testi
 	if s.Evictions != 2 {
 		t.Errorf("Got %d evictions, expecting 2", s.Evictions)
 	}
+	if s.Expirations != 2 || s.CapacityEvictions != 0 {
+		t.Errorf("Got %d expirations and %d capacity evictions, expecting 2 and 0", s.Expirations, s.CapacityEvictions)
+	}
 }
 
 func testCacheEvictExpired(c ExpiringCache, t *testing.T) {
@@ -253,6 +262,623 @@ func testCacheEvicter(c ExpiringCache) {
 	}
 }
 
+type evictionRecord struct {
+	key    interface{}
+	reason EvictionReason
+}
+
+// WARNING: This test expects the cache to have been created with no automatic eviction.
+func testCacheEvictionCallback(c ExpiringCache, evictExpired func(time.Time), t *testing.T) {
+	var evictions []evictionRecord
+	c.SetEvictionCallback(func(key, value interface{}, reason EvictionReason) {
+		if key != value {
+			t.Errorf("Got value %v for key %v, expected the value to match the key", value, key)
+		}
+
+		// callbacks must be able to use the cache
+		if _, ok := c.Get(key); ok {
+			t.Errorf("Got an entry for %v, expected it to be gone by the time the callback is invoked", key)
+		}
+
+		evictions = append(evictions, evictionRecord{key, reason})
+	})
+
+	now := time.Now()
+	c.SetWithExpiration("A", "A", 10*time.Millisecond)
+	c.SetWithExpiration("B", "B", time.Hour)
+	c.SetWithExpiration("C", "C", time.Hour)
+	c.SetWithExpiration("D", "D", time.Hour)
+
+	evictExpired(now.Add(15 * time.Millisecond))
+	c.Remove("B")
+	c.Remove("Z")
+	c.RemoveAll()
+
+	if len(evictions) != 4 {
+		t.Fatalf("Got %d evictions, expected 4: %v", len(evictions), evictions)
+	}
+
+	expected := []evictionRecord{{"A", ReasonExpired}, {"B", ReasonRemoved}}
+	for i, e := range expected {
+		if evictions[i] != e {
+			t.Errorf("Got eviction %v, expected %v", evictions[i], e)
+		}
+	}
+
+	// RemoveAll makes no promise about the order in which entries are removed
+	remaining := map[evictionRecord]bool{{"C", ReasonRemoved}: true, {"D", ReasonRemoved}: true}
+	for _, e := range evictions[2:] {
+		if !remaining[e] {
+			t.Errorf("Got unexpected eviction %v", e)
+		}
+		delete(remaining, e)
+	}
+
+	// once unregistered, the callback should no longer be invoked
+	c.SetEvictionCallback(nil)
+	c.Set("E", "E")
+	c.Remove("E")
+	if len(evictions) != 4 {
+		t.Errorf("Got %d evictions, expected the callback to have been unregistered", len(evictions))
+	}
+}
+
+func testCacheExpirationCallback(c ExpiringCache, evictExpired func(time.Time), t *testing.T) {
+	var expired []interface{}
+	c.SetExpirationCallback(func(key, value interface{}) {
+		if key != value {
+			t.Errorf("Got value %v for key %v, expected the value to match the key", value, key)
+		}
+
+		// callbacks must be able to use the cache
+		if _, ok := c.Get(key); ok {
+			t.Errorf("Got an entry for %v, expected it to be gone by the time the callback is invoked", key)
+		}
+
+		expired = append(expired, key)
+	})
+
+	now := time.Now()
+	c.SetWithExpiration("A", "A", 10*time.Millisecond)
+	c.SetWithExpiration("B", "B", 10*time.Millisecond)
+	c.SetWithExpiration("C", "C", time.Hour)
+
+	// explicit removals aren't expirations
+	c.Remove("B")
+	evictExpired(now.Add(15 * time.Millisecond))
+	c.RemoveAll()
+
+	if len(expired) != 1 || expired[0] != "A" {
+		t.Errorf("Got expirations for %v, expected only A", expired)
+	}
+
+	// once unregistered, the callback should no longer be invoked
+	c.SetExpirationCallback(nil)
+	c.SetWithExpiration("D", "D", 10*time.Millisecond)
+	evictExpired(now.Add(time.Hour))
+	if len(expired) != 1 {
+		t.Errorf("Got %d expirations, expected the callback to have been unregistered", len(expired))
+	}
+}
+
+// WARNING: This test expects the cache to have been created with room for a single entry.
+func testCacheCapacityCallback(c Cache, t *testing.T) {
+	var evictions []evictionRecord
+	c.SetEvictionCallback(func(key, value interface{}, reason EvictionReason) {
+		evictions = append(evictions, evictionRecord{key, reason})
+	})
+
+	c.Set("A", "A")
+	c.Set("B", "B")
+
+	if len(evictions) != 1 || evictions[0] != (evictionRecord{"A", ReasonCapacity}) {
+		t.Errorf("Got evictions %v, expected A to have been displaced", evictions)
+	}
+	if s := c.Stats(); s.Evictions != 1 || s.CapacityEvictions != 1 || s.Expirations != 0 {
+		t.Errorf("Got %+v, expected a single capacity eviction", s)
+	}
+}
+
+// testCachePin exercises a Pinnable cache with a capacity of 2.
+func testCachePin(c Cache, t *testing.T) {
+	p := c.(Pinnable[interface{}])
+	if p.Pin("A") {
+		t.Error("Got true, expected pinning a missing key to fail")
+	}
+
+	// pinned entries survive a stream of other entries
+	c.Set("A", "A")
+	if !p.Pin("A") {
+		t.Error("Got false, expected A to be pinned")
+	}
+	for _, key := range []string{"B", "C", "D"} {
+		c.Set(key, key)
+	}
+	if _, ok := c.Get("A"); !ok {
+		t.Error("Got no entry for A, expected it to be pinned")
+	}
+	if _, ok := c.Get("D"); !ok {
+		t.Error("Got no entry for D, expected it to take the other slot")
+	}
+
+	// new entries are dropped once all the entries are pinned
+	p.Pin("D")
+	c.Set("E", "E")
+	if _, ok := c.Get("E"); ok {
+		t.Error("Got an entry for E, expected no room for it")
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("Got %d entries, expected the 2 pinned entries", n)
+	}
+
+	// unpinned entries can be displaced again, and pinned entries can still be removed
+	p.Unpin("D")
+	c.Set("E", "E")
+	if _, ok := c.Get("D"); ok {
+		t.Error("Got an entry for D, expected it to be displaced once unpinned")
+	}
+	c.Remove("A")
+	if _, ok := c.Get("A"); ok {
+		t.Error("Got an entry for A, expected it to be removed")
+	}
+	c.Set("A", "A")
+	c.Set("F", "F")
+	c.Set("G", "G")
+	if _, ok := c.Get("A"); ok {
+		t.Error("Got an entry for A, expected it to have been unpinned by its removal")
+	}
+}
+
+func testCacheGetOrLoad(c Cache, t *testing.T) {
+	var loads int32
+	release := make(chan struct{})
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "A", nil
+	}
+
+	// concurrent callers share a single load
+	wg := new(sync.WaitGroup)
+	workers := 10
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			v, err := c.GetOrLoad("A", loader)
+			if err != nil || v != "A" {
+				t.Errorf("Got %v, %v, expecting A, nil", v, err)
+			}
+			wg.Done()
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("Got %d loads, expecting 1", loads)
+	}
+	if v, ok := c.Get("A"); !ok || v != "A" {
+		t.Errorf("Got %v, %v, expecting the loaded value to be cached", v, ok)
+	}
+
+	// errors are passed on but not cached
+	errLoad := errors.New("load failed")
+	if _, err := c.GetOrLoad("B", func() (interface{}, error) { return nil, errLoad }); err != errLoad {
+		t.Errorf("Got error %v, expecting %v", err, errLoad)
+	}
+	if _, ok := c.Get("B"); ok {
+		t.Error("Got an entry for B, expecting the failed load not to be cached")
+	}
+
+	// a panicking loader doesn't leave other callers hanging
+	func() {
+		defer func() { _ = recover() }()
+		_, _ = c.GetOrLoad("C", func() (interface{}, error) { panic("boom") })
+	}()
+	if v, err := c.GetOrLoad("C", func() (interface{}, error) { return "C", nil }); err != nil || v != "C" {
+		t.Errorf("Got %v, %v, expecting C, nil", v, err)
+	}
+}
+
+type loadCtxKey struct{}
+
+func testCacheGetOrLoadCtx(c Cache, t *testing.T) {
+	// the context is passed on to the loader
+	ctx := context.WithValue(context.Background(), loadCtxKey{}, "trace")
+	v, err := c.GetOrLoadCtx(ctx, "A", func(ctx context.Context) (interface{}, error) {
+		return ctx.Value(loadCtxKey{}), nil
+	})
+	if err != nil || v != "trace" {
+		t.Errorf("Got %v, %v, expecting trace, nil", v, err)
+	}
+
+	// loaders honoring cancellation return the context's error, which isn't cached
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetOrLoadCtx(canceled, "B", func(ctx context.Context) (interface{}, error) {
+		return nil, ctx.Err()
+	}); err != context.Canceled {
+		t.Errorf("Got error %v, expecting %v", err, context.Canceled)
+	}
+	if _, ok := c.Get("B"); ok {
+		t.Error("Got an entry for B, expecting the canceled load not to be cached")
+	}
+
+	// callers waiting on a load in flight give up once their context is done
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_, _ = c.GetOrLoadCtx(context.Background(), "C", func(context.Context) (interface{}, error) {
+			close(started)
+			<-release
+			return "C", nil
+		})
+	}()
+	<-started
+
+	waiting, cancelWaiting := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelWaiting()
+	if _, err := c.GetOrLoadCtx(waiting, "C", func(context.Context) (interface{}, error) {
+		t.Error("Got a second load of C, expecting to wait on the load in flight")
+		return nil, nil
+	}); err != context.DeadlineExceeded {
+		t.Errorf("Got error %v, expecting %v", err, context.DeadlineExceeded)
+	}
+
+	// the load keeps running on behalf of its own caller
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if v, ok := c.Get("C"); ok && v == "C" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for C to be loaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func testCacheBatch(c Cache, t *testing.T) {
+	c.Set("A", "0")
+	c.SetAll(map[interface{}]interface{}{"A": "1", "B": "2", "C": "3"})
+
+	got := c.GetAll([]interface{}{"A", "B", "Z"})
+	expected := map[interface{}]interface{}{"A": "1", "B": "2"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Got %v, expected %v", got, expected)
+	}
+
+	s := c.Stats()
+	s.Removals = 0
+	if expected := (Stats{Writes: 4, Hits: 2, Misses: 1}); s != expected {
+		t.Errorf("Got stats of %v, expected %v", s, expected)
+	}
+
+	if got := c.GetAll(nil); len(got) != 0 {
+		t.Errorf("Got %v, expected an empty result", got)
+	}
+}
+
+func testCacheForEach(c ExpiringCache, t *testing.T) {
+	c.SetWithExpiration("A", "1", time.Hour)
+	c.SetWithExpiration("B", "2", time.Hour)
+	c.SetWithExpiration("C", "3", time.Hour)
+	c.SetWithExpiration("EXPIRED", "4", -time.Second)
+
+	got := make(map[interface{}]interface{})
+	c.ForEach(func(key, value interface{}) bool {
+		got[key] = value
+		return true
+	})
+	expected := map[interface{}]interface{}{"A": "1", "B": "2", "C": "3"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Got %v, expected %v", got, expected)
+	}
+
+	// returning false stops the iteration
+	calls := 0
+	c.ForEach(func(key, value interface{}) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("Got %d calls, expected the iteration to stop after 1", calls)
+	}
+}
+
+func testCacheIncrement(c Cache, t *testing.T) {
+	if n, err := c.Increment("A", 5); err != nil || n != 5 {
+		t.Errorf("Got %d, %v, expected a missing entry to be created with the delta", n, err)
+	}
+	if n, err := c.Increment("A", -2); err != nil || n != 3 {
+		t.Errorf("Got %d, %v, expected 3", n, err)
+	}
+	if value, ok := c.Get("A"); !ok || value != int64(3) {
+		t.Errorf("Got %v, %t, expected int64 3", value, ok)
+	}
+
+	// the type of existing values is preserved
+	c.Set("B", 10)
+	if n, err := c.Increment("B", 1); err != nil || n != 11 {
+		t.Errorf("Got %d, %v, expected 11", n, err)
+	}
+	if value, ok := c.Get("B"); !ok || value != 11 {
+		t.Errorf("Got %v, %t, expected int 11", value, ok)
+	}
+
+	const workers = 10
+	const increments = 100
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := 0; j < increments; j++ {
+				_, _ = c.Increment("C", 1)
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	if value, ok := c.Get("C"); !ok || value != int64(workers*increments) {
+		t.Errorf("Got %v, %t, expected %d from concurrent increments", value, ok, workers*increments)
+	}
+
+	c.Set("D", "not a number")
+	if _, err := c.Increment("D", 1); err == nil {
+		t.Error("Got success, expected an error when incrementing a string")
+	}
+	if value, ok := c.Get("D"); !ok || value != "not a number" {
+		t.Errorf("Got %v, %t, expected the string to be left unchanged", value, ok)
+	}
+
+	if n, err := c.Increment("A", 1); err != nil || n != 4 {
+		t.Errorf("Got %d, %v, expected 4", n, err)
+	}
+}
+
+func testCacheClock(c ExpiringCache, t *testing.T) {
+	clock := NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.SetClock(clock)
+
+	c.SetWithExpiration("A", "1", time.Minute)
+	c.SetWithExpiration("B", "2", time.Hour)
+
+	clock.Advance(30 * time.Second)
+	c.EvictExpired()
+	if _, ok := c.Get("A"); !ok {
+		t.Error("Got no entry for A, expected it to be present before its expiration")
+	}
+
+	clock.Advance(time.Minute)
+	visited := map[interface{}]bool{}
+	c.ForEach(func(key, value interface{}) bool {
+		visited[key] = true
+		return true
+	})
+	if visited["A"] || !visited["B"] {
+		t.Errorf("Got %v visited, expected only B to be visited once A expired", visited)
+	}
+
+	c.EvictExpired()
+	if _, ok := c.Get("A"); ok {
+		t.Error("Got an entry for A, expected it to be evicted after its expiration")
+	}
+	if _, ok := c.Get("B"); !ok {
+		t.Error("Got no entry for B, expected it to be present before its expiration")
+	}
+}
+
+func testCacheRefreshAhead(c ExpiringCache, t *testing.T) {
+	clock := NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.SetClock(clock)
+
+	var calls int32
+	release := make(chan struct{})
+	c.SetRefresher(func(key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "fresh", nil
+	}, 20*time.Second)
+
+	c.SetWithExpiration("A", "stale", time.Minute)
+	if value, _ := c.Get("A"); value != "stale" {
+		t.Errorf("Got %v, expected stale", value)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Errorf("Got %d refreshes, expected none long before expiration", n)
+	}
+
+	clock.Advance(45 * time.Second)
+	c.EvictExpired()
+	for i := 0; i < 3; i++ {
+		if value, _ := c.Get("A"); value != "stale" {
+			t.Errorf("Got %v, expected stale while the refresh is in flight", value)
+		}
+	}
+	close(release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if value, _ := c.Get("A"); value == "fresh" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Got no fresh value for A, expected it to be refreshed ahead of its expiration")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Got %d refreshes, expected 1", n)
+	}
+}
+
+func testCacheWriteTo(src, dst ExpiringCache, t *testing.T) {
+	clock := NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	src.SetClock(clock)
+	dst.SetClock(clock)
+
+	src.SetWithExpiration("A", "1", time.Minute)
+	src.SetWithExpiration("B", 2, time.Hour)
+	src.SetWithExpiration("EXPIRED", "3", time.Second)
+	clock.Advance(30 * time.Second)
+
+	var buf bytes.Buffer
+	n, err := src.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("Got %d, %v, expected %d bytes to be written", n, err, buf.Len())
+	}
+
+	dst.Set("A", "0")
+	if n, err := dst.ReadFrom(&buf); err != nil || n == 0 {
+		t.Fatalf("Got %d, %v, expected the entries to be read", n, err)
+	}
+	got := dst.Snapshot()
+	expected := map[interface{}]interface{}{"A": "1", "B": 2}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Got %v, expected %v", got, expected)
+	}
+
+	// the entries keep the time they had left
+	clock.Advance(time.Minute)
+	dst.EvictExpired()
+	if _, ok := dst.Get("A"); ok {
+		t.Error("Got an entry for A, expected it to expire a minute after it was written to src")
+	}
+	if _, ok := dst.Get("B"); !ok {
+		t.Error("Got no entry for B, expected it to be present before its expiration")
+	}
+
+	if _, err := dst.ReadFrom(strings.NewReader("garbage")); err == nil {
+		t.Error("Got no error, expected reading garbage to fail")
+	}
+}
+
+func testCacheStatsDelta(c Cache, t *testing.T) {
+	c.Set("A", "1")
+	c.Get("A")
+	c.Get("Z")
+	if d := c.StatsDelta(); d != (Stats{Writes: 1, Hits: 1, Misses: 1}) {
+		t.Errorf("Got %+v, expected 1 write, 1 hit and 1 miss", d)
+	}
+	if d := c.StatsDelta(); d != (Stats{}) {
+		t.Errorf("Got %+v, expected nothing to have happened since the last call", d)
+	}
+
+	c.Get("A")
+	if d := c.StatsDelta(); d != (Stats{Hits: 1}) {
+		t.Errorf("Got %+v, expected 1 hit", d)
+	}
+
+	c.Get("A")
+	c.ResetStats()
+	if s := c.Stats(); s != (Stats{}) {
+		t.Errorf("Got %+v, expected the stats to be reset", s)
+	}
+	c.Get("Z")
+	if d := c.StatsDelta(); d != (Stats{Misses: 1}) {
+		t.Errorf("Got %+v, expected 1 miss since the reset", d)
+	}
+}
+
+func testCacheLockKey(c Cache, t *testing.T) {
+	c.Set("A", 0)
+
+	// read-modify-write cycles holding the key's lock don't lose updates
+	wg := new(sync.WaitGroup)
+	workers := 10
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := 0; j < 50; j++ {
+				unlock := c.LockKey("A")
+				value, _ := c.Get("A")
+				c.Set("A", value.(int)+1)
+				unlock()
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	if value, ok := c.Get("A"); !ok || value != workers*50 {
+		t.Errorf("Got %v, %t, expected %d", value, ok, workers*50)
+	}
+}
+
+func testCacheRemoveIf(c Cache, t *testing.T) {
+	c.SetAll(map[interface{}]interface{}{"ns1/A": "1", "ns1/B": "2", "ns2/A": "3"})
+
+	var evicted []interface{}
+	c.SetEvictionCallback(func(key, value interface{}, reason EvictionReason) {
+		if reason != ReasonRemoved {
+			t.Errorf("Got reason %v for %v, expected %v", reason, key, ReasonRemoved)
+		}
+		evicted = append(evicted, key)
+	})
+
+	n := c.RemoveIf(func(key, value interface{}) bool {
+		return strings.HasPrefix(key.(string), "ns1/")
+	})
+	if n != 2 {
+		t.Errorf("Got %d entries removed, expected 2", n)
+	}
+	if len(evicted) != 2 {
+		t.Errorf("Got callbacks for %v, expected ns1/A and ns1/B", evicted)
+	}
+	if s := c.Stats(); s.Removals != 2 {
+		t.Errorf("Got %d removals, expected 2", s.Removals)
+	}
+
+	for _, key := range []string{"ns1/A", "ns1/B"} {
+		if _, ok := c.Get(key); ok {
+			t.Errorf("Got %s, expected it to be removed", key)
+		}
+	}
+	if value, ok := c.Get("ns2/A"); !ok || value != "3" {
+		t.Errorf("Got %v, %t for ns2/A, expected it to be kept", value, ok)
+	}
+
+	if n := c.RemoveIf(func(key, value interface{}) bool { return false }); n != 0 {
+		t.Errorf("Got %d entries removed, expected 0", n)
+	}
+}
+
+func testCachePreloadSnapshot(c Cache, t *testing.T) {
+	c.Preload(map[interface{}]interface{}{"A": "1", "B": "2"})
+	c.Set("C", "3")
+	c.Remove("A")
+
+	if s := c.Stats(); s.Writes != 3 {
+		t.Errorf("Got %d writes, expecting preloaded entries to count as writes", s.Writes)
+	}
+
+	if n := c.Len(); n != 2 {
+		t.Errorf("Got %d entries, expecting 2", n)
+	}
+
+	snapshot := c.Snapshot()
+	expected := map[interface{}]interface{}{"B": "2", "C": "3"}
+	if !reflect.DeepEqual(snapshot, expected) {
+		t.Errorf("Got snapshot %v, expected %v", snapshot, expected)
+	}
+
+	// the snapshot is a copy
+	snapshot["D"] = "4"
+	if _, ok := c.Get("D"); ok {
+		t.Error("Got an entry for D, expecting the snapshot to be independent of the cache")
+	}
+
+	c.RemoveAll()
+	if snapshot = c.Snapshot(); len(snapshot) != 0 {
+		t.Errorf("Got snapshot %v, expected an empty cache", snapshot)
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("Got %d entries, expecting 0", n)
+	}
+}
+
 func testCacheFinalizer(gate *sync.WaitGroup) {
 	runtime.GC()
 	gate.Wait()
@@ -372,3 +998,15 @@ func benchmarkCacheSetRemove(c Cache, b *testing.B) {
 		c.Remove(name)
 	}
 }
+
+func benchmarkCacheSetAll(c Cache, b *testing.B) {
+	entries := make(map[interface{}]interface{}, 100)
+	for i := 0; i < 100; i++ {
+		entries["foo"+strconv.Itoa(i)] = "bar"
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.SetAll(entries)
+	}
+}
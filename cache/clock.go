@@ -0,0 +1,87 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock tells the time to expiring caches.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// FakeClock is a Clock which only moves when told to, such that tests and simulations can control
+// the passage of time rather than sleep.
+//
+//	clock := cache.NewFakeClock(time.Now())
+//	c := cache.NewTTL(time.Minute, 0)
+//	c.SetClock(clock)
+//	c.Set("foo", "bar")
+//	clock.Advance(2 * time.Minute)
+//	c.EvictExpired() // foo is gone
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time the clock is set to.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// Set sets the clock to the given time.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	c.now = now
+	c.mu.Unlock()
+}
+
+// clockHolder holds the clock of a cache, which is the wall clock unless replaced.
+type clockHolder struct {
+	clock atomic.Pointer[Clock]
+}
+
+func (h *clockHolder) setClock(clock Clock) {
+	if clock == nil {
+		h.clock.Store(nil)
+		return
+	}
+	h.clock.Store(&clock)
+}
+
+func (h *clockHolder) now() time.Time {
+	if clock := h.clock.Load(); clock != nil {
+		return (*clock).Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	if now := clock.Now(); !now.Equal(start) {
+		t.Errorf("Got %v, expected %v", now, start)
+	}
+
+	clock.Advance(time.Hour)
+	if now := clock.Now(); !now.Equal(start.Add(time.Hour)) {
+		t.Errorf("Got %v, expected %v", now, start.Add(time.Hour))
+	}
+
+	clock.Set(start)
+	if now := clock.Now(); !now.Equal(start) {
+		t.Errorf("Got %v, expected %v", now, start)
+	}
+}
+
+func TestClockHolder(t *testing.T) {
+	var h clockHolder
+	if now := h.now(); time.Since(now) > time.Minute {
+		t.Errorf("Got %v, expected the wall clock by default", now)
+	}
+
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.setClock(NewFakeClock(start))
+	if now := h.now(); !now.Equal(start) {
+		t.Errorf("Got %v, expected %v", now, start)
+	}
+
+	h.setClock(nil)
+	if now := h.now(); time.Since(now) > time.Minute {
+		t.Errorf("Got %v, expected the wall clock to be restored", now)
+	}
+}
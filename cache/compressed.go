@@ -0,0 +1,284 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"io"
+	"sync"
+)
+
+// The compressed cache sits in front of another cache, compressing the values larger than a
+// threshold before handing them to the underlying cache, and decompressing them on the way out.
+// Small values aren't worth the CPU, and are stored as they are.
+//
+// The untyped cache compresses values of type []byte and string, and stores other values as they
+// are. Compressed values are boxed in a compressedValue to tell them apart. Typed caches hold values
+// of a single type, so instead every value stored is prefixed with a tag byte telling whether the
+// rest is compressed.
+
+const (
+	tagRaw byte = iota
+	tagFlate
+)
+
+// compressedValue is a value compressed by an untyped compressed cache.
+type compressedValue struct {
+	data   []byte
+	string bool
+}
+
+// compressedCache compresses the values of the underlying cache with encode and decode.
+type compressedCache[K comparable, V any] struct {
+	c      Typed[K, V]
+	encode func(value V) V
+	decode func(value V) (V, bool)
+	loads  LoadGroup[K, V]
+}
+
+var _ Cache = &compressedCache[interface{}, interface{}]{}
+
+// Compressed returns a cache storing its entries in c, compressing the values of type []byte and
+// string larger than threshold bytes. This bounds the memory held by caches of large marshaled
+// documents, at the expense of the CPU spent compressing and decompressing them.
+//
+// The compressed cache takes over the values of c, which shouldn't be used directly afterwards.
+// Values passed to the eviction callback are decompressed. The statistics and expiration of entries
+// are those of c.
+func Compressed(c Cache, threshold int) Cache {
+	return &compressedCache[interface{}, interface{}]{
+		c: c,
+		encode: func(value interface{}) interface{} {
+			switch v := value.(type) {
+			case []byte:
+				if len(v) > threshold {
+					if data, ok := compress(v); ok {
+						return &compressedValue{data: data}
+					}
+				}
+			case string:
+				if len(v) > threshold {
+					if data, ok := compress([]byte(v)); ok {
+						return &compressedValue{data: data, string: true}
+					}
+				}
+			}
+			return value
+		},
+		decode: func(value interface{}) (interface{}, bool) {
+			cv, ok := value.(*compressedValue)
+			if !ok {
+				return value, true
+			}
+			data, err := decompress(cv.data)
+			if err != nil {
+				return nil, false
+			}
+			if cv.string {
+				return string(data), true
+			}
+			return data, true
+		},
+	}
+}
+
+// NewTypedCompressed returns a cache storing its entries in c, compressing the values larger than
+// threshold bytes. Every value stored in c is prefixed with a tag byte, so that caches bounded by
+// the size of their values measure them as stored. See also: Compressed.
+func NewTypedCompressed[K comparable, V ~[]byte | ~string](c Typed[K, V], threshold int) Typed[K, V] {
+	return &compressedCache[K, V]{
+		c: c,
+		encode: func(value V) V {
+			if len(value) > threshold {
+				if data, ok := compress([]byte(value)); ok {
+					return V(append([]byte{tagFlate}, data...))
+				}
+			}
+			return V(append([]byte{tagRaw}, value...))
+		},
+		decode: func(value V) (V, bool) {
+			if len(value) == 0 {
+				return value, false
+			}
+			switch value[0] {
+			case tagRaw:
+				return value[1:], true
+			case tagFlate:
+				data, err := decompress([]byte(value[1:]))
+				if err == nil {
+					return V(data), true
+				}
+			}
+			var zero V
+			return zero, false
+		},
+	}
+}
+
+var flateWriters = sync.Pool{
+	New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	},
+}
+
+// compress returns the compressed form of data, and false if that isn't smaller than data.
+func compress(data []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	w := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(w)
+
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, false
+	}
+	if err := w.Close(); err != nil {
+		return nil, false
+	}
+	if buf.Len() >= len(data) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+func decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (c *compressedCache[K, V]) Set(key K, value V) {
+	c.c.Set(key, c.encode(value))
+}
+
+func (c *compressedCache[K, V]) SetAll(entries map[K]V) {
+	encoded := make(map[K]V, len(entries))
+	for key, value := range entries {
+		encoded[key] = c.encode(value)
+	}
+	c.c.SetAll(encoded)
+}
+
+func (c *compressedCache[K, V]) Get(key K) (V, bool) {
+	value, ok := c.c.Get(key)
+	if !ok {
+		return value, false
+	}
+	return c.decode(value)
+}
+
+func (c *compressedCache[K, V]) GetAll(keys []K) map[K]V {
+	result := c.c.GetAll(keys)
+	for key, value := range result {
+		if decoded, ok := c.decode(value); ok {
+			result[key] = decoded
+		} else {
+			delete(result, key)
+		}
+	}
+	return result
+}
+
+func (c *compressedCache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	return c.loads.GetOrLoad(c, key, loader)
+}
+
+func (c *compressedCache[K, V]) GetOrLoadCtx(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error) {
+	return c.loads.GetOrLoadCtx(ctx, c, key, loader)
+}
+
+// Increment is passed on to the underlying cache, since integers aren't compressed.
+func (c *compressedCache[K, V]) Increment(key K, delta int64) (int64, error) {
+	return c.c.Increment(key, delta)
+}
+
+func (c *compressedCache[K, V]) LockKey(key K) func() {
+	return c.c.LockKey(key)
+}
+
+func (c *compressedCache[K, V]) Remove(key K) {
+	c.c.Remove(key)
+}
+
+func (c *compressedCache[K, V]) RemoveAll() {
+	c.c.RemoveAll()
+}
+
+func (c *compressedCache[K, V]) RemoveIf(predicate func(key K, value V) bool) int {
+	return c.c.RemoveIf(func(key K, value V) bool {
+		decoded, ok := c.decode(value)
+		return ok && predicate(key, decoded)
+	})
+}
+
+func (c *compressedCache[K, V]) Preload(entries map[K]V) {
+	encoded := make(map[K]V, len(entries))
+	for key, value := range entries {
+		encoded[key] = c.encode(value)
+	}
+	c.c.Preload(encoded)
+}
+
+func (c *compressedCache[K, V]) ForEach(f func(key K, value V) bool) {
+	c.c.ForEach(func(key K, value V) bool {
+		decoded, ok := c.decode(value)
+		if !ok {
+			return true
+		}
+		return f(key, decoded)
+	})
+}
+
+func (c *compressedCache[K, V]) Snapshot() map[K]V {
+	result := c.c.Snapshot()
+	for key, value := range result {
+		if decoded, ok := c.decode(value); ok {
+			result[key] = decoded
+		} else {
+			delete(result, key)
+		}
+	}
+	return result
+}
+
+func (c *compressedCache[K, V]) Stats() Stats {
+	return c.c.Stats()
+}
+
+func (c *compressedCache[K, V]) StatsDelta() Stats {
+	return c.c.StatsDelta()
+}
+
+func (c *compressedCache[K, V]) ResetStats() {
+	c.c.ResetStats()
+}
+
+func (c *compressedCache[K, V]) Len() int {
+	return c.c.Len()
+}
+
+func (c *compressedCache[K, V]) SetEvictionCallback(callback func(key K, value V, reason EvictionReason)) {
+	if callback == nil {
+		c.c.SetEvictionCallback(nil)
+		return
+	}
+	c.c.SetEvictionCallback(func(key K, value V, reason EvictionReason) {
+		if decoded, ok := c.decode(value); ok {
+			callback(key, decoded, reason)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCompressed() Cache {
+	return Compressed(NewLRU(5*time.Minute, 1*time.Minute, 500), 0)
+}
+
+func TestCompressedBasic(t *testing.T) {
+	testCacheBasic(newTestCompressed(), t)
+}
+
+func TestCompressedConcurrent(t *testing.T) {
+	testCacheConcurrent(newTestCompressed(), t)
+}
+
+func TestCompressedGetOrLoad(t *testing.T) {
+	testCacheGetOrLoad(newTestCompressed(), t)
+	testCacheGetOrLoadCtx(newTestCompressed(), t)
+}
+
+func TestCompressedBatch(t *testing.T) {
+	testCacheBatch(newTestCompressed(), t)
+}
+
+func TestCompressedIncrement(t *testing.T) {
+	testCacheIncrement(newTestCompressed(), t)
+}
+
+func TestCompressedLockKey(t *testing.T) {
+	testCacheLockKey(newTestCompressed(), t)
+}
+
+func TestCompressedRemoveIf(t *testing.T) {
+	testCacheRemoveIf(newTestCompressed(), t)
+}
+
+func TestCompressedStatsDelta(t *testing.T) {
+	testCacheStatsDelta(newTestCompressed(), t)
+}
+
+func TestCompressedPreloadSnapshot(t *testing.T) {
+	testCachePreloadSnapshot(newTestCompressed(), t)
+}
+
+func TestCompressedValues(t *testing.T) {
+	lru := NewLRU(5*time.Minute, 0, 10)
+	c := Compressed(lru, 64)
+
+	large := strings.Repeat("compressible ", 100)
+	c.Set("STRING", large)
+	c.Set("BYTES", []byte(large))
+	c.Set("SMALL", "small")
+	c.Set("INT", 42)
+
+	if value, ok := c.Get("STRING"); !ok || value != large {
+		t.Errorf("Got %.20v, %t, expected the large string back", value, ok)
+	}
+	if value, ok := c.Get("BYTES"); !ok || !bytes.Equal(value.([]byte), []byte(large)) {
+		t.Errorf("Got %.20v, %t, expected the large byte slice back", value, ok)
+	}
+	if value, ok := c.Get("SMALL"); !ok || value != "small" {
+		t.Errorf("Got %v, %t, expected small", value, ok)
+	}
+	if value, ok := c.Get("INT"); !ok || value != 42 {
+		t.Errorf("Got %v, %t, expected 42", value, ok)
+	}
+
+	// only the large values are compressed in the underlying cache
+	for _, key := range []string{"STRING", "BYTES"} {
+		value, _ := lru.Get(key)
+		if cv, ok := value.(*compressedValue); !ok || len(cv.data) >= len(large) {
+			t.Errorf("Got %T for %s, expected a compressed value", value, key)
+		}
+	}
+	if value, _ := lru.Get("SMALL"); value != "small" {
+		t.Errorf("Got %v, expected the small value to be stored as is", value)
+	}
+
+	var evicted interface{}
+	c.SetEvictionCallback(func(key, value interface{}, reason EvictionReason) {
+		evicted = value
+	})
+	c.Remove("STRING")
+	if evicted != large {
+		t.Errorf("Got %.20v, expected the eviction callback to get the decompressed value", evicted)
+	}
+}
+
+func TestTypedCompressed(t *testing.T) {
+	sized := NewTypedSizedLRU[string, []byte](5*time.Minute, 0, 1000, func(key string, value []byte) int64 {
+		return int64(len(value))
+	})
+	c := NewTypedCompressed[string, []byte](sized, 64)
+
+	// the values are measured compressed, so many more of them fit
+	large := bytes.Repeat([]byte("compressible "), 100)
+	for _, key := range []string{"A", "B", "C"} {
+		c.Set(key, large)
+	}
+	c.Set("SMALL", []byte("small"))
+
+	if n := c.Len(); n != 4 {
+		t.Errorf("Got %d entries, expected 4", n)
+	}
+	if value, ok := c.Get("A"); !ok || !bytes.Equal(value, large) {
+		t.Errorf("Got %d bytes, %t, expected the large value back", len(value), ok)
+	}
+	if value, ok := c.Get("SMALL"); !ok || string(value) != "small" {
+		t.Errorf("Got %q, %t, expected small", value, ok)
+	}
+	if got := c.Snapshot(); len(got) != 4 || !bytes.Equal(got["B"], large) {
+		t.Errorf("Got %d entries, expected the snapshot to hold the decompressed values", len(got))
+	}
+}
@@ -0,0 +1,681 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// The disk cache keeps its entries in a BoltDB store within a directory, such that the
+// contents of the cache survive restarts and can grow beyond what fits in memory. Nothing
+// about the entries is held in memory: the store holds the entries, and an index of the
+// entries by expiration time such that evictions only visit the expired entries.
+//
+// Keys and values are encoded with encoding/gob, and entries are looked up by the encoding
+// of their key. As gob encodes the values pointers point to rather than the pointers, keys
+// holding pointers are identified by the values they point to, and keys whose encoding
+// differs while they are equal, such as keys holding interfaces, are distinct entries.
+//
+// Lookups run concurrently, while writes are serialized by the store. The store isn't
+// synced to disk after every write, so the writes of the last moments may be lost, and
+// the store may be corrupted by a system crash, in which case it is recreated empty.
+//
+// The Cache interface has no room for reporting errors, so failing to write an entry is
+// treated like the entry having been evicted immediately, and failing to read an entry
+// is reported as a miss.
+//
+// Just like for the LRU cache, the same finalizer trickery is used to stop the evicter
+// goroutine once the cache is no longer referenced, at which point the store is closed.
+
+const (
+	diskStoreName = "cache.db"
+
+	// diskBatch is the number of entries read at a time when iterating over the cache, such
+	// that the callbacks run outside of the transactions of the store.
+	diskBatch = 100
+)
+
+var (
+	diskEntries     = []byte("entries")     // encoded key => expiration and encoded value
+	diskExpirations = []byte("expirations") // expiration and encoded key => nothing
+)
+
+// See use of SetFinalizer below for an explanation of this weird composition
+type diskWrapper[K comparable, V any] struct {
+	*diskCache[K, V]
+}
+
+type diskCache[K comparable, V any] struct {
+	// baseTimeNanos must be at start of struct to ensure 64bit alignment for atomics on
+	// 32bit architectures. See also: https://golang.org/pkg/sync/atomic/#pkg-note-BUG
+	baseTimeNanos int64
+	len           int64
+	stats         Stats
+
+	// held by the writes, which record the evictions to notify, and the changes of the transaction
+	// in progress to the number of entries and the stats, applied once it commits
+	sync.Mutex
+	txLen   int64
+	txStats Stats
+
+	db                *bolt.DB
+	defaultExpiration time.Duration
+	stopEvicter       chan bool
+	evicterTerminated sync.WaitGroup // used by unit tests to verify the finalizer ran
+	evictionNotifier[K, V]
+	clockHolder
+	refreshAhead[K, V]
+	statsWindow
+	loads    LoadGroup[K, V]
+	keyLocks KeyLocks[K]
+}
+
+// NewDisk creates a new cache with a time-based eviction model, holding its entries in a BoltDB
+// store within dir such that they survive restarts. The directory is created if needed, and the
+// entries left in it by a previous instance are kept, except those which expired in the meantime.
+// Only a single cache can use the directory at a time, across processes: creating a cache over a
+// directory in use fails, until the cache using it is garbage collected.
+//
+// Keys and values are stored using encoding/gob, which requires the concrete types
+// of the keys and values held in the cache to be registered with gob.Register, unless
+// they are basic types such as strings and integers. Use NewTypedDisk to avoid this.
+//
+// Cache eviction is done on a periodic basis. Individual cache entries are evicted
+// after their expiration time has passed. The periodic nature of eviction means that
+// cache entries tend to survive around (expirationTime + (evictionInterval / 2))
+//
+// defaultExpiration specifies the default minimum amount of time a cached
+// entry remains in the cache before eviction. This value is used with the
+// Set function. Explicit per-entry expiration times can be set with the
+// SetWithExpiration function instead.
+//
+// evictionInterval specifies the frequency at which eviction activities take
+// place. This should likely be >= 1 second.
+func NewDisk(dir string, defaultExpiration time.Duration, evictionInterval time.Duration) (ExpiringCache, error) {
+	return NewTypedDisk[interface{}, interface{}](dir, defaultExpiration, evictionInterval)
+}
+
+// NewTypedDisk creates a new cache with a time-based eviction model, holding its entries in a
+// BoltDB store within dir, with keys of type K and values of type V. See also: NewDisk.
+func NewTypedDisk[K comparable, V any](dir string, defaultExpiration time.Duration, evictionInterval time.Duration) (Expiring[K, V], error) {
+	c := &diskCache[K, V]{
+		defaultExpiration: defaultExpiration,
+	}
+
+	c.baseTimeNanos = time.Now().UnixNano()
+	if err := c.open(dir); err != nil {
+		return nil, err
+	}
+	c.evictExpired(time.Now())
+
+	if evictionInterval > 0 {
+		c.stopEvicter = make(chan bool, 1)
+		c.evicterTerminated.Add(1)
+		go c.evicter(evictionInterval)
+
+		// We return a 'see-through' wrapper for the real object such that
+		// the finalizer can trigger on the wrapper. We can't set a finalizer
+		// on the main cache object because it would never fire, since the
+		// evicter goroutine is keeping it alive
+		result := &diskWrapper[K, V]{c}
+		runtime.SetFinalizer(result, func(w *diskWrapper[K, V]) {
+			w.stopEvicter <- true
+			w.evicterTerminated.Wait()
+			_ = w.db.Close()
+		})
+		return result, nil
+	}
+
+	runtime.SetFinalizer(c, func(c *diskCache[K, V]) {
+		_ = c.db.Close()
+	})
+	return c, nil
+}
+
+// open opens the store within dir, recreating it if it can't be opened other than because another
+// cache uses it.
+func (c *diskCache[K, V]) open(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("unable to create cache directory: %v", err)
+	}
+
+	path := filepath.Join(dir, diskStoreName)
+	opts := &bolt.Options{Timeout: time.Second, NoSync: true, NoFreelistSync: true}
+	db, err := bolt.Open(path, 0600, opts)
+	if err != nil && !errors.Is(err, bolt.ErrTimeout) {
+		_ = os.Remove(path)
+		db, err = bolt.Open(path, 0600, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to open cache store %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		entries, err := tx.CreateBucketIfNotExists(diskEntries)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(diskExpirations); err != nil {
+			return err
+		}
+		c.len = int64(entries.Stats().KeyN)
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return fmt.Errorf("unable to initialize cache store %s: %v", path, err)
+	}
+
+	c.db = db
+	return nil
+}
+
+func encodeDiskKey[K comparable](key K) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&key); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeDiskKey[K comparable](b []byte) (K, error) {
+	var key K
+	err := gob.NewDecoder(bytes.NewReader(b)).Decode(&key)
+	return key, err
+}
+
+// encodeDiskEntry encodes a value along with its expiration in nanoseconds, which comes first such
+// that it is read without decoding the value.
+func encodeDiskEntry[V any](value V, exp int64) ([]byte, error) {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.BigEndian, exp)
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeDiskEntry[V any](b []byte) (V, int64, error) {
+	var value V
+	if len(b) < 8 {
+		return value, 0, errors.New("truncated cache entry")
+	}
+	exp := int64(binary.BigEndian.Uint64(b))
+	err := gob.NewDecoder(bytes.NewReader(b[8:])).Decode(&value)
+	return value, exp, err
+}
+
+// expirationKey returns the key of an entry in the index by expiration.
+func expirationKey(exp int64, k []byte) []byte {
+	b := make([]byte, 8, 8+len(k))
+	binary.BigEndian.PutUint64(b, uint64(exp))
+	return append(b, k...)
+}
+
+func (c *diskCache[K, V]) evicter(evictionInterval time.Duration) {
+	// Wake up once in a while and evict stale items
+	ticker := time.NewTicker(evictionInterval)
+	for {
+		select {
+		case <-ticker.C:
+			c.evictExpired(c.now())
+		case <-c.stopEvicter:
+			ticker.Stop()
+			c.evicterTerminated.Done() // record this for the sake of unit tests
+			return
+		}
+	}
+}
+
+func (c *diskCache[K, V]) evictExpired(t time.Time) {
+	// We snapshot a base time here such that the time doesn't need to be
+	// sampled in the Set call as calling time.Now() is relatively expensive.
+	// Doing it here provides enough precision for our needs and tends to have
+	// much lower call frequency.
+	n := t.UnixNano()
+	atomic.StoreInt64(&c.baseTimeNanos, n)
+
+	c.Lock()
+	_ = c.update(func(tx *bolt.Tx) error {
+		var expired [][]byte
+		cur := tx.Bucket(diskExpirations).Cursor()
+		for k, _ := cur.First(); k != nil && int64(binary.BigEndian.Uint64(k)) <= n; k, _ = cur.Next() {
+			expired = append(expired, append([]byte(nil), k[8:]...))
+		}
+		for _, k := range expired {
+			c.remove(tx, k, ReasonExpired)
+		}
+		return nil
+	})
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *diskCache[K, V]) EvictExpired() {
+	c.evictExpired(c.now())
+}
+
+func (c *diskCache[K, V]) SetClock(clock Clock) {
+	c.setClock(clock)
+	atomic.StoreInt64(&c.baseTimeNanos, c.now().UnixNano())
+}
+
+// update runs fn in a write transaction, and once it commits, applies the changes it recorded to
+// the number of entries and the stats. The evictions it recorded are dropped if it doesn't. The
+// caller must hold the lock.
+func (c *diskCache[K, V]) update(fn func(tx *bolt.Tx) error) error {
+	c.txLen, c.txStats = 0, Stats{}
+	pending := len(c.pending)
+	if err := c.db.Update(fn); err != nil {
+		c.pending = c.pending[:pending]
+		return err
+	}
+
+	atomic.AddInt64(&c.len, c.txLen)
+	atomic.AddUint64(&c.stats.Evictions, c.txStats.Evictions)
+	atomic.AddUint64(&c.stats.Expirations, c.txStats.Expirations)
+	atomic.AddUint64(&c.stats.CapacityEvictions, c.txStats.CapacityEvictions)
+	atomic.AddUint64(&c.stats.Removals, c.txStats.Removals)
+	return nil
+}
+
+// remove deletes the entry with the encoded key k, recording its eviction for the given reason,
+// and returns whether it existed. The caller must hold the lock.
+func (c *diskCache[K, V]) remove(tx *bolt.Tx, k []byte, reason EvictionReason) bool {
+	entries := tx.Bucket(diskEntries)
+	b := entries.Get(k)
+	if b == nil {
+		return false
+	}
+
+	if c.wants(reason) {
+		key, kerr := decodeDiskKey[K](k)
+		value, _, verr := decodeDiskEntry[V](b)
+		if kerr == nil && verr == nil {
+			c.evicted(key, value, reason)
+		}
+	}
+	if len(b) >= 8 {
+		_ = tx.Bucket(diskExpirations).Delete(expirationKey(int64(binary.BigEndian.Uint64(b)), k))
+	}
+	if entries.Delete(k) != nil {
+		return false
+	}
+
+	c.txLen--
+	if reason == ReasonRemoved {
+		c.txStats.Removals++
+	} else {
+		c.txStats.recordEviction(reason)
+	}
+	return true
+}
+
+// put stores the entry for the encoded key k, replacing any previous entry. The caller must hold
+// the lock.
+func (c *diskCache[K, V]) put(tx *bolt.Tx, k []byte, value V, exp int64) error {
+	b, err := encodeDiskEntry(value, exp)
+	if err != nil {
+		return err
+	}
+
+	entries, expirations := tx.Bucket(diskEntries), tx.Bucket(diskExpirations)
+	old := entries.Get(k)
+	if len(old) >= 8 {
+		if err := expirations.Delete(expirationKey(int64(binary.BigEndian.Uint64(old)), k)); err != nil {
+			return err
+		}
+	}
+	if err := entries.Put(k, b); err != nil {
+		return err
+	}
+	if err := expirations.Put(expirationKey(exp, k), nil); err != nil {
+		return err
+	}
+	if old == nil {
+		c.txLen++
+	}
+	return nil
+}
+
+func (c *diskCache[K, V]) Set(key K, value V) {
+	c.SetWithExpiration(key, value, c.defaultExpiration)
+}
+
+func (c *diskCache[K, V]) SetWithExpiration(key K, value V, expiration time.Duration) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + expiration.Nanoseconds()
+
+	c.Lock()
+	c.set(map[K]V{key: value}, exp)
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *diskCache[K, V]) SetAll(entries map[K]V) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + c.defaultExpiration.Nanoseconds()
+
+	c.Lock()
+	c.set(entries, exp)
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+// set stores entries in a single transaction. The caller must hold the lock.
+func (c *diskCache[K, V]) set(entries map[K]V, exp int64) {
+	atomic.AddUint64(&c.stats.Writes, uint64(len(entries)))
+	err := c.update(func(tx *bolt.Tx) error {
+		for key, value := range entries {
+			k, err := encodeDiskKey(key)
+			if err != nil {
+				return err
+			}
+			if err := c.put(tx, k, value, exp); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		return
+	}
+
+	// the transaction was rolled back, don't leave stale values behind
+	_ = c.update(func(tx *bolt.Tx) error {
+		for key := range entries {
+			if k, err := encodeDiskKey(key); err == nil {
+				c.remove(tx, k, ReasonCapacity)
+			}
+		}
+		return nil
+	})
+}
+
+func (c *diskCache[K, V]) Get(key K) (V, bool) {
+	value, exp, ok := c.get(key)
+	if ok {
+		c.check(key, exp, atomic.LoadInt64(&c.baseTimeNanos), c.Set)
+	}
+	return value, ok
+}
+
+func (c *diskCache[K, V]) GetAll(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+	for _, key := range keys {
+		if value, ok := c.Get(key); ok {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// get looks up key, and returns its value and expiration.
+func (c *diskCache[K, V]) get(key K) (value V, exp int64, ok bool) {
+	if k, err := encodeDiskKey(key); err == nil {
+		_ = c.db.View(func(tx *bolt.Tx) error {
+			if b := tx.Bucket(diskEntries).Get(k); b != nil {
+				var err error
+				value, exp, err = decodeDiskEntry[V](b)
+				ok = err == nil
+			}
+			return nil
+		})
+	}
+
+	if ok {
+		atomic.AddUint64(&c.stats.Hits, 1)
+	} else {
+		atomic.AddUint64(&c.stats.Misses, 1)
+	}
+	return value, exp, ok
+}
+
+func (c *diskCache[K, V]) Increment(key K, delta int64) (int64, error) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + c.defaultExpiration.Nanoseconds()
+	k, err := encodeDiskKey(key)
+	if err != nil {
+		return 0, fmt.Errorf("cache: unable to encode key %v: %v", key, err)
+	}
+
+	var n int64
+	var incErr error
+	c.Lock()
+	atomic.AddUint64(&c.stats.Writes, 1)
+	err = c.update(func(tx *bolt.Tx) error {
+		var value V
+		found := false
+		if b := tx.Bucket(diskEntries).Get(k); b != nil {
+			if old, oldExp, err := decodeDiskEntry[V](b); err == nil {
+				value, exp, found = old, oldExp, true
+			}
+		}
+		if value, n, incErr = IncrementValue(value, found, delta); incErr != nil {
+			return incErr
+		}
+		return c.put(tx, k, value, exp)
+	})
+	if err != nil && incErr == nil {
+		// don't leave a stale value behind
+		_ = c.update(func(tx *bolt.Tx) error {
+			c.remove(tx, k, ReasonCapacity)
+			return nil
+		})
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (c *diskCache[K, V]) LockKey(key K) func() {
+	return c.keyLocks.Lock(key)
+}
+
+func (c *diskCache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	return c.loads.GetOrLoad(c, key, loader)
+}
+
+func (c *diskCache[K, V]) GetOrLoadCtx(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error) {
+	return c.loads.GetOrLoadCtx(ctx, c, key, loader)
+}
+
+func (c *diskCache[K, V]) Remove(key K) {
+	k, err := encodeDiskKey(key)
+	if err != nil {
+		return
+	}
+
+	c.Lock()
+	_ = c.update(func(tx *bolt.Tx) error {
+		c.remove(tx, k, ReasonRemoved)
+		return nil
+	})
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *diskCache[K, V]) RemoveAll() {
+	c.RemoveIf(func(key K, value V) bool {
+		return true
+	})
+}
+
+// RemoveIf calls predicate while holding a transaction of the store, so it must not call back
+// into the cache.
+func (c *diskCache[K, V]) RemoveIf(predicate func(key K, value V) bool) int {
+	n := 0
+
+	c.Lock()
+	_ = c.update(func(tx *bolt.Tx) error {
+		var matching [][]byte
+		err := tx.Bucket(diskEntries).ForEach(func(k, b []byte) error {
+			key, kerr := decodeDiskKey[K](k)
+			value, _, verr := decodeDiskEntry[V](b)
+			// the entries which can't be decoded can't be read either
+			if kerr != nil || verr != nil || predicate(key, value) {
+				matching = append(matching, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range matching {
+			if c.remove(tx, k, ReasonRemoved) {
+				n++
+			}
+		}
+		return nil
+	})
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+
+	return n
+}
+
+func (c *diskCache[K, V]) Preload(entries map[K]V) {
+	c.SetAll(entries)
+}
+
+func (c *diskCache[K, V]) Snapshot() map[K]V {
+	result := make(map[K]V)
+	c.forEach(func(key K, value V, exp int64) bool {
+		result[key] = value
+		return true
+	})
+	return result
+}
+
+// ForEach reads the entries in batches, and calls f outside of the transactions of the store,
+// such that f is free to call back into the cache.
+func (c *diskCache[K, V]) ForEach(f func(key K, value V) bool) {
+	c.forEachTTL(func(key K, value V, _ time.Duration) bool {
+		return f(key, value)
+	})
+}
+
+// forEachTTL is like ForEach, also passing the time left before each entry expires to f.
+func (c *diskCache[K, V]) forEachTTL(f func(key K, value V, ttl time.Duration) bool) {
+	now := c.now().UnixNano()
+	c.forEach(func(key K, value V, exp int64) bool {
+		return exp <= now || f(key, value, time.Duration(exp-now))
+	})
+}
+
+// forEach calls f with each entry and its expiration, including the expired entries which weren't
+// evicted yet, until f returns false.
+func (c *diskCache[K, V]) forEach(f func(key K, value V, exp int64) bool) {
+	type entry struct {
+		key   K
+		value V
+		exp   int64
+	}
+	var after []byte
+	for {
+		batch := make([]entry, 0, diskBatch)
+		_ = c.db.View(func(tx *bolt.Tx) error {
+			cur := tx.Bucket(diskEntries).Cursor()
+			k, b := cur.First()
+			if after != nil {
+				k, b = cur.Seek(after)
+				if bytes.Equal(k, after) {
+					k, b = cur.Next()
+				}
+			}
+			for ; k != nil && len(batch) < diskBatch; k, b = cur.Next() {
+				after = append(after[:0], k...)
+				key, kerr := decodeDiskKey[K](k)
+				value, exp, verr := decodeDiskEntry[V](b)
+				if kerr == nil && verr == nil {
+					batch = append(batch, entry{key, value, exp})
+				}
+			}
+			if k == nil {
+				after = nil
+			}
+			return nil
+		})
+
+		for _, e := range batch {
+			if !f(e.key, e.value, e.exp) {
+				return
+			}
+		}
+		if after == nil {
+			return
+		}
+	}
+}
+
+func (c *diskCache[K, V]) WriteTo(w io.Writer) (int64, error) {
+	return WriteEntries[K, V](w, c.forEachTTL)
+}
+
+func (c *diskCache[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	return ReadEntries[K, V](r, c.SetWithExpiration)
+}
+
+func (c *diskCache[K, V]) Len() int {
+	return int(atomic.LoadInt64(&c.len))
+}
+
+func (c *diskCache[K, V]) Stats() Stats {
+	return Stats{
+		Writes:            atomic.LoadUint64(&c.stats.Writes),
+		Hits:              atomic.LoadUint64(&c.stats.Hits),
+		Misses:            atomic.LoadUint64(&c.stats.Misses),
+		Evictions:         atomic.LoadUint64(&c.stats.Evictions),
+		Expirations:       atomic.LoadUint64(&c.stats.Expirations),
+		CapacityEvictions: atomic.LoadUint64(&c.stats.CapacityEvictions),
+		Removals:          atomic.LoadUint64(&c.stats.Removals),
+	}
+}
+
+func (c *diskCache[K, V]) StatsDelta() Stats {
+	return c.delta(c.Stats())
+}
+
+func (c *diskCache[K, V]) ResetStats() {
+	atomic.StoreUint64(&c.stats.Writes, 0)
+	atomic.StoreUint64(&c.stats.Hits, 0)
+	atomic.StoreUint64(&c.stats.Misses, 0)
+	atomic.StoreUint64(&c.stats.Evictions, 0)
+	atomic.StoreUint64(&c.stats.Expirations, 0)
+	atomic.StoreUint64(&c.stats.CapacityEvictions, 0)
+	atomic.StoreUint64(&c.stats.Removals, 0)
+	c.reset()
+}
@@ -0,0 +1,228 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestDisk(t testing.TB, defaultExpiration time.Duration, evictionInterval time.Duration) ExpiringCache {
+	c, err := NewDisk(t.TempDir(), defaultExpiration, evictionInterval)
+	if err != nil {
+		t.Fatalf("Unable to create disk cache: %v", err)
+	}
+	return c
+}
+
+func TestDiskBasic(t *testing.T) {
+	disk := newTestDisk(t, 5*time.Minute, 1*time.Millisecond)
+	testCacheBasic(disk, t)
+}
+
+func TestDiskExpiration(t *testing.T) {
+	disk := newTestDisk(t, 5*time.Second, 0).(*diskCache[interface{}, interface{}])
+	testCacheExpiration(disk, disk.evictExpired, t)
+}
+
+func TestDiskEvicter(t *testing.T) {
+	disk := newTestDisk(t, 5*time.Second, 1*time.Millisecond)
+	testCacheEvicter(disk)
+}
+
+func TestDiskEvictExpired(t *testing.T) {
+	disk := newTestDisk(t, 5*time.Second, 0).(*diskCache[interface{}, interface{}])
+	testCacheEvictExpired(disk, t)
+}
+
+func TestDiskSetEvictionCallback(t *testing.T) {
+	disk := newTestDisk(t, 5*time.Second, 0).(*diskCache[interface{}, interface{}])
+	testCacheEvictionCallback(disk, disk.evictExpired, t)
+}
+
+func TestDiskSetExpirationCallback(t *testing.T) {
+	disk := newTestDisk(t, 5*time.Second, 0).(*diskCache[interface{}, interface{}])
+	testCacheExpirationCallback(disk, disk.evictExpired, t)
+}
+
+func TestDiskGetOrLoad(t *testing.T) {
+	testCacheGetOrLoad(newTestDisk(t, 5*time.Minute, 1*time.Minute), t)
+	testCacheGetOrLoadCtx(newTestDisk(t, 5*time.Minute, 1*time.Minute), t)
+}
+
+func TestDiskBatch(t *testing.T) {
+	testCacheBatch(newTestDisk(t, 5*time.Minute, 1*time.Minute), t)
+}
+
+func TestDiskForEach(t *testing.T) {
+	testCacheForEach(newTestDisk(t, 5*time.Minute, 1*time.Minute), t)
+}
+
+func TestDiskIncrement(t *testing.T) {
+	testCacheIncrement(newTestDisk(t, 5*time.Minute, 1*time.Minute), t)
+}
+
+func TestDiskLockKey(t *testing.T) {
+	testCacheLockKey(newTestDisk(t, 5*time.Minute, 1*time.Minute), t)
+}
+
+func TestDiskClock(t *testing.T) {
+	testCacheClock(newTestDisk(t, 5*time.Minute, 1*time.Minute), t)
+}
+
+func TestDiskRefreshAhead(t *testing.T) {
+	testCacheRefreshAhead(newTestDisk(t, 5*time.Minute, 1*time.Minute), t)
+}
+
+func TestDiskWriteTo(t *testing.T) {
+	testCacheWriteTo(newTestDisk(t, 5*time.Minute, 1*time.Minute), newTestDisk(t, 5*time.Minute, 1*time.Minute), t)
+}
+
+func TestDiskRemoveIf(t *testing.T) {
+	testCacheRemoveIf(newTestDisk(t, 5*time.Minute, 1*time.Minute), t)
+}
+
+func TestDiskStatsDelta(t *testing.T) {
+	testCacheStatsDelta(newTestDisk(t, 5*time.Minute, 1*time.Minute), t)
+}
+
+func TestDiskPreloadSnapshot(t *testing.T) {
+	testCachePreloadSnapshot(newTestDisk(t, 5*time.Minute, 1*time.Minute), t)
+}
+
+func TestDiskFinalizer(t *testing.T) {
+	disk := newTestDisk(t, 5*time.Second, 1*time.Millisecond).(*diskWrapper[interface{}, interface{}])
+	testCacheFinalizer(&disk.evicterTerminated)
+}
+
+func TestDiskPersistence(t *testing.T) {
+	type payload struct {
+		Name  string
+		Count int
+	}
+
+	dir := t.TempDir()
+	disk, err := NewTypedDisk[string, payload](dir, 5*time.Minute, 0)
+	if err != nil {
+		t.Fatalf("Unable to create disk cache: %v", err)
+	}
+
+	disk.Set("A", payload{"a", 1})
+	disk.Set("B", payload{"b", 2})
+	disk.SetWithExpiration("C", payload{"c", 3}, time.Nanosecond)
+	disk.Remove("B")
+
+	// the store can only be used by a single cache at a time
+	if _, err := NewTypedDisk[string, payload](dir, 5*time.Minute, 0); err == nil {
+		t.Error("Got success, expecting the store to be in use")
+	}
+	_ = disk.(*diskCache[string, payload]).db.Close()
+
+	// a new cache over the same directory picks up where the previous one left off
+	time.Sleep(time.Millisecond)
+	disk, err = NewTypedDisk[string, payload](dir, 5*time.Minute, 0)
+	if err != nil {
+		t.Fatalf("Unable to reopen disk cache: %v", err)
+	}
+
+	if v, ok := disk.Get("A"); !ok || v != (payload{"a", 1}) {
+		t.Errorf("Got %v, %v for A, expecting it to have survived", v, ok)
+	}
+	if _, ok := disk.Get("B"); ok {
+		t.Error("Got an entry for B, expecting it to remain removed")
+	}
+	if _, ok := disk.Get("C"); ok {
+		t.Error("Got an entry for C, expecting it to have expired")
+	}
+	if n := disk.Len(); n != 1 {
+		t.Errorf("Got %d entries, expecting only A to remain", n)
+	}
+	_ = disk.(*diskCache[string, payload]).db.Close()
+
+	// a store which can't be opened is recreated
+	if err := os.WriteFile(filepath.Join(dir, diskStoreName), []byte("corrupt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	disk, err = NewTypedDisk[string, payload](dir, 5*time.Minute, 0)
+	if err != nil {
+		t.Fatalf("Unable to recreate disk cache: %v", err)
+	}
+	if n := disk.Len(); n != 0 {
+		t.Errorf("Got %d entries, expecting the recreated cache to be empty", n)
+	}
+	_ = disk.(*diskCache[string, payload]).db.Close()
+}
+
+func TestDiskPointerKeys(t *testing.T) {
+	type key struct {
+		Name *string
+	}
+
+	disk, err := NewTypedDisk[key, int](t.TempDir(), 5*time.Minute, 0)
+	if err != nil {
+		t.Fatalf("Unable to create disk cache: %v", err)
+	}
+
+	// keys holding pointers are identified by the values they point to
+	a1, a2 := "a", "a"
+	disk.Set(key{&a1}, 1)
+	if v, ok := disk.Get(key{&a2}); !ok || v != 1 {
+		t.Errorf("Got %v, %v, expecting the entry of the key pointing to the same value", v, ok)
+	}
+}
+
+func TestDiskForEachBatches(t *testing.T) {
+	disk, err := NewTypedDisk[int, int](t.TempDir(), 5*time.Minute, 0)
+	if err != nil {
+		t.Fatalf("Unable to create disk cache: %v", err)
+	}
+
+	entries := make(map[int]int)
+	for i := 0; i < 2*diskBatch+10; i++ {
+		entries[i] = i
+	}
+	disk.SetAll(entries)
+
+	// the entries are visited once, and f may call back into the cache
+	visited := make(map[int]bool)
+	disk.ForEach(func(key int, value int) bool {
+		if visited[key] || key != value {
+			t.Errorf("Got %d for %d, expecting each entry to be visited once", value, key)
+		}
+		visited[key] = true
+		disk.Remove(key)
+		return true
+	})
+	if len(visited) != len(entries) || disk.Len() != 0 {
+		t.Errorf("Got %d entries visited and %d left, expecting all %d to be visited and removed", len(visited), disk.Len(), len(entries))
+	}
+}
+
+func BenchmarkDiskGet(b *testing.B) {
+	c := newTestDisk(b, 5*time.Minute, 1*time.Minute)
+	benchmarkCacheGet(c, b)
+}
+
+func BenchmarkDiskSet(b *testing.B) {
+	c := newTestDisk(b, 5*time.Minute, 1*time.Minute)
+	benchmarkCacheSet(c, b)
+}
+
+func BenchmarkDiskSetRemove(b *testing.B) {
+	c := newTestDisk(b, 5*time.Minute, 1*time.Minute)
+	benchmarkCacheSetRemove(c, b)
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"sync/atomic"
+)
+
+// EvictionReason describes why an entry left a cache.
+type EvictionReason int
+
+const (
+	// ReasonExpired is used for entries removed because their expiration time passed.
+	ReasonExpired EvictionReason = iota
+
+	// ReasonCapacity is used for entries displaced to make room for other entries.
+	ReasonCapacity
+
+	// ReasonRemoved is used for entries explicitly removed from the cache.
+	ReasonRemoved
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// evictedEntry is an entry which left a cache, waiting for the eviction callback to be invoked.
+type evictedEntry[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictionReason
+}
+
+// evictionNotifier implements SetEvictionCallback and SetExpirationCallback for the caches. Caches
+// which are protected by a lock record evictions while holding it, and invoke the callbacks once the
+// lock is released so that callbacks are free to call back into the cache.
+type evictionNotifier[K comparable, V any] struct {
+	callback           atomic.Pointer[func(key K, value V, reason EvictionReason)]
+	expirationCallback atomic.Pointer[func(key K, value V)]
+
+	// pending holds the evictions recorded under the cache's lock
+	pending []evictedEntry[K, V]
+}
+
+func (n *evictionNotifier[K, V]) SetEvictionCallback(callback func(key K, value V, reason EvictionReason)) {
+	if callback == nil {
+		n.callback.Store(nil)
+		return
+	}
+	n.callback.Store(&callback)
+}
+
+func (n *evictionNotifier[K, V]) SetExpirationCallback(callback func(key K, value V)) {
+	if callback == nil {
+		n.expirationCallback.Store(nil)
+		return
+	}
+	n.expirationCallback.Store(&callback)
+}
+
+// wants returns whether a callback is registered for evictions with the given reason.
+func (n *evictionNotifier[K, V]) wants(reason EvictionReason) bool {
+	return n.callback.Load() != nil || (reason == ReasonExpired && n.expirationCallback.Load() != nil)
+}
+
+// evicted records an eviction to be notified once the cache's lock is released.
+// The caller must hold the cache's lock.
+func (n *evictionNotifier[K, V]) evicted(key K, value V, reason EvictionReason) {
+	if n.wants(reason) {
+		n.pending = append(n.pending, evictedEntry[K, V]{key: key, value: value, reason: reason})
+	}
+}
+
+// takePending returns the evictions recorded so far. The caller must hold the cache's lock.
+func (n *evictionNotifier[K, V]) takePending() []evictedEntry[K, V] {
+	p := n.pending
+	n.pending = nil
+	return p
+}
+
+// notify invokes the callbacks for each of evictions. The caller must not hold the cache's lock.
+func (n *evictionNotifier[K, V]) notify(evictions []evictedEntry[K, V]) {
+	for _, e := range evictions {
+		n.notifyOne(e.key, e.value, e.reason)
+	}
+}
+
+// notifyOne invokes the callbacks for a single eviction. The caller must not hold the cache's lock.
+func (n *evictionNotifier[K, V]) notifyOne(key K, value V, reason EvictionReason) {
+	if cb := n.callback.Load(); cb != nil {
+		(*cb)(key, value, reason)
+	}
+	if reason == ReasonExpired {
+		if cb := n.expirationCallback.Load(); cb != nil {
+			(*cb)(key, value)
+		}
+	}
+}
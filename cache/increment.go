@@ -0,0 +1,62 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// IncrementValue adds delta to value, returning the new value along with its value as an int64.
+// When found is false, value is ignored and delta is returned, as a V if V is an integer type or
+// as an int64 if V is an interface type. The result has the same type as value otherwise, and an
+// error is returned if that isn't an integer type.
+//
+// This implements the arithmetic of Typed.Increment, for use by implementations of Typed outside
+// of this package.
+func IncrementValue[V any](value V, found bool, delta int64) (V, int64, error) {
+	var n int64
+	t := reflect.TypeOf(&value).Elem()
+	if found {
+		v := reflect.ValueOf(&value).Elem()
+		if v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = v.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			n = int64(v.Uint())
+		default:
+			return value, 0, fmt.Errorf("cache: unable to increment a value of type %T", value)
+		}
+		t = v.Type()
+	} else if t.Kind() == reflect.Interface {
+		t = reflect.TypeOf(n)
+	}
+	n += delta
+
+	result := reflect.New(t).Elem()
+	switch result.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		result.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		result.SetUint(uint64(n))
+	default:
+		return value, 0, fmt.Errorf("cache: unable to increment a value of type %v", t)
+	}
+	return result.Interface().(V), n, nil
+}
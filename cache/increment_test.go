@@ -0,0 +1,45 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"testing"
+)
+
+func TestIncrementValue(t *testing.T) {
+	if v, n, err := IncrementValue[uint8](0, false, 3); err != nil || v != 3 || n != 3 {
+		t.Errorf("Got %v, %d, %v, expected uint8 3", v, n, err)
+	}
+
+	if v, n, err := IncrementValue[interface{}](nil, false, 3); err != nil || v != int64(3) || n != 3 {
+		t.Errorf("Got %v, %d, %v, expected int64 3", v, n, err)
+	}
+
+	if v, n, err := IncrementValue[interface{}](int32(7), true, -3); err != nil || v != int32(4) || n != 4 {
+		t.Errorf("Got %v, %d, %v, expected int32 4", v, n, err)
+	}
+
+	if v, n, err := IncrementValue[uint](7, true, 1); err != nil || v != 8 || n != 8 {
+		t.Errorf("Got %v, %d, %v, expected uint 8", v, n, err)
+	}
+
+	if _, _, err := IncrementValue[interface{}]("7", true, 1); err == nil {
+		t.Error("Got success, expected an error when incrementing a string")
+	}
+
+	if _, _, err := IncrementValue[float64](0, false, 1); err == nil {
+		t.Error("Got success, expected an error when incrementing a float")
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"sync"
+)
+
+// keyStripes is the number of mutexes keys are spread over by KeyLocks.
+const keyStripes = 256
+
+// KeyLocks implements LockKey on behalf of a cache. Keys are hashed onto a fixed set of mutexes,
+// such that distinct keys rarely contend and memory use doesn't grow with the number of keys. It is
+// exported for the sake of cache implementations living outside of this package. The zero value is
+// ready to use.
+type KeyLocks[K comparable] struct {
+	stripes [keyStripes]sync.Mutex
+}
+
+// Lock locks the mutex of key, and returns the function unlocking it.
+func (l *KeyLocks[K]) Lock(key K) func() {
+	mu := &l.stripes[hashKey(key)%keyStripes]
+	mu.Lock()
+	return mu.Unlock
+}
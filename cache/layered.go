@@ -0,0 +1,340 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// WriteMode determines when the entries written to a LayeredCache reach its slower layer.
+type WriteMode int
+
+const (
+	// WriteThrough writes entries to both layers right away.
+	WriteThrough WriteMode = iota
+
+	// WriteBack writes entries to the fast layer only, and to the slow layer once they are
+	// displaced from the fast layer or when the cache is flushed.
+	WriteBack
+)
+
+// LayeredCache combines a fast cache, typically in memory, with a slower but larger one, typically
+// on disk or remote. Lookups which miss in the fast layer fall through to the slow layer, and entries
+// found there are promoted to the fast layer.
+//
+// The layers are operated independently, such that the cache isn't strictly consistent under
+// concurrent use: for example a Get may promote an entry which a concurrent Remove just removed.
+type LayeredCache[K comparable, V any] struct {
+	// the counters must be at start of struct to ensure 64bit alignment for atomics on
+	// 32bit architectures. See also: https://golang.org/pkg/sync/atomic/#pkg-note-BUG
+	writes   uint64
+	hits     uint64
+	misses   uint64
+	removals uint64
+
+	l1, l2 Typed[K, V]
+	mode   WriteMode
+
+	// dirty holds the entries of l1 not yet written to l2 in WriteBack mode
+	mu    sync.Mutex
+	dirty map[K]V
+
+	callback atomic.Pointer[func(key K, value V, reason EvictionReason)]
+	loads    LoadGroup[K, V]
+	keyLocks KeyLocks[K]
+	statsWindow
+}
+
+var _ Cache = &LayeredCache[interface{}, interface{}]{}
+
+// Layered combines the fast cache l1 with the slower cache l2, see LayeredCache.
+//
+// The layered cache takes over the eviction callbacks of both layers, which shouldn't be used
+// directly afterwards. The expiration of entries and the bounds on the number of entries are
+// those of each layer.
+func Layered(l1, l2 Cache, mode WriteMode) *LayeredCache[interface{}, interface{}] {
+	return NewTypedLayered[interface{}, interface{}](l1, l2, mode)
+}
+
+// NewTypedLayered combines the fast cache l1 with the slower cache l2, holding keys of type K and
+// values of type V. See also: Layered.
+func NewTypedLayered[K comparable, V any](l1, l2 Typed[K, V], mode WriteMode) *LayeredCache[K, V] {
+	c := &LayeredCache[K, V]{
+		l1:    l1,
+		l2:    l2,
+		mode:  mode,
+		dirty: make(map[K]V),
+	}
+
+	l1.SetEvictionCallback(c.l1Evicted)
+	l2.SetEvictionCallback(c.notify)
+	return c
+}
+
+// l1Evicted writes back the dirty entries displaced from l1, and reports the dirty entries which
+// leave l1 for other reasons since they won't make it to l2.
+func (c *LayeredCache[K, V]) l1Evicted(key K, _ V, reason EvictionReason) {
+	c.mu.Lock()
+	value, dirty := c.dirty[key]
+	delete(c.dirty, key)
+	c.mu.Unlock()
+
+	if !dirty {
+		return
+	}
+
+	if reason == ReasonCapacity {
+		c.l2.Set(key, value)
+	} else {
+		c.notify(key, value, reason)
+	}
+}
+
+func (c *LayeredCache[K, V]) notify(key K, value V, reason EvictionReason) {
+	if cb := c.callback.Load(); cb != nil {
+		(*cb)(key, value, reason)
+	}
+}
+
+// Set adds the entry to the fast layer, and to the slow layer right away in WriteThrough mode.
+func (c *LayeredCache[K, V]) Set(key K, value V) {
+	atomic.AddUint64(&c.writes, 1)
+
+	if c.mode == WriteBack {
+		// mark the entry dirty first, such that it's written back if it's displaced right away
+		c.mu.Lock()
+		c.dirty[key] = value
+		c.mu.Unlock()
+		c.l1.Set(key, value)
+		return
+	}
+
+	c.l1.Set(key, value)
+	c.l2.Set(key, value)
+}
+
+func (c *LayeredCache[K, V]) SetAll(entries map[K]V) {
+	atomic.AddUint64(&c.writes, uint64(len(entries)))
+
+	if c.mode == WriteBack {
+		c.mu.Lock()
+		for key, value := range entries {
+			c.dirty[key] = value
+		}
+		c.mu.Unlock()
+		c.l1.SetAll(entries)
+		return
+	}
+
+	c.l1.SetAll(entries)
+	c.l2.SetAll(entries)
+}
+
+// Get looks the key up in the fast layer, then in the slow layer, promoting the entry to the fast layer
+// if it's found there.
+func (c *LayeredCache[K, V]) Get(key K) (V, bool) {
+	if value, ok := c.l1.Get(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return value, true
+	}
+
+	value, ok := c.l2.Get(key)
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return value, false
+	}
+
+	atomic.AddUint64(&c.hits, 1)
+	c.l1.Set(key, value)
+	return value, true
+}
+
+func (c *LayeredCache[K, V]) GetAll(keys []K) map[K]V {
+	result := c.l1.GetAll(keys)
+	if len(result) < len(keys) {
+		missing := make([]K, 0, len(keys)-len(result))
+		for _, key := range keys {
+			if _, ok := result[key]; !ok {
+				missing = append(missing, key)
+			}
+		}
+
+		promoted := c.l2.GetAll(missing)
+		c.l1.SetAll(promoted)
+		for key, value := range promoted {
+			result[key] = value
+		}
+	}
+
+	atomic.AddUint64(&c.hits, uint64(len(result)))
+	atomic.AddUint64(&c.misses, uint64(len(keys)-len(result)))
+	return result
+}
+
+func (c *LayeredCache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	return c.loads.GetOrLoad(c, key, loader)
+}
+
+func (c *LayeredCache[K, V]) GetOrLoadCtx(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error) {
+	return c.loads.GetOrLoadCtx(ctx, c, key, loader)
+}
+
+// Increment increments the value in the fast layer, promoting it from the slow layer first if necessary,
+// and then writes the result to the slow layer in WriteThrough mode. This isn't atomic across layers.
+func (c *LayeredCache[K, V]) Increment(key K, delta int64) (int64, error) {
+	atomic.AddUint64(&c.writes, 1)
+	c.Get(key)
+
+	n, err := c.l1.Increment(key, delta)
+	if err != nil {
+		return 0, err
+	}
+	if value, ok := c.l1.Get(key); ok {
+		if c.mode == WriteBack {
+			c.mu.Lock()
+			c.dirty[key] = value
+			c.mu.Unlock()
+		} else {
+			c.l2.Set(key, value)
+		}
+	}
+	return n, nil
+}
+
+func (c *LayeredCache[K, V]) LockKey(key K) func() {
+	return c.keyLocks.Lock(key)
+}
+
+func (c *LayeredCache[K, V]) Remove(key K) {
+	atomic.AddUint64(&c.removals, 1)
+	c.l1.Remove(key)
+	c.l2.Remove(key)
+}
+
+func (c *LayeredCache[K, V]) RemoveAll() {
+	atomic.AddUint64(&c.removals, uint64(c.Len()))
+	c.l1.RemoveAll()
+	c.l2.RemoveAll()
+}
+
+// RemoveIf removes the matching entries from both layers, and returns the number of distinct keys removed.
+func (c *LayeredCache[K, V]) RemoveIf(predicate func(key K, value V) bool) int {
+	removed := make(map[K]struct{})
+	match := func(key K, value V) bool {
+		if predicate(key, value) {
+			removed[key] = struct{}{}
+			return true
+		}
+		return false
+	}
+
+	c.l1.RemoveIf(match)
+	c.l2.RemoveIf(match)
+	atomic.AddUint64(&c.removals, uint64(len(removed)))
+	return len(removed)
+}
+
+// Flush writes the entries which haven't reached the slow layer yet in WriteBack mode. It should be called
+// before shutting down, lest these entries be lost.
+func (c *LayeredCache[K, V]) Flush() {
+	c.mu.Lock()
+	dirty := c.dirty
+	c.dirty = make(map[K]V)
+	c.mu.Unlock()
+
+	c.l2.SetAll(dirty)
+}
+
+func (c *LayeredCache[K, V]) Preload(entries map[K]V) {
+	c.SetAll(entries)
+}
+
+// ForEach visits the entries of the fast layer, then the entries of the slow layer which aren't in the fast layer.
+func (c *LayeredCache[K, V]) ForEach(f func(key K, value V) bool) {
+	visited := make(map[K]struct{})
+	stopped := false
+	c.l1.ForEach(func(key K, value V) bool {
+		visited[key] = struct{}{}
+		if !f(key, value) {
+			stopped = true
+		}
+		return !stopped
+	})
+	if stopped {
+		return
+	}
+
+	c.l2.ForEach(func(key K, value V) bool {
+		if _, ok := visited[key]; ok {
+			return true
+		}
+		return f(key, value)
+	})
+}
+
+func (c *LayeredCache[K, V]) Snapshot() map[K]V {
+	result := c.l2.Snapshot()
+	for key, value := range c.l1.Snapshot() {
+		result[key] = value
+	}
+	return result
+}
+
+// Stats returns the statistics of the layered cache, a hit being a hit in either layer. Evictions are
+// those of the slow layer, since entries displaced from the fast layer remain in the slow layer.
+func (c *LayeredCache[K, V]) Stats() Stats {
+	l2 := c.l2.Stats()
+	return Stats{
+		Writes:            atomic.LoadUint64(&c.writes),
+		Hits:              atomic.LoadUint64(&c.hits),
+		Misses:            atomic.LoadUint64(&c.misses),
+		Evictions:         l2.Evictions,
+		Expirations:       l2.Expirations,
+		CapacityEvictions: l2.CapacityEvictions,
+		Removals:          atomic.LoadUint64(&c.removals),
+	}
+}
+
+func (c *LayeredCache[K, V]) StatsDelta() Stats {
+	return c.delta(c.Stats())
+}
+
+// ResetStats zeroes the counters of the layered cache, and those of both layers.
+func (c *LayeredCache[K, V]) ResetStats() {
+	atomic.StoreUint64(&c.writes, 0)
+	atomic.StoreUint64(&c.hits, 0)
+	atomic.StoreUint64(&c.misses, 0)
+	atomic.StoreUint64(&c.removals, 0)
+	c.l1.ResetStats()
+	c.l2.ResetStats()
+	c.reset()
+}
+
+// Len returns the number of distinct keys held in either layer.
+func (c *LayeredCache[K, V]) Len() int {
+	return len(c.Snapshot())
+}
+
+// SetEvictionCallback registers a function to be called when entries leave the slow layer, and when
+// entries which haven't been written back leave the fast layer for another reason than being displaced.
+func (c *LayeredCache[K, V]) SetEvictionCallback(callback func(key K, value V, reason EvictionReason)) {
+	if callback == nil {
+		c.callback.Store(nil)
+		return
+	}
+	c.callback.Store(&callback)
+}
@@ -0,0 +1,144 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLayered(mode WriteMode) *LayeredCache[interface{}, interface{}] {
+	return Layered(NewLRU(5*time.Minute, 1*time.Minute, 500), NewTTL(5*time.Minute, 1*time.Minute), mode)
+}
+
+func TestLayeredBasic(t *testing.T) {
+	testCacheBasic(newTestLayered(WriteThrough), t)
+	testCacheBasic(newTestLayered(WriteBack), t)
+}
+
+func TestLayeredConcurrent(t *testing.T) {
+	testCacheConcurrent(newTestLayered(WriteThrough), t)
+	testCacheConcurrent(newTestLayered(WriteBack), t)
+}
+
+func TestLayeredGetOrLoad(t *testing.T) {
+	testCacheGetOrLoad(newTestLayered(WriteThrough), t)
+	testCacheGetOrLoadCtx(newTestLayered(WriteBack), t)
+}
+
+func TestLayeredBatch(t *testing.T) {
+	testCacheBatch(newTestLayered(WriteThrough), t)
+	testCacheBatch(newTestLayered(WriteBack), t)
+}
+
+func TestLayeredIncrement(t *testing.T) {
+	testCacheIncrement(newTestLayered(WriteThrough), t)
+	testCacheIncrement(newTestLayered(WriteBack), t)
+}
+
+func TestLayeredLockKey(t *testing.T) {
+	testCacheLockKey(newTestLayered(WriteThrough), t)
+}
+
+func TestLayeredRemoveIf(t *testing.T) {
+	testCacheRemoveIf(newTestLayered(WriteThrough), t)
+	testCacheRemoveIf(newTestLayered(WriteBack), t)
+}
+
+func TestLayeredStatsDelta(t *testing.T) {
+	testCacheStatsDelta(newTestLayered(WriteThrough), t)
+}
+
+func TestLayeredPreloadSnapshot(t *testing.T) {
+	testCachePreloadSnapshot(newTestLayered(WriteThrough), t)
+}
+
+func TestLayeredWriteThrough(t *testing.T) {
+	l1 := NewTypedLRU[string, int](5*time.Minute, 0, 1)
+	l2 := NewTypedTTL[string, int](5*time.Minute, 0)
+	c := NewTypedLayered[string, int](l1, l2, WriteThrough)
+
+	c.Set("A", 1)
+	if _, ok := l2.Get("A"); !ok {
+		t.Error("Got no entry for A in L2, expected it to be written through")
+	}
+
+	// displacing A from L1 keeps it in L2, and a lookup promotes it back
+	c.Set("B", 2)
+	if _, ok := l1.Get("A"); ok {
+		t.Error("Got an entry for A in L1, expected it to be displaced")
+	}
+	if value, ok := c.Get("A"); !ok || value != 1 {
+		t.Errorf("Got %d, %t, expected A to be found in L2", value, ok)
+	}
+	if _, ok := l1.Get("A"); !ok {
+		t.Error("Got no entry for A in L1, expected it to be promoted")
+	}
+
+	if _, ok := c.Get("Z"); ok {
+		t.Error("Got an entry for Z, expected a miss in both layers")
+	}
+	if s := c.Stats(); s.Writes != 2 || s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("Got %+v, expected 2 writes, 1 hit and 1 miss", s)
+	}
+
+	c.Remove("A")
+	if _, ok := l2.Get("A"); ok {
+		t.Error("Got an entry for A in L2, expected it to be removed from both layers")
+	}
+	if n := c.Len(); n != 1 {
+		t.Errorf("Got %d entries, expected 1", n)
+	}
+}
+
+func TestLayeredWriteBack(t *testing.T) {
+	l1 := NewTypedLRU[string, int](5*time.Minute, 0, 1).(*lruCache[string, int])
+	l2 := NewTypedTTL[string, int](5*time.Minute, 0)
+	c := NewTypedLayered[string, int](l1, l2, WriteBack)
+
+	var evicted []string
+	c.SetEvictionCallback(func(key string, value int, reason EvictionReason) {
+		evicted = append(evicted, key+":"+reason.String())
+	})
+
+	c.Set("A", 1)
+	if _, ok := l2.Get("A"); ok {
+		t.Error("Got an entry for A in L2, expected it to be written back later")
+	}
+
+	// displacing A from L1 writes it back
+	c.Set("B", 2)
+	if value, ok := l2.Get("A"); !ok || value != 1 {
+		t.Errorf("Got %d, %t for A in L2, expected it to be written back", value, ok)
+	}
+	if _, ok := l2.Get("B"); ok {
+		t.Error("Got an entry for B in L2, expected it to be written back later")
+	}
+
+	c.Flush()
+	if value, ok := l2.Get("B"); !ok || value != 2 {
+		t.Errorf("Got %d, %t for B in L2, expected it to be flushed", value, ok)
+	}
+
+	// dirty entries expiring from L1 never make it to L2, and are reported as evicted
+	c.Set("C", 3)
+	l1.evictExpired(time.Now().Add(time.Hour))
+	if _, ok := c.Get("C"); ok {
+		t.Error("Got an entry for C, expected it to have expired")
+	}
+	if len(evicted) != 1 || evicted[0] != "C:expired" {
+		t.Errorf("Got evictions %v, expected only C to be reported", evicted)
+	}
+}
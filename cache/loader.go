@@ -0,0 +1,90 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errLoaderPanicked is returned to the callers waiting on a load whose loader panicked.
+var errLoaderPanicked = errors.New("cache: loader panicked")
+
+// LoadGroup implements GetOrLoad on behalf of a cache. It tracks the loads in flight such that
+// concurrent calls for the same key wait for a single call to the loader rather than each
+// computing the value. It is exported for the sake of cache implementations living outside of
+// this package. The zero value is ready to use.
+type LoadGroup[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*loadCall[V]
+}
+
+// loadCall is a load in flight, done is closed once it completes.
+type loadCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// GetOrLoad returns the value associated with key in c, calling loader to produce the value and
+// add it to c if it isn't present.
+func (g *LoadGroup[K, V]) GetOrLoad(c Typed[K, V], key K, loader func() (V, error)) (V, error) {
+	return g.GetOrLoadCtx(context.Background(), c, key, func(context.Context) (V, error) {
+		return loader()
+	})
+}
+
+// GetOrLoadCtx is like GetOrLoad, passing ctx on to loader. Callers waiting on a load started
+// by another caller give up with the context's error once ctx is done, leaving the load running.
+func (g *LoadGroup[K, V]) GetOrLoadCtx(ctx context.Context, c Typed[K, V], key K,
+	loader func(ctx context.Context) (V, error)) (V, error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.value, call.err
+		case <-ctx.Done():
+			var zero V
+			return zero, ctx.Err()
+		}
+	}
+
+	call := &loadCall[V]{done: make(chan struct{}), err: errLoaderPanicked}
+	if g.calls == nil {
+		g.calls = make(map[K]*loadCall[V])
+	}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		// the value is cached before the call is forgotten, such that later callers find it
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.err = loader(ctx)
+	if call.err == nil {
+		c.Set(key, call.value)
+	}
+	return call.value, call.err
+}
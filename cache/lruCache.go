@@ -15,6 +15,8 @@
 package cache
 
 import (
+	"context"
+	"io"
 	"math"
 	"runtime"
 	"sync"
@@ -72,29 +74,37 @@ import (
 // ends well.
 
 // See use of SetFinalizer below for an explanation of this weird composition
-type lruWrapper struct {
-	*lruCache
+type lruWrapper[K comparable, V any] struct {
+	*lruCache[K, V]
 }
 
-type lruCache struct {
+type lruCache[K comparable, V any] struct {
 	sync.RWMutex
-	entries           []lruEntry            // allocate once, not resizable
-	sentinel          *lruEntry             // direct pointer to entries[0] to avoid bounds checking
-	lookup            map[interface{}]int32 // keys => entry index
+	entries           []lruEntry[K, V] // allocate once, not resizable
+	sentinel          *lruEntry[K, V]  // direct pointer to entries[0] to avoid bounds checking
+	lookup            map[K]int32      // keys => entry index
 	stats             Stats
 	defaultExpiration time.Duration
 	stopEvicter       chan bool
 	baseTimeNanos     int64
 	evicterTerminated sync.WaitGroup // used by unit tests to verify the finalizer ran
+	evictionNotifier[K, V]
+	clockHolder
+	refreshAhead[K, V]
+	statsWindow
+	loads    LoadGroup[K, V]
+	keyLocks KeyLocks[K]
 }
 
 // lruEntry is used to hold a value in the ordered lru list represented by the entry slice
-type lruEntry struct {
-	next       int32       // index of next entry
-	prev       int32       // index of previous entry
-	key        interface{} // cache key associated with this entry
-	value      interface{} // cache value associated with this entry
-	expiration int64       // nanoseconds
+type lruEntry[K comparable, V any] struct {
+	next       int32 // index of next entry
+	prev       int32 // index of previous entry
+	key        K     // cache key associated with this entry
+	value      V     // cache value associated with this entry
+	expiration int64 // nanoseconds
+	inUse      bool  // whether this entry currently holds a key
+	pinned     bool  // whether this entry is exempt from being displaced
 }
 
 // entry 0 in the slice is the sentinel node
@@ -117,9 +127,15 @@ const sentinelIndex = 0
 // evictionInterval specifies the frequency at which eviction activities take
 // place. This should likely be >= 1 second.
 func NewLRU(defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32) ExpiringCache {
-	c := &lruCache{
-		entries:           make([]lruEntry, maxEntries+1),
-		lookup:            make(map[interface{}]int32, maxEntries),
+	return NewTypedLRU[interface{}, interface{}](defaultExpiration, evictionInterval, maxEntries)
+}
+
+// NewTypedLRU creates a new cache with an LRU and time-based eviction model, holding keys of type K
+// and values of type V. See also: NewLRU.
+func NewTypedLRU[K comparable, V any](defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32) Expiring[K, V] {
+	c := &lruCache[K, V]{
+		entries:           make([]lruEntry[K, V], maxEntries+1),
+		lookup:            make(map[K]int32, maxEntries),
 		defaultExpiration: defaultExpiration,
 	}
 
@@ -145,8 +161,8 @@ func NewLRU(defaultExpiration time.Duration, evictionInterval time.Duration, max
 		// the finalizer can trigger on the wrapper. We can't set a finalizer
 		// on the main cache object because it would never fire, since the
 		// evicter goroutine is keeping it alive
-		result := &lruWrapper{c}
-		runtime.SetFinalizer(result, func(w *lruWrapper) {
+		result := &lruWrapper[K, V]{c}
+		runtime.SetFinalizer(result, func(w *lruWrapper[K, V]) {
 			w.stopEvicter <- true
 			w.evicterTerminated.Wait()
 		})
@@ -156,13 +172,13 @@ func NewLRU(defaultExpiration time.Duration, evictionInterval time.Duration, max
 	return c
 }
 
-func (c *lruCache) evicter(evictionInterval time.Duration) {
+func (c *lruCache[K, V]) evicter(evictionInterval time.Duration) {
 	// Wake up once in a while and evict stale items
 	ticker := time.NewTicker(evictionInterval)
 	for {
 		select {
-		case now := <-ticker.C:
-			c.evictExpired(now)
+		case <-ticker.C:
+			c.evictExpired(c.now())
 		case <-c.stopEvicter:
 			ticker.Stop()
 			c.evicterTerminated.Done() // record this for the sake of unit tests
@@ -171,7 +187,7 @@ func (c *lruCache) evicter(evictionInterval time.Duration) {
 	}
 }
 
-func (c *lruCache) evictExpired(t time.Time) {
+func (c *lruCache[K, V]) evictExpired(t time.Time) {
 	// We snapshot a base time here such that the time doesn't need to be
 	// sampled in the Set call as calling time.Now() is relatively expensive.
 	// Doing it here provides enough precision for our needs and tends to have
@@ -184,25 +200,33 @@ func (c *lruCache) evictExpired(t time.Time) {
 
 		c.Lock()
 		if ent.expiration <= n {
+			c.evicted(ent.key, ent.value, ReasonExpired)
 			c.remove(i)
-			c.stats.Evictions++
+			c.stats.recordEviction(ReasonExpired)
 		}
+		pending := c.takePending()
 		c.Unlock()
+		c.notify(pending)
 	}
 }
 
-func (c *lruCache) EvictExpired() {
-	c.evictExpired(time.Now())
+func (c *lruCache[K, V]) EvictExpired() {
+	c.evictExpired(c.now())
+}
+
+func (c *lruCache[K, V]) SetClock(clock Clock) {
+	c.setClock(clock)
+	atomic.StoreInt64(&c.baseTimeNanos, c.now().UnixNano())
 }
 
-func (c *lruCache) unlinkEntry(index int32) {
+func (c *lruCache[K, V]) unlinkEntry(index int32) {
 	ent := &c.entries[index]
 
 	c.entries[ent.prev].next = ent.next
 	c.entries[ent.next].prev = ent.prev
 }
 
-func (c *lruCache) linkEntryAtHead(index int32) {
+func (c *lruCache[K, V]) linkEntryAtHead(index int32) {
 	ent := &c.entries[index]
 
 	ent.next = c.sentinel.next
@@ -211,7 +235,7 @@ func (c *lruCache) linkEntryAtHead(index int32) {
 	c.sentinel.next = index
 }
 
-func (c *lruCache) linkEntryAtTail(index int32) {
+func (c *lruCache[K, V]) linkEntryAtTail(index int32) {
 	ent := &c.entries[index]
 
 	ent.next = sentinelIndex
@@ -220,20 +244,52 @@ func (c *lruCache) linkEntryAtTail(index int32) {
 	c.sentinel.prev = index
 }
 
-func (c *lruCache) Set(key interface{}, value interface{}) {
+func (c *lruCache[K, V]) Set(key K, value V) {
 	c.SetWithExpiration(key, value, c.defaultExpiration)
 }
 
-func (c *lruCache) SetWithExpiration(key interface{}, value interface{}, expiration time.Duration) {
+func (c *lruCache[K, V]) SetWithExpiration(key K, value V, expiration time.Duration) {
 	exp := atomic.LoadInt64(&c.baseTimeNanos) + expiration.Nanoseconds()
 
 	c.Lock()
+	c.set(key, value, exp)
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *lruCache[K, V]) SetAll(entries map[K]V) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + c.defaultExpiration.Nanoseconds()
+
+	c.Lock()
+	for key, value := range entries {
+		c.set(key, value, exp)
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
 
+func (c *lruCache[K, V]) set(key K, value V, exp int64) {
 	index, ok := c.lookup[key]
 	if !ok {
-		// reclaim the tail entry
+		// reclaim the tail entry, moving pinned entries out of the way
 		index = c.sentinel.prev
-		delete(c.lookup, c.entries[index].key)
+		for i := 1; c.entries[index].pinned; i++ {
+			if i == len(c.entries) {
+				// all the entries are pinned, there's no room for this one
+				return
+			}
+			c.unlinkEntry(index)
+			c.linkEntryAtHead(index)
+			index = c.sentinel.prev
+		}
+
+		if tail := &c.entries[index]; tail.inUse {
+			c.evicted(tail.key, tail.value, ReasonCapacity)
+			delete(c.lookup, tail.key)
+			c.stats.recordEviction(ReasonCapacity)
+		}
 		c.lookup[key] = index
 	}
 
@@ -243,74 +299,267 @@ func (c *lruCache) SetWithExpiration(key interface{}, value interface{}, expirat
 	ent.key = key
 	ent.value = value
 	ent.expiration = exp
+	ent.inUse = true
 
 	c.stats.Writes++
+}
 
+func (c *lruCache[K, V]) Get(key K) (V, bool) {
+	c.Lock()
+	value, ok := c.get(key)
 	c.Unlock()
+
+	return value, ok
 }
 
-func (c *lruCache) Get(key interface{}) (interface{}, bool) {
+func (c *lruCache[K, V]) GetAll(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+
 	c.Lock()
+	for _, key := range keys {
+		if value, ok := c.get(key); ok {
+			result[key] = value
+		}
+	}
+	c.Unlock()
+
+	return result
+}
 
-	var value interface{}
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	var value V
 	index, ok := c.lookup[key]
 	if ok {
 		c.unlinkEntry(index)
 		c.linkEntryAtHead(index)
 		value = c.entries[index].value
 		c.stats.Hits++
+		c.check(key, c.entries[index].expiration, atomic.LoadInt64(&c.baseTimeNanos), c.Set)
 	} else {
 		c.stats.Misses++
 	}
 
+	return value, ok
+}
+
+func (c *lruCache[K, V]) Increment(key K, delta int64) (int64, error) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + c.defaultExpiration.Nanoseconds()
+
+	c.Lock()
+	var value V
+	index, ok := c.lookup[key]
+	if ok {
+		value = c.entries[index].value
+		exp = c.entries[index].expiration
+	}
+	value, n, err := IncrementValue(value, ok, delta)
+	if err != nil {
+		c.Unlock()
+		return 0, err
+	}
+	c.set(key, value, exp)
+	pending := c.takePending()
 	c.Unlock()
+	c.notify(pending)
 
-	return value, ok
+	return n, nil
+}
+
+func (c *lruCache[K, V]) LockKey(key K) func() {
+	return c.keyLocks.Lock(key)
 }
 
-func (c *lruCache) Remove(key interface{}) {
+func (c *lruCache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	return c.loads.GetOrLoad(c, key, loader)
+}
+
+func (c *lruCache[K, V]) GetOrLoadCtx(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error) {
+	return c.loads.GetOrLoadCtx(ctx, c, key, loader)
+}
+
+func (c *lruCache[K, V]) Remove(key K) {
 	c.Lock()
 
 	if index, ok := c.lookup[key]; ok {
+		c.evicted(key, c.entries[index].value, ReasonRemoved)
 		c.remove(index)
 		c.stats.Removals++
 	}
 
+	pending := c.takePending()
 	c.Unlock()
+	c.notify(pending)
 }
 
-func (c *lruCache) RemoveAll() {
+func (c *lruCache[K, V]) RemoveAll() {
 	for i := 1; i < len(c.entries); i++ {
 		ent := &c.entries[i]
 
 		c.Lock()
-		if ent.key != nil {
+		if ent.inUse {
+			c.evicted(ent.key, ent.value, ReasonRemoved)
 			c.remove(int32(i))
 			c.stats.Removals++
 		}
+		pending := c.takePending()
 		c.Unlock()
+		c.notify(pending)
 	}
 }
 
-func (c *lruCache) remove(index int32) {
+func (c *lruCache[K, V]) remove(index int32) {
 	ent := &c.entries[index]
 
+	var zeroKey K
+	var zeroValue V
+
 	delete(c.lookup, ent.key)
 	c.unlinkEntry(index)
 	c.linkEntryAtTail(index)
-	ent.key = nil
-	ent.value = nil
+	ent.key = zeroKey
+	ent.value = zeroValue
 	ent.expiration = math.MaxInt64
+	ent.inUse = false
+	ent.pinned = false
 }
 
-func (c *lruCache) Stats() Stats {
+// Pin exempts the entry for key from being displaced to make room for other entries. See Pinnable.
+func (c *lruCache[K, V]) Pin(key K) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	index, ok := c.lookup[key]
+	if ok {
+		c.entries[index].pinned = true
+	}
+	return ok
+}
+
+func (c *lruCache[K, V]) Unpin(key K) {
+	c.Lock()
+	defer c.Unlock()
+
+	if index, ok := c.lookup[key]; ok {
+		c.entries[index].pinned = false
+	}
+}
+
+// shed displaces up to n of the least recently used entries which aren't pinned, returning the number of
+// entries displaced.
+func (c *lruCache[K, V]) shed(n int) int {
+	shed := 0
+
+	c.Lock()
+	for index := c.sentinel.prev; index != sentinelIndex && shed < n; {
+		ent := &c.entries[index]
+		prev := ent.prev
+		if ent.inUse && !ent.pinned {
+			c.evicted(ent.key, ent.value, ReasonCapacity)
+			c.remove(index)
+			c.stats.recordEviction(ReasonCapacity)
+			shed++
+		}
+		index = prev
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+
+	return shed
+}
+
+func (c *lruCache[K, V]) RemoveIf(predicate func(key K, value V) bool) int {
+	n := 0
+	for i := 1; i < len(c.entries); i++ {
+		ent := &c.entries[i]
+
+		c.Lock()
+		if ent.inUse && predicate(ent.key, ent.value) {
+			c.evicted(ent.key, ent.value, ReasonRemoved)
+			c.remove(int32(i))
+			c.stats.Removals++
+			n++
+		}
+		pending := c.takePending()
+		c.Unlock()
+		c.notify(pending)
+	}
+	return n
+}
+
+func (c *lruCache[K, V]) Preload(entries map[K]V) {
+	c.SetAll(entries)
+}
+
+func (c *lruCache[K, V]) Snapshot() map[K]V {
+	c.RLock()
+	defer c.RUnlock()
+
+	result := make(map[K]V, len(c.lookup))
+	for key, index := range c.lookup {
+		result[key] = c.entries[index].value
+	}
+	return result
+}
+
+// ForEach locks the cache for each entry in turn rather than for the whole iteration, such
+// that f is free to call back into the cache.
+func (c *lruCache[K, V]) ForEach(f func(key K, value V) bool) {
+	c.forEachTTL(func(key K, value V, _ time.Duration) bool {
+		return f(key, value)
+	})
+}
+
+// forEachTTL is like ForEach, also passing the time left before each entry expires to f.
+func (c *lruCache[K, V]) forEachTTL(f func(key K, value V, ttl time.Duration) bool) {
+	now := c.now().UnixNano()
+	for i := 1; i < len(c.entries); i++ {
+		ent := &c.entries[i]
+
+		c.RLock()
+		live := ent.inUse && ent.expiration > now
+		key, value, exp := ent.key, ent.value, ent.expiration
+		c.RUnlock()
+
+		if live && !f(key, value, time.Duration(exp-now)) {
+			return
+		}
+	}
+}
+
+func (c *lruCache[K, V]) WriteTo(w io.Writer) (int64, error) {
+	return WriteEntries[K, V](w, c.forEachTTL)
+}
+
+func (c *lruCache[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	return ReadEntries[K, V](r, c.SetWithExpiration)
+}
+
+func (c *lruCache[K, V]) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.lookup)
+}
+
+func (c *lruCache[K, V]) Stats() Stats {
 	c.RLock()
 	defer c.RUnlock()
 	return c.stats
 }
 
+func (c *lruCache[K, V]) StatsDelta() Stats {
+	return c.delta(c.Stats())
+}
+
+func (c *lruCache[K, V]) ResetStats() {
+	c.Lock()
+	c.stats = Stats{}
+	c.Unlock()
+	c.reset()
+}
+
 /* debugging aid
-func (c *lruCache) dumpList(banner string) {
+func (c *lruCache[K, V]) dumpList(banner string) {
 	fmt.Printf("%s\n", banner)
 	index := c.entries[0].next
 	count := 8
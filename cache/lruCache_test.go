@@ -30,7 +30,7 @@ func TestLRUConcurrent(t *testing.T) {
 }
 
 func TestLRUExpiration(t *testing.T) {
-	lru := NewLRU(5*time.Second, 0, 500).(*lruCache)
+	lru := NewLRU(5*time.Second, 0, 500).(*lruCache[interface{}, interface{}])
 	testCacheExpiration(lru, lru.evictExpired, t)
 }
 
@@ -40,12 +40,73 @@ func TestLRUEvicter(t *testing.T) {
 }
 
 func TestLRUEvictExpired(t *testing.T) {
-	lru := NewLRU(5*time.Second, 0, 500).(*lruCache)
+	lru := NewLRU(5*time.Second, 0, 500).(*lruCache[interface{}, interface{}])
 	testCacheEvictExpired(lru, t)
 }
 
+func TestLRUSetEvictionCallback(t *testing.T) {
+	lru := NewLRU(5*time.Second, 0, 500).(*lruCache[interface{}, interface{}])
+	testCacheEvictionCallback(lru, lru.evictExpired, t)
+
+	testCacheCapacityCallback(NewLRU(5*time.Second, 0, 1), t)
+}
+
+func TestLRUSetExpirationCallback(t *testing.T) {
+	lru := NewLRU(5*time.Second, 0, 500).(*lruCache[interface{}, interface{}])
+	testCacheExpirationCallback(lru, lru.evictExpired, t)
+}
+
+func TestLRUPin(t *testing.T) {
+	testCachePin(NewLRU(5*time.Minute, 0, 2), t)
+}
+
+func TestLRUGetOrLoad(t *testing.T) {
+	testCacheGetOrLoad(NewLRU(5*time.Minute, 1*time.Minute, 500), t)
+	testCacheGetOrLoadCtx(NewLRU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestLRUBatch(t *testing.T) {
+	testCacheBatch(NewLRU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestLRUForEach(t *testing.T) {
+	testCacheForEach(NewLRU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestLRUIncrement(t *testing.T) {
+	testCacheIncrement(NewLRU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestLRULockKey(t *testing.T) {
+	testCacheLockKey(NewLRU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestLRUClock(t *testing.T) {
+	testCacheClock(NewLRU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestLRURefreshAhead(t *testing.T) {
+	testCacheRefreshAhead(NewLRU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestLRUWriteTo(t *testing.T) {
+	testCacheWriteTo(NewLRU(5*time.Minute, 1*time.Minute, 500), NewLRU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestLRURemoveIf(t *testing.T) {
+	testCacheRemoveIf(NewLRU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestLRUStatsDelta(t *testing.T) {
+	testCacheStatsDelta(NewLRU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestLRUPreloadSnapshot(t *testing.T) {
+	testCachePreloadSnapshot(NewLRU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
 func TestLRUFinalizer(t *testing.T) {
-	lru := NewLRU(5*time.Second, 1*time.Millisecond, 500).(*lruWrapper)
+	lru := NewLRU(5*time.Second, 1*time.Millisecond, 500).(*lruWrapper[interface{}, interface{}])
 	testCacheFinalizer(&lru.evicterTerminated)
 }
 
@@ -82,6 +143,29 @@ func TestLRUBehavior(t *testing.T) {
 	}
 }
 
+func TestTypedLRU(t *testing.T) {
+	lru := NewTypedLRU[string, int](5*time.Minute, 0, 2)
+
+	// the zero value of the key type is a valid key
+	lru.Set("", 0)
+	lru.Set("1", 1)
+	lru.Set("2", 2)
+	if _, ok := lru.Get(""); ok {
+		t.Error("Got an entry, expecting it to have been displaced")
+	}
+	if v, ok := lru.Get("1"); !ok || v != 1 {
+		t.Errorf("Got %v %v, expected 1 true", v, ok)
+	}
+	lru.Remove("1")
+	lru.Set("", 3)
+	if v, ok := lru.Get(""); !ok || v != 3 {
+		t.Errorf("Got %v %v, expected 3 true", v, ok)
+	}
+	if v, ok := lru.Get("2"); !ok || v != 2 {
+		t.Errorf("Got %v %v, expected 2 true", v, ok)
+	}
+}
+
 func BenchmarkLRUGet(b *testing.B) {
 	c := NewLRU(5*time.Minute, 1*time.Minute, 500)
 	benchmarkCacheGet(c, b)
@@ -111,3 +195,8 @@ func BenchmarkLRUSetRemove(b *testing.B) {
 	c := NewLRU(5*time.Minute, 1*time.Minute, 500)
 	benchmarkCacheSetRemove(c, b)
 }
+
+func BenchmarkLRUSetAll(b *testing.B) {
+	c := NewLRU(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheSetAll(c, b)
+}
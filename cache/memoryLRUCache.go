@@ -0,0 +1,154 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"runtime"
+	"runtime/metrics"
+	"time"
+)
+
+// The memory-bounded LRU cache is a regular LRU cache whose evicter also keeps an eye on the
+// memory used by the process. Whenever the process is found to use more memory than the
+// configured limit, the cache sheds a share of its least recently used entries proportional to
+// the excess, such that the garbage collector can reclaim them before the process gets killed
+// for running out of memory.
+//
+// The memory used by the process is only sampled once per eviction interval, and freed entries
+// only lower it once the garbage collector has run, so the cache may shed more entries than
+// strictly necessary in a burst. This is a cache, entries can always be fetched again.
+//
+// The same finalizer trickery as for the LRU cache is used to stop the evicter goroutine once
+// the cache is no longer referenced.
+
+// See use of SetFinalizer below for an explanation of this weird composition
+type memoryLRUWrapper[K comparable, V any] struct {
+	*memoryLRUCache[K, V]
+}
+
+type memoryLRUCache[K comparable, V any] struct {
+	*lruCache[K, V]
+	memoryLimit uint64
+	memoryUsage func() uint64 // returns the memory currently used by the process
+}
+
+// NewMemoryBoundedLRU creates a new cache with an LRU and time-based eviction model, which also
+// sheds entries when the process uses too much memory.
+//
+// Cache eviction is done on a periodic basis. Individual cache entries are evicted
+// after their expiration time has passed. The periodic nature of eviction means that
+// cache entries tend to survive around (expirationTime + (evictionInterval / 2))
+//
+// In addition, when the cache is full, adding a new item will displace the item that has
+// been referenced least recently. And when eviction takes place while the memory used by the
+// process exceeds memoryLimit bytes, items that have been referenced least recently are displaced
+// in proportion to the excess. The memory used by the process is measured by the Go runtime as the
+// memory it obtained from the OS and hasn't returned, which tracks the resident set of pure Go
+// programs. The limit should leave some headroom below the memory limit of the container for the
+// time it takes to react.
+//
+// defaultExpiration specifies the default minimum amount of time a cached
+// entry remains in the cache before eviction. This value is used with the
+// Set function. Explicit per-entry expiration times can be set with the
+// SetWithExpiration function instead.
+//
+// evictionInterval specifies the frequency at which eviction activities take
+// place, including checking the memory used by the process. This should likely
+// be >= 1 second.
+func NewMemoryBoundedLRU(defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32,
+	memoryLimit uint64) ExpiringCache {
+	return NewTypedMemoryBoundedLRU[interface{}, interface{}](defaultExpiration, evictionInterval, maxEntries, memoryLimit)
+}
+
+// NewTypedMemoryBoundedLRU creates a new cache with an LRU and time-based eviction model which also sheds
+// entries when the process uses too much memory, holding keys of type K and values of type V.
+// See also: NewMemoryBoundedLRU.
+func NewTypedMemoryBoundedLRU[K comparable, V any](defaultExpiration time.Duration, evictionInterval time.Duration,
+	maxEntries int32, memoryLimit uint64) Expiring[K, V] {
+	c := &memoryLRUCache[K, V]{
+		lruCache:    NewTypedLRU[K, V](defaultExpiration, 0, maxEntries).(*lruCache[K, V]),
+		memoryLimit: memoryLimit,
+		memoryUsage: processMemory,
+	}
+
+	if evictionInterval > 0 {
+		c.stopEvicter = make(chan bool, 1)
+		c.evicterTerminated.Add(1)
+		go c.evicter(evictionInterval)
+
+		// We return a 'see-through' wrapper for the real object such that
+		// the finalizer can trigger on the wrapper. We can't set a finalizer
+		// on the main cache object because it would never fire, since the
+		// evicter goroutine is keeping it alive
+		result := &memoryLRUWrapper[K, V]{c}
+		runtime.SetFinalizer(result, func(w *memoryLRUWrapper[K, V]) {
+			w.stopEvicter <- true
+			w.evicterTerminated.Wait()
+		})
+		return result
+	}
+
+	return c
+}
+
+// processMemory returns the memory the Go runtime obtained from the OS and hasn't returned to it.
+func processMemory() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
+
+func (c *memoryLRUCache[K, V]) evicter(evictionInterval time.Duration) {
+	// Wake up once in a while and evict stale items
+	ticker := time.NewTicker(evictionInterval)
+	for {
+		select {
+		case <-ticker.C:
+			c.evictExpired(c.now())
+		case <-c.stopEvicter:
+			ticker.Stop()
+			c.evicterTerminated.Done() // record this for the sake of unit tests
+			return
+		}
+	}
+}
+
+func (c *memoryLRUCache[K, V]) evictExpired(t time.Time) {
+	c.lruCache.evictExpired(t)
+	c.shedExcess()
+}
+
+// EvictExpired synchronously evicts all expired entries from the cache, and sheds entries if the
+// process uses too much memory.
+func (c *memoryLRUCache[K, V]) EvictExpired() {
+	c.evictExpired(c.now())
+}
+
+// shedExcess displaces a share of the entries proportional to the memory used in excess of the limit.
+func (c *memoryLRUCache[K, V]) shedExcess() {
+	usage := c.memoryUsage()
+	if usage <= c.memoryLimit {
+		return
+	}
+
+	n := int(float64(c.Len()) * float64(usage-c.memoryLimit) / float64(usage))
+	if n < 1 {
+		n = 1
+	}
+	c.shed(n)
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoryBoundedLRUBasic(t *testing.T) {
+	lru := NewMemoryBoundedLRU(5*time.Minute, 1*time.Millisecond, 500, 1<<40)
+	testCacheBasic(lru, t)
+}
+
+func TestMemoryBoundedLRUConcurrent(t *testing.T) {
+	lru := NewMemoryBoundedLRU(5*time.Minute, 1*time.Minute, 500, 1<<40)
+	testCacheConcurrent(lru, t)
+}
+
+func TestMemoryBoundedLRUExpiration(t *testing.T) {
+	lru := NewMemoryBoundedLRU(5*time.Second, 0, 500, 1<<40).(*memoryLRUCache[interface{}, interface{}])
+	testCacheExpiration(lru, lru.evictExpired, t)
+}
+
+func TestMemoryBoundedLRUEvicter(t *testing.T) {
+	lru := NewMemoryBoundedLRU(5*time.Second, 1*time.Millisecond, 500, 1<<40)
+	testCacheEvicter(lru)
+}
+
+func TestMemoryBoundedLRUPin(t *testing.T) {
+	testCachePin(NewMemoryBoundedLRU(5*time.Minute, 0, 2, math.MaxUint64), t)
+}
+
+func TestMemoryBoundedLRUFinalizer(t *testing.T) {
+	lru := NewMemoryBoundedLRU(5*time.Second, 1*time.Millisecond, 500, 1<<40).(*memoryLRUWrapper[interface{}, interface{}])
+	testCacheFinalizer(&lru.evicterTerminated)
+}
+
+func TestMemoryBoundedLRUShedding(t *testing.T) {
+	lru := NewTypedMemoryBoundedLRU[string, int](5*time.Minute, 0, 100, 1000).(*memoryLRUCache[string, int])
+	usage := uint64(0)
+	lru.memoryUsage = func() uint64 { return usage }
+
+	var shed []string
+	lru.SetEvictionCallback(func(key string, value int, reason EvictionReason) {
+		if reason != ReasonCapacity {
+			t.Errorf("Got reason %v for %s, expected %v", reason, key, ReasonCapacity)
+		}
+		shed = append(shed, key)
+	})
+
+	for i := 0; i < 100; i++ {
+		lru.Set(strconv.Itoa(i), i)
+	}
+
+	// nothing to do under the limit
+	usage = 1000
+	lru.EvictExpired()
+	if n := lru.Len(); n != 100 {
+		t.Errorf("Got %d entries, expected 100 under the memory limit", n)
+	}
+
+	// a quarter of the memory is in excess, a quarter of the entries go, least recently used first
+	usage = 1334
+	lru.Get("0")
+	lru.EvictExpired()
+	if n := lru.Len(); n != 75 {
+		t.Errorf("Got %d entries, expected 75", n)
+	}
+	if len(shed) != 25 || shed[0] != "1" || shed[24] != "25" {
+		t.Errorf("Got %v shed, expected 1 to 25", shed)
+	}
+	if _, ok := lru.Get("0"); !ok {
+		t.Error("Got no entry for 0, expected the recently used entry to survive")
+	}
+	if s := lru.Stats(); s.Evictions != 25 {
+		t.Errorf("Got %d evictions, expected 25", s.Evictions)
+	}
+
+	// at least one entry goes when barely over the limit, pinned entries being skipped
+	usage = 1001
+	lru.Pin("26")
+	lru.EvictExpired()
+	if n := lru.Len(); n != 74 {
+		t.Errorf("Got %d entries, expected 74", n)
+	}
+	if _, ok := lru.Get("26"); !ok {
+		t.Error("Got no entry for 26, expected the pinned entry to survive")
+	}
+	if shed[len(shed)-1] != "27" {
+		t.Errorf("Got %s shed, expected 27", shed[len(shed)-1])
+	}
+}
+
+func TestProcessMemory(t *testing.T) {
+	if m := processMemory(); m == 0 {
+		t.Error("Got 0 bytes used by the process")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// monitored is the part of a cache needed to report metrics about it.
+type monitored interface {
+	Stats() Stats
+	Len() int
+}
+
+// collector reports the stats of a cache as Prometheus metrics, reading them when metrics are
+// gathered such that cache operations don't pay for metrics.
+type collector struct {
+	c                 monitored
+	writes            *prometheus.Desc
+	hits              *prometheus.Desc
+	misses            *prometheus.Desc
+	evictions         *prometheus.Desc
+	expirations       *prometheus.Desc
+	capacityEvictions *prometheus.Desc
+	removals          *prometheus.Desc
+	entries           *prometheus.Desc
+}
+
+// Monitor exports the stats of a cache as Prometheus metrics, labeled with the given name. The
+// evictions are also broken down into expirations and capacity evictions, as in Stats.
+//
+// The metrics are registered with the default Prometheus registry, and are computed from the
+// cache's Stats whenever they are gathered. Note that the registry keeps the cache referenced,
+// so the cache remains alive for the lifetime of the process.
+func Monitor[K comparable, V any](name string, c Typed[K, V]) error {
+	return monitor(prometheus.DefaultRegisterer, name, c)
+}
+
+func monitor(r prometheus.Registerer, name string, c monitored) error {
+	labels := prometheus.Labels{"cache": name}
+	return r.Register(&collector{
+		c:           c,
+		writes:      prometheus.NewDesc("cache_writes_total", "Number of entries added or updated in the cache.", nil, labels),
+		hits:        prometheus.NewDesc("cache_hits_total", "Number of lookups which found an entry in the cache.", nil, labels),
+		misses:      prometheus.NewDesc("cache_misses_total", "Number of lookups which failed to find an entry in the cache.", nil, labels),
+		evictions:   prometheus.NewDesc("cache_evictions_total", "Number of entries evicted from the cache.", nil, labels),
+		expirations: prometheus.NewDesc("cache_expirations_total", "Number of entries evicted from the cache once expired.", nil, labels),
+		capacityEvictions: prometheus.NewDesc("cache_capacity_evictions_total",
+			"Number of entries evicted from the cache to make room for others.", nil, labels),
+		removals: prometheus.NewDesc("cache_removals_total", "Number of entries explicitly removed from the cache.", nil, labels),
+		entries:  prometheus.NewDesc("cache_entries", "Number of entries currently held in the cache.", nil, labels),
+	})
+}
+
+func (m *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.writes
+	ch <- m.hits
+	ch <- m.misses
+	ch <- m.evictions
+	ch <- m.expirations
+	ch <- m.capacityEvictions
+	ch <- m.removals
+	ch <- m.entries
+}
+
+func (m *collector) Collect(ch chan<- prometheus.Metric) {
+	s := m.c.Stats()
+	ch <- prometheus.MustNewConstMetric(m.writes, prometheus.CounterValue, float64(s.Writes))
+	ch <- prometheus.MustNewConstMetric(m.hits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(m.misses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(m.evictions, prometheus.CounterValue, float64(s.Evictions))
+	ch <- prometheus.MustNewConstMetric(m.expirations, prometheus.CounterValue, float64(s.Expirations))
+	ch <- prometheus.MustNewConstMetric(m.capacityEvictions, prometheus.CounterValue, float64(s.CapacityEvictions))
+	ch <- prometheus.MustNewConstMetric(m.removals, prometheus.CounterValue, float64(s.Removals))
+	ch <- prometheus.MustNewConstMetric(m.entries, prometheus.GaugeValue, float64(m.c.Len()))
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMonitor(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	a := NewLRU(5*time.Minute, 0, 2)
+	b := NewTypedTTL[string, int](5*time.Minute, 0)
+	if err := monitor(reg, "a", a); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+	if err := monitor(reg, "b", b); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	// each cache can only be monitored once under a given name
+	if err := monitor(reg, "a", a); err == nil {
+		t.Error("Got success, expecting a duplicate registration to fail")
+	}
+
+	a.Set("X", "1")
+	a.Set("Y", "2")
+	a.Get("X")
+	a.Get("Z")
+	a.Remove("Y")
+	b.Set("X", 1)
+
+	// X is the least recently used entry once V and W are added
+	a.Set("V", "3")
+	a.Set("W", "4")
+
+	clock := NewFakeClock(time.Now())
+	b.SetClock(clock)
+	b.SetWithExpiration("E", 2, time.Second)
+	clock.Advance(2 * time.Second)
+	b.EvictExpired()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	got := make(map[string]float64)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			name := f.GetName() + "/" + m.GetLabel()[0].GetValue()
+			if m.GetCounter() != nil {
+				got[name] = m.GetCounter().GetValue()
+			} else {
+				got[name] = m.GetGauge().GetValue()
+			}
+		}
+	}
+
+	expected := map[string]float64{
+		"cache_writes_total/a":             4,
+		"cache_hits_total/a":               1,
+		"cache_misses_total/a":             1,
+		"cache_evictions_total/a":          1,
+		"cache_expirations_total/a":        0,
+		"cache_capacity_evictions_total/a": 1,
+		"cache_removals_total/a":           1,
+		"cache_entries/a":                  2,
+		"cache_writes_total/b":             2,
+		"cache_evictions_total/b":          1,
+		"cache_expirations_total/b":        1,
+		"cache_capacity_evictions_total/b": 0,
+		"cache_entries/b":                  1,
+	}
+	for name, value := range expected {
+		if v, ok := got[name]; !ok || v != value {
+			t.Errorf("Got %v for %s, expected %v", v, name, value)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Client is the subset of the Redis commands used by the cache. It is satisfied by Conn,
+// and can be implemented on top of any other Redis client library.
+type Client interface {
+	// Get returns the value of key, and whether the key exists.
+	Get(key string) ([]byte, bool, error)
+
+	// Set sets the value of key, to expire after ttl.
+	Set(key string, value []byte, ttl time.Duration) error
+
+	// IncrBy adds delta to the integer value of key and returns the result. A missing key is
+	// created with the value delta, to expire after ttl, while an existing key keeps its expiration.
+	// The increment must be atomic, such as with INCRBY and PEXPIRE run by a script.
+	IncrBy(key string, delta int64, ttl time.Duration) (int64, error)
+
+	// Del deletes keys, returning the number of keys which existed.
+	Del(keys ...string) (int, error)
+
+	// Scan iterates over the keys matching a pattern, returning a batch of keys along with the
+	// cursor to pass to the next call. Iteration is complete when the returned cursor is 0.
+	Scan(cursor uint64, match string, count int) ([]string, uint64, error)
+
+	// Info returns the given section of the server's INFO report.
+	Info(section string) (string, error)
+}
+
+// Error is an error reply from the Redis server.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+var errProtocol = errors.New("redis: protocol error")
+
+// Conn is a Client speaking the Redis protocol over a single connection. Commands are
+// serialized over the connection, so a Conn is safe for concurrent use.
+//
+// The connection is closed on any error other than an error reply, since a reply which wasn't
+// read, or only partially, would otherwise be taken as the reply to the next command. A Conn
+// created by Dial connects again on the next command, while the commands of a Conn created by
+// NewConn keep failing.
+type Conn struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	r       *bufio.Reader
+	w       *bufio.Writer
+	timeout time.Duration
+	address string // the address to connect to again once broken, set by Dial
+	broken  error  // the error which broke the connection
+	closed  bool
+}
+
+var _ Client = &Conn{}
+
+// ErrClosed is returned by the commands of a Conn which was closed.
+var ErrClosed = errors.New("redis: connection closed")
+
+// Dial connects to the Redis server at address. The timeout applies to establishing the
+// connection as well as to each command, zero meaning no timeout.
+func Dial(address string, timeout time.Duration) (*Conn, error) {
+	conn, err := dial(address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := NewConn(conn, timeout)
+	c.address = address
+	return c, nil
+}
+
+func dial(address string, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to redis at %s: %v", address, err)
+	}
+	return conn, nil
+}
+
+// NewConn returns a Conn speaking the Redis protocol over an established connection.
+func NewConn(conn net.Conn, timeout time.Duration) *Conn {
+	c := &Conn{timeout: timeout}
+	c.reset(conn)
+	return c
+}
+
+func (c *Conn) reset(conn net.Conn) {
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+	c.w = bufio.NewWriter(conn)
+	c.broken = nil
+}
+
+// Close closes the underlying connection, after the command in progress if any.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.broken != nil {
+		return nil
+	}
+	c.broken = ErrClosed
+	return c.conn.Close()
+}
+
+// Do sends a command to the server and returns its reply. Replies are returned as strings for
+// status replies, int64 for integer replies, []byte for bulk replies, []interface{} for array
+// replies, and nil for null replies. Error replies are returned as an Error.
+func (c *Conn) Do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.usable(); err != nil {
+		return nil, err
+	}
+
+	if c.timeout > 0 {
+		if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+			return nil, c.fail(err)
+		}
+	}
+
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, c.fail(err)
+	}
+
+	reply, err := readReply(c.r)
+	if err != nil {
+		return nil, c.fail(err)
+	}
+	if e, ok := reply.(Error); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// usable connects again if the connection is broken and the address is known, or returns why
+// the connection can't be used.
+func (c *Conn) usable() error {
+	switch {
+	case c.closed:
+		return ErrClosed
+	case c.broken == nil:
+		return nil
+	case c.address == "":
+		return fmt.Errorf("redis: connection broken: %v", c.broken)
+	}
+
+	conn, err := dial(c.address, c.timeout)
+	if err != nil {
+		return err
+	}
+	c.reset(conn)
+	return nil
+}
+
+// fail closes the connection, such that the reply to the failed command isn't read as the
+// reply to the next one, and returns err.
+func (c *Conn) fail(err error) error {
+	c.broken = err
+	_ = c.conn.Close()
+	return err
+}
+
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errProtocol
+	}
+	kind, line := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return line, nil
+
+	case '-':
+		return Error(line), nil
+
+	case ':':
+		n, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return nil, errProtocol
+		}
+		return n, nil
+
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			// error replies nested in arrays are returned as is
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+
+	return nil, errProtocol
+}
+
+func (c *Conn) Get(key string) ([]byte, bool, error) {
+	reply, err := c.Do("GET", key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	b, ok := reply.([]byte)
+	if !ok {
+		return nil, false, errProtocol
+	}
+	return b, true, nil
+}
+
+func (c *Conn) Set(key string, value []byte, ttl time.Duration) error {
+	_, err := c.Do("SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// incrByScript increments a key, and sets the expiration of the keys created by the increment,
+// which are the only ones without one since the cache sets every key with an expiration.
+const incrByScript = `local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return n`
+
+func (c *Conn) IncrBy(key string, delta int64, ttl time.Duration) (int64, error) {
+	reply, err := c.Do("EVAL", incrByScript, "1", key, strconv.FormatInt(delta, 10), strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, errProtocol
+	}
+	return n, nil
+}
+
+func (c *Conn) Del(keys ...string) (int, error) {
+	reply, err := c.Do(append([]string{"DEL"}, keys...)...)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, errProtocol
+	}
+	return int(n), nil
+}
+
+func (c *Conn) Scan(cursor uint64, match string, count int) ([]string, uint64, error) {
+	reply, err := c.Do("SCAN", strconv.FormatUint(cursor, 10), "MATCH", match, "COUNT", strconv.Itoa(count))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != 2 {
+		return nil, 0, errProtocol
+	}
+	next, ok := items[0].([]byte)
+	if !ok {
+		return nil, 0, errProtocol
+	}
+	if cursor, err = strconv.ParseUint(string(next), 10, 64); err != nil {
+		return nil, 0, errProtocol
+	}
+	found, ok := items[1].([]interface{})
+	if !ok {
+		return nil, 0, errProtocol
+	}
+
+	keys := make([]string, 0, len(found))
+	for _, k := range found {
+		b, ok := k.([]byte)
+		if !ok {
+			return nil, 0, errProtocol
+		}
+		keys = append(keys, string(b))
+	}
+	return keys, cursor, nil
+}
+
+func (c *Conn) Info(section string) (string, error) {
+	reply, err := c.Do("INFO", section)
+	if err != nil {
+		return "", err
+	}
+	b, ok := reply.([]byte)
+	if !ok {
+		return "", errProtocol
+	}
+	return string(b), nil
+}
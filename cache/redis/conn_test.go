@@ -0,0 +1,363 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer is an in-memory server speaking enough of the Redis protocol for the tests.
+type fakeServer struct {
+	sync.Mutex
+	entries map[string]fakeEntry
+	hits    int
+	misses  int
+	expired int
+	now     time.Time
+}
+
+type fakeEntry struct {
+	value      string
+	expiration time.Time
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{entries: make(map[string]fakeEntry), now: time.Now()}
+}
+
+// dial returns a Conn to the server.
+func (s *fakeServer) dial(t *testing.T) *Conn {
+	client, server := net.Pipe()
+	go s.serve(server)
+	t.Cleanup(func() { _ = client.Close() })
+	return NewConn(client, time.Second)
+}
+
+// advance moves the server's clock, expiring entries.
+func (s *fakeServer) advance(d time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	s.now = s.now.Add(d)
+	for k, e := range s.entries {
+		if !e.expiration.After(s.now) {
+			delete(s.entries, k)
+			s.expired++
+		}
+	}
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		cmd, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		s.Lock()
+		s.execute(w, cmd)
+		s.Unlock()
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	reply, err := readReply(r)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected command %v", reply)
+	}
+	cmd := make([]string, len(items))
+	for i, item := range items {
+		cmd[i] = string(item.([]byte))
+	}
+	return cmd, nil
+}
+
+func bulk(w io.Writer, s string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+}
+
+func (s *fakeServer) execute(w io.Writer, cmd []string) {
+	switch strings.ToUpper(cmd[0]) {
+	case "GET":
+		if e, ok := s.entries[cmd[1]]; ok {
+			s.hits++
+			bulk(w, e.value)
+		} else {
+			s.misses++
+			fmt.Fprint(w, "$-1\r\n")
+		}
+
+	case "SET":
+		if len(cmd) != 5 || cmd[3] != "PX" {
+			fmt.Fprint(w, "-ERR syntax error\r\n")
+			return
+		}
+		ms, err := strconv.Atoi(cmd[4])
+		if err != nil || ms <= 0 {
+			fmt.Fprint(w, "-ERR invalid expire time in 'set' command\r\n")
+			return
+		}
+		s.entries[cmd[1]] = fakeEntry{cmd[2], s.now.Add(time.Duration(ms) * time.Millisecond)}
+		fmt.Fprint(w, "+OK\r\n")
+
+	case "DEL":
+		n := 0
+		for _, k := range cmd[1:] {
+			if _, ok := s.entries[k]; ok {
+				delete(s.entries, k)
+				n++
+			}
+		}
+		fmt.Fprintf(w, ":%d\r\n", n)
+
+	case "SCAN":
+		// all the keys are returned at once
+		var keys []string
+		for k := range s.entries {
+			if ok, _ := path.Match(cmd[3], k); ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(w, "*2\r\n")
+		bulk(w, "0")
+		fmt.Fprintf(w, "*%d\r\n", len(keys))
+		for _, k := range keys {
+			bulk(w, k)
+		}
+
+	case "EVAL":
+		// only the script of IncrBy is supported
+		if len(cmd) != 6 || cmd[1] != incrByScript || cmd[2] != "1" {
+			fmt.Fprint(w, "-ERR unsupported script\r\n")
+			return
+		}
+		delta, _ := strconv.ParseInt(cmd[4], 10, 64)
+		ms, _ := strconv.Atoi(cmd[5])
+		e, ok := s.entries[cmd[3]]
+		var n int64
+		if ok {
+			var err error
+			if n, err = strconv.ParseInt(e.value, 10, 64); err != nil {
+				fmt.Fprint(w, "-ERR value is not an integer or out of range\r\n")
+				return
+			}
+		} else {
+			e.expiration = s.now.Add(time.Duration(ms) * time.Millisecond)
+		}
+		n += delta
+		e.value = strconv.FormatInt(n, 10)
+		s.entries[cmd[3]] = e
+		fmt.Fprintf(w, ":%d\r\n", n)
+
+	case "INFO":
+		bulk(w, fmt.Sprintf("# Stats\r\nkeyspace_hits:%d\r\nkeyspace_misses:%d\r\nexpired_keys:%d\r\nevicted_keys:0\r\n",
+			s.hits, s.misses, s.expired))
+
+	default:
+		fmt.Fprintf(w, "-ERR unknown command '%s'\r\n", cmd[0])
+	}
+}
+
+func TestConn(t *testing.T) {
+	c := newFakeServer().dial(t)
+
+	if err := c.Set("A", []byte("a\r\nb"), time.Second); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+	if v, ok, err := c.Get("A"); err != nil || !ok || string(v) != "a\r\nb" {
+		t.Errorf("Got %q, %v, %v, expecting the value to round-trip", v, ok, err)
+	}
+	if _, ok, err := c.Get("B"); err != nil || ok {
+		t.Errorf("Got %v, %v, expecting a miss", ok, err)
+	}
+
+	keys, cursor, err := c.Scan(0, "*", 10)
+	if err != nil || cursor != 0 || len(keys) != 1 || keys[0] != "A" {
+		t.Errorf("Got %v, %d, %v, expecting to find A", keys, cursor, err)
+	}
+
+	if n, err := c.IncrBy("N", 3, time.Second); err != nil || n != 3 {
+		t.Errorf("Got %d, %v, expecting a missing key to be created with the delta", n, err)
+	}
+	if n, err := c.IncrBy("N", -1, time.Minute); err != nil || n != 2 {
+		t.Errorf("Got %d, %v, expecting 2", n, err)
+	}
+	if _, err := c.IncrBy("A", 1, time.Second); err == nil {
+		t.Error("Got success, expecting a value which isn't an integer not to be incremented")
+	}
+
+	if n, err := c.Del("A", "B", "N"); err != nil || n != 2 {
+		t.Errorf("Got %d, %v, expecting 1 key to be deleted", n, err)
+	}
+
+	info, err := c.Info("stats")
+	if err != nil || !strings.Contains(info, "keyspace_hits:1") {
+		t.Errorf("Got %q, %v, expecting the stats section", info, err)
+	}
+
+	// error replies are surfaced as errors
+	_, err = c.Do("BOGUS")
+	if _, ok := err.(Error); !ok {
+		t.Errorf("Got %v, expecting an error reply", err)
+	}
+
+	// the connection remains usable after an error reply
+	if err := c.Set("A", nil, 0); err == nil {
+		t.Error("Got success, expecting an invalid expiration to be rejected")
+	}
+	if _, _, err := c.Get("A"); err != nil {
+		t.Errorf("Got %v, expecting success", err)
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	cases := []struct {
+		data  string
+		reply interface{}
+	}{
+		{"+OK\r\n", "OK"},
+		{"-ERR bad\r\n", Error("ERR bad")},
+		{":42\r\n", int64(42)},
+		{"$3\r\nabc\r\n", "abc"},
+		{"$-1\r\n", nil},
+		{"*-1\r\n", nil},
+		{"*2\r\n:1\r\n$1\r\nx\r\n", "[1 x]"},
+	}
+
+	for _, tc := range cases {
+		t.Run(strconv.Quote(tc.data), func(t *testing.T) {
+			reply, err := readReply(bufio.NewReader(strings.NewReader(tc.data)))
+			if err != nil {
+				t.Fatalf("Got error %v, expecting success", err)
+			}
+			switch r := reply.(type) {
+			case []byte:
+				reply = string(r)
+			case []interface{}:
+				for i, item := range r {
+					if b, ok := item.([]byte); ok {
+						r[i] = string(b)
+					}
+				}
+				reply = fmt.Sprint(r)
+			}
+			if reply != tc.reply {
+				t.Errorf("Got %#v, expecting %#v", reply, tc.reply)
+			}
+		})
+	}
+
+	for _, bad := range []string{"", "?\r\n", "+OK\n", ":x\r\n", "$x\r\n", "$5\r\nab"} {
+		if _, err := readReply(bufio.NewReader(strings.NewReader(bad))); err == nil {
+			t.Errorf("Got success for %q, expecting an error", bad)
+		}
+	}
+	for _, bad := range []string{"?\r\n", ":x\r\n", "$x\r\n"} {
+		if _, err := readReply(bufio.NewReader(strings.NewReader(bad))); err != errProtocol {
+			t.Errorf("Got %v for %q, expecting a protocol error", err, bad)
+		}
+	}
+}
+
+// serveMalformed answers the first command on conn with a malformed reply followed by a valid one,
+// which must not be taken as the reply to the next command.
+func serveMalformed(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	if _, err := readCommand(r); err != nil {
+		return
+	}
+	_, _ = io.WriteString(conn, ":x\r\n$5\r\nstale\r\n")
+	_, _ = readCommand(r)
+}
+
+func TestConnBroken(t *testing.T) {
+	client, server := net.Pipe()
+	go serveMalformed(server)
+	c := NewConn(client, time.Second)
+
+	if _, _, err := c.Get("A"); err != errProtocol {
+		t.Errorf("Got %v, expecting a protocol error", err)
+	}
+	if v, _, err := c.Get("B"); err == nil {
+		t.Errorf("Got %q, expecting the broken connection not to be used", v)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Got %v, expecting the connection to be closed already", err)
+	}
+	if _, _, err := c.Get("B"); err != ErrClosed {
+		t.Errorf("Got %v, expecting the connection to be closed", err)
+	}
+}
+
+func TestConnRedial(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	defer l.Close()
+
+	s := newFakeServer()
+	go func() {
+		for i := 0; ; i++ {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if i == 0 {
+				go serveMalformed(conn)
+			} else {
+				go s.serve(conn)
+			}
+		}
+	}()
+
+	c, err := Dial(l.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatalf("Unable to dial: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Set("A", []byte("a"), time.Minute); err != errProtocol {
+		t.Errorf("Got %v, expecting a protocol error", err)
+	}
+	// the next command goes through a new connection
+	if _, ok, err := c.Get("A"); err != nil || ok {
+		t.Errorf("Got %v, %v, expecting a miss", ok, err)
+	}
+	if err := c.Set("A", []byte("a"), time.Minute); err != nil {
+		t.Errorf("Got %v, expecting success", err)
+	}
+	if v, ok, err := c.Get("A"); err != nil || !ok || string(v) != "a" {
+		t.Errorf("Got %q, %v, %v, expecting a", v, ok, err)
+	}
+}
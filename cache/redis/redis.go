@@ -0,0 +1,515 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redis provides a cache backed by a Redis server, such that multiple replicas
+// of a service can share cached entries.
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"istio.io/pkg/cache"
+)
+
+// scanBatch is the number of keys requested from the server at a time by RemoveAll.
+const scanBatch = 100
+
+// Options controls how a cache maps its keys and values to Redis.
+type Options[K comparable, V any] struct {
+	// Prefix is prepended to the keys of the cache, such that several caches can share a
+	// server. RemoveAll only removes the keys with this prefix.
+	Prefix string
+
+	// EncodeKey turns keys into strings. Defaults to formatting keys with fmt.Sprint.
+	EncodeKey func(key K) string
+
+	// DecodeKey turns strings produced by EncodeKey back into keys. It is only needed for
+	// RemoveAll to report the removed entries to the eviction callback, and defaults to
+	// the identity for caches with string keys.
+	DecodeKey func(s string) (K, error)
+
+	// Marshal and Unmarshal serialize the values stored in the server. Default to using
+	// encoding/gob, which for untyped caches requires the concrete types of the values
+	// to be registered with gob.Register unless they are basic types.
+	Marshal   func(value V) ([]byte, error)
+	Unmarshal func(data []byte) (V, error)
+
+	// OnError is called with the errors encountered while talking to the server, which
+	// the Cache interface otherwise has no way to report. Failed reads are reported as
+	// misses, failed writes mean the entry isn't cached.
+	OnError func(err error)
+}
+
+type redisCache[K comparable, V any] struct {
+	client            Client
+	opts              Options[K, V]
+	defaultExpiration time.Duration
+	writes            uint64
+	removals          uint64
+	callback          atomic.Pointer[func(key K, value V, reason cache.EvictionReason)]
+	loads             cache.LoadGroup[K, V]
+	keyLocks          cache.KeyLocks[K]
+
+	// the server's counters can't be reset, so the stats are reported relative to base
+	statsMu sync.Mutex
+	base    cache.Stats
+	last    cache.Stats
+}
+
+// New creates a cache holding its entries in a Redis server.
+//
+// Entries are set in the server with an expiration time, and are expired by the server
+// itself, so EvictExpired does nothing. Eviction callbacks are only invoked for entries
+// explicitly removed through this cache, and expiration callbacks never are, since
+// expirations and evictions happen in the server, unseen. See Options.DecodeKey for the
+// entries removed by RemoveAll.
+//
+// Hits, misses and evictions reported by Stats are sourced from the server's INFO report,
+// and as such cover all the keys of the server rather than only the keys of this cache.
+// Writes and removals are those performed through this cache.
+func New(client Client, defaultExpiration time.Duration, opts Options[interface{}, interface{}]) cache.ExpiringCache {
+	if opts.Marshal == nil && opts.Unmarshal == nil {
+		opts.Marshal = func(value interface{}) ([]byte, error) {
+			return gobMarshal(&value)
+		}
+		opts.Unmarshal = func(data []byte) (interface{}, error) {
+			var value interface{}
+			err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+			return value, err
+		}
+	}
+	return NewTyped[interface{}, interface{}](client, defaultExpiration, opts)
+}
+
+// NewTyped creates a cache holding its entries in a Redis server, with keys of type K and values
+// of type V. See also: New.
+func NewTyped[K comparable, V any](client Client, defaultExpiration time.Duration, opts Options[K, V]) cache.Expiring[K, V] {
+	if _, ok := interface{}(*new(K)).(string); ok && opts.DecodeKey == nil && opts.EncodeKey == nil {
+		opts.DecodeKey = func(s string) (K, error) {
+			return interface{}(s).(K), nil
+		}
+	}
+	if opts.EncodeKey == nil {
+		opts.EncodeKey = func(key K) string {
+			return fmt.Sprint(key)
+		}
+	}
+	if opts.Marshal == nil {
+		opts.Marshal = func(value V) ([]byte, error) {
+			return gobMarshal(value)
+		}
+	}
+	if opts.Unmarshal == nil {
+		opts.Unmarshal = func(data []byte) (V, error) {
+			var value V
+			err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+			return value, err
+		}
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {}
+	}
+
+	return &redisCache[K, V]{
+		client:            client,
+		opts:              opts,
+		defaultExpiration: defaultExpiration,
+	}
+}
+
+func gobMarshal(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *redisCache[K, V]) key(key K) string {
+	return c.opts.Prefix + c.opts.EncodeKey(key)
+}
+
+func (c *redisCache[K, V]) Set(key K, value V) {
+	c.SetWithExpiration(key, value, c.defaultExpiration)
+}
+
+func (c *redisCache[K, V]) SetWithExpiration(key K, value V, expiration time.Duration) {
+	atomic.AddUint64(&c.writes, 1)
+	k := c.key(key)
+
+	if expiration <= 0 {
+		// already expired
+		if _, err := c.client.Del(k); err != nil {
+			c.opts.OnError(err)
+		}
+		return
+	}
+
+	data, err := c.opts.Marshal(value)
+	if err != nil {
+		c.opts.OnError(fmt.Errorf("unable to marshal value for key %s: %v", k, err))
+		return
+	}
+
+	if err := c.client.Set(k, data, serverTTL(expiration)); err != nil {
+		c.opts.OnError(err)
+	}
+}
+
+// serverTTL rounds up an expiration to the millisecond precision of the server, such that entries
+// never expire early.
+func serverTTL(expiration time.Duration) time.Duration {
+	ttl := expiration.Truncate(time.Millisecond)
+	if ttl < expiration {
+		ttl += time.Millisecond
+	}
+	return ttl
+}
+
+// SetAll sets each of the entries in turn, the Client interface has no batch operations.
+func (c *redisCache[K, V]) SetAll(entries map[K]V) {
+	for key, value := range entries {
+		c.Set(key, value)
+	}
+}
+
+// GetAll gets each of the keys in turn, the Client interface has no batch operations.
+func (c *redisCache[K, V]) GetAll(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+	for _, key := range keys {
+		if value, ok := c.Get(key); ok {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func (c *redisCache[K, V]) Get(key K) (V, bool) {
+	var zero V
+	k := c.key(key)
+
+	data, ok, err := c.client.Get(k)
+	if err != nil {
+		c.opts.OnError(err)
+		return zero, false
+	}
+	if !ok {
+		return zero, false
+	}
+
+	value, err := c.unmarshal(data)
+	if err != nil {
+		c.opts.OnError(fmt.Errorf("unable to unmarshal value for key %s: %v", k, err))
+		return zero, false
+	}
+	return value, true
+}
+
+// unmarshal decodes a value read from the server. The values of Increment are held as decimal
+// integers rather than marshaled, for the server to increment them, so the values which fail to
+// unmarshal are decoded as such when they are integers.
+func (c *redisCache[K, V]) unmarshal(data []byte) (V, error) {
+	value, err := c.opts.Unmarshal(data)
+	if err == nil {
+		return value, nil
+	}
+	n, perr := strconv.ParseInt(string(data), 10, 64)
+	if perr != nil {
+		return value, err
+	}
+	counter, _, cerr := cache.IncrementValue(*new(V), false, n)
+	if cerr != nil {
+		return value, err
+	}
+	return counter, nil
+}
+
+// Increment has the server increment the value of the key, such that increments are atomic across
+// all the processes sharing the server. The value is held as a decimal integer, so the values set
+// through Set can only be incremented if they marshal to one. The errors of the server, including
+// those about values which aren't integers, are returned rather than reported to Options.OnError.
+func (c *redisCache[K, V]) Increment(key K, delta int64) (int64, error) {
+	if _, _, err := cache.IncrementValue(*new(V), false, delta); err != nil {
+		return 0, err
+	}
+
+	atomic.AddUint64(&c.writes, 1)
+	return c.client.IncrBy(c.key(key), delta, serverTTL(c.defaultExpiration))
+}
+
+// LockKey only serializes the callers within this process, other processes sharing the server
+// don't see the lock.
+func (c *redisCache[K, V]) LockKey(key K) func() {
+	return c.keyLocks.Lock(key)
+}
+
+func (c *redisCache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	return c.loads.GetOrLoad(c, key, loader)
+}
+
+func (c *redisCache[K, V]) GetOrLoadCtx(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error) {
+	return c.loads.GetOrLoadCtx(ctx, c, key, loader)
+}
+
+func (c *redisCache[K, V]) Remove(key K) {
+	c.remove(c.key(key), key)
+}
+
+// remove deletes a key from the server, notifying the eviction callback if the key existed.
+func (c *redisCache[K, V]) remove(k string, key K) bool {
+	cb := c.callback.Load()
+	var value V
+	var found bool
+	if cb != nil {
+		value, found = c.Get(key)
+	}
+
+	n, err := c.client.Del(k)
+	if err != nil {
+		c.opts.OnError(err)
+		return false
+	}
+	if n > 0 {
+		atomic.AddUint64(&c.removals, uint64(n))
+		if found {
+			(*cb)(key, value, cache.ReasonRemoved)
+		}
+	}
+	return n > 0
+}
+
+func (c *redisCache[K, V]) RemoveAll() {
+	c.scan(func(k string) bool {
+		if c.opts.DecodeKey != nil && c.callback.Load() != nil {
+			if key, err := c.opts.DecodeKey(strings.TrimPrefix(k, c.opts.Prefix)); err == nil {
+				c.remove(k, key)
+				return true
+			}
+		}
+
+		if n, err := c.client.Del(k); err != nil {
+			c.opts.OnError(err)
+		} else {
+			atomic.AddUint64(&c.removals, uint64(n))
+		}
+		return true
+	})
+}
+
+// scan calls f with each of the keys of the server with the cache's prefix, until f returns false.
+func (c *redisCache[K, V]) scan(f func(k string) bool) {
+	var cursor uint64
+	for {
+		keys, next, err := c.client.Scan(cursor, escapePattern(c.opts.Prefix)+"*", scanBatch)
+		if err != nil {
+			c.opts.OnError(err)
+			return
+		}
+
+		for _, k := range keys {
+			if !f(k) {
+				return
+			}
+		}
+
+		if cursor = next; cursor == 0 {
+			return
+		}
+	}
+}
+
+// escapePattern escapes the characters of s which have a special meaning in SCAN patterns.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// EvictExpired does nothing, the server expires entries by itself.
+func (c *redisCache[K, V]) EvictExpired() {
+}
+
+// SetClock does nothing, the server's clock governs expiration.
+func (c *redisCache[K, V]) SetClock(clock cache.Clock) {
+}
+
+// WriteTo writes no entries, since the entries of the cache are held in the server and are
+// shared by all the instances talking to it already.
+func (c *redisCache[K, V]) WriteTo(w io.Writer) (int64, error) {
+	return cache.WriteEntries[K, V](w, func(f func(key K, value V, ttl time.Duration) bool) {})
+}
+
+// ReadFrom sets the entries written by the WriteTo method of another cache in the server, which
+// lets the contents of an in-memory cache be moved to Redis.
+func (c *redisCache[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	return cache.ReadEntries[K, V](r, c.SetWithExpiration)
+}
+
+// SetExpirationCallback does nothing, the server expires entries without telling the cache.
+func (c *redisCache[K, V]) SetExpirationCallback(callback func(key K, value V)) {
+}
+
+// SetRefresher does nothing, since the Client interface doesn't tell how long entries have left
+// before they expire.
+func (c *redisCache[K, V]) SetRefresher(refresh func(key K) (V, error), ahead time.Duration) {
+}
+
+func (c *redisCache[K, V]) SetEvictionCallback(callback func(key K, value V, reason cache.EvictionReason)) {
+	if callback == nil {
+		c.callback.Store(nil)
+		return
+	}
+	c.callback.Store(&callback)
+}
+
+// RemoveIf removes the entries of the cache held in the server which match the predicate, which
+// requires keys to be decoded. Without Options.DecodeKey, no entry is ever removed.
+func (c *redisCache[K, V]) RemoveIf(predicate func(key K, value V) bool) int {
+	n := 0
+	c.ForEach(func(key K, value V) bool {
+		if predicate(key, value) && c.remove(c.key(key), key) {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+func (c *redisCache[K, V]) Preload(entries map[K]V) {
+	c.SetAll(entries)
+}
+
+// Snapshot returns the entries of the cache held in the server, which requires keys to be
+// decoded. Without Options.DecodeKey, the snapshot is always empty.
+func (c *redisCache[K, V]) Snapshot() map[K]V {
+	result := make(map[K]V)
+	c.ForEach(func(key K, value V) bool {
+		result[key] = value
+		return true
+	})
+	return result
+}
+
+// ForEach iterates over the entries of the cache held in the server, which requires keys to be
+// decoded. Without Options.DecodeKey, f is never called.
+func (c *redisCache[K, V]) ForEach(f func(key K, value V) bool) {
+	if c.opts.DecodeKey == nil {
+		return
+	}
+
+	c.scan(func(k string) bool {
+		key, err := c.opts.DecodeKey(strings.TrimPrefix(k, c.opts.Prefix))
+		if err != nil {
+			c.opts.OnError(fmt.Errorf("unable to decode key %s: %v", k, err))
+			return true
+		}
+		if value, ok := c.Get(key); ok {
+			return f(key, value)
+		}
+		return true
+	})
+}
+
+// Len counts the keys of the cache held in the server, which requires scanning through them.
+func (c *redisCache[K, V]) Len() int {
+	n := 0
+	c.scan(func(k string) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func (c *redisCache[K, V]) Stats() cache.Stats {
+	s := c.serverStats()
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return s.Sub(c.base)
+}
+
+func (c *redisCache[K, V]) StatsDelta() cache.Stats {
+	s := c.Stats()
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	d := s.Sub(c.last)
+	c.last = s
+	return d
+}
+
+// ResetStats takes the current counters as the new base, since those of the server can't be reset.
+func (c *redisCache[K, V]) ResetStats() {
+	s := c.serverStats()
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	c.base = s
+	c.last = cache.Stats{}
+}
+
+// serverStats returns the counters of this cache and of the server, since it was created.
+func (c *redisCache[K, V]) serverStats() cache.Stats {
+	s := cache.Stats{
+		Writes:   atomic.LoadUint64(&c.writes),
+		Removals: atomic.LoadUint64(&c.removals),
+	}
+
+	info, err := c.client.Info("stats")
+	if err != nil {
+		c.opts.OnError(err)
+		return s
+	}
+
+	fields := parseInfo(info)
+	s.Hits = fields["keyspace_hits"]
+	s.Misses = fields["keyspace_misses"]
+	s.Expirations = fields["expired_keys"]
+	s.CapacityEvictions = fields["evicted_keys"]
+	s.Evictions = s.Expirations + s.CapacityEvictions
+	return s
+}
+
+// parseInfo extracts the numeric fields of an INFO report.
+func parseInfo(info string) map[string]uint64 {
+	fields := make(map[string]uint64)
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+			fields[name] = n
+		}
+	}
+	return fields
+}
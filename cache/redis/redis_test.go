@@ -0,0 +1,433 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"istio.io/pkg/cache"
+)
+
+func TestBasic(t *testing.T) {
+	s := newFakeServer()
+	c := New(s.dial(t), time.Minute, Options[interface{}, interface{}]{Prefix: "test:"})
+
+	if _, ok := c.Get("X"); ok {
+		t.Error("Got an entry for X, expecting a miss")
+	}
+
+	c.Set("X", "12")
+	c.Set(1, 23)
+	if v, ok := c.Get("X"); !ok || v != "12" {
+		t.Errorf("Got %v, %v for X, expecting 12", v, ok)
+	}
+	if v, ok := c.Get(1); !ok || v != 23 {
+		t.Errorf("Got %v, %v for 1, expecting 23", v, ok)
+	}
+	if _, ok := s.entries["test:X"]; !ok {
+		t.Error("Got no test:X key in the server, expecting keys to be prefixed")
+	}
+
+	c.Remove("X")
+	if _, ok := c.Get("X"); ok {
+		t.Error("Got an entry for X, expecting it to have been removed")
+	}
+
+	// RemoveAll only affects the keys with the cache's prefix
+	other := New(s.dial(t), time.Minute, Options[interface{}, interface{}]{Prefix: "other*"})
+	other.Set("Y", "34")
+	c.RemoveAll()
+	if _, ok := c.Get(1); ok {
+		t.Error("Got an entry for 1, expecting it to have been removed")
+	}
+	if v, ok := other.Get("Y"); !ok || v != "34" {
+		t.Errorf("Got %v, %v for Y, expecting the other cache to be unaffected", v, ok)
+	}
+	other.RemoveAll()
+	if len(s.entries) != 0 {
+		t.Errorf("Got %d keys in the server, expecting none", len(s.entries))
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	s := newFakeServer()
+	c := NewTyped[string, string](s.dial(t), time.Minute, Options[string, string]{})
+
+	c.SetWithExpiration("EARLY", "123", 1500*time.Microsecond)
+	c.SetWithExpiration("LATER", "123", 20*time.Millisecond)
+	c.SetWithExpiration("NEVER", "123", 0)
+
+	// expirations are rounded up to the next millisecond
+	if exp := s.entries["EARLY"].expiration.Sub(s.now); exp != 2*time.Millisecond {
+		t.Errorf("Got expiration of %v, expecting 2ms", exp)
+	}
+	if _, ok := c.Get("NEVER"); ok {
+		t.Error("Got an entry for NEVER, expecting it to have expired immediately")
+	}
+
+	s.advance(10 * time.Millisecond)
+	c.EvictExpired()
+	if _, ok := c.Get("EARLY"); ok {
+		t.Error("Got an entry for EARLY, expecting it to have expired")
+	}
+	if _, ok := c.Get("LATER"); !ok {
+		t.Error("Got no entry for LATER, expecting it to still be present")
+	}
+}
+
+func TestTyped(t *testing.T) {
+	type payload struct {
+		Name  string
+		Count int
+	}
+
+	s := newFakeServer()
+	c := NewTyped[int, payload](s.dial(t), time.Minute, Options[int, payload]{
+		Marshal: func(value payload) ([]byte, error) {
+			return json.Marshal(value)
+		},
+		Unmarshal: func(data []byte) (payload, error) {
+			var p payload
+			err := json.Unmarshal(data, &p)
+			return p, err
+		},
+	})
+
+	c.Set(1, payload{"a", 1})
+	if v, ok := c.Get(1); !ok || v != (payload{"a", 1}) {
+		t.Errorf("Got %v, %v, expecting the value to round-trip", v, ok)
+	}
+	if data := s.entries["1"].value; data != `{"Name":"a","Count":1}` {
+		t.Errorf("Got %s in the server, expecting the value to have been encoded by the hook", data)
+	}
+}
+
+func TestEvictionCallback(t *testing.T) {
+	s := newFakeServer()
+	c := NewTyped[string, string](s.dial(t), time.Minute, Options[string, string]{Prefix: "p:"})
+
+	removed := map[string]string{}
+	c.SetEvictionCallback(func(key string, value string, reason cache.EvictionReason) {
+		if reason != cache.ReasonRemoved {
+			t.Errorf("Got reason %v, expecting removed", reason)
+		}
+		removed[key] = value
+	})
+
+	c.Set("A", "a")
+	c.Set("B", "b")
+	c.Set("C", "c")
+	c.Remove("A")
+	c.Remove("Z")
+	if len(removed) != 1 || removed["A"] != "a" {
+		t.Errorf("Got %v, expecting A to have been removed", removed)
+	}
+
+	// string keys can be decoded, so RemoveAll reports its removals too
+	c.RemoveAll()
+	if len(removed) != 3 || removed["B"] != "b" || removed["C"] != "c" {
+		t.Errorf("Got %v, expecting all entries to have been removed", removed)
+	}
+
+	// untyped keys can't be decoded by default
+	u := New(s.dial(t), time.Minute, Options[interface{}, interface{}]{})
+	calls := 0
+	u.SetEvictionCallback(func(key, value interface{}, reason cache.EvictionReason) {
+		calls++
+	})
+	u.Set("A", "a")
+	u.RemoveAll()
+	if calls != 0 || len(s.entries) != 0 {
+		t.Errorf("Got %d calls and %d keys, expecting the entry to be removed silently", calls, len(s.entries))
+	}
+}
+
+func TestBatch(t *testing.T) {
+	c := NewTyped[string, int](newFakeServer().dial(t), time.Minute, Options[string, int]{})
+
+	c.SetAll(map[string]int{"A": 1, "B": 2})
+	got := c.GetAll([]string{"A", "B", "Z"})
+	if len(got) != 2 || got["A"] != 1 || got["B"] != 2 {
+		t.Errorf("Got %v, expecting A and B", got)
+	}
+}
+
+func TestPreloadSnapshot(t *testing.T) {
+	s := newFakeServer()
+	c := NewTyped[string, int](s.dial(t), time.Minute, Options[string, int]{Prefix: "p:"})
+	other := NewTyped[string, int](s.dial(t), time.Minute, Options[string, int]{Prefix: "q:"})
+
+	c.Preload(map[string]int{"A": 1, "B": 2})
+	other.Set("C", 3)
+
+	if n := c.Len(); n != 2 {
+		t.Errorf("Got %d entries, expecting only the entries of the cache to be counted", n)
+	}
+
+	snapshot := c.Snapshot()
+	if len(snapshot) != 2 || snapshot["A"] != 1 || snapshot["B"] != 2 {
+		t.Errorf("Got snapshot %v, expecting only the entries of the cache", snapshot)
+	}
+
+	// untyped keys can't be decoded by default
+	u := New(s.dial(t), time.Minute, Options[interface{}, interface{}]{})
+	if snapshot := u.Snapshot(); len(snapshot) != 0 {
+		t.Errorf("Got snapshot %v, expecting it to be empty", snapshot)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	c := NewTyped[string, int](newFakeServer().dial(t), time.Minute, Options[string, int]{})
+	c.SetAll(map[string]int{"A": 1, "B": 2})
+
+	got := map[string]int{}
+	c.ForEach(func(key string, value int) bool {
+		got[key] = value
+		return true
+	})
+	if len(got) != 2 || got["A"] != 1 || got["B"] != 2 {
+		t.Errorf("Got %v, expecting A and B", got)
+	}
+
+	calls := 0
+	c.ForEach(func(key string, value int) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("Got %d calls, expected the iteration to stop after 1", calls)
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	c := NewTyped[string, int](newFakeServer().dial(t), time.Minute, Options[string, int]{})
+
+	if n, err := c.Increment("A", 5); err != nil || n != 5 {
+		t.Errorf("Got %d, %v, expected a missing entry to be created with the delta", n, err)
+	}
+	if n, err := c.Increment("A", -2); err != nil || n != 3 {
+		t.Errorf("Got %d, %v, expected 3", n, err)
+	}
+	if value, ok := c.Get("A"); !ok || value != 3 {
+		t.Errorf("Got %d, %t, expected 3", value, ok)
+	}
+}
+
+func TestIncrementShared(t *testing.T) {
+	s := newFakeServer()
+	a := New(s.dial(t), time.Minute, Options[interface{}, interface{}]{})
+	b := New(s.dial(t), time.Hour, Options[interface{}, interface{}]{})
+
+	// the increments of caches sharing the server add up, and keep the expiration of the entry
+	if _, err := a.Increment("A", 1); err != nil {
+		t.Fatalf("Got %v, expected success", err)
+	}
+	s.advance(30 * time.Second)
+	if n, err := b.Increment("A", 2); err != nil || n != 3 {
+		t.Errorf("Got %d, %v, expected the increments of both caches to add up", n, err)
+	}
+	if value, ok := a.Get("A"); !ok || value != int64(3) {
+		t.Errorf("Got %v, %t, expected 3", value, ok)
+	}
+	s.advance(30 * time.Second)
+	if _, ok := b.Get("A"); ok {
+		t.Error("Got an entry for A, expected it to have expired a minute after its creation")
+	}
+
+	var errs []error
+	c := New(s.dial(t), time.Minute, Options[interface{}, interface{}]{OnError: func(err error) { errs = append(errs, err) }})
+	c.Set("B", "b")
+	if n, err := c.Increment("B", 1); err == nil || n != 0 || len(errs) != 0 {
+		t.Errorf("Got %d, %v, %v, expected the value which isn't an integer to be returned as an error", n, err, errs)
+	}
+}
+
+func TestRemoveIf(t *testing.T) {
+	s := newFakeServer()
+	c := NewTyped[string, int](s.dial(t), time.Minute, Options[string, int]{})
+	c.SetAll(map[string]int{"A": 1, "B": 2, "C": 3})
+
+	if n := c.RemoveIf(func(key string, value int) bool { return value >= 2 }); n != 2 {
+		t.Errorf("Got %d entries removed, expected 2", n)
+	}
+	if snapshot := c.Snapshot(); len(snapshot) != 1 || snapshot["A"] != 1 {
+		t.Errorf("Got snapshot %v, expecting only A to be left", snapshot)
+	}
+	if st := c.Stats(); st.Removals != 2 {
+		t.Errorf("Got %d removals, expected 2", st.Removals)
+	}
+
+	// untyped keys can't be decoded by default, so nothing matches
+	u := New(s.dial(t), time.Minute, Options[interface{}, interface{}]{})
+	u.Set("D", 4)
+	if n := u.RemoveIf(func(key, value interface{}) bool { return true }); n != 0 {
+		t.Errorf("Got %d entries removed, expected 0", n)
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := newFakeServer()
+	c := New(s.dial(t), time.Minute, Options[interface{}, interface{}]{})
+
+	c.Set("A", "a")
+	c.SetWithExpiration("B", "b", time.Millisecond)
+	c.Get("A")
+	c.Get("Z")
+	s.advance(time.Second)
+	c.Remove("A")
+
+	expected := cache.Stats{Writes: 2, Hits: 1, Misses: 1, Evictions: 1, Expirations: 1, Removals: 1}
+	if st := c.Stats(); st != expected {
+		t.Errorf("Got stats of %v, expected %v", st, expected)
+	}
+}
+
+func TestStatsDelta(t *testing.T) {
+	s := newFakeServer()
+	c := New(s.dial(t), time.Minute, Options[interface{}, interface{}]{})
+
+	c.Set("A", "a")
+	c.Get("A")
+	if d := c.StatsDelta(); d != (cache.Stats{Writes: 1, Hits: 1}) {
+		t.Errorf("Got %+v, expected 1 write and 1 hit", d)
+	}
+	c.Get("Z")
+	if d := c.StatsDelta(); d != (cache.Stats{Misses: 1}) {
+		t.Errorf("Got %+v, expected 1 miss", d)
+	}
+
+	// the server's counters go on, but are reported from the reset
+	c.ResetStats()
+	c.Get("A")
+	if st := c.Stats(); st != (cache.Stats{Hits: 1}) {
+		t.Errorf("Got %+v, expected 1 hit since the reset", st)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	conn := newFakeServer().dial(t)
+
+	var errs []error
+	c := New(conn, time.Minute, Options[interface{}, interface{}]{
+		OnError: func(err error) { errs = append(errs, err) },
+		Marshal: func(value interface{}) ([]byte, error) {
+			if value == "bad" {
+				return nil, errors.New("unable to marshal")
+			}
+			return []byte{}, nil
+		},
+		Unmarshal: func(data []byte) (interface{}, error) { return nil, nil },
+	})
+
+	c.Set("A", "bad")
+	if len(errs) != 1 {
+		t.Errorf("Got %d errors, expecting the marshaling error to be reported", len(errs))
+	}
+
+	// failing to talk to the server is reported, and reads are misses
+	_ = conn.Close()
+	c.Set("A", "a")
+	if _, ok := c.Get("A"); ok {
+		t.Error("Got an entry, expecting a miss")
+	}
+	c.Stats()
+	if len(errs) != 4 {
+		t.Errorf("Got %d errors, expecting 4: %v", len(errs), errs)
+	}
+}
+
+func TestGetOrLoad(t *testing.T) {
+	c := New(newFakeServer().dial(t), time.Minute, Options[interface{}, interface{}]{})
+
+	loads := 0
+	loader := func() (interface{}, error) {
+		loads++
+		return "a", nil
+	}
+	for i := 0; i < 3; i++ {
+		if v, err := c.GetOrLoad("A", loader); err != nil || v != "a" {
+			t.Errorf("Got %v, %v, expecting a", v, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("Got %d loads, expecting the loaded value to be cached", loads)
+	}
+}
+
+func TestGetOrLoadCtx(t *testing.T) {
+	c := New(newFakeServer().dial(t), time.Minute, Options[interface{}, interface{}]{})
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "a")
+	v, err := c.GetOrLoadCtx(ctx, "A", func(ctx context.Context) (interface{}, error) {
+		return ctx.Value(key{}), nil
+	})
+	if err != nil || v != "a" {
+		t.Errorf("Got %v, %v, expecting a", v, err)
+	}
+	if v, ok := c.Get("A"); !ok || v != "a" {
+		t.Errorf("Got %v, %v, expecting the loaded value to be cached", v, ok)
+	}
+}
+
+func TestReadFrom(t *testing.T) {
+	lru := cache.NewLRU(time.Minute, 0, 10)
+	lru.Set("A", "a")
+	var buf bytes.Buffer
+	if _, err := lru.WriteTo(&buf); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+
+	c := New(newFakeServer().dial(t), time.Minute, Options[interface{}, interface{}]{})
+	if _, err := c.ReadFrom(&buf); err != nil {
+		t.Fatalf("Got error %v, expecting success", err)
+	}
+	if v, ok := c.Get("A"); !ok || v != "a" {
+		t.Errorf("Got %v, %v, expecting the entry to be moved to the server", v, ok)
+	}
+
+	// the entries live in the server, so there is nothing to hand off
+	buf.Reset()
+	if n, err := c.WriteTo(&buf); err != nil || n != 0 {
+		t.Errorf("Got %d, %v, expecting nothing to be written", n, err)
+	}
+}
+
+func TestLockKey(t *testing.T) {
+	c := NewTyped[string, int](newFakeServer().dial(t), time.Minute, Options[string, int]{})
+
+	done := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func() {
+			unlock := c.LockKey("A")
+			value, _ := c.Get("A")
+			c.Set("A", value+1)
+			unlock()
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+
+	if value, ok := c.Get("A"); !ok || value != 10 {
+		t.Errorf("Got %d, %t, expected 10", value, ok)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// refresher is the function registered with SetRefresher, along with how long before expiration
+// entries are refreshed.
+type refresher[K comparable, V any] struct {
+	refresh func(key K) (V, error)
+	ahead   int64 // nanoseconds
+}
+
+// refreshAhead implements SetRefresher for the caches. Caches call check whenever a lookup finds
+// an entry, which starts a refresh in the background if the entry is about to expire.
+type refreshAhead[K comparable, V any] struct {
+	refresher atomic.Pointer[refresher[K, V]]
+
+	// refreshing holds the keys with a refresh in flight
+	refreshMu  sync.Mutex
+	refreshing map[K]struct{}
+}
+
+func (r *refreshAhead[K, V]) SetRefresher(refresh func(key K) (V, error), ahead time.Duration) {
+	if refresh == nil {
+		r.refresher.Store(nil)
+		return
+	}
+	r.refresher.Store(&refresher[K, V]{refresh: refresh, ahead: ahead.Nanoseconds()})
+}
+
+// check starts a refresh of key, an entry expiring at exp, if it expires within the time registered
+// with SetRefresher of now and no refresh of key is in flight already. The fresh value is stored with
+// set. This may be called while holding the cache's lock.
+func (r *refreshAhead[K, V]) check(key K, exp int64, now int64, set func(key K, value V)) {
+	rf := r.refresher.Load()
+	if rf == nil || exp-now > rf.ahead {
+		return
+	}
+
+	r.refreshMu.Lock()
+	if _, ok := r.refreshing[key]; ok {
+		r.refreshMu.Unlock()
+		return
+	}
+	if r.refreshing == nil {
+		r.refreshing = make(map[K]struct{})
+	}
+	r.refreshing[key] = struct{}{}
+	r.refreshMu.Unlock()
+
+	go func() {
+		defer func() {
+			r.refreshMu.Lock()
+			delete(r.refreshing, key)
+			r.refreshMu.Unlock()
+		}()
+
+		// on failure the entry is left alone, and a later lookup tries again
+		if value, err := rf.refresh(key); err == nil {
+			set(key, value)
+		}
+	}()
+}
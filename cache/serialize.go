@@ -0,0 +1,89 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// serializedEntry is the gob encoding of an entry written by WriteEntries.
+type serializedEntry[K comparable, V any] struct {
+	Key   K
+	Value V
+	TTL   time.Duration
+}
+
+// WriteEntries implements Expiring.WriteTo on behalf of a cache, writing the entries visited by
+// forEach to w as a stream of gob values. The entries are collected before being encoded, such
+// that a slow writer doesn't hold up the cache.
+func WriteEntries[K comparable, V any](w io.Writer, forEach func(f func(key K, value V, ttl time.Duration) bool)) (int64, error) {
+	var entries []serializedEntry[K, V]
+	forEach(func(key K, value V, ttl time.Duration) bool {
+		entries = append(entries, serializedEntry[K, V]{Key: key, Value: value, TTL: ttl})
+		return true
+	})
+
+	cw := &countingWriter{w: w}
+	enc := gob.NewEncoder(cw)
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return cw.n, fmt.Errorf("unable to encode cache entry %v: %v", entries[i].Key, err)
+		}
+	}
+	return cw.n, nil
+}
+
+// ReadEntries implements Expiring.ReadFrom on behalf of a cache, calling set for each of the
+// entries written to r by WriteEntries.
+func ReadEntries[K comparable, V any](r io.Reader, set func(key K, value V, ttl time.Duration)) (int64, error) {
+	cr := &countingReader{r: r}
+	dec := gob.NewDecoder(cr)
+	for {
+		var e serializedEntry[K, V]
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				return cr.n, nil
+			}
+			return cr.n, fmt.Errorf("unable to decode cache entry: %v", err)
+		}
+		set(e.Key, e.Value, e.TTL)
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,388 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// The sharded LRU cache spreads its entries over a number of independent LRU caches,
+// selected by a hash of the key. Each shard has its own lock, so operations on keys
+// which land in different shards don't contend with each other. The price is that the
+// LRU ordering is only maintained within each shard, so the entry displaced when a shard
+// is full isn't necessarily the least recently used entry of the whole cache.
+//
+// The shards don't run their own evicter goroutine, a single one drives the eviction of
+// all of them. The same finalizer trickery as for the LRU cache is used to stop it once
+// the cache is no longer referenced.
+
+// See use of SetFinalizer below for an explanation of this weird composition
+type shardedLRUWrapper[K comparable, V any] struct {
+	*shardedLRUCache[K, V]
+}
+
+type shardedLRUCache[K comparable, V any] struct {
+	shards            []*lruCache[K, V]
+	hash              func(key K) uint64
+	stopEvicter       chan bool
+	evicterTerminated sync.WaitGroup // used by unit tests to verify the finalizer ran
+	clockHolder
+}
+
+// keySeed is used to hash the keys of untyped sharded caches.
+var keySeed = maphash.MakeSeed()
+
+// hashKey is the hash function used for the keys of untyped sharded caches.
+func hashKey(key interface{}) uint64 {
+	switch k := key.(type) {
+	case string:
+		return maphash.String(keySeed, k)
+	case int:
+		return mix(uint64(k))
+	case int32:
+		return mix(uint64(k))
+	case int64:
+		return mix(uint64(k))
+	case uint32:
+		return mix(uint64(k))
+	case uint64:
+		return mix(k)
+	}
+	return maphash.String(keySeed, fmt.Sprintf("%T:%v", key, key))
+}
+
+// mix scrambles the bits of integer keys, such that keys following a pattern are spread over the shards.
+func mix(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// NewShardedLRU creates a new cache with an LRU and time-based eviction model, split into a number
+// of shards to reduce lock contention between goroutines.
+//
+// Cache eviction is done on a periodic basis. Individual cache entries are evicted
+// after their expiration time has passed. The periodic nature of eviction means that
+// cache entries tend to survive around (expirationTime + (evictionInterval / 2))
+//
+// In addition, maxEntries is divided evenly between the shards, and when the shard a new
+// item lands in is full, adding the item will displace the item of that shard that has been
+// referenced least recently.
+//
+// Keys of type string and of the common integer types are hashed directly, other keys are hashed
+// through their textual representation. Use NewTypedShardedLRU to supply a hash function when
+// keys are of another type.
+//
+// defaultExpiration specifies the default minimum amount of time a cached
+// entry remains in the cache before eviction. This value is used with the
+// Set function. Explicit per-entry expiration times can be set with the
+// SetWithExpiration function instead.
+//
+// evictionInterval specifies the frequency at which eviction activities take
+// place. This should likely be >= 1 second.
+func NewShardedLRU(defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32, shards int) ExpiringCache {
+	return NewTypedShardedLRU[interface{}, interface{}](defaultExpiration, evictionInterval, maxEntries, shards, hashKey)
+}
+
+// NewTypedShardedLRU creates a new cache with an LRU and time-based eviction model, split into a number
+// of shards selected with the given hash function, holding keys of type K and values of type V.
+// See also: NewShardedLRU.
+func NewTypedShardedLRU[K comparable, V any](defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32,
+	shards int, hash func(key K) uint64) Expiring[K, V] {
+	if shards < 1 {
+		shards = 1
+	}
+
+	perShard := (maxEntries + int32(shards) - 1) / int32(shards)
+	c := &shardedLRUCache[K, V]{
+		shards: make([]*lruCache[K, V], shards),
+		hash:   hash,
+	}
+	for i := range c.shards {
+		c.shards[i] = NewTypedLRU[K, V](defaultExpiration, 0, perShard).(*lruCache[K, V])
+	}
+
+	if evictionInterval > 0 {
+		c.stopEvicter = make(chan bool, 1)
+		c.evicterTerminated.Add(1)
+		go c.evicter(evictionInterval)
+
+		// We return a 'see-through' wrapper for the real object such that
+		// the finalizer can trigger on the wrapper. We can't set a finalizer
+		// on the main cache object because it would never fire, since the
+		// evicter goroutine is keeping it alive
+		result := &shardedLRUWrapper[K, V]{c}
+		runtime.SetFinalizer(result, func(w *shardedLRUWrapper[K, V]) {
+			w.stopEvicter <- true
+			w.evicterTerminated.Wait()
+		})
+		return result
+	}
+
+	return c
+}
+
+func (c *shardedLRUCache[K, V]) evicter(evictionInterval time.Duration) {
+	// Wake up once in a while and evict stale items
+	ticker := time.NewTicker(evictionInterval)
+	for {
+		select {
+		case <-ticker.C:
+			c.evictExpired(c.now())
+		case <-c.stopEvicter:
+			ticker.Stop()
+			c.evicterTerminated.Done() // record this for the sake of unit tests
+			return
+		}
+	}
+}
+
+func (c *shardedLRUCache[K, V]) evictExpired(t time.Time) {
+	for _, s := range c.shards {
+		s.evictExpired(t)
+	}
+}
+
+func (c *shardedLRUCache[K, V]) EvictExpired() {
+	c.evictExpired(c.now())
+}
+
+func (c *shardedLRUCache[K, V]) SetClock(clock Clock) {
+	c.setClock(clock)
+	for _, s := range c.shards {
+		s.SetClock(clock)
+	}
+}
+
+// shard returns the shard holding key.
+func (c *shardedLRUCache[K, V]) shard(key K) *lruCache[K, V] {
+	return c.shards[c.index(key)]
+}
+
+// index returns the index of the shard holding key.
+func (c *shardedLRUCache[K, V]) index(key K) int {
+	return int(c.hash(key) % uint64(len(c.shards)))
+}
+
+func (c *shardedLRUCache[K, V]) Set(key K, value V) {
+	c.shard(key).Set(key, value)
+}
+
+func (c *shardedLRUCache[K, V]) SetWithExpiration(key K, value V, expiration time.Duration) {
+	c.shard(key).SetWithExpiration(key, value, expiration)
+}
+
+func (c *shardedLRUCache[K, V]) SetAll(entries map[K]V) {
+	if len(c.shards) == 1 {
+		c.shards[0].SetAll(entries)
+		return
+	}
+
+	batches := make([]map[K]V, len(c.shards))
+	for key, value := range entries {
+		i := c.index(key)
+		if batches[i] == nil {
+			batches[i] = make(map[K]V)
+		}
+		batches[i][key] = value
+	}
+	for i, batch := range batches {
+		if batch != nil {
+			c.shards[i].SetAll(batch)
+		}
+	}
+}
+
+func (c *shardedLRUCache[K, V]) GetAll(keys []K) map[K]V {
+	if len(c.shards) == 1 {
+		return c.shards[0].GetAll(keys)
+	}
+
+	batches := make([][]K, len(c.shards))
+	for _, key := range keys {
+		i := c.index(key)
+		batches[i] = append(batches[i], key)
+	}
+
+	result := make(map[K]V, len(keys))
+	for i, batch := range batches {
+		if batch != nil {
+			for key, value := range c.shards[i].GetAll(batch) {
+				result[key] = value
+			}
+		}
+	}
+	return result
+}
+
+func (c *shardedLRUCache[K, V]) Get(key K) (V, bool) {
+	return c.shard(key).Get(key)
+}
+
+func (c *shardedLRUCache[K, V]) Increment(key K, delta int64) (int64, error) {
+	return c.shard(key).Increment(key, delta)
+}
+
+func (c *shardedLRUCache[K, V]) LockKey(key K) func() {
+	return c.shard(key).LockKey(key)
+}
+
+func (c *shardedLRUCache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	return c.shard(key).GetOrLoad(key, loader)
+}
+
+func (c *shardedLRUCache[K, V]) GetOrLoadCtx(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error) {
+	return c.shard(key).GetOrLoadCtx(ctx, key, loader)
+}
+
+func (c *shardedLRUCache[K, V]) Pin(key K) bool {
+	return c.shard(key).Pin(key)
+}
+
+func (c *shardedLRUCache[K, V]) Unpin(key K) {
+	c.shard(key).Unpin(key)
+}
+
+func (c *shardedLRUCache[K, V]) Remove(key K) {
+	c.shard(key).Remove(key)
+}
+
+func (c *shardedLRUCache[K, V]) RemoveAll() {
+	for _, s := range c.shards {
+		s.RemoveAll()
+	}
+}
+
+func (c *shardedLRUCache[K, V]) SetEvictionCallback(callback func(key K, value V, reason EvictionReason)) {
+	for _, s := range c.shards {
+		s.SetEvictionCallback(callback)
+	}
+}
+
+func (c *shardedLRUCache[K, V]) SetExpirationCallback(callback func(key K, value V)) {
+	for _, s := range c.shards {
+		s.SetExpirationCallback(callback)
+	}
+}
+
+func (c *shardedLRUCache[K, V]) SetRefresher(refresh func(key K) (V, error), ahead time.Duration) {
+	for _, s := range c.shards {
+		s.SetRefresher(refresh, ahead)
+	}
+}
+
+func (c *shardedLRUCache[K, V]) RemoveIf(predicate func(key K, value V) bool) int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.RemoveIf(predicate)
+	}
+	return n
+}
+
+func (c *shardedLRUCache[K, V]) Preload(entries map[K]V) {
+	c.SetAll(entries)
+}
+
+func (c *shardedLRUCache[K, V]) Snapshot() map[K]V {
+	result := make(map[K]V)
+	for _, s := range c.shards {
+		for key, value := range s.Snapshot() {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func (c *shardedLRUCache[K, V]) ForEach(f func(key K, value V) bool) {
+	more := true
+	for _, s := range c.shards {
+		s.ForEach(func(key K, value V) bool {
+			more = f(key, value)
+			return more
+		})
+		if !more {
+			return
+		}
+	}
+}
+
+// forEachTTL is like ForEach, also passing the time left before each entry expires to f.
+func (c *shardedLRUCache[K, V]) forEachTTL(f func(key K, value V, ttl time.Duration) bool) {
+	more := true
+	for _, s := range c.shards {
+		s.forEachTTL(func(key K, value V, ttl time.Duration) bool {
+			more = f(key, value, ttl)
+			return more
+		})
+		if !more {
+			return
+		}
+	}
+}
+
+func (c *shardedLRUCache[K, V]) WriteTo(w io.Writer) (int64, error) {
+	return WriteEntries[K, V](w, c.forEachTTL)
+}
+
+func (c *shardedLRUCache[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	return ReadEntries[K, V](r, c.SetWithExpiration)
+}
+
+func (c *shardedLRUCache[K, V]) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.Len()
+	}
+	return n
+}
+
+func (c *shardedLRUCache[K, V]) Stats() Stats {
+	return c.sumStats((*lruCache[K, V]).Stats)
+}
+
+func (c *shardedLRUCache[K, V]) StatsDelta() Stats {
+	return c.sumStats((*lruCache[K, V]).StatsDelta)
+}
+
+// sumStats adds up the stats returned by stats for each of the shards.
+func (c *shardedLRUCache[K, V]) sumStats(stats func(s *lruCache[K, V]) Stats) Stats {
+	var total Stats
+	for _, s := range c.shards {
+		st := stats(s)
+		total.Writes += st.Writes
+		total.Hits += st.Hits
+		total.Misses += st.Misses
+		total.Evictions += st.Evictions
+		total.Expirations += st.Expirations
+		total.CapacityEvictions += st.CapacityEvictions
+		total.Removals += st.Removals
+	}
+	return total
+}
+
+func (c *shardedLRUCache[K, V]) ResetStats() {
+	for _, s := range c.shards {
+		s.ResetStats()
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestShardedLRUBasic(t *testing.T) {
+	sharded := NewShardedLRU(5*time.Minute, 1*time.Millisecond, 500, 16)
+	testCacheBasic(sharded, t)
+}
+
+func TestShardedLRUConcurrent(t *testing.T) {
+	sharded := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	testCacheConcurrent(sharded, t)
+}
+
+func TestShardedLRUExpiration(t *testing.T) {
+	sharded := NewShardedLRU(5*time.Second, 0, 500, 16).(*shardedLRUCache[interface{}, interface{}])
+	testCacheExpiration(sharded, sharded.evictExpired, t)
+}
+
+func TestShardedLRUEvicter(t *testing.T) {
+	sharded := NewShardedLRU(5*time.Second, 1*time.Millisecond, 500, 16)
+	testCacheEvicter(sharded)
+}
+
+func TestShardedLRUEvictExpired(t *testing.T) {
+	sharded := NewShardedLRU(5*time.Second, 0, 500, 16).(*shardedLRUCache[interface{}, interface{}])
+	testCacheEvictExpired(sharded, t)
+}
+
+func TestShardedLRUSetEvictionCallback(t *testing.T) {
+	sharded := NewShardedLRU(5*time.Second, 0, 500, 16).(*shardedLRUCache[interface{}, interface{}])
+	testCacheEvictionCallback(sharded, sharded.evictExpired, t)
+
+	testCacheCapacityCallback(NewShardedLRU(5*time.Second, 0, 1, 1), t)
+}
+
+func TestShardedLRUSetExpirationCallback(t *testing.T) {
+	sharded := NewShardedLRU(5*time.Second, 0, 500, 16).(*shardedLRUCache[interface{}, interface{}])
+	testCacheExpirationCallback(sharded, sharded.evictExpired, t)
+}
+
+func TestShardedLRUPin(t *testing.T) {
+	testCachePin(NewShardedLRU(5*time.Minute, 0, 2, 1), t)
+}
+
+func TestShardedLRUGetOrLoad(t *testing.T) {
+	testCacheGetOrLoad(NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16), t)
+	testCacheGetOrLoadCtx(NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16), t)
+}
+
+func TestShardedLRUBatch(t *testing.T) {
+	testCacheBatch(NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16), t)
+}
+
+func TestShardedLRUForEach(t *testing.T) {
+	testCacheForEach(NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16), t)
+}
+
+func TestShardedLRUIncrement(t *testing.T) {
+	testCacheIncrement(NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16), t)
+}
+
+func TestShardedLRULockKey(t *testing.T) {
+	testCacheLockKey(NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16), t)
+}
+
+func TestShardedLRUClock(t *testing.T) {
+	testCacheClock(NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16), t)
+}
+
+func TestShardedLRURefreshAhead(t *testing.T) {
+	testCacheRefreshAhead(NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16), t)
+}
+
+func TestShardedLRUWriteTo(t *testing.T) {
+	testCacheWriteTo(NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16), NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16), t)
+}
+
+func TestShardedLRURemoveIf(t *testing.T) {
+	testCacheRemoveIf(NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16), t)
+}
+
+func TestShardedLRUStatsDelta(t *testing.T) {
+	testCacheStatsDelta(NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16), t)
+}
+
+func TestShardedLRUPreloadSnapshot(t *testing.T) {
+	testCachePreloadSnapshot(NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16), t)
+}
+
+func TestShardedLRUFinalizer(t *testing.T) {
+	sharded := NewShardedLRU(5*time.Second, 1*time.Millisecond, 500, 16).(*shardedLRUWrapper[interface{}, interface{}])
+	testCacheFinalizer(&sharded.evicterTerminated)
+}
+
+func TestShardedLRUBehavior(t *testing.T) {
+	sharded := NewTypedShardedLRU[int, int](5*time.Minute, 0, 4, 2, func(key int) uint64 {
+		return uint64(key)
+	}).(*shardedLRUCache[int, int])
+
+	// each shard holds 2 entries, and displaces its own least recently used entry
+	sharded.Set(0, 0)
+	sharded.Set(2, 2)
+	sharded.Set(1, 1)
+	sharded.Get(0)
+	sharded.Set(4, 4)
+
+	if _, ok := sharded.Get(2); ok {
+		t.Error("Got an entry for 2, expecting it to have been displaced from its shard")
+	}
+	for _, key := range []int{0, 1, 4} {
+		if _, ok := sharded.Get(key); !ok {
+			t.Errorf("Got no entry for %d, expecting it to be present", key)
+		}
+	}
+
+	// stats are aggregated over all the shards
+	s := sharded.Stats()
+	if s.Writes != 4 || s.Hits != 4 || s.Misses != 1 {
+		t.Errorf("Got stats of %v, expected 4 writes, 4 hits and 1 miss", s)
+	}
+}
+
+func TestShardedLRUHashKey(t *testing.T) {
+	sharded := NewShardedLRU(5*time.Minute, 0, 1000, 8).(*shardedLRUCache[interface{}, interface{}])
+
+	type custom struct{ a, b int }
+	keys := []interface{}{"A", 1, int32(1), int64(1), uint32(1), uint64(1), custom{1, 2}, 1.5}
+	for _, key := range keys {
+		if hashKey(key) != hashKey(key) {
+			t.Errorf("Got different hashes for %v, expecting the hash to be stable", key)
+		}
+		sharded.Set(key, key)
+		if v, ok := sharded.Get(key); !ok || v != key {
+			t.Errorf("Got %v, %v for %v, expecting the entry to be present", v, ok, key)
+		}
+	}
+
+	// keys are spread over the shards
+	for i := 0; i < 1000; i++ {
+		sharded.Set(strconv.Itoa(i), i)
+	}
+	for i, s := range sharded.shards {
+		if len(s.lookup) == 0 {
+			t.Errorf("Got no entries in shard %d, expecting keys to be spread over all shards", i)
+		}
+	}
+}
+
+func BenchmarkShardedLRUGet(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	benchmarkCacheGet(c, b)
+}
+
+func BenchmarkShardedLRUGetConcurrent(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	benchmarkCacheGetConcurrent(c, b)
+}
+
+func BenchmarkShardedLRUSet(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	benchmarkCacheSet(c, b)
+}
+
+func BenchmarkShardedLRUSetConcurrent(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	benchmarkCacheSetConcurrent(c, b)
+}
+
+func BenchmarkShardedLRUGetSetConcurrent(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	benchmarkCacheGetSetConcurrent(c, b)
+}
+
+func BenchmarkShardedLRUSetRemove(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	benchmarkCacheSetRemove(c, b)
+}
@@ -0,0 +1,464 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// This is an LRU cache which bounds the total size of its entries rather than their number.
+// The size of each entry is computed by a caller-supplied function when the entry is set,
+// and entries are displaced from the tail of the LRU list until the new entry fits in the
+// budget. Since entries vary in size, they are individually allocated and linked with
+// container/list rather than preallocated like in the regular LRU cache.
+//
+// Just like for the LRU cache, entries also have an expiration time and are evicted
+// periodically once expired, and the same finalizer trickery is used to stop the
+// evicter goroutine once the cache is no longer referenced.
+
+// See use of SetFinalizer below for an explanation of this weird composition
+type sizedLRUWrapper[K comparable, V any] struct {
+	*sizedLRUCache[K, V]
+}
+
+type sizedLRUCache[K comparable, V any] struct {
+	sync.Mutex
+	// baseTimeNanos must be at start of struct to ensure 64bit alignment for atomics on
+	// 32bit architectures. See also: https://golang.org/pkg/sync/atomic/#pkg-note-BUG
+	baseTimeNanos     int64
+	maxEntries        int // no bound on the number of entries if <= 0
+	maxBytes          int64
+	bytes             int64 // total size of the resident entries
+	sizeOf            func(key K, value V) int64
+	entries           *list.List // most recently used at the front
+	lookup            map[K]*list.Element
+	stats             Stats
+	defaultExpiration time.Duration
+	stopEvicter       chan bool
+	evicterTerminated sync.WaitGroup // used by unit tests to verify the finalizer ran
+	evictionNotifier[K, V]
+	clockHolder
+	refreshAhead[K, V]
+	statsWindow
+	loads    LoadGroup[K, V]
+	keyLocks KeyLocks[K]
+}
+
+// sizedLRUEntry is the value held by the elements of the LRU list.
+type sizedLRUEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	size       int64
+	expiration int64 // nanoseconds
+}
+
+// NewSizedLRU creates a new cache with an LRU and time-based eviction model, bounded by the
+// total size of its entries.
+//
+// Cache eviction is done on a periodic basis. Individual cache entries are evicted
+// after their expiration time has passed. The periodic nature of eviction means that
+// cache entries tend to survive around (expirationTime + (evictionInterval / 2))
+//
+// In addition, sizeOf is called to compute the size of each entry as it is added, and
+// when the total size of the entries would exceed maxBytes, the items that have been
+// referenced least recently are displaced to make room. An entry larger than maxBytes
+// is never retained.
+//
+// defaultExpiration specifies the default minimum amount of time a cached
+// entry remains in the cache before eviction. This value is used with the
+// Set function. Explicit per-entry expiration times can be set with the
+// SetWithExpiration function instead.
+//
+// evictionInterval specifies the frequency at which eviction activities take
+// place. This should likely be >= 1 second.
+func NewSizedLRU(defaultExpiration time.Duration, evictionInterval time.Duration, maxBytes int64,
+	sizeOf func(key, value interface{}) int64) ExpiringCache {
+	return NewTypedSizedLRU[interface{}, interface{}](defaultExpiration, evictionInterval, maxBytes, sizeOf)
+}
+
+// NewTypedSizedLRU creates a new cache with an LRU and time-based eviction model, bounded by the
+// total size of its entries, holding keys of type K and values of type V. See also: NewSizedLRU.
+func NewTypedSizedLRU[K comparable, V any](defaultExpiration time.Duration, evictionInterval time.Duration, maxBytes int64,
+	sizeOf func(key K, value V) int64) Expiring[K, V] {
+	return NewTypedBoundedLRU[K, V](defaultExpiration, evictionInterval, 0, maxBytes, sizeOf)
+}
+
+// NewBoundedLRU creates a new cache with an LRU and time-based eviction model, bounded by both the
+// number of its entries and their total size.
+//
+// This behaves like a cache created by NewSizedLRU, except that the number of items is also limited
+// to maxEntries: when adding an item would exceed either maxEntries items or maxBytes in total, the
+// items that have been referenced least recently are displaced until both bounds are respected.
+// A maxEntries of zero or less leaves the number of items unbounded. See NewSizedLRU for a description
+// of the other parameters.
+func NewBoundedLRU(defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32, maxBytes int64,
+	sizeOf func(key, value interface{}) int64) ExpiringCache {
+	return NewTypedBoundedLRU[interface{}, interface{}](defaultExpiration, evictionInterval, maxEntries, maxBytes, sizeOf)
+}
+
+// NewTypedBoundedLRU creates a new cache with an LRU and time-based eviction model, bounded by both the
+// number of its entries and their total size, holding keys of type K and values of type V. See also: NewBoundedLRU.
+func NewTypedBoundedLRU[K comparable, V any](defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32,
+	maxBytes int64, sizeOf func(key K, value V) int64) Expiring[K, V] {
+	c := &sizedLRUCache[K, V]{
+		maxEntries:        int(maxEntries),
+		maxBytes:          maxBytes,
+		sizeOf:            sizeOf,
+		entries:           list.New(),
+		lookup:            make(map[K]*list.Element),
+		defaultExpiration: defaultExpiration,
+	}
+
+	c.baseTimeNanos = time.Now().UnixNano()
+	if evictionInterval > 0 {
+		c.stopEvicter = make(chan bool, 1)
+		c.evicterTerminated.Add(1)
+		go c.evicter(evictionInterval)
+
+		// We return a 'see-through' wrapper for the real object such that
+		// the finalizer can trigger on the wrapper. We can't set a finalizer
+		// on the main cache object because it would never fire, since the
+		// evicter goroutine is keeping it alive
+		result := &sizedLRUWrapper[K, V]{c}
+		runtime.SetFinalizer(result, func(w *sizedLRUWrapper[K, V]) {
+			w.stopEvicter <- true
+			w.evicterTerminated.Wait()
+		})
+		return result
+	}
+
+	return c
+}
+
+func (c *sizedLRUCache[K, V]) evicter(evictionInterval time.Duration) {
+	// Wake up once in a while and evict stale items
+	ticker := time.NewTicker(evictionInterval)
+	for {
+		select {
+		case <-ticker.C:
+			c.evictExpired(c.now())
+		case <-c.stopEvicter:
+			ticker.Stop()
+			c.evicterTerminated.Done() // record this for the sake of unit tests
+			return
+		}
+	}
+}
+
+func (c *sizedLRUCache[K, V]) evictExpired(t time.Time) {
+	// We snapshot a base time here such that the time doesn't need to be
+	// sampled in the Set call as calling time.Now() is relatively expensive.
+	// Doing it here provides enough precision for our needs and tends to have
+	// much lower call frequency.
+	n := t.UnixNano()
+	atomic.StoreInt64(&c.baseTimeNanos, n)
+
+	c.Lock()
+	for elem := c.entries.Front(); elem != nil; {
+		next := elem.Next()
+		if ent := elem.Value.(*sizedLRUEntry[K, V]); ent.expiration <= n {
+			c.evicted(ent.key, ent.value, ReasonExpired)
+			c.remove(elem)
+			c.stats.recordEviction(ReasonExpired)
+		}
+		elem = next
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *sizedLRUCache[K, V]) EvictExpired() {
+	c.evictExpired(c.now())
+}
+
+func (c *sizedLRUCache[K, V]) SetClock(clock Clock) {
+	c.setClock(clock)
+	atomic.StoreInt64(&c.baseTimeNanos, c.now().UnixNano())
+}
+
+func (c *sizedLRUCache[K, V]) Set(key K, value V) {
+	c.SetWithExpiration(key, value, c.defaultExpiration)
+}
+
+func (c *sizedLRUCache[K, V]) SetWithExpiration(key K, value V, expiration time.Duration) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + expiration.Nanoseconds()
+	size := c.sizeOf(key, value)
+
+	c.Lock()
+	c.setWithExpiration(key, value, size, exp)
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *sizedLRUCache[K, V]) SetAll(entries map[K]V) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + c.defaultExpiration.Nanoseconds()
+
+	c.Lock()
+	for key, value := range entries {
+		c.setWithExpiration(key, value, c.sizeOf(key, value), exp)
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *sizedLRUCache[K, V]) setWithExpiration(key K, value V, size int64, exp int64) {
+	c.stats.Writes++
+
+	elem, ok := c.lookup[key]
+	if ok {
+		// take the old value out of the budget while making room for the new one
+		c.bytes -= elem.Value.(*sizedLRUEntry[K, V]).size
+	}
+
+	if size > c.maxBytes {
+		// this entry will never fit, make sure we don't keep returning the old value
+		if ok {
+			ent := elem.Value.(*sizedLRUEntry[K, V])
+			c.evicted(ent.key, ent.value, ReasonCapacity)
+			c.entries.Remove(elem)
+			delete(c.lookup, key)
+			c.stats.recordEviction(ReasonCapacity)
+		}
+		return
+	}
+
+	for c.bytes+size > c.maxBytes || (!ok && c.maxEntries > 0 && c.entries.Len() >= c.maxEntries) {
+		victim := c.entries.Back()
+		if victim == elem {
+			victim = victim.Prev()
+		}
+		ent := victim.Value.(*sizedLRUEntry[K, V])
+		c.evicted(ent.key, ent.value, ReasonCapacity)
+		c.remove(victim)
+		c.stats.recordEviction(ReasonCapacity)
+	}
+
+	if ok {
+		ent := elem.Value.(*sizedLRUEntry[K, V])
+		ent.value = value
+		ent.size = size
+		ent.expiration = exp
+		c.entries.MoveToFront(elem)
+	} else {
+		c.lookup[key] = c.entries.PushFront(&sizedLRUEntry[K, V]{key: key, value: value, size: size, expiration: exp})
+	}
+	c.bytes += size
+}
+
+// remove forgets a resident entry.
+func (c *sizedLRUCache[K, V]) remove(elem *list.Element) {
+	ent := elem.Value.(*sizedLRUEntry[K, V])
+	c.entries.Remove(elem)
+	delete(c.lookup, ent.key)
+	c.bytes -= ent.size
+}
+
+func (c *sizedLRUCache[K, V]) Get(key K) (V, bool) {
+	c.Lock()
+	defer c.Unlock()
+	return c.get(key)
+}
+
+func (c *sizedLRUCache[K, V]) GetAll(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+
+	c.Lock()
+	for _, key := range keys {
+		if value, ok := c.get(key); ok {
+			result[key] = value
+		}
+	}
+	c.Unlock()
+
+	return result
+}
+
+func (c *sizedLRUCache[K, V]) get(key K) (V, bool) {
+	if elem, ok := c.lookup[key]; ok {
+		c.entries.MoveToFront(elem)
+		c.stats.Hits++
+		ent := elem.Value.(*sizedLRUEntry[K, V])
+		c.check(key, ent.expiration, atomic.LoadInt64(&c.baseTimeNanos), c.Set)
+		return ent.value, true
+	}
+
+	c.stats.Misses++
+	var zero V
+	return zero, false
+}
+
+func (c *sizedLRUCache[K, V]) Increment(key K, delta int64) (int64, error) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + c.defaultExpiration.Nanoseconds()
+
+	c.Lock()
+	var value V
+	elem, ok := c.lookup[key]
+	if ok {
+		ent := elem.Value.(*sizedLRUEntry[K, V])
+		value, exp = ent.value, ent.expiration
+	}
+	value, n, err := IncrementValue(value, ok, delta)
+	if err != nil {
+		c.Unlock()
+		return 0, err
+	}
+	c.setWithExpiration(key, value, c.sizeOf(key, value), exp)
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+
+	return n, nil
+}
+
+func (c *sizedLRUCache[K, V]) LockKey(key K) func() {
+	return c.keyLocks.Lock(key)
+}
+
+func (c *sizedLRUCache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	return c.loads.GetOrLoad(c, key, loader)
+}
+
+func (c *sizedLRUCache[K, V]) GetOrLoadCtx(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error) {
+	return c.loads.GetOrLoadCtx(ctx, c, key, loader)
+}
+
+func (c *sizedLRUCache[K, V]) Remove(key K) {
+	c.Lock()
+
+	if elem, ok := c.lookup[key]; ok {
+		ent := elem.Value.(*sizedLRUEntry[K, V])
+		c.evicted(ent.key, ent.value, ReasonRemoved)
+		c.remove(elem)
+		c.stats.Removals++
+	}
+
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *sizedLRUCache[K, V]) RemoveAll() {
+	c.Lock()
+
+	c.stats.Removals += uint64(c.entries.Len())
+	for elem := c.entries.Front(); elem != nil; elem = elem.Next() {
+		ent := elem.Value.(*sizedLRUEntry[K, V])
+		c.evicted(ent.key, ent.value, ReasonRemoved)
+	}
+	c.entries.Init()
+	c.lookup = make(map[K]*list.Element)
+	c.bytes = 0
+
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *sizedLRUCache[K, V]) RemoveIf(predicate func(key K, value V) bool) int {
+	n := 0
+
+	c.Lock()
+	for elem := c.entries.Front(); elem != nil; {
+		next := elem.Next()
+		if ent := elem.Value.(*sizedLRUEntry[K, V]); predicate(ent.key, ent.value) {
+			c.evicted(ent.key, ent.value, ReasonRemoved)
+			c.remove(elem)
+			c.stats.Removals++
+			n++
+		}
+		elem = next
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+
+	return n
+}
+
+func (c *sizedLRUCache[K, V]) Preload(entries map[K]V) {
+	c.SetAll(entries)
+}
+
+func (c *sizedLRUCache[K, V]) Snapshot() map[K]V {
+	c.Lock()
+	defer c.Unlock()
+
+	result := make(map[K]V, c.entries.Len())
+	for elem := c.entries.Front(); elem != nil; elem = elem.Next() {
+		ent := elem.Value.(*sizedLRUEntry[K, V])
+		result[ent.key] = ent.value
+	}
+	return result
+}
+
+func (c *sizedLRUCache[K, V]) ForEach(f func(key K, value V) bool) {
+	c.forEachTTL(func(key K, value V, _ time.Duration) bool {
+		return f(key, value)
+	})
+}
+
+// forEachTTL is like ForEach, also passing the time left before each entry expires to f.
+func (c *sizedLRUCache[K, V]) forEachTTL(f func(key K, value V, ttl time.Duration) bool) {
+	now := c.now().UnixNano()
+
+	c.Lock()
+	defer c.Unlock()
+
+	for elem := c.entries.Front(); elem != nil; elem = elem.Next() {
+		if ent := elem.Value.(*sizedLRUEntry[K, V]); ent.expiration > now && !f(ent.key, ent.value, time.Duration(ent.expiration-now)) {
+			return
+		}
+	}
+}
+
+func (c *sizedLRUCache[K, V]) WriteTo(w io.Writer) (int64, error) {
+	return WriteEntries[K, V](w, c.forEachTTL)
+}
+
+func (c *sizedLRUCache[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	return ReadEntries[K, V](r, c.SetWithExpiration)
+}
+
+func (c *sizedLRUCache[K, V]) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.entries.Len()
+}
+
+func (c *sizedLRUCache[K, V]) Stats() Stats {
+	c.Lock()
+	defer c.Unlock()
+	return c.stats
+}
+
+func (c *sizedLRUCache[K, V]) StatsDelta() Stats {
+	return c.delta(c.Stats())
+}
+
+func (c *sizedLRUCache[K, V]) ResetStats() {
+	c.Lock()
+	c.stats = Stats{}
+	c.Unlock()
+	c.reset()
+}
@@ -0,0 +1,241 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// unitSize counts every entry as a single byte, making the budget an entry count.
+func unitSize(key, value interface{}) int64 {
+	return 1
+}
+
+func TestSizedLRUBasic(t *testing.T) {
+	sized := NewSizedLRU(5*time.Minute, 1*time.Millisecond, 500, unitSize)
+	testCacheBasic(sized, t)
+}
+
+func TestSizedLRUConcurrent(t *testing.T) {
+	sized := NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize)
+	testCacheConcurrent(sized, t)
+}
+
+func TestSizedLRUExpiration(t *testing.T) {
+	sized := NewSizedLRU(5*time.Second, 0, 500, unitSize).(*sizedLRUCache[interface{}, interface{}])
+	testCacheExpiration(sized, sized.evictExpired, t)
+}
+
+func TestSizedLRUEvicter(t *testing.T) {
+	sized := NewSizedLRU(5*time.Second, 1*time.Millisecond, 500, unitSize)
+	testCacheEvicter(sized)
+}
+
+func TestSizedLRUEvictExpired(t *testing.T) {
+	sized := NewSizedLRU(5*time.Second, 0, 500, unitSize).(*sizedLRUCache[interface{}, interface{}])
+	testCacheEvictExpired(sized, t)
+}
+
+func TestSizedLRUSetEvictionCallback(t *testing.T) {
+	sized := NewSizedLRU(5*time.Second, 0, 500, unitSize).(*sizedLRUCache[interface{}, interface{}])
+	testCacheEvictionCallback(sized, sized.evictExpired, t)
+
+	testCacheCapacityCallback(NewSizedLRU(5*time.Second, 0, 1, unitSize), t)
+}
+
+func TestSizedLRUSetExpirationCallback(t *testing.T) {
+	sized := NewSizedLRU(5*time.Second, 0, 500, unitSize).(*sizedLRUCache[interface{}, interface{}])
+	testCacheExpirationCallback(sized, sized.evictExpired, t)
+}
+
+func TestSizedLRUGetOrLoad(t *testing.T) {
+	testCacheGetOrLoad(NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize), t)
+	testCacheGetOrLoadCtx(NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize), t)
+}
+
+func TestSizedLRUBatch(t *testing.T) {
+	testCacheBatch(NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize), t)
+}
+
+func TestSizedLRUForEach(t *testing.T) {
+	testCacheForEach(NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize), t)
+}
+
+func TestSizedLRUIncrement(t *testing.T) {
+	testCacheIncrement(NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize), t)
+}
+
+func TestSizedLRULockKey(t *testing.T) {
+	testCacheLockKey(NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize), t)
+}
+
+func TestSizedLRUClock(t *testing.T) {
+	testCacheClock(NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize), t)
+}
+
+func TestSizedLRURefreshAhead(t *testing.T) {
+	testCacheRefreshAhead(NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize), t)
+}
+
+func TestSizedLRUWriteTo(t *testing.T) {
+	testCacheWriteTo(NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize), NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize), t)
+}
+
+func TestSizedLRURemoveIf(t *testing.T) {
+	testCacheRemoveIf(NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize), t)
+}
+
+func TestSizedLRUStatsDelta(t *testing.T) {
+	testCacheStatsDelta(NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize), t)
+}
+
+func TestSizedLRUPreloadSnapshot(t *testing.T) {
+	testCachePreloadSnapshot(NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize), t)
+}
+
+func TestSizedLRUFinalizer(t *testing.T) {
+	sized := NewSizedLRU(5*time.Second, 1*time.Millisecond, 500, unitSize).(*sizedLRUWrapper[interface{}, interface{}])
+	testCacheFinalizer(&sized.evicterTerminated)
+}
+
+func TestSizedLRUBehavior(t *testing.T) {
+	sized := NewTypedSizedLRU[string, string](5*time.Minute, 0, 10, func(key, value string) int64 {
+		return int64(len(value))
+	}).(*sizedLRUCache[string, string])
+
+	sized.Set("A", "aaaa")
+	sized.Set("B", "bbbb")
+	sized.Get("A")
+
+	// B is the least recently used entry and has to make room
+	sized.Set("C", "cccc")
+	if _, ok := sized.Get("B"); ok {
+		t.Error("Got an entry for B, expecting it to have been displaced")
+	}
+	if sized.bytes != 8 {
+		t.Errorf("Got %d bytes, expecting 8", sized.bytes)
+	}
+
+	// growing an entry displaces others, even when it is itself the least recently used
+	sized.Set("A", "aaaaaaaa")
+	if v, ok := sized.Get("A"); !ok || v != "aaaaaaaa" {
+		t.Errorf("Got %v, %v for A, expecting the updated value", v, ok)
+	}
+	if _, ok := sized.Get("C"); ok {
+		t.Error("Got an entry for C, expecting it to have been displaced")
+	}
+	if sized.bytes != 8 {
+		t.Errorf("Got %d bytes, expecting 8", sized.bytes)
+	}
+
+	sized.Set("D", "dd")
+	if sized.bytes != 10 {
+		t.Errorf("Got %d bytes, expecting 10", sized.bytes)
+	}
+
+	// an entry larger than the budget is never retained, and doesn't leave a stale value behind
+	sized.Set("A", strings.Repeat("a", 11))
+	if _, ok := sized.Get("A"); ok {
+		t.Error("Got an entry for A, expecting it to be too large to be retained")
+	}
+	if _, ok := sized.Get("D"); !ok {
+		t.Error("Got no entry for D, expecting it to be unaffected by the oversized entry")
+	}
+	if sized.bytes != 2 {
+		t.Errorf("Got %d bytes, expecting 2", sized.bytes)
+	}
+
+	sized.RemoveAll()
+	if sized.bytes != 0 {
+		t.Errorf("Got %d bytes, expecting 0", sized.bytes)
+	}
+}
+
+func TestBoundedLRUBasic(t *testing.T) {
+	bounded := NewBoundedLRU(5*time.Minute, 1*time.Millisecond, 500, 1<<20, unitSize)
+	testCacheBasic(bounded, t)
+}
+
+func TestBoundedLRUBehavior(t *testing.T) {
+	bounded := NewTypedBoundedLRU[string, string](5*time.Minute, 0, 3, 10, func(key, value string) int64 {
+		return int64(len(value))
+	}).(*sizedLRUCache[string, string])
+
+	// small entries are bounded by their number
+	bounded.Set("A", "a")
+	bounded.Set("B", "b")
+	bounded.Set("C", "c")
+	bounded.Get("A")
+	bounded.Set("D", "d")
+	if _, ok := bounded.Get("B"); ok {
+		t.Error("Got an entry for B, expecting it to have been displaced by the entry count")
+	}
+	if n := bounded.Len(); n != 3 {
+		t.Errorf("Got %d entries, expecting 3", n)
+	}
+
+	// updating an entry doesn't count as an additional entry
+	bounded.Set("A", "aa")
+	if n := bounded.Len(); n != 3 {
+		t.Errorf("Got %d entries, expecting 3", n)
+	}
+
+	// large entries are bounded by their total size
+	bounded.Set("E", "eeeeeeee")
+	if n := bounded.Len(); n != 2 {
+		t.Errorf("Got %d entries, expecting 2", n)
+	}
+	if _, ok := bounded.Get("A"); !ok {
+		t.Error("Got no entry for A, expecting the most recently used small entry to fit along with E")
+	}
+	if bounded.bytes != 10 {
+		t.Errorf("Got %d bytes, expecting 10", bounded.bytes)
+	}
+	if s := bounded.Stats(); s.Evictions != 3 {
+		t.Errorf("Got %d evictions, expecting 3", s.Evictions)
+	}
+}
+
+func BenchmarkSizedLRUGet(b *testing.B) {
+	c := NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize)
+	benchmarkCacheGet(c, b)
+}
+
+func BenchmarkSizedLRUGetConcurrent(b *testing.B) {
+	c := NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize)
+	benchmarkCacheGetConcurrent(c, b)
+}
+
+func BenchmarkSizedLRUSet(b *testing.B) {
+	c := NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize)
+	benchmarkCacheSet(c, b)
+}
+
+func BenchmarkSizedLRUSetConcurrent(b *testing.B) {
+	c := NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize)
+	benchmarkCacheSetConcurrent(c, b)
+}
+
+func BenchmarkSizedLRUGetSetConcurrent(b *testing.B) {
+	c := NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize)
+	benchmarkCacheGetSetConcurrent(c, b)
+}
+
+func BenchmarkSizedLRUSetRemove(b *testing.B) {
+	c := NewSizedLRU(5*time.Minute, 1*time.Minute, 500, unitSize)
+	benchmarkCacheSetRemove(c, b)
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"sync"
+)
+
+// Sub returns the difference between s and earlier stats of the same cache. Counters which went
+// down in the meantime, because the stats were reset, are reported from zero.
+func (s Stats) Sub(earlier Stats) Stats {
+	return Stats{
+		Writes:            sub(s.Writes, earlier.Writes),
+		Hits:              sub(s.Hits, earlier.Hits),
+		Misses:            sub(s.Misses, earlier.Misses),
+		Evictions:         sub(s.Evictions, earlier.Evictions),
+		Expirations:       sub(s.Expirations, earlier.Expirations),
+		CapacityEvictions: sub(s.CapacityEvictions, earlier.CapacityEvictions),
+		Removals:          sub(s.Removals, earlier.Removals),
+	}
+}
+
+// recordEviction counts an eviction for the given reason. The caller must hold the cache's lock.
+func (s *Stats) recordEviction(reason EvictionReason) {
+	s.Evictions++
+	switch reason {
+	case ReasonExpired:
+		s.Expirations++
+	case ReasonCapacity:
+		s.CapacityEvictions++
+	}
+}
+
+func sub(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return a - b
+}
+
+// statsWindow implements StatsDelta for the caches, remembering the stats returned by the last call.
+type statsWindow struct {
+	mu   sync.Mutex
+	last Stats
+}
+
+// delta returns the difference between the current stats of the cache and those of the last call.
+func (w *statsWindow) delta(current Stats) Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	d := current.Sub(w.last)
+	w.last = current
+	return d
+}
+
+// reset forgets the stats of the last call, once the stats of the cache have been reset.
+func (w *statsWindow) reset() {
+	w.mu.Lock()
+	w.last = Stats{}
+	w.mu.Unlock()
+}
@@ -0,0 +1,584 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// This is an implementation of the W-TinyLFU policy described in "TinyLFU: A Highly Efficient
+// Cache Admission Policy" by Einziger, Friedman and Manes.
+//
+// New entries go into a small LRU window, which absorbs bursts of accesses to recent keys.
+// Entries falling off the window compete for a place in the main space, which is a segmented
+// LRU: entries enter its probation segment, and move to its protected segment when accessed
+// again. When the main space is full, the entry falling off the window is only admitted if it
+// has been accessed more frequently than the entry it would displace from the probation
+// segment. The frequencies are estimated with a count-min sketch which is periodically aged,
+// so keys seen only once can't displace popular keys, and keys which used to be popular
+// eventually make room for new ones.
+//
+// Just like for the other caches, entries also have an expiration time and are evicted
+// periodically once expired, and the same finalizer trickery is used to stop the evicter
+// goroutine once the cache is no longer referenced.
+
+// See use of SetFinalizer below for an explanation of this weird composition
+type tinyLFUWrapper[K comparable, V any] struct {
+	*tinyLFUCache[K, V]
+}
+
+type tinyLFUCache[K comparable, V any] struct {
+	sync.Mutex
+	// baseTimeNanos must be at start of struct to ensure 64bit alignment for atomics on
+	// 32bit architectures. See also: https://golang.org/pkg/sync/atomic/#pkg-note-BUG
+	baseTimeNanos     int64
+	size              int
+	windowSize        int
+	protectedSize     int
+	window            *list.List
+	probation         *list.List
+	protected         *list.List
+	lookup            map[K]*list.Element
+	sketch            *countMinSketch
+	hash              func(key K) uint64
+	stats             Stats
+	defaultExpiration time.Duration
+	stopEvicter       chan bool
+	evicterTerminated sync.WaitGroup // used by unit tests to verify the finalizer ran
+	evictionNotifier[K, V]
+	clockHolder
+	refreshAhead[K, V]
+	statsWindow
+	loads    LoadGroup[K, V]
+	keyLocks KeyLocks[K]
+}
+
+// tinyLFUEntry is the value held by the elements of the TinyLFU lists.
+type tinyLFUEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	hash       uint64
+	expiration int64 // nanoseconds
+	list       *list.List
+}
+
+// NewTinyLFU creates a new cache with a frequency-based admission policy and time-based eviction model.
+//
+// Cache eviction is done on a periodic basis. Individual cache entries are evicted
+// after their expiration time has passed. The periodic nature of eviction means that
+// cache entries tend to survive around (expirationTime + (evictionInterval / 2))
+//
+// In addition, the cache holds up to maxEntries items. When the cache is full, a new item
+// only displaces an item that has been referenced least recently if the new item's key
+// has been referenced more frequently, such that a burst of keys referenced only once doesn't
+// flush the items that are referenced frequently. The frequencies are tracked in a compact
+// sketch rather than per key, and favor recent references.
+//
+// Keys of type string and of the common integer types are hashed directly, other keys are hashed
+// through their textual representation. Use NewTypedTinyLFU to supply a hash function when
+// keys are of another type.
+//
+// defaultExpiration specifies the default minimum amount of time a cached
+// entry remains in the cache before eviction. This value is used with the
+// Set function. Explicit per-entry expiration times can be set with the
+// SetWithExpiration function instead.
+//
+// evictionInterval specifies the frequency at which eviction activities take
+// place. This should likely be >= 1 second.
+func NewTinyLFU(defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32) ExpiringCache {
+	return NewTypedTinyLFU[interface{}, interface{}](defaultExpiration, evictionInterval, maxEntries, hashKey)
+}
+
+// NewTypedTinyLFU creates a new cache with a frequency-based admission policy and time-based eviction model,
+// tracking the frequency of keys with the given hash function, holding keys of type K and values of type V.
+// See also: NewTinyLFU.
+func NewTypedTinyLFU[K comparable, V any](defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32,
+	hash func(key K) uint64) Expiring[K, V] {
+	size := int(maxEntries)
+	if size < 1 {
+		size = 1
+	}
+
+	// the window gets 1% of the capacity, and the protected segment 80% of the rest
+	windowSize := size / 100
+	if windowSize < 1 {
+		windowSize = 1
+	}
+
+	c := &tinyLFUCache[K, V]{
+		size:              size,
+		windowSize:        windowSize,
+		protectedSize:     (size - windowSize) * 4 / 5,
+		window:            list.New(),
+		probation:         list.New(),
+		protected:         list.New(),
+		lookup:            make(map[K]*list.Element, size),
+		sketch:            newCountMinSketch(size),
+		hash:              hash,
+		defaultExpiration: defaultExpiration,
+	}
+
+	c.baseTimeNanos = time.Now().UnixNano()
+	if evictionInterval > 0 {
+		c.stopEvicter = make(chan bool, 1)
+		c.evicterTerminated.Add(1)
+		go c.evicter(evictionInterval)
+
+		// We return a 'see-through' wrapper for the real object such that
+		// the finalizer can trigger on the wrapper. We can't set a finalizer
+		// on the main cache object because it would never fire, since the
+		// evicter goroutine is keeping it alive
+		result := &tinyLFUWrapper[K, V]{c}
+		runtime.SetFinalizer(result, func(w *tinyLFUWrapper[K, V]) {
+			w.stopEvicter <- true
+			w.evicterTerminated.Wait()
+		})
+		return result
+	}
+
+	return c
+}
+
+func (c *tinyLFUCache[K, V]) evicter(evictionInterval time.Duration) {
+	// Wake up once in a while and evict stale items
+	ticker := time.NewTicker(evictionInterval)
+	for {
+		select {
+		case <-ticker.C:
+			c.evictExpired(c.now())
+		case <-c.stopEvicter:
+			ticker.Stop()
+			c.evicterTerminated.Done() // record this for the sake of unit tests
+			return
+		}
+	}
+}
+
+func (c *tinyLFUCache[K, V]) evictExpired(t time.Time) {
+	// We snapshot a base time here such that the time doesn't need to be
+	// sampled in the Set call as calling time.Now() is relatively expensive.
+	// Doing it here provides enough precision for our needs and tends to have
+	// much lower call frequency.
+	n := t.UnixNano()
+	atomic.StoreInt64(&c.baseTimeNanos, n)
+
+	c.Lock()
+	for _, l := range c.lists() {
+		for elem := l.Front(); elem != nil; {
+			next := elem.Next()
+			if ent := elem.Value.(*tinyLFUEntry[K, V]); ent.expiration <= n {
+				c.evicted(ent.key, ent.value, ReasonExpired)
+				c.remove(elem)
+				c.stats.recordEviction(ReasonExpired)
+			}
+			elem = next
+		}
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *tinyLFUCache[K, V]) EvictExpired() {
+	c.evictExpired(c.now())
+}
+
+func (c *tinyLFUCache[K, V]) SetClock(clock Clock) {
+	c.setClock(clock)
+	atomic.StoreInt64(&c.baseTimeNanos, c.now().UnixNano())
+}
+
+func (c *tinyLFUCache[K, V]) lists() []*list.List {
+	return []*list.List{c.window, c.probation, c.protected}
+}
+
+func (c *tinyLFUCache[K, V]) Set(key K, value V) {
+	c.SetWithExpiration(key, value, c.defaultExpiration)
+}
+
+func (c *tinyLFUCache[K, V]) SetWithExpiration(key K, value V, expiration time.Duration) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + expiration.Nanoseconds()
+
+	c.Lock()
+	c.setWithExpiration(key, value, exp)
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *tinyLFUCache[K, V]) SetAll(entries map[K]V) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + c.defaultExpiration.Nanoseconds()
+
+	c.Lock()
+	for key, value := range entries {
+		c.setWithExpiration(key, value, exp)
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *tinyLFUCache[K, V]) setWithExpiration(key K, value V, exp int64) {
+	c.stats.Writes++
+
+	if elem, ok := c.lookup[key]; ok {
+		ent := elem.Value.(*tinyLFUEntry[K, V])
+		c.sketch.increment(ent.hash)
+		ent.value = value
+		ent.expiration = exp
+		c.touch(elem)
+		return
+	}
+
+	h := c.hash(key)
+	c.sketch.increment(h)
+	ent := &tinyLFUEntry[K, V]{key: key, value: value, hash: h, expiration: exp, list: c.window}
+	c.lookup[key] = c.window.PushFront(ent)
+
+	if c.window.Len() > c.windowSize {
+		c.admit(c.window.Back())
+	}
+}
+
+// admit moves the entry falling off the window to the main space, if it's worth more than the
+// entry it would displace from there.
+func (c *tinyLFUCache[K, V]) admit(candidate *list.Element) {
+	if c.probation.Len()+c.protected.Len() < c.size-c.windowSize {
+		c.move(candidate, c.probation)
+		return
+	}
+
+	victim := c.probation.Back()
+	if victim == nil {
+		victim = c.protected.Back()
+	}
+
+	if victim != nil && c.frequency(candidate) > c.frequency(victim) {
+		c.displace(victim)
+		c.move(candidate, c.probation)
+	} else {
+		c.displace(candidate)
+	}
+}
+
+func (c *tinyLFUCache[K, V]) frequency(elem *list.Element) int {
+	return c.sketch.estimate(elem.Value.(*tinyLFUEntry[K, V]).hash)
+}
+
+// displace evicts an entry to make room for another one.
+func (c *tinyLFUCache[K, V]) displace(elem *list.Element) {
+	ent := elem.Value.(*tinyLFUEntry[K, V])
+	c.evicted(ent.key, ent.value, ReasonCapacity)
+	c.remove(elem)
+	c.stats.recordEviction(ReasonCapacity)
+}
+
+// touch records a reference to a resident entry.
+func (c *tinyLFUCache[K, V]) touch(elem *list.Element) {
+	ent := elem.Value.(*tinyLFUEntry[K, V])
+	if ent.list != c.probation {
+		ent.list.MoveToFront(elem)
+		return
+	}
+
+	// an entry referenced again while on probation is protected, making room in the protected
+	// segment by putting its least recently used entry back on probation
+	c.move(elem, c.protected)
+	if c.protected.Len() > c.protectedSize {
+		c.move(c.protected.Back(), c.probation)
+	}
+}
+
+// move unlinks elem from its current list and links it at the front of l.
+func (c *tinyLFUCache[K, V]) move(elem *list.Element, l *list.List) {
+	ent := elem.Value.(*tinyLFUEntry[K, V])
+	ent.list.Remove(elem)
+	ent.list = l
+	c.lookup[ent.key] = l.PushFront(ent)
+}
+
+// remove forgets an entry entirely.
+func (c *tinyLFUCache[K, V]) remove(elem *list.Element) {
+	ent := elem.Value.(*tinyLFUEntry[K, V])
+	ent.list.Remove(elem)
+	delete(c.lookup, ent.key)
+}
+
+func (c *tinyLFUCache[K, V]) Get(key K) (V, bool) {
+	c.Lock()
+	defer c.Unlock()
+	return c.get(key)
+}
+
+func (c *tinyLFUCache[K, V]) GetAll(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+
+	c.Lock()
+	for _, key := range keys {
+		if value, ok := c.get(key); ok {
+			result[key] = value
+		}
+	}
+	c.Unlock()
+
+	return result
+}
+
+func (c *tinyLFUCache[K, V]) get(key K) (V, bool) {
+	if elem, ok := c.lookup[key]; ok {
+		ent := elem.Value.(*tinyLFUEntry[K, V])
+		c.sketch.increment(ent.hash)
+		c.touch(elem)
+		c.stats.Hits++
+		c.check(key, ent.expiration, atomic.LoadInt64(&c.baseTimeNanos), c.Set)
+		return ent.value, true
+	}
+
+	// misses count too, such that keys which keep being asked for get admitted once set
+	c.sketch.increment(c.hash(key))
+	c.stats.Misses++
+	var zero V
+	return zero, false
+}
+
+func (c *tinyLFUCache[K, V]) Increment(key K, delta int64) (int64, error) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + c.defaultExpiration.Nanoseconds()
+
+	c.Lock()
+	var value V
+	elem, ok := c.lookup[key]
+	if ok {
+		ent := elem.Value.(*tinyLFUEntry[K, V])
+		value, exp = ent.value, ent.expiration
+	}
+	value, n, err := IncrementValue(value, ok, delta)
+	if err != nil {
+		c.Unlock()
+		return 0, err
+	}
+	c.setWithExpiration(key, value, exp)
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+
+	return n, nil
+}
+
+func (c *tinyLFUCache[K, V]) LockKey(key K) func() {
+	return c.keyLocks.Lock(key)
+}
+
+func (c *tinyLFUCache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	return c.loads.GetOrLoad(c, key, loader)
+}
+
+func (c *tinyLFUCache[K, V]) GetOrLoadCtx(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error) {
+	return c.loads.GetOrLoadCtx(ctx, c, key, loader)
+}
+
+func (c *tinyLFUCache[K, V]) Remove(key K) {
+	c.Lock()
+
+	if elem, ok := c.lookup[key]; ok {
+		ent := elem.Value.(*tinyLFUEntry[K, V])
+		c.evicted(ent.key, ent.value, ReasonRemoved)
+		c.remove(elem)
+		c.stats.Removals++
+	}
+
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *tinyLFUCache[K, V]) RemoveAll() {
+	c.Lock()
+
+	c.stats.Removals += uint64(len(c.lookup))
+	for _, l := range c.lists() {
+		for elem := l.Front(); elem != nil; elem = elem.Next() {
+			ent := elem.Value.(*tinyLFUEntry[K, V])
+			c.evicted(ent.key, ent.value, ReasonRemoved)
+		}
+		l.Init()
+	}
+	c.lookup = make(map[K]*list.Element, c.size)
+	c.sketch = newCountMinSketch(c.size)
+
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *tinyLFUCache[K, V]) RemoveIf(predicate func(key K, value V) bool) int {
+	n := 0
+
+	c.Lock()
+	for _, l := range c.lists() {
+		for elem := l.Front(); elem != nil; {
+			next := elem.Next()
+			if ent := elem.Value.(*tinyLFUEntry[K, V]); predicate(ent.key, ent.value) {
+				c.evicted(ent.key, ent.value, ReasonRemoved)
+				c.remove(elem)
+				c.stats.Removals++
+				n++
+			}
+			elem = next
+		}
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+
+	return n
+}
+
+func (c *tinyLFUCache[K, V]) Preload(entries map[K]V) {
+	c.SetAll(entries)
+}
+
+func (c *tinyLFUCache[K, V]) Snapshot() map[K]V {
+	c.Lock()
+	defer c.Unlock()
+
+	result := make(map[K]V, len(c.lookup))
+	for _, elem := range c.lookup {
+		ent := elem.Value.(*tinyLFUEntry[K, V])
+		result[ent.key] = ent.value
+	}
+	return result
+}
+
+func (c *tinyLFUCache[K, V]) ForEach(f func(key K, value V) bool) {
+	c.forEachTTL(func(key K, value V, _ time.Duration) bool {
+		return f(key, value)
+	})
+}
+
+// forEachTTL is like ForEach, also passing the time left before each entry expires to f.
+func (c *tinyLFUCache[K, V]) forEachTTL(f func(key K, value V, ttl time.Duration) bool) {
+	now := c.now().UnixNano()
+
+	c.Lock()
+	defer c.Unlock()
+
+	for _, l := range c.lists() {
+		for elem := l.Front(); elem != nil; elem = elem.Next() {
+			if ent := elem.Value.(*tinyLFUEntry[K, V]); ent.expiration > now && !f(ent.key, ent.value, time.Duration(ent.expiration-now)) {
+				return
+			}
+		}
+	}
+}
+
+func (c *tinyLFUCache[K, V]) WriteTo(w io.Writer) (int64, error) {
+	return WriteEntries[K, V](w, c.forEachTTL)
+}
+
+func (c *tinyLFUCache[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	return ReadEntries[K, V](r, c.SetWithExpiration)
+}
+
+func (c *tinyLFUCache[K, V]) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.lookup)
+}
+
+func (c *tinyLFUCache[K, V]) Stats() Stats {
+	c.Lock()
+	defer c.Unlock()
+	return c.stats
+}
+
+func (c *tinyLFUCache[K, V]) StatsDelta() Stats {
+	return c.delta(c.Stats())
+}
+
+func (c *tinyLFUCache[K, V]) ResetStats() {
+	c.Lock()
+	c.stats = Stats{}
+	c.Unlock()
+	c.reset()
+}
+
+// countMinSketch estimates the frequency of keys from their hash, in a fixed amount of memory.
+//
+// Each row holds 4-bit counters, packed 16 to a word. A key maps to one counter per row, and its
+// frequency is estimated as the smallest of them, which is only ever overestimated by collisions.
+// Once enough increments have been recorded, all the counters are halved such that the estimates
+// favor recent history.
+type countMinSketch struct {
+	rows      [4][]uint64
+	mask      uint32 // number of counters per row, minus 1
+	additions int
+	resetAt   int
+}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	// 4 counters per entry of the cache keep collisions rare enough
+	width := 64
+	for width < 4*capacity {
+		width <<= 1
+	}
+
+	s := &countMinSketch{mask: uint32(width - 1), resetAt: 10 * capacity}
+	for i := range s.rows {
+		s.rows[i] = make([]uint64, width/16)
+	}
+	return s
+}
+
+// counter returns the word and shift of the counter of the given row for a hash.
+func (s *countMinSketch) counter(h uint64, row int) (int, uint) {
+	// derive the index in each row from two halves of the hash
+	i := (uint32(h) + uint32(row)*uint32(h>>32)) & s.mask
+	return int(i / 16), uint(i%16) * 4
+}
+
+func (s *countMinSketch) increment(h uint64) {
+	for row := range s.rows {
+		word, shift := s.counter(h, row)
+		if (s.rows[row][word]>>shift)&0xf < 0xf {
+			s.rows[row][word] += 1 << shift
+		}
+	}
+
+	if s.additions++; s.additions >= s.resetAt {
+		for _, r := range s.rows {
+			for i := range r {
+				r[i] = (r[i] >> 1) & 0x7777777777777777
+			}
+		}
+		s.additions /= 2
+	}
+}
+
+func (s *countMinSketch) estimate(h uint64) int {
+	lowest := 0xf
+	for row := range s.rows {
+		word, shift := s.counter(h, row)
+		if n := int((s.rows[row][word] >> shift) & 0xf); n < lowest {
+			lowest = n
+		}
+	}
+	return lowest
+}
@@ -0,0 +1,237 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTinyLFUBasic(t *testing.T) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Millisecond, 500)
+	testCacheBasic(c, t)
+}
+
+func TestTinyLFUConcurrent(t *testing.T) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	testCacheConcurrent(c, t)
+}
+
+func TestTinyLFUExpiration(t *testing.T) {
+	c := NewTinyLFU(5*time.Second, 0, 500).(*tinyLFUCache[interface{}, interface{}])
+	testCacheExpiration(c, c.evictExpired, t)
+}
+
+func TestTinyLFUEvicter(t *testing.T) {
+	c := NewTinyLFU(5*time.Second, 1*time.Millisecond, 500)
+	testCacheEvicter(c)
+}
+
+func TestTinyLFUEvictExpired(t *testing.T) {
+	c := NewTinyLFU(5*time.Second, 0, 500).(*tinyLFUCache[interface{}, interface{}])
+	testCacheEvictExpired(c, t)
+}
+
+func TestTinyLFUSetEvictionCallback(t *testing.T) {
+	c := NewTinyLFU(5*time.Second, 0, 500).(*tinyLFUCache[interface{}, interface{}])
+	testCacheEvictionCallback(c, c.evictExpired, t)
+
+	testCacheCapacityCallback(NewTinyLFU(5*time.Second, 0, 1), t)
+}
+
+func TestTinyLFUSetExpirationCallback(t *testing.T) {
+	c := NewTinyLFU(5*time.Second, 0, 500).(*tinyLFUCache[interface{}, interface{}])
+	testCacheExpirationCallback(c, c.evictExpired, t)
+}
+
+func TestTinyLFUGetOrLoad(t *testing.T) {
+	testCacheGetOrLoad(NewTinyLFU(5*time.Minute, 1*time.Minute, 500), t)
+	testCacheGetOrLoadCtx(NewTinyLFU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTinyLFUBatch(t *testing.T) {
+	testCacheBatch(NewTinyLFU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTinyLFUForEach(t *testing.T) {
+	testCacheForEach(NewTinyLFU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTinyLFUIncrement(t *testing.T) {
+	testCacheIncrement(NewTinyLFU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTinyLFULockKey(t *testing.T) {
+	testCacheLockKey(NewTinyLFU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTinyLFUClock(t *testing.T) {
+	testCacheClock(NewTinyLFU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTinyLFURefreshAhead(t *testing.T) {
+	testCacheRefreshAhead(NewTinyLFU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTinyLFUWriteTo(t *testing.T) {
+	testCacheWriteTo(NewTinyLFU(5*time.Minute, 1*time.Minute, 500), NewTinyLFU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTinyLFURemoveIf(t *testing.T) {
+	testCacheRemoveIf(NewTinyLFU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTinyLFUStatsDelta(t *testing.T) {
+	testCacheStatsDelta(NewTinyLFU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTinyLFUPreloadSnapshot(t *testing.T) {
+	testCachePreloadSnapshot(NewTinyLFU(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTinyLFUFinalizer(t *testing.T) {
+	c := NewTinyLFU(5*time.Second, 1*time.Millisecond, 500).(*tinyLFUWrapper[interface{}, interface{}])
+	testCacheFinalizer(&c.evicterTerminated)
+}
+
+func TestTinyLFUBehavior(t *testing.T) {
+	c := NewTypedTinyLFU[string, int](5*time.Minute, 0, 100, func(key string) uint64 { return hashKey(key) })
+
+	// make a few entries frequently used
+	for i := 0; i < 10; i++ {
+		c.Set("hot"+strconv.Itoa(i), i)
+	}
+	useHot := func() {
+		for i := 0; i < 10; i++ {
+			c.Get("hot" + strconv.Itoa(i))
+		}
+	}
+	for j := 0; j < 3; j++ {
+		useHot()
+	}
+
+	// a scan over many keys seen only once shouldn't displace them while they keep being used
+	for i := 0; i < 1000; i++ {
+		c.Set("scan"+strconv.Itoa(i), i)
+		if i%100 == 0 {
+			useHot()
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if _, ok := c.Get("hot" + strconv.Itoa(i)); !ok {
+			t.Errorf("Got no entry for hot%d, expecting it to survive the scan", i)
+		}
+	}
+	if _, ok := c.Get("scan999"); !ok {
+		t.Error("Got no entry for scan999, expecting the most recent entry to be present")
+	}
+
+	// the cache never holds more than its capacity
+	s := c.Stats()
+	resident := s.Writes - s.Evictions - s.Removals
+	if resident != 100 {
+		t.Errorf("Got %d resident entries, expecting 100", resident)
+	}
+	if n := c.Len(); n != 100 {
+		t.Errorf("Got %d entries, expecting 100", n)
+	}
+}
+
+func TestTinyLFUAdmission(t *testing.T) {
+	c := NewTypedTinyLFU[int, int](5*time.Minute, 0, 100, func(key int) uint64 { return hashKey(key) })
+
+	// fill the cache with entries referenced twice
+	for i := 0; i < 100; i++ {
+		c.Set(i, i)
+		c.Get(i)
+	}
+
+	// a new key referenced often enough gets admitted, displacing an older entry
+	for i := 0; i < 5; i++ {
+		c.Get(1000)
+	}
+	c.Set(1000, 1000)
+	c.Set(1001, 1001) // pushes 1000 out of the window
+	if _, ok := c.Get(1000); !ok {
+		t.Error("Got no entry for 1000, expecting it to be admitted")
+	}
+}
+
+func TestCountMinSketch(t *testing.T) {
+	s := newCountMinSketch(100)
+
+	for i := 0; i < 5; i++ {
+		s.increment(hashKey("A"))
+	}
+	s.increment(hashKey("B"))
+
+	if n := s.estimate(hashKey("A")); n != 5 {
+		t.Errorf("Got an estimate of %d for A, expected 5", n)
+	}
+	if n := s.estimate(hashKey("B")); n != 1 {
+		t.Errorf("Got an estimate of %d for B, expected 1", n)
+	}
+	if n := s.estimate(hashKey("C")); n != 0 {
+		t.Errorf("Got an estimate of %d for C, expected 0", n)
+	}
+
+	// counters saturate
+	for i := 0; i < 100; i++ {
+		s.increment(hashKey("A"))
+	}
+	if n := s.estimate(hashKey("A")); n != 15 {
+		t.Errorf("Got an estimate of %d for A, expected 15", n)
+	}
+
+	// and are halved as they age
+	for i := 0; s.additions < s.resetAt-1; i++ {
+		s.increment(hashKey(i))
+	}
+	s.increment(hashKey("D"))
+	if n := s.estimate(hashKey("A")); n != 7 {
+		t.Errorf("Got an estimate of %d for A, expected 7 after aging", n)
+	}
+}
+
+func BenchmarkTinyLFUGet(b *testing.B) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheGet(c, b)
+}
+
+func BenchmarkTinyLFUGetConcurrent(b *testing.B) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheGetConcurrent(c, b)
+}
+
+func BenchmarkTinyLFUSet(b *testing.B) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheSet(c, b)
+}
+
+func BenchmarkTinyLFUSetConcurrent(b *testing.B) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheSetConcurrent(c, b)
+}
+
+func BenchmarkTinyLFUGetSetConcurrent(b *testing.B) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheGetSetConcurrent(c, b)
+}
+
+func BenchmarkTinyLFUSetRemove(b *testing.B) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheSetRemove(c, b)
+}
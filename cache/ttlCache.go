@@ -15,6 +15,9 @@
 package cache
 
 import (
+	"context"
+	"io"
+	"math/rand"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -35,11 +38,11 @@ import (
 // ends well.
 
 // See use of SetFinalizer below for an explanation of this weird composition
-type ttlWrapper struct {
-	*ttlCache
+type ttlWrapper[K comparable, V any] struct {
+	*ttlCache[K, V]
 }
 
-type ttlCache struct {
+type ttlCache[K comparable, V any] struct {
 	// baseTimeNanos must be at start of struct to ensure 64bit alignment for atomics on
 	// 32bit architectures. See also: https://golang.org/pkg/sync/atomic/#pkg-note-BUG
 	baseTimeNanos     int64
@@ -48,13 +51,26 @@ type ttlCache struct {
 	defaultExpiration time.Duration
 	stopEvicter       chan bool
 	evicterTerminated sync.WaitGroup // used by unit tests to verify the finalizer ran
-	callback          EvictionCallback
+	callback          func(key K, value V)
+	sliding           bool  // whether Get extends the expiration of entries
+	maxStale          int64 // nanoseconds, how long expired entries are kept while being refreshed
+	refresh           func(key K) (V, error)
+	jitter            float64 // fraction of their expiration by which the expiration of entries is randomly extended
+	evictionNotifier[K, V]
+	clockHolder
+	refreshAhead[K, V]
+	statsWindow
+	loads    LoadGroup[K, V]
+	keyLocks KeyLocks[K]
 }
 
 // A single cache entry. This is the values we use in our storage map
-type entry struct {
-	value      interface{}
+type entry[V any] struct {
+	// expiration must be at start of struct to ensure 64bit alignment for atomics on 32bit architectures
 	expiration int64 // nanoseconds
+	window     int64 // nanoseconds, the expiration time of the entry relative to its last use
+	value      V
+	refreshing int32 // whether a refresh of a stale entry is in flight
 }
 
 // EvictionCallback is a function that will be called on entry eviction
@@ -90,11 +106,100 @@ func NewTTL(defaultExpiration time.Duration, evictionInterval time.Duration) Exp
 // NewTTLWithCallback creates a new cache with a time-based eviction model that will invoke the supplied
 // callback on all evictions. See also: NewTTL.
 func NewTTLWithCallback(defaultExpiration time.Duration, evictionInterval time.Duration, callback EvictionCallback) ExpiringCache {
-	c := &ttlCache{
+	return NewTypedTTLWithCallback[interface{}, interface{}](defaultExpiration, evictionInterval, callback)
+}
+
+// NewTypedTTL creates a new cache with a time-based eviction model, holding keys of type K and values of type V.
+// See also: NewTTL.
+func NewTypedTTL[K comparable, V any](defaultExpiration time.Duration, evictionInterval time.Duration) Expiring[K, V] {
+	return NewTypedTTLWithCallback[K, V](defaultExpiration, evictionInterval, func(key K, value V) {})
+}
+
+// NewTypedTTLWithCallback creates a new cache with a time-based eviction model, holding keys of type K and values
+// of type V, that will invoke the supplied callback on all evictions. See also: NewTTL.
+func NewTypedTTLWithCallback[K comparable, V any](defaultExpiration time.Duration, evictionInterval time.Duration,
+	callback func(key K, value V)) Expiring[K, V] {
+	return newTTL(&ttlCache[K, V]{defaultExpiration: defaultExpiration, callback: callback}, evictionInterval)
+}
+
+// NewSlidingTTL creates a new cache with a time-based eviction model where entries expire once
+// they haven't been used for a while, rather than a while after they were set.
+//
+// Every successful Get of an entry pushes its expiration time back by the expiration
+// duration the entry was set with, such that entries survive for as long as they keep being
+// used. This is suitable for session-like data. See NewTTL for a description of the parameters.
+func NewSlidingTTL(defaultExpiration time.Duration, evictionInterval time.Duration) ExpiringCache {
+	return NewTypedSlidingTTL[interface{}, interface{}](defaultExpiration, evictionInterval)
+}
+
+// NewTypedSlidingTTL creates a new cache with a sliding time-based eviction model, holding keys of type K and
+// values of type V. See also: NewSlidingTTL.
+func NewTypedSlidingTTL[K comparable, V any](defaultExpiration time.Duration, evictionInterval time.Duration) Expiring[K, V] {
+	c := &ttlCache[K, V]{
+		defaultExpiration: defaultExpiration,
+		callback:          func(key K, value V) {},
+		sliding:           true,
+	}
+	return newTTL(c, evictionInterval)
+}
+
+// NewRevalidatingTTL creates a new cache with a time-based eviction model where expired entries keep
+// being served for a while as they are refreshed in the background.
+//
+// When Get finds an entry past its expiration time, it returns the stale value right away and calls
+// refresh in a separate goroutine to produce a fresh value for the key, which then replaces the entry
+// with the default expiration. Only one refresh is in flight per entry. If refresh fails, the stale value
+// keeps being served and the next Get tries again, until the entry is evicted maxStale after its expiration.
+// This trades slightly stale values for the absence of latency spikes when popular entries expire.
+//
+// As for eviction, whether an entry is stale is judged against the time of the last eviction pass, so
+// evictionInterval should be small compared to defaultExpiration. See NewTTL for a description of the
+// other parameters.
+func NewRevalidatingTTL(defaultExpiration time.Duration, evictionInterval time.Duration, maxStale time.Duration,
+	refresh func(key interface{}) (interface{}, error)) ExpiringCache {
+	return NewTypedRevalidatingTTL[interface{}, interface{}](defaultExpiration, evictionInterval, maxStale, refresh)
+}
+
+// NewTypedRevalidatingTTL creates a new cache with a time-based eviction model serving stale entries while
+// they are refreshed, holding keys of type K and values of type V. See also: NewRevalidatingTTL.
+func NewTypedRevalidatingTTL[K comparable, V any](defaultExpiration time.Duration, evictionInterval time.Duration,
+	maxStale time.Duration, refresh func(key K) (V, error)) Expiring[K, V] {
+	c := &ttlCache[K, V]{
+		defaultExpiration: defaultExpiration,
+		callback:          func(key K, value V) {},
+		maxStale:          maxStale.Nanoseconds(),
+		refresh:           refresh,
+	}
+	return newTTL(c, evictionInterval)
+}
+
+// NewTTLWithJitter creates a new cache with a time-based eviction model where the expiration of each entry
+// is randomly extended by up to the given fraction of it, between 0 and 1. Entries set together then expire
+// over a band of time rather than all at once, which spreads the load of refetching them from the backing
+// store. See NewTTL for a description of the other parameters.
+func NewTTLWithJitter(defaultExpiration time.Duration, evictionInterval time.Duration, jitter float64) ExpiringCache {
+	return NewTypedTTLWithJitter[interface{}, interface{}](defaultExpiration, evictionInterval, jitter)
+}
+
+// NewTypedTTLWithJitter creates a new cache with a time-based eviction model randomizing the expiration of
+// entries, holding keys of type K and values of type V. See also: NewTTLWithJitter.
+func NewTypedTTLWithJitter[K comparable, V any](defaultExpiration time.Duration, evictionInterval time.Duration,
+	jitter float64) Expiring[K, V] {
+	if jitter < 0 {
+		jitter = 0
+	} else if jitter > 1 {
+		jitter = 1
+	}
+
+	c := &ttlCache[K, V]{
 		defaultExpiration: defaultExpiration,
-		callback:          callback,
+		callback:          func(key K, value V) {},
+		jitter:            jitter,
 	}
+	return newTTL(c, evictionInterval)
+}
 
+func newTTL[K comparable, V any](c *ttlCache[K, V], evictionInterval time.Duration) Expiring[K, V] {
 	c.baseTimeNanos = time.Now().UnixNano()
 	if evictionInterval > 0 {
 		c.stopEvicter = make(chan bool, 1)
@@ -105,8 +210,8 @@ func NewTTLWithCallback(defaultExpiration time.Duration, evictionInterval time.D
 		// the finalizer can trigger on the wrapper. We can't set a finalizer
 		// on the main cache object because it would never fire, since the
 		// evicter goroutine is keeping it alive
-		result := &ttlWrapper{c}
-		runtime.SetFinalizer(result, func(w *ttlWrapper) {
+		result := &ttlWrapper[K, V]{c}
+		runtime.SetFinalizer(result, func(w *ttlWrapper[K, V]) {
 			w.stopEvicter <- true
 			w.evicterTerminated.Wait()
 		})
@@ -116,13 +221,13 @@ func NewTTLWithCallback(defaultExpiration time.Duration, evictionInterval time.D
 	return c
 }
 
-func (c *ttlCache) evicter(evictionInterval time.Duration) {
+func (c *ttlCache[K, V]) evicter(evictionInterval time.Duration) {
 	// Wake up once in a while and evict stale items
 	ticker := time.NewTicker(evictionInterval)
 	for {
 		select {
-		case now := <-ticker.C:
-			c.evictExpired(now)
+		case <-ticker.C:
+			c.evictExpired(c.now())
 		case <-c.stopEvicter:
 			ticker.Stop()
 			c.evicterTerminated.Done() // record this for the sake of unit tests
@@ -131,7 +236,7 @@ func (c *ttlCache) evicter(evictionInterval time.Duration) {
 	}
 }
 
-func (c *ttlCache) evictExpired(t time.Time) {
+func (c *ttlCache[K, V]) evictExpired(t time.Time) {
 	// We snapshot a base time here such that the time doesn't need to be
 	// sampled in the Set call as calling time.Now() is relatively expensive.
 	// Doing it here provides enough precision for our needs and tends to have
@@ -150,40 +255,81 @@ func (c *ttlCache) evictExpired(t time.Time) {
 	// situation. So long as the cache never lies, it's OK if it spuriously
 	// forgets.
 	c.entries.Range(func(key interface{}, value interface{}) bool {
-		e := value.(*entry)
-		if e.expiration <= n {
+		e := value.(*entry[V])
+		if atomic.LoadInt64(&e.expiration)+c.maxStale <= n {
 			c.entries.Delete(key)
-			c.callback(key, value.(*entry).value)
+			k, _ := key.(K)
+			c.callback(k, e.value)
+			c.notifyOne(k, e.value, ReasonExpired)
 			// Note: can miscount if the key was removed before it was evicted
 			atomic.AddUint64(&c.stats.Evictions, 1)
+			atomic.AddUint64(&c.stats.Expirations, 1)
 		}
 		return true
 	})
 }
 
-func (c *ttlCache) EvictExpired() {
-	c.evictExpired(time.Now())
+func (c *ttlCache[K, V]) EvictExpired() {
+	c.evictExpired(c.now())
+}
+
+func (c *ttlCache[K, V]) SetClock(clock Clock) {
+	c.setClock(clock)
+	atomic.StoreInt64(&c.baseTimeNanos, c.now().UnixNano())
 }
 
-func (c *ttlCache) Set(key interface{}, value interface{}) {
+// expirationTime returns the time, in nanoseconds, at which an entry set now with the given expiration expires.
+func (c *ttlCache[K, V]) expirationTime(expiration time.Duration) int64 {
+	d := expiration.Nanoseconds()
+	if c.jitter > 0 && d > 0 {
+		d += rand.Int63n(int64(float64(d)*c.jitter) + 1)
+	}
+	return atomic.LoadInt64(&c.baseTimeNanos) + d
+}
+
+func (c *ttlCache[K, V]) Set(key K, value V) {
 	c.SetWithExpiration(key, value, c.defaultExpiration)
 }
 
-func (c *ttlCache) SetWithExpiration(key interface{}, value interface{}, expiration time.Duration) {
-	e := &entry{
+func (c *ttlCache[K, V]) SetWithExpiration(key K, value V, expiration time.Duration) {
+	e := &entry[V]{
 		value:      value,
-		expiration: atomic.LoadInt64(&c.baseTimeNanos) + expiration.Nanoseconds(),
+		expiration: c.expirationTime(expiration),
+		window:     expiration.Nanoseconds(),
 	}
 
 	c.entries.Store(key, e)
 	atomic.AddUint64(&c.stats.Writes, 1)
 }
 
-func (c *ttlCache) Get(key interface{}) (interface{}, bool) {
+func (c *ttlCache[K, V]) SetAll(entries map[K]V) {
+	// there's no lock to amortize, but the base time is only sampled once unless each entry gets its own jitter
+	exp := c.expirationTime(c.defaultExpiration)
+	for key, value := range entries {
+		if c.jitter > 0 {
+			exp = c.expirationTime(c.defaultExpiration)
+		}
+		c.entries.Store(key, &entry[V]{value: value, expiration: exp, window: c.defaultExpiration.Nanoseconds()})
+	}
+	atomic.AddUint64(&c.stats.Writes, uint64(len(entries)))
+}
+
+func (c *ttlCache[K, V]) GetAll(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+	for _, key := range keys {
+		if value, ok := c.Get(key); ok {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+func (c *ttlCache[K, V]) Get(key K) (V, bool) {
 	e, ok := c.entries.Load(key)
 	if !ok {
 		atomic.AddUint64(&c.stats.Misses, 1)
-		return nil, false
+		var zero V
+		return zero, false
 	}
 
 	// Note that we could check the current time here and discard the returned value
@@ -191,20 +337,103 @@ func (c *ttlCache) Get(key interface{}) (interface{}, bool) {
 	// time by > 50% (since time.Now is relatively expensive). Instead, we don't check time
 	// here and accept some imprecision in actual eviction times.
 
+	ent := e.(*entry[V])
+	if c.sliding {
+		atomic.StoreInt64(&ent.expiration, atomic.LoadInt64(&c.baseTimeNanos)+ent.window)
+	} else if c.refresh != nil && atomic.LoadInt64(&ent.expiration) <= atomic.LoadInt64(&c.baseTimeNanos) {
+		if atomic.CompareAndSwapInt32(&ent.refreshing, 0, 1) {
+			go c.revalidate(key, ent)
+		}
+	} else {
+		c.check(key, atomic.LoadInt64(&ent.expiration), atomic.LoadInt64(&c.baseTimeNanos), c.Set)
+	}
+
 	atomic.AddUint64(&c.stats.Hits, 1)
-	return e.(*entry).value, true
+	return ent.value, true
+}
+
+// revalidate replaces a stale entry with a fresh value, unless the entry was updated in the meantime.
+func (c *ttlCache[K, V]) revalidate(key K, stale *entry[V]) {
+	value, err := c.refresh(key)
+	if err != nil {
+		// keep serving the stale value, the next Get will try again
+		atomic.StoreInt32(&stale.refreshing, 0)
+		return
+	}
+
+	fresh := &entry[V]{
+		value:      value,
+		expiration: c.expirationTime(c.defaultExpiration),
+		window:     c.defaultExpiration.Nanoseconds(),
+	}
+	if c.entries.CompareAndSwap(key, stale, fresh) {
+		atomic.AddUint64(&c.stats.Writes, 1)
+	}
+}
+
+func (c *ttlCache[K, V]) Increment(key K, delta int64) (int64, error) {
+	for {
+		e, ok := c.entries.Load(key)
+		if !ok {
+			var zero V
+			value, n, err := IncrementValue(zero, false, delta)
+			if err != nil {
+				return 0, err
+			}
+			ent := &entry[V]{
+				value:      value,
+				expiration: c.expirationTime(c.defaultExpiration),
+				window:     c.defaultExpiration.Nanoseconds(),
+			}
+			if _, loaded := c.entries.LoadOrStore(key, ent); !loaded {
+				atomic.AddUint64(&c.stats.Writes, 1)
+				return n, nil
+			}
+			continue
+		}
+
+		// entries are immutable but for their expiration, so swap in a new one keeping the expiration of the
+		// old one, retrying if another update got there first
+		old := e.(*entry[V])
+		value, n, err := IncrementValue(old.value, true, delta)
+		if err != nil {
+			return 0, err
+		}
+		ent := &entry[V]{value: value, expiration: atomic.LoadInt64(&old.expiration), window: old.window}
+		if c.entries.CompareAndSwap(key, e, ent) {
+			atomic.AddUint64(&c.stats.Writes, 1)
+			return n, nil
+		}
+	}
 }
 
-func (c *ttlCache) Remove(key interface{}) {
-	c.entries.Delete(key)
+func (c *ttlCache[K, V]) LockKey(key K) func() {
+	return c.keyLocks.Lock(key)
+}
+
+func (c *ttlCache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	return c.loads.GetOrLoad(c, key, loader)
+}
+
+func (c *ttlCache[K, V]) GetOrLoadCtx(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error) {
+	return c.loads.GetOrLoadCtx(ctx, c, key, loader)
+}
+
+func (c *ttlCache[K, V]) Remove(key K) {
+	if e, ok := c.entries.LoadAndDelete(key); ok {
+		c.notifyOne(key, e.(*entry[V]).value, ReasonRemoved)
+	}
 
 	// Note: we count this as a removal even in the case where the key wasn't actually in the map
 	atomic.AddUint64(&c.stats.Removals, 1)
 }
 
-func (c *ttlCache) RemoveAll() {
+func (c *ttlCache[K, V]) RemoveAll() {
 	c.entries.Range(func(key interface{}, value interface{}) bool {
-		c.entries.Delete(key)
+		if e, ok := c.entries.LoadAndDelete(key); ok {
+			k, _ := key.(K)
+			c.notifyOne(k, e.(*entry[V]).value, ReasonRemoved)
+		}
 
 		// Note: can miscount if the key was evicted before it was removed
 		atomic.AddUint64(&c.stats.Removals, 1)
@@ -213,12 +442,97 @@ func (c *ttlCache) RemoveAll() {
 	})
 }
 
-func (c *ttlCache) Stats() Stats {
+func (c *ttlCache[K, V]) RemoveIf(predicate func(key K, value V) bool) int {
+	n := 0
+	c.entries.Range(func(key interface{}, value interface{}) bool {
+		k, _ := key.(K)
+		e := value.(*entry[V])
+
+		// only remove the entry the predicate was evaluated against, and not a fresh value set concurrently
+		if predicate(k, e.value) && c.entries.CompareAndDelete(key, value) {
+			atomic.AddUint64(&c.stats.Removals, 1)
+			c.notifyOne(k, e.value, ReasonRemoved)
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+func (c *ttlCache[K, V]) Preload(entries map[K]V) {
+	c.SetAll(entries)
+}
+
+func (c *ttlCache[K, V]) Snapshot() map[K]V {
+	result := make(map[K]V)
+	c.entries.Range(func(key interface{}, value interface{}) bool {
+		k, _ := key.(K)
+		result[k] = value.(*entry[V]).value
+		return true
+	})
+	return result
+}
+
+func (c *ttlCache[K, V]) WriteTo(w io.Writer) (int64, error) {
+	return WriteEntries[K, V](w, c.forEachTTL)
+}
+
+func (c *ttlCache[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	return ReadEntries[K, V](r, c.SetWithExpiration)
+}
+
+// Len has to walk the entries of the cache, as sync.Map doesn't track its size.
+func (c *ttlCache[K, V]) Len() int {
+	n := 0
+	c.entries.Range(func(key interface{}, value interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// ForEach skips the expired entries which weren't evicted yet.
+func (c *ttlCache[K, V]) ForEach(f func(key K, value V) bool) {
+	c.forEachTTL(func(key K, value V, _ time.Duration) bool {
+		return f(key, value)
+	})
+}
+
+// forEachTTL is like ForEach, also passing the time left before each entry expires to f.
+func (c *ttlCache[K, V]) forEachTTL(f func(key K, value V, ttl time.Duration) bool) {
+	now := c.now().UnixNano()
+	c.entries.Range(func(key interface{}, value interface{}) bool {
+		e := value.(*entry[V])
+		exp := atomic.LoadInt64(&e.expiration)
+		if exp <= now {
+			return true
+		}
+		k, _ := key.(K)
+		return f(k, e.value, time.Duration(exp-now))
+	})
+}
+
+func (c *ttlCache[K, V]) Stats() Stats {
 	return Stats{
-		Evictions: atomic.LoadUint64(&c.stats.Evictions),
-		Hits:      atomic.LoadUint64(&c.stats.Hits),
-		Misses:    atomic.LoadUint64(&c.stats.Misses),
-		Writes:    atomic.LoadUint64(&c.stats.Writes),
-		Removals:  atomic.LoadUint64(&c.stats.Removals),
+		Evictions:   atomic.LoadUint64(&c.stats.Evictions),
+		Expirations: atomic.LoadUint64(&c.stats.Expirations),
+		Hits:        atomic.LoadUint64(&c.stats.Hits),
+		Misses:      atomic.LoadUint64(&c.stats.Misses),
+		Writes:      atomic.LoadUint64(&c.stats.Writes),
+		Removals:    atomic.LoadUint64(&c.stats.Removals),
 	}
 }
+
+func (c *ttlCache[K, V]) StatsDelta() Stats {
+	return c.delta(c.Stats())
+}
+
+func (c *ttlCache[K, V]) ResetStats() {
+	atomic.StoreUint64(&c.stats.Evictions, 0)
+	atomic.StoreUint64(&c.stats.Expirations, 0)
+	atomic.StoreUint64(&c.stats.Hits, 0)
+	atomic.StoreUint64(&c.stats.Misses, 0)
+	atomic.StoreUint64(&c.stats.Writes, 0)
+	atomic.StoreUint64(&c.stats.Removals, 0)
+	c.reset()
+}
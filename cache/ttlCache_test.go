@@ -15,6 +15,7 @@
 package cache
 
 import (
+	"errors"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -31,7 +32,7 @@ func TestTTLConcurrent(t *testing.T) {
 }
 
 func TestTTLExpiration(t *testing.T) {
-	ttl := NewTTL(5*time.Second, 0).(*ttlCache)
+	ttl := NewTTL(5*time.Second, 0).(*ttlCache[interface{}, interface{}])
 	testCacheExpiration(ttl, ttl.evictExpired, t)
 }
 
@@ -41,7 +42,7 @@ func TestTTLEvicter(t *testing.T) {
 }
 
 func TestTTLEvictExpired(t *testing.T) {
-	ttl := NewTTL(5*time.Second, 0).(*ttlCache)
+	ttl := NewTTL(5*time.Second, 0).(*ttlCache[interface{}, interface{}])
 	testCacheEvictExpired(ttl, t)
 }
 
@@ -62,8 +63,205 @@ func TestTTLEvictionCallback(t *testing.T) {
 	}
 }
 
+func TestTTLSetEvictionCallback(t *testing.T) {
+	ttl := NewTTL(5*time.Second, 0).(*ttlCache[interface{}, interface{}])
+	testCacheEvictionCallback(ttl, ttl.evictExpired, t)
+}
+
+func TestTTLSetExpirationCallback(t *testing.T) {
+	ttl := NewTTL(5*time.Second, 0).(*ttlCache[interface{}, interface{}])
+	testCacheExpirationCallback(ttl, ttl.evictExpired, t)
+}
+
+func TestTTLGetOrLoad(t *testing.T) {
+	testCacheGetOrLoad(NewTTL(5*time.Minute, 1*time.Minute), t)
+	testCacheGetOrLoadCtx(NewTTL(5*time.Minute, 1*time.Minute), t)
+}
+
+func TestSlidingTTLBasic(t *testing.T) {
+	ttl := NewSlidingTTL(5*time.Second, 1*time.Millisecond)
+	testCacheBasic(ttl, t)
+}
+
+func TestSlidingTTLExpiration(t *testing.T) {
+	ttl := NewTypedSlidingTTL[string, string](5*time.Second, 0).(*ttlCache[string, string])
+	now := time.Now()
+	ttl.evictExpired(now)
+
+	ttl.SetWithExpiration("USED", "123", 10*time.Millisecond)
+	ttl.SetWithExpiration("UNUSED", "123", 10*time.Millisecond)
+
+	// using USED pushes its expiration back by its window
+	ttl.evictExpired(now.Add(8 * time.Millisecond))
+	if _, ok := ttl.Get("USED"); !ok {
+		t.Errorf("Got no value, expected USED to be present")
+	}
+
+	ttl.evictExpired(now.Add(15 * time.Millisecond))
+	if _, ok := ttl.Get("UNUSED"); ok {
+		t.Errorf("Got value, expected UNUSED to have been evicted")
+	}
+	if _, ok := ttl.Get("USED"); !ok {
+		t.Errorf("Got no value, expected USED to still be present")
+	}
+
+	// once it isn't used for longer than its window, USED expires too
+	ttl.evictExpired(now.Add(30 * time.Millisecond))
+	if _, ok := ttl.Get("USED"); ok {
+		t.Errorf("Got value, expected USED to have been evicted")
+	}
+
+	if s := ttl.Stats(); s.Evictions != 2 {
+		t.Errorf("Got %d evictions, expecting 2", s.Evictions)
+	}
+}
+
+func TestRevalidatingTTLBasic(t *testing.T) {
+	ttl := NewRevalidatingTTL(5*time.Second, 1*time.Millisecond, time.Second, func(key interface{}) (interface{}, error) {
+		return nil, errors.New("no refresh")
+	})
+	testCacheBasic(ttl, t)
+}
+
+func TestRevalidatingTTLRefresh(t *testing.T) {
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	var refreshes int32
+	ttl := NewTypedRevalidatingTTL[string, int](5*time.Second, 0, time.Second, func(key string) (int, error) {
+		atomic.AddInt32(&refreshes, 1)
+		started <- key
+		<-release
+		if key == "BROKEN" {
+			return 0, errors.New("unable to refresh")
+		}
+		return 2, nil
+	}).(*ttlCache[string, int])
+
+	now := time.Now()
+	ttl.evictExpired(now)
+	ttl.SetWithExpiration("A", 1, 10*time.Millisecond)
+
+	// fresh entries are served without a refresh
+	if value, ok := ttl.Get("A"); !ok || value != 1 {
+		t.Errorf("Got %d, %t, expected 1", value, ok)
+	}
+	if n := atomic.LoadInt32(&refreshes); n != 0 {
+		t.Errorf("Got %d refreshes, expected none", n)
+	}
+
+	// stale entries are served while a single refresh is in flight
+	ttl.evictExpired(now.Add(15 * time.Millisecond))
+	for i := 0; i < 3; i++ {
+		if value, ok := ttl.Get("A"); !ok || value != 1 {
+			t.Errorf("Got %d, %t, expected the stale value 1", value, ok)
+		}
+	}
+	<-started
+	if n := atomic.LoadInt32(&refreshes); n != 1 {
+		t.Errorf("Got %d refreshes, expected 1", n)
+	}
+	release <- struct{}{}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if value, _ := ttl.Get("A"); value == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for A to be refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a failed refresh keeps the stale value around until it's too stale
+	ttl.SetWithExpiration("BROKEN", 1, 10*time.Millisecond)
+	ttl.evictExpired(now.Add(30 * time.Millisecond))
+	if value, ok := ttl.Get("BROKEN"); !ok || value != 1 {
+		t.Errorf("Got %d, %t, expected the stale value 1", value, ok)
+	}
+	<-started
+	release <- struct{}{}
+
+	ttl.evictExpired(now.Add(2 * time.Second))
+	if _, ok := ttl.Get("BROKEN"); ok {
+		t.Error("Got a value, expected BROKEN to be evicted once too stale")
+	}
+}
+
+func TestTTLWithJitterBasic(t *testing.T) {
+	ttl := NewTTLWithJitter(5*time.Second, 1*time.Millisecond, 0.5)
+	testCacheBasic(ttl, t)
+}
+
+func TestTTLWithJitterExpiration(t *testing.T) {
+	ttl := NewTypedTTLWithJitter[int, int](100*time.Millisecond, 0, 0.5).(*ttlCache[int, int])
+	now := time.Now()
+	ttl.evictExpired(now)
+
+	entries := make(map[int]int)
+	for i := 0; i < 50; i++ {
+		entries[i] = i
+		ttl.Set(i+50, i)
+	}
+	ttl.SetAll(entries)
+
+	// entries never expire before their expiration, and some of them expire later
+	ttl.evictExpired(now.Add(99 * time.Millisecond))
+	if n := ttl.Len(); n != 100 {
+		t.Errorf("Got %d entries, expected none to expire before their expiration", n)
+	}
+	ttl.evictExpired(now.Add(125 * time.Millisecond))
+	if n := ttl.Len(); n == 0 || n == 100 {
+		t.Errorf("Got %d entries, expected the expirations to be spread out", n)
+	}
+	ttl.evictExpired(now.Add(151 * time.Millisecond))
+	if n := ttl.Len(); n != 0 {
+		t.Errorf("Got %d entries, expected all of them to expire within the jitter", n)
+	}
+}
+
+func TestTTLBatch(t *testing.T) {
+	testCacheBatch(NewTTL(5*time.Minute, 1*time.Minute), t)
+}
+
+func TestTTLForEach(t *testing.T) {
+	testCacheForEach(NewTTL(5*time.Minute, 1*time.Minute), t)
+}
+
+func TestTTLIncrement(t *testing.T) {
+	testCacheIncrement(NewTTL(5*time.Minute, 1*time.Minute), t)
+}
+
+func TestTTLLockKey(t *testing.T) {
+	testCacheLockKey(NewTTL(5*time.Minute, 1*time.Minute), t)
+}
+
+func TestTTLClock(t *testing.T) {
+	testCacheClock(NewTTL(5*time.Minute, 1*time.Minute), t)
+}
+
+func TestTTLRefreshAhead(t *testing.T) {
+	testCacheRefreshAhead(NewTTL(5*time.Minute, 1*time.Minute), t)
+}
+
+func TestTTLWriteTo(t *testing.T) {
+	testCacheWriteTo(NewTTL(5*time.Minute, 1*time.Minute), NewTTL(5*time.Minute, 1*time.Minute), t)
+}
+
+func TestTTLRemoveIf(t *testing.T) {
+	testCacheRemoveIf(NewTTL(5*time.Minute, 1*time.Minute), t)
+}
+
+func TestTTLStatsDelta(t *testing.T) {
+	testCacheStatsDelta(NewTTL(5*time.Minute, 1*time.Minute), t)
+}
+
+func TestTTLPreloadSnapshot(t *testing.T) {
+	testCachePreloadSnapshot(NewTTL(5*time.Minute, 1*time.Minute), t)
+}
+
 func TestTTLFinalizer(t *testing.T) {
-	ttl := NewTTL(5*time.Second, 1*time.Millisecond).(*ttlWrapper)
+	ttl := NewTTL(5*time.Second, 1*time.Millisecond).(*ttlWrapper[interface{}, interface{}])
 	testCacheFinalizer(&ttl.evicterTerminated)
 }
 
@@ -96,3 +294,25 @@ func BenchmarkTTLSetRemove(b *testing.B) {
 	c := NewTTL(5*time.Minute, 1*time.Minute)
 	benchmarkCacheSetRemove(c, b)
 }
+
+func TestTypedTTL(t *testing.T) {
+	ttl := NewTypedTTL[string, int](5*time.Second, 0)
+	ttl.Set("foo", 42)
+	if v, ok := ttl.Get("foo"); !ok || v != 42 {
+		t.Errorf("Got %v %v, expected 42 true", v, ok)
+	}
+	if v, ok := ttl.Get("bar"); ok || v != 0 {
+		t.Errorf("Got %v %v, expected 0 false", v, ok)
+	}
+	ttl.SetWithExpiration("bar", 1, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	ttl.EvictExpired()
+	if _, ok := ttl.Get("bar"); ok {
+		t.Error("Got an entry, expecting it to have been evicted")
+	}
+}
+
+func BenchmarkTTLSetAll(b *testing.B) {
+	c := NewTTL(5*time.Minute, 1*time.Minute)
+	benchmarkCacheSetAll(c, b)
+}
@@ -0,0 +1,486 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// This is an implementation of the full 2Q algorithm described in "2Q: A Low Overhead
+// High Performance Buffer Management Replacement Algorithm" by Johnson and Shasha.
+//
+// New entries are admitted into a small FIFO queue, a1in. Entries which fall off the end of
+// a1in without having been used again are assumed to be one-time accesses, and only their keys
+// are remembered in a second FIFO queue, a1out. An entry whose key is found in a1out when it is
+// set again has proven to be re-used, and is promoted to the main LRU list, am. Since entries
+// only enter am after being re-used, a scan over many keys only ever churns a1in and leaves the
+// frequently re-used entries in am resident.
+//
+// Just like for the LRU cache, entries also have an expiration time and are evicted
+// periodically once expired, and the same finalizer trickery is used to stop the
+// evicter goroutine once the cache is no longer referenced.
+
+// The fraction of the cache's capacity dedicated to a1in, and the number of keys remembered
+// in a1out relative to the capacity, as recommended by the paper.
+const (
+	twoQueueInRatio  = 0.25
+	twoQueueOutRatio = 0.50
+)
+
+// See use of SetFinalizer below for an explanation of this weird composition
+type twoQueueWrapper[K comparable, V any] struct {
+	*twoQueueCache[K, V]
+}
+
+type twoQueueCache[K comparable, V any] struct {
+	sync.Mutex
+	// baseTimeNanos must be at start of struct to ensure 64bit alignment for atomics on
+	// 32bit architectures. See also: https://golang.org/pkg/sync/atomic/#pkg-note-BUG
+	baseTimeNanos     int64
+	size              int
+	inSize            int
+	outSize           int
+	a1in, a1out, am   *list.List
+	lookup            map[K]*list.Element
+	stats             Stats
+	defaultExpiration time.Duration
+	stopEvicter       chan bool
+	evicterTerminated sync.WaitGroup // used by unit tests to verify the finalizer ran
+	evictionNotifier[K, V]
+	clockHolder
+	refreshAhead[K, V]
+	statsWindow
+	loads    LoadGroup[K, V]
+	keyLocks KeyLocks[K]
+}
+
+// twoQueueEntry is the value held by the elements of the 2Q lists. Entries of a1out have no value.
+type twoQueueEntry[K comparable, V any] struct {
+	key        K
+	value      V
+	expiration int64 // nanoseconds
+	list       *list.List
+}
+
+// NewTwoQueue creates a new cache with a 2Q and time-based eviction model.
+//
+// Cache eviction is done on a periodic basis. Individual cache entries are evicted
+// after their expiration time has passed. The periodic nature of eviction means that
+// cache entries tend to survive around (expirationTime + (evictionInterval / 2))
+//
+// In addition, when the cache is full, adding a new item will preferably displace an
+// item which has only been used once, such that scans over many keys don't displace the
+// items which are frequently re-used.
+//
+// defaultExpiration specifies the default minimum amount of time a cached
+// entry remains in the cache before eviction. This value is used with the
+// Set function. Explicit per-entry expiration times can be set with the
+// SetWithExpiration function instead.
+//
+// evictionInterval specifies the frequency at which eviction activities take
+// place. This should likely be >= 1 second.
+func NewTwoQueue(defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32) ExpiringCache {
+	return NewTypedTwoQueue[interface{}, interface{}](defaultExpiration, evictionInterval, maxEntries)
+}
+
+// NewTypedTwoQueue creates a new cache with a 2Q and time-based eviction model, holding keys
+// of type K and values of type V. See also: NewTwoQueue.
+func NewTypedTwoQueue[K comparable, V any](defaultExpiration time.Duration, evictionInterval time.Duration,
+	maxEntries int32) Expiring[K, V] {
+	inSize := int(float64(maxEntries) * twoQueueInRatio)
+	if inSize < 1 {
+		inSize = 1
+	}
+	c := &twoQueueCache[K, V]{
+		size:              int(maxEntries),
+		inSize:            inSize,
+		outSize:           int(float64(maxEntries) * twoQueueOutRatio),
+		a1in:              list.New(),
+		a1out:             list.New(),
+		am:                list.New(),
+		lookup:            make(map[K]*list.Element, maxEntries),
+		defaultExpiration: defaultExpiration,
+	}
+
+	c.baseTimeNanos = time.Now().UnixNano()
+	if evictionInterval > 0 {
+		c.stopEvicter = make(chan bool, 1)
+		c.evicterTerminated.Add(1)
+		go c.evicter(evictionInterval)
+
+		// We return a 'see-through' wrapper for the real object such that
+		// the finalizer can trigger on the wrapper. We can't set a finalizer
+		// on the main cache object because it would never fire, since the
+		// evicter goroutine is keeping it alive
+		result := &twoQueueWrapper[K, V]{c}
+		runtime.SetFinalizer(result, func(w *twoQueueWrapper[K, V]) {
+			w.stopEvicter <- true
+			w.evicterTerminated.Wait()
+		})
+		return result
+	}
+
+	return c
+}
+
+func (c *twoQueueCache[K, V]) evicter(evictionInterval time.Duration) {
+	// Wake up once in a while and evict stale items
+	ticker := time.NewTicker(evictionInterval)
+	for {
+		select {
+		case <-ticker.C:
+			c.evictExpired(c.now())
+		case <-c.stopEvicter:
+			ticker.Stop()
+			c.evicterTerminated.Done() // record this for the sake of unit tests
+			return
+		}
+	}
+}
+
+func (c *twoQueueCache[K, V]) evictExpired(t time.Time) {
+	// We snapshot a base time here such that the time doesn't need to be
+	// sampled in the Set call as calling time.Now() is relatively expensive.
+	// Doing it here provides enough precision for our needs and tends to have
+	// much lower call frequency.
+	n := t.UnixNano()
+	atomic.StoreInt64(&c.baseTimeNanos, n)
+
+	c.Lock()
+	for _, l := range []*list.List{c.a1in, c.am} {
+		for elem := l.Front(); elem != nil; {
+			next := elem.Next()
+			if ent := elem.Value.(*twoQueueEntry[K, V]); ent.expiration <= n {
+				c.evicted(ent.key, ent.value, ReasonExpired)
+				c.remove(elem)
+				c.stats.recordEviction(ReasonExpired)
+			}
+			elem = next
+		}
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *twoQueueCache[K, V]) EvictExpired() {
+	c.evictExpired(c.now())
+}
+
+func (c *twoQueueCache[K, V]) SetClock(clock Clock) {
+	c.setClock(clock)
+	atomic.StoreInt64(&c.baseTimeNanos, c.now().UnixNano())
+}
+
+func (c *twoQueueCache[K, V]) Set(key K, value V) {
+	c.SetWithExpiration(key, value, c.defaultExpiration)
+}
+
+func (c *twoQueueCache[K, V]) SetWithExpiration(key K, value V, expiration time.Duration) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + expiration.Nanoseconds()
+
+	c.Lock()
+	c.setWithExpiration(key, value, exp)
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *twoQueueCache[K, V]) SetAll(entries map[K]V) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + c.defaultExpiration.Nanoseconds()
+
+	c.Lock()
+	for key, value := range entries {
+		c.setWithExpiration(key, value, exp)
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *twoQueueCache[K, V]) setWithExpiration(key K, value V, exp int64) {
+	c.stats.Writes++
+
+	if elem, ok := c.lookup[key]; ok {
+		ent := elem.Value.(*twoQueueEntry[K, V])
+		switch ent.list {
+		case c.am:
+			c.am.MoveToFront(elem)
+		case c.a1in:
+			// entries stay in FIFO order while in a1in
+		case c.a1out:
+			// the entry was re-used after falling off a1in, it belongs in the main list
+			c.reclaim()
+			c.a1out.Remove(elem)
+			ent.list = c.am
+			c.lookup[key] = c.am.PushFront(ent)
+		}
+		ent.value = value
+		ent.expiration = exp
+		return
+	}
+
+	c.reclaim()
+	ent := &twoQueueEntry[K, V]{key: key, value: value, expiration: exp, list: c.a1in}
+	c.lookup[key] = c.a1in.PushFront(ent)
+}
+
+// reclaim makes room for one more resident entry.
+func (c *twoQueueCache[K, V]) reclaim() {
+	if c.a1in.Len()+c.am.Len() < c.size {
+		return
+	}
+
+	if c.a1in.Len() > c.inSize || c.am.Len() == 0 {
+		// page out the oldest entry of a1in, remembering its key in a1out
+		elem := c.a1in.Back()
+		if elem == nil {
+			return
+		}
+		var zero V
+		ent := elem.Value.(*twoQueueEntry[K, V])
+		c.evicted(ent.key, ent.value, ReasonCapacity)
+		ent.value = zero
+		c.a1in.Remove(elem)
+		ent.list = c.a1out
+		c.lookup[ent.key] = c.a1out.PushFront(ent)
+		if c.a1out.Len() > c.outSize {
+			c.remove(c.a1out.Back())
+		}
+	} else {
+		elem := c.am.Back()
+		ent := elem.Value.(*twoQueueEntry[K, V])
+		c.evicted(ent.key, ent.value, ReasonCapacity)
+		c.remove(elem)
+	}
+	c.stats.recordEviction(ReasonCapacity)
+}
+
+// remove forgets an entry entirely.
+func (c *twoQueueCache[K, V]) remove(elem *list.Element) {
+	ent := elem.Value.(*twoQueueEntry[K, V])
+	ent.list.Remove(elem)
+	delete(c.lookup, ent.key)
+}
+
+func (c *twoQueueCache[K, V]) Get(key K) (V, bool) {
+	c.Lock()
+	defer c.Unlock()
+	return c.get(key)
+}
+
+func (c *twoQueueCache[K, V]) GetAll(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+
+	c.Lock()
+	for _, key := range keys {
+		if value, ok := c.get(key); ok {
+			result[key] = value
+		}
+	}
+	c.Unlock()
+
+	return result
+}
+
+func (c *twoQueueCache[K, V]) get(key K) (V, bool) {
+	if elem, ok := c.lookup[key]; ok {
+		ent := elem.Value.(*twoQueueEntry[K, V])
+		if ent.list == c.am {
+			c.am.MoveToFront(elem)
+		}
+		if ent.list != c.a1out {
+			c.stats.Hits++
+			c.check(key, ent.expiration, atomic.LoadInt64(&c.baseTimeNanos), c.Set)
+			return ent.value, true
+		}
+	}
+
+	c.stats.Misses++
+	var zero V
+	return zero, false
+}
+
+func (c *twoQueueCache[K, V]) Increment(key K, delta int64) (int64, error) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + c.defaultExpiration.Nanoseconds()
+
+	c.Lock()
+	var value V
+	found := false
+	if elem, ok := c.lookup[key]; ok {
+		if ent := elem.Value.(*twoQueueEntry[K, V]); ent.list != c.a1out {
+			value, exp, found = ent.value, ent.expiration, true
+		}
+	}
+	value, n, err := IncrementValue(value, found, delta)
+	if err != nil {
+		c.Unlock()
+		return 0, err
+	}
+	c.setWithExpiration(key, value, exp)
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+
+	return n, nil
+}
+
+func (c *twoQueueCache[K, V]) LockKey(key K) func() {
+	return c.keyLocks.Lock(key)
+}
+
+func (c *twoQueueCache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	return c.loads.GetOrLoad(c, key, loader)
+}
+
+func (c *twoQueueCache[K, V]) GetOrLoadCtx(ctx context.Context, key K, loader func(ctx context.Context) (V, error)) (V, error) {
+	return c.loads.GetOrLoadCtx(ctx, c, key, loader)
+}
+
+func (c *twoQueueCache[K, V]) Remove(key K) {
+	c.Lock()
+
+	if elem, ok := c.lookup[key]; ok {
+		if ent := elem.Value.(*twoQueueEntry[K, V]); ent.list != c.a1out {
+			c.evicted(ent.key, ent.value, ReasonRemoved)
+			c.stats.Removals++
+		}
+		c.remove(elem)
+	}
+
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *twoQueueCache[K, V]) RemoveAll() {
+	c.Lock()
+
+	c.stats.Removals += uint64(c.a1in.Len() + c.am.Len())
+	for _, l := range []*list.List{c.a1in, c.am} {
+		for elem := l.Front(); elem != nil; elem = elem.Next() {
+			ent := elem.Value.(*twoQueueEntry[K, V])
+			c.evicted(ent.key, ent.value, ReasonRemoved)
+		}
+	}
+	c.a1in.Init()
+	c.a1out.Init()
+	c.am.Init()
+	c.lookup = make(map[K]*list.Element, c.size)
+
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+}
+
+func (c *twoQueueCache[K, V]) RemoveIf(predicate func(key K, value V) bool) int {
+	n := 0
+
+	c.Lock()
+	for _, l := range []*list.List{c.a1in, c.am} {
+		for elem := l.Front(); elem != nil; {
+			next := elem.Next()
+			if ent := elem.Value.(*twoQueueEntry[K, V]); predicate(ent.key, ent.value) {
+				c.evicted(ent.key, ent.value, ReasonRemoved)
+				c.remove(elem)
+				c.stats.Removals++
+				n++
+			}
+			elem = next
+		}
+	}
+	pending := c.takePending()
+	c.Unlock()
+	c.notify(pending)
+
+	return n
+}
+
+func (c *twoQueueCache[K, V]) Preload(entries map[K]V) {
+	c.SetAll(entries)
+}
+
+func (c *twoQueueCache[K, V]) Snapshot() map[K]V {
+	c.Lock()
+	defer c.Unlock()
+
+	result := make(map[K]V, c.a1in.Len()+c.am.Len())
+	for _, l := range []*list.List{c.a1in, c.am} {
+		for elem := l.Front(); elem != nil; elem = elem.Next() {
+			ent := elem.Value.(*twoQueueEntry[K, V])
+			result[ent.key] = ent.value
+		}
+	}
+	return result
+}
+
+func (c *twoQueueCache[K, V]) ForEach(f func(key K, value V) bool) {
+	c.forEachTTL(func(key K, value V, _ time.Duration) bool {
+		return f(key, value)
+	})
+}
+
+// forEachTTL is like ForEach, also passing the time left before each entry expires to f.
+func (c *twoQueueCache[K, V]) forEachTTL(f func(key K, value V, ttl time.Duration) bool) {
+	now := c.now().UnixNano()
+
+	c.Lock()
+	defer c.Unlock()
+
+	for _, l := range []*list.List{c.a1in, c.am} {
+		for elem := l.Front(); elem != nil; elem = elem.Next() {
+			if ent := elem.Value.(*twoQueueEntry[K, V]); ent.expiration > now && !f(ent.key, ent.value, time.Duration(ent.expiration-now)) {
+				return
+			}
+		}
+	}
+}
+
+func (c *twoQueueCache[K, V]) WriteTo(w io.Writer) (int64, error) {
+	return WriteEntries[K, V](w, c.forEachTTL)
+}
+
+func (c *twoQueueCache[K, V]) ReadFrom(r io.Reader) (int64, error) {
+	return ReadEntries[K, V](r, c.SetWithExpiration)
+}
+
+func (c *twoQueueCache[K, V]) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.a1in.Len() + c.am.Len()
+}
+
+func (c *twoQueueCache[K, V]) Stats() Stats {
+	c.Lock()
+	defer c.Unlock()
+	return c.stats
+}
+
+func (c *twoQueueCache[K, V]) StatsDelta() Stats {
+	return c.delta(c.Stats())
+}
+
+func (c *twoQueueCache[K, V]) ResetStats() {
+	c.Lock()
+	c.stats = Stats{}
+	c.Unlock()
+	c.reset()
+}
@@ -0,0 +1,178 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTwoQueueBasic(t *testing.T) {
+	tq := NewTwoQueue(5*time.Minute, 1*time.Millisecond, 500)
+	testCacheBasic(tq, t)
+}
+
+func TestTwoQueueConcurrent(t *testing.T) {
+	tq := NewTwoQueue(5*time.Minute, 1*time.Minute, 500)
+	testCacheConcurrent(tq, t)
+}
+
+func TestTwoQueueExpiration(t *testing.T) {
+	tq := NewTwoQueue(5*time.Second, 0, 500).(*twoQueueCache[interface{}, interface{}])
+	testCacheExpiration(tq, tq.evictExpired, t)
+}
+
+func TestTwoQueueEvicter(t *testing.T) {
+	tq := NewTwoQueue(5*time.Second, 1*time.Millisecond, 500)
+	testCacheEvicter(tq)
+}
+
+func TestTwoQueueEvictExpired(t *testing.T) {
+	tq := NewTwoQueue(5*time.Second, 0, 500).(*twoQueueCache[interface{}, interface{}])
+	testCacheEvictExpired(tq, t)
+}
+
+func TestTwoQueueSetEvictionCallback(t *testing.T) {
+	tq := NewTwoQueue(5*time.Second, 0, 500).(*twoQueueCache[interface{}, interface{}])
+	testCacheEvictionCallback(tq, tq.evictExpired, t)
+
+	testCacheCapacityCallback(NewTwoQueue(5*time.Second, 0, 1), t)
+}
+
+func TestTwoQueueSetExpirationCallback(t *testing.T) {
+	tq := NewTwoQueue(5*time.Second, 0, 500).(*twoQueueCache[interface{}, interface{}])
+	testCacheExpirationCallback(tq, tq.evictExpired, t)
+}
+
+func TestTwoQueueGetOrLoad(t *testing.T) {
+	testCacheGetOrLoad(NewTwoQueue(5*time.Minute, 1*time.Minute, 500), t)
+	testCacheGetOrLoadCtx(NewTwoQueue(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTwoQueueBatch(t *testing.T) {
+	testCacheBatch(NewTwoQueue(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTwoQueueForEach(t *testing.T) {
+	testCacheForEach(NewTwoQueue(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTwoQueueIncrement(t *testing.T) {
+	testCacheIncrement(NewTwoQueue(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTwoQueueLockKey(t *testing.T) {
+	testCacheLockKey(NewTwoQueue(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTwoQueueClock(t *testing.T) {
+	testCacheClock(NewTwoQueue(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTwoQueueRefreshAhead(t *testing.T) {
+	testCacheRefreshAhead(NewTwoQueue(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTwoQueueWriteTo(t *testing.T) {
+	testCacheWriteTo(NewTwoQueue(5*time.Minute, 1*time.Minute, 500), NewTwoQueue(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTwoQueueRemoveIf(t *testing.T) {
+	testCacheRemoveIf(NewTwoQueue(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTwoQueueStatsDelta(t *testing.T) {
+	testCacheStatsDelta(NewTwoQueue(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTwoQueuePreloadSnapshot(t *testing.T) {
+	testCachePreloadSnapshot(NewTwoQueue(5*time.Minute, 1*time.Minute, 500), t)
+}
+
+func TestTwoQueueFinalizer(t *testing.T) {
+	tq := NewTwoQueue(5*time.Second, 1*time.Millisecond, 500).(*twoQueueWrapper[interface{}, interface{}])
+	testCacheFinalizer(&tq.evicterTerminated)
+}
+
+func TestTwoQueueBehavior(t *testing.T) {
+	tq := NewTypedTwoQueue[string, int](5*time.Minute, 0, 8)
+
+	// entries which are re-used after being paged out of the admission queue are promoted
+	tq.Set("hot1", 1)
+	tq.Set("hot2", 2)
+	for i := 0; i < 8; i++ {
+		tq.Set("warmup"+strconv.Itoa(i), i)
+	}
+	if _, ok := tq.Get("hot1"); ok {
+		t.Error("Got an entry for hot1, expecting it to have been paged out")
+	}
+	tq.Set("hot1", 1)
+	tq.Set("hot2", 2)
+
+	// a scan over many keys seen only once shouldn't displace them
+	for i := 0; i < 100; i++ {
+		tq.Set("scan"+strconv.Itoa(i), i)
+	}
+	if _, ok := tq.Get("hot1"); !ok {
+		t.Error("Got no entry for hot1, expecting it to survive the scan")
+	}
+	if _, ok := tq.Get("hot2"); !ok {
+		t.Error("Got no entry for hot2, expecting it to survive the scan")
+	}
+	if _, ok := tq.Get("scan99"); !ok {
+		t.Error("Got no entry for scan99, expecting the most recent entry to be present")
+	}
+	if _, ok := tq.Get("scan0"); ok {
+		t.Error("Got an entry for scan0, expecting it to have been displaced")
+	}
+
+	// the cache never holds more than its capacity
+	s := tq.Stats()
+	resident := s.Writes - s.Evictions - s.Removals
+	if resident != 8 {
+		t.Errorf("Got %d resident entries, expecting 8", resident)
+	}
+}
+
+func BenchmarkTwoQueueGet(b *testing.B) {
+	c := NewTwoQueue(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheGet(c, b)
+}
+
+func BenchmarkTwoQueueGetConcurrent(b *testing.B) {
+	c := NewTwoQueue(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheGetConcurrent(c, b)
+}
+
+func BenchmarkTwoQueueSet(b *testing.B) {
+	c := NewTwoQueue(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheSet(c, b)
+}
+
+func BenchmarkTwoQueueSetConcurrent(b *testing.B) {
+	c := NewTwoQueue(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheSetConcurrent(c, b)
+}
+
+func BenchmarkTwoQueueGetSetConcurrent(b *testing.B) {
+	c := NewTwoQueue(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheGetSetConcurrent(c, b)
+}
+
+func BenchmarkTwoQueueSetRemove(b *testing.B) {
+	c := NewTwoQueue(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheSetRemove(c, b)
+}
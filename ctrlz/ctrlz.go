@@ -44,6 +44,7 @@ import (
 
 var coreTopics = []fw.Topic{
 	topics.ScopeTopic(),
+	topics.RecordsTopic(),
 	topics.MemTopic(),
 	topics.EnvTopic(),
 	topics.ProcTopic(),
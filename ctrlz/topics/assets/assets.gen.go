@@ -8,6 +8,7 @@
 // templates/mem.html
 // templates/metrics.html
 // templates/proc.html
+// templates/records.html
 // templates/scopes.html
 // templates/signals.html
 // templates/version.html
@@ -820,6 +821,74 @@ func templatesProcHtml() (*asset, error) {
 	return a, nil
 }
 
+var _templatesRecordsHtml = []byte(`{{ define "content" }}
+
+<p>
+    The last log records of each scope retained in memory, whatever the output level of the scope.
+</p>
+
+{{ if .Enabled }}
+<form method="get">
+    <input name="scope" placeholder="scope" value="{{.Scope}}">
+    <select name="level">
+        <option value="debug" {{ if eq .Level "debug" }}selected{{ end }}>debug</option>
+        <option value="info" {{ if eq .Level "info" }}selected{{ end }}>info</option>
+        <option value="warn" {{ if eq .Level "warn" }}selected{{ end }}>warn</option>
+        <option value="error" {{ if eq .Level "error" }}selected{{ end }}>error</option>
+    </select>
+    <input name="contains" placeholder="message contains" value="{{.Contains}}">
+    <button class="btn btn-istio" type="submit">Filter</button>
+</form>
+
+<table>
+    <thead>
+    <tr>
+        <th>Time</th>
+        <th>Level</th>
+        <th>Scope</th>
+        <th>Message</th>
+        <th>Fields</th>
+    </tr>
+    </thead>
+
+    <tbody>
+        {{ range .Records }}
+            <tr>
+                <td>{{.Time.Format "2006-01-02T15:04:05.000000Z07:00"}}</td>
+                <td>{{.Level}}</td>
+                <td>{{.Scope}}</td>
+                <td>{{.Message}}</td>
+                <td>{{ range $key, $value := .Fields }}{{$key}}={{$value}} {{ end }}</td>
+            </tr>
+        {{ end }}
+    </tbody>
+</table>
+{{ else }}
+<p>
+    No records are retained, as the log ring buffer is disabled.
+</p>
+{{ end }}
+
+{{ template "last-refresh" .}}
+
+{{ end }}
+`)
+
+func templatesRecordsHtmlBytes() ([]byte, error) {
+	return _templatesRecordsHtml, nil
+}
+
+func templatesRecordsHtml() (*asset, error) {
+	bytes, err := templatesRecordsHtmlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "templates/records.html", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 var _templatesScopesHtml = []byte(`{{ define "content" }}
 <p>
         Logging for this process is organized in scopes. Each scope has different
@@ -832,6 +901,8 @@ var _templatesScopesHtml = []byte(`{{ define "content" }}
         <tr>
             <th>Scope</th>
             <th>Description</th>
+            <th>Component</th>
+            <th>Stability</th>
             <th>Output Level</th>
             <th>Stack Trace Level</th>
             <th>Log Callers?</th>
@@ -844,7 +915,9 @@ var _templatesScopesHtml = []byte(`{{ define "content" }}
             <tr id="{{$value.Name}}">
                 <td>{{$value.Name}}</td>
                 <td>{{$value.Description}}</td>
-                <td class="text-center">
+                <td>{{$value.Component}}</td>
+                <td>{{$value.Stability}}</td>
+                <td class="text-center" title="{{$value.DefaultLevelRationale}}">
                     <div class="dropdown">
                         <button id="outputLevel" class="btn btn-istio dropdown-toggle" type="button" data-toggle="dropdown">
                             {{$value.OutputLevel}}
@@ -1211,6 +1284,7 @@ var _bindata = map[string]func() (*asset, error){
 	"templates/mem.html":             templatesMemHtml,
 	"templates/metrics.html":         templatesMetricsHtml,
 	"templates/proc.html":            templatesProcHtml,
+	"templates/records.html":         templatesRecordsHtml,
 	"templates/scopes.html":          templatesScopesHtml,
 	"templates/signals.html":         templatesSignalsHtml,
 	"templates/version.html":         templatesVersionHtml,
@@ -1268,6 +1342,7 @@ var _bintree = &bintree{nil, map[string]*bintree{
 		"mem.html":     &bintree{templatesMemHtml, map[string]*bintree{}},
 		"metrics.html": &bintree{templatesMetricsHtml, map[string]*bintree{}},
 		"proc.html":    &bintree{templatesProcHtml, map[string]*bintree{}},
+		"records.html": &bintree{templatesRecordsHtml, map[string]*bintree{}},
 		"scopes.html":  &bintree{templatesScopesHtml, map[string]*bintree{}},
 		"signals.html": &bintree{templatesSignalsHtml, map[string]*bintree{}},
 		"version.html": &bintree{templatesVersionHtml, map[string]*bintree{}},
@@ -0,0 +1,125 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topics
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"istio.io/pkg/ctrlz/fw"
+	"istio.io/pkg/ctrlz/topics/assets"
+	"istio.io/pkg/log"
+)
+
+type recordsTopic struct {
+}
+
+// RecordsTopic returns a ControlZ topic that allows visualization of the log records retained by the
+// ring buffer of the log package, see log.Options.RingBufferSize.
+func RecordsTopic() fw.Topic {
+	return recordsTopic{}
+}
+
+func (recordsTopic) Title() string {
+	return "Recent Log Records"
+}
+
+func (recordsTopic) Prefix() string {
+	return "records"
+}
+
+type recordInfo struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Scope   string                 `json:"scope"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+type recordsInfo struct {
+	Enabled  bool
+	Scope    string
+	Level    string
+	Contains string
+	Records  []recordInfo
+}
+
+// getRecords returns the records filtered by the scope, level and contains query parameters, which
+// respectively give the scope of the records, the least severe level of the records, and text the
+// messages of the records must contain.
+func getRecords(req *http.Request) (*recordsInfo, error) {
+	q := req.URL.Query()
+	info := &recordsInfo{
+		Scope:    q.Get("scope"),
+		Level:    q.Get("level"),
+		Contains: q.Get("contains"),
+		Records:  []recordInfo{},
+	}
+
+	level := log.DebugLevel
+	if info.Level != "" {
+		l, ok := stringToLevel[info.Level]
+		if !ok {
+			return nil, fmt.Errorf("unknown level: %s", info.Level)
+		}
+		level = l
+	}
+
+	records := log.RecentRecords(info.Scope, level)
+	info.Enabled = records != nil
+	for _, r := range records {
+		if info.Contains != "" && !strings.Contains(r.Message, info.Contains) {
+			continue
+		}
+
+		l, ok := levelToString[r.Level]
+		if !ok {
+			l = "fatal"
+		}
+		info.Records = append(info.Records, recordInfo{
+			Time:    r.Time,
+			Level:   l,
+			Scope:   r.Scope,
+			Message: r.Message,
+			Fields:  r.Fields,
+		})
+	}
+
+	return info, nil
+}
+
+func (recordsTopic) Activate(context fw.TopicContext) {
+	tmpl := template.Must(context.Layout().Parse(string(assets.MustAsset("templates/records.html"))))
+
+	_ = context.HTMLRouter().StrictSlash(true).NewRoute().Path("/").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info, err := getRecords(req)
+		if err != nil {
+			info = &recordsInfo{Records: []recordInfo{}}
+		}
+		fw.RenderHTML(w, tmpl, info)
+	})
+
+	_ = context.JSONRouter().StrictSlash(true).NewRoute().Methods("GET").Path("/").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info, err := getRecords(req)
+		if err != nil {
+			fw.RenderError(w, http.StatusBadRequest, err)
+			return
+		}
+		fw.RenderJSON(w, http.StatusOK, info.Records)
+	})
+}
@@ -36,6 +36,11 @@ type scopeInfo struct {
 	OutputLevel     string `json:"output_level"`
 	StackTraceLevel string `json:"stack_trace_level"`
 	LogCallers      bool   `json:"log_callers"`
+
+	// from the metadata of the scope, these can't be changed
+	Component             string `json:"component,omitempty"`
+	Stability             string `json:"stability,omitempty"`
+	DefaultLevelRationale string `json:"default_level_rationale,omitempty"`
 }
 
 var levelToString = map[log.Level]string{
@@ -68,12 +73,16 @@ func (scopeTopic) Prefix() string {
 }
 
 func getScopeInfo(s *log.Scope) *scopeInfo {
+	md := s.Metadata()
 	return &scopeInfo{
-		Name:            s.Name(),
-		Description:     s.Description(),
-		OutputLevel:     levelToString[s.GetOutputLevel()],
-		StackTraceLevel: levelToString[s.GetStackTraceLevel()],
-		LogCallers:      s.GetLogCallers(),
+		Name:                  s.Name(),
+		Description:           s.Description(),
+		OutputLevel:           levelToString[s.GetOutputLevel()],
+		StackTraceLevel:       levelToString[s.GetStackTraceLevel()],
+		LogCallers:            s.GetLogCallers(),
+		Component:             md.Component,
+		Stability:             string(md.Stability),
+		DefaultLevelRationale: md.DefaultLevelRationale,
 	}
 }
 
@@ -94,8 +103,13 @@ func (scopeTopic) Activate(context fw.TopicContext) {
 	_ = context.JSONRouter().NewRoute().Methods("PUT").Path("/{scope}").HandlerFunc(putScope)
 }
 
-func getAllScopes(w http.ResponseWriter, _ *http.Request) {
+// getAllScopes returns all the scopes, or those owned by the component given by the component query
+// parameter.
+func getAllScopes(w http.ResponseWriter, req *http.Request) {
 	allScopes := log.Scopes()
+	if component := req.URL.Query().Get("component"); component != "" {
+		allScopes = log.FilterScopes(func(s *log.Scope) bool { return s.Metadata().Component == component })
+	}
 
 	scopeInfos := make([]scopeInfo, 0, len(allScopes))
 	for _, s := range allScopes {
@@ -0,0 +1,111 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ledger
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// version is a single state of the ledger, identified by the root hash of its trie.
+type version struct {
+	root    []byte
+	seq     uint64
+	created time.Time
+	// changes were applied to the previous version to produce this one
+	changes []Change
+}
+
+// history tracks the versions of a ledger which are still retained, oldest first.
+// The last version is the current state of the ledger.
+type history struct {
+	sync.RWMutex
+	versions []version
+	// next is the sequence number of the next recorded version
+	next uint64
+}
+
+// record appends a new current version, and forgets any versions which were replaced
+// more than retention ago. Nothing is recorded if root is already the current version.
+// The caller must hold the write lock.
+func (h *history) record(root []byte, changes []Change, now time.Time, retention time.Duration) {
+	if len(h.versions) > 0 && bytes.Equal(h.versions[len(h.versions)-1].root, root) {
+		return
+	}
+	r := make([]byte, len(root))
+	copy(r, root)
+	h.versions = append(h.versions, version{root: r, seq: h.next, created: now, changes: append([]Change(nil), changes...)})
+	h.next++
+
+	// versions[i] was replaced at the time versions[i+1] was created
+	i := 0
+	for i < len(h.versions)-1 && now.Sub(h.versions[i+1].created) >= retention {
+		i++
+	}
+	h.versions = h.versions[i:]
+}
+
+// roots returns the root hashes of every retained version. The caller must hold the read lock.
+func (h *history) roots() [][]byte {
+	roots := make([][]byte, 0, len(h.versions))
+	for _, v := range h.versions {
+		roots = append(roots, v.root)
+	}
+	return roots
+}
+
+// sequence returns the sequence number of the current version. The caller must hold the read lock.
+func (h *history) sequence() uint64 {
+	if h.next == 0 {
+		return 0
+	}
+	return h.next - 1
+}
+
+// at returns the retained version with sequence number seq. The caller must hold the read lock.
+func (h *history) at(seq uint64) (version, error) {
+	i := sort.Search(len(h.versions), func(i int) bool {
+		return h.versions[i].seq >= seq
+	})
+	if i == len(h.versions) || h.versions[i].seq != seq {
+		return version{}, fmt.Errorf("version %d of the ledger is not retained", seq)
+	}
+	return h.versions[i], nil
+}
+
+// find returns the index of the most recent retained version with the given root. The caller must hold the read lock.
+func (h *history) find(root []byte) (int, error) {
+	for i := len(h.versions) - 1; i >= 0; i-- {
+		if bytes.Equal(h.versions[i].root, root) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("version %s of the ledger is not retained", base64.StdEncoding.EncodeToString(root))
+}
+
+// atTime returns the retained version which was current at t. The caller must hold the read lock.
+func (h *history) atTime(t time.Time) (version, error) {
+	i := sort.Search(len(h.versions), func(i int) bool {
+		return h.versions[i].created.After(t)
+	})
+	if i == 0 {
+		return version{}, fmt.Errorf("no version of the ledger current at %v is retained", t)
+	}
+	return h.versions[i-1], nil
+}
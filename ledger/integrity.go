@@ -0,0 +1,85 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ledger
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Inconsistency is a single problem found by VerifyIntegrity.
+type Inconsistency struct {
+	// Root is the root hash of the version in which the problem was found.
+	Root string
+	// Node is the hex encoded hash of the inconsistent node, if any.
+	Node string
+	// Height of the inconsistent node in the trie.
+	Height int
+	// Problem describes what is wrong.
+	Problem string
+}
+
+func (i Inconsistency) String() string {
+	if i.Node == "" {
+		return fmt.Sprintf("version %s: %s", i.Root, i.Problem)
+	}
+	return fmt.Sprintf("version %s: node %s at height %d: %s", i.Root, i.Node, i.Height, i.Problem)
+}
+
+// IntegrityError is returned by VerifyIntegrity when the ledger is corrupted.
+type IntegrityError struct {
+	Inconsistencies []Inconsistency
+}
+
+func (e *IntegrityError) Error() string {
+	problems := make([]string, 0, len(e.Inconsistencies))
+	for _, i := range e.Inconsistencies {
+		problems = append(problems, i.String())
+	}
+	return fmt.Sprintf("ledger integrity check found %d inconsistencies: %s", len(problems), strings.Join(problems, "; "))
+}
+
+// VerifyIntegrity walks the trie of every retained version, recomputing the hash of each node from its children,
+// and checks that the accounting of nodes held in memory covers every reachable node. This is expensive, and is
+// meant to be used after crashes or in long running soak tests.
+func (s *smtLedger) VerifyIntegrity() error {
+	s.history.RLock()
+	roots := append(s.history.roots(), s.tree.root)
+	s.history.RUnlock()
+
+	s.tree.lock.RLock()
+	defer s.tree.lock.RUnlock()
+	var e IntegrityError
+	v := &verifier{}
+	for _, root := range roots {
+		r := base64.StdEncoding.EncodeToString(root)
+		v.report = func(node []byte, height int, problem string) {
+			e.Inconsistencies = append(e.Inconsistencies, Inconsistency{
+				Root: r, Node: fmt.Sprintf("%x", node), Height: height, Problem: problem})
+		}
+		s.tree.verify(root, v)
+	}
+	if n := s.tree.db.updatedNodes.Len(); n < len(v.verified) {
+		e.Inconsistencies = append(e.Inconsistencies, Inconsistency{
+			Root:    base64.StdEncoding.EncodeToString(s.tree.root),
+			Problem: fmt.Sprintf("%d nodes are accounted for, but %d are reachable", n, len(v.verified)),
+		})
+	}
+	if len(e.Inconsistencies) > 0 {
+		return &e
+	}
+	return nil
+}
@@ -19,7 +19,12 @@
 package ledger
 
 import (
+	"bytes"
 	"encoding/base64"
+	"errors"
+	"io"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/spaolacci/murmur3"
@@ -34,39 +39,268 @@ type Ledger interface {
 	Put(key, value string) (string, error)
 	// Delete removes a key from the Ledger, which may still be read using GetPreviousValue
 	Delete(key string) error
+	// DeleteMulti removes several keys from the Ledger at once, creating a single new version.
+	DeleteMulti(keys []string) (string, error)
+	// Apply applies several changes to the Ledger at once, creating a single new version.
+	Apply(changes []Change) (string, error)
+	// Changes returns the changes applied to a previous version of the Ledger to produce its current state. Applying
+	// them to a ledger which is at that previous version makes it converge to the same root hash.
+	Changes(sinceRoot string) ([]Change, error)
 	// Get returns a the value of the key from the Ledger's current state
 	Get(key string) (string, error)
 	// RootHash is the hash of all keys and values currently in the Ledger
 	RootHash() string
 	// GetPreviousValue executes a get against a previous version of the ledger, using that version's root hash.
 	GetPreviousValue(previousRootHash, key string) (result string, err error)
+	// ProveConsistency returns a proof that the version toRoot of the Ledger was derived from the version fromRoot.
+	ProveConsistency(fromRoot, toRoot string) (*ConsistencyProof, error)
+	// Sequence returns the sequence number of the current version of the Ledger. Sequence numbers increase
+	// with every new version.
+	Sequence() uint64
+	// RootHashAt returns the root hash of the version of the Ledger with sequence number seq, if it is still retained.
+	RootHashAt(seq uint64) (string, error)
+	// RootHashAtTime returns the root hash of the version of the Ledger which was current at t, if it is still retained.
+	RootHashAtTime(t time.Time) (string, error)
+	// GetAt returns the value of key in the version of the Ledger which was current at t, if it is still retained.
+	GetAt(t time.Time, key string) (string, error)
+	// VerifyIntegrity checks that every retained version of the Ledger is intact, returning an *IntegrityError
+	// describing the inconsistencies found otherwise.
+	VerifyIntegrity() error
+	// Stats returns information about the nodes held in memory by the Ledger.
+	Stats() (Stats, error)
+	// DumpTree writes a human-readable description of the tree of a version of the ledger to w.
+	DumpTree(rootHash string, w io.Writer) error
+	// EraseRootHash forgets a previous version of the ledger, reclaiming the memory used by nodes which are not
+	// part of any other retained version.
+	EraseRootHash(rootHash string) error
+	// EraseRootHashAsync queues a call to EraseRootHash on a background goroutine, and calls done with the result.
+	EraseRootHashAsync(rootHash string, done func(error))
+}
+
+// Change is a single mutation of a Ledger.
+type Change struct {
+	Key   string
+	Value string
+	// Deleted is true if Key was removed from the Ledger, in which case Value is ignored.
+	Deleted bool
+}
+
+// Stats describes the trie nodes held in memory by a Ledger.
+type Stats struct {
+	// Nodes is the number of trie nodes currently held in memory.
+	Nodes uint64
+
+	// OrphanedNodes is the number of nodes held in memory which are no longer reachable from the root
+	// of any retained version of the Ledger, and have not been evicted yet.
+	OrphanedNodes uint64
 }
 
 type smtLedger struct {
-	tree *smt
+	tree    *smt
+	history history
+
+	// maxValueSize is the maximum length of a value accepted by Put, 0 meaning unlimited.
+	maxValueSize int
+
+	// eraseMux protects the erase queue that is drained by a background goroutine.
+	eraseMux     sync.Mutex
+	erasePending []eraseRequest
+	eraseRunning bool
+}
+
+// eraseRequest is a call to EraseRootHashAsync waiting to be processed.
+type eraseRequest struct {
+	root []byte
+	done func(error)
+}
+
+// ErrValueTooLarge is returned by Put when a value exceeds the size set with WithMaxValueSize.
+var ErrValueTooLarge = errors.New("value exceeds the maximum size of the ledger")
+
+// Option configures a Ledger created by Make.
+type Option func(*smtLedger)
+
+// WithMaxValueSize causes Put to reject values longer than n bytes with ErrValueTooLarge.
+func WithMaxValueSize(n int) Option {
+	return func(l *smtLedger) {
+		l.maxValueSize = n
+	}
 }
 
 // Make returns a Ledger which will retain previous nodes after they are deleted.
-func Make(retention time.Duration) Ledger {
-	return smtLedger{tree: newSMT(hasher, nil, retention)}
+func Make(retention time.Duration, opts ...Option) Ledger {
+	l := &smtLedger{tree: newSMT(hasher, nil, retention)}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.history.record(l.tree.root, nil, time.Now(), retention)
+	return l
 }
 
 // Put adds a key value pair to the ledger, overwriting previous values and marking them for
 // removal after the retention specified in Make()
-func (s smtLedger) Put(key, value string) (result string, err error) {
-	b, err := s.tree.Update([][]byte{coerceKeyToHashLen(key)}, [][]byte{coerceToHashLen(value)})
+func (s *smtLedger) Put(key, value string) (result string, err error) {
+	b, err := s.apply([]Change{{Key: key, Value: value}})
 	result = string(b)
 	return
 }
 
 // Delete removes a key value pair from the ledger, marking it for removal after the retention specified in Make()
-func (s smtLedger) Delete(key string) (err error) {
-	_, err = s.tree.Update([][]byte{[]byte(key)}, [][]byte{defaultLeaf})
+func (s *smtLedger) Delete(key string) (err error) {
+	_, err = s.DeleteMulti([]string{key})
 	return
 }
 
+// DeleteMulti removes all of keys from the ledger in a single new version, and returns its root hash.
+// Removed values are marked for removal after the retention specified in Make()
+func (s *smtLedger) DeleteMulti(keys []string) (string, error) {
+	changes := make([]Change, 0, len(keys))
+	for _, key := range keys {
+		changes = append(changes, Change{Key: key, Deleted: true})
+	}
+	return s.Apply(changes)
+}
+
+// Apply applies changes to the ledger in a single new version, and returns its root hash. When the same key
+// is changed several times, the last change wins.
+func (s *smtLedger) Apply(changes []Change) (string, error) {
+	if len(changes) == 0 {
+		return s.RootHash(), nil
+	}
+	b, err := s.apply(changes)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// apply checks the size of the values of changes before applying them, and returns the resulting root.
+func (s *smtLedger) apply(changes []Change) ([]byte, error) {
+	if s.maxValueSize > 0 {
+		for _, c := range changes {
+			if len(c.Value) > s.maxValueSize {
+				return nil, ErrValueTooLarge
+			}
+		}
+	}
+	return s.update(changes)
+}
+
+// Changes returns the changes which were applied to the version with hash sinceRoot to produce the current version,
+// oldest first. sinceRoot must still be retained.
+func (s *smtLedger) Changes(sinceRoot string) ([]Change, error) {
+	root, err := base64.StdEncoding.DecodeString(sinceRoot)
+	if err != nil {
+		return nil, err
+	}
+	s.history.RLock()
+	defer s.history.RUnlock()
+	i, err := s.history.find(root)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, v := range s.history.versions[i+1:] {
+		changes = append(changes, v.changes...)
+	}
+	return changes, nil
+}
+
+// update applies changes to the trie, and records the resulting version.
+func (s *smtLedger) update(changes []Change) ([]byte, error) {
+	keys, values := trieUpdates(changes)
+	s.history.Lock()
+	defer s.history.Unlock()
+	b, err := s.tree.Update(keys, values)
+	if err != nil {
+		return nil, err
+	}
+	s.history.record(b, changes, time.Now(), s.tree.retentionDuration)
+	return b, nil
+}
+
+// trieUpdates converts changes to the sorted, unique keys and values required by the trie.
+func trieUpdates(changes []Change) (keys, values [][]byte) {
+	latest := make(map[hash][]byte, len(changes))
+	keys = make([][]byte, 0, len(changes))
+	for _, c := range changes {
+		k := coerceKeyToHashLen(c.Key)
+		var h hash
+		copy(h[:], k)
+		if _, ok := latest[h]; !ok {
+			keys = append(keys, k)
+		}
+		if c.Deleted {
+			latest[h] = defaultLeaf
+		} else {
+			latest[h] = coerceToHashLen(c.Value)
+		}
+	}
+	sort.Sort(dataArray(keys))
+	values = make([][]byte, len(keys))
+	for i, k := range keys {
+		var h hash
+		copy(h[:], k)
+		values[i] = latest[h]
+	}
+	return keys, values
+}
+
+// Sequence returns the sequence number of the current version. The empty ledger created by Make is version 0,
+// and every change to the ledger's state creates a version with the next sequence number.
+func (s *smtLedger) Sequence() uint64 {
+	s.history.RLock()
+	defer s.history.RUnlock()
+	return s.history.sequence()
+}
+
+// RootHashAt returns the root hash of the version with sequence number seq, if it is still retained.
+func (s *smtLedger) RootHashAt(seq uint64) (string, error) {
+	s.history.RLock()
+	defer s.history.RUnlock()
+	v, err := s.history.at(seq)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(v.root), nil
+}
+
+// RootHashAtTime returns the root hash of the version which was current at t, if it is still retained.
+func (s *smtLedger) RootHashAtTime(t time.Time) (string, error) {
+	s.history.RLock()
+	defer s.history.RUnlock()
+	v, err := s.history.atTime(t)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(v.root), nil
+}
+
+// GetAt returns the value of key in the version which was current at t, if it is still retained.
+func (s *smtLedger) GetAt(t time.Time, key string) (string, error) {
+	root, err := s.RootHashAtTime(t)
+	if err != nil {
+		return "", err
+	}
+	return s.GetPreviousValue(root, key)
+}
+
+// Stats returns the number of nodes held in memory, and how many of them could be reclaimed because
+// they are not reachable from any retained version. Computing the stats walks every retained version,
+// so this should not be called on a hot path.
+func (s *smtLedger) Stats() (Stats, error) {
+	s.history.RLock()
+	roots := append(s.history.roots(), s.tree.root)
+	s.history.RUnlock()
+	total, orphaned, err := s.tree.orphanedNodes(roots...)
+	if err != nil {
+		return Stats{}, err
+	}
+	return Stats{Nodes: total, OrphanedNodes: orphaned}, nil
+}
+
 // GetPreviousValue returns the value of key when the ledger's RootHash was previousHash, if it is still retained.
-func (s smtLedger) GetPreviousValue(previousRootHash, key string) (result string, err error) {
+func (s *smtLedger) GetPreviousValue(previousRootHash, key string) (result string, err error) {
 	prevBytes, err := base64.StdEncoding.DecodeString(previousRootHash)
 	if err != nil {
 		return "", err
@@ -84,15 +318,138 @@ func (s smtLedger) GetPreviousValue(previousRootHash, key string) (result string
 }
 
 // Get returns the current value of key.
-func (s smtLedger) Get(key string) (result string, err error) {
+func (s *smtLedger) Get(key string) (result string, err error) {
 	return s.GetPreviousValue(s.RootHash(), key)
 }
 
 // RootHash represents the hash of the current state of the ledger.
-func (s smtLedger) RootHash() string {
+func (s *smtLedger) RootHash() string {
 	return base64.StdEncoding.EncodeToString(s.tree.root)
 }
 
+// DumpTree writes the structure of the trie with root rootHash to w, one node per line, indented by depth.
+// Leaves are printed with their (hashed) key and value, which helps tracking down hash mismatches between replicas.
+func (s *smtLedger) DumpTree(rootHash string, w io.Writer) error {
+	root, err := base64.StdEncoding.DecodeString(rootHash)
+	if err != nil {
+		return err
+	}
+	return s.tree.Dump(root, w)
+}
+
+// EraseRootHash forgets every retained version of the ledger with hash rootHash, and removes from memory the nodes which
+// are no longer reachable from the remaining versions. The current version of the ledger cannot be erased.
+func (s *smtLedger) EraseRootHash(rootHash string) error {
+	root, err := base64.StdEncoding.DecodeString(rootHash)
+	if err != nil {
+		return err
+	}
+	return s.erase([][]byte{root})
+}
+
+// EraseRootHashAsync erases rootHash like EraseRootHash, but without blocking the caller. The erase is performed on a
+// background goroutine, batched with any other erase queued in the meantime, and done is called with its result.
+func (s *smtLedger) EraseRootHashAsync(rootHash string, done func(error)) {
+	if done == nil {
+		done = func(error) {}
+	}
+	root, err := base64.StdEncoding.DecodeString(rootHash)
+	if err != nil {
+		done(err)
+		return
+	}
+	s.eraseMux.Lock()
+	defer s.eraseMux.Unlock()
+	s.erasePending = append(s.erasePending, eraseRequest{root: root, done: done})
+	if !s.eraseRunning {
+		// the goroutine exits once the queue is drained, so that idle ledgers don't hold on to one
+		s.eraseRunning = true
+		go s.eraser()
+	}
+}
+
+// eraser processes queued erase requests until there are none left.
+func (s *smtLedger) eraser() {
+	for {
+		s.eraseMux.Lock()
+		pending := s.erasePending
+		s.erasePending = nil
+		if len(pending) == 0 {
+			s.eraseRunning = false
+			s.eraseMux.Unlock()
+			return
+		}
+		s.eraseMux.Unlock()
+
+		roots := make([][]byte, 0, len(pending))
+		var valid []eraseRequest
+		for _, req := range pending {
+			if err := s.checkErasable(req.root); err != nil {
+				req.done(err)
+				continue
+			}
+			roots = append(roots, req.root)
+			valid = append(valid, req)
+		}
+		if len(valid) == 0 {
+			continue
+		}
+		err := s.erase(roots)
+		for _, req := range valid {
+			req.done(err)
+		}
+	}
+}
+
+var errEraseCurrent = errors.New("the current version of the ledger cannot be erased")
+
+// checkErasable returns an error if root cannot be erased.
+func (s *smtLedger) checkErasable(root []byte) error {
+	s.history.RLock()
+	defer s.history.RUnlock()
+	if bytes.Equal(root, s.tree.root) {
+		return errEraseCurrent
+	}
+	return nil
+}
+
+// erase forgets the versions with the given roots, and sweeps their nodes in a single pass. The current version,
+// which is never erased, follows any erased version, so their changes are always merged into a retained one.
+func (s *smtLedger) erase(roots [][]byte) error {
+	s.history.Lock()
+	defer s.history.Unlock()
+	for _, root := range roots {
+		if bytes.Equal(root, s.tree.root) {
+			return errEraseCurrent
+		}
+	}
+	// the changes of erased versions are merged into the next retained version, such that the changes
+	// since any retained version still produce the current one
+	versions := s.history.versions[:0]
+	var merged []Change
+	for _, v := range s.history.versions {
+		erased := false
+		for _, root := range roots {
+			if bytes.Equal(v.root, root) {
+				erased = true
+				break
+			}
+		}
+		if erased {
+			merged = append(merged, v.changes...)
+			continue
+		}
+		if len(merged) > 0 {
+			v.changes = append(merged, v.changes...)
+			merged = nil
+		}
+		versions = append(versions, v)
+	}
+	s.history.versions = versions
+	_, err := s.tree.erase(roots, append(s.history.roots(), s.tree.root))
+	return err
+}
+
 func coerceKeyToHashLen(val string) []byte {
 	hasher := murmur3.New64()
 	_, _ = hasher.Write([]byte(val))
@@ -15,6 +15,7 @@
 package ledger
 
 import (
+	"encoding/base64"
 	"fmt"
 	"math/rand"
 	"strconv"
@@ -157,3 +158,364 @@ func addConfig(ledger Ledger, b *testing.B) string {
 	assert.NilError(b, err)
 	return objectID
 }
+
+func TestOrphanedNodes(t *testing.T) {
+	l := Make(time.Minute)
+	_, err := l.Put("foo", "bar")
+	assert.NilError(t, err)
+	_, err = l.Put("second", "value")
+	assert.NilError(t, err)
+	stats, err := l.Stats()
+	assert.NilError(t, err)
+	assert.Assert(t, stats.Nodes > 0)
+	assert.Equal(t, stats.OrphanedNodes, uint64(0))
+
+	// without retention, every replaced node is orphaned as soon as a new version is created
+	l = Make(0)
+	_, err = l.Put("foo", "bar")
+	assert.NilError(t, err)
+	stats, err = l.Stats()
+	assert.NilError(t, err)
+	assert.Equal(t, stats.OrphanedNodes, uint64(0))
+	_, err = l.Put("foo", "baz")
+	assert.NilError(t, err)
+	stats, err = l.Stats()
+	assert.NilError(t, err)
+	assert.Assert(t, stats.OrphanedNodes > 0)
+	assert.Assert(t, stats.Nodes > stats.OrphanedNodes)
+}
+
+func TestDumpTree(t *testing.T) {
+	l := Make(time.Minute)
+	var b strings.Builder
+	assert.NilError(t, l.DumpTree(l.RootHash(), &b))
+	assert.Equal(t, b.String(), "<empty>\n")
+
+	_, err := l.Put("foo", "bar")
+	assert.NilError(t, err)
+	_, err = l.Put("second", "value")
+	assert.NilError(t, err)
+	b.Reset()
+	assert.NilError(t, l.DumpTree(l.RootHash(), &b))
+	out := b.String()
+	assert.Equal(t, strings.Count(out, "leaf "), 2)
+	assert.Assert(t, strings.Contains(out, fmt.Sprintf("key=%x", coerceKeyToHashLen("foo"))))
+	assert.Assert(t, strings.Contains(out, fmt.Sprintf("value=%x", coerceToHashLen("value"))))
+
+	assert.ErrorContains(t, l.DumpTree("not base64!", &b), "illegal base64")
+}
+
+func TestEraseRootHash(t *testing.T) {
+	l := Make(time.Minute)
+	_, err := l.Put("foo", "bar")
+	assert.NilError(t, err)
+	_, err = l.Put("second", "value")
+	assert.NilError(t, err)
+	first := l.RootHash()
+	_, err = l.Put("foo", "baz")
+	assert.NilError(t, err)
+	before, err := l.Stats()
+	assert.NilError(t, err)
+
+	assert.NilError(t, l.EraseRootHash(first))
+	_, err = l.GetPreviousValue(first, "foo")
+	assert.ErrorContains(t, err, "unavailable")
+	value, err := l.Get("foo")
+	assert.NilError(t, err)
+	assert.Equal(t, value, "baz")
+	value, err = l.Get("second")
+	assert.NilError(t, err)
+	assert.Equal(t, value, "value")
+	after, err := l.Stats()
+	assert.NilError(t, err)
+	assert.Assert(t, after.Nodes < before.Nodes)
+	assert.Equal(t, after.OrphanedNodes, uint64(0))
+
+	assert.Error(t, l.EraseRootHash(l.RootHash()), errEraseCurrent.Error())
+}
+
+func TestEraseRootHashAsync(t *testing.T) {
+	l := Make(time.Minute)
+	var roots []string
+	for i := 0; i < 10; i++ {
+		_, err := l.Put("foo", strconv.Itoa(i))
+		assert.NilError(t, err)
+		roots = append(roots, l.RootHash())
+	}
+	errs := make(chan error, len(roots))
+	for _, root := range roots {
+		l.EraseRootHashAsync(root, func(err error) { errs <- err })
+	}
+	var failed int
+	for range roots {
+		if err := <-errs; err != nil {
+			assert.Error(t, err, errEraseCurrent.Error())
+			failed++
+		}
+	}
+	assert.Equal(t, failed, 1)
+	for _, root := range roots[:len(roots)-1] {
+		_, err := l.GetPreviousValue(root, "foo")
+		assert.ErrorContains(t, err, "unavailable")
+	}
+	value, err := l.Get("foo")
+	assert.NilError(t, err)
+	assert.Equal(t, value, "9")
+}
+
+func TestMaxValueSize(t *testing.T) {
+	l := Make(time.Minute, WithMaxValueSize(4))
+	_, err := l.Put("foo", "bar")
+	assert.NilError(t, err)
+	root := l.RootHash()
+	_, err = l.Put("foo", "toolong")
+	assert.Equal(t, err, ErrValueTooLarge)
+	assert.Equal(t, l.RootHash(), root)
+	value, err := l.Get("foo")
+	assert.NilError(t, err)
+	assert.Equal(t, value, "bar")
+}
+
+func TestDeleteMulti(t *testing.T) {
+	l := Make(time.Minute)
+	for _, key := range []string{"foo", "bar", "baz", "second"} {
+		_, err := l.Put(key, key)
+		assert.NilError(t, err)
+	}
+	before := l.RootHash()
+	root, err := l.DeleteMulti([]string{"foo", "baz", "foo", "missing"})
+	assert.NilError(t, err)
+	assert.Equal(t, root, l.RootHash())
+	for key, expected := range map[string]string{"foo": "", "bar": "bar", "baz": "", "second": "second"} {
+		value, err := l.Get(key)
+		assert.NilError(t, err)
+		assert.Equal(t, value, expected)
+	}
+	value, err := l.GetPreviousValue(before, "foo")
+	assert.NilError(t, err)
+	assert.Equal(t, value, "foo")
+
+	// the result is identical to a ledger which never held the deleted keys
+	clean := Make(time.Minute)
+	_, err = clean.Put("bar", "bar")
+	assert.NilError(t, err)
+	_, err = clean.Put("second", "second")
+	assert.NilError(t, err)
+	assert.Equal(t, clean.RootHash(), root)
+
+	assert.NilError(t, l.Delete("bar"))
+	value, err = l.Get("bar")
+	assert.NilError(t, err)
+	assert.Equal(t, value, "")
+}
+
+func TestSequence(t *testing.T) {
+	l := Make(time.Minute)
+	assert.Equal(t, l.Sequence(), uint64(0))
+	empty, err := l.RootHashAt(0)
+	assert.NilError(t, err)
+	assert.Equal(t, empty, l.RootHash())
+
+	_, err = l.Put("foo", "bar")
+	assert.NilError(t, err)
+	foo := l.RootHash()
+	assert.Equal(t, l.Sequence(), uint64(1))
+	// writing the same state again doesn't create a new version
+	_, err = l.Put("foo", "bar")
+	assert.NilError(t, err)
+	assert.Equal(t, l.Sequence(), uint64(1))
+	_, err = l.Put("second", "value")
+	assert.NilError(t, err)
+	second := l.RootHash()
+	assert.Equal(t, l.Sequence(), uint64(2))
+
+	for seq, expected := range []string{empty, foo, second} {
+		root, err := l.RootHashAt(uint64(seq))
+		assert.NilError(t, err)
+		assert.Equal(t, root, expected)
+	}
+	_, err = l.RootHashAt(3)
+	assert.ErrorContains(t, err, "not retained")
+
+	assert.NilError(t, l.EraseRootHash(foo))
+	_, err = l.RootHashAt(1)
+	assert.ErrorContains(t, err, "not retained")
+	root, err := l.RootHashAt(2)
+	assert.NilError(t, err)
+	assert.Equal(t, root, second)
+}
+
+func TestChangefeed(t *testing.T) {
+	leader := Make(time.Minute)
+	follower := Make(time.Minute)
+	_, err := leader.Put("foo", "bar")
+	assert.NilError(t, err)
+	_, err = leader.Put("second", "value")
+	assert.NilError(t, err)
+
+	changes, err := leader.Changes(follower.RootHash())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes, []Change{{Key: "foo", Value: "bar"}, {Key: "second", Value: "value"}})
+	root, err := follower.Apply(changes)
+	assert.NilError(t, err)
+	assert.Equal(t, root, leader.RootHash())
+
+	since := leader.RootHash()
+	_, err = leader.Put("foo", "baz")
+	assert.NilError(t, err)
+	_, err = leader.DeleteMulti([]string{"second"})
+	assert.NilError(t, err)
+	changes, err = leader.Changes(since)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes, []Change{{Key: "foo", Value: "baz"}, {Key: "second", Deleted: true}})
+	root, err = follower.Apply(changes)
+	assert.NilError(t, err)
+	assert.Equal(t, root, leader.RootHash())
+	value, err := follower.Get("second")
+	assert.NilError(t, err)
+	assert.Equal(t, value, "")
+
+	changes, err = leader.Changes(leader.RootHash())
+	assert.NilError(t, err)
+	assert.Equal(t, len(changes), 0)
+	_, err = leader.Changes(base64.StdEncoding.EncodeToString([]byte("unknown!")))
+	assert.ErrorContains(t, err, "not retained")
+}
+
+func TestChangefeedAcrossErasedVersions(t *testing.T) {
+	leader := Make(time.Minute)
+	follower := Make(time.Minute)
+	since := leader.RootHash()
+	_, err := leader.Put("foo", "bar")
+	assert.NilError(t, err)
+	_, err = leader.Put("second", "value")
+	assert.NilError(t, err)
+	middle := leader.RootHash()
+	_, err = leader.Put("third", "value")
+	assert.NilError(t, err)
+	assert.NilError(t, leader.EraseRootHash(middle))
+
+	changes, err := leader.Changes(since)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, changes, []Change{{Key: "foo", Value: "bar"}, {Key: "second", Value: "value"}, {Key: "third", Value: "value"}})
+	root, err := follower.Apply(changes)
+	assert.NilError(t, err)
+	assert.Equal(t, root, leader.RootHash())
+}
+
+func TestConsistencyProof(t *testing.T) {
+	l := Make(time.Minute)
+	for i := 0; i < 50; i++ {
+		_, err := l.Put(strconv.Itoa(i), strconv.Itoa(i))
+		assert.NilError(t, err)
+	}
+	from := l.RootHash()
+	_, err := l.Put("3", "changed")
+	assert.NilError(t, err)
+	_, err = l.DeleteMulti([]string{"7", "42"})
+	assert.NilError(t, err)
+	_, err = l.Put("new", "key")
+	assert.NilError(t, err)
+	to := l.RootHash()
+
+	p, err := l.ProveConsistency(from, to)
+	assert.NilError(t, err)
+	assert.Equal(t, len(p.Changes), 4)
+	assert.NilError(t, VerifyConsistency(p))
+
+	// tampering with the changes is detected
+	p.Changes[0].Value = "forged"
+	assert.ErrorContains(t, VerifyConsistency(p), "results in root")
+	p.Changes[0].Value = "changed"
+
+	// tampering with the nodes is detected
+	batch := p.Nodes[from]
+	forged := append([][]byte(nil), batch...)
+	for i := 1; i < len(forged); i++ {
+		if len(forged[i]) != 0 {
+			forged[i] = append([]byte(nil), forged[i]...)
+			forged[i][0] ^= 0xff
+			break
+		}
+	}
+	p.Nodes[from] = forged
+	assert.ErrorContains(t, VerifyConsistency(p), "inconsistent with root")
+	p.Nodes[from] = batch
+	assert.NilError(t, VerifyConsistency(p))
+
+	_, err = l.ProveConsistency(to, from)
+	assert.ErrorContains(t, err, "not retained before")
+}
+
+func TestConsistencyProofAcrossErasedVersions(t *testing.T) {
+	l := Make(time.Minute)
+	_, err := l.Put("foo", "bar")
+	assert.NilError(t, err)
+	from := l.RootHash()
+	_, err = l.Put("foo", "baz")
+	assert.NilError(t, err)
+	middle := l.RootHash()
+	_, err = l.Put("second", "value")
+	assert.NilError(t, err)
+	to := l.RootHash()
+	assert.NilError(t, l.EraseRootHash(middle))
+
+	p, err := l.ProveConsistency(from, to)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, p.Changes, []Change{{Key: "foo", Value: "baz"}, {Key: "second", Value: "value"}})
+	assert.NilError(t, VerifyConsistency(p))
+}
+
+func TestTimeTravel(t *testing.T) {
+	l := Make(time.Minute)
+	created := time.Now()
+	_, err := l.RootHashAtTime(created.Add(-time.Hour))
+	assert.ErrorContains(t, err, "is retained")
+
+	_, err = l.Put("foo", "bar")
+	assert.NilError(t, err)
+	time.Sleep(time.Millisecond)
+	between := time.Now()
+	time.Sleep(time.Millisecond)
+	_, err = l.Put("foo", "baz")
+	assert.NilError(t, err)
+
+	value, err := l.GetAt(between, "foo")
+	assert.NilError(t, err)
+	assert.Equal(t, value, "bar")
+	value, err = l.GetAt(time.Now(), "foo")
+	assert.NilError(t, err)
+	assert.Equal(t, value, "baz")
+	root, err := l.RootHashAtTime(time.Now())
+	assert.NilError(t, err)
+	assert.Equal(t, root, l.RootHash())
+}
+
+func TestVerifyIntegrity(t *testing.T) {
+	l := Make(time.Minute)
+	for i := 0; i < 50; i++ {
+		_, err := l.Put(strconv.Itoa(i), strconv.Itoa(i))
+		assert.NilError(t, err)
+	}
+	_, err := l.DeleteMulti([]string{"1", "2", "3"})
+	assert.NilError(t, err)
+	assert.NilError(t, l.VerifyIntegrity())
+
+	// corrupt a node of the current version
+	s := l.(*smtLedger)
+	var node hash
+	copy(node[:], s.tree.root)
+	batch, ok := s.tree.db.updatedNodes.Get(node)
+	assert.Assert(t, ok)
+	corrupted := append([][]byte(nil), batch...)
+	corrupted[1] = append([]byte(nil), corrupted[1]...)
+	corrupted[1][0] ^= 0xff
+	s.tree.db.updatedNodes.Set(node, corrupted)
+
+	err = l.VerifyIntegrity()
+	ierr, ok := err.(*IntegrityError)
+	assert.Assert(t, ok, "unexpected error %v", err)
+	assert.Assert(t, len(ierr.Inconsistencies) > 0)
+	assert.Equal(t, ierr.Inconsistencies[0].Node, fmt.Sprintf("%x", s.tree.root[:hashLength]))
+	assert.Equal(t, ierr.Inconsistencies[0].Height, s.tree.trieHeight)
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ledger
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+
+	"istio.io/pkg/cache"
+)
+
+// ConsistencyProof demonstrates that a version of a Ledger was derived from a previous version by a set of changes.
+// Besides the changes, it only holds the trie nodes of the previous version along the paths of the changed keys,
+// so it can be verified by a remote auditor who knows both root hashes without transferring the ledger's state.
+type ConsistencyProof struct {
+	FromRoot string
+	ToRoot   string
+	// Changes were applied to FromRoot, in order, to produce ToRoot.
+	Changes []Change
+	// Nodes are the stored trie nodes of FromRoot traversed by the changed keys, keyed by base64 encoded hash.
+	Nodes map[string][][]byte
+}
+
+// ProveConsistency returns a proof that toRoot was derived from fromRoot by the changes recorded in between.
+// Both versions must still be retained, and fromRoot must precede toRoot.
+func (s *smtLedger) ProveConsistency(fromRoot, toRoot string) (*ConsistencyProof, error) {
+	from, err := base64.StdEncoding.DecodeString(fromRoot)
+	if err != nil {
+		return nil, err
+	}
+	to, err := base64.StdEncoding.DecodeString(toRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	s.history.RLock()
+	defer s.history.RUnlock()
+	j, err := s.history.find(to)
+	if err != nil {
+		return nil, err
+	}
+	i := j
+	for i >= 0 && !bytes.Equal(s.history.versions[i].root, from) {
+		i--
+	}
+	if i < 0 {
+		return nil, fmt.Errorf("version %s of the ledger is not retained before version %s", fromRoot, toRoot)
+	}
+	p := &ConsistencyProof{FromRoot: fromRoot, ToRoot: toRoot, Nodes: make(map[string][][]byte)}
+	for _, v := range s.history.versions[i+1 : j+1] {
+		p.Changes = append(p.Changes, v.changes...)
+	}
+
+	keys, _ := trieUpdates(p.Changes)
+	s.tree.lock.RLock()
+	nodes, err := s.tree.proofNodes(from, keys)
+	s.tree.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	for node, batch := range nodes {
+		p.Nodes[base64.StdEncoding.EncodeToString(node[:])] = batch
+	}
+	return p, nil
+}
+
+// VerifyConsistency checks a proof produced by Ledger.ProveConsistency. It returns an error unless the nodes of the
+// proof are consistent with FromRoot, and applying the changes of the proof to them results in ToRoot.
+func VerifyConsistency(p *ConsistencyProof) error {
+	from, err := base64.StdEncoding.DecodeString(p.FromRoot)
+	if err != nil {
+		return err
+	}
+	to, err := base64.StdEncoding.DecodeString(p.ToRoot)
+	if err != nil {
+		return err
+	}
+
+	// rebuild the partial trie of FromRoot from the proof
+	tree := newSMT(hasher, cache.NewTTL(forever, 0), forever)
+	for k, batch := range p.Nodes {
+		b, err := base64.StdEncoding.DecodeString(k)
+		if err != nil {
+			return err
+		}
+		if len(b) != hashLength || len(batch) != batchLen {
+			return fmt.Errorf("proof node %s is malformed", k)
+		}
+		var node hash
+		copy(node[:], b)
+		tree.db.updatedNodes.Set(node, batch)
+	}
+	var problems []string
+	tree.verify(from, &verifier{partial: true, report: func(node []byte, height int, problem string) {
+		problems = append(problems, fmt.Sprintf("%x at height %d: %s", node, height, problem))
+	}})
+	if len(problems) > 0 {
+		return fmt.Errorf("proof nodes are inconsistent with root %s: %v", p.FromRoot, problems)
+	}
+
+	tree.root = from
+	keys, values := trieUpdates(p.Changes)
+	root, err := tree.Update(keys, values)
+	if err != nil {
+		return fmt.Errorf("proof is incomplete: %v", err)
+	}
+	if !bytes.Equal(root, to) {
+		return fmt.Errorf("applying the changes of the proof results in root %s, not %s",
+			base64.StdEncoding.EncodeToString(root), p.ToRoot)
+	}
+	return nil
+}
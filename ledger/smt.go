@@ -81,17 +81,16 @@ const forever time.Duration = 1<<(63-1) - 1
 // newSMT creates a new smt given a keySize, hash function, cache (nil will be defaulted to TTLCache), and retention
 // duration for old nodes.
 func newSMT(hash func(data ...[]byte) []byte, updateCache cache.ExpiringCache, retentionDuration time.Duration) *smt {
-	if updateCache == nil {
-		updateCache = cache.NewTTL(forever, time.Second)
-	}
 	s := &smt{
 		hash:              hash,
 		trieHeight:        len(hash([]byte("height"))) * 8, // hash any string to get output length
 		retentionDuration: retentionDuration,
+		db:                &cacheDB{},
 	}
-	s.db = &cacheDB{
-		updatedNodes: byteCache{cache: updateCache},
+	if updateCache == nil {
+		updateCache = cache.NewTTL(forever, time.Second)
 	}
+	s.db.updatedNodes = byteCache{cache: updateCache}
 	s.loadDefaultHashes()
 	return s
 }
@@ -219,8 +219,30 @@ func TestTrieUpdateAndDelete(t *testing.T) {
 	_, err := smt.Update(keys, values)
 	assert.NilError(t, err)
 }
-func bitSet(bits []byte, i int) {
-	bits[i/8] |= 1 << uint(7-i%8)
+
+func TestSmtEvictedNodes(t *testing.T) {
+	// the nodes evicted by a cache supplied by the caller are accounted for too
+	c := cache.NewTTL(forever, 0)
+	smt := newSMT(hasher, c, 0)
+	smt.atomicUpdate = false
+
+	keys := getFreshData(10)
+	ch := make(chan result, 1)
+	smt.update(smt.root, keys, getFreshData(10), nil, 0, smt.trieHeight, false, true, ch)
+	root := (<-ch).update
+	smt.update(root, keys, getFreshData(10), nil, 0, smt.trieHeight, false, true, ch)
+	root = (<-ch).update
+
+	total, orphaned, err := smt.orphanedNodes(root)
+	assert.NilError(t, err)
+	assert.Assert(t, orphaned > 0)
+	assert.Equal(t, total, uint64(c.Len()))
+
+	c.EvictExpired()
+	after, afterOrphaned, err := smt.orphanedNodes(root)
+	assert.NilError(t, err)
+	assert.Equal(t, afterOrphaned, uint64(0))
+	assert.Equal(t, after, total-orphaned)
 }
 
 func TestSmtRaisesError(t *testing.T) {
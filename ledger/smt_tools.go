@@ -16,6 +16,10 @@ package ledger
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 )
 
 // Get fetches the value of a key by going down the current trie root.
@@ -62,3 +66,166 @@ func (s *smt) get(root []byte, key []byte, batch [][]byte, iBatch, height int) (
 func (s *smt) DefaultHash(height int) []byte {
 	return s.defaultHashes[height]
 }
+
+// Dump writes the nodes reachable from root to w, one node per line, indented by depth.
+func (s *smt) Dump(root []byte, w io.Writer) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if len(root) == 0 {
+		_, err := fmt.Fprintln(w, "<empty>")
+		return err
+	}
+	return s.walk(root, func(n trieNode) error {
+		depth := s.trieHeight - n.height
+		indent := strings.Repeat("  ", depth)
+		stored := ""
+		if n.stored {
+			stored = " stored"
+		}
+		var err error
+		if n.leaf {
+			_, err = fmt.Fprintf(w, "%sleaf %x depth=%d%s key=%x value=%x\n", indent, n.hash, depth, stored, n.key, n.value)
+		} else {
+			_, err = fmt.Fprintf(w, "%snode %x depth=%d%s\n", indent, n.hash, depth, stored)
+		}
+		return err
+	})
+}
+
+// trieNode describes a single node of the trie encountered during a walk.
+type trieNode struct {
+	// hash of the node
+	hash []byte
+	// height of the node in the trie, leaves being at height 0
+	height int
+	// stored is true if the node is the root of a batch held in the db
+	stored bool
+	// leaf is true if the node holds a single key, either at height 0 or as a shortcut node
+	leaf bool
+	// key and value of a leaf node
+	key, value []byte
+}
+
+// errSkip may be returned by a walk visitor to skip the children of the visited node.
+var errSkip = errors.New("skip children")
+
+// walk visits every node reachable from root, parents before children and left before right.
+// The caller must hold the trie lock.
+func (s *smt) walk(root []byte, visit func(n trieNode) error) error {
+	return s.walkNode(root, nil, 0, s.trieHeight, make([]byte, hashLength), visit)
+}
+
+// walkNode visits root and its children. path holds the bits of the keys below root.
+func (s *smt) walkNode(root []byte, batch [][]byte, iBatch, height int, path []byte, visit func(n trieNode) error) error {
+	if len(root) == 0 {
+		return nil
+	}
+	n := trieNode{hash: root[:hashLength], height: height, stored: height%4 == 0}
+	if height == 0 {
+		n.leaf, n.key, n.value = true, path, root[:hashLength]
+		return skipped(visit(n))
+	}
+	batch, iBatch, lnode, rnode, isShortcut, err := s.loadChildren(root, height, iBatch, batch)
+	if err != nil {
+		return err
+	}
+	if isShortcut {
+		n.leaf, n.key, n.value = true, lnode[:hashLength], rnode[:hashLength]
+		return skipped(visit(n))
+	}
+	if err = visit(n); err != nil {
+		return skipped(err)
+	}
+	if err = s.walkNode(lnode, batch, 2*iBatch+1, height-1, path, visit); err != nil {
+		return err
+	}
+	rpath := make([]byte, len(path))
+	copy(rpath, path)
+	bitSet(rpath, s.trieHeight-height)
+	return s.walkNode(rnode, batch, 2*iBatch+2, height-1, rpath, visit)
+}
+
+// skipped converts errSkip to nil, since there is nothing left to walk below the visited node.
+func skipped(err error) error {
+	if err == errSkip {
+		return nil
+	}
+	return err
+}
+
+// storedNodes returns the set of stored nodes reachable from any of roots.
+func (s *smt) storedNodes(roots ...[]byte) (map[hash]struct{}, error) {
+	nodes := make(map[hash]struct{})
+	for _, root := range roots {
+		err := s.walk(root, func(n trieNode) error {
+			if !n.stored {
+				return nil
+			}
+			var node hash
+			copy(node[:], n.hash)
+			if _, seen := nodes[node]; seen {
+				// the subtree below a stored node is fully determined by its hash
+				return errSkip
+			}
+			nodes[node] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// orphanedNodes returns the number of nodes held in the db, and how many of them are not reachable
+// from any of roots.
+func (s *smt) orphanedNodes(roots ...[]byte) (total, orphaned uint64, err error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	reachable, err := s.storedNodes(roots...)
+	if err != nil {
+		return 0, 0, err
+	}
+	total = uint64(s.db.updatedNodes.Len())
+	if total > uint64(len(reachable)) {
+		orphaned = total - uint64(len(reachable))
+	}
+	return total, orphaned, nil
+}
+
+// erase removes from the db every stored node reachable from roots which is not reachable from one of retained.
+// It returns the number of nodes removed.
+func (s *smt) erase(roots [][]byte, retained [][]byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	live, err := s.storedNodes(retained...)
+	if err != nil {
+		return 0, err
+	}
+	var doomed []hash
+	for _, root := range roots {
+		err = s.walk(root, func(n trieNode) error {
+			if !n.stored {
+				return nil
+			}
+			var node hash
+			copy(node[:], n.hash)
+			if _, ok := live[node]; ok {
+				return errSkip
+			}
+			// mark the node as live so that shared subtrees of the erased roots are only visited once
+			live[node] = struct{}{}
+			doomed = append(doomed, node)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	s.db.updatedMux.Lock()
+	for _, node := range doomed {
+		s.db.updatedNodes.Remove(node)
+	}
+	s.db.updatedMux.Unlock()
+	return len(doomed), nil
+}
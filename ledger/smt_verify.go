@@ -0,0 +1,126 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ledger
+
+import (
+	"bytes"
+)
+
+// verifier recomputes the hashes of trie nodes.
+type verifier struct {
+	// partial is true if nodes which are not in the db should be treated as opaque hashes, rather
+	// than reported as missing.
+	partial bool
+	// report is called for each inconsistent node.
+	report func(node []byte, height int, problem string)
+	// verified holds the stored nodes already verified, whose subtrees don't need to be checked again.
+	verified map[hash]struct{}
+}
+
+// verify recomputes the hash of every node reachable from root, and reports each node whose hash doesn't
+// match its children. The caller must hold the trie lock.
+func (s *smt) verify(root []byte, v *verifier) {
+	if v.verified == nil {
+		v.verified = make(map[hash]struct{})
+	}
+	s.verifyNode(root, nil, 0, s.trieHeight, v)
+}
+
+func (s *smt) verifyNode(root []byte, batch [][]byte, iBatch, height int, v *verifier) {
+	if len(root) == 0 || height == 0 {
+		return
+	}
+	batch, iBatch, lnode, rnode, isShortcut, err := s.loadChildren(root, height, iBatch, batch)
+	if err != nil {
+		if !v.partial {
+			v.report(root[:hashLength], height, "node is missing from the db")
+		}
+		return
+	}
+	if height%4 == 0 {
+		var node hash
+		copy(node[:], root)
+		if _, ok := v.verified[node]; ok {
+			return
+		}
+		v.verified[node] = struct{}{}
+	}
+	if isShortcut {
+		if !bytes.Equal(s.leafHash(lnode[:hashLength], rnode[:hashLength], height), root[:hashLength]) {
+			v.report(root[:hashLength], height, "shortcut hash doesn't match its key and value")
+		}
+		return
+	}
+	left, right := s.defaultHashes[height-1], s.defaultHashes[height-1]
+	if len(lnode) != 0 {
+		left = lnode[:hashLength]
+	}
+	if len(rnode) != 0 {
+		right = rnode[:hashLength]
+	}
+	if !bytes.Equal(s.hash(left, right), root[:hashLength]) {
+		v.report(root[:hashLength], height, "node hash doesn't match its children")
+	}
+	s.verifyNode(lnode, batch, 2*iBatch+1, height-1, v)
+	s.verifyNode(rnode, batch, 2*iBatch+2, height-1, v)
+}
+
+// leafHash computes the hash of a subtree at height holding a single key.
+func (s *smt) leafHash(key, value []byte, height int) []byte {
+	h := value
+	for i := 1; i <= height; i++ {
+		if bitIsSet(key, s.trieHeight-i) {
+			h = s.hash(s.defaultHashes[i-1], h)
+		} else {
+			h = s.hash(h, s.defaultHashes[i-1])
+		}
+	}
+	return h
+}
+
+// proofNodes returns the stored nodes traversed when looking up each of keys from root.
+// The caller must hold the trie lock.
+func (s *smt) proofNodes(root []byte, keys [][]byte) (map[hash][][]byte, error) {
+	nodes := make(map[hash][][]byte)
+	for _, key := range keys {
+		if err := s.trace(root, key, nil, 0, s.trieHeight, nodes); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// trace follows the path of key below root, adding the stored nodes to nodes.
+func (s *smt) trace(root, key []byte, batch [][]byte, iBatch, height int, nodes map[hash][][]byte) error {
+	if len(root) == 0 || height == 0 {
+		return nil
+	}
+	batch, iBatch, lnode, rnode, isShortcut, err := s.loadChildren(root, height, iBatch, batch)
+	if err != nil {
+		return err
+	}
+	if height%4 == 0 {
+		var node hash
+		copy(node[:], root)
+		nodes[node] = append([][]byte(nil), batch...)
+	}
+	if isShortcut {
+		return nil
+	}
+	if bitIsSet(key, s.trieHeight-height) {
+		return s.trace(rnode, key, batch, 2*iBatch+2, height-1, nodes)
+	}
+	return s.trace(lnode, key, batch, 2*iBatch+1, height-1, nodes)
+}
@@ -60,3 +60,13 @@ func (b *byteCache) Get(key hash) (value [][]byte, ok bool) {
 func (b *byteCache) SetWithExpiration(key hash, value [][]byte, expiration time.Duration) {
 	b.cache.SetWithExpiration(key, value, expiration)
 }
+
+// Remove synchronously deletes the given key from the cache.
+func (b *byteCache) Remove(key hash) {
+	b.cache.Remove(key)
+}
+
+// Len returns the number of entries currently held in the cache.
+func (b *byteCache) Len() int {
+	return b.cache.Len()
+}
@@ -35,6 +35,10 @@ func bitIsSet(bits []byte, i int) bool {
 	return bits[i/8]&(1<<uint(7-i%8)) != 0
 }
 
+func bitSet(bits []byte, i int) {
+	bits[i/8] |= 1 << uint(7-i%8)
+}
+
 func hasher(data ...[]byte) []byte {
 	var hasher = murmur3.New64()
 	for i := 0; i < len(data); i++ {
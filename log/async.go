@@ -0,0 +1,133 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// asyncRecord is either encoded log data, or a request to sync the sink when flushed is set.
+type asyncRecord struct {
+	data    []byte
+	flushed chan error
+}
+
+// asyncSink hands writes over to a goroutine writing them to the wrapped sink, such that callers never
+// wait on slow disks. Writes made while the buffer is full are dropped and counted, rather than
+// blocking callers. The goroutine is started by the first write, and exits once the sink is stopped.
+type asyncSink struct {
+	dropped uint64
+
+	sink    zapcore.WriteSyncer
+	records chan asyncRecord
+	stopCh  chan struct{}
+	start   sync.Once
+	stop    sync.Once
+	done    chan struct{}
+}
+
+func newAsyncSink(sink zapcore.WriteSyncer, size int) *asyncSink {
+	return &asyncSink{
+		sink:    sink,
+		records: make(chan asyncRecord, size),
+		stopCh:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (s *asyncSink) Write(p []byte) (int, error) {
+	s.start.Do(func() { go s.run() })
+
+	// the encoder reuses its buffer once this returns
+	data := make([]byte, len(p))
+	copy(data, p)
+
+	select {
+	case s.records <- asyncRecord{data: data}:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+		droppedRecords.Increment()
+	}
+
+	return len(p), nil
+}
+
+// Sync waits for the records buffered so far to be written, then syncs the wrapped sink.
+func (s *asyncSink) Sync() error {
+	select {
+	case <-s.done:
+		return s.sink.Sync()
+	default:
+	}
+
+	s.start.Do(func() { go s.run() })
+	flushed := make(chan error, 1)
+	select {
+	case s.records <- asyncRecord{flushed: flushed}:
+	case <-s.done:
+		return s.sink.Sync()
+	}
+
+	select {
+	case err := <-flushed:
+		return err
+	case <-s.done:
+		return s.sink.Sync()
+	}
+}
+
+// Stop writes out the buffered records and makes the goroutine exit. Records written afterwards
+// are dropped once the buffer fills up.
+func (s *asyncSink) Stop() {
+	s.stop.Do(func() {
+		close(s.stopCh)
+		s.start.Do(func() { close(s.done) })
+	})
+	<-s.done
+}
+
+func (s *asyncSink) run() {
+	defer close(s.done)
+
+	for {
+		select {
+		case r := <-s.records:
+			s.handle(r)
+		case <-s.stopCh:
+			for {
+				select {
+				case r := <-s.records:
+					s.handle(r)
+				default:
+					_ = s.sink.Sync()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *asyncSink) handle(r asyncRecord) {
+	if r.flushed != nil {
+		r.flushed <- s.sink.Sync()
+		return
+	}
+
+	// there's no caller left to report errors to
+	_, _ = s.sink.Write(r.data)
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// gatedSink blocks writes until it is opened.
+type gatedSink struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	gate chan struct{}
+}
+
+func (g *gatedSink) Write(p []byte) (int, error) {
+	<-g.gate
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.buf.Write(p)
+}
+
+func (g *gatedSink) Sync() error {
+	return nil
+}
+
+func (g *gatedSink) String() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.buf.String()
+}
+
+func TestAsyncSink(t *testing.T) {
+	g := &gatedSink{gate: make(chan struct{})}
+	s := newAsyncSink(g, 2)
+	defer s.Stop()
+
+	// the first write is picked up by the goroutine, which blocks on the gate, then the
+	// buffer fills up and later writes are dropped
+	buf := []byte("0\n")
+	_, _ = s.Write(buf)
+	for atomic.LoadUint64(&s.dropped) == 0 {
+		buf[0]++
+		if n, err := s.Write(buf); n != len(buf) || err != nil {
+			t.Fatalf("Got %d, %v, expecting writes to always succeed", n, err)
+		}
+	}
+
+	close(g.gate)
+	if err := s.Sync(); err != nil {
+		t.Errorf("Got %v, expecting success", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(g.String()), "\n")
+	dropped := atomic.LoadUint64(&s.dropped)
+	if int(dropped)+len(lines) != int(buf[0]-'0')+1 || lines[0] != "0" {
+		t.Errorf("Got %v written and %d dropped, expecting every write to be one or the other", lines, dropped)
+	}
+}
+
+func TestAsyncStop(t *testing.T) {
+	g := &gatedSink{gate: make(chan struct{})}
+	close(g.gate)
+
+	s := newAsyncSink(g, 10)
+	s.Stop()
+	for i := 0; i < 3; i++ {
+		_, _ = s.Write([]byte("HELLO\n"))
+	}
+	if err := s.Sync(); err != nil || g.String() != "" {
+		t.Errorf("Got %v, %q, expecting nothing to be written once stopped", err, g.String())
+	}
+
+	s = newAsyncSink(g, 10)
+	_, _ = s.Write([]byte("HELLO\n"))
+	s.Stop()
+	if g.String() != "HELLO\n" {
+		t.Errorf("Got %q, expecting the buffered records to be written when stopping", g.String())
+	}
+}
+
+func TestAsyncConfigure(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "TestAsyncConfigure")
+	defer os.RemoveAll(dir)
+
+	file := dir + "/async.log"
+
+	o := DefaultOptions()
+	o.OutputPaths = []string{}
+	o.RotateOutputPath = file
+	o.AsyncBufferSize = 100
+	if err := Configure(o); err != nil {
+		t.Fatalf("Unable to configure logging: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		defaultScope.Error("HELLO")
+	}
+	_ = Sync()
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Errorf("Got failure '%v', expecting success", err)
+	}
+	if n := strings.Count(string(content), "HELLO"); n != 10 {
+		t.Errorf("Got %d records, expecting 10 once synced", n)
+	}
+
+	_ = Configure(DefaultOptions())
+}
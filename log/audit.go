@@ -0,0 +1,344 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"istio.io/pkg/ledger"
+)
+
+// The fields chaining the records of the audit log together.
+const (
+	auditSeqKey  = "audit_seq"
+	auditPrevKey = "audit_prev"
+	auditHeadKey = "audit_head"
+	auditRootKey = "audit_root"
+
+	auditCheckpointMessage = "audit checkpoint"
+
+	defaultAuditCheckpointInterval = time.Minute
+
+	// how long the ledger retains previous checkpoints
+	auditRetention = 24 * time.Hour
+)
+
+// auditCore writes the records of the audited scopes to the audit log, see auditChain.
+type auditCore struct {
+	enc    zapcore.Encoder
+	scopes map[string]bool
+	chain  *auditChain
+}
+
+func newAuditCore(enc zapcore.Encoder, chain *auditChain, scopes []string) zapcore.Core {
+	c := &auditCore{
+		enc:    enc,
+		scopes: make(map[string]bool, len(scopes)),
+		chain:  chain,
+	}
+	for _, s := range scopes {
+		c.scopes[s] = true
+	}
+	return c
+}
+
+func (c *auditCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *auditCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+	return &clone
+}
+
+func (c *auditCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.audited(ent) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *auditCore) audited(ent zapcore.Entry) bool {
+	scope := ent.LoggerName
+	if scope == "" {
+		scope = DefaultScopeName
+	}
+	return c.scopes[scope]
+}
+
+func (c *auditCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.audited(ent) {
+		return nil
+	}
+
+	c.chain.mu.Lock()
+	defer c.chain.mu.Unlock()
+	return c.chain.append(c.enc, ent, fields)
+}
+
+func (c *auditCore) Sync() error {
+	return c.chain.sink.Sync()
+}
+
+// auditChain writes JSON records to an audit log, chained together: each record holds a sequence number
+// and the hash of the previous record, such that a record can't be modified, removed or inserted without
+// breaking the chain. The head of the chain is periodically recorded in a ledger, and the root hash of
+// the ledger is written to the audit log as a checkpoint record, as well as output through the default
+// scope, such that it can be kept apart from the audit log. See VerifyAuditLog.
+//
+// Chains are shared by the cores writing to the same audit log, such that reconfiguring logging doesn't
+// fork them, and resumed from the audit log when it already exists.
+type auditChain struct {
+	path string
+	refs int // guarded by auditChainsMu
+
+	mu     sync.Mutex
+	sink   zapcore.WriteSyncer
+	close  func()
+	enc    zapcore.Encoder
+	seq    uint64
+	prev   string
+	ledger ledger.Ledger
+
+	// the sequence number of the last checkpoint
+	checkpointed uint64
+
+	interval chan time.Duration
+	stopCh   chan struct{}
+	done     chan struct{}
+}
+
+var (
+	auditChains   = make(map[string]*auditChain)
+	auditChainsMu sync.Mutex
+)
+
+// acquireAuditChain returns the chain writing to the audit log at path, creating it if needed. The chain
+// must be released once it's no longer used.
+func acquireAuditChain(path string, enc zapcore.Encoder, interval time.Duration) (*auditChain, error) {
+	if interval <= 0 {
+		interval = defaultAuditCheckpointInterval
+	}
+
+	auditChainsMu.Lock()
+	defer auditChainsMu.Unlock()
+
+	if a, ok := auditChains[path]; ok {
+		a.refs++
+		a.interval <- interval
+		return a, nil
+	}
+
+	a := &auditChain{
+		path:     path,
+		refs:     1,
+		enc:      enc,
+		ledger:   ledger.Make(auditRetention),
+		interval: make(chan time.Duration),
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if path == "stdout" || path == "stderr" {
+		// nothing to resume from
+	} else if f, err := os.Open(path); err == nil {
+		err = a.replay(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to resume audit log %s: %v", path, err)
+		}
+	}
+
+	sink, closeSink, err := zap.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	a.sink, a.close = sink, closeSink
+
+	auditChains[path] = a
+	go a.run(interval)
+	return a, nil
+}
+
+// release stops the chain once its last user releases it, after writing a final checkpoint.
+func (a *auditChain) release() {
+	auditChainsMu.Lock()
+	a.refs--
+	last := a.refs == 0
+	if last {
+		delete(auditChains, a.path)
+	}
+	auditChainsMu.Unlock()
+
+	if last {
+		close(a.stopCh)
+		<-a.done
+		a.close()
+	}
+}
+
+// append writes a record to the audit log, chaining it to the previous record. The caller holds a.mu.
+func (a *auditChain) append(enc zapcore.Encoder, ent zapcore.Entry, fields []zapcore.Field) error {
+	chained := make([]zapcore.Field, 0, len(fields)+2)
+	chained = append(chained, fields...)
+	chained = append(chained, zap.Uint64(auditSeqKey, a.seq+1), zap.String(auditPrevKey, a.prev))
+
+	buf, err := enc.EncodeEntry(ent, chained)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	if _, err := a.sink.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	a.seq++
+	a.prev = auditHash(buf.Bytes())
+	return nil
+}
+
+// checkpoint records the head of the chain in the ledger, and writes the resulting root hash to the
+// audit log. This does nothing if no records were written since the last checkpoint.
+func (a *auditChain) checkpoint() {
+	a.mu.Lock()
+	if a.seq == a.checkpointed {
+		a.mu.Unlock()
+		return
+	}
+
+	head, seq := a.prev, a.seq
+	root, err := a.ledger.Apply([]ledger.Change{{Key: auditCheckpointKey(seq), Value: head}})
+	if err == nil {
+		ent := zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Now(), Message: auditCheckpointMessage}
+		err = a.append(a.enc, ent, []zapcore.Field{zap.String(auditHeadKey, head), zap.String(auditRootKey, root)})
+	}
+	if err == nil {
+		a.checkpointed = a.seq
+	}
+	a.mu.Unlock()
+
+	if err != nil {
+		Errorf("unable to checkpoint audit log %s: %v", a.path, err)
+		return
+	}
+	Infow(auditCheckpointMessage, "path", a.path, auditSeqKey, seq, auditHeadKey, head, auditRootKey, root)
+}
+
+func (a *auditChain) run(interval time.Duration) {
+	defer close(a.done)
+
+	t := time.NewTicker(interval)
+	defer func() { t.Stop() }()
+
+	for {
+		select {
+		case <-t.C:
+			a.checkpoint()
+		case i := <-a.interval:
+			t.Stop()
+			t = time.NewTicker(i)
+		case <-a.stopCh:
+			a.checkpoint()
+			_ = a.sink.Sync()
+			return
+		}
+	}
+}
+
+// replay verifies the records of an audit log, leaving the chain at the last of them.
+func (a *auditChain) replay(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec struct {
+			Seq  uint64 `json:"audit_seq"`
+			Prev string `json:"audit_prev"`
+			Head string `json:"audit_head"`
+			Root string `json:"audit_root"`
+		}
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("invalid audit record %d: %v", a.seq+1, err)
+		}
+
+		if rec.Seq != a.seq+1 {
+			return fmt.Errorf("audit record %d is followed by record %d", a.seq, rec.Seq)
+		}
+		if rec.Prev != a.prev {
+			return fmt.Errorf("audit record %d isn't chained to the previous record", rec.Seq)
+		}
+
+		if rec.Root != "" {
+			if rec.Head != a.prev {
+				return fmt.Errorf("audit checkpoint %d doesn't match the chain", rec.Seq)
+			}
+			root, err := a.ledger.Apply([]ledger.Change{{Key: auditCheckpointKey(a.seq), Value: rec.Head}})
+			if err != nil {
+				return err
+			}
+			if root != rec.Root {
+				return fmt.Errorf("audit checkpoint %d has root hash %s, expecting %s", rec.Seq, rec.Root, root)
+			}
+			a.checkpointed = rec.Seq
+		}
+
+		a.seq = rec.Seq
+		a.prev = auditHash(append(line, '\n'))
+	}
+
+	return scanner.Err()
+}
+
+func auditHash(record []byte) string {
+	h := sha256.Sum256(record)
+	return hex.EncodeToString(h[:])
+}
+
+func auditCheckpointKey(seq uint64) string {
+	return fmt.Sprintf("checkpoint/%020d", seq)
+}
+
+// VerifyAuditLog checks that an audit log written through Options.AuditOutputPath wasn't tampered
+// with: records must be chained to the records preceding them, and the root hashes of checkpoints
+// must match the ones of a ledger rebuilt from the log. This returns the root hash of the last
+// checkpoint, which should be compared to the root hashes output through the default scope to
+// detect the truncation or the wholesale rewriting of the log.
+func VerifyAuditLog(r io.Reader) (string, error) {
+	a := &auditChain{ledger: ledger.Make(auditRetention)}
+	if err := a.replay(r); err != nil {
+		return "", err
+	}
+	return a.ledger.RootHash(), nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	resetGlobals()
+	authz := RegisterScope("authz", "For testing", 0)
+	other := RegisterScope("other", "For testing", 0)
+
+	dir, _ := ioutil.TempDir("", "TestAuditLog")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "audit.log")
+
+	o := DefaultOptions()
+	o.LogGrpc = false
+	o.AuditOutputPath = file
+	o.AuditScopes = []string{"authz"}
+	o.AuditCheckpointInterval = time.Hour
+
+	stdout, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+		authz.Infow("allowed", "principal", "spiffe://cluster.local/ns/default/sa/a")
+		other.Info("not audited")
+
+		// reconfiguring keeps the chain going
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+		authz.Warnw("denied", "principal", "spiffe://cluster.local/ns/default/sa/b")
+		_ = Configure(DefaultOptions())
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+
+	content, _ := ioutil.ReadFile(file)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "allowed") || !strings.Contains(lines[1], "denied") {
+		t.Fatalf("Got %v, expecting the audited records and a checkpoint", lines)
+	}
+
+	var checkpoint map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[2]), &checkpoint); err != nil || checkpoint["msg"] != auditCheckpointMessage {
+		t.Fatalf("Got %v, %v, expecting a checkpoint", checkpoint, err)
+	}
+
+	root, err := VerifyAuditLog(bytes.NewReader(content))
+	if err != nil || root != checkpoint[auditRootKey] {
+		t.Errorf("Got %v, %v, expecting the root hash of the checkpoint %v", root, err, checkpoint[auditRootKey])
+	}
+	if !strings.Contains(strings.Join(stdout, "\n"), root) {
+		t.Errorf("Got %v, expecting the root hash to be output through the default scope", stdout)
+	}
+
+	// existing audit logs are resumed
+	o.AuditCheckpointInterval = 10 * time.Millisecond
+	_, _ = captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+		authz.Info("allowed again")
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			content, _ = ioutil.ReadFile(file)
+			if strings.Count(string(content), auditCheckpointMessage) == 2 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for a periodic checkpoint")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		_ = Configure(DefaultOptions())
+	})
+
+	content, _ = ioutil.ReadFile(file)
+	if _, err := VerifyAuditLog(bytes.NewReader(content)); err != nil {
+		t.Errorf("Got %v, expecting the resumed audit log to verify", err)
+	}
+
+	for _, tamper := range []func(string) string{
+		func(s string) string { return strings.Replace(s, "denied", "allowed", 1) },
+		func(s string) string { return s[strings.Index(s, "\n")+1:] },
+		func(s string) string {
+			lines := strings.Split(s, "\n")
+			return strings.Join(append(lines[:1], lines[2:]...), "\n")
+		},
+	} {
+		if _, err := VerifyAuditLog(strings.NewReader(tamper(string(content)))); err == nil {
+			t.Error("Got success, expecting the tampered audit log to fail verification")
+		}
+	}
+
+	_ = ioutil.WriteFile(file, []byte(strings.Replace(string(content), "denied", "allowed", 1)), 0644)
+	if err := Configure(o); err == nil {
+		t.Error("Got success, expecting the tampered audit log to fail to resume")
+	}
+	_ = Configure(DefaultOptions())
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"runtime"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// The special fields of structured log entries recognized by the Cloud Logging agents, see
+// https://cloud.google.com/logging/docs/structured-logging.
+const (
+	cloudLoggingSourceLocationKey = "logging.googleapis.com/sourceLocation"
+	cloudLoggingTraceKey          = "logging.googleapis.com/trace"
+	cloudLoggingSpanIDKey         = "logging.googleapis.com/spanId"
+)
+
+var cloudLoggingSeverities = map[zapcore.Level]string{
+	zapcore.DebugLevel:  "DEBUG",
+	zapcore.InfoLevel:   "INFO",
+	zapcore.WarnLevel:   "WARNING",
+	zapcore.ErrorLevel:  "ERROR",
+	zapcore.DPanicLevel: "CRITICAL",
+	zapcore.PanicLevel:  "ALERT",
+	zapcore.FatalLevel:  "EMERGENCY",
+}
+
+// cloudLoggingEncoder formats log records as the JSON structured log entries parsed by Cloud Logging.
+// The trace_id and span_id fields, see FromContext, are renamed to the fields Cloud Logging correlates traces with,
+// and the caller location is output as a sourceLocation.
+type cloudLoggingEncoder struct {
+	zapcore.Encoder
+	project string
+}
+
+func newCloudLoggingEncoder(encCfg zapcore.EncoderConfig, project string) zapcore.Encoder {
+	encCfg.TimeKey = "time"
+	encCfg.LevelKey = "severity"
+	encCfg.MessageKey = "message"
+	encCfg.StacktraceKey = "stack_trace"
+	encCfg.CallerKey = "" // output as a sourceLocation
+	encCfg.EncodeLevel = func(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(cloudLoggingSeverities[l])
+	}
+	encCfg.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		enc.AppendString(t.UTC().Format(time.RFC3339Nano))
+	}
+
+	return &cloudLoggingEncoder{
+		Encoder: zapcore.NewJSONEncoder(encCfg),
+		project: project,
+	}
+}
+
+func (e *cloudLoggingEncoder) Clone() zapcore.Encoder {
+	return &cloudLoggingEncoder{
+		Encoder: e.Encoder.Clone(),
+		project: e.project,
+	}
+}
+
+// AddString renames the trace fields added to derived cores.
+func (e *cloudLoggingEncoder) AddString(key, value string) {
+	key, value = e.rename(key, value)
+	e.Encoder.AddString(key, value)
+}
+
+func (e *cloudLoggingEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	renamed := make([]zapcore.Field, 0, len(fields)+1)
+	for _, f := range fields {
+		if f.Type == zapcore.StringType {
+			f.Key, f.String = e.rename(f.Key, f.String)
+		}
+		renamed = append(renamed, f)
+	}
+
+	if ent.Caller.Defined {
+		renamed = append(renamed, zap.Object(cloudLoggingSourceLocationKey, sourceLocation(ent.Caller)))
+	}
+
+	return e.Encoder.EncodeEntry(ent, renamed)
+}
+
+func (e *cloudLoggingEncoder) rename(key, value string) (string, string) {
+	switch key {
+	case traceIDKey:
+		if e.project != "" {
+			value = "projects/" + e.project + "/traces/" + value
+		}
+		return cloudLoggingTraceKey, value
+	case spanIDKey:
+		return cloudLoggingSpanIDKey, value
+	}
+	return key, value
+}
+
+// sourceLocation outputs the caller of a log record in the format of Cloud Logging.
+type sourceLocation zapcore.EntryCaller
+
+func (l sourceLocation) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("file", l.File)
+	enc.AddString("line", strconv.Itoa(l.Line))
+	if f := runtime.FuncForPC(l.PC); f != nil {
+		enc.AddString("function", f.Name())
+	}
+	return nil
+}
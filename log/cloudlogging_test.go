@@ -0,0 +1,99 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestCloudLogging(t *testing.T) {
+	resetGlobals()
+
+	o := DefaultOptions()
+	o.CloudLogging = true
+	o.CloudLoggingProject = "my-project"
+	o.SetLogCallers(DefaultScopeName, true)
+
+	lines, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+		Warnw("Hello", "trace_id", "4bf92f3577b34da6", "span_id", "00f067aa0ba902b7")
+		_ = Sync()
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	_ = Configure(DefaultOptions())
+
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatalf("Got %v, expecting %q to be JSON", err, lines[0])
+	}
+
+	if m["severity"] != "WARNING" || m["message"] != "Hello" {
+		t.Errorf("Got %v, expecting the Cloud Logging severity and message", m)
+	}
+	if ts, ok := m["time"].(string); !ok {
+		t.Errorf("Got %v, expecting a timestamp", m["time"])
+	} else if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+		t.Errorf("Got %v, expecting an RFC 3339 timestamp", err)
+	}
+	if m[cloudLoggingTraceKey] != "projects/my-project/traces/4bf92f3577b34da6" || m[cloudLoggingSpanIDKey] != "00f067aa0ba902b7" {
+		t.Errorf("Got %v, expecting the Cloud Logging trace fields", m)
+	}
+	if _, ok := m["trace_id"]; ok {
+		t.Errorf("Got %v, expecting trace_id to be renamed", m)
+	}
+
+	loc, ok := m[cloudLoggingSourceLocationKey].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Got %v, expecting a source location", m)
+	}
+	if file, _ := loc["file"].(string); !strings.HasSuffix(file, "cloudlogging_test.go") {
+		t.Errorf("Got file %v, expecting this file", loc["file"])
+	}
+	if line, _ := loc["line"].(string); line == "" {
+		t.Errorf("Got line %v, expecting a line number", loc["line"])
+	}
+	if fn, _ := loc["function"].(string); !strings.HasSuffix(fn, "TestCloudLogging.func1") {
+		t.Errorf("Got function %v, expecting this test", loc["function"])
+	}
+}
+
+func TestCloudLoggingEncoderWith(t *testing.T) {
+	enc := newCloudLoggingEncoder(zapcore.EncoderConfig{}, "")
+	enc = enc.Clone()
+	zap.String("trace_id", "4bf92f3577b34da6").AddTo(enc)
+
+	buf, err := enc.EncodeEntry(zapcore.Entry{Level: zapcore.FatalLevel, Message: "Hello"}, nil)
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("Got %v, expecting %q to be JSON", err, buf.String())
+	}
+	if m["severity"] != "EMERGENCY" || m[cloudLoggingTraceKey] != "4bf92f3577b34da6" {
+		t.Errorf("Got %v, expecting the Cloud Logging severity and trace", m)
+	}
+}
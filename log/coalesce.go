@@ -0,0 +1,145 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// coalescer tracks the last record written through a set of coalescing cores, and counts the
+// identical records following it.
+type coalescer struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	last     *coalescingCore // the core the last record was written through, nil once reported
+	ent      zapcore.Entry
+	fields   []zapcore.Field
+	repeated int
+	timer    *time.Timer
+	stopped  bool
+}
+
+// coalescingCore drops the records identical to the previous one which are written within the
+// window of the coalescer, and reports how many were dropped once a different record is written or
+// the window ends.
+type coalescingCore struct {
+	zapcore.Core
+	c *coalescer
+}
+
+func newCoalescingCore(core zapcore.Core, window time.Duration) *coalescingCore {
+	return &coalescingCore{Core: core, c: &coalescer{window: window}}
+}
+
+func (cc *coalescingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &coalescingCore{Core: cc.Core.With(fields), c: cc.c}
+}
+
+func (cc *coalescingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if cc.Enabled(ent.Level) {
+		return ce.AddCore(ent, cc)
+	}
+	return ce
+}
+
+func (cc *coalescingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c := cc.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ent.Level < zapcore.FatalLevel && c.last == cc && ent.Time.Sub(c.ent.Time) < c.window && c.identical(ent, fields) {
+		c.repeated++
+		return nil
+	}
+
+	err := c.report()
+	c.last, c.ent, c.fields, c.repeated = cc, ent, fields, 0
+	if !c.stopped {
+		if c.timer == nil {
+			c.timer = time.AfterFunc(c.window, c.end)
+		} else {
+			c.timer.Reset(c.window)
+		}
+	}
+
+	if werr := cc.Core.Write(ent, fields); werr != nil {
+		err = werr
+	}
+	return err
+}
+
+func (cc *coalescingCore) Sync() error {
+	cc.c.mu.Lock()
+	err := cc.c.report()
+	cc.c.mu.Unlock()
+
+	if serr := cc.Core.Sync(); serr != nil {
+		err = serr
+	}
+	return err
+}
+
+// stop reports the pending repeated records, such that they're written before the outputs close.
+func (cc *coalescingCore) stop() {
+	c := cc.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stopped = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	_ = c.report()
+}
+
+func (c *coalescer) identical(ent zapcore.Entry, fields []zapcore.Field) bool {
+	return ent.Level == c.ent.Level &&
+		ent.LoggerName == c.ent.LoggerName &&
+		ent.Message == c.ent.Message &&
+		ent.Caller == c.ent.Caller &&
+		reflect.DeepEqual(fields, c.fields)
+}
+
+// end is called once the window of the last record ends.
+func (c *coalescer) end() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_ = c.report()
+}
+
+// report writes how many records identical to the last one were dropped, if any, after which records
+// identical to the last one are written again. This must be called with the lock held.
+func (c *coalescer) report() error {
+	last, repeated := c.last, c.repeated
+	c.last, c.fields, c.repeated = nil, nil, 0
+	if last == nil || repeated == 0 {
+		return nil
+	}
+
+	return last.Core.Write(zapcore.Entry{
+		Level:      c.ent.Level,
+		Time:       time.Now(),
+		LoggerName: c.ent.LoggerName,
+		Message:    fmt.Sprintf("last message repeated %d times", repeated),
+	}, []zapcore.Field{zap.Int("repeated", repeated)})
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoalescing(t *testing.T) {
+	o := testOptions()
+	o.CoalesceWindow = time.Hour
+
+	lines, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Errorf("Got err '%v', expecting success", err)
+		}
+
+		// records are only identical when logged from the same place, as callers may be output
+		for _, attempt := range []int{1, 1, 1, 1, 1, 2, 2} {
+			Errorw("unable to connect", "attempt", attempt)
+		}
+		for i := 0; i < 5; i++ {
+			if i == 3 {
+				_ = Sync()
+			}
+			Info("connected")
+		}
+		_ = Configure(DefaultOptions())
+	})
+	if err != nil {
+		t.Errorf("Got error '%v', expected success", err)
+	}
+
+	expected := []string{
+		"unable to connect\t{\"attempt\": 1}",
+		"last message repeated 4 times\t{\"repeated\": 4}",
+		"unable to connect\t{\"attempt\": 2}",
+		"last message repeated 1 times\t{\"repeated\": 1}",
+		"connected",
+		"last message repeated 2 times\t{\"repeated\": 2}",
+		"connected",
+		"last message repeated 1 times\t{\"repeated\": 1}",
+	}
+	if len(lines) < len(expected) {
+		t.Fatalf("Got %v, expecting %d lines", lines, len(expected))
+	}
+	for i, e := range expected {
+		if !strings.HasSuffix(lines[i], "\t"+e) {
+			t.Errorf("Got '%s', expecting '%s'", lines[i], e)
+		}
+	}
+}
+
+func TestCoalescingWindow(t *testing.T) {
+	o := testOptions()
+	o.CoalesceWindow = 20 * time.Millisecond
+
+	lines, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Errorf("Got err '%v', expecting success", err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if i == 2 {
+				time.Sleep(100 * time.Millisecond)
+			}
+			Warn("retrying")
+		}
+		_ = Configure(DefaultOptions())
+	})
+	if err != nil {
+		t.Errorf("Got error '%v', expected success", err)
+	}
+
+	expected := []string{"retrying", "last message repeated 1 times\t{\"repeated\": 1}", "retrying"}
+	if len(lines) < len(expected) {
+		t.Fatalf("Got %v, expecting %d lines", lines, len(expected))
+	}
+	for i, e := range expected {
+		if !strings.HasSuffix(lines[i], "\t"+e) {
+			t.Errorf("Got '%s', expecting '%s'", lines[i], e)
+		}
+	}
+}
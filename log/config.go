@@ -53,12 +53,11 @@ package log
 
 import (
 	"fmt"
-	"os"
+	"path"
 	"strings"
 	"sync/atomic"
 	"time"
 
-	"github.com/natefinch/lumberjack"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zapgrpc"
@@ -83,6 +82,11 @@ type patchTable struct {
 	sync        func() error
 	exitProcess func(code int)
 	errorSink   zapcore.WriteSyncer
+	stop        func()
+	ring        *ringBuffer
+
+	// countRecords counts the records output by the scopes, see Options.RecordMetrics
+	countRecords bool
 }
 
 // function table that can be replaced by tests
@@ -93,8 +97,9 @@ func init() {
 	_ = Configure(DefaultOptions())
 }
 
-// prepZap is a utility function used by the Configure function.
-func prepZap(options *Options) (zapcore.Core, zapcore.Core, zapcore.WriteSyncer, error) {
+// prepZap is a utility function used by the Configure function. The returned function releases the
+// resources held by the cores once they are replaced.
+func prepZap(options *Options) (zapcore.Core, zapcore.Core, zapcore.WriteSyncer, func(), error) {
 	encCfg := zapcore.EncoderConfig{
 		TimeKey:        "time",
 		LevelKey:       "level",
@@ -110,60 +115,364 @@ func prepZap(options *Options) (zapcore.Core, zapcore.Core, zapcore.WriteSyncer,
 	}
 
 	var enc zapcore.Encoder
-	if options.JSONEncoding {
+	if options.CloudLogging {
+		enc = newCloudLoggingEncoder(encCfg, options.CloudLoggingProject)
+	} else if options.JSONEncoding {
+		applyJSONSchema(&encCfg, options.JSONSchema)
 		enc = zapcore.NewJSONEncoder(encCfg)
 	} else {
 		enc = zapcore.NewConsoleEncoder(encCfg)
 	}
 
-	var rotaterSink zapcore.WriteSyncer
-	if options.RotateOutputPath != "" {
-		rotaterSink = zapcore.AddSync(&lumberjack.Logger{
-			Filename:   options.RotateOutputPath,
-			MaxSize:    options.RotationMaxSize,
-			MaxBackups: options.RotationMaxBackups,
-			MaxAge:     options.RotationMaxAge,
-		})
+	levels, err := processTargetLevels(options)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var enabler zap.LevelEnablerFunc = func(lvl zapcore.Level) bool {
+		switch lvl {
+		case zapcore.ErrorLevel:
+			return defaultScope.ErrorEnabled()
+		case zapcore.WarnLevel:
+			return defaultScope.WarnEnabled()
+		case zapcore.InfoLevel:
+			return defaultScope.InfoEnabled()
+		}
+		return defaultScope.DebugEnabled()
 	}
 
 	errSink, closeErrorSink, err := zap.Open(options.ErrorOutputPaths...)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
-	var outputSink zapcore.WriteSyncer
-	if len(options.OutputPaths) > 0 {
-		outputSink, _, err = zap.Open(options.OutputPaths...)
+	// the sinks output the same levels share a core
+	var sinkRanges []levelRange
+	sinks := make(map[levelRange][]zapcore.WriteSyncer)
+	addSink := func(target string, sink zapcore.WriteSyncer) {
+		r := levelRange{min: levels.level(target), max: none}
+		if options.SplitStreams {
+			switch target {
+			case "stdout":
+				r.max = zapcore.WarnLevel
+			case "stderr":
+				if r.min < zapcore.WarnLevel {
+					r.min = zapcore.WarnLevel
+				}
+			}
+		}
+
+		if _, ok := sinks[r]; !ok {
+			sinkRanges = append(sinkRanges, r)
+		}
+		sinks[r] = append(sinks[r], sink)
+	}
+
+	// the network sinks run a goroutine until they're closed, and the rotating sink holds its file open
+	var closeSinks []func()
+	for _, p := range outputPaths(options) {
+		outputSink, closeSink, err := zap.Open(p)
 		if err != nil {
+			for _, c := range closeSinks {
+				c()
+			}
 			closeErrorSink()
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
+		}
+		addSink(p, outputSink)
+		if isNetworkPath(p) {
+			closeSinks = append(closeSinks, closeSink)
 		}
 	}
 
-	var sink zapcore.WriteSyncer
-	if rotaterSink != nil && outputSink != nil {
-		sink = zapcore.NewMultiWriteSyncer(outputSink, rotaterSink)
-	} else if rotaterSink != nil {
-		sink = rotaterSink
-	} else {
-		sink = outputSink
+	if options.RotateOutputPath != "" && !isPathTemplate(options.RotateOutputPath) {
+		rotating := newRotatingSink(options)
+		addSink(options.RotateOutputPath, rotating)
+		closeSinks = append(closeSinks, func() { _ = rotating.Close() })
 	}
 
-	var enabler zap.LevelEnablerFunc = func(lvl zapcore.Level) bool {
-		switch lvl {
-		case zapcore.ErrorLevel:
-			return defaultScope.ErrorEnabled()
-		case zapcore.WarnLevel:
-			return defaultScope.WarnEnabled()
-		case zapcore.InfoLevel:
-			return defaultScope.InfoEnabled()
+	var stops []func()
+	stop := func() {
+		for _, s := range stops {
+			s()
 		}
-		return defaultScope.DebugEnabled()
+		for _, c := range closeSinks {
+			c()
+		}
+	}
+
+	var cores, captureCores []zapcore.Core
+	for _, r := range sinkRanges {
+		sink := sinks[r][0]
+		if len(sinks[r]) > 1 {
+			sink = zapcore.NewMultiWriteSyncer(sinks[r]...)
+		}
+
+		if options.AsyncBufferSize > 0 {
+			async := newAsyncSink(sink, options.AsyncBufferSize)
+			sink = async
+			stops = append(stops, async.Stop)
+		}
+
+		cores = append(cores, zapcore.NewCore(enc, sink, r))
+		captureCores = append(captureCores, zapcore.NewCore(enc, sink, r.enabler(enabler)))
+	}
+
+	if options.RotateOutputPath != "" && isPathTemplate(options.RotateOutputPath) {
+		files, err := newTemplatedFiles(options)
+		if err != nil {
+			stop()
+			closeErrorSink()
+			return nil, nil, nil, nil, err
+		}
+
+		l := levels.level(options.RotateOutputPath)
+		cores = append(cores, newTemplatedCore(enc, files, l))
+		captureCores = append(captureCores, newTemplatedCore(enc, files, levels.enabler(l, enabler)))
+		stops = append(stops, files.close)
+	}
+
+	if options.OTLPEndpoint != "" {
+		l := levels.level(OTLPTarget)
+		exporter := newOTLPExporter(options, errSink)
+		cores = append(cores, newOTLPCore(exporter, l))
+		captureCores = append(captureCores, newOTLPCore(exporter, levels.enabler(l, enabler)))
+	}
+
+	if options.EventLogSource != "" {
+		l, err := openEventLog(options.EventLogSource)
+		if err != nil {
+			stop()
+			closeErrorSink()
+			return nil, nil, nil, nil, err
+		}
+
+		eventCfg := encCfg
+		eventCfg.TimeKey = ""
+		eventCfg.LevelKey = ""
+		eventEnc := zapcore.NewConsoleEncoder(eventCfg)
+		lvl := levels.level(EventLogTarget)
+		cores = append(cores, newEventLogCore(eventEnc, l, lvl))
+		captureCores = append(captureCores, newEventLogCore(eventEnc, l, levels.enabler(lvl, enabler)))
+		stops = append(stops, func() { _ = l.Close() })
 	}
 
-	return zapcore.NewCore(enc, sink, zap.NewAtomicLevelAt(zapcore.DebugLevel)),
-		zapcore.NewCore(enc, sink, enabler),
-		errSink, nil
+	if options.Journald {
+		j, err := openJournal()
+		if err != nil {
+			stop()
+			closeErrorSink()
+			return nil, nil, nil, nil, err
+		}
+
+		l := levels.level(JournaldTarget)
+		cores = append(cores, newJournaldCore(options.JournaldIdentifier, j, l))
+		captureCores = append(captureCores, newJournaldCore(options.JournaldIdentifier, j, levels.enabler(l, enabler)))
+		stops = append(stops, func() { _ = j.Close() })
+	}
+
+	if options.AuditOutputPath != "" {
+		chain, err := acquireAuditChain(options.AuditOutputPath, zapcore.NewJSONEncoder(encCfg), options.AuditCheckpointInterval)
+		if err != nil {
+			stop()
+			closeErrorSink()
+			return nil, nil, nil, nil, err
+		}
+
+		cores = append(cores, newAuditCore(zapcore.NewJSONEncoder(encCfg), chain, options.AuditScopes))
+		stops = append(stops, chain.release)
+	}
+
+	if options.SIEMOutputPath != "" {
+		siemEnc, err := newSIEMEncoder(options)
+		if err != nil {
+			stop()
+			closeErrorSink()
+			return nil, nil, nil, nil, err
+		}
+
+		sink, closeSink, err := zap.Open(options.SIEMOutputPath)
+		if err != nil {
+			stop()
+			closeErrorSink()
+			return nil, nil, nil, nil, err
+		}
+
+		siemCore := zapcore.NewCore(siemEnc, sink, levels.level(options.SIEMOutputPath))
+		cores = append(cores, newScopeFilterCore(siemCore, options.SIEMScopes))
+		stops = append(stops, closeSink)
+	}
+
+	for i, c := range cores {
+		cores[i] = leveledCore{c}
+	}
+	core := zapcore.NewTee(cores...)
+	captureCore := zapcore.NewTee(captureCores...)
+
+	if options.CoalesceWindow > 0 {
+		coalescing, captureCoalescing := newCoalescingCore(core, options.CoalesceWindow), newCoalescingCore(captureCore, options.CoalesceWindow)
+		core, captureCore = coalescing, captureCoalescing
+
+		// report the pending repeated records before the outputs close
+		stops = append([]func(){coalescing.stop, captureCoalescing.stop}, stops...)
+	}
+
+	if options.ErrorFingerprints {
+		core = fingerprintingCore{Core: core}
+		captureCore = fingerprintingCore{Core: captureCore}
+	}
+
+	return hookingCore{redactingCore{core}}, hookingCore{redactingCore{captureCore}}, errSink, stop, nil
+}
+
+// targetLevels holds the minimum levels of the output targets which don't output all the messages.
+type targetLevels map[string]zapcore.Level
+
+// level returns the minimum level of messages output to a target.
+func (t targetLevels) level(target string) zapcore.Level {
+	if l, ok := t[target]; ok {
+		return l
+	}
+	return zapcore.DebugLevel
+}
+
+// enabler combines the minimum level of a target with the enabler of captured messages.
+func (t targetLevels) enabler(l zapcore.Level, capture zap.LevelEnablerFunc) zapcore.LevelEnabler {
+	return zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+		return lvl >= l && capture(lvl)
+	})
+}
+
+// levelRange holds the levels of the records output to sinks, from min up to but excluding max.
+type levelRange struct {
+	min zapcore.Level
+	max zapcore.Level
+}
+
+func (r levelRange) Enabled(lvl zapcore.Level) bool {
+	return lvl >= r.min && lvl < r.max
+}
+
+// enabler combines the range with the enabler of captured messages.
+func (r levelRange) enabler(capture zap.LevelEnablerFunc) zapcore.LevelEnabler {
+	return zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+		return r.Enabled(lvl) && capture(lvl)
+	})
+}
+
+// outputPaths returns the output paths of the options, along with the standard streams when they are split.
+func outputPaths(options *Options) []string {
+	if !options.SplitStreams {
+		return options.OutputPaths
+	}
+
+	paths := options.OutputPaths
+	for _, stream := range []string{"stdout", "stderr"} {
+		found := false
+		for _, p := range paths {
+			if p == stream {
+				found = true
+				break
+			}
+		}
+		if !found {
+			paths = append(paths[:len(paths):len(paths)], stream)
+		}
+	}
+	return paths
+}
+
+// leveledCore drops the records below the level of the wrapped core, as scopes write records to the
+// cores without checking them first.
+type leveledCore struct {
+	zapcore.Core
+}
+
+func (c leveledCore) With(fields []zapcore.Field) zapcore.Core {
+	return leveledCore{c.Core.With(fields)}
+}
+
+func (c leveledCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c leveledCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.Enabled(ent.Level) {
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// processTargetLevels breaks down the target levels of the options, ensuring they refer to configured
+// output targets.
+func processTargetLevels(options *Options) (targetLevels, error) {
+	levels := make(targetLevels)
+	if options.targetLevels == "" {
+		return levels, nil
+	}
+
+	targets := map[string]bool{
+		options.RotateOutputPath: options.RotateOutputPath != "",
+		OTLPTarget:               options.OTLPEndpoint != "",
+		EventLogTarget:           options.EventLogSource != "",
+		JournaldTarget:           options.Journald,
+		options.SIEMOutputPath:   options.SIEMOutputPath != "",
+	}
+	for _, p := range outputPaths(options) {
+		targets[p] = true
+	}
+
+	for _, tl := range strings.Split(options.targetLevels, ",") {
+		t, l, err := convertTargetLevel(tl)
+		if err != nil {
+			return nil, err
+		}
+
+		if !targets[t] {
+			return nil, fmt.Errorf("unknown output target '%s' specified", t)
+		}
+		levels[t] = levelToZap[l]
+	}
+
+	return levels, nil
+}
+
+// applyJSONSchema overrides the field names and timestamp format of an encoder configuration.
+func applyJSONSchema(encCfg *zapcore.EncoderConfig, schema JSONSchema) {
+	override := func(key *string, value string) {
+		switch value {
+		case "":
+		case "-":
+			*key = ""
+		default:
+			*key = value
+		}
+	}
+
+	override(&encCfg.TimeKey, schema.TimeKey)
+	override(&encCfg.LevelKey, schema.LevelKey)
+	override(&encCfg.NameKey, schema.ScopeKey)
+	override(&encCfg.CallerKey, schema.CallerKey)
+	override(&encCfg.MessageKey, schema.MessageKey)
+	override(&encCfg.StacktraceKey, schema.StackKey)
+
+	switch schema.TimeFormat {
+	case "":
+	case "epoch":
+		encCfg.EncodeTime = zapcore.EpochTimeEncoder
+	case "epoch_millis":
+		encCfg.EncodeTime = zapcore.EpochMillisTimeEncoder
+	case "epoch_nanos":
+		encCfg.EncodeTime = zapcore.EpochNanosTimeEncoder
+	default:
+		layout := schema.TimeFormat
+		encCfg.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(t.UTC().Format(layout))
+		}
+	}
 }
 
 func formatDate(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
@@ -219,6 +528,23 @@ func updateScopes(options *Options) error {
 		return err
 	}
 
+	// update the ring buffer retention levels of all listed scopes
+	if options.ringLevels != "" {
+		if err := processLevels(allScopes, options.ringLevels, func(s *Scope, l Level) { s.SetRingLevel(l) }); err != nil {
+			return err
+		}
+	}
+
+	// update the sampling of all listed scopes
+	if err := processSampling(allScopes, options.sampling); err != nil {
+		return err
+	}
+
+	// update the rate limits of all listed scopes
+	if err := processRateLimits(allScopes, options.rateLimits); err != nil {
+		return err
+	}
+
 	// update the caller location setting of all listed scopes
 	sc := strings.Split(options.logCallers, ",")
 	for _, s := range sc {
@@ -232,7 +558,7 @@ func updateScopes(options *Options) error {
 				scope.SetLogCallers(true)
 			}
 
-			return nil
+			break
 		}
 
 		if scope, ok := allScopes[s]; ok {
@@ -242,11 +568,19 @@ func updateScopes(options *Options) error {
 		}
 	}
 
+	// update the caller location levels of all listed scopes, which refine the setting above
+	if options.callerLevels != "" {
+		if err := processLevels(allScopes, options.callerLevels, func(s *Scope, l Level) { s.SetCallerLevel(l) }); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 // processLevels breaks down an argument string into a set of scope & levels and then
-// tries to apply the result to the scopes. It supports the use of a global override.
+// tries to apply the result to the scopes. It supports the use of a global override, and of
+// patterns as accepted by SetLevelForPattern in place of scope names.
 func processLevels(allScopes map[string]*Scope, arg string, setter func(*Scope, Level)) error {
 	levels := strings.Split(arg, ",")
 	for _, sl := range levels {
@@ -257,6 +591,15 @@ func processLevels(allScopes map[string]*Scope, arg string, setter func(*Scope,
 
 		if scope, ok := allScopes[s]; ok {
 			setter(scope, l)
+		} else if isScopePattern(s) {
+			if _, err := path.Match(s, ""); err != nil {
+				return fmt.Errorf("invalid scope pattern '%s': %v", s, err)
+			}
+			for name, scope := range allScopes {
+				if ok, _ := path.Match(s, name); ok {
+					setter(scope, l)
+				}
+			}
 		} else if s == OverrideScopeName {
 			// override replaces everything
 			for _, scope := range allScopes {
@@ -271,33 +614,133 @@ func processLevels(allScopes map[string]*Scope, arg string, setter func(*Scope,
 	return nil
 }
 
+// processSampling breaks down an argument string into a set of scope & sampling configurations and then
+// tries to apply the result to the scopes. It supports the use of a global override.
+func processSampling(allScopes map[string]*Scope, arg string) error {
+	if arg == "" {
+		return nil
+	}
+
+	for _, ss := range strings.Split(arg, ",") {
+		s, c, err := convertScopedSampling(ss)
+		if err != nil {
+			return err
+		}
+
+		if scope, ok := allScopes[s]; ok {
+			scope.SetSampling(c)
+		} else if s == OverrideScopeName {
+			// override replaces everything
+			for _, scope := range allScopes {
+				scope.SetSampling(c)
+			}
+			return nil
+		} else {
+			return fmt.Errorf("unknown scope '%s' specified", s)
+		}
+	}
+
+	return nil
+}
+
+// processRateLimits breaks down an argument string into a set of scope & rate limits and then tries to
+// apply the result to the scopes. It supports the use of a global override.
+func processRateLimits(allScopes map[string]*Scope, arg string) error {
+	if arg == "" {
+		return nil
+	}
+
+	for _, sl := range strings.Split(arg, ",") {
+		s, l, err := convertScopedRateLimit(sl)
+		if err != nil {
+			return err
+		}
+
+		if scope, ok := allScopes[s]; ok {
+			scope.SetRateLimit(l)
+		} else if s == OverrideScopeName {
+			// override replaces everything
+			for _, scope := range allScopes {
+				scope.SetRateLimit(l)
+			}
+			return nil
+		} else {
+			return fmt.Errorf("unknown scope '%s' specified", s)
+		}
+	}
+
+	return nil
+}
+
 // Configure initializes Istio's logging subsystem.
 //
 // You typically call this once at process startup.
 // Once this call returns, the logging system is ready to accept data.
 // nolint: staticcheck
 func Configure(options *Options) error {
-	core, captureCore, errSink, err := prepZap(options)
+	if err := configure(options); err != nil {
+		return err
+	}
+
+	// the options the configuration files watched by WatchConfig apply on top of
+	o := *options
+	configuredOptions.Store(&o)
+	return nil
+}
+
+// nolint: staticcheck
+func configure(options *Options) error {
+	core, captureCore, errSink, stop, err := prepZap(options)
 	if err != nil {
 		return err
 	}
 
+	if options.LogGrpc && options.GrpcScope != "" {
+		_ = RegisterScope(options.GrpcScope, "gRPC internal logging", 0)
+	}
+
 	if err = updateScopes(options); err != nil {
+		stop()
 		return err
 	}
 
+	if options.RecordMetrics {
+		if err = records.Register(); err != nil {
+			stop()
+			return err
+		}
+	} else {
+		records.Unregister()
+	}
+
 	pt := patchTable{
 		write: func(ent zapcore.Entry, fields []zapcore.Field) error {
 			err := core.Write(ent, fields)
 			if ent.Level == zapcore.FatalLevel {
+				// make sure the record is out before exiting, in case it's buffered
+				_ = core.Sync()
 				funcs.Load().(patchTable).exitProcess(1)
 			}
 
 			return err
 		},
-		sync:        core.Sync,
-		exitProcess: os.Exit,
-		errorSink:   errSink,
+		sync:         core.Sync,
+		exitProcess:  exit,
+		errorSink:    errSink,
+		stop:         stop,
+		countRecords: options.RecordMetrics,
+	}
+	old, ok := funcs.Load().(patchTable)
+	if options.RingBufferSize > 0 {
+		// keep the records retained so far when the size doesn't change
+		if ok && old.ring != nil && old.ring.size == options.RingBufferSize {
+			pt.ring = old.ring
+		} else {
+			pt.ring = newRingBuffer(options.RingBufferSize)
+		}
+	}
+	if ok && old.stop != nil {
+		defer old.stop()
 	}
 	funcs.Store(pt)
 
@@ -325,7 +768,11 @@ func Configure(options *Options) error {
 
 	// capture gRPC logging
 	if options.LogGrpc {
-		grpclog.SetLogger(zapgrpc.NewLogger(captureLogger.WithOptions(zap.AddCallerSkip(2))))
+		if options.GrpcScope != "" {
+			grpclog.SetLoggerV2(NewGrpcLogger(FindScope(options.GrpcScope)))
+		} else {
+			grpclog.SetLogger(zapgrpc.NewLogger(captureLogger.WithOptions(zap.AddCallerSkip(2))))
+		}
 	}
 
 	return nil
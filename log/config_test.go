@@ -15,6 +15,7 @@
 package log
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"log"
 	"os"
@@ -120,6 +121,86 @@ func TestOverrides(t *testing.T) {
 	}
 }
 
+func TestPatternLevels(t *testing.T) {
+	resetGlobals()
+	ads := RegisterScope("ads", "For testing", 0)
+	other := RegisterScope("validation", "For testing", 0)
+
+	o := DefaultOptions()
+	o.outputLevels = "default:info,ads*:debug"
+	o.stackTraceLevels = "default:none,*:error"
+	if err := Configure(o); err != nil {
+		t.Fatalf("Expecting success, got %v", err)
+	}
+	if ads.GetOutputLevel() != DebugLevel || other.GetOutputLevel() != InfoLevel {
+		t.Errorf("Expecting DebugLevel and InfoLevel, got %v and %v", ads.GetOutputLevel(), other.GetOutputLevel())
+	}
+	if ads.GetStackTraceLevel() != ErrorLevel || defaultScope.GetStackTraceLevel() != ErrorLevel {
+		t.Errorf("Expecting ErrorLevel, got %v and %v", ads.GetStackTraceLevel(), defaultScope.GetStackTraceLevel())
+	}
+
+	o = DefaultOptions()
+	o.outputLevels = "[ads:debug"
+	if err := Configure(o); err == nil {
+		t.Error("Got success, expected failure")
+	}
+
+	resetGlobals()
+	_ = Configure(DefaultOptions())
+}
+
+func TestJSONSchema(t *testing.T) {
+	resetGlobals()
+
+	o := DefaultOptions()
+	o.JSONEncoding = true
+	o.JSONSchema = JSONSchema{
+		TimeKey:    "@timestamp",
+		TimeFormat: "2006-01-02",
+		LevelKey:   "log.level",
+		ScopeKey:   "-",
+		MessageKey: "message",
+	}
+
+	lines, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+		Info("Hello")
+		_ = Sync()
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatalf("Got %v, expecting %q to be JSON", err, lines[0])
+	}
+	if m["message"] != "Hello" || m["log.level"] != "info" || len(m) != 3 {
+		t.Errorf("Got %v, expecting the custom field names", m)
+	}
+	if ts, ok := m["@timestamp"].(string); !ok || !regexp.MustCompile("^[0-9]{4}-[0-9]{2}-[0-9]{2}$").MatchString(ts) {
+		t.Errorf("Got %v, expecting a date", m["@timestamp"])
+	}
+
+	o.JSONSchema = JSONSchema{TimeFormat: "epoch_millis"}
+	lines, _ = captureStdout(func() {
+		_ = Configure(o)
+		Info("Hello")
+		_ = Sync()
+	})
+	m = nil
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatalf("Got %v, expecting %q to be JSON", err, lines[0])
+	}
+	if _, ok := m["time"].(float64); !ok || m["msg"] != "Hello" {
+		t.Errorf("Got %v, expecting the default field names and a numeric timestamp", m)
+	}
+
+	_ = Configure(DefaultOptions())
+}
+
 func TestOddballs(t *testing.T) {
 	resetGlobals()
 
@@ -252,6 +333,112 @@ func TestRotateAndStdout(t *testing.T) {
 	}
 }
 
+func TestTargetLevels(t *testing.T) {
+	resetGlobals()
+
+	dir, _ := ioutil.TempDir("", "TestTargetLevels")
+	defer os.RemoveAll(dir)
+
+	file := dir + "/debug.log"
+	rotFile := dir + "/rot.log"
+
+	o := DefaultOptions()
+	o.SetOutputLevel(DefaultScopeName, DebugLevel)
+	o.OutputPaths = []string{"stdout", file}
+	o.RotateOutputPath = rotFile
+	o.SetTargetLevel("stdout", DebugLevel)
+	o.SetTargetLevel("stdout", WarnLevel)
+	o.SetTargetLevel(rotFile, NoneLevel)
+	if l, err := o.GetTargetLevel("stdout"); err != nil || l != WarnLevel {
+		t.Errorf("Got %v, %v, expecting warn", l, err)
+	}
+	if _, err := o.GetTargetLevel(file); err == nil {
+		t.Error("Got success, expecting no level to be defined")
+	}
+
+	stdoutLines, _ := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+
+		Debug("DEBUG")
+		Warn("WARN")
+		_ = Sync()
+	})
+	_ = Configure(DefaultOptions())
+
+	if len(stdoutLines) != 2 || !strings.Contains(stdoutLines[0], "WARN") {
+		t.Errorf("Got %v, expecting only the warning on stdout", stdoutLines)
+	}
+
+	content, _ := ioutil.ReadFile(file)
+	if lines := strings.Split(string(content), "\n"); len(lines) != 3 || !strings.Contains(lines[0], "DEBUG") {
+		t.Errorf("Got %v, expecting all the messages in the file", lines)
+	}
+
+	if content, _ := ioutil.ReadFile(rotFile); len(content) != 0 {
+		t.Errorf("Got %q, expecting nothing in the rotating file", content)
+	}
+
+	for _, bad := range []string{"stdout", "stdout:foobar", "stderr:info", "otlp:info"} {
+		o = DefaultOptions()
+		o.targetLevels = bad
+		if err := Configure(o); err == nil {
+			t.Errorf("Got success for '%s', expecting failure", bad)
+		}
+	}
+
+	o = DefaultOptions()
+	o.OutputPaths = []string{`C:\logs\istio.log`}
+	o.SetTargetLevel(`C:\logs\istio.log`, ErrorLevel)
+	o.SetTargetLevel(`C:\logs\istio.log`, InfoLevel)
+	if l, err := o.GetTargetLevel(`C:\logs\istio.log`); err != nil || l != InfoLevel || o.targetLevels != `C:\logs\istio.log:info` {
+		t.Errorf("Got %v, %v, expecting targets holding colons to be supported", l, err)
+	}
+}
+
+func TestSplitStreams(t *testing.T) {
+	resetGlobals()
+
+	stderr, err := ioutil.TempFile("", "TestSplitStreams")
+	if err != nil {
+		t.Fatalf("Unable to create the stderr file: %v", err)
+	}
+	defer os.Remove(stderr.Name())
+
+	o := DefaultOptions()
+	o.SetOutputLevel(DefaultScopeName, DebugLevel)
+	o.SplitStreams = true
+	o.SetTargetLevel("stderr", ErrorLevel)
+
+	stdoutLines, _ := captureStdout(func() {
+		old := os.Stderr
+		os.Stderr = stderr
+		defer func() { os.Stderr = old }()
+
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+
+		Debug("DEBUG")
+		Info("INFO")
+		Warn("WARN")
+		Error("ERROR")
+		_ = Sync()
+	})
+	_ = Configure(DefaultOptions())
+	_ = stderr.Close()
+
+	if len(stdoutLines) != 3 || !strings.Contains(stdoutLines[0], "DEBUG") || !strings.Contains(stdoutLines[1], "INFO") {
+		t.Errorf("Got %v, expecting only the debug and info messages on stdout", stdoutLines)
+	}
+
+	content, _ := ioutil.ReadFile(stderr.Name())
+	if lines := strings.Split(string(content), "\n"); len(lines) != 2 || !strings.Contains(lines[0], "ERROR") {
+		t.Errorf("Got %v, expecting only the error on stderr", lines)
+	}
+}
+
 func TestRotateMaxBackups(t *testing.T) {
 	dir, _ := ioutil.TempDir("", "TestRotateMaxBackups")
 	defer os.RemoveAll(dir)
@@ -0,0 +1,84 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+)
+
+type scopeContextKey struct{}
+
+type debugContextKey struct{}
+
+// NewContext returns a copy of ctx carrying a scope which adds structured fields made of alternating
+// keys and values, such as a request ID, to all the messages it outputs. Code further down the call
+// chain retrieves the scope with FromContext. Fields already carried by ctx are kept, and a nil scope
+// keeps the scope carried by ctx.
+//
+//	ctx = log.NewContext(ctx, adsScope, "request", id, "peer", peer)
+//	...
+//	log.FromContext(ctx).Infof("pushed %d clusters", n) // includes the request and peer fields
+func NewContext(ctx context.Context, scope *Scope, keysAndValues ...interface{}) context.Context {
+	current := scopeFromContext(ctx)
+	if scope == nil || scope == current {
+		scope = current
+	} else if len(current.fields) > 0 {
+		// carry the fields of the scope carried by ctx over to the new scope
+		scope = scope.registered().derive(current.fields, 0).derive(scope.fields, 0)
+	}
+
+	return context.WithValue(ctx, scopeContextKey{}, scope.WithLabels(keysAndValues...))
+}
+
+// FromContext returns the scope carried by ctx, or the default scope if ctx doesn't carry any. When
+// ctx carries a span, the scope adds trace_id and span_id fields identifying the span to the messages
+// it outputs, which lets log backends correlate log records with traces. See RegisterTraceExtractor
+// for the supported tracing libraries. See NewDebugContext for troubleshooting a single request.
+func FromContext(ctx context.Context) *Scope {
+	s := scopeFromContext(ctx)
+	if isDebugContext(ctx) {
+		s = s.withForcedDebug()
+	}
+	if labels := traceLabels(ctx); labels != nil {
+		return s.WithLabels(labels...)
+	}
+	return s
+}
+
+// NewDebugContext returns a copy of ctx marking a request to troubleshoot, such that the scopes returned
+// by FromContext for the request output messages of all levels, whatever their output level. This lets
+// operators debug a single request in production without drowning the logs in the debug messages of
+// all the other requests.
+//
+//	if req.Header.Get("x-debug-request") != "" {
+//		ctx = log.NewDebugContext(ctx)
+//	}
+//	...
+//	log.FromContext(ctx).Debugf("matched route %s", name) // output for this request only
+func NewDebugContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugContextKey{}, true)
+}
+
+func isDebugContext(ctx context.Context) bool {
+	debug, _ := ctx.Value(debugContextKey{}).(bool)
+	return debug
+}
+
+func scopeFromContext(ctx context.Context) *Scope {
+	if s, ok := ctx.Value(scopeContextKey{}).(*Scope); ok {
+		return s
+	}
+	return defaultScope
+}
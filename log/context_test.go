@@ -0,0 +1,133 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	resetGlobals()
+	ads := RegisterScope("ads", "For testing", 0)
+
+	if FromContext(context.Background()) != defaultScope {
+		t.Error("Expecting the default scope for a context carrying none")
+	}
+
+	o := DefaultOptions()
+	o.JSONEncoding = true
+
+	lines, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+
+		ctx := NewContext(context.Background(), nil, "request", "r1")
+		FromContext(ctx).Info("first")
+
+		ctx = NewContext(ctx, ads, "peer", "sidecar~1")
+		FromContext(ctx).Infof("second %d", 2)
+
+		ctx = NewContext(ctx, nil, "attempt", 3)
+		FromContext(ctx).Warn("third")
+		_ = Sync()
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	_ = Configure(DefaultOptions())
+
+	expected := []map[string]interface{}{
+		{"msg": "first", "request": "r1"},
+		{"msg": "second 2", "scope": "ads", "request": "r1", "peer": "sidecar~1"},
+		{"msg": "third", "scope": "ads", "request": "r1", "peer": "sidecar~1", "attempt": float64(3)},
+	}
+	for i, exp := range expected {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &m); err != nil {
+			t.Fatalf("Got %v, expecting %q to be JSON", err, lines[i])
+		}
+		for k, v := range exp {
+			if m[k] != v {
+				t.Errorf("Got %s=%v in line %d, expecting %v", k, m[k], i, v)
+			}
+		}
+	}
+}
+
+func TestDebugContext(t *testing.T) {
+	resetGlobals()
+	ads := RegisterScope("ads", "For testing", 0)
+	ads.SetOutputLevel(WarnLevel)
+
+	lines, err := captureStdout(func() {
+		if err := Configure(DefaultOptions()); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+
+		ctx := NewContext(context.Background(), ads, "request", "r1")
+		FromContext(ctx).Debug("not output")
+
+		debugCtx := NewDebugContext(ctx)
+		FromContext(debugCtx).Debug("debugging r1")
+		FromContext(NewContext(debugCtx, nil, "attempt", 2)).WithLabels("peer", "sidecar~1").Info("retrying r1")
+
+		ads.Info("not output either")
+		_ = Sync()
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	_ = Configure(DefaultOptions())
+
+	expected := []string{
+		"\tdebug\tads\tdebugging r1\t{\"request\": \"r1\"}",
+		"\tinfo\tads\tretrying r1\t{\"request\": \"r1\", \"attempt\": 2, \"peer\": \"sidecar~1\"}",
+	}
+	if len(lines) < len(expected) || lines[len(expected)] != "" {
+		t.Fatalf("Got %v, expecting %d lines", lines, len(expected))
+	}
+	for i, e := range expected {
+		if !strings.HasSuffix(lines[i], e) {
+			t.Errorf("Got '%s', expecting '%s'", lines[i], e)
+		}
+	}
+
+	if ads.GetOutputLevel() != WarnLevel {
+		t.Errorf("Got %v, expecting the output level of the scope to be unchanged", ads.GetOutputLevel())
+	}
+}
+
+func TestWithLabels(t *testing.T) {
+	resetGlobals()
+	s := RegisterScope("TestWithLabels", "For testing", 0)
+
+	derived := s.WithLabels("key", "value")
+	if derived.Name() != s.Name() || s.WithLabels() != s {
+		t.Error("Expecting the derived scope to share the name of its scope")
+	}
+
+	derived.SetOutputLevel(DebugLevel)
+	if s.GetOutputLevel() != DebugLevel {
+		t.Errorf("Got %v, expecting the derived scope to share the output level of its scope", s.GetOutputLevel())
+	}
+	s.SetOutputLevel(ErrorLevel)
+	if derived.InfoEnabled() {
+		t.Error("Expecting info to be disabled for the derived scope")
+	}
+}
@@ -24,21 +24,21 @@ var defaultScope = RegisterScope(DefaultScopeName, "Unscoped logging messages.",
 
 // Fatal outputs a message at fatal level.
 func Fatal(msg string, fields ...zapcore.Field) {
-	if defaultScope.GetOutputLevel() >= FatalLevel {
+	if defaultScope.shouldEmit(FatalLevel) {
 		defaultScope.emit(zapcore.FatalLevel, defaultScope.GetStackTraceLevel() >= FatalLevel, msg, fields)
 	}
 }
 
 // Fatala uses fmt.Sprint to construct and log a message at fatal level.
 func Fatala(args ...interface{}) {
-	if defaultScope.GetOutputLevel() >= FatalLevel {
+	if defaultScope.shouldEmit(FatalLevel) {
 		defaultScope.emit(zapcore.FatalLevel, defaultScope.GetStackTraceLevel() >= FatalLevel, fmt.Sprint(args...), nil)
 	}
 }
 
 // Fatalf uses fmt.Sprintf to construct and log a message at fatal level.
 func Fatalf(template string, args ...interface{}) {
-	if defaultScope.GetOutputLevel() >= FatalLevel {
+	if defaultScope.shouldEmit(FatalLevel) {
 		msg := template
 		if len(args) > 0 {
 			msg = fmt.Sprintf(template, args...)
@@ -47,6 +47,14 @@ func Fatalf(template string, args ...interface{}) {
 	}
 }
 
+// Fatalw outputs a message at fatal level, along with structured fields made of alternating keys and values
+// which keep their type, such as ("count", 3, "elapsed", time.Second).
+func Fatalw(msg string, keysAndValues ...interface{}) {
+	if defaultScope.shouldEmit(FatalLevel) {
+		defaultScope.emit(zapcore.FatalLevel, defaultScope.GetStackTraceLevel() >= FatalLevel, msg, keysAndValuesToFields(keysAndValues))
+	}
+}
+
 // FatalEnabled returns whether output of messages using this scope is currently enabled for fatal-level output.
 func FatalEnabled() bool {
 	return defaultScope.GetOutputLevel() >= FatalLevel
@@ -54,21 +62,21 @@ func FatalEnabled() bool {
 
 // Error outputs a message at error level.
 func Error(msg string, fields ...zapcore.Field) {
-	if defaultScope.GetOutputLevel() >= ErrorLevel {
+	if defaultScope.shouldEmit(ErrorLevel) {
 		defaultScope.emit(zapcore.ErrorLevel, defaultScope.GetStackTraceLevel() >= ErrorLevel, msg, fields)
 	}
 }
 
 // Errora uses fmt.Sprint to construct and log a message at error level.
 func Errora(args ...interface{}) {
-	if defaultScope.GetOutputLevel() >= ErrorLevel {
+	if defaultScope.shouldEmit(ErrorLevel) {
 		defaultScope.emit(zapcore.ErrorLevel, defaultScope.GetStackTraceLevel() >= ErrorLevel, fmt.Sprint(args...), nil)
 	}
 }
 
 // Errorf uses fmt.Sprintf to construct and log a message at error level.
 func Errorf(template string, args ...interface{}) {
-	if defaultScope.GetOutputLevel() >= ErrorLevel {
+	if defaultScope.shouldEmit(ErrorLevel) {
 		msg := template
 		if len(args) > 0 {
 			msg = fmt.Sprintf(template, args...)
@@ -77,6 +85,14 @@ func Errorf(template string, args ...interface{}) {
 	}
 }
 
+// Errorw outputs a message at error level, along with structured fields made of alternating keys and values
+// which keep their type, such as ("count", 3, "elapsed", time.Second).
+func Errorw(msg string, keysAndValues ...interface{}) {
+	if defaultScope.shouldEmit(ErrorLevel) {
+		defaultScope.emit(zapcore.ErrorLevel, defaultScope.GetStackTraceLevel() >= ErrorLevel, msg, keysAndValuesToFields(keysAndValues))
+	}
+}
+
 // ErrorEnabled returns whether output of messages using this scope is currently enabled for error-level output.
 func ErrorEnabled() bool {
 	return defaultScope.GetOutputLevel() >= ErrorLevel
@@ -84,21 +100,21 @@ func ErrorEnabled() bool {
 
 // Warn outputs a message at warn level.
 func Warn(msg string, fields ...zapcore.Field) {
-	if defaultScope.GetOutputLevel() >= WarnLevel {
+	if defaultScope.shouldEmit(WarnLevel) {
 		defaultScope.emit(zapcore.WarnLevel, defaultScope.GetStackTraceLevel() >= WarnLevel, msg, fields)
 	}
 }
 
 // Warna uses fmt.Sprint to construct and log a message at warn level.
 func Warna(args ...interface{}) {
-	if defaultScope.GetOutputLevel() >= WarnLevel {
+	if defaultScope.shouldEmit(WarnLevel) {
 		defaultScope.emit(zapcore.WarnLevel, defaultScope.GetStackTraceLevel() >= WarnLevel, fmt.Sprint(args...), nil)
 	}
 }
 
 // Warnf uses fmt.Sprintf to construct and log a message at warn level.
 func Warnf(template string, args ...interface{}) {
-	if defaultScope.GetOutputLevel() >= WarnLevel {
+	if defaultScope.shouldEmit(WarnLevel) {
 		msg := template
 		if len(args) > 0 {
 			msg = fmt.Sprintf(template, args...)
@@ -107,6 +123,14 @@ func Warnf(template string, args ...interface{}) {
 	}
 }
 
+// Warnw outputs a message at warn level, along with structured fields made of alternating keys and values
+// which keep their type, such as ("count", 3, "elapsed", time.Second).
+func Warnw(msg string, keysAndValues ...interface{}) {
+	if defaultScope.shouldEmit(WarnLevel) {
+		defaultScope.emit(zapcore.WarnLevel, defaultScope.GetStackTraceLevel() >= WarnLevel, msg, keysAndValuesToFields(keysAndValues))
+	}
+}
+
 // WarnEnabled returns whether output of messages using this scope is currently enabled for warn-level output.
 func WarnEnabled() bool {
 	return defaultScope.GetOutputLevel() >= WarnLevel
@@ -114,21 +138,21 @@ func WarnEnabled() bool {
 
 // Info outputs a message at info level.
 func Info(msg string, fields ...zapcore.Field) {
-	if defaultScope.GetOutputLevel() >= InfoLevel {
+	if defaultScope.shouldEmit(InfoLevel) {
 		defaultScope.emit(zapcore.InfoLevel, defaultScope.GetStackTraceLevel() >= InfoLevel, msg, fields)
 	}
 }
 
 // Infoa uses fmt.Sprint to construct and log a message at info level.
 func Infoa(args ...interface{}) {
-	if defaultScope.GetOutputLevel() >= InfoLevel {
+	if defaultScope.shouldEmit(InfoLevel) {
 		defaultScope.emit(zapcore.InfoLevel, defaultScope.GetStackTraceLevel() >= InfoLevel, fmt.Sprint(args...), nil)
 	}
 }
 
 // Infof uses fmt.Sprintf to construct and log a message at info level.
 func Infof(template string, args ...interface{}) {
-	if defaultScope.GetOutputLevel() >= InfoLevel {
+	if defaultScope.shouldEmit(InfoLevel) {
 		msg := template
 		if len(args) > 0 {
 			msg = fmt.Sprintf(template, args...)
@@ -137,6 +161,14 @@ func Infof(template string, args ...interface{}) {
 	}
 }
 
+// Infow outputs a message at info level, along with structured fields made of alternating keys and values
+// which keep their type, such as ("count", 3, "elapsed", time.Second).
+func Infow(msg string, keysAndValues ...interface{}) {
+	if defaultScope.shouldEmit(InfoLevel) {
+		defaultScope.emit(zapcore.InfoLevel, defaultScope.GetStackTraceLevel() >= InfoLevel, msg, keysAndValuesToFields(keysAndValues))
+	}
+}
+
 // InfoEnabled returns whether output of messages using this scope is currently enabled for info-level output.
 func InfoEnabled() bool {
 	return defaultScope.GetOutputLevel() >= InfoLevel
@@ -144,21 +176,21 @@ func InfoEnabled() bool {
 
 // Debug outputs a message at debug level.
 func Debug(msg string, fields ...zapcore.Field) {
-	if defaultScope.GetOutputLevel() >= DebugLevel {
+	if defaultScope.shouldEmit(DebugLevel) {
 		defaultScope.emit(zapcore.DebugLevel, defaultScope.GetStackTraceLevel() >= DebugLevel, msg, fields)
 	}
 }
 
 // Debuga uses fmt.Sprint to construct and log a message at debug level.
 func Debuga(args ...interface{}) {
-	if defaultScope.GetOutputLevel() >= DebugLevel {
+	if defaultScope.shouldEmit(DebugLevel) {
 		defaultScope.emit(zapcore.DebugLevel, defaultScope.GetStackTraceLevel() >= DebugLevel, fmt.Sprint(args...), nil)
 	}
 }
 
 // Debugf uses fmt.Sprintf to construct and log a message at debug level.
 func Debugf(template string, args ...interface{}) {
-	if defaultScope.GetOutputLevel() >= DebugLevel {
+	if defaultScope.shouldEmit(DebugLevel) {
 		msg := template
 		if len(args) > 0 {
 			msg = fmt.Sprintf(template, args...)
@@ -167,6 +199,14 @@ func Debugf(template string, args ...interface{}) {
 	}
 }
 
+// Debugw outputs a message at debug level, along with structured fields made of alternating keys and values
+// which keep their type, such as ("count", 3, "elapsed", time.Second).
+func Debugw(msg string, keysAndValues ...interface{}) {
+	if defaultScope.shouldEmit(DebugLevel) {
+		defaultScope.emit(zapcore.DebugLevel, defaultScope.GetStackTraceLevel() >= DebugLevel, msg, keysAndValuesToFields(keysAndValues))
+	}
+}
+
 // DebugEnabled returns whether output of messages using this scope is currently enabled for debug-level output.
 func DebugEnabled() bool {
 	return defaultScope.GetOutputLevel() >= DebugLevel
@@ -49,6 +49,10 @@ func TestDefault(t *testing.T) {
 			f:   func() { Debuga("Hello") },
 			pat: timePattern + "\tdebug\tHello",
 		},
+		{
+			f:   func() { Debugw("Hello", "count", 3) },
+			pat: timePattern + "\tdebug\tHello\t\\{\"count\": 3\\}",
+		},
 
 		{
 			f:   func() { Info("Hello") },
@@ -66,6 +70,10 @@ func TestDefault(t *testing.T) {
 			f:   func() { Infoa("Hello") },
 			pat: timePattern + "\tinfo\tHello",
 		},
+		{
+			f:   func() { Infow("Hello", "count", 3) },
+			pat: timePattern + "\tinfo\tHello\t\\{\"count\": 3\\}",
+		},
 
 		{
 			f:   func() { Warn("Hello") },
@@ -83,6 +91,10 @@ func TestDefault(t *testing.T) {
 			f:   func() { Warna("Hello") },
 			pat: timePattern + "\twarn\tHello",
 		},
+		{
+			f:   func() { Warnw("Hello", "count", 3) },
+			pat: timePattern + "\twarn\tHello\t\\{\"count\": 3\\}",
+		},
 
 		{
 			f:   func() { Error("Hello") },
@@ -100,6 +112,10 @@ func TestDefault(t *testing.T) {
 			f:   func() { Errora("Hello") },
 			pat: timePattern + "\terror\tHello",
 		},
+		{
+			f:   func() { Errorw("Hello", "count", 3) },
+			pat: timePattern + "\terror\tHello\t\\{\"count\": 3\\}",
+		},
 
 		{
 			f:        func() { Fatal("Hello") },
@@ -121,6 +137,11 @@ func TestDefault(t *testing.T) {
 			pat:      timePattern + "\tfatal\tHello",
 			wantExit: true,
 		},
+		{
+			f:        func() { Fatalw("Hello", "count", 3) },
+			pat:      timePattern + "\tfatal\tHello\t\\{\"count\": 3\\}",
+			wantExit: true,
+		},
 
 		{
 			f:      func() { Debug("Hello") },
@@ -0,0 +1,92 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// eventID is the identifier of all the events reported to the Windows Event Log. Sources registered
+// by eventcreate only support identifiers between 1 and 1000.
+const eventID = 1
+
+// eventLog is the part of a Windows Event Log handle used to report events.
+type eventLog interface {
+	Info(eid uint32, msg string) error
+	Warning(eid uint32, msg string) error
+	Error(eid uint32, msg string) error
+	Close() error
+}
+
+// eventLogCore reports log records to the Windows Event Log. The Event Log records the time and
+// severity of events on its own, so the encoder only needs to output the rest of the records.
+type eventLogCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+	log eventLog
+}
+
+func newEventLogCore(enc zapcore.Encoder, log eventLog, enab zapcore.LevelEnabler) zapcore.Core {
+	return &eventLogCore{
+		LevelEnabler: enab,
+		enc:          enc,
+		log:          log,
+	}
+}
+
+func (c *eventLogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &eventLogCore{
+		LevelEnabler: c.LevelEnabler,
+		enc:          c.enc.Clone(),
+		log:          c.log,
+	}
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+	return clone
+}
+
+func (c *eventLogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *eventLogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	msg := strings.TrimSuffix(buf.String(), zapcore.DefaultLineEnding)
+	buf.Free()
+
+	// the Event Log has no severity below informational nor above error
+	switch ent.Level {
+	case zapcore.DebugLevel, zapcore.InfoLevel:
+		return c.log.Info(eventID, msg)
+	case zapcore.WarnLevel:
+		return c.log.Warning(eventID, msg)
+	default:
+		return c.log.Error(eventID, msg)
+	}
+}
+
+// Sync does nothing, as events are reported as soon as they are written.
+func (c *eventLogCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"runtime"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type event struct {
+	severity string
+	msg      string
+}
+
+type fakeEventLog struct {
+	events []event
+}
+
+func (l *fakeEventLog) Info(eid uint32, msg string) error {
+	l.events = append(l.events, event{"info", msg})
+	return nil
+}
+
+func (l *fakeEventLog) Warning(eid uint32, msg string) error {
+	l.events = append(l.events, event{"warning", msg})
+	return nil
+}
+
+func (l *fakeEventLog) Error(eid uint32, msg string) error {
+	l.events = append(l.events, event{"error", msg})
+	return nil
+}
+
+func (l *fakeEventLog) Close() error {
+	return nil
+}
+
+func TestEventLogCore(t *testing.T) {
+	l := &fakeEventLog{}
+	enc := zapcore.NewConsoleEncoder(zapcore.EncoderConfig{
+		NameKey:    "scope",
+		MessageKey: "msg",
+		LineEnding: zapcore.DefaultLineEnding,
+	})
+	core := newEventLogCore(enc, l, zapcore.InfoLevel).With([]zapcore.Field{zap.String("node", "win-1")})
+
+	for _, lvl := range []zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel, zapcore.FatalLevel} {
+		ent := zapcore.Entry{Level: lvl, LoggerName: "ads", Message: lvl.String()}
+		if ce := core.Check(ent, nil); ce != nil {
+			ce.Write(zap.Int("id", 1))
+		}
+	}
+
+	expected := []event{
+		{"info", `ads	info	{"node": "win-1", "id": 1}`},
+		{"warning", `ads	warn	{"node": "win-1", "id": 1}`},
+		{"error", `ads	error	{"node": "win-1", "id": 1}`},
+		{"error", `ads	fatal	{"node": "win-1", "id": 1}`},
+	}
+	if len(l.events) != len(expected) {
+		t.Fatalf("Got %d events, expecting %d: %v", len(l.events), len(expected), l.events)
+	}
+	for i, e := range expected {
+		if l.events[i] != e {
+			t.Errorf("Got %v, expecting %v", l.events[i], e)
+		}
+	}
+}
+
+func TestEventLogUnsupported(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the event log is available on Windows")
+	}
+
+	o := DefaultOptions()
+	o.EventLogSource = "istio"
+	if err := Configure(o); err == nil {
+		t.Error("Got success, expecting error")
+	}
+	_ = Configure(DefaultOptions())
+}
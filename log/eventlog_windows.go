@@ -0,0 +1,38 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// openEventLog opens the Windows Event Log for the given source, registering the source in the
+// Application log first if it isn't yet. Registering requires administrative privileges, which
+// are therefore only needed the first time a component runs.
+func openEventLog(source string) (eventLog, error) {
+	err := eventlog.InstallAsEventCreate(source, eventlog.Error|eventlog.Warning|eventlog.Info)
+	if err != nil && !strings.Contains(err.Error(), "registry key already exists") {
+		return nil, fmt.Errorf("unable to register event source '%s': %v", source, err)
+	}
+
+	l, err := eventlog.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the event log for source '%s': %v", source, err)
+	}
+	return l, nil
+}
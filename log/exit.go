@@ -0,0 +1,84 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	exitFunc    atomic.Value // func(int)
+	exitHooks   atomic.Value // []func()
+	exitHooksMu sync.Mutex
+)
+
+func init() {
+	exitFunc.Store(os.Exit)
+	exitHooks.Store([]func(){})
+}
+
+// SetExitFunc sets the function called with exit code 1 once a fatal message is output through a
+// scope, which defaults to os.Exit. Embedding applications can use this to shut down gracefully, and
+// tests to intercept fatal paths rather than having the test binary exit. Unless the function doesn't
+// return, logging goes on after the fatal message. A nil function restores os.Exit.
+//
+// This doesn't apply to the fatal messages of the standard golang "log" package, nor of zap loggers.
+func SetExitFunc(exit func(code int)) {
+	if exit == nil {
+		exit = os.Exit
+	}
+	exitFunc.Store(exit)
+}
+
+// AddExitHook registers a function called once a fatal message is output, before the exit function,
+// such as to flush buffers or release resources. Hooks are called in the order they're registered,
+// once the log outputs are synced. A hook panicking doesn't prevent the next hooks from being called.
+func AddExitHook(hook func()) {
+	exitHooksMu.Lock()
+	defer exitHooksMu.Unlock()
+
+	old := exitHooks.Load().([]func())
+	exitHooks.Store(append(old[:len(old):len(old)], hook))
+}
+
+// ClearExitHooks unregisters all the exit hooks.
+func ClearExitHooks() {
+	exitHooksMu.Lock()
+	exitHooks.Store([]func(){})
+	exitHooksMu.Unlock()
+}
+
+// exit calls the exit hooks and then the exit function.
+func exit(code int) {
+	for _, h := range exitHooks.Load().([]func()) {
+		callExitHook(h)
+	}
+	exitFunc.Load().(func(int))(code)
+}
+
+func callExitHook(h func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			pt := funcs.Load().(patchTable)
+			_, _ = fmt.Fprintf(pt.errorSink, "%v log exit hook panic: %v\n", time.Now(), r)
+			_ = pt.errorSink.Sync()
+		}
+	}()
+	h()
+}
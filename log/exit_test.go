@@ -0,0 +1,66 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExitFunc(t *testing.T) {
+	var calls []string
+	SetExitFunc(func(code int) {
+		if code != 1 {
+			t.Errorf("Got exit code %d, expecting 1", code)
+		}
+		calls = append(calls, "exit")
+	})
+	defer SetExitFunc(nil)
+
+	AddExitHook(func() { calls = append(calls, "flush") })
+	AddExitHook(func() { panic("broken") })
+	AddExitHook(func() { calls = append(calls, "cleanup") })
+	defer ClearExitHooks()
+
+	lines, err := captureStdout(func() {
+		if err := Configure(testOptions()); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+
+		Fatal("unrecoverable")
+		Info("still logging")
+		_ = Sync()
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+
+	if got := strings.Join(calls, ","); got != "flush,cleanup,exit" {
+		t.Errorf("Got %s, expecting the hooks to be called in order before exiting", got)
+	}
+	if len(lines) < 2 || !strings.HasSuffix(lines[0], "\tunrecoverable") || !strings.HasSuffix(lines[1], "\tstill logging") {
+		t.Errorf("Got %v, expecting logging to go on once the exit function returns", lines)
+	}
+
+	ClearExitHooks()
+	calls = nil
+	_, _ = captureStdout(func() {
+		_ = Configure(testOptions())
+		Fatal("unrecoverable")
+	})
+	if got := strings.Join(calls, ","); got != "exit" {
+		t.Errorf("Got %s, expecting no hooks once cleared", got)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// FingerprintKey is the name of the field holding the fingerprints of error records, see
+// Options.ErrorFingerprints.
+const FingerprintKey = "fingerprint"
+
+// variable parts of messages, such as quoted strings, identifiers, addresses and counts
+var fingerprintVariables = regexp.MustCompile(`"[^"]*"|'[^']*'|0[xX][0-9a-fA-F]+|[0-9a-fA-F]*[0-9][0-9a-fA-F]*`)
+
+// fingerprintingCore adds a fingerprint field to the error records handed to the wrapped core.
+type fingerprintingCore struct {
+	zapcore.Core
+
+	errType string // the type of the first error added by With
+}
+
+func (c fingerprintingCore) With(fields []zapcore.Field) zapcore.Core {
+	if c.errType == "" {
+		c.errType = errorType(fields)
+	}
+	c.Core = c.Core.With(fields)
+	return c
+}
+
+func (c fingerprintingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c fingerprintingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level >= zapcore.ErrorLevel {
+		errType := errorType(fields)
+		if errType == "" {
+			errType = c.errType
+		}
+		fields = append(fields[:len(fields):len(fields)], zap.String(FingerprintKey, fingerprint(errType, ent)))
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// fingerprint hashes the error type, the message with its variable parts masked, and the function
+// logging a record. Line numbers are left out, so that fingerprints survive unrelated changes of the
+// code.
+func fingerprint(errType string, ent zapcore.Entry) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(errType))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(fingerprintVariables.ReplaceAllString(ent.Message, "?")))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(topFrame()))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func errorType(fields []zapcore.Field) string {
+	for _, f := range fields {
+		if f.Type == zapcore.ErrorType && f.Interface != nil {
+			return fmt.Sprintf("%T", f.Interface)
+		}
+	}
+	return ""
+}
+
+// the packages which log on behalf of their callers
+var loggingPackages = []string{"istio.io/pkg/log.", "go.uber.org/zap", "google.golang.org/grpc/grpclog", "log.", "runtime."}
+
+// topFrame returns the function at the top of the stack which doesn't belong to the logging packages.
+func topFrame() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		f, more := frames.Next()
+		if !isLoggingFrame(f.Function) {
+			return f.Function
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func isLoggingFrame(function string) bool {
+	for _, p := range loggingPackages {
+		if strings.HasPrefix(function, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestErrorFingerprints(t *testing.T) {
+	o := testOptions()
+	o.JSONEncoding = true
+	o.ErrorFingerprints = true
+
+	lines, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Errorf("Got err '%v', expecting success", err)
+		}
+
+		Errorw("unable to push 3 clusters to \"sidecar~10.0.0.1\"", "error", errors.New("timeout"))
+		Errorw("unable to push 12 clusters to \"router~10.0.0.2\"", "error", errors.New("reset"))
+		Errorw("unable to push 12 clusters to \"router~10.0.0.2\"", "error", &os.PathError{Op: "write", Path: "sidecar", Err: errors.New("reset")})
+		Errorf("unable to read the mesh configuration")
+		Info("pushed 3 clusters")
+		zap.L().Error("unable to push 3 clusters to 'sidecar'", zap.Error(errors.New("timeout")))
+		_ = Sync()
+	})
+	if err != nil {
+		t.Errorf("Got error '%v', expected success", err)
+	}
+
+	fingerprints := make([]interface{}, 0, len(lines))
+	for _, l := range lines {
+		if l == "" {
+			continue
+		}
+
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(l), &m); err != nil {
+			t.Fatalf("Got error '%v' parsing '%s'", err, l)
+		}
+		fingerprints = append(fingerprints, m[FingerprintKey])
+	}
+
+	if len(fingerprints) != 6 {
+		t.Fatalf("Got %v, expecting 6 records", lines)
+	}
+	if fingerprints[0] == nil || fingerprints[0] != fingerprints[1] {
+		t.Errorf("Got %v and %v, expecting the same fingerprint", fingerprints[0], fingerprints[1])
+	}
+	if fingerprints[2] == fingerprints[1] || fingerprints[3] == fingerprints[1] {
+		t.Errorf("Got %v, expecting different fingerprints for different errors", fingerprints)
+	}
+	if fingerprints[4] != nil {
+		t.Errorf("Got %v, expecting no fingerprint for info records", fingerprints[4])
+	}
+	if fingerprints[5] == nil {
+		t.Error("Got no fingerprint, expecting the errors logged through zap to be fingerprinted")
+	}
+
+	_ = Configure(DefaultOptions())
+}
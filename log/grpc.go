@@ -0,0 +1,128 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// the frames of the package-level functions of grpclog and of the adapter
+const grpcCallerSkip = 2
+
+// grpcLogger adapts a scope to the logger interface of gRPC.
+type grpcLogger struct {
+	scope *Scope
+}
+
+// NewGrpcLogger returns a gRPC logger outputting messages through scope, such that the logs of gRPC
+// internals are subject to the same levels and sinks as the rest of the process:
+//
+//	grpclog.SetLoggerV2(log.NewGrpcLogger(log.RegisterScope("grpc", "gRPC internals", 0)))
+//
+// gRPC info, warning, error and fatal messages are output at the corresponding levels. gRPC verbose
+// messages, for which V(l) is called with l greater than 0, are only output when the scope is at the
+// debug level. The logger also provides the InfoDepth, WarningDepth, ErrorDepth and FatalDepth methods
+// that let gRPC report the location of the code logging messages.
+func NewGrpcLogger(scope *Scope) grpclog.LoggerV2 {
+	return &grpcLogger{scope: scope.withCallerSkip(grpcCallerSkip)}
+}
+
+func sprintln(args []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
+
+func (g *grpcLogger) Info(args ...interface{}) {
+	g.scope.Infoa(args...)
+}
+
+func (g *grpcLogger) Infoln(args ...interface{}) {
+	if g.scope.InfoEnabled() {
+		g.scope.Info(sprintln(args))
+	}
+}
+
+func (g *grpcLogger) Infof(format string, args ...interface{}) {
+	g.scope.Infof(format, args...)
+}
+
+func (g *grpcLogger) Warning(args ...interface{}) {
+	g.scope.Warna(args...)
+}
+
+func (g *grpcLogger) Warningln(args ...interface{}) {
+	if g.scope.WarnEnabled() {
+		g.scope.Warn(sprintln(args))
+	}
+}
+
+func (g *grpcLogger) Warningf(format string, args ...interface{}) {
+	g.scope.Warnf(format, args...)
+}
+
+func (g *grpcLogger) Error(args ...interface{}) {
+	g.scope.Errora(args...)
+}
+
+func (g *grpcLogger) Errorln(args ...interface{}) {
+	if g.scope.ErrorEnabled() {
+		g.scope.Error(sprintln(args))
+	}
+}
+
+func (g *grpcLogger) Errorf(format string, args ...interface{}) {
+	g.scope.Errorf(format, args...)
+}
+
+func (g *grpcLogger) Fatal(args ...interface{}) {
+	g.scope.Fatala(args...)
+}
+
+func (g *grpcLogger) Fatalln(args ...interface{}) {
+	if g.scope.FatalEnabled() {
+		g.scope.Fatal(sprintln(args))
+	}
+}
+
+func (g *grpcLogger) Fatalf(format string, args ...interface{}) {
+	g.scope.Fatalf(format, args...)
+}
+
+func (g *grpcLogger) V(l int) bool {
+	if l <= 0 {
+		return g.scope.InfoEnabled()
+	}
+	return g.scope.DebugEnabled()
+}
+
+// The depth methods count frames from their caller, rather than from the caller of grpclog.
+
+func (g *grpcLogger) InfoDepth(depth int, args ...interface{}) {
+	g.scope.withCallerSkip(depth + 1 - grpcCallerSkip).Infoa(args...)
+}
+
+func (g *grpcLogger) WarningDepth(depth int, args ...interface{}) {
+	g.scope.withCallerSkip(depth + 1 - grpcCallerSkip).Warna(args...)
+}
+
+func (g *grpcLogger) ErrorDepth(depth int, args ...interface{}) {
+	g.scope.withCallerSkip(depth + 1 - grpcCallerSkip).Errora(args...)
+}
+
+func (g *grpcLogger) FatalDepth(depth int, args ...interface{}) {
+	g.scope.withCallerSkip(depth + 1 - grpcCallerSkip).Fatala(args...)
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"regexp"
+	"testing"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+func TestGrpcLogger(t *testing.T) {
+	resetGlobals()
+
+	var l grpclog.LoggerV2
+	lines, _ := captureStdout(func() {
+		o := DefaultOptions()
+		o.GrpcScope = "grpc"
+		o.SetLogCallers("grpc", true)
+		_ = Configure(o)
+
+		s := FindScope("grpc")
+		if s == nil {
+			t.Fatal("Expecting the grpc scope to be registered")
+		}
+		l = NewGrpcLogger(s)
+
+		grpclog.Info("grpc-info")
+		grpclog.Warningln("grpc-warn", 1)
+		grpclog.Errorf("grpc-error %d", 2)
+		if grpclog.V(2) {
+			t.Error("Expecting verbose messages to be disabled at info level")
+		}
+
+		s.SetOutputLevel(DebugLevel)
+		if !grpclog.V(2) {
+			t.Error("Expecting verbose messages to be enabled at debug level")
+		}
+
+		s.SetOutputLevel(ErrorLevel)
+		grpclog.Info("grpc-info-2")
+		grpclog.Warning("grpc-warn-2")
+		grpclog.Error("grpc-error-3")
+		if grpclog.V(0) {
+			t.Error("Expecting messages to be disabled at error level")
+		}
+
+		l.(interface{ ErrorDepth(int, ...interface{}) }).ErrorDepth(0, "grpc-error-depth")
+		_ = Sync()
+	})
+	_ = Configure(DefaultOptions())
+
+	patterns := []string{
+		timePattern + "\tinfo\tgrpc\tlog/grpc_test.go:.*\tgrpc-info$",
+		timePattern + "\twarn\tgrpc\tlog/grpc_test.go:.*\tgrpc-warn 1$",
+		timePattern + "\terror\tgrpc\tlog/grpc_test.go:.*\tgrpc-error 2$",
+		timePattern + "\terror\tgrpc\tlog/grpc_test.go:.*\tgrpc-error-3$",
+		timePattern + "\terror\tgrpc\tlog/grpc_test.go:.*\tgrpc-error-depth$",
+		"",
+	}
+	if len(lines) != len(patterns) {
+		t.Fatalf("Got %d lines %v, expecting %d", len(lines), lines, len(patterns))
+	}
+	for i, pat := range patterns {
+		if match, _ := regexp.MatchString(pat, lines[i]); !match {
+			t.Errorf("Got '%v', expected a match with '%v'", lines[i], pat)
+		}
+	}
+}
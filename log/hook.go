@@ -0,0 +1,86 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Hook inspects a log record before it reaches the outputs. A hook can modify the entry of the record,
+// such as its message or level, and returns the fields of the record, to which it can add fields or
+// from which it can remove fields. Hooks must not modify the fields they're given in place, and must
+// copy them to modify them. A hook returning false drops the record.
+//
+//	log.AddHook(func(ent *zapcore.Entry, fields []zapcore.Field) ([]zapcore.Field, bool) {
+//		return append(fields[:len(fields):len(fields)], zap.String("pod", podName)), true
+//	})
+type Hook func(ent *zapcore.Entry, fields []zapcore.Field) ([]zapcore.Field, bool)
+
+var (
+	hooks   atomic.Value // []Hook
+	hooksMu sync.Mutex
+)
+
+func init() {
+	hooks.Store([]Hook(nil))
+}
+
+// AddHook registers a hook called with all log records, including the records captured from the
+// standard golang "log" package, zap and gRPC. Hooks are called in the order they're registered, each
+// with the record returned by the previous one, and before the records are redacted, see RedactFields.
+// Hooks are called concurrently when records are logged concurrently.
+func AddHook(h Hook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+
+	old := hooks.Load().([]Hook)
+	hooks.Store(append(old[:len(old):len(old)], h))
+}
+
+// ClearHooks unregisters all the hooks.
+func ClearHooks() {
+	hooksMu.Lock()
+	hooks.Store([]Hook(nil))
+	hooksMu.Unlock()
+}
+
+// hookingCore calls the hooks with the log records handed to the wrapped core.
+type hookingCore struct {
+	zapcore.Core
+}
+
+func (c hookingCore) With(fields []zapcore.Field) zapcore.Core {
+	return hookingCore{c.Core.With(fields)}
+}
+
+func (c hookingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c hookingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	for _, h := range hooks.Load().([]Hook) {
+		var keep bool
+		if fields, keep = h(&ent, fields); !keep {
+			return nil
+		}
+	}
+	return c.Core.Write(ent, fields)
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestHooks(t *testing.T) {
+	ClearHooks()
+	defer ClearHooks()
+	ClearRedactions()
+	defer ClearRedactions()
+
+	var order []string
+	AddHook(func(ent *zapcore.Entry, fields []zapcore.Field) ([]zapcore.Field, bool) {
+		order = append(order, "drop")
+		return fields, !strings.HasPrefix(ent.Message, "GET /healthz")
+	})
+	AddHook(func(ent *zapcore.Entry, fields []zapcore.Field) ([]zapcore.Field, bool) {
+		order = append(order, "enrich")
+		ent.Message = strings.ToUpper(ent.Message)
+		return append(fields[:len(fields):len(fields)], zap.String("pod", "istiod-1"), zap.String("token", "secret")), true
+	})
+	RedactFields("token")
+
+	lines, err := captureStdout(func() {
+		if err := Configure(testOptions()); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+
+		Info("GET /healthz")
+		Infow("pushed", "count", 3)
+		zap.L().Info("from zap")
+		_ = Sync()
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+
+	expected := []string{
+		"\tPUSHED\t{\"count\": 3, \"pod\": \"istiod-1\", \"token\": \"[REDACTED]\"}",
+		"\tFROM ZAP\t{\"pod\": \"istiod-1\", \"token\": \"[REDACTED]\"}",
+	}
+	if len(lines) < len(expected) {
+		t.Fatalf("Got %v, expecting %d lines", lines, len(expected))
+	}
+	for i, e := range expected {
+		if !strings.HasSuffix(lines[i], e) {
+			t.Errorf("Got '%s', expecting '%s'", lines[i], e)
+		}
+	}
+
+	if got := strings.Join(order, ","); got != "drop,drop,enrich,drop,enrich" {
+		t.Errorf("Got %s, expecting the hooks to be called in order", got)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// journalSocket is the socket of the native protocol of systemd-journald.
+var journalSocket = "/run/systemd/journal/socket"
+
+// journal sends entries encoded in the native protocol of systemd-journald.
+type journal interface {
+	send(data []byte) error
+	Close() error
+}
+
+// journalPriorities maps levels to syslog priorities.
+var journalPriorities = map[zapcore.Level]int{
+	zapcore.DebugLevel:  7, // debug
+	zapcore.InfoLevel:   6, // info
+	zapcore.WarnLevel:   4, // warning
+	zapcore.ErrorLevel:  3, // err
+	zapcore.DPanicLevel: 2, // crit
+	zapcore.PanicLevel:  2, // crit
+	zapcore.FatalLevel:  2, // crit
+}
+
+// journaldCore sends log records to systemd-journald, as journal entries holding the message, priority
+// and scope of records, as well as their structured fields under upper case names. The caller is sent
+// as the well-known CODE_FILE, CODE_LINE and CODE_FUNC fields.
+type journaldCore struct {
+	zapcore.LevelEnabler
+	identifier string
+	fields     []zapcore.Field
+	journal    journal
+}
+
+func newJournaldCore(identifier string, j journal, enab zapcore.LevelEnabler) zapcore.Core {
+	if identifier == "" {
+		identifier = filepath.Base(os.Args[0])
+	}
+
+	return &journaldCore{
+		LevelEnabler: enab,
+		identifier:   identifier,
+		journal:      j,
+	}
+}
+
+func (c *journaldCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(clone.fields[:len(clone.fields):len(clone.fields)], fields...)
+	return &clone
+}
+
+func (c *journaldCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *journaldCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var buf bytes.Buffer
+	appendJournalField(&buf, "MESSAGE", ent.Message)
+	appendJournalField(&buf, "PRIORITY", strconv.Itoa(journalPriorities[ent.Level]))
+	appendJournalField(&buf, "SYSLOG_IDENTIFIER", c.identifier)
+	if ent.LoggerName != "" {
+		appendJournalField(&buf, "SCOPE", ent.LoggerName)
+	}
+	if ent.Caller.Defined {
+		appendJournalField(&buf, "CODE_FILE", ent.Caller.File)
+		appendJournalField(&buf, "CODE_LINE", strconv.Itoa(ent.Caller.Line))
+		if f := runtime.FuncForPC(ent.Caller.PC); f != nil {
+			appendJournalField(&buf, "CODE_FUNC", f.Name())
+		}
+	}
+	if ent.Stack != "" {
+		appendJournalField(&buf, "STACK", ent.Stack)
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	keys := make([]string, 0, len(enc.Fields))
+	for k := range enc.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		appendJournalField(&buf, journalFieldName(k), journalFieldValue(enc.Fields[k]))
+	}
+
+	return c.journal.send(buf.Bytes())
+}
+
+// Sync does nothing, as entries are sent as soon as they are written.
+func (c *journaldCore) Sync() error {
+	return nil
+}
+
+// appendJournalField encodes a field in the native protocol, where values holding line breaks are
+// preceded by their size rather than followed by a line break.
+func appendJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.ContainsRune(value, '\n') {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalFieldName turns the key of a structured field into a valid journal field name, made of upper
+// case letters, digits and underscores, and not starting with an underscore nor a digit, as those are
+// reserved to fields set by journald.
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, b := range name {
+		if (b < 'A' || b > 'Z') && (b < '0' || b > '9') {
+			name[i] = '_'
+		}
+	}
+
+	if len(name) == 0 || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		return "F_" + string(name)
+	}
+	return string(name)
+}
+
+func journalFieldValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case map[string]interface{}, []interface{}:
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+type journalConn struct {
+	conn *net.UnixConn
+	addr *net.UnixAddr
+}
+
+// openJournal connects to systemd-journald, failing if it doesn't run on this host.
+func openJournal() (journal, error) {
+	if _, err := os.Stat(journalSocket); err != nil {
+		return nil, fmt.Errorf("unable to connect to journald: %v", err)
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to journald: %v", err)
+	}
+
+	return &journalConn{
+		conn: conn,
+		addr: &net.UnixAddr{Name: journalSocket, Net: "unixgram"},
+	}, nil
+}
+
+func (j *journalConn) send(data []byte) error {
+	_, _, err := j.conn.WriteMsgUnix(data, nil, j.addr)
+	if err == nil || !(errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)) {
+		return err
+	}
+
+	// entries too large for a datagram are passed as a file descriptor instead
+	f, err := os.CreateTemp("/dev/shm", "journal.")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := os.Remove(f.Name()); err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+
+	_, _, err = j.conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), j.addr)
+	return err
+}
+
+func (j *journalConn) Close() error {
+	return j.conn.Close()
+}
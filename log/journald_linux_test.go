@@ -0,0 +1,101 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestJournald(t *testing.T) {
+	resetGlobals()
+
+	dir, err := ioutil.TempDir("", "journald")
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	defer os.RemoveAll(dir)
+
+	old := journalSocket
+	journalSocket = filepath.Join(dir, "socket")
+	defer func() { journalSocket = old }()
+
+	o := DefaultOptions()
+	o.Journald = true
+	o.JournaldIdentifier = "pilot-agent"
+	o.OutputPaths = nil
+	if err := Configure(o); err == nil {
+		t.Error("Got success, expecting error as journald doesn't run")
+	}
+
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	defer l.Close()
+
+	if err := Configure(o); err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	defer func() { _ = Configure(DefaultOptions()) }()
+
+	Warnw("Hello", "count", 3)
+
+	buf := make([]byte, 4096)
+	n, err := l.Read(buf)
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	fields := parseJournalEntry(t, buf[:n])
+	if fields["MESSAGE"] != "Hello" || fields["PRIORITY"] != "4" || fields["SYSLOG_IDENTIFIER"] != "pilot-agent" || fields["COUNT"] != "3" {
+		t.Errorf("Got %v, expecting the journal fields of the record", fields)
+	}
+
+	// entries larger than the maximum datagram size are passed as a file descriptor
+	large := strings.Repeat("x", 1024*1024)
+	Info(large)
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := l.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("Got %v, %v, expecting a control message", msgs, err)
+	}
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("Got %v, %v, expecting a file descriptor", fds, err)
+	}
+
+	f := os.NewFile(uintptr(fds[0]), "journal")
+	defer f.Close()
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	if fields := parseJournalEntry(t, data); fields["MESSAGE"] != large {
+		t.Errorf("Got a message of %d bytes, expecting %d", len(fields["MESSAGE"]), len(large))
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type fakeJournal struct {
+	entries [][]byte
+}
+
+func (j *fakeJournal) send(data []byte) error {
+	j.entries = append(j.entries, append([]byte(nil), data...))
+	return nil
+}
+
+func (j *fakeJournal) Close() error {
+	return nil
+}
+
+// parseJournalEntry decodes an entry encoded in the native protocol of journald.
+func parseJournalEntry(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		i := bytes.IndexAny(data, "=\n")
+		if i < 0 {
+			t.Fatalf("Got %q, expecting a field", data)
+		}
+
+		name := string(data[:i])
+		if data[i] == '=' {
+			data = data[i+1:]
+			end := bytes.IndexByte(data, '\n')
+			fields[name] = string(data[:end])
+			data = data[end+1:]
+			continue
+		}
+
+		data = data[i+1:]
+		size := binary.LittleEndian.Uint64(data[:8])
+		fields[name] = string(data[8 : 8+size])
+		data = data[8+size+1:]
+	}
+	return fields
+}
+
+func TestJournaldCore(t *testing.T) {
+	j := &fakeJournal{}
+	core := newJournaldCore("pilot-agent", j, zapcore.InfoLevel).With([]zapcore.Field{zap.String("node-id", "node~1")})
+
+	for _, lvl := range []zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel, zapcore.FatalLevel} {
+		ent := zapcore.Entry{Level: lvl, LoggerName: "ads", Message: "Hello"}
+		if ce := core.Check(ent, nil); ce != nil {
+			ce.Write(zap.Int("count", 3), zap.Error(errors.New("two\nlines")), zap.Strings("1tags", []string{"a", "b"}))
+		}
+	}
+
+	if len(j.entries) != 4 {
+		t.Fatalf("Got %d entries, expecting 4", len(j.entries))
+	}
+
+	for i, priority := range []string{"6", "4", "3", "2"} {
+		fields := parseJournalEntry(t, j.entries[i])
+		expected := map[string]string{
+			"MESSAGE":           "Hello",
+			"PRIORITY":          priority,
+			"SYSLOG_IDENTIFIER": "pilot-agent",
+			"SCOPE":             "ads",
+			"NODE_ID":           "node~1",
+			"COUNT":             "3",
+			"ERROR":             "two\nlines",
+			"F_1TAGS":           `["a","b"]`,
+		}
+		for k, v := range expected {
+			if fields[k] != v {
+				t.Errorf("Got %s=%q, expecting %q", k, fields[k], v)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// klogHeader matches the header of klog records, such as "I1014 19:02:23.123456   12345 reflector.go:123] ",
+// capturing the severity and the source location.
+var klogHeader = regexp.MustCompile(`^([IWEF])\d{4} \d{2}:\d{2}:\d{2}\.\d{6}\s+\d+ ([^\]]+:\d+)\] `)
+
+type klogWriter struct {
+	scope *Scope
+}
+
+// NewKlogWriter returns a writer outputting the records klog writes to it through scope, such that
+// the logs of client-go are subject to the same levels and sinks as the rest of the process. klog
+// writes records of all severities to its info output, which is where the writer goes:
+//
+//	klog.SetOutputBySeverity("INFO", log.NewKlogWriter(log.RegisterScope("klog", "client-go", 0)))
+//
+// with klog's -logtostderr flag disabled. Records are output at the level corresponding to their
+// severity, except for fatal ones which are output at the error level, as klog exits the process on
+// its own. The klog source location of records is output as a source field.
+func NewKlogWriter(scope *Scope) io.Writer {
+	return &klogWriter{scope: scope}
+}
+
+func (w *klogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+
+	output := w.scope.Info
+	var fields []zapcore.Field
+	if m := klogHeader.FindStringSubmatch(msg); m != nil {
+		switch m[1] {
+		case "W":
+			output = w.scope.Warn
+		case "E", "F":
+			output = w.scope.Error
+		}
+		fields = []zapcore.Field{zap.String("source", m[2])}
+		msg = msg[len(m[0]):]
+	}
+
+	output(msg, fields...)
+	return len(p), nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestKlogWriter(t *testing.T) {
+	s := RegisterScope("TestKlogWriter", "", 0)
+	s.SetOutputLevel(InfoLevel)
+	records := recordWrites(t)
+
+	w := NewKlogWriter(s)
+	_, _ = io.WriteString(w, "I1014 19:02:23.123456   12345 reflector.go:123] Listing pods\n")
+	_, _ = io.WriteString(w, "W1014 19:02:23.123456       1 reflector.go:302] watch closed\n")
+	_, _ = io.WriteString(w, "E1014 19:02:23.123456       1 leaderelection.go:331] error retrieving lease\n")
+	_, _ = io.WriteString(w, "F1014 19:02:23.123456       1 main.go:10] giving up\n")
+	_, _ = io.WriteString(w, "no header\n")
+
+	expected := []struct {
+		level  zapcore.Level
+		msg    string
+		source interface{}
+	}{
+		{zapcore.InfoLevel, "Listing pods", "reflector.go:123"},
+		{zapcore.WarnLevel, "watch closed", "reflector.go:302"},
+		{zapcore.ErrorLevel, "error retrieving lease", "leaderelection.go:331"},
+		{zapcore.ErrorLevel, "giving up", "main.go:10"},
+		{zapcore.InfoLevel, "no header", nil},
+	}
+	if len(*records) != len(expected) {
+		t.Fatalf("Got %d records, expecting %d", len(*records), len(expected))
+	}
+	for i, exp := range expected {
+		r := (*records)[i]
+		if r.entry.Level != exp.level || r.entry.Message != exp.msg || r.fields["source"] != exp.source {
+			t.Errorf("Got %v %q %v, expecting %v %q %v", r.entry.Level, r.entry.Message, r.fields["source"], exp.level, exp.msg, exp.source)
+		}
+	}
+}
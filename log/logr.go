@@ -0,0 +1,97 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"github.com/go-logr/logr"
+)
+
+// logrSink adapts a scope to the logr.LogSink interface, see NewLogr.
+type logrSink struct {
+	scope *Scope
+	name  string
+}
+
+var _ logr.CallDepthLogSink = &logrSink{}
+
+// NewLogr returns a logr.Logger, the structured logging interface used by controller-runtime, outputting
+// messages through scope, such that the logs of controllers are subject to the same levels and sinks
+// as the rest of the process:
+//
+//	ctrl.SetLogger(log.NewLogr(log.RegisterScope("controller", "Logs of the controllers", 0)))
+//
+// Info messages at verbosity 0 are output at the info level, and more verbose ones at the debug
+// level. Names given through WithName are joined with periods, and output as a logger field.
+func NewLogr(s *Scope) logr.Logger {
+	return logr.New(&logrSink{scope: s})
+}
+
+// Init skips the frames of logr.Logger when reporting callers.
+func (l *logrSink) Init(info logr.RuntimeInfo) {
+	// the frame of the sink itself is skipped too
+	l.scope = l.scope.withCallerSkip(info.CallDepth + 1)
+}
+
+func (l *logrSink) Enabled(level int) bool {
+	if level <= 0 {
+		return l.scope.InfoEnabled()
+	}
+	return l.scope.DebugEnabled()
+}
+
+func (l *logrSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	if level <= 0 {
+		l.scope.Infow(msg, l.withName(keysAndValues)...)
+	} else {
+		l.scope.Debugw(msg, l.withName(keysAndValues)...)
+	}
+}
+
+// Error outputs a message at the error level, with the error as an error field.
+func (l *logrSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	if l.scope.ErrorEnabled() {
+		l.scope.Errorw(msg, append(l.withName(keysAndValues), "error", err)...)
+	}
+}
+
+func (l *logrSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	v := *l
+	v.scope = l.scope.WithLabels(keysAndValues...)
+	return &v
+}
+
+// WithName appends name to the name of l.
+func (l *logrSink) WithName(name string) logr.LogSink {
+	v := *l
+	if v.name != "" {
+		v.name += "." + name
+	} else {
+		v.name = name
+	}
+	return &v
+}
+
+func (l *logrSink) WithCallDepth(depth int) logr.LogSink {
+	v := *l
+	v.scope = l.scope.withCallerSkip(depth)
+	return &v
+}
+
+func (l *logrSink) withName(keysAndValues []interface{}) []interface{} {
+	if l.name == "" {
+		return keysAndValues
+	}
+	return append([]interface{}{"logger", l.name}, keysAndValues...)
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap/zapcore"
+)
+
+type record struct {
+	entry  zapcore.Entry
+	fields map[string]interface{}
+}
+
+// recordWrites replaces the write function for the duration of a test.
+func recordWrites(t *testing.T) *[]record {
+	old := funcs.Load().(patchTable)
+	t.Cleanup(func() { funcs.Store(old) })
+
+	var records []record
+	pt := old
+	pt.write = func(ent zapcore.Entry, fields []zapcore.Field) error {
+		enc := zapcore.NewMapObjectEncoder()
+		for _, f := range fields {
+			f.AddTo(enc)
+		}
+		records = append(records, record{ent, enc.Fields})
+		return nil
+	}
+	funcs.Store(pt)
+	return &records
+}
+
+func TestLogr(t *testing.T) {
+	s := RegisterScope("TestLogr", "", 0)
+	s.SetOutputLevel(InfoLevel)
+	s.SetLogCallers(true)
+	records := recordWrites(t)
+
+	var l logr.Logger = NewLogr(s).WithName("controller").WithName("pods").WithValues("namespace", "default")
+	l.Info("reconciling", "pod", "p1")
+	l.V(1).Info("verbose")
+	l.Error(errors.New("boom"), "failed", "pod", "p1")
+
+	if !l.Enabled() || l.V(1).Enabled() {
+		t.Error("Expecting verbosity 0 only to be enabled at the info level")
+	}
+	s.SetOutputLevel(DebugLevel)
+	if !l.V(2).Enabled() {
+		t.Error("Expecting all verbosities to be enabled at the debug level")
+	}
+	l.V(1).Info("verbose")
+	helperLine := logrHelper(l.WithCallDepth(1), "helped")
+
+	if len(*records) != 4 {
+		t.Fatalf("Got %d records, expecting 4", len(*records))
+	}
+
+	for i, exp := range []struct {
+		level zapcore.Level
+		msg   string
+	}{{zapcore.InfoLevel, "reconciling"}, {zapcore.ErrorLevel, "failed"}, {zapcore.DebugLevel, "verbose"}, {zapcore.InfoLevel, "helped"}} {
+		r := (*records)[i]
+		if r.entry.Level != exp.level || r.entry.Message != exp.msg {
+			t.Errorf("Got %v %q, expecting %v %q", r.entry.Level, r.entry.Message, exp.level, exp.msg)
+		}
+		if r.fields["logger"] != "controller.pods" || r.fields["namespace"] != "default" {
+			t.Errorf("Got %v, expecting the name and values of the logger", r.fields)
+		}
+		if !strings.HasSuffix(r.entry.Caller.File, "logr_test.go") {
+			t.Errorf("Got caller %v, expecting the test", r.entry.Caller)
+		}
+	}
+	if (*records)[1].fields["error"] != "boom" {
+		t.Errorf("Got %v, expecting the error", (*records)[1].fields)
+	}
+	if (*records)[3].entry.Caller.Line == helperLine {
+		t.Errorf("Got caller %v, expecting the caller of the helper", (*records)[3].entry.Caller)
+	}
+}
+
+// logrHelper outputs a message on behalf of its caller, and returns the line outputting it.
+func logrHelper(l logr.Logger, msg string) int {
+	_, _, line, _ := runtime.Caller(0)
+	l.Info(msg)
+	return line + 1
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+// Stability is the stability of the messages output through a scope, which tells whether tooling and
+// alerts can rely on them.
+type Stability string
+
+const (
+	// StabilityStable denotes messages which only change in backward compatible ways.
+	StabilityStable Stability = "stable"
+	// StabilityBeta denotes messages which may change, but whose intent is settled.
+	StabilityBeta Stability = "beta"
+	// StabilityAlpha denotes messages which may change or go away at any time.
+	StabilityAlpha Stability = "alpha"
+	// StabilityDeprecated denotes messages which are going away.
+	StabilityDeprecated Stability = "deprecated"
+)
+
+// ScopeMetadata describes a scope, such that the many scopes of large binaries are discoverable.
+type ScopeMetadata struct {
+	// Component is the name of the component owning the scope, such as "pilot" or "citadel".
+	Component string
+
+	// Stability is the stability of the messages output through the scope.
+	Stability Stability
+
+	// DefaultLevelRationale explains the output level the scope is configured with by default, such
+	// as why debug messages are worth enabling.
+	DefaultLevelRationale string
+}
+
+// Metadata returns the metadata the scope was registered with.
+func (s *Scope) Metadata() ScopeMetadata {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return s.registered().metadata
+}
+
+// FilterScopes returns a snapshot of the currently defined scopes which keep returns true for, such as
+// the scopes owned by a given component:
+//
+//	pilot := log.FilterScopes(func(s *log.Scope) bool { return s.Metadata().Component == "pilot" })
+func FilterScopes(keep func(*Scope) bool) map[string]*Scope {
+	all := Scopes()
+	for name, s := range all {
+		if !keep(s) {
+			delete(all, name)
+		}
+	}
+	return all
+}
@@ -0,0 +1,48 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"testing"
+)
+
+func TestScopeMetadata(t *testing.T) {
+	md := ScopeMetadata{
+		Component:             "pilot",
+		Stability:             StabilityBeta,
+		DefaultLevelRationale: "pushes are too frequent for info",
+	}
+
+	ads := RegisterScope("TestScopeMetadata-ads", "z", 0, md)
+	_ = RegisterScope("TestScopeMetadata-other", "z", 0)
+
+	if got := ads.WithLabels("k", "v").Metadata(); got != md {
+		t.Errorf("Got %v, expecting %v", got, md)
+	}
+
+	if got := RegisterScope("TestScopeMetadata-ads", "z", 0); got != ads || got.Metadata() != md {
+		t.Errorf("Got %v, expecting registering again without metadata to keep it", got.Metadata())
+	}
+
+	md.Stability = StabilityStable
+	if got := RegisterScope("TestScopeMetadata-ads", "z", 0, md).Metadata(); got != md {
+		t.Errorf("Got %v, expecting %v", got, md)
+	}
+
+	pilot := FilterScopes(func(s *Scope) bool { return s.Metadata().Component == "pilot" })
+	if len(pilot) != 1 || pilot["TestScopeMetadata-ads"] != ads {
+		t.Errorf("Got %v, expecting only the pilot scope", pilot)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"go.uber.org/zap/zapcore"
+
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	scopeLabel = monitoring.MustCreateLabel("scope")
+	levelLabel = monitoring.MustCreateLabel("level")
+
+	records = monitoring.NewInt64Counter(
+		"istio_log_records_total",
+		"Number of log records output, by scope and level",
+		monitoring.WithLabels(scopeLabel, levelLabel),
+	)
+
+	droppedRecords = monitoring.NewSum(
+		"istio_log_dropped_records_total",
+		"Number of log records dropped because the asynchronous log buffer was full",
+	)
+
+	droppedNetworkRecords = monitoring.NewSum(
+		"istio_log_network_dropped_records_total",
+		"Number of log records dropped because they couldn't be sent over the network in time",
+	)
+)
+
+func init() {
+	monitoring.MustRegister(droppedRecords, droppedNetworkRecords)
+}
+
+// scopeRecords returns the counters of the records output by a scope, by level.
+func scopeRecords(scope string) map[zapcore.Level]monitoring.Int64Counter {
+	counters := make(map[zapcore.Level]monitoring.Int64Counter, len(levelToZap))
+	for l, zl := range levelToZap {
+		if l != NoneLevel {
+			counters[zl] = records.With(scopeLabel.Value(scope), levelLabel.Value(levelToString[l]))
+		}
+	}
+	return counters
+}
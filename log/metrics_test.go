@@ -0,0 +1,92 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"istio.io/pkg/monitoring"
+)
+
+func TestRecordMetrics(t *testing.T) {
+	o := testOptions()
+	o.RecordMetrics = true
+
+	_, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Errorf("Got err '%v', expecting success", err)
+		}
+
+		Error("unable to push")
+		Error("unable to push")
+		Info("pushed")
+		Debug("pushing")
+
+		// the records dropped by sampling and rate limits aren't output, so they aren't counted
+		limited := RegisterScope("metricslimited", "For testing", 0)
+		limited.SetRateLimit(RateLimit{PerSecond: 0.001, Burst: 1})
+		sampled := RegisterScope("metricssampled", "For testing", 0)
+		sampled.SetSampling(SamplingConfig{Initial: 1})
+		for i := 0; i < 5; i++ {
+			limited.Warn("limited")
+			sampled.Warn("sampled")
+		}
+		_ = Sync()
+	})
+	if err != nil {
+		t.Errorf("Got error '%v', expected success", err)
+	}
+
+	s := httptest.NewServer(monitoring.ExporterHandler())
+	defer s.Close()
+
+	metrics := scrapeMetrics(t, s.URL)
+	for _, e := range []string{
+		`istio_log_records_total{level="error",scope="default"} 2`,
+		`istio_log_records_total{level="info",scope="default"} 1`,
+		`istio_log_records_total{level="warn",scope="metricslimited"} 1`,
+		`istio_log_records_total{level="warn",scope="metricssampled"} 1`,
+	} {
+		if !strings.Contains(metrics, e) {
+			t.Errorf("Got %s, expecting %s", metrics, e)
+		}
+	}
+	if strings.Contains(metrics, `level="debug"`) {
+		t.Errorf("Got %s, expecting the records which aren't output not to be counted", metrics)
+	}
+
+	_ = Configure(DefaultOptions())
+	if metrics = scrapeMetrics(t, s.URL); strings.Contains(metrics, "istio_log_records_total") {
+		t.Errorf("Got %s, expecting the records not to be counted by default", metrics)
+	}
+}
+
+func scrapeMetrics(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Unable to scrape the metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Unable to read the metrics: %v", err)
+	}
+	return string(body)
+}
@@ -0,0 +1,262 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// The schemes of the output paths sending logs over the network, see Options.OutputPaths.
+const (
+	tcpScheme = "tcp"
+	udpScheme = "udp"
+	tlsScheme = "tls"
+)
+
+const (
+	defaultNetBufferSize = 1024
+	netDialTimeout       = 10 * time.Second
+	netWriteTimeout      = 10 * time.Second
+	netMinBackoff        = 100 * time.Millisecond
+	netMaxBackoff        = 30 * time.Second
+)
+
+// can be replaced by tests
+var netSyncTimeout = 5 * time.Second
+
+func init() {
+	for _, scheme := range []string{tcpScheme, udpScheme, tlsScheme} {
+		if err := zap.RegisterSink(scheme, newNetSink); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// isNetworkPath returns whether an output path sends logs over the network.
+func isNetworkPath(path string) bool {
+	for _, scheme := range []string{tcpScheme, udpScheme, tlsScheme} {
+		if strings.HasPrefix(path, scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+// netSink sends log records to a collector over TCP, UDP or TLS. Records are handed over to a goroutine
+// through a buffer, such that callers never wait on the network. The goroutine connects to the
+// collector, and reconnects with an exponential backoff once the connection fails, meanwhile keeping
+// the records it fails to send. Records written while the buffer is full are dropped and counted.
+type netSink struct {
+	dropped uint64
+
+	network string
+	address string
+	tls     *tls.Config
+
+	records chan asyncRecord
+	stopCh  chan struct{}
+	stop    sync.Once
+	done    chan struct{}
+}
+
+// newNetSink opens a sink for an URL of the form <scheme>://<host>:<port>, which accepts the query
+// parameters buffer, the number of records buffered, and for TLS ca, the path of the PEM certificates
+// of the certificate authorities of the collector, and servername, the name the certificate of the
+// collector is issued for.
+func newNetSink(u *url.URL) (zap.Sink, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("no host specified in output path '%s'", u)
+	}
+
+	s := &netSink{
+		network: u.Scheme,
+		address: u.Host,
+		stopCh:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	q := u.Query()
+	size := defaultNetBufferSize
+	if b := q.Get("buffer"); b != "" {
+		var err error
+		if size, err = strconv.Atoi(b); err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid buffer size '%s' in output path '%s'", b, u)
+		}
+	}
+	s.records = make(chan asyncRecord, size)
+
+	if u.Scheme == tlsScheme {
+		s.network = tcpScheme
+		s.tls = &tls.Config{ServerName: q.Get("servername")}
+		if s.tls.ServerName == "" {
+			s.tls.ServerName = u.Hostname()
+		}
+
+		if ca := q.Get("ca"); ca != "" {
+			pem, err := ioutil.ReadFile(ca)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read the certificate authorities of output path '%s': %v", u, err)
+			}
+			s.tls.RootCAs = x509.NewCertPool()
+			if !s.tls.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate authorities found in '%s'", ca)
+			}
+		}
+	}
+
+	go s.run()
+	return s, nil
+}
+
+func (s *netSink) Write(p []byte) (int, error) {
+	// the encoder reuses its buffer once this returns
+	data := make([]byte, len(p))
+	copy(data, p)
+
+	select {
+	case s.records <- asyncRecord{data: data}:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+		droppedNetworkRecords.Increment()
+	}
+
+	return len(p), nil
+}
+
+// Sync waits for the records buffered so far to be sent, for up to netSyncTimeout as the collector
+// may be unreachable.
+func (s *netSink) Sync() error {
+	flushed := make(chan error, 1)
+	timeout := time.NewTimer(netSyncTimeout)
+	defer timeout.Stop()
+
+	select {
+	case s.records <- asyncRecord{flushed: flushed}:
+	case <-s.done:
+		return nil
+	case <-timeout.C:
+		return fmt.Errorf("timed out syncing the logs sent to %s", s.address)
+	}
+
+	select {
+	case err := <-flushed:
+		return err
+	case <-s.done:
+		return nil
+	case <-timeout.C:
+		return fmt.Errorf("timed out syncing the logs sent to %s", s.address)
+	}
+}
+
+// Close makes the goroutine exit once it has sent the buffered records, or once it fails to.
+func (s *netSink) Close() error {
+	s.stop.Do(func() { close(s.stopCh) })
+	<-s.done
+	return nil
+}
+
+func (s *netSink) run() {
+	defer close(s.done)
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}()
+
+	backoff := netMinBackoff
+	stopping := false
+	for {
+		var r asyncRecord
+		select {
+		case r = <-s.records:
+		case <-s.stopCh:
+			stopping = true
+			select {
+			case r = <-s.records:
+			default:
+				return
+			}
+		}
+
+		if r.flushed != nil {
+			r.flushed <- nil
+			continue
+		}
+
+		// retry the record until it's sent, unless stopping
+		for {
+			if conn == nil {
+				var err error
+				if conn, err = s.dial(); err != nil {
+					conn = nil
+				}
+			}
+
+			if conn != nil {
+				_ = conn.SetWriteDeadline(time.Now().Add(netWriteTimeout))
+				if _, err := conn.Write(r.data); err == nil {
+					backoff = netMinBackoff
+					break
+				}
+				_ = conn.Close()
+				conn = nil
+
+				// datagrams failing to be sent, such as those too large, fail again when retried
+				if s.network == udpScheme {
+					atomic.AddUint64(&s.dropped, 1)
+					droppedNetworkRecords.Increment()
+					break
+				}
+			}
+
+			if stopping {
+				atomic.AddUint64(&s.dropped, 1)
+				droppedNetworkRecords.Increment()
+				break
+			}
+
+			select {
+			case <-time.After(backoff):
+			case <-s.stopCh:
+				stopping = true
+			}
+			if backoff *= 2; backoff > netMaxBackoff {
+				backoff = netMaxBackoff
+			}
+		}
+	}
+}
+
+func (s *netSink) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: netDialTimeout}
+	if s.tls != nil {
+		return tls.DialWithDialer(d, s.network, s.address, s.tls)
+	}
+	return d.Dial(s.network, s.address)
+}
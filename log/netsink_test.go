@@ -0,0 +1,187 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bufio"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func openNetSink(t *testing.T, path string) *netSink {
+	t.Helper()
+	u, err := url.Parse(path)
+	if err != nil {
+		t.Fatalf("Unable to parse %s: %v", path, err)
+	}
+	s, err := newNetSink(u)
+	if err != nil {
+		t.Fatalf("Unable to open %s: %v", path, err)
+	}
+	return s.(*netSink)
+}
+
+func TestNetSinkTCP(t *testing.T) {
+	// reserve a port, the collector only starts listening after the first record is written
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	s := openNetSink(t, "tcp://"+addr)
+	defer s.Close()
+
+	_, _ = s.Write([]byte("one\n"))
+	_, _ = s.Write([]byte("two\n"))
+
+	// the goroutine reconnects with a backoff once the collector is up
+	if l, err = net.Listen("tcp", addr); err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	defer l.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Unable to accept: %v", err)
+	}
+	defer conn.Close()
+
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, msg := range []string{"one\n", "two\n"} {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Unable to read %q: %v", msg, err)
+		}
+		if line != msg {
+			t.Errorf("Got %q, expecting %q", line, msg)
+		}
+	}
+}
+
+func TestNetSinkUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	defer pc.Close()
+
+	s := openNetSink(t, "udp://"+pc.LocalAddr().String())
+	defer s.Close()
+
+	_, _ = s.Write([]byte("hello\n"))
+	if err := s.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Unable to read the datagram: %v", err)
+	}
+	if got := string(buf[:n]); got != "hello\n" {
+		t.Errorf("Got %q, expecting %q", got, "hello\n")
+	}
+}
+
+func TestNetSinkDrops(t *testing.T) {
+	// reserve a port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	old := netSyncTimeout
+	netSyncTimeout = 100 * time.Millisecond
+	defer func() { netSyncTimeout = old }()
+
+	s := openNetSink(t, "tcp://"+addr+"?buffer=1")
+
+	// the goroutine keeps retrying the first record, so the buffer fills up
+	for i := 0; i < 10; i++ {
+		_, _ = s.Write([]byte("x\n"))
+	}
+	if atomic.LoadUint64(&s.dropped) == 0 {
+		t.Error("Expecting records to be dropped")
+	}
+
+	if err := s.Sync(); err == nil {
+		t.Error("Expecting Sync to time out")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_ = s.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out closing the sink")
+	}
+}
+
+func TestNetSinkErrors(t *testing.T) {
+	cases := []struct {
+		path string
+		err  string
+	}{
+		{"tcp://", "no host"},
+		{"udp://localhost:1234?buffer=0", "invalid buffer size"},
+		{"tcp://localhost:1234?buffer=x", "invalid buffer size"},
+		{"tls://localhost:1234?ca=/does/not/exist", "unable to read the certificate authorities"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			u, err := url.Parse(c.path)
+			if err != nil {
+				t.Fatalf("Unable to parse %s: %v", c.path, err)
+			}
+			if _, err := newNetSink(u); err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("Got %v, expecting an error containing %q", err, c.err)
+			}
+		})
+	}
+}
+
+func TestIsNetworkPath(t *testing.T) {
+	cases := map[string]bool{
+		"tcp://localhost:1234": true,
+		"udp://localhost:1234": true,
+		"tls://localhost:1234": true,
+		"stdout":               false,
+		"/var/log/tcp.log":     false,
+	}
+
+	for path, expected := range cases {
+		if got := isNetworkPath(path); got != expected {
+			t.Errorf("isNetworkPath(%s) = %v, expecting %v", path, got, expected)
+		}
+	}
+}
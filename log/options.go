@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -34,6 +35,13 @@ const (
 	defaultRotationMaxBackups = 1000
 )
 
+// The names of the output targets which aren't file system paths, see Options.SetTargetLevel.
+const (
+	OTLPTarget     = "otlp"
+	EventLogTarget = "eventlog"
+	JournaldTarget = "journald"
+)
+
 // Level is an enumeration of all supported log levels.
 type Level int
 
@@ -75,8 +83,21 @@ type Options struct {
 	// OutputPaths is a list of file system paths to write the log data to.
 	// The special values stdout and stderr can be used to output to the
 	// standard I/O streams. This defaults to stdout.
+	//
+	// Logs can also be sent to a collector over the network with the URLs
+	// tcp://<host>:<port>, udp://<host>:<port> and tls://<host>:<port>. The
+	// records are buffered, 1024 by default which the buffer query parameter
+	// overrides, and those written while the buffer is full are dropped. For TLS
+	// the ca query parameter is the path of the PEM certificates of the
+	// certificate authorities of the collector, and servername the name its
+	// certificate is issued for.
 	OutputPaths []string
 
+	// SplitStreams writes warn, error and fatal records to stderr, and debug and info records to
+	// stdout, as container platforms classify the severity of logs by the stream they are written
+	// to. The standard streams are added to OutputPaths as needed.
+	SplitStreams bool
+
 	// ErrorOutputPaths is a list of file system paths to write logger errors to.
 	// The special values stdout and stderr can be used to output to the
 	// standard I/O streams. This defaults to stderr.
@@ -91,6 +112,15 @@ type Options struct {
 	// old, then the file is renamed by appending a timestamp to the name. Such renamed
 	// files are called backups. Once a backup has been created,
 	// output resumes to this path.
+	//
+	// The path may hold the directives %Y, %m, %d and %H, which expand to the
+	// UTC year, month, day and hour at which records are logged, %h which
+	// expands to the hostname, %s which expands to the scope of records, and
+	// %% for a literal percent sign. Records are then written to the file the
+	// path expands to, such as a file per day with /var/log/app-%Y%m%d.log, and
+	// the files matching the path older than RotationMaxAge are deleted when
+	// switching files. Such files are written synchronously whatever
+	// AsyncBufferSize.
 	RotateOutputPath string
 
 	// RotationMaxSize is the maximum size in megabytes of a log file before it gets
@@ -108,17 +138,174 @@ type Options struct {
 	// is to retain at most 1000 logs.
 	RotationMaxBackups int
 
+	// RotationCompress controls whether rotated log files are compressed with gzip.
+	RotationCompress bool
+
+	// RotationMaxTotalSize is the maximum total size in megabytes of the rotated log files. Once
+	// their size adds up to more than this, the oldest are deleted. The default is to not limit
+	// their total size.
+	RotationMaxTotalSize int
+
+	// RotationInterval is the maximum age of a log file before it gets rotated, in addition to
+	// rotating once it reaches RotationMaxSize. The default is to rotate based on size only.
+	RotationInterval time.Duration
+
 	// JSONEncoding controls whether the log is formatted as JSON.
 	JSONEncoding bool
 
+	// JSONSchema customizes the field names and timestamp format of the log when it is formatted as
+	// JSON, such that it can match an established schema. The zero value keeps the defaults.
+	JSONSchema JSONSchema
+
+	// CloudLogging formats the log as the JSON structured log entries of Google Cloud Logging, such
+	// that the Cloud Logging agents of GKE parse the severity, trace, spanId and sourceLocation of
+	// the entries without any rewrite rules. This takes precedence over JSONEncoding and JSONSchema.
+	CloudLogging bool
+
+	// CloudLoggingProject is the ID of the Google Cloud project holding the traces of the log
+	// entries when CloudLogging is set. When set, the trace_id fields of log records are output as
+	// the full resource names of the traces, which Cloud Logging requires to correlate entries with
+	// traces.
+	CloudLoggingProject string
+
+	// CoalesceWindow enables the coalescing of duplicate records when positive. Records identical to
+	// the previous one, with the same level, scope, message and fields, are then dropped for up to this
+	// long, and reported by a single "last message repeated N times" record once the window ends or a
+	// different record is output. This cuts the log volume of tight error loops. The default is to
+	// output all records.
+	CoalesceWindow time.Duration
+
+	// ErrorFingerprints adds a fingerprint field to error and fatal records, which lets log backends
+	// group recurring errors. Fingerprints hash the type of the first error field of records, their
+	// message with quoted strings and numbers masked, and the function logging them.
+	ErrorFingerprints bool
+
+	// RecordMetrics counts the records output by each scope at each level with the
+	// istio_log_records_total metric, such that spikes of errors show in dashboards even when the
+	// collection of the logs lags. Records are counted before they are sampled or rate limited. The
+	// metric is only registered when this is set.
+	RecordMetrics bool
+
 	// LogGrpc indicates that Grpc logs should be captured. The default is true.
 	// This is not exposed through the command-line flags, as this flag is mainly useful for testing: Grpc
 	// stack will hold on to the logger even though it gets closed. This causes data races.
 	LogGrpc bool
 
+	// GrpcScope is the name of the scope gRPC logs through when LogGrpc is set, which is registered
+	// if needed. gRPC messages are then subject to the output level of that scope, see NewGrpcLogger.
+	// The default is to capture gRPC logs like those of the standard golang "log" package.
+	GrpcScope string
+
+	// AsyncBufferSize enables asynchronous logging when positive. Log records are then written by a
+	// background goroutine rather than by callers, through a buffer holding this many records. Records
+	// logged while the buffer is full are dropped, and counted by the istio_log_dropped_records_total
+	// metric. Sync waits for the buffered records to be written. The default is to log synchronously.
+	AsyncBufferSize int
+
+	// RingBufferSize enables an in-memory ring buffer when positive, which retains the last this many
+	// records of each scope at or above the ring level of the scope, whatever its output level, see
+	// SetRingLevel. This lets operators inspect recent debug messages through ctrlz, see RecentRecords,
+	// even though they aren't output, by lowering the ring level of the scopes of interest to debug.
+	// Messages retained are formatted even when they aren't output. The default is to not retain records.
+	RingBufferSize int
+
+	// OTLPEndpoint is the URL of the OTLP/HTTP logs endpoint of an OpenTelemetry collector, such as
+	// http://localhost:4318/v1/logs. When set, log records are exported to the collector using the
+	// JSON encoding of the protocol, in addition to being written to the other outputs. Each scope
+	// is exported as an instrumentation scope of the same name. The default is to not export.
+	OTLPEndpoint string
+
+	// OTLPResourceAttributes are the attributes describing the process producing the log records
+	// exported over OTLP, such as service.name or k8s.pod.name.
+	OTLPResourceAttributes map[string]string
+
+	// EventLogSource is the name of the source under which log records are reported to the Windows
+	// Event Log, in addition to being written to the other outputs. The source is registered in the
+	// Application log the first time it is used, which requires administrative privileges. Debug and
+	// info records are reported as informational events, and error and fatal records as error events.
+	// Configure fails on other platforms. The default is to not report events.
+	EventLogSource string
+
+	// Journald sends log records to systemd-journald using its native protocol, in addition to
+	// writing them to the other outputs. This is meant for components running directly on hosts
+	// rather than in containers. Structured fields are sent as journal fields of the same name in
+	// upper case. Configure fails if journald doesn't run on the host.
+	Journald bool
+
+	// JournaldIdentifier is the SYSLOG_IDENTIFIER of the journal entries, which defaults to the
+	// name of the program.
+	JournaldIdentifier string
+
+	// AuditOutputPath is the path of a tamper-evident audit log, to which the messages of AuditScopes
+	// are written in addition to the other outputs. Audit records are JSON records chained together by
+	// their hashes, and the head of the chain is periodically checkpointed in a ledger. Checkpoints
+	// are written to the audit log along with the root hash of the ledger, which is also output
+	// through the default scope, such that VerifyAuditLog can check the audit log against it. An
+	// existing audit log is resumed, and Configure fails if it doesn't verify.
+	AuditOutputPath string
+
+	// AuditScopes are the names of the scopes whose messages are written to the audit log, such as
+	// those reporting authorization decisions or certificate issuance.
+	AuditScopes []string
+
+	// AuditCheckpointInterval is the interval between checkpoints of the audit log, which defaults to
+	// a minute. Checkpoints are only written if the audit log got new records.
+	AuditCheckpointInterval time.Duration
+
+	// SIEMOutputPath is the path of a security event log, to which the messages of SIEMScopes are
+	// written in addition to the other outputs, in a format which SIEM systems ingest directly. The scope
+	// of messages is their event class, and their fields are output as extension fields.
+	SIEMOutputPath string
+
+	// SIEMFormat is the format of the security event log, either CEFFormat for the Common Event Format
+	// of ArcSight, or LEEFFormat for the Log Event Extended Format of QRadar. The default is CEFFormat.
+	SIEMFormat string
+
+	// SIEMScopes are the names of the scopes whose messages are written to the security event log.
+	SIEMScopes []string
+
+	// SIEMProduct is the product reported in the security event log, which defaults to the name of the
+	// program.
+	SIEMProduct string
+
+	// SIEMProductVersion is the product version reported in the security event log.
+	SIEMProductVersion string
+
 	outputLevels     string
 	logCallers       string
+	callerLevels     string
+	ringLevels       string
 	stackTraceLevels string
+	sampling         string
+	rateLimits       string
+	targetLevels     string
+}
+
+// JSONSchema defines the field names and timestamp format of JSON-encoded logs. Empty fields keep
+// their default value, and setting a key to "-" omits the corresponding field.
+type JSONSchema struct {
+	// TimeKey is the name of the timestamp field, which defaults to "time".
+	TimeKey string
+
+	// TimeFormat is the format of timestamps, either a layout as accepted by time.Time.Format, or one of
+	// the special values "epoch" (seconds since the Unix epoch), "epoch_millis" and "epoch_nanos". This
+	// defaults to an RFC 3339 UTC timestamp with microseconds.
+	TimeFormat string
+
+	// LevelKey is the name of the level field, which defaults to "level".
+	LevelKey string
+
+	// ScopeKey is the name of the scope field, which defaults to "scope".
+	ScopeKey string
+
+	// CallerKey is the name of the caller location field, which defaults to "caller".
+	CallerKey string
+
+	// MessageKey is the name of the message field, which defaults to "msg".
+	MessageKey string
+
+	// StackKey is the name of the stack trace field, which defaults to "stack".
+	StackKey string
 }
 
 // DefaultOptions returns a new set of options, initialized to the defaults
@@ -243,6 +430,180 @@ func (o *Options) GetStackTraceLevel(scope string) (Level, error) {
 	return NoneLevel, fmt.Errorf("no level defined for scope '%s'", scope)
 }
 
+// SetSampling sets the sampling configuration for a given scope.
+func (o *Options) SetSampling(scope string, config SamplingConfig) {
+	ss := scope + ":" + config.String()
+	var configs []string
+	if o.sampling != "" {
+		configs = strings.Split(o.sampling, ",")
+	}
+
+	prefix := scope + ":"
+	for i, c := range configs {
+		if strings.HasPrefix(c, prefix) {
+			configs[i] = ss
+			o.sampling = strings.Join(configs, ",")
+			return
+		}
+	}
+
+	configs = append(configs, ss)
+	o.sampling = strings.Join(configs, ",")
+}
+
+// GetSampling returns the sampling configuration for a given scope.
+func (o *Options) GetSampling(scope string) (SamplingConfig, error) {
+	prefix := scope + ":"
+	for _, c := range strings.Split(o.sampling, ",") {
+		if strings.HasPrefix(c, prefix) {
+			_, config, err := convertScopedSampling(c)
+			return config, err
+		}
+	}
+
+	return SamplingConfig{}, fmt.Errorf("no sampling defined for scope '%s'", scope)
+}
+
+// SetRingLevel sets the minimum level of the messages of a given scope retained by the ring buffer,
+// see RingBufferSize. Scopes retain their messages of info level and above by default.
+func (o *Options) SetRingLevel(scope string, level Level) {
+	sl := scope + ":" + levelToString[level]
+	levels := strings.Split(o.ringLevels, ",")
+	prefix := scope + ":"
+	for i, rl := range levels {
+		if strings.HasPrefix(rl, prefix) {
+			levels[i] = sl
+			o.ringLevels = strings.Join(levels, ",")
+			return
+		}
+	}
+
+	if o.ringLevels == "" {
+		o.ringLevels = sl
+		return
+	}
+	o.ringLevels = strings.Join(append(levels, sl), ",")
+}
+
+// GetRingLevel returns the minimum level of the messages of a given scope retained by the ring buffer.
+func (o *Options) GetRingLevel(scope string) (Level, error) {
+	prefix := scope + ":"
+	for _, rl := range strings.Split(o.ringLevels, ",") {
+		if strings.HasPrefix(rl, prefix) {
+			_, l, err := convertScopedLevel(rl)
+			return l, err
+		}
+	}
+
+	return NoneLevel, fmt.Errorf("no ring level defined for scope '%s'", scope)
+}
+
+// SetRateLimit sets the rate limit for a given scope.
+func (o *Options) SetRateLimit(scope string, limit RateLimit) {
+	sl := scope + ":" + limit.String()
+	var limits []string
+	if o.rateLimits != "" {
+		limits = strings.Split(o.rateLimits, ",")
+	}
+
+	prefix := scope + ":"
+	for i, l := range limits {
+		if strings.HasPrefix(l, prefix) {
+			limits[i] = sl
+			o.rateLimits = strings.Join(limits, ",")
+			return
+		}
+	}
+
+	limits = append(limits, sl)
+	o.rateLimits = strings.Join(limits, ",")
+}
+
+// GetRateLimit returns the rate limit for a given scope.
+func (o *Options) GetRateLimit(scope string) (RateLimit, error) {
+	prefix := scope + ":"
+	for _, l := range strings.Split(o.rateLimits, ",") {
+		if strings.HasPrefix(l, prefix) {
+			_, limit, err := convertScopedRateLimit(l)
+			return limit, err
+		}
+	}
+
+	return RateLimit{}, fmt.Errorf("no rate limit defined for scope '%s'", scope)
+}
+
+// SetTargetLevel sets the minimum level of the messages written to a given output target, which is
+// either one of the OutputPaths, the RotateOutputPath, or one of OTLPTarget, EventLogTarget and
+// JournaldTarget. This applies on top of the output levels of scopes: a message is written to the
+// target if its scope outputs it and its level is at least the target level. For instance, with the
+// default scope output level at debug, stdout can get info messages and above while a file gets all
+// the messages. Targets output all the messages the scopes output by default.
+func (o *Options) SetTargetLevel(target string, level Level) {
+	tl := target + ":" + levelToString[level]
+	var levels []string
+	if o.targetLevels != "" {
+		levels = strings.Split(o.targetLevels, ",")
+	}
+
+	for i, l := range levels {
+		if t, _, err := convertTargetLevel(l); err == nil && t == target {
+			levels[i] = tl
+			o.targetLevels = strings.Join(levels, ",")
+			return
+		}
+	}
+
+	levels = append(levels, tl)
+	o.targetLevels = strings.Join(levels, ",")
+}
+
+// GetTargetLevel returns the minimum level of the messages written to a given output target.
+func (o *Options) GetTargetLevel(target string) (Level, error) {
+	for _, tl := range strings.Split(o.targetLevels, ",") {
+		if t, l, err := convertTargetLevel(tl); err == nil && t == target {
+			return l, nil
+		}
+	}
+
+	return NoneLevel, fmt.Errorf("no level defined for output target '%s'", target)
+}
+
+// SetCallerLevel sets the minimum level of the messages for which the caller's source code location
+// is output for a given scope. This refines SetLogCallers, which outputs the location of messages of
+// all levels.
+func (o *Options) SetCallerLevel(scope string, level Level) {
+	sl := scope + ":" + levelToString[level]
+	levels := strings.Split(o.callerLevels, ",")
+	prefix := scope + ":"
+	for i, cl := range levels {
+		if strings.HasPrefix(cl, prefix) {
+			levels[i] = sl
+			o.callerLevels = strings.Join(levels, ",")
+			return
+		}
+	}
+
+	if o.callerLevels == "" {
+		o.callerLevels = sl
+		return
+	}
+	o.callerLevels = strings.Join(append(levels, sl), ",")
+}
+
+// GetCallerLevel returns the minimum level of the messages for which the caller's source code location
+// is output for a given scope.
+func (o *Options) GetCallerLevel(scope string) (Level, error) {
+	prefix := scope + ":"
+	for _, cl := range strings.Split(o.callerLevels, ",") {
+		if strings.HasPrefix(cl, prefix) {
+			_, l, err := convertScopedLevel(cl)
+			return l, err
+		}
+	}
+
+	return NoneLevel, fmt.Errorf("no caller level defined for scope '%s'", scope)
+}
+
 // SetLogCallers sets whether to output the caller's source code location for a given scope.
 func (o *Options) SetLogCallers(scope string, include bool) {
 	scopes := strings.Split(o.logCallers, ",")
@@ -306,6 +667,22 @@ func convertScopedLevel(sl string) (string, Level, error) {
 	return s, level, nil
 }
 
+// convertTargetLevel parses a target level of the form <target>:<level>, where targets may themselves
+// hold colons, as paths do on Windows.
+func convertTargetLevel(tl string) (string, Level, error) {
+	i := strings.LastIndex(tl, ":")
+	if i <= 0 {
+		return "", NoneLevel, fmt.Errorf("invalid output target level format '%s'", tl)
+	}
+
+	level, ok := stringToLevel[tl[i+1:]]
+	if !ok {
+		return "", NoneLevel, fmt.Errorf("invalid output target level '%s'", tl)
+	}
+
+	return tl[:i], level, nil
+}
+
 // AttachCobraFlags attaches a set of Cobra flags to the given Cobra command.
 //
 // Cobra is the command-line processor that Istio uses. This command attaches
@@ -327,10 +704,14 @@ func (o *Options) AttachFlags(
 	boolVar func(p *bool, name string, value bool, usage string)) {
 
 	stringArrayVar(&o.OutputPaths, "log_target", o.OutputPaths,
-		"The set of paths where to output the log. This can be any path as well as the special values stdout and stderr")
+		"The set of paths where to output the log. This can be any path as well as the special values stdout and stderr, "+
+			"or tcp://, udp:// and tls:// URLs of a collector")
+
+	boolVar(&o.SplitStreams, "log_split_streams", o.SplitStreams,
+		"Whether to write warn, error and fatal messages to stderr and the other messages to stdout")
 
 	stringVar(&o.RotateOutputPath, "log_rotate", o.RotateOutputPath,
-		"The path for the optional rotating log file")
+		"The path for the optional rotating log file, which may hold the directives %Y, %m, %d, %H, %h (hostname) and %s (scope)")
 
 	intVar(&o.RotationMaxAge, "log_rotate_max_age", o.RotationMaxAge,
 		"The maximum age in days of a log file beyond which the file is rotated (0 indicates no limit)")
@@ -341,9 +722,60 @@ func (o *Options) AttachFlags(
 	intVar(&o.RotationMaxBackups, "log_rotate_max_backups", o.RotationMaxBackups,
 		"The maximum number of log file backups to keep before older files are deleted (0 indicates no limit)")
 
+	intVar(&o.AsyncBufferSize, "log_async_buffer_size", o.AsyncBufferSize,
+		"The number of log records buffered when logging asynchronously, beyond which records are dropped (0 logs synchronously)")
+
+	intVar(&o.RingBufferSize, "log_ring_buffer_size", o.RingBufferSize,
+		"The number of records retained in memory for each scope whatever their output level, for inspection through ControlZ (0 retains none)")
+
+	stringVar(&o.OTLPEndpoint, "log_otlp_endpoint", o.OTLPEndpoint,
+		"The URL of the OTLP/HTTP logs endpoint of an OpenTelemetry collector to export logs to, such as http://localhost:4318/v1/logs")
+
+	stringVar(&o.EventLogSource, "log_event_source", o.EventLogSource,
+		"The name of the source under which to report logs to the Windows Event Log")
+
+	boolVar(&o.Journald, "log_journald", o.Journald,
+		"Whether to send logs to systemd-journald")
+
+	stringVar(&o.JournaldIdentifier, "log_journald_identifier", o.JournaldIdentifier,
+		"The syslog identifier of the journal entries, which defaults to the name of the program")
+
+	stringVar(&o.AuditOutputPath, "log_audit_output", o.AuditOutputPath,
+		"The path of the tamper-evident audit log")
+
+	stringArrayVar(&o.AuditScopes, "log_audit_scope", o.AuditScopes,
+		"The scopes whose messages are written to the audit log")
+
+	stringVar(&o.SIEMOutputPath, "log_siem_output", o.SIEMOutputPath,
+		"The path of the security event log, in the format given by --log_siem_format")
+
+	stringVar(&o.SIEMFormat, "log_siem_format", o.SIEMFormat,
+		"The format of the security event log, either cef or leef")
+
+	stringArrayVar(&o.SIEMScopes, "log_siem_scope", o.SIEMScopes,
+		"The scopes whose messages are written to the security event log")
+
+	boolVar(&o.RotationCompress, "log_rotate_compress", o.RotationCompress,
+		"Whether to compress rotated log files with gzip")
+
+	intVar(&o.RotationMaxTotalSize, "log_rotate_max_total_size", o.RotationMaxTotalSize,
+		"The maximum total size in megabytes of the log file backups beyond which the oldest are deleted (0 indicates no limit)")
+
 	boolVar(&o.JSONEncoding, "log_as_json", o.JSONEncoding,
 		"Whether to format output as JSON or in plain console-friendly format")
 
+	boolVar(&o.ErrorFingerprints, "log_error_fingerprint", o.ErrorFingerprints,
+		"Whether to add a fingerprint field to error records, which identifies recurring errors")
+
+	boolVar(&o.RecordMetrics, "log_record_metrics", o.RecordMetrics,
+		"Whether to count the records output by each scope at each level with the istio_log_records_total metric")
+
+	boolVar(&o.CloudLogging, "log_as_cloud_logging", o.CloudLogging,
+		"Whether to format output as the JSON structured log entries of Google Cloud Logging")
+
+	stringVar(&o.CloudLoggingProject, "log_cloud_logging_project", o.CloudLoggingProject,
+		"The ID of the Google Cloud project holding the traces the Cloud Logging entries refer to")
+
 	levelListString := fmt.Sprintf("[%s, %s, %s, %s, %s, %s]",
 		levelToString[DebugLevel],
 		levelToString[InfoLevel],
@@ -352,6 +784,11 @@ func (o *Options) AttachFlags(
 		levelToString[FatalLevel],
 		levelToString[NoneLevel])
 
+	stringVar(&o.targetLevels, "log_target_level", o.targetLevels,
+		fmt.Sprintf("Comma-separated minimum level of messages written to output targets, in the form of "+
+			"<target>:<level>,<target>:<level>,... where target can be any output path, the rotating log file path, "+
+			"%s, %s or %s, and level can be one of %s", OTLPTarget, EventLogTarget, JournaldTarget, levelListString))
+
 	allScopes := Scopes()
 	if len(allScopes) > 1 {
 		keys := make([]string, 0, len(allScopes))
@@ -374,6 +811,27 @@ func (o *Options) AttachFlags(
 
 		stringVar(&o.logCallers, "log_caller", o.logCallers,
 			fmt.Sprintf("Comma-separated list of scopes for which to include caller information, scopes can be any of [%s]", s))
+
+		stringVar(&o.callerLevels, "log_caller_level", o.callerLevels,
+			fmt.Sprintf("Comma-separated minimum per-scope logging level at which caller information is included, in the form of "+
+				"<scope>:<level>,<scope>:<level>,... where scope can be one of [%s] and level can be one of %s",
+				s, levelListString))
+
+		stringVar(&o.ringLevels, "log_ring_level", o.ringLevels,
+			fmt.Sprintf("Comma-separated minimum per-scope logging level of the records retained in memory, see "+
+				"--log_ring_buffer_size, in the form of <scope>:<level>,<scope>:<level>,... where scope can be one of [%s] "+
+				"and level can be one of %s (info by default)", s, levelListString))
+
+		stringVar(&o.sampling, "log_sampling", o.sampling,
+			fmt.Sprintf("Comma-separated per-scope sampling of messages to output, in the form of "+
+				"<scope>:<initial>:<thereafter>:<cap>,... where scope can be one of [%s]. Each second, the first <initial> "+
+				"messages with the same level and text are output, then every <thereafter>-th, and at most <cap> messages "+
+				"overall (0 for no cap)", s))
+
+		stringVar(&o.rateLimits, "log_rate_limit", o.rateLimits,
+			fmt.Sprintf("Comma-separated per-scope rate limits of messages to output, in the form of "+
+				"<scope>:<per second>:<burst>,... where scope can be one of [%s]. Messages beyond the limit are "+
+				"suppressed, and counted in a warning once messages are output again", s))
 	} else {
 		stringVar(&o.outputLevels, "log_output_level", o.outputLevels,
 			fmt.Sprintf("The minimum logging level of messages to output,  can be one of %s",
@@ -385,6 +843,23 @@ func (o *Options) AttachFlags(
 
 		stringVar(&o.logCallers, "log_caller", o.logCallers,
 			"Comma-separated list of scopes for which to include called information, scopes can be any of [default]")
+
+		stringVar(&o.callerLevels, "log_caller_level", o.callerLevels,
+			fmt.Sprintf("The minimum logging level at which caller information is included, can be one of %s",
+				levelListString))
+
+		stringVar(&o.ringLevels, "log_ring_level", o.ringLevels,
+			fmt.Sprintf("The minimum logging level of the records retained in memory, see --log_ring_buffer_size, "+
+				"can be one of %s (info by default)", levelListString))
+
+		stringVar(&o.sampling, "log_sampling", o.sampling,
+			"Sampling of messages to output, in the form of default:<initial>:<thereafter>:<cap>. Each second, the first "+
+				"<initial> messages with the same level and text are output, then every <thereafter>-th, and at most <cap> "+
+				"messages overall (0 for no cap)")
+
+		stringVar(&o.rateLimits, "log_rate_limit", o.rateLimits,
+			"Rate limit of messages to output, in the form of default:<per second>:<burst>. Messages beyond the limit "+
+				"are suppressed, and counted in a warning once messages are output again")
 	}
 
 	// NOTE: we don't currently expose a command-line option to control ErrorOutputPaths since it
@@ -53,6 +53,18 @@ func TestOpts(t *testing.T) {
 			LogGrpc:            true,
 		}},
 
+		{"--log_split_streams", Options{
+			OutputPaths:        []string{defaultOutputPath},
+			ErrorOutputPaths:   []string{defaultErrorOutputPath},
+			SplitStreams:       true,
+			outputLevels:       DefaultScopeName + ":" + levelToString[defaultOutputLevel],
+			stackTraceLevels:   DefaultScopeName + ":" + levelToString[defaultStackTraceLevel],
+			RotationMaxAge:     defaultRotationMaxAge,
+			RotationMaxSize:    defaultRotationMaxSize,
+			RotationMaxBackups: defaultRotationMaxBackups,
+			LogGrpc:            true,
+		}},
+
 		{"--log_caller default", Options{
 			OutputPaths:        []string{defaultOutputPath},
 			ErrorOutputPaths:   []string{defaultErrorOutputPath},
@@ -454,3 +466,47 @@ func TestLogCallers(t *testing.T) {
 		t.Error("Expecting false")
 	}
 }
+
+func TestCallerLevels(t *testing.T) {
+	o := DefaultOptions()
+
+	if _, err := o.GetCallerLevel("s1"); err == nil {
+		t.Error("Got success, expecting error")
+	}
+
+	o.SetCallerLevel("s1", ErrorLevel)
+	o.SetCallerLevel("s2", DebugLevel)
+	o.SetCallerLevel("s1", WarnLevel)
+
+	if l, err := o.GetCallerLevel("s1"); err != nil || l != WarnLevel {
+		t.Errorf("Got %v, %v, expecting WarnLevel", l, err)
+	}
+	if l, err := o.GetCallerLevel("s2"); err != nil || l != DebugLevel {
+		t.Errorf("Got %v, %v, expecting DebugLevel", l, err)
+	}
+	if o.callerLevels != "s1:warn,s2:debug" {
+		t.Errorf("Got %s, expecting s1:warn,s2:debug", o.callerLevels)
+	}
+}
+
+func TestRingLevels(t *testing.T) {
+	o := DefaultOptions()
+
+	if _, err := o.GetRingLevel("s1"); err == nil {
+		t.Error("Got success, expecting error")
+	}
+
+	o.SetRingLevel("s1", ErrorLevel)
+	o.SetRingLevel("s2", DebugLevel)
+	o.SetRingLevel("s1", NoneLevel)
+
+	if l, err := o.GetRingLevel("s1"); err != nil || l != NoneLevel {
+		t.Errorf("Got %v, %v, expecting NoneLevel", l, err)
+	}
+	if l, err := o.GetRingLevel("s2"); err != nil || l != DebugLevel {
+		t.Errorf("Got %v, %v, expecting DebugLevel", l, err)
+	}
+	if o.ringLevels != "s1:none,s2:debug" {
+		t.Errorf("Got %s, expecting s1:none,s2:debug", o.ringLevels)
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// number of records which triggers an export
+	otlpBatchSize = 512
+
+	// how long records wait for an export at most
+	otlpFlushInterval = time.Second
+
+	otlpTimeout = 10 * time.Second
+)
+
+// The OTLP severity numbers, see https://opentelemetry.io/docs/specs/otel/logs/data-model/#field-severitynumber
+var zapToOTLPSeverity = map[zapcore.Level]int{
+	zapcore.DebugLevel:  5,
+	zapcore.InfoLevel:   9,
+	zapcore.WarnLevel:   13,
+	zapcore.ErrorLevel:  17,
+	zapcore.DPanicLevel: 21,
+	zapcore.PanicLevel:  21,
+	zapcore.FatalLevel:  21,
+}
+
+// The following types mirror the JSON encoding of the OTLP logs protocol, see
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/logs/v1/logs.proto
+type otlpRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // 64 bit integers are encoded as strings in JSON
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+// otlpValue converts a field value produced by a zapcore.MapObjectEncoder.
+func otlpValue(v interface{}) otlpAnyValue {
+	switch v := v.(type) {
+	case string:
+		return otlpString(v)
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int:
+		s := strconv.FormatInt(int64(v), 10)
+		return otlpAnyValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpAnyValue{IntValue: &s}
+	case int32:
+		s := strconv.FormatInt(int64(v), 10)
+		return otlpAnyValue{IntValue: &s}
+	case uint32:
+		s := strconv.FormatUint(uint64(v), 10)
+		return otlpAnyValue{IntValue: &s}
+	case uint64:
+		s := strconv.FormatUint(v, 10)
+		return otlpAnyValue{IntValue: &s}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	case float32:
+		f := float64(v)
+		return otlpAnyValue{DoubleValue: &f}
+	case time.Duration:
+		return otlpString(v.String())
+	case time.Time:
+		return otlpString(v.UTC().Format(time.RFC3339Nano))
+	}
+
+	// nested objects and arrays are flattened to their JSON form
+	if b, err := json.Marshal(v); err == nil {
+		return otlpString(string(b))
+	}
+	return otlpString(fmt.Sprint(v))
+}
+
+// otlpAttributes converts a map of attributes, sorting them by key to produce a stable output.
+func otlpAttributes(m map[string]interface{}) []otlpKeyValue {
+	if len(m) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: otlpValue(m[k])})
+	}
+	return kvs
+}
+
+type otlpPendingRecord struct {
+	scope  string
+	record otlpLogRecord
+}
+
+// otlpExporter batches log records and posts them to an OTLP/HTTP endpoint. Records are exported
+// once a batch fills up, after otlpFlushInterval, or when the logs are synced. Records which fail to
+// be exported are dropped, such that an unavailable collector doesn't make memory use grow.
+type otlpExporter struct {
+	endpoint  string
+	client    *http.Client
+	resource  otlpResource
+	errorSink zapcore.WriteSyncer
+
+	mu      sync.Mutex
+	pending []otlpPendingRecord
+	timer   *time.Timer
+}
+
+func newOTLPExporter(options *Options, errorSink zapcore.WriteSyncer) *otlpExporter {
+	attrs := make(map[string]interface{}, len(options.OTLPResourceAttributes))
+	for k, v := range options.OTLPResourceAttributes {
+		attrs[k] = v
+	}
+
+	return &otlpExporter{
+		endpoint:  options.OTLPEndpoint,
+		client:    &http.Client{Timeout: otlpTimeout},
+		resource:  otlpResource{Attributes: otlpAttributes(attrs)},
+		errorSink: errorSink,
+	}
+}
+
+func (e *otlpExporter) add(scope string, record otlpLogRecord) error {
+	e.mu.Lock()
+	e.pending = append(e.pending, otlpPendingRecord{scope: scope, record: record})
+	if len(e.pending) >= otlpBatchSize {
+		batch := e.takePending()
+		e.mu.Unlock()
+		return e.export(batch)
+	}
+
+	if e.timer == nil {
+		e.timer = time.AfterFunc(otlpFlushInterval, func() {
+			if err := e.flush(); err != nil {
+				_, _ = fmt.Fprintf(e.errorSink, "%v log write error: %v\n", time.Now(), err)
+				_ = e.errorSink.Sync()
+			}
+		})
+	}
+	e.mu.Unlock()
+	return nil
+}
+
+// takePending must be called with the lock held.
+func (e *otlpExporter) takePending() []otlpPendingRecord {
+	batch := e.pending
+	e.pending = nil
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	return batch
+}
+
+func (e *otlpExporter) flush() error {
+	e.mu.Lock()
+	batch := e.takePending()
+	e.mu.Unlock()
+	return e.export(batch)
+}
+
+func (e *otlpExporter) export(batch []otlpPendingRecord) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// group the records by scope, preserving the order in which scopes first appear
+	rl := otlpResourceLogs{Resource: e.resource}
+	index := make(map[string]int)
+	for _, p := range batch {
+		i, ok := index[p.scope]
+		if !ok {
+			i = len(rl.ScopeLogs)
+			index[p.scope] = i
+			rl.ScopeLogs = append(rl.ScopeLogs, otlpScopeLogs{Scope: otlpScope{Name: p.scope}})
+		}
+		rl.ScopeLogs[i].LogRecords = append(rl.ScopeLogs[i].LogRecords, p.record)
+	}
+
+	body, err := json.Marshal(otlpRequest{ResourceLogs: []otlpResourceLogs{rl}})
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to export %d log records to %s: %v", len(batch), e.endpoint, err)
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unable to export %d log records to %s: %s", len(batch), e.endpoint, resp.Status)
+	}
+	return nil
+}
+
+// otlpCore is a zapcore.Core handing log entries over to an otlpExporter.
+type otlpCore struct {
+	zapcore.LevelEnabler
+	exporter *otlpExporter
+	fields   []zapcore.Field
+}
+
+func newOTLPCore(exporter *otlpExporter, enabler zapcore.LevelEnabler) zapcore.Core {
+	return &otlpCore{LevelEnabler: enabler, exporter: exporter}
+}
+
+func (c *otlpCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return &clone
+}
+
+func (c *otlpCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *otlpCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	if ent.Caller.Defined {
+		enc.Fields["code.filepath"] = ent.Caller.File
+		enc.Fields["code.lineno"] = ent.Caller.Line
+	}
+	if ent.Stack != "" {
+		enc.Fields["exception.stacktrace"] = ent.Stack
+	}
+
+	scope := ent.LoggerName
+	if scope == "" {
+		scope = DefaultScopeName
+	}
+
+	return c.exporter.add(scope, otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(ent.Time.UnixNano(), 10),
+		SeverityNumber: zapToOTLPSeverity[ent.Level],
+		SeverityText:   ent.Level.CapitalString(),
+		Body:           otlpString(ent.Message),
+		Attributes:     otlpAttributes(enc.Fields),
+	})
+}
+
+func (c *otlpCore) Sync() error {
+	return c.exporter.flush()
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+type otlpCollector struct {
+	sync.Mutex
+	requests []otlpRequest
+	status   int
+}
+
+func (c *otlpCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.Lock()
+	c.requests = append(c.requests, req)
+	status := c.status
+	c.Unlock()
+
+	if status != 0 {
+		w.WriteHeader(status)
+	}
+}
+
+func TestOTLPExport(t *testing.T) {
+	resetGlobals()
+	s := RegisterScope("otlptest", "For testing", 0)
+
+	collector := &otlpCollector{}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	o := DefaultOptions()
+	o.OTLPEndpoint = srv.URL + "/v1/logs"
+	o.OTLPResourceAttributes = map[string]string{"service.name": "pilot"}
+	o.LogGrpc = false
+
+	lines, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+		s.Info("Hello", zap.String("key", "value"), zap.Int("count", 3))
+		Warn("World")
+		if err := Sync(); err != nil {
+			t.Errorf("Got %v, expecting the records to be exported", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	_ = Configure(DefaultOptions())
+
+	if len(lines) < 2 {
+		t.Errorf("Got %v, expecting the records to be output to stdout as well", lines)
+	}
+
+	collector.Lock()
+	defer collector.Unlock()
+	if len(collector.requests) != 1 || len(collector.requests[0].ResourceLogs) != 1 {
+		t.Fatalf("Got %+v, expecting a single export", collector.requests)
+	}
+
+	rl := collector.requests[0].ResourceLogs[0]
+	if len(rl.Resource.Attributes) != 1 || rl.Resource.Attributes[0].Key != "service.name" ||
+		*rl.Resource.Attributes[0].Value.StringValue != "pilot" {
+		t.Errorf("Got resource %+v, expecting service.name=pilot", rl.Resource)
+	}
+
+	if len(rl.ScopeLogs) != 2 {
+		t.Fatalf("Got %d scopes, expecting 2", len(rl.ScopeLogs))
+	}
+
+	sl := rl.ScopeLogs[0]
+	if sl.Scope.Name != "otlptest" || len(sl.LogRecords) != 1 {
+		t.Fatalf("Got %+v, expecting a record for the otlptest scope", sl)
+	}
+	rec := sl.LogRecords[0]
+	if *rec.Body.StringValue != "Hello" || rec.SeverityNumber != 9 || rec.SeverityText != "INFO" {
+		t.Errorf("Got %+v, expecting an info record saying Hello", rec)
+	}
+	if len(rec.Attributes) != 2 || rec.Attributes[0].Key != "count" || *rec.Attributes[0].Value.IntValue != "3" ||
+		rec.Attributes[1].Key != "key" || *rec.Attributes[1].Value.StringValue != "value" {
+		t.Errorf("Got attributes %+v, expecting count=3 and key=value", rec.Attributes)
+	}
+
+	sl = rl.ScopeLogs[1]
+	if sl.Scope.Name != DefaultScopeName || len(sl.LogRecords) != 1 || sl.LogRecords[0].SeverityNumber != 13 {
+		t.Errorf("Got %+v, expecting a warn record for the default scope", sl)
+	}
+}
+
+func TestOTLPExportFailure(t *testing.T) {
+	resetGlobals()
+
+	collector := &otlpCollector{status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	o := DefaultOptions()
+	o.OTLPEndpoint = srv.URL + "/v1/logs"
+	o.LogGrpc = false
+
+	_, _ = captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+		Info("Hello")
+		if err := Sync(); err == nil {
+			t.Error("Got success, expecting the export to fail")
+		}
+
+		// the failed records are dropped rather than exported again
+		if err := Sync(); err != nil {
+			t.Errorf("Got %v, expecting nothing left to export", err)
+		}
+	})
+	_ = Configure(DefaultOptions())
+}
@@ -0,0 +1,265 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// isPathTemplate returns whether a path holds directives, see Options.RotateOutputPath.
+func isPathTemplate(path string) bool {
+	return strings.Contains(path, "%")
+}
+
+// pathTemplate expands the directives of the path of a log file.
+type pathTemplate struct {
+	template string
+	hostname string
+}
+
+func newPathTemplate(template string) (*pathTemplate, error) {
+	t := &pathTemplate{template: template}
+
+	// validate the directives
+	if _, err := t.expand(time.Time{}, DefaultScopeName); err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(template, "%h") {
+		var err error
+		if t.hostname, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("unable to get the hostname for log file '%s': %v", template, err)
+		}
+	}
+
+	return t, nil
+}
+
+// expand returns the path of the file the records of a scope logged at a given time are written to.
+func (t *pathTemplate) expand(tm time.Time, scope string) (string, error) {
+	tm = tm.UTC()
+
+	var b strings.Builder
+	for i := 0; i < len(t.template); i++ {
+		c := t.template[i]
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+
+		if i++; i == len(t.template) {
+			return "", fmt.Errorf("incomplete directive at the end of log file '%s'", t.template)
+		}
+
+		switch t.template[i] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", tm.Year())
+		case 'm':
+			fmt.Fprintf(&b, "%02d", tm.Month())
+		case 'd':
+			fmt.Fprintf(&b, "%02d", tm.Day())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", tm.Hour())
+		case 'h':
+			b.WriteString(t.hostname)
+		case 's':
+			b.WriteString(scope)
+		case '%':
+			b.WriteByte('%')
+		default:
+			return "", fmt.Errorf("unknown directive '%%%c' in log file '%s'", t.template[i], t.template)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// glob returns a pattern matching the files the template expands to.
+func (t *pathTemplate) glob() string {
+	var b strings.Builder
+	for i := 0; i < len(t.template); i++ {
+		c := t.template[i]
+		if c != '%' || i+1 == len(t.template) {
+			b.WriteByte(c)
+			continue
+		}
+
+		i++
+		switch t.template[i] {
+		case '%':
+			b.WriteByte('%')
+		case 'h':
+			b.WriteString(t.hostname)
+		default:
+			b.WriteByte('*')
+		}
+	}
+	return b.String()
+}
+
+// templatedFiles holds the rotating files the records of each scope are currently written to. The
+// files of a scope are switched once the path they expand to changes, such as when the day changes,
+// after which the files older than the maximum age of rotated files are deleted.
+type templatedFiles struct {
+	mu       sync.Mutex
+	template *pathTemplate
+	options  Options
+	files    map[string]*templatedFile
+}
+
+type templatedFile struct {
+	path string
+	sink *rotatingSink
+}
+
+func newTemplatedFiles(options *Options) (*templatedFiles, error) {
+	t, err := newPathTemplate(options.RotateOutputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &templatedFiles{
+		template: t,
+		options:  *options,
+		files:    make(map[string]*templatedFile),
+	}, nil
+}
+
+func (f *templatedFiles) write(ent zapcore.Entry, p []byte) error {
+	scope := ent.LoggerName
+	if scope == "" {
+		scope = DefaultScopeName
+	}
+
+	path, err := f.template.expand(ent.Time, scope)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file := f.files[scope]
+	if file == nil || file.path != path {
+		if file != nil {
+			_ = file.sink.logger.Close()
+			delete(f.files, scope)
+			f.prune()
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		o := f.options
+		o.RotateOutputPath = path
+		file = &templatedFile{path: path, sink: newRotatingSink(&o)}
+		f.files[scope] = file
+	}
+
+	_, err = file.sink.Write(p)
+	return err
+}
+
+// prune deletes the files matching the template which haven't been written to for longer than the
+// maximum age of rotated files.
+func (f *templatedFiles) prune() {
+	if f.options.RotationMaxAge <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(f.template.glob())
+	if err != nil {
+		return
+	}
+
+	open := make(map[string]bool, len(f.files))
+	for _, file := range f.files {
+		open[filepath.Clean(file.path)] = true
+	}
+
+	cutoff := time.Now().Add(-time.Duration(f.options.RotationMaxAge) * 24 * time.Hour)
+	for _, m := range matches {
+		if open[filepath.Clean(m)] {
+			continue
+		}
+		if fi, err := os.Stat(m); err == nil && !fi.IsDir() && fi.ModTime().Before(cutoff) {
+			_ = os.Remove(m)
+		}
+	}
+}
+
+func (f *templatedFiles) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, file := range f.files {
+		_ = file.sink.logger.Close()
+	}
+	f.files = make(map[string]*templatedFile)
+}
+
+// templatedCore writes log records to the files a path template expands to for each record.
+type templatedCore struct {
+	zapcore.LevelEnabler
+	enc   zapcore.Encoder
+	files *templatedFiles
+}
+
+func newTemplatedCore(enc zapcore.Encoder, files *templatedFiles, enab zapcore.LevelEnabler) zapcore.Core {
+	return &templatedCore{
+		LevelEnabler: enab,
+		enc:          enc,
+		files:        files,
+	}
+}
+
+func (c *templatedCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+	return &clone
+}
+
+func (c *templatedCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *templatedCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	return c.files.write(ent, buf.Bytes())
+}
+
+// Sync does nothing, as the rotating files are written to without buffering.
+func (c *templatedCore) Sync() error {
+	return nil
+}
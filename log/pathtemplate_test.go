@@ -0,0 +1,126 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestPathTemplate(t *testing.T) {
+	hostname, _ := os.Hostname()
+	tm := time.Date(2019, time.March, 7, 15, 4, 5, 0, time.UTC)
+
+	cases := []struct {
+		template string
+		expected string
+	}{
+		{"/var/log/app-%Y%m%d.log", "/var/log/app-20190307.log"},
+		{"/var/log/%Y/%m/%d/%H.log", "/var/log/2019/03/07/15.log"},
+		{"/var/log/%s.log", "/var/log/foo.log"},
+		{"/var/log/%h.log", "/var/log/" + hostname + ".log"},
+		{"/var/log/100%%.log", "/var/log/100%.log"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.template, func(t *testing.T) {
+			p, err := newPathTemplate(c.template)
+			if err != nil {
+				t.Fatalf("Got %v, expecting success", err)
+			}
+			if path, _ := p.expand(tm, "foo"); path != c.expected {
+				t.Errorf("Got %s, expecting %s", path, c.expected)
+			}
+		})
+	}
+
+	for _, bad := range []string{"/var/log/%q.log", "/var/log/app.log%"} {
+		if _, err := newPathTemplate(bad); err == nil {
+			t.Errorf("Got success for '%s', expecting failure", bad)
+		}
+	}
+
+	if p, _ := newPathTemplate("/var/log/app-%Y%m%d-%s.log"); p.glob() != "/var/log/app-***-*.log" {
+		t.Errorf("Got glob %s", p.glob())
+	}
+}
+
+func TestTemplatedFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "TestTemplatedFiles")
+	defer os.RemoveAll(dir)
+
+	o := DefaultOptions()
+	o.RotateOutputPath = dir + "/%s/app-%Y%m%d.log"
+	o.RotationMaxAge = 1
+	files, err := newTemplatedFiles(o)
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	defer files.close()
+
+	day := time.Date(2019, time.March, 7, 15, 0, 0, 0, time.UTC)
+	_ = files.write(zapcore.Entry{Time: day}, []byte("DAY1\n"))
+	_ = files.write(zapcore.Entry{Time: day, LoggerName: "foo"}, []byte("FOO\n"))
+
+	// make the first file look stale, such that switching files deletes it
+	first := filepath.Join(dir, "default", "app-20190307.log")
+	stale := time.Now().Add(-72 * time.Hour)
+	_ = os.Chtimes(first, stale, stale)
+
+	_ = files.write(zapcore.Entry{Time: day.Add(24 * time.Hour)}, []byte("DAY2\n"))
+
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, "default", "app-20190308.log")); string(content) != "DAY2\n" {
+		t.Errorf("Got %q, expecting the record of the second day", content)
+	}
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, "foo", "app-20190307.log")); string(content) != "FOO\n" {
+		t.Errorf("Got %q, expecting the record of the foo scope", content)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("Got %v, expecting the stale file to be deleted", err)
+	}
+}
+
+func TestTemplatedRotateOutputPath(t *testing.T) {
+	resetGlobals()
+
+	dir, _ := ioutil.TempDir("", "TestTemplatedRotateOutputPath")
+	defer os.RemoveAll(dir)
+
+	o := DefaultOptions()
+	o.OutputPaths = []string{}
+	o.RotateOutputPath = dir + "/app-%s.log"
+	if err := Configure(o); err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+
+	Info("HELLO")
+	_ = Configure(DefaultOptions())
+
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "app-default.log"))
+	if !strings.Contains(string(content), "HELLO") {
+		t.Errorf("Got %q, expecting the record", content)
+	}
+
+	o.RotateOutputPath = dir + "/app-%x.log"
+	if err := Configure(o); err == nil {
+		t.Error("Got success, expecting an unknown directive to fail")
+	}
+}
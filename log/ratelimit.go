@@ -0,0 +1,122 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// RateLimit caps the rate at which messages are output through a scope, such that a misbehaving
+// component can't starve the log sinks nor fill up the log storage. Messages are limited using a
+// token bucket: each message takes a token, tokens are refilled at PerSecond tokens per second, and
+// the bucket holds at most Burst tokens. Messages output while the bucket is empty are suppressed,
+// and once messages go through again, they are preceded by a warning reporting how many messages
+// were suppressed. Fatal messages are never suppressed.
+//
+// The zero value disables rate limiting.
+type RateLimit struct {
+	// PerSecond is the sustained number of messages output each second.
+	PerSecond float64
+
+	// Burst is the number of messages which can be output at once, which defaults to PerSecond
+	// rounded up.
+	Burst int
+}
+
+type rateLimiter struct {
+	limit RateLimit
+	burst float64
+
+	mu         sync.Mutex
+	tokens     float64
+	last       time.Time
+	suppressed uint64
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.PerSecond <= 0 {
+		return nil
+	}
+
+	burst := float64(limit.Burst)
+	if limit.Burst <= 0 {
+		burst = math.Ceil(limit.PerSecond)
+	}
+	return &rateLimiter{
+		limit:  limit,
+		burst:  burst,
+		tokens: burst,
+	}
+}
+
+// allow returns whether a message should be logged, as well as the number of messages suppressed
+// since the last message logged.
+func (r *rateLimiter) allow(level zapcore.Level, now time.Time) (bool, uint64) {
+	if r == nil || level >= zapcore.FatalLevel {
+		return true, 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.last.IsZero() {
+		r.tokens += now.Sub(r.last).Seconds() * r.limit.PerSecond
+		if r.tokens > r.burst {
+			r.tokens = r.burst
+		}
+	}
+	r.last = now
+
+	if r.tokens < 1 {
+		r.suppressed++
+		return false, 0
+	}
+
+	r.tokens--
+	suppressed := r.suppressed
+	r.suppressed = 0
+	return true, suppressed
+}
+
+func (l RateLimit) String() string {
+	return strconv.FormatFloat(l.PerSecond, 'g', -1, 64) + ":" + strconv.Itoa(l.Burst)
+}
+
+// convertScopedRateLimit parses a rate limit of the form <scope>:<per second>:<burst>.
+func convertScopedRateLimit(sl string) (string, RateLimit, error) {
+	pieces := strings.Split(sl, ":")
+	if len(pieces) != 3 {
+		return "", RateLimit{}, fmt.Errorf("invalid rate limit format '%s'", sl)
+	}
+
+	perSecond, err := strconv.ParseFloat(pieces[1], 64)
+	if err != nil || perSecond < 0 || math.IsInf(perSecond, 0) {
+		return "", RateLimit{}, fmt.Errorf("invalid rate limit value '%s' in '%s'", pieces[1], sl)
+	}
+
+	burst, err := strconv.Atoi(pieces[2])
+	if err != nil || burst < 0 {
+		return "", RateLimit{}, fmt.Errorf("invalid rate limit burst '%s' in '%s'", pieces[2], sl)
+	}
+
+	return pieces[0], RateLimit{PerSecond: perSecond, Burst: burst}, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	r := newRateLimiter(RateLimit{PerSecond: 2, Burst: 3})
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := r.allow(zapcore.InfoLevel, now); !ok {
+			t.Errorf("Got message %d suppressed, expecting the burst to go through", i)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if ok, _ := r.allow(zapcore.InfoLevel, now); ok {
+			t.Errorf("Got message %d output, expecting the bucket to be empty", i)
+		}
+	}
+	if ok, _ := r.allow(zapcore.FatalLevel, now); !ok {
+		t.Error("Got a fatal message suppressed, expecting fatal messages to go through")
+	}
+
+	// half a second refills a token at 2 per second
+	now = now.Add(500 * time.Millisecond)
+	if ok, suppressed := r.allow(zapcore.InfoLevel, now); !ok || suppressed != 4 {
+		t.Errorf("Got %v, %d, expecting the message to be output after 4 suppressed ones", ok, suppressed)
+	}
+	if ok, _ := r.allow(zapcore.InfoLevel, now); ok {
+		t.Error("Got the message output, expecting the bucket to be empty")
+	}
+
+	// the bucket doesn't fill beyond the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 4; i++ {
+		ok, _ := r.allow(zapcore.InfoLevel, now)
+		if ok != (i < 3) {
+			t.Errorf("Got %v for message %d, expecting the bucket to hold 3 tokens", ok, i)
+		}
+	}
+
+	if r = newRateLimiter(RateLimit{PerSecond: 1.5}); r.burst != 2 {
+		t.Errorf("Got a burst of %v, expecting it to default to 2", r.burst)
+	}
+	if r = newRateLimiter(RateLimit{}); r != nil {
+		t.Error("Got a rate limiter, expecting the zero limit to disable rate limiting")
+	}
+}
+
+func TestScopeRateLimit(t *testing.T) {
+	s := RegisterScope("TestScopeRateLimit", "", 0)
+
+	old := funcs.Load().(patchTable)
+	defer funcs.Store(old)
+
+	var entries []zapcore.Entry
+	pt := old
+	pt.write = func(ent zapcore.Entry, fields []zapcore.Field) error {
+		entries = append(entries, ent)
+		return nil
+	}
+	funcs.Store(pt)
+
+	limit := RateLimit{PerSecond: 0.001, Burst: 2}
+	s.SetRateLimit(limit)
+	if got := s.GetRateLimit(); got != limit {
+		t.Errorf("Got %v, expecting %v", got, limit)
+	}
+	for i := 0; i < 10; i++ {
+		s.Info("Hello")
+	}
+	if len(entries) != 2 {
+		t.Errorf("Got %d writes, expecting 2", len(entries))
+	}
+
+	// refill the bucket
+	s.registered().rateLimiter.Load().(*rateLimiter).tokens = 1
+	entries = nil
+	s.Info("Hello")
+	if len(entries) != 2 || entries[0].Message != "suppressed 8 messages" || entries[0].Level != zapcore.WarnLevel || entries[1].Message != "Hello" {
+		t.Errorf("Got %v, expecting a summary of the suppressed messages", entries)
+	}
+
+	entries = nil
+	s.SetRateLimit(RateLimit{})
+	for i := 0; i < 10; i++ {
+		s.Info("Hello")
+	}
+	if len(entries) != 10 {
+		t.Errorf("Got %d writes, expecting 10 once rate limiting is disabled", len(entries))
+	}
+}
+
+func TestOptionsRateLimit(t *testing.T) {
+	resetGlobals()
+	s := RegisterScope("TestOptionsRateLimit", "", 0)
+
+	o := DefaultOptions()
+	if _, err := o.GetRateLimit("TestOptionsRateLimit"); err == nil {
+		t.Error("Got success, expecting no rate limit to be defined")
+	}
+
+	limit := RateLimit{PerSecond: 0.5, Burst: 10}
+	o.SetRateLimit("TestOptionsRateLimit", RateLimit{PerSecond: 1})
+	o.SetRateLimit(DefaultScopeName, RateLimit{PerSecond: 100})
+	o.SetRateLimit("TestOptionsRateLimit", limit)
+	if got, err := o.GetRateLimit("TestOptionsRateLimit"); err != nil || got != limit {
+		t.Errorf("Got %v, %v, expecting %v", got, err, limit)
+	}
+
+	if err := Configure(o); err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	if got := s.GetRateLimit(); got != limit {
+		t.Errorf("Got %v, expecting %v", got, limit)
+	}
+	if got := defaultScope.GetRateLimit(); got != (RateLimit{PerSecond: 100}) {
+		t.Errorf("Got %v, expecting the default scope to be rate limited", got)
+	}
+
+	o = DefaultOptions()
+	o.rateLimits = "all:5:1"
+	if err := Configure(o); err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	if got := s.GetRateLimit(); got != (RateLimit{PerSecond: 5, Burst: 1}) {
+		t.Errorf("Got %v, expecting the override to apply", got)
+	}
+
+	for _, bad := range []string{"default:1", "default:a:2", "default:-1:2", "default:1:-2", "foobar:1:2"} {
+		o = DefaultOptions()
+		o.rateLimits = bad
+		if err := Configure(o); err == nil {
+			t.Errorf("Got success for '%s', expecting failure", bad)
+		}
+	}
+
+	resetGlobals()
+	_ = Configure(DefaultOptions())
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Record is a log record intercepted by a Recorder.
+type Record struct {
+	Time    time.Time
+	Level   Level
+	Scope   string
+	Message string
+	Fields  map[string]interface{}
+}
+
+// Recorder intercepts the records output through scopes during a test, see Capture.
+type Recorder struct {
+	t      testing.TB
+	scopes map[string]bool
+
+	mu      sync.Mutex
+	records []Record
+}
+
+// Capture intercepts the records output through the given scopes, or through all scopes if none is
+// given, until the test completes. Records are kept by the returned recorder rather than written to
+// the log outputs, which lets tests make assertions about their logs without redirecting stdout.
+// Fatal records don't exit the process while captured.
+//
+//	r := log.Capture(t, "ads")
+//	push()
+//	r.AssertContains(log.WarnLevel, "pushed 0 clusters")
+//
+// As the interception is process-wide, tests capturing records can't run in parallel, and must not
+// call Configure until they complete.
+func Capture(t testing.TB, scopes ...string) *Recorder {
+	r := &Recorder{t: t}
+	if len(scopes) > 0 {
+		r.scopes = make(map[string]bool, len(scopes))
+		for _, s := range scopes {
+			r.scopes[s] = true
+		}
+	}
+
+	old := funcs.Load().(patchTable)
+	pt := old
+	pt.write = r.write
+	pt.exitProcess = func(int) {}
+	funcs.Store(pt)
+	t.Cleanup(func() { funcs.Store(old) })
+
+	return r
+}
+
+func (r *Recorder) write(ent zapcore.Entry, fields []zapcore.Field) error {
+	scope := ent.LoggerName
+	if scope == "" {
+		scope = DefaultScopeName
+	}
+	if r.scopes != nil && !r.scopes[scope] {
+		return nil
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	r.mu.Lock()
+	r.records = append(r.records, Record{
+		Time:    ent.Time,
+		Level:   zapToLevel(ent.Level),
+		Scope:   scope,
+		Message: ent.Message,
+		Fields:  enc.Fields,
+	})
+	r.mu.Unlock()
+	return nil
+}
+
+func zapToLevel(l zapcore.Level) Level {
+	switch l {
+	case zapcore.DebugLevel:
+		return DebugLevel
+	case zapcore.InfoLevel:
+		return InfoLevel
+	case zapcore.WarnLevel:
+		return WarnLevel
+	case zapcore.ErrorLevel:
+		return ErrorLevel
+	}
+	return FatalLevel
+}
+
+// Records returns a snapshot of the records captured so far.
+func (r *Recorder) Records() []Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Record(nil), r.records...)
+}
+
+// Reset discards the records captured so far.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.records = nil
+	r.mu.Unlock()
+}
+
+// Contains returns whether a record at the given level holds substring in its message.
+func (r *Recorder) Contains(level Level, substring string) bool {
+	for _, rec := range r.Records() {
+		if rec.Level == level && strings.Contains(rec.Message, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertContains fails the test unless a record at the given level holds substring in its message.
+func (r *Recorder) AssertContains(level Level, substring string) {
+	r.t.Helper()
+	if !r.Contains(level, substring) {
+		r.t.Errorf("Got %s, expecting a %s record containing %q", r, levelToString[level], substring)
+	}
+}
+
+// AssertNotContains fails the test if a record at the given level holds substring in its message.
+func (r *Recorder) AssertNotContains(level Level, substring string) {
+	r.t.Helper()
+	if r.Contains(level, substring) {
+		r.t.Errorf("Got %s, expecting no %s record containing %q", r, levelToString[level], substring)
+	}
+}
+
+// String lists the records captured so far, one per line.
+func (r *Recorder) String() string {
+	var b strings.Builder
+	b.WriteString("records [")
+	for _, rec := range r.Records() {
+		b.WriteString("\n\t")
+		b.WriteString(levelToString[rec.Level])
+		b.WriteString(" ")
+		b.WriteString(rec.Scope)
+		b.WriteString(": ")
+		b.WriteString(rec.Message)
+	}
+	b.WriteString("\n]")
+	return b.String()
+}
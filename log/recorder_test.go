@@ -0,0 +1,85 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fakeTB records the failures of assertions.
+type fakeTB struct {
+	testing.TB
+	failures int
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.failures++
+}
+
+func TestCaptureRecorder(t *testing.T) {
+	s := RegisterScope("TestCaptureRecorder", "", 0)
+	other := RegisterScope("TestCaptureRecorderOther", "", 0)
+
+	before := reflect.ValueOf(funcs.Load().(patchTable).write).Pointer()
+	t.Run("capture", func(t *testing.T) {
+		r := Capture(t, "TestCaptureRecorder", DefaultScopeName)
+
+		s.Warnw("pushed 0 clusters", "proxy", "sidecar~1")
+		other.Warn("not captured")
+		Info("from the default scope")
+		s.Fatal("fatal doesn't exit")
+
+		r.AssertContains(WarnLevel, "pushed 0")
+		r.AssertContains(InfoLevel, "default scope")
+		r.AssertContains(FatalLevel, "doesn't exit")
+		r.AssertNotContains(WarnLevel, "not captured")
+		r.AssertNotContains(InfoLevel, "pushed 0")
+
+		records := r.Records()
+		if len(records) != 3 {
+			t.Fatalf("Got %d records, expecting 3", len(records))
+		}
+		if rec := records[0]; rec.Scope != "TestCaptureRecorder" || rec.Fields["proxy"] != "sidecar~1" {
+			t.Errorf("Got %+v, expecting the scope and fields of the record", rec)
+		}
+		if records[1].Scope != DefaultScopeName {
+			t.Errorf("Got scope %q, expecting the default scope", records[1].Scope)
+		}
+
+		tb := &fakeTB{TB: t}
+		r.t = tb
+		r.AssertContains(ErrorLevel, "pushed 0")
+		r.AssertNotContains(WarnLevel, "pushed 0")
+		if tb.failures != 2 {
+			t.Errorf("Got %d failures, expecting 2", tb.failures)
+		}
+
+		r.Reset()
+		if len(r.Records()) != 0 {
+			t.Error("Expecting no records once reset")
+		}
+	})
+
+	// the capture of the subtest ended with it, and records of all scopes are captured by default
+	if reflect.ValueOf(funcs.Load().(patchTable).write).Pointer() != before {
+		t.Error("Expecting the write function to be restored")
+	}
+	r := Capture(t)
+	other.Warn("captured")
+	r.AssertContains(WarnLevel, "captured")
+}
@@ -0,0 +1,164 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces the sensitive data masked by the redaction filters.
+const Redacted = "[REDACTED]"
+
+// redactor holds the redaction filters, it is replaced as a whole when filters are added.
+type redactor struct {
+	fields   map[string]bool // lower case
+	patterns []*regexp.Regexp
+}
+
+var (
+	redactors   atomic.Value // *redactor
+	redactorsMu sync.Mutex
+)
+
+func init() {
+	redactors.Store((*redactor)(nil))
+}
+
+// RedactFields registers names of fields, such as "authorization" or "token", whose values are
+// replaced by Redacted in all log records, whatever the type of the values. Names are matched
+// ignoring case. Redaction happens before log records reach any output, including the records
+// captured from the standard golang "log" package, zap and gRPC.
+func RedactFields(names ...string) {
+	updateRedactor(func(r *redactor) {
+		for _, n := range names {
+			r.fields[strings.ToLower(n)] = true
+		}
+	})
+}
+
+// RedactPattern registers a regular expression whose matches are replaced by Redacted in the
+// messages of all log records, as well as in the values of their string, error and fmt.Stringer
+// fields. This returns an error if the pattern doesn't compile.
+func RedactPattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid redaction pattern '%s': %v", pattern, err)
+	}
+
+	updateRedactor(func(r *redactor) {
+		r.patterns = append(r.patterns, re)
+	})
+	return nil
+}
+
+// ClearRedactions unregisters all the redaction filters.
+func ClearRedactions() {
+	redactorsMu.Lock()
+	redactors.Store((*redactor)(nil))
+	redactorsMu.Unlock()
+}
+
+func updateRedactor(update func(r *redactor)) {
+	redactorsMu.Lock()
+	defer redactorsMu.Unlock()
+
+	r := &redactor{fields: make(map[string]bool)}
+	if old := redactors.Load().(*redactor); old != nil {
+		for n := range old.fields {
+			r.fields[n] = true
+		}
+		r.patterns = append(r.patterns, old.patterns...)
+	}
+
+	update(r)
+	redactors.Store(r)
+}
+
+func (r *redactor) redactString(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+func (r *redactor) redactFields(fields []zapcore.Field) []zapcore.Field {
+	if len(fields) == 0 {
+		return fields
+	}
+
+	redacted := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		redacted[i] = r.redactField(f)
+	}
+	return redacted
+}
+
+func (r *redactor) redactField(f zapcore.Field) zapcore.Field {
+	if r.fields[strings.ToLower(f.Key)] {
+		return zap.String(f.Key, Redacted)
+	}
+
+	if len(r.patterns) == 0 {
+		return f
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		f.String = r.redactString(f.String)
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok && err != nil {
+			return zap.String(f.Key, r.redactString(err.Error()))
+		}
+	case zapcore.StringerType:
+		if s, ok := f.Interface.(fmt.Stringer); ok && s != nil {
+			return zap.String(f.Key, r.redactString(s.String()))
+		}
+	}
+	return f
+}
+
+// redactingCore applies the redaction filters to the log records handed to the wrapped core.
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	if r := redactors.Load().(*redactor); r != nil {
+		fields = r.redactFields(fields)
+	}
+	return redactingCore{c.Core.With(fields)}
+}
+
+func (c redactingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c redactingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if r := redactors.Load().(*redactor); r != nil {
+		ent.Message = r.redactString(ent.Message)
+		fields = r.redactFields(fields)
+	}
+	return c.Core.Write(ent, fields)
+}
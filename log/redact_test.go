@@ -0,0 +1,93 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRedaction(t *testing.T) {
+	resetGlobals()
+	defer ClearRedactions()
+
+	RedactFields("Authorization", "token")
+	if err := RedactPattern(`Bearer [A-Za-z0-9.]+`); err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	if err := RedactPattern(`[`); err == nil {
+		t.Error("Got success, expecting an invalid pattern to fail")
+	}
+
+	o := DefaultOptions()
+	o.JSONEncoding = true
+
+	lines, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+		Infow("sending Bearer abc.def",
+			"authorization", "Bearer xyz",
+			"TOKEN", 42,
+			"header", "x-auth: Bearer xyz",
+			"error", errors.New("rejected Bearer xyz"),
+			"user", "alice")
+		defaultScope.WithLabels("token", "secret").Info("labelled")
+		zap.L().Info("captured", zap.String("token", "secret"))
+		_ = Sync()
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+
+	ClearRedactions()
+	lines2, _ := captureStdout(func() {
+		_ = Configure(o)
+		Infow("cleared", "token", "visible")
+		_ = Sync()
+	})
+	_ = Configure(DefaultOptions())
+
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatalf("Got %v, expecting %q to be JSON", err, lines[0])
+	}
+	expected := map[string]interface{}{
+		"msg":           "sending " + Redacted,
+		"authorization": Redacted,
+		"TOKEN":         Redacted,
+		"header":        "x-auth: " + Redacted,
+		"error":         "rejected " + Redacted,
+		"user":          "alice",
+	}
+	for k, v := range expected {
+		if m[k] != v {
+			t.Errorf("Got %s=%v, expecting %v", k, m[k], v)
+		}
+	}
+
+	for _, l := range lines[1:3] {
+		if strings.Contains(l, "secret") {
+			t.Errorf("Got %q, expecting the token to be redacted", l)
+		}
+	}
+	if !strings.Contains(lines2[0], "visible") {
+		t.Errorf("Got %q, expecting no redaction once cleared", lines2[0])
+	}
+}
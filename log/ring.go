@@ -0,0 +1,131 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"sort"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ringBuffer retains the last records of each scope, whatever their output level, see
+// Options.RingBufferSize.
+type ringBuffer struct {
+	size int
+
+	mu     sync.RWMutex
+	scopes map[string]*scopeRing
+}
+
+// scopeRing holds the last records of a scope, records[next] being the oldest once it is full.
+type scopeRing struct {
+	mu      sync.Mutex
+	records []Record
+	next    int
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{
+		size:   size,
+		scopes: make(map[string]*scopeRing),
+	}
+}
+
+func (b *ringBuffer) add(ent zapcore.Entry, fields []zapcore.Field) {
+	scope := ent.LoggerName
+	if scope == "" {
+		scope = DefaultScopeName
+	}
+
+	// records are retained as output, redacted and with their fields encoded
+	if r := redactors.Load().(*redactor); r != nil {
+		ent.Message = r.redactString(ent.Message)
+		fields = r.redactFields(fields)
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	rec := Record{
+		Time:    ent.Time,
+		Level:   zapToLevel(ent.Level),
+		Scope:   scope,
+		Message: ent.Message,
+		Fields:  enc.Fields,
+	}
+
+	b.mu.RLock()
+	sr, ok := b.scopes[scope]
+	b.mu.RUnlock()
+	if !ok {
+		b.mu.Lock()
+		if sr, ok = b.scopes[scope]; !ok {
+			sr = &scopeRing{records: make([]Record, 0, b.size)}
+			b.scopes[scope] = sr
+		}
+		b.mu.Unlock()
+	}
+
+	sr.mu.Lock()
+	if len(sr.records) < b.size {
+		sr.records = append(sr.records, rec)
+	} else {
+		sr.records[sr.next] = rec
+		sr.next = (sr.next + 1) % b.size
+	}
+	sr.mu.Unlock()
+}
+
+// snapshot returns the records of the scope, oldest first.
+func (sr *scopeRing) snapshot() []Record {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	records := make([]Record, 0, len(sr.records))
+	records = append(records, sr.records[sr.next:]...)
+	return append(records, sr.records[:sr.next]...)
+}
+
+// RecentRecords returns the records retained by the ring buffer, see Options.RingBufferSize, ordered by
+// time. Only the records of the given scope are returned unless scope is empty, and only those of the
+// given level or more severe. This returns nil when the ring buffer is disabled.
+func RecentRecords(scope string, level Level) []Record {
+	b := funcs.Load().(patchTable).ring
+	if b == nil {
+		return nil
+	}
+
+	b.mu.RLock()
+	rings := make([]*scopeRing, 0, len(b.scopes))
+	for name, sr := range b.scopes {
+		if scope == "" || name == scope {
+			rings = append(rings, sr)
+		}
+	}
+	b.mu.RUnlock()
+
+	records := []Record{}
+	for _, sr := range rings {
+		for _, r := range sr.snapshot() {
+			if r.Level <= level {
+				records = append(records, r)
+			}
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return records
+}
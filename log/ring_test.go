@@ -0,0 +1,93 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRingBuffer(t *testing.T) {
+	ads := RegisterScope("ringads", "For testing", 0)
+	other := RegisterScope("ringother", "For testing", 0)
+
+	if RecentRecords("", DebugLevel) != nil {
+		t.Error("Got records, expecting none while the ring buffer is disabled")
+	}
+
+	o := testOptions()
+	o.RingBufferSize = 3
+	o.SetRingLevel("ringads", DebugLevel)
+
+	lines, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+		ClearRedactions()
+		RedactFields("token")
+		defer ClearRedactions()
+
+		for i := 0; i < 5; i++ {
+			ads.WithLabels("push", i).Debugf("pushing %d", i)
+		}
+		ads.Warnw("push failed", "token", "secret")
+		other.Debug("other debug")
+		other.Info("other")
+
+		// the records are kept when reconfiguring with the same size
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+
+	if out := strings.Join(lines, "\n"); strings.Contains(out, "pushing") || !strings.Contains(out, "push failed") {
+		t.Errorf("Got %v, expecting only the warning to be output", lines)
+	}
+
+	records := RecentRecords("ringads", DebugLevel)
+	if len(records) != 3 {
+		t.Fatalf("Got %v, expecting the last 3 records", records)
+	}
+	for i, r := range records[:2] {
+		if r.Level != DebugLevel || r.Message != "pushing "+strconv.Itoa(i+3) || r.Fields["push"] != int64(i+3) {
+			t.Errorf("Got %v, expecting debug message %d", r, i+3)
+		}
+	}
+	if records[2].Level != WarnLevel || records[2].Fields["token"] != Redacted {
+		t.Errorf("Got %v, expecting the redacted warning", records[2])
+	}
+
+	if records := RecentRecords("ringads", WarnLevel); len(records) != 1 {
+		t.Errorf("Got %v, expecting only the warning", records)
+	}
+	if records := RecentRecords("", DebugLevel); len(records) < 4 {
+		t.Errorf("Got %v, expecting the records of all scopes", records)
+	}
+	if records := RecentRecords("ringother", DebugLevel); len(records) != 1 || records[0].Message != "other" {
+		t.Errorf("Got %v, expecting only the info message, below the default ring level", records)
+	}
+	if other.shouldEmit(DebugLevel) || !ads.shouldEmit(DebugLevel) {
+		t.Error("Got debug messages formatted for scopes which don't retain them")
+	}
+
+	_ = Configure(DefaultOptions())
+	if RecentRecords("", DebugLevel) != nil {
+		t.Error("Got records, expecting none once the ring buffer is disabled")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/natefinch/lumberjack"
+)
+
+const megabyte = 1024 * 1024
+
+// rotatingSink writes to a rotating log file. The file is rotated once it would grow past
+// its maximum size or once it gets older than the rotation interval, whichever comes first,
+// after which the oldest backups are deleted until the backups fit in their total size limit.
+type rotatingSink struct {
+	mu           sync.Mutex
+	logger       *lumberjack.Logger
+	maxSize      int64 // bytes
+	maxTotalSize int64 // bytes
+	compress     bool
+	interval     time.Duration
+	size         int64
+	openedAt     time.Time
+
+	// can be replaced by tests
+	now func() time.Time
+}
+
+func newRotatingSink(options *Options) *rotatingSink {
+	s := &rotatingSink{
+		logger: &lumberjack.Logger{
+			Filename:   options.RotateOutputPath,
+			MaxSize:    options.RotationMaxSize,
+			MaxBackups: options.RotationMaxBackups,
+			MaxAge:     options.RotationMaxAge,
+			Compress:   options.RotationCompress,
+		},
+		maxSize:      int64(options.RotationMaxSize) * megabyte,
+		maxTotalSize: int64(options.RotationMaxTotalSize) * megabyte,
+		compress:     options.RotationCompress,
+		interval:     options.RotationInterval,
+		now:          time.Now,
+	}
+
+	if s.maxSize <= 0 {
+		// the default of lumberjack
+		s.maxSize = 100 * megabyte
+	}
+
+	s.openedAt = s.now()
+	if fi, err := os.Stat(options.RotateOutputPath); err == nil {
+		s.size = fi.Size()
+	}
+
+	return s
+}
+
+func (s *rotatingSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.size > 0 && (s.size+int64(len(p)) > s.maxSize || (s.interval > 0 && now.Sub(s.openedAt) >= s.interval)) {
+		if err := s.logger.Rotate(); err != nil {
+			return 0, err
+		}
+		s.size = 0
+		s.openedAt = now
+		s.prune()
+	}
+
+	n, err := s.logger.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+func (s *rotatingSink) Sync() error {
+	return nil
+}
+
+// Close closes the current file.
+func (s *rotatingSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logger.Close()
+}
+
+// prune deletes the oldest backups until the total size of the backups fits in maxTotalSize.
+//
+// When backups are compressed, lumberjack compresses them asynchronously after the rotation, so
+// only the finished compressed backups are counted and deleted: the backups still to compress,
+// and the compressed ones whose uncompressed backup wasn't deleted yet, are left for the next
+// rotation. The backups may therefore exceed maxTotalSize by the backups of one rotation.
+func (s *rotatingSink) prune() {
+	if s.maxTotalSize <= 0 {
+		return
+	}
+
+	dir := filepath.Dir(s.logger.Filename)
+	name := filepath.Base(s.logger.Filename)
+	ext := filepath.Ext(name)
+	prefix := name[:len(name)-len(ext)] + "-"
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	names := make(map[string]bool, len(files))
+	for _, fi := range files {
+		names[fi.Name()] = true
+	}
+
+	var backups []os.FileInfo
+	for _, fi := range files {
+		n := fi.Name()
+		if fi.IsDir() || !strings.HasPrefix(n, prefix) {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(n, ext+".gz"):
+			if names[strings.TrimSuffix(n, ".gz")] {
+				// still being compressed
+				continue
+			}
+		case strings.HasSuffix(n, ext):
+			if s.compress {
+				// to be compressed
+				continue
+			}
+		default:
+			continue
+		}
+		backups = append(backups, fi)
+	}
+
+	// newest first, the timestamps in the names sort chronologically
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Name() > backups[j].Name()
+	})
+
+	var total int64
+	for _, fi := range backups {
+		total += fi.Size()
+		if total > s.maxTotalSize {
+			_ = os.Remove(filepath.Join(dir, fi.Name()))
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readDir(t *testing.T, dir string) []os.FileInfo {
+	t.Helper()
+	rd, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Unable to read dir: %v", err)
+	}
+	return rd
+}
+
+func TestRotationInterval(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "TestRotationInterval")
+	defer os.RemoveAll(dir)
+
+	o := DefaultOptions()
+	o.RotateOutputPath = dir + "/rot.log"
+	o.RotationInterval = time.Hour
+
+	now := time.Now()
+	s := newRotatingSink(o)
+	s.now = func() time.Time { return now }
+
+	_, _ = s.Write([]byte("HELLO\n"))
+	now = now.Add(30 * time.Minute)
+	_, _ = s.Write([]byte("HELLO\n"))
+	if rd := readDir(t, dir); len(rd) != 1 {
+		t.Errorf("Got %d files, expecting no rotation before the interval", len(rd))
+	}
+
+	now = now.Add(time.Hour)
+	_, _ = s.Write([]byte("WORLD\n"))
+	if rd := readDir(t, dir); len(rd) != 2 {
+		t.Errorf("Got %d files, expecting a rotation once the interval passed", len(rd))
+	}
+
+	content, _ := ioutil.ReadFile(o.RotateOutputPath)
+	if string(content) != "WORLD\n" {
+		t.Errorf("Got %q, expecting the current file to only hold the latest write", content)
+	}
+	_ = s.logger.Close()
+}
+
+func TestRotationMaxTotalSize(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "TestRotationMaxTotalSize")
+	defer os.RemoveAll(dir)
+
+	o := DefaultOptions()
+	o.RotateOutputPath = dir + "/rot.log"
+	s := newRotatingSink(o)
+	s.maxSize = 100
+	s.maxTotalSize = 250
+
+	line := []byte(strings.Repeat("0123456789", 5)) // 50 bytes
+	for i := 0; i < 20; i++ {
+		if _, err := s.Write(line); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+
+		// backups are named after the time of rotation in milliseconds
+		time.Sleep(2 * time.Millisecond)
+	}
+	_ = s.logger.Close()
+
+	var total int64
+	backups := 0
+	for _, fi := range readDir(t, dir) {
+		if fi.Name() != "rot.log" {
+			total += fi.Size()
+			backups++
+		}
+	}
+	if total > 250 || backups != 2 {
+		t.Errorf("Got %d backups totalling %d bytes, expecting 2 backups of at most 250 bytes", backups, total)
+	}
+}
+
+func TestRotationCompress(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "TestRotationCompress")
+	defer os.RemoveAll(dir)
+
+	o := DefaultOptions()
+	o.RotateOutputPath = dir + "/rot.log"
+	o.RotationCompress = true
+	o.RotationInterval = time.Hour
+
+	now := time.Now()
+	s := newRotatingSink(o)
+	s.now = func() time.Time { return now }
+
+	_, _ = s.Write([]byte("HELLO\n"))
+	now = now.Add(2 * time.Hour)
+	_, _ = s.Write([]byte("WORLD\n"))
+
+	// compression happens asynchronously and there's no way to synchronize with it,
+	// so we poll
+	for i := 0; i < 500; i++ {
+		for _, fi := range readDir(t, dir) {
+			if strings.HasSuffix(fi.Name(), ".log.gz") {
+				_ = s.logger.Close()
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Error("Got no compressed backup, expecting the rotated file to be compressed")
+}
+
+func TestRotationPruneCompressed(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "TestRotationPruneCompressed")
+	defer os.RemoveAll(dir)
+
+	o := DefaultOptions()
+	o.RotateOutputPath = dir + "/rot.log"
+	o.RotationCompress = true
+	s := newRotatingSink(o)
+	s.maxTotalSize = 250
+
+	for name, size := range map[string]int{
+		"rot-2020-01-01T00-00-00.000.log.gz": 100, // finished, beyond the limit
+		"rot-2020-01-02T00-00-00.000.log.gz": 100, // finished
+		"rot-2020-01-03T00-00-00.000.log.gz": 100, // finished
+		"rot-2020-01-04T00-00-00.000.log":    200, // being compressed
+		"rot-2020-01-04T00-00-00.000.log.gz": 10,
+		"rot-2020-01-05T00-00-00.000.log":    200, // to be compressed
+	} {
+		if err := ioutil.WriteFile(dir+"/"+name, make([]byte, size), 0o644); err != nil {
+			t.Fatalf("Unable to write %s: %v", name, err)
+		}
+	}
+	s.prune()
+
+	var names []string
+	for _, fi := range readDir(t, dir) {
+		names = append(names, fi.Name())
+	}
+	if len(names) != 5 || names[0] != "rot-2020-01-02T00-00-00.000.log.gz" {
+		t.Errorf("Got %v, expecting only the oldest compressed backup to be deleted", names)
+	}
+}
+
+func TestRotationClosedOnReconfigure(t *testing.T) {
+	if _, err := ioutil.ReadDir("/proc/self/fd"); err != nil {
+		t.Skip("Unable to list the open files")
+	}
+
+	dir, _ := ioutil.TempDir("", "TestRotationClosedOnReconfigure")
+	defer os.RemoveAll(dir)
+
+	o := DefaultOptions()
+	o.RotateOutputPath = dir + "/rot.log"
+	o.OutputPaths = nil
+	for i := 0; i < 2; i++ {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+		Info("HELLO")
+	}
+	if err := Configure(DefaultOptions()); err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+
+	fds, _ := ioutil.ReadDir("/proc/self/fd")
+	for _, fd := range fds {
+		if target, _ := os.Readlink("/proc/self/fd/" + fd.Name()); target == o.RotateOutputPath {
+			t.Errorf("Got %s open, expecting the rotated file to be closed once the logs are reconfigured", target)
+		}
+	}
+}
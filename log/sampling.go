@@ -0,0 +1,133 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SamplingConfig controls how many of the messages output through a scope are actually logged, which
+// lets a chatty scope be enabled in production without flooding the log sinks. Sampling happens per
+// second: the first Initial messages with a given level and text are logged, then every Thereafter-th
+// of them. Independently of the text, at most PerSecondCap messages are logged overall. Fatal messages
+// are never dropped.
+//
+// The zero value disables sampling.
+type SamplingConfig struct {
+	// Initial is the number of messages with a given level and text which are logged each second
+	// before sampling kicks in. With Initial and Thereafter both 0, messages aren't sampled per text.
+	Initial int
+
+	// Thereafter controls which of the messages past Initial are logged, every Thereafter-th message
+	// is. With Thereafter 0, messages past Initial are all dropped.
+	Thereafter int
+
+	// PerSecondCap is the maximum number of messages logged each second, or 0 for no cap.
+	PerSecondCap int
+}
+
+const (
+	// number of counters per level, messages hashing to the same counter are sampled together
+	samplerBuckets = 256
+
+	samplerTick = int64(time.Second)
+
+	numZapLevels = int(zapcore.FatalLevel-zapcore.DebugLevel) + 1
+)
+
+type samplerCounter struct {
+	resetAt int64
+	count   uint64
+}
+
+// inc increments the counter and returns the count for the current tick.
+func (c *samplerCounter) inc(now int64) uint64 {
+	if atomic.LoadInt64(&c.resetAt) > now {
+		return atomic.AddUint64(&c.count, 1)
+	}
+
+	// races between concurrent resets only make the sampling slightly imprecise
+	atomic.StoreUint64(&c.count, 1)
+	atomic.StoreInt64(&c.resetAt, now+samplerTick)
+	return 1
+}
+
+type sampler struct {
+	config SamplingConfig
+	counts [numZapLevels][samplerBuckets]samplerCounter
+	total  samplerCounter
+}
+
+func newSampler(config SamplingConfig) *sampler {
+	if config == (SamplingConfig{}) {
+		return nil
+	}
+	return &sampler{config: config}
+}
+
+// allow returns whether a message should be logged.
+func (s *sampler) allow(level zapcore.Level, msg string, now time.Time) bool {
+	if s == nil || level >= zapcore.FatalLevel || level < zapcore.DebugLevel {
+		return true
+	}
+
+	nanos := now.UnixNano()
+	if s.config.Initial > 0 || s.config.Thereafter > 0 {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(msg))
+		n := s.counts[level-zapcore.DebugLevel][h.Sum32()%samplerBuckets].inc(nanos)
+
+		initial := uint64(s.config.Initial)
+		if n > initial && (s.config.Thereafter <= 0 || (n-initial)%uint64(s.config.Thereafter) != 0) {
+			return false
+		}
+	}
+
+	if s.config.PerSecondCap > 0 && s.total.inc(nanos) > uint64(s.config.PerSecondCap) {
+		return false
+	}
+
+	return true
+}
+
+func (c SamplingConfig) String() string {
+	return fmt.Sprintf("%d:%d:%d", c.Initial, c.Thereafter, c.PerSecondCap)
+}
+
+// convertScopedSampling parses a sampling configuration of the form <scope>:<initial>:<thereafter>:<cap>.
+func convertScopedSampling(ss string) (string, SamplingConfig, error) {
+	pieces := strings.Split(ss, ":")
+	if len(pieces) != 4 {
+		return "", SamplingConfig{}, fmt.Errorf("invalid sampling format '%s'", ss)
+	}
+
+	var values [3]int
+	for i, p := range pieces[1:] {
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 0 {
+			return "", SamplingConfig{}, fmt.Errorf("invalid sampling value '%s' in '%s'", p, ss)
+		}
+		values[i] = v
+	}
+
+	return pieces[0], SamplingConfig{Initial: values[0], Thereafter: values[1], PerSecondCap: values[2]}, nil
+}
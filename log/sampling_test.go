@@ -0,0 +1,148 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSamplerAllow(t *testing.T) {
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s := newSampler(SamplingConfig{Initial: 2, Thereafter: 3})
+	var allowed []int
+	for i := 1; i <= 10; i++ {
+		if s.allow(zapcore.InfoLevel, "Hello", now) {
+			allowed = append(allowed, i)
+		}
+	}
+	if fmt.Sprint(allowed) != "[1 2 5 8]" {
+		t.Errorf("Got %v allowed, expecting [1 2 5 8]", allowed)
+	}
+
+	if !s.allow(zapcore.WarnLevel, "Hello", now) {
+		t.Error("Got a warn message dropped, expecting levels to be sampled separately")
+	}
+	if !s.allow(zapcore.FatalLevel, "Hello", now) {
+		t.Error("Got a fatal message dropped, expecting fatal messages to never be dropped")
+	}
+	if !s.allow(zapcore.InfoLevel, "Hello", now.Add(time.Second)) {
+		t.Error("Got a message dropped, expecting the counts to reset each second")
+	}
+
+	s = newSampler(SamplingConfig{PerSecondCap: 3})
+	n := 0
+	for i := 0; i < 10; i++ {
+		if s.allow(zapcore.InfoLevel, fmt.Sprintf("Hello %d", i), now) {
+			n++
+		}
+	}
+	if n != 3 {
+		t.Errorf("Got %d allowed, expecting the cap of 3", n)
+	}
+
+	if s = newSampler(SamplingConfig{}); s != nil || !s.allow(zapcore.InfoLevel, "Hello", now) {
+		t.Error("Got messages sampled, expecting the zero config to disable sampling")
+	}
+}
+
+func TestScopeSampling(t *testing.T) {
+	s := RegisterScope("TestScopeSampling", "", 0)
+	s.SetOutputLevel(DebugLevel)
+
+	old := funcs.Load().(patchTable)
+	defer funcs.Store(old)
+
+	writes := 0
+	pt := old
+	pt.write = func(ent zapcore.Entry, fields []zapcore.Field) error {
+		writes++
+		return nil
+	}
+	funcs.Store(pt)
+
+	cfg := SamplingConfig{Initial: 2, Thereafter: 3}
+	s.SetSampling(cfg)
+	if got := s.GetSampling(); got != cfg {
+		t.Errorf("Got %v, expecting %v", got, cfg)
+	}
+	for i := 0; i < 10; i++ {
+		s.Debug("Hello")
+	}
+	if writes != 4 {
+		t.Errorf("Got %d writes, expecting 4", writes)
+	}
+
+	writes = 0
+	s.SetSampling(SamplingConfig{})
+	for i := 0; i < 10; i++ {
+		s.Debug("Hello")
+	}
+	if writes != 10 {
+		t.Errorf("Got %d writes, expecting 10 once sampling is disabled", writes)
+	}
+}
+
+func TestOptionsSampling(t *testing.T) {
+	resetGlobals()
+	s := RegisterScope("TestOptionsSampling", "", 0)
+
+	o := DefaultOptions()
+	if _, err := o.GetSampling("TestOptionsSampling"); err == nil {
+		t.Error("Got success, expecting no sampling to be defined")
+	}
+
+	cfg := SamplingConfig{Initial: 10, Thereafter: 100, PerSecondCap: 1000}
+	o.SetSampling("TestOptionsSampling", SamplingConfig{Initial: 1})
+	o.SetSampling(DefaultScopeName, SamplingConfig{Initial: 5})
+	o.SetSampling("TestOptionsSampling", cfg)
+	if got, err := o.GetSampling("TestOptionsSampling"); err != nil || got != cfg {
+		t.Errorf("Got %v, %v, expecting %v", got, err, cfg)
+	}
+
+	if err := Configure(o); err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	if got := s.GetSampling(); got != cfg {
+		t.Errorf("Got %v, expecting %v", got, cfg)
+	}
+	if got := defaultScope.GetSampling(); got != (SamplingConfig{Initial: 5}) {
+		t.Errorf("Got %v, expecting the default scope to be sampled", got)
+	}
+
+	o = DefaultOptions()
+	o.sampling = "all:1:0:0"
+	if err := Configure(o); err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	if got := s.GetSampling(); got != (SamplingConfig{Initial: 1}) {
+		t.Errorf("Got %v, expecting the override to apply", got)
+	}
+
+	for _, bad := range []string{"default:1:2", "default:a:2:3", "default:-1:2:3", "foobar:1:2:3"} {
+		o = DefaultOptions()
+		o.sampling = bad
+		if err := Configure(o); err == nil {
+			t.Errorf("Got success for '%s', expecting failure", bad)
+		}
+	}
+
+	resetGlobals()
+	_ = Configure(DefaultOptions())
+}
@@ -15,8 +15,11 @@
 package log
 
 import (
+	"errors"
 	"fmt"
+	"path"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -24,8 +27,15 @@ import (
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+
+	"istio.io/pkg/monitoring"
 )
 
+// scopeOutput holds the core writing the records of a scope in place of the log outputs.
+type scopeOutput struct {
+	core zapcore.Core
+}
+
 // Scope let's you log data for an area of code, enabling the user full control over
 // the level of logging output produced.
 type Scope struct {
@@ -34,21 +44,37 @@ type Scope struct {
 	nameToEmit  string
 	description string
 	callerSkip  int
+	records     map[zapcore.Level]monitoring.Int64Counter // counters of the records output, by level
+
+	// set at registration, guarded by lock
+	metadata ScopeMetadata
 
 	// set by the Configure method and adjustable dynamically
 	outputLevel     atomic.Value
 	stackTraceLevel atomic.Value
-	logCallers      atomic.Value
+	callerLevel     atomic.Value
+	ringLevel       atomic.Value
+	sampler         atomic.Value
+	rateLimiter     atomic.Value
+	output          atomic.Value // *scopeOutput replacing the log outputs, see SetSlogHandler
+
+	// set on scopes derived with WithLabels, the settings above are the ones of the root
+	root   *Scope
+	fields []zapcore.Field
+
+	// set on scopes derived by FromContext for contexts returned by NewDebugContext
+	forceDebug bool
 }
 
 var scopes = make(map[string]*Scope)
 var lock = sync.RWMutex{}
 
 // RegisterScope registers a new logging scope. If the same name is used multiple times
-// for a single process, the same Scope struct is returned.
+// for a single process, the same Scope struct is returned. The scope can optionally be
+// described by metadata, which replaces the metadata of a scope registered previously.
 //
 // Scope names cannot include colons, commas, or periods.
-func RegisterScope(name string, description string, callerSkip int) *Scope {
+func RegisterScope(name string, description string, callerSkip int, metadata ...ScopeMetadata) *Scope {
 	if strings.ContainsAny(name, ":,.") {
 		panic(fmt.Sprintf("scope name %s is invalid, it cannot contain colons, commas, or periods", name))
 	}
@@ -62,10 +88,14 @@ func RegisterScope(name string, description string, callerSkip int) *Scope {
 			name:        name,
 			description: description,
 			callerSkip:  callerSkip,
+			records:     scopeRecords(name),
 		}
 		s.SetOutputLevel(InfoLevel)
 		s.SetStackTraceLevel(NoneLevel)
 		s.SetLogCallers(false)
+		s.SetRingLevel(InfoLevel)
+		s.SetSampling(SamplingConfig{})
+		s.SetRateLimit(RateLimit{})
 
 		if name != DefaultScopeName {
 			s.nameToEmit = name
@@ -74,6 +104,10 @@ func RegisterScope(name string, description string, callerSkip int) *Scope {
 		scopes[name] = s
 	}
 
+	for _, md := range metadata {
+		s.metadata = md
+	}
+
 	return s
 }
 
@@ -99,23 +133,70 @@ func Scopes() map[string]*Scope {
 	return s
 }
 
+// SetLevelForPattern sets the output level of all the registered scopes whose name matches pattern,
+// using the syntax of path.Match, such as "ads*". This returns an error if the pattern is malformed.
+func SetLevelForPattern(pattern string, level Level) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid scope pattern '%s': %v", pattern, err)
+	}
+
+	for name, s := range Scopes() {
+		if ok, _ := path.Match(pattern, name); ok {
+			s.SetOutputLevel(level)
+		}
+	}
+
+	return nil
+}
+
+// SetLevels sets the output levels of a set of scopes at once. Keys are either scope names or patterns
+// as accepted by SetLevelForPattern. All the valid entries are applied, and an error is returned if any
+// key is a malformed pattern or names a scope which isn't registered.
+func SetLevels(levels map[string]Level) error {
+	allScopes := Scopes()
+
+	var errs []string
+	for key, level := range levels {
+		if isScopePattern(key) {
+			if err := SetLevelForPattern(key, level); err != nil {
+				errs = append(errs, err.Error())
+			}
+		} else if s, ok := allScopes[key]; ok {
+			s.SetOutputLevel(level)
+		} else {
+			errs = append(errs, fmt.Sprintf("unknown scope '%s' specified", key))
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// isScopePattern returns whether name holds any of the special characters of path.Match.
+func isScopePattern(name string) bool {
+	return strings.ContainsAny(name, "*?[\\")
+}
+
 // Fatal outputs a message at fatal level.
 func (s *Scope) Fatal(msg string, fields ...zapcore.Field) {
-	if s.GetOutputLevel() >= FatalLevel {
+	if s.shouldEmit(FatalLevel) {
 		s.emit(zapcore.FatalLevel, s.GetStackTraceLevel() >= FatalLevel, msg, fields)
 	}
 }
 
 // Fatala uses fmt.Sprint to construct and log a message at fatal level.
 func (s *Scope) Fatala(args ...interface{}) {
-	if s.GetOutputLevel() >= FatalLevel {
+	if s.shouldEmit(FatalLevel) {
 		s.emit(zapcore.FatalLevel, s.GetStackTraceLevel() >= FatalLevel, fmt.Sprint(args...), nil)
 	}
 }
 
 // Fatalf uses fmt.Sprintf to construct and log a message at fatal level.
 func (s *Scope) Fatalf(template string, args ...interface{}) {
-	if s.GetOutputLevel() >= FatalLevel {
+	if s.shouldEmit(FatalLevel) {
 		msg := template
 		if len(args) > 0 {
 			msg = fmt.Sprintf(template, args...)
@@ -124,6 +205,14 @@ func (s *Scope) Fatalf(template string, args ...interface{}) {
 	}
 }
 
+// Fatalw outputs a message at fatal level, along with structured fields made of alternating keys and values
+// which keep their type, such as ("count", 3, "elapsed", time.Second).
+func (s *Scope) Fatalw(msg string, keysAndValues ...interface{}) {
+	if s.shouldEmit(FatalLevel) {
+		s.emit(zapcore.FatalLevel, s.GetStackTraceLevel() >= FatalLevel, msg, keysAndValuesToFields(keysAndValues))
+	}
+}
+
 // FatalEnabled returns whether output of messages using this scope is currently enabled for fatal-level output.
 func (s *Scope) FatalEnabled() bool {
 	return s.GetOutputLevel() >= FatalLevel
@@ -131,21 +220,21 @@ func (s *Scope) FatalEnabled() bool {
 
 // Error outputs a message at error level.
 func (s *Scope) Error(msg string, fields ...zapcore.Field) {
-	if s.GetOutputLevel() >= ErrorLevel {
+	if s.shouldEmit(ErrorLevel) {
 		s.emit(zapcore.ErrorLevel, s.GetStackTraceLevel() >= ErrorLevel, msg, fields)
 	}
 }
 
 // Errora uses fmt.Sprint to construct and log a message at error level.
 func (s *Scope) Errora(args ...interface{}) {
-	if s.GetOutputLevel() >= ErrorLevel {
+	if s.shouldEmit(ErrorLevel) {
 		s.emit(zapcore.ErrorLevel, s.GetStackTraceLevel() >= ErrorLevel, fmt.Sprint(args...), nil)
 	}
 }
 
 // Errorf uses fmt.Sprintf to construct and log a message at error level.
 func (s *Scope) Errorf(template string, args ...interface{}) {
-	if s.GetOutputLevel() >= ErrorLevel {
+	if s.shouldEmit(ErrorLevel) {
 		msg := template
 		if len(args) > 0 {
 			msg = fmt.Sprintf(template, args...)
@@ -154,6 +243,14 @@ func (s *Scope) Errorf(template string, args ...interface{}) {
 	}
 }
 
+// Errorw outputs a message at error level, along with structured fields made of alternating keys and values
+// which keep their type, such as ("count", 3, "elapsed", time.Second).
+func (s *Scope) Errorw(msg string, keysAndValues ...interface{}) {
+	if s.shouldEmit(ErrorLevel) {
+		s.emit(zapcore.ErrorLevel, s.GetStackTraceLevel() >= ErrorLevel, msg, keysAndValuesToFields(keysAndValues))
+	}
+}
+
 // ErrorEnabled returns whether output of messages using this scope is currently enabled for error-level output.
 func (s *Scope) ErrorEnabled() bool {
 	return s.GetOutputLevel() >= ErrorLevel
@@ -161,26 +258,34 @@ func (s *Scope) ErrorEnabled() bool {
 
 // Warn outputs a message at warn level.
 func (s *Scope) Warn(msg string, fields ...zapcore.Field) {
-	if s.GetOutputLevel() >= WarnLevel {
-		s.emit(zapcore.WarnLevel, s.GetStackTraceLevel() >= ErrorLevel, msg, fields)
+	if s.shouldEmit(WarnLevel) {
+		s.emit(zapcore.WarnLevel, s.GetStackTraceLevel() >= WarnLevel, msg, fields)
 	}
 }
 
 // Warna uses fmt.Sprint to construct and log a message at warn level.
 func (s *Scope) Warna(args ...interface{}) {
-	if s.GetOutputLevel() >= WarnLevel {
-		s.emit(zapcore.WarnLevel, s.GetStackTraceLevel() >= ErrorLevel, fmt.Sprint(args...), nil)
+	if s.shouldEmit(WarnLevel) {
+		s.emit(zapcore.WarnLevel, s.GetStackTraceLevel() >= WarnLevel, fmt.Sprint(args...), nil)
 	}
 }
 
 // Warnf uses fmt.Sprintf to construct and log a message at warn level.
 func (s *Scope) Warnf(template string, args ...interface{}) {
-	if s.GetOutputLevel() >= WarnLevel {
+	if s.shouldEmit(WarnLevel) {
 		msg := template
 		if len(args) > 0 {
 			msg = fmt.Sprintf(template, args...)
 		}
-		s.emit(zapcore.WarnLevel, s.GetStackTraceLevel() >= ErrorLevel, msg, nil)
+		s.emit(zapcore.WarnLevel, s.GetStackTraceLevel() >= WarnLevel, msg, nil)
+	}
+}
+
+// Warnw outputs a message at warn level, along with structured fields made of alternating keys and values
+// which keep their type, such as ("count", 3, "elapsed", time.Second).
+func (s *Scope) Warnw(msg string, keysAndValues ...interface{}) {
+	if s.shouldEmit(WarnLevel) {
+		s.emit(zapcore.WarnLevel, s.GetStackTraceLevel() >= WarnLevel, msg, keysAndValuesToFields(keysAndValues))
 	}
 }
 
@@ -191,26 +296,34 @@ func (s *Scope) WarnEnabled() bool {
 
 // Info outputs a message at info level.
 func (s *Scope) Info(msg string, fields ...zapcore.Field) {
-	if s.GetOutputLevel() >= InfoLevel {
-		s.emit(zapcore.InfoLevel, s.GetStackTraceLevel() >= ErrorLevel, msg, fields)
+	if s.shouldEmit(InfoLevel) {
+		s.emit(zapcore.InfoLevel, s.GetStackTraceLevel() >= InfoLevel, msg, fields)
 	}
 }
 
 // Infoa uses fmt.Sprint to construct and log a message at info level.
 func (s *Scope) Infoa(args ...interface{}) {
-	if s.GetOutputLevel() >= InfoLevel {
-		s.emit(zapcore.InfoLevel, s.GetStackTraceLevel() >= ErrorLevel, fmt.Sprint(args...), nil)
+	if s.shouldEmit(InfoLevel) {
+		s.emit(zapcore.InfoLevel, s.GetStackTraceLevel() >= InfoLevel, fmt.Sprint(args...), nil)
 	}
 }
 
 // Infof uses fmt.Sprintf to construct and log a message at info level.
 func (s *Scope) Infof(template string, args ...interface{}) {
-	if s.GetOutputLevel() >= InfoLevel {
+	if s.shouldEmit(InfoLevel) {
 		msg := template
 		if len(args) > 0 {
 			msg = fmt.Sprintf(template, args...)
 		}
-		s.emit(zapcore.InfoLevel, s.GetStackTraceLevel() >= ErrorLevel, msg, nil)
+		s.emit(zapcore.InfoLevel, s.GetStackTraceLevel() >= InfoLevel, msg, nil)
+	}
+}
+
+// Infow outputs a message at info level, along with structured fields made of alternating keys and values
+// which keep their type, such as ("count", 3, "elapsed", time.Second).
+func (s *Scope) Infow(msg string, keysAndValues ...interface{}) {
+	if s.shouldEmit(InfoLevel) {
+		s.emit(zapcore.InfoLevel, s.GetStackTraceLevel() >= InfoLevel, msg, keysAndValuesToFields(keysAndValues))
 	}
 }
 
@@ -221,26 +334,34 @@ func (s *Scope) InfoEnabled() bool {
 
 // Debug outputs a message at debug level.
 func (s *Scope) Debug(msg string, fields ...zapcore.Field) {
-	if s.GetOutputLevel() >= DebugLevel {
-		s.emit(zapcore.DebugLevel, s.GetStackTraceLevel() >= ErrorLevel, msg, fields)
+	if s.shouldEmit(DebugLevel) {
+		s.emit(zapcore.DebugLevel, s.GetStackTraceLevel() >= DebugLevel, msg, fields)
 	}
 }
 
 // Debuga uses fmt.Sprint to construct and log a message at debug level.
 func (s *Scope) Debuga(args ...interface{}) {
-	if s.GetOutputLevel() >= DebugLevel {
-		s.emit(zapcore.DebugLevel, s.GetStackTraceLevel() >= ErrorLevel, fmt.Sprint(args...), nil)
+	if s.shouldEmit(DebugLevel) {
+		s.emit(zapcore.DebugLevel, s.GetStackTraceLevel() >= DebugLevel, fmt.Sprint(args...), nil)
 	}
 }
 
 // Debugf uses fmt.Sprintf to construct and log a message at debug level.
 func (s *Scope) Debugf(template string, args ...interface{}) {
-	if s.GetOutputLevel() >= DebugLevel {
+	if s.shouldEmit(DebugLevel) {
 		msg := template
 		if len(args) > 0 {
 			msg = fmt.Sprintf(template, args...)
 		}
-		s.emit(zapcore.DebugLevel, s.GetStackTraceLevel() >= ErrorLevel, msg, nil)
+		s.emit(zapcore.DebugLevel, s.GetStackTraceLevel() >= DebugLevel, msg, nil)
+	}
+}
+
+// Debugw outputs a message at debug level, along with structured fields made of alternating keys and values
+// which keep their type, such as ("count", 3, "elapsed", time.Second).
+func (s *Scope) Debugw(msg string, keysAndValues ...interface{}) {
+	if s.shouldEmit(DebugLevel) {
+		s.emit(zapcore.DebugLevel, s.GetStackTraceLevel() >= DebugLevel, msg, keysAndValuesToFields(keysAndValues))
 	}
 }
 
@@ -249,6 +370,66 @@ func (s *Scope) DebugEnabled() bool {
 	return s.GetOutputLevel() >= DebugLevel
 }
 
+// WithLabels returns a scope which adds structured fields made of alternating keys and values to
+// all the messages it outputs, as accepted by the Xw methods. The returned scope shares the name
+// and settings of s, such that adjusting its output level adjusts the output level of s.
+func (s *Scope) WithLabels(keysAndValues ...interface{}) *Scope {
+	return s.derive(keysAndValuesToFields(keysAndValues), 0)
+}
+
+// withCallerSkip returns a scope deriving from s which skips extra more frames when reporting the
+// location of callers, for use by adapters calling the methods of s on behalf of their own callers.
+func (s *Scope) withCallerSkip(extra int) *Scope {
+	return s.derive(nil, extra)
+}
+
+// derive returns a scope sharing the settings of s, which adds fields to the ones of s and skips
+// extraSkip more frames when reporting the location of callers.
+func (s *Scope) derive(fields []zapcore.Field, extraSkip int) *Scope {
+	if len(fields) == 0 && extraSkip == 0 {
+		return s
+	}
+
+	derived := &Scope{
+		name:        s.name,
+		nameToEmit:  s.nameToEmit,
+		description: s.description,
+		callerSkip:  s.callerSkip + extraSkip,
+		root:        s.registered(),
+		forceDebug:  s.forceDebug,
+	}
+	derived.fields = make([]zapcore.Field, 0, len(s.fields)+len(fields))
+	derived.fields = append(derived.fields, s.fields...)
+	derived.fields = append(derived.fields, fields...)
+	return derived
+}
+
+// withForcedDebug returns a scope deriving from s which outputs messages of all levels, whatever the
+// output level of s.
+func (s *Scope) withForcedDebug() *Scope {
+	if s.forceDebug {
+		return s
+	}
+
+	return &Scope{
+		name:        s.name,
+		nameToEmit:  s.nameToEmit,
+		description: s.description,
+		callerSkip:  s.callerSkip,
+		root:        s.registered(),
+		fields:      s.fields,
+		forceDebug:  true,
+	}
+}
+
+// registered returns the registered scope s derives from, which holds the settings of s.
+func (s *Scope) registered() *Scope {
+	if s.root != nil {
+		return s.root
+	}
+	return s
+}
+
 // Name returns this scope's name.
 func (s *Scope) Name() string {
 	return s.name
@@ -259,25 +440,91 @@ func (s *Scope) Description() string {
 	return s.description
 }
 
-const callerSkipOffset = 2
+const callerSkipOffset = 3
+
+// shouldEmit returns whether messages of the given level are output, or retained by the ring buffer.
+func (s *Scope) shouldEmit(l Level) bool {
+	return s.GetOutputLevel() >= l || (s.GetRingLevel() >= l && funcs.Load().(patchTable).ring != nil)
+}
 
 func (s *Scope) emit(level zapcore.Level, dumpStack bool, msg string, fields []zapcore.Field) {
+	s.emitAt(level, dumpStack, msg, fields, 0)
+}
+
+// emitAt emits a message logged at pc, or by the caller of the scope if pc is 0.
+func (s *Scope) emitAt(level zapcore.Level, dumpStack bool, msg string, fields []zapcore.Field, pc uintptr) {
+	now := time.Now()
+	pt := funcs.Load().(patchTable)
+	if pt.ring != nil && zapToLevel(level) <= s.GetRingLevel() {
+		ringFields := fields
+		if len(s.fields) > 0 {
+			ringFields = append(s.fields[:len(s.fields):len(s.fields)], fields...)
+		}
+		pt.ring.add(zapcore.Entry{Message: msg, Level: level, Time: now, LoggerName: s.nameToEmit}, ringFields)
+	}
+	if s.GetOutputLevel() < zapToLevel(level) {
+		return
+	}
+
+	if !s.registered().sampler.Load().(*sampler).allow(level, msg, now) {
+		return
+	}
+
+	allowed, suppressed := s.registered().rateLimiter.Load().(*rateLimiter).allow(level, now)
+	if !allowed {
+		return
+	}
+
+	if suppressed > 0 {
+		s.count(pt, zapcore.WarnLevel)
+		s.write(pt, zapcore.Entry{
+			Message:    fmt.Sprintf("suppressed %d messages", suppressed),
+			Level:      zapcore.WarnLevel,
+			Time:       now,
+			LoggerName: s.nameToEmit,
+		}, []zapcore.Field{zap.Uint64("suppressed", suppressed)})
+	}
+
+	s.count(pt, level)
+	if len(s.fields) > 0 {
+		fields = append(s.fields[:len(s.fields):len(s.fields)], fields...)
+	}
+
 	e := zapcore.Entry{
 		Message:    msg,
 		Level:      level,
-		Time:       time.Now(),
+		Time:       now,
 		LoggerName: s.nameToEmit,
 	}
 
-	if s.GetLogCallers() {
-		e.Caller = zapcore.NewEntryCaller(runtime.Caller(s.callerSkip + callerSkipOffset))
+	if zapToLevel(level) <= s.GetCallerLevel() {
+		if pc != 0 {
+			f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+			e.Caller = zapcore.NewEntryCaller(pc, f.File, f.Line, true)
+		} else {
+			e.Caller = zapcore.NewEntryCaller(runtime.Caller(s.callerSkip + callerSkipOffset))
+		}
 	}
 
 	if dumpStack {
 		e.Stack = zap.Stack("").String
 	}
 
-	pt := funcs.Load().(patchTable)
+	s.write(pt, e, fields)
+}
+
+func (s *Scope) write(pt patchTable, e zapcore.Entry, fields []zapcore.Field) {
+	if o, _ := s.registered().output.Load().(*scopeOutput); o != nil {
+		if err := o.core.Write(e, fields); err != nil {
+			_, _ = fmt.Fprintf(pt.errorSink, "%v log write error: %v\n", time.Now(), err)
+			_ = pt.errorSink.Sync()
+		}
+		if e.Level == zapcore.FatalLevel {
+			pt.exitProcess(1)
+		}
+		return
+	}
+
 	if pt.write != nil {
 		if err := pt.write(e, fields); err != nil {
 			_, _ = fmt.Fprintf(pt.errorSink, "%v log write error: %v\n", time.Now(), err)
@@ -286,32 +533,101 @@ func (s *Scope) emit(level zapcore.Level, dumpStack bool, msg string, fields []z
 	}
 }
 
+// count counts a record output at the given level, when counting records is enabled.
+func (s *Scope) count(pt patchTable, level zapcore.Level) {
+	if pt.countRecords {
+		if c, ok := s.registered().records[level]; ok {
+			c.Increment()
+		}
+	}
+}
+
 // SetOutputLevel adjusts the output level associated with the scope.
 func (s *Scope) SetOutputLevel(l Level) {
-	s.outputLevel.Store(l)
+	s.registered().outputLevel.Store(l)
 }
 
-// GetOutputLevel returns the output level associated with the scope.
+// GetOutputLevel returns the output level associated with the scope, which is DebugLevel for the
+// scopes returned by FromContext for the contexts returned by NewDebugContext.
 func (s *Scope) GetOutputLevel() Level {
-	return s.outputLevel.Load().(Level)
+	if s.forceDebug {
+		return DebugLevel
+	}
+	return s.registered().outputLevel.Load().(Level)
 }
 
 // SetStackTraceLevel adjusts the stack tracing level associated with the scope.
 func (s *Scope) SetStackTraceLevel(l Level) {
-	s.stackTraceLevel.Store(l)
+	s.registered().stackTraceLevel.Store(l)
 }
 
 // GetStackTraceLevel returns the stack tracing level associated with the scope.
 func (s *Scope) GetStackTraceLevel() Level {
-	return s.stackTraceLevel.Load().(Level)
+	return s.registered().stackTraceLevel.Load().(Level)
 }
 
-// SetLogCallers adjusts the output level associated with the scope.
+// SetLogCallers adjusts whether the scope outputs the caller's source code location of its messages,
+// whatever their level.
 func (s *Scope) SetLogCallers(logCallers bool) {
-	s.logCallers.Store(logCallers)
+	if logCallers {
+		s.SetCallerLevel(DebugLevel)
+	} else {
+		s.SetCallerLevel(NoneLevel)
+	}
 }
 
-// GetLogCallers returns the output level associated with the scope.
+// GetLogCallers returns whether the scope outputs the caller's source code location of any of its
+// messages.
 func (s *Scope) GetLogCallers() bool {
-	return s.logCallers.Load().(bool)
+	return s.GetCallerLevel() != NoneLevel
+}
+
+// SetCallerLevel adjusts the minimum level of the messages for which the scope outputs the caller's
+// source code location. NoneLevel disables caller locations.
+func (s *Scope) SetCallerLevel(l Level) {
+	s.registered().callerLevel.Store(l)
+}
+
+// GetCallerLevel returns the caller location level associated with the scope.
+func (s *Scope) GetCallerLevel() Level {
+	return s.registered().callerLevel.Load().(Level)
+}
+
+// SetRingLevel adjusts the minimum level of the messages of the scope retained by the ring buffer, see
+// Options.RingBufferSize, whatever the output level of the scope. NoneLevel retains no messages.
+func (s *Scope) SetRingLevel(l Level) {
+	s.registered().ringLevel.Store(l)
+}
+
+// GetRingLevel returns the ring buffer retention level associated with the scope.
+func (s *Scope) GetRingLevel() Level {
+	return s.registered().ringLevel.Load().(Level)
+}
+
+// SetSampling adjusts the sampling of the messages output through the scope. The zero SamplingConfig
+// disables sampling.
+func (s *Scope) SetSampling(config SamplingConfig) {
+	s.registered().sampler.Store(newSampler(config))
+}
+
+// GetSampling returns the sampling configuration associated with the scope.
+func (s *Scope) GetSampling() SamplingConfig {
+	if sm := s.registered().sampler.Load().(*sampler); sm != nil {
+		return sm.config
+	}
+	return SamplingConfig{}
+}
+
+// SetRateLimit adjusts the rate limit of the messages output through the scope. The zero RateLimit
+// disables rate limiting.
+func (s *Scope) SetRateLimit(limit RateLimit) {
+	s.registered().rateLimiter.Store(newRateLimiter(limit))
+}
+
+// GetRateLimit returns the rate limit associated with the scope.
+func (s *Scope) GetRateLimit() RateLimit {
+	if rl := s.registered().rateLimiter.Load().(*rateLimiter); rl != nil {
+		return rl.limit
+	}
+	return RateLimit{}
 }
@@ -18,6 +18,7 @@ import (
 	"errors"
 	"regexp"
 	"strconv"
+	"strings"
 	"testing"
 
 	"go.uber.org/zap/zapcore"
@@ -50,6 +51,10 @@ func TestBasicScopes(t *testing.T) {
 			f:   func() { s.Debuga("Hello") },
 			pat: timePattern + "\tdebug\ttestScope\tHello",
 		},
+		{
+			f:   func() { s.Debugw("Hello", "count", 3) },
+			pat: timePattern + "\tdebug\ttestScope\tHello\t\\{\"count\": 3\\}",
+		},
 
 		{
 			f:   func() { s.Info("Hello") },
@@ -67,6 +72,10 @@ func TestBasicScopes(t *testing.T) {
 			f:   func() { s.Infoa("Hello") },
 			pat: timePattern + "\tinfo\ttestScope\tHello",
 		},
+		{
+			f:   func() { s.Infow("Hello", "count", 3) },
+			pat: timePattern + "\tinfo\ttestScope\tHello\t\\{\"count\": 3\\}",
+		},
 
 		{
 			f:   func() { s.Warn("Hello") },
@@ -84,6 +93,10 @@ func TestBasicScopes(t *testing.T) {
 			f:   func() { s.Warna("Hello") },
 			pat: timePattern + "\twarn\ttestScope\tHello",
 		},
+		{
+			f:   func() { s.Warnw("Hello", "count", 3) },
+			pat: timePattern + "\twarn\ttestScope\tHello\t\\{\"count\": 3\\}",
+		},
 
 		{
 			f:   func() { s.Error("Hello") },
@@ -101,6 +114,10 @@ func TestBasicScopes(t *testing.T) {
 			f:   func() { s.Errora("Hello") },
 			pat: timePattern + "\terror\ttestScope\tHello",
 		},
+		{
+			f:   func() { s.Errorw("Hello", "count", 3) },
+			pat: timePattern + "\terror\ttestScope\tHello\t\\{\"count\": 3\\}",
+		},
 
 		{
 			f:        func() { s.Fatal("Hello") },
@@ -122,6 +139,11 @@ func TestBasicScopes(t *testing.T) {
 			pat:      timePattern + "\tfatal\ttestScope\tHello",
 			wantExit: true,
 		},
+		{
+			f:        func() { s.Fatalw("Hello", "count", 3) },
+			pat:      timePattern + "\tfatal\ttestScope\tHello\t\\{\"count\": 3\\}",
+			wantExit: true,
+		},
 
 		{
 			f:      func() { s.Debug("Hello") },
@@ -218,6 +240,62 @@ func TestBasicScopes(t *testing.T) {
 	}
 }
 
+func TestScopeThresholds(t *testing.T) {
+	s := RegisterScope("thresholds", "z", 0)
+
+	o := testOptions()
+	o.JSONEncoding = true
+	o.SetOutputLevel("thresholds", DebugLevel)
+	o.SetStackTraceLevel("thresholds", ErrorLevel)
+	o.SetCallerLevel("thresholds", WarnLevel)
+
+	lines, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Errorf("Got err '%v', expecting success", err)
+		}
+
+		s.Debug("Hello")
+		s.Infow("Hello", "count", 3)
+		s.Warnw("Hello", "count", 3)
+		s.Errorw("Hello", "count", 3)
+		_ = Sync()
+	})
+	if err != nil {
+		t.Errorf("Got error '%v', expected success", err)
+	}
+
+	cases := []struct {
+		caller bool
+		stack  bool
+	}{
+		{false, false},
+		{false, false},
+		{true, false},
+		{true, true},
+	}
+
+	if len(lines) < len(cases) {
+		t.Fatalf("Got %v, expecting %d lines", lines, len(cases))
+	}
+	for i, c := range cases {
+		if got := strings.Contains(lines[i], `"caller":`); got != c.caller {
+			t.Errorf("Got caller %v in '%s', expecting %v", got, lines[i], c.caller)
+		}
+		if got := strings.Contains(lines[i], `"stack":`); got != c.stack {
+			t.Errorf("Got stack %v in '%s', expecting %v", got, lines[i], c.stack)
+		}
+	}
+
+	if !s.GetLogCallers() || s.GetCallerLevel() != WarnLevel {
+		t.Errorf("Got %v, %v, expecting callers up to WarnLevel", s.GetLogCallers(), s.GetCallerLevel())
+	}
+	s.SetLogCallers(false)
+	if s.GetCallerLevel() != NoneLevel {
+		t.Errorf("Got %v, expecting NoneLevel", s.GetCallerLevel())
+	}
+	_ = Configure(DefaultOptions())
+}
+
 func TestScopeEnabled(t *testing.T) {
 	const name = "TestEnabled"
 	const desc = "Desc"
@@ -346,3 +424,51 @@ func TestBadWriter(t *testing.T) {
 	// inspect it, but it's just not worth it
 	defaultScope.Error("TestBadWriter")
 }
+
+func TestSetLevelForPattern(t *testing.T) {
+	resetGlobals()
+	ads := RegisterScope("ads", "", 0)
+	adsDebug := RegisterScope("adsdebug", "", 0)
+	other := RegisterScope("validation", "", 0)
+
+	if err := SetLevelForPattern("ads*", DebugLevel); err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	if ads.GetOutputLevel() != DebugLevel || adsDebug.GetOutputLevel() != DebugLevel {
+		t.Errorf("Got %v and %v, expecting the ads scopes to be at debug level", ads.GetOutputLevel(), adsDebug.GetOutputLevel())
+	}
+	if other.GetOutputLevel() != InfoLevel {
+		t.Errorf("Got %v, expecting the validation scope to be left alone", other.GetOutputLevel())
+	}
+
+	if err := SetLevelForPattern("[ads", DebugLevel); err == nil {
+		t.Error("Got success, expecting a malformed pattern to fail")
+	}
+}
+
+func TestSetLevels(t *testing.T) {
+	resetGlobals()
+	ads := RegisterScope("ads", "", 0)
+	adsDebug := RegisterScope("adsdebug", "", 0)
+	other := RegisterScope("validation", "", 0)
+
+	err := SetLevels(map[string]Level{
+		"ads?*":      WarnLevel,
+		"validation": ErrorLevel,
+		"pizza":      DebugLevel,
+	})
+	if err == nil || !strings.Contains(err.Error(), "pizza") {
+		t.Errorf("Got %v, expecting an error about the unknown scope", err)
+	}
+	if ads.GetOutputLevel() != InfoLevel || adsDebug.GetOutputLevel() != WarnLevel || other.GetOutputLevel() != ErrorLevel {
+		t.Errorf("Got %v, %v and %v, expecting info, warn and error",
+			ads.GetOutputLevel(), adsDebug.GetOutputLevel(), other.GetOutputLevel())
+	}
+
+	if err := SetLevels(map[string]Level{"ads": DebugLevel, "valid*": DebugLevel}); err != nil {
+		t.Errorf("Got %v, expecting success", err)
+	}
+	if ads.GetOutputLevel() != DebugLevel || other.GetOutputLevel() != DebugLevel {
+		t.Errorf("Got %v and %v, expecting debug", ads.GetOutputLevel(), other.GetOutputLevel())
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// The formats of the security event log, see Options.SIEMFormat.
+const (
+	CEFFormat  = "cef"
+	LEEFFormat = "leef"
+)
+
+const siemVendor = "Istio"
+
+// the severities of CEF and LEEF, from 0 to 10
+var siemSeverities = map[zapcore.Level]int{
+	zapcore.DebugLevel:  1,
+	zapcore.InfoLevel:   3,
+	zapcore.WarnLevel:   5,
+	zapcore.ErrorLevel:  8,
+	zapcore.DPanicLevel: 9,
+	zapcore.PanicLevel:  9,
+	zapcore.FatalLevel:  10,
+}
+
+var siemPool = buffer.NewPool()
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	leefHeaderEscaper   = strings.NewReplacer(`|`, `\|`, "\n", " ", "\r", " ", "\t", " ")
+	leefValueEscaper    = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+)
+
+// siemEncoder formats log records as the Common Event Format lines of ArcSight, or the Log Event
+// Extended Format lines of QRadar. The scope of records is their event class, their message is
+// their name, and their fields are output as extension fields.
+type siemEncoder struct {
+	*zapcore.MapObjectEncoder
+	leef    bool
+	product string
+	version string
+}
+
+func newSIEMEncoder(options *Options) (zapcore.Encoder, error) {
+	e := &siemEncoder{
+		MapObjectEncoder: zapcore.NewMapObjectEncoder(),
+		product:          options.SIEMProduct,
+		version:          options.SIEMProductVersion,
+	}
+
+	switch options.SIEMFormat {
+	case "", CEFFormat:
+	case LEEFFormat:
+		e.leef = true
+	default:
+		return nil, fmt.Errorf("unknown security event format '%s'", options.SIEMFormat)
+	}
+
+	if e.product == "" {
+		e.product = filepath.Base(os.Args[0])
+	}
+
+	return e, nil
+}
+
+func (e *siemEncoder) Clone() zapcore.Encoder {
+	clone := *e
+	clone.MapObjectEncoder = zapcore.NewMapObjectEncoder()
+	for k, v := range e.Fields {
+		clone.Fields[k] = v
+	}
+	return &clone
+}
+
+func (e *siemEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	all := e.Clone().(*siemEncoder)
+	for _, f := range fields {
+		f.AddTo(all)
+	}
+
+	scope := ent.LoggerName
+	if scope == "" {
+		scope = DefaultScopeName
+	}
+
+	buf := siemPool.Get()
+	if e.leef {
+		e.encodeLEEF(buf, scope, ent, all.Fields)
+	} else {
+		e.encodeCEF(buf, scope, ent, all.Fields)
+	}
+	buf.AppendByte('\n')
+	return buf, nil
+}
+
+// encodeCEF appends a line of the form
+//
+//	CEF:0|Istio|<product>|<version>|<scope>|<message>|<severity>|rt=<millis> key=value ...
+func (e *siemEncoder) encodeCEF(buf *buffer.Buffer, scope string, ent zapcore.Entry, fields map[string]interface{}) {
+	buf.AppendString("CEF:0")
+	for _, h := range []string{siemVendor, e.product, e.version, scope, ent.Message} {
+		buf.AppendByte('|')
+		buf.AppendString(cefHeaderEscaper.Replace(h))
+	}
+	buf.AppendByte('|')
+	buf.AppendInt(int64(siemSeverities[ent.Level]))
+	buf.AppendString("|rt=")
+	buf.AppendInt(ent.Time.UnixNano() / 1e6)
+
+	for _, k := range sortedKeys(fields) {
+		buf.AppendByte(' ')
+		buf.AppendString(siemKey(k))
+		buf.AppendByte('=')
+		buf.AppendString(cefExtensionEscaper.Replace(siemValue(fields[k])))
+	}
+}
+
+// encodeLEEF appends a line of the form, with the attributes separated by tabs,
+//
+//	LEEF:1.0|Istio|<product>|<version>|<scope>|cat=<scope>	devTime=<millis>	sev=<severity>	msg=<message>	key=value ...
+func (e *siemEncoder) encodeLEEF(buf *buffer.Buffer, scope string, ent zapcore.Entry, fields map[string]interface{}) {
+	buf.AppendString("LEEF:1.0")
+	for _, h := range []string{siemVendor, e.product, e.version, scope} {
+		buf.AppendByte('|')
+		buf.AppendString(leefHeaderEscaper.Replace(h))
+	}
+	buf.AppendString("|cat=")
+	buf.AppendString(leefValueEscaper.Replace(scope))
+	buf.AppendString("\tdevTime=")
+	buf.AppendInt(ent.Time.UnixNano() / 1e6)
+	buf.AppendString("\tsev=")
+	buf.AppendInt(int64(siemSeverities[ent.Level]))
+	buf.AppendString("\tmsg=")
+	buf.AppendString(leefValueEscaper.Replace(ent.Message))
+
+	for _, k := range sortedKeys(fields) {
+		buf.AppendByte('\t')
+		buf.AppendString(siemKey(k))
+		buf.AppendByte('=')
+		buf.AppendString(leefValueEscaper.Replace(siemValue(fields[k])))
+	}
+}
+
+func sortedKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// siemKey replaces the characters which can't appear in extension keys.
+func siemKey(k string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, k)
+}
+
+// siemValue formats scalar values as text, and structured values as JSON.
+func siemValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+// scopeFilterCore only writes the records of the given scopes to the wrapped core.
+type scopeFilterCore struct {
+	zapcore.Core
+	scopes map[string]bool
+}
+
+func newScopeFilterCore(core zapcore.Core, scopes []string) zapcore.Core {
+	c := scopeFilterCore{
+		Core:   core,
+		scopes: make(map[string]bool, len(scopes)),
+	}
+	for _, s := range scopes {
+		c.scopes[s] = true
+	}
+	return c
+}
+
+func (c scopeFilterCore) With(fields []zapcore.Field) zapcore.Core {
+	return scopeFilterCore{Core: c.Core.With(fields), scopes: c.scopes}
+}
+
+func (c scopeFilterCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.kept(ent) && c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c scopeFilterCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.kept(ent) {
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
+
+func (c scopeFilterCore) kept(ent zapcore.Entry) bool {
+	scope := ent.LoggerName
+	if scope == "" {
+		scope = DefaultScopeName
+	}
+	return c.scopes[scope]
+}
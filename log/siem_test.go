@@ -0,0 +1,89 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSIEM(t *testing.T) {
+	authz := RegisterScope("siemauthz", "For testing", 0)
+	other := RegisterScope("siemother", "For testing", 0)
+
+	cases := []struct {
+		format string
+		pat    string
+	}{
+		{
+			format: CEFFormat,
+			pat: `^CEF:0\|Istio\|pilot\|1\.4\|siemauthz\|denied a\\\|b\|5\|rt=\d+ ` +
+				`peer.ip=10\.0\.0\.1 policy=a\\=b\\\\c principal=spiffe://cluster\.local/ns/default/sa/a retries=3$`,
+		},
+		{
+			format: LEEFFormat,
+			pat: "^LEEF:1\\.0\\|Istio\\|pilot\\|1\\.4\\|siemauthz\\|cat=siemauthz\tdevTime=\\d+\tsev=5\tmsg=denied a\\|b\t" +
+				"peer.ip=10\\.0\\.0\\.1\tpolicy=a=b\\\\c\tprincipal=spiffe://cluster\\.local/ns/default/sa/a\tretries=3$",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.format, func(t *testing.T) {
+			dir, _ := ioutil.TempDir("", "TestSIEM")
+			defer os.RemoveAll(dir)
+			file := filepath.Join(dir, "siem.log")
+
+			o := testOptions()
+			o.LogGrpc = false
+			o.SIEMOutputPath = file
+			o.SIEMFormat = c.format
+			o.SIEMScopes = []string{"siemauthz"}
+			o.SIEMProduct = "pilot"
+			o.SIEMProductVersion = "1.4"
+
+			_, _ = captureStdout(func() {
+				if err := Configure(o); err != nil {
+					t.Fatalf("Got %v, expecting success", err)
+				}
+
+				authz.WithLabels("principal", "spiffe://cluster.local/ns/default/sa/a").
+					Warnw("denied a|b", "policy", `a=b\c`, "retries", 3, "peer ip", "10.0.0.1")
+				other.Warn("not a security event")
+				_ = Configure(DefaultOptions())
+			})
+
+			content, _ := ioutil.ReadFile(file)
+			lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("Got %v, expecting a single security event", lines)
+			}
+			if match, _ := regexp.MatchString(c.pat, lines[0]); !match {
+				t.Errorf("Got '%s', expecting a match with '%s'", lines[0], c.pat)
+			}
+		})
+	}
+
+	o := testOptions()
+	o.SIEMOutputPath = "stdout"
+	o.SIEMFormat = "syslog"
+	if err := Configure(o); err == nil {
+		t.Error("Got success, expecting an error for an unknown format")
+	}
+	_ = Configure(DefaultOptions())
+}
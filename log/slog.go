@@ -0,0 +1,223 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+
+package log
+
+import (
+	"context"
+	"log/slog"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// slogHandler adapts a scope to the slog.Handler interface, see SlogHandler.
+type slogHandler struct {
+	scope  *Scope
+	prefix string // the groups opened by WithGroup, joined with periods
+}
+
+// SlogHandler returns a log/slog handler outputting the records of the libraries using log/slog through
+// scope, such that they're subject to the same levels and outputs as the rest of the process:
+//
+//	slog.SetDefault(slog.New(log.SlogHandler(log.RegisterScope("lib", "Logs of lib", 0))))
+//
+// Debug and less severe records are output at the debug level, and records more severe than the
+// error level at the error level. Groups are output as fields whose names are prefixed with the names
+// of the groups joined with periods. The context trace and span are output as for FromContext, and
+// records are output whatever their level for the contexts returned by NewDebugContext.
+func SlogHandler(scope *Scope) slog.Handler {
+	return &slogHandler{scope: scope}
+}
+
+func slogToLevel(l slog.Level) Level {
+	switch {
+	case l <= slog.LevelDebug:
+		return DebugLevel
+	case l < slog.LevelWarn:
+		return InfoLevel
+	case l < slog.LevelError:
+		return WarnLevel
+	}
+	return ErrorLevel
+}
+
+func (h *slogHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return isDebugContext(ctx) || h.scope.shouldEmit(slogToLevel(l))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	s := h.scope
+	if isDebugContext(ctx) {
+		s = s.withForcedDebug()
+	}
+
+	level := slogToLevel(r.Level)
+	if !s.shouldEmit(level) {
+		return nil
+	}
+
+	fields := make([]zapcore.Field, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendSlogAttr(fields, h.prefix, a)
+		return true
+	})
+
+	if labels := traceLabels(ctx); labels != nil {
+		s = s.WithLabels(labels...)
+	}
+	s.emitAt(levelToZap[level], s.GetStackTraceLevel() >= level, r.Message, fields, r.PC)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]zapcore.Field, 0, len(attrs))
+	for _, a := range attrs {
+		fields = appendSlogAttr(fields, h.prefix, a)
+	}
+	return &slogHandler{scope: h.scope.derive(fields, 0), prefix: h.prefix}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{scope: h.scope, prefix: h.prefix + name + "."}
+}
+
+// appendSlogAttr appends an attribute as a field, or the attributes of a group as fields.
+func appendSlogAttr(fields []zapcore.Field, prefix string, a slog.Attr) []zapcore.Field {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			fields = appendSlogAttr(fields, prefix, ga)
+		}
+		return fields
+	}
+
+	if a.Key == "" {
+		return fields
+	}
+
+	key := prefix + a.Key
+	switch v.Kind() {
+	case slog.KindString:
+		return append(fields, zap.String(key, v.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(key, v.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(key, v.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(key, v.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(key, v.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(key, v.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(key, v.Time()))
+	}
+	return append(fields, zap.Any(key, v.Any()))
+}
+
+// SetSlogHandler routes the records of the scope to h rather than to the log outputs, such that they
+// follow the logging setup of a program built around log/slog. The records are still subject to the
+// output level of the scope, hooks and redaction, and carry the name of the scope as a scope
+// attribute. Fatal records still exit the process once handled. A nil handler routes the records
+// back to the log outputs.
+func (s *Scope) SetSlogHandler(h slog.Handler) {
+	if h == nil {
+		s.registered().output.Store((*scopeOutput)(nil))
+		return
+	}
+
+	c := &slogCore{handler: h, scope: s.Name()}
+	s.registered().output.Store(&scopeOutput{core: hookingCore{redactingCore{c}}})
+}
+
+// slogCore writes log records to a slog handler.
+type slogCore struct {
+	handler slog.Handler
+	scope   string
+	attrs   []slog.Attr
+}
+
+func (c *slogCore) Enabled(l zapcore.Level) bool {
+	return c.handler.Enabled(context.Background(), zapToSlog(l))
+}
+
+func (c *slogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.attrs = append(c.attrs[:len(c.attrs):len(c.attrs)], fieldsToSlog(fields)...)
+	return &clone
+}
+
+func (c *slogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *slogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	level := zapToSlog(ent.Level)
+	if !c.handler.Enabled(context.Background(), level) {
+		return nil
+	}
+
+	var pc uintptr
+	if ent.Caller.Defined {
+		pc = ent.Caller.PC
+	}
+	r := slog.NewRecord(ent.Time, level, ent.Message, pc)
+	r.AddAttrs(slog.String("scope", c.scope))
+	r.AddAttrs(c.attrs...)
+	r.AddAttrs(fieldsToSlog(fields)...)
+	return c.handler.Handle(context.Background(), r)
+}
+
+func (c *slogCore) Sync() error {
+	return nil
+}
+
+func fieldsToSlog(fields []zapcore.Field) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		for k, v := range enc.Fields {
+			attrs = append(attrs, slog.Any(k, v))
+		}
+	}
+	return attrs
+}
+
+func zapToSlog(l zapcore.Level) slog.Level {
+	switch l {
+	case zapcore.DebugLevel:
+		return slog.LevelDebug
+	case zapcore.InfoLevel:
+		return slog.LevelInfo
+	case zapcore.WarnLevel:
+		return slog.LevelWarn
+	case zapcore.ErrorLevel:
+		return slog.LevelError
+	}
+	// slog has no level beyond error, so fatal records are made more severe than errors
+	return slog.LevelError + 4
+}
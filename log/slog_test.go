@@ -0,0 +1,103 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"regexp"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	s := RegisterScope("slogscope", "For testing", 0)
+
+	o := testOptions()
+	o.JSONEncoding = true
+
+	lines, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+		s.SetLogCallers(true)
+		defer s.SetLogCallers(false)
+
+		l := slog.New(SlogHandler(s)).With("component", "lib").WithGroup("req")
+		l.Debug("not output")
+		l.Info("handled", "count", 3, slog.Group("peer", "ip", "10.0.0.1"))
+		l.Error("failed", "error", errors.New("boom"))
+		_ = Sync()
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+
+	expected := []string{
+		`{"level":"info","time":".*","scope":"slogscope","caller":"log/slog_test.go:\d+","msg":"handled",` +
+			`"component":"lib","req.count":3,"req.peer.ip":"10.0.0.1"}`,
+		`{"level":"error","time":".*","scope":"slogscope","caller":"log/slog_test.go:\d+","msg":"failed",` +
+			`"component":"lib","req.error":"boom"}`,
+	}
+	if len(lines) < len(expected) {
+		t.Fatalf("Got %v, expecting %d lines", lines, len(expected))
+	}
+	for i, pat := range expected {
+		if match, _ := regexp.MatchString(pat, lines[i]); !match {
+			t.Errorf("Got '%s', expecting a match with '%s'", lines[i], pat)
+		}
+	}
+}
+
+func TestSetSlogHandler(t *testing.T) {
+	s := RegisterScope("slogrouted", "For testing", 0)
+	ClearRedactions()
+	RedactFields("token")
+	defer ClearRedactions()
+
+	var buf bytes.Buffer
+	s.SetSlogHandler(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	lines, err := captureStdout(func() {
+		if err := Configure(testOptions()); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+
+		s.Info("not handled")
+		s.WithLabels("request", 7).Warnw("routed", "token", "secret")
+		s.SetSlogHandler(nil)
+		s.Info("output")
+		_ = Sync()
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("Got %v parsing '%s', expecting a single record", err, buf.String())
+	}
+	if m["msg"] != "routed" || m["level"] != "WARN" || m["scope"] != "slogrouted" ||
+		m["request"] != float64(7) || m["token"] != Redacted {
+		t.Errorf("Got %v, expecting the redacted warning", m)
+	}
+
+	if len(lines) < 1 || !regexp.MustCompile(`\toutput$`).MatchString(lines[0]) {
+		t.Errorf("Got %v, expecting the records to be output once routed back", lines)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Dict constructs a field holding a nested object made of the given fields, which keep their types
+// in the JSON output.
+//
+//	log.Info("Pushed config", log.Dict("proxy", zap.String("id", id), zap.Int("version", v)))
+func Dict(key string, fields ...zapcore.Field) zapcore.Field {
+	return zap.Object(key, zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		for _, f := range fields {
+			f.AddTo(enc)
+		}
+		return nil
+	}))
+}
+
+// keysAndValuesToFields converts the alternating keys and values passed to the Xw logging methods
+// into fields. Values keep their type: numbers, bools, durations and errors are encoded natively,
+// while maps, slices and structs are encoded as nested JSON objects and arrays. Fields can also be
+// passed as is in place of a key and its value. Keys which aren't strings are formatted with
+// fmt.Sprint, and a trailing key without a value gets a null value.
+func keysAndValuesToFields(keysAndValues []interface{}) []zapcore.Field {
+	if len(keysAndValues) == 0 {
+		return nil
+	}
+
+	fields := make([]zapcore.Field, 0, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i++ {
+		if f, ok := keysAndValues[i].(zapcore.Field); ok {
+			fields = append(fields, f)
+			continue
+		}
+
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+
+		var value interface{}
+		if i+1 < len(keysAndValues) {
+			i++
+			value = keysAndValues[i]
+		}
+
+		fields = append(fields, zap.Any(key, value))
+	}
+
+	return fields
+}
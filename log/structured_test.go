@@ -0,0 +1,77 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestStructuredFields(t *testing.T) {
+	resetGlobals()
+
+	o := DefaultOptions()
+	o.JSONEncoding = true
+
+	lines, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+		Infow("Hello",
+			"count", 3,
+			"enabled", true,
+			"elapsed", 1500*time.Millisecond,
+			"error", errors.New("boom"),
+			"tags", []string{"a", "b"},
+			"peer", map[string]interface{}{"id": "sidecar~1", "port": 15001},
+			zap.String("field", "as is"),
+			Dict("proxy", zap.String("id", "router~2"), zap.Int("version", 7)),
+			42, "non-string key",
+			"dangling")
+		_ = Sync()
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	_ = Configure(DefaultOptions())
+
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+		t.Fatalf("Got %v, expecting %q to be JSON", err, lines[0])
+	}
+
+	expected := map[string]interface{}{
+		"count":    float64(3),
+		"enabled":  true,
+		"elapsed":  "1.5s",
+		"error":    "boom",
+		"tags":     []interface{}{"a", "b"},
+		"peer":     map[string]interface{}{"id": "sidecar~1", "port": float64(15001)},
+		"field":    "as is",
+		"proxy":    map[string]interface{}{"id": "router~2", "version": float64(7)},
+		"42":       "non-string key",
+		"dangling": nil,
+	}
+	for k, v := range expected {
+		if got, ok := m[k]; !ok || !reflect.DeepEqual(got, v) {
+			t.Errorf("Got %s=%#v, expecting %#v", k, got, v)
+		}
+	}
+}
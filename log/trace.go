@@ -0,0 +1,75 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"go.opencensus.io/trace"
+)
+
+// The fields holding the trace and span identifiers of log records.
+const (
+	traceIDKey = "trace_id"
+	spanIDKey  = "span_id"
+)
+
+// TraceExtractor returns the identifiers of the trace and span carried by a context, in their hex
+// representation, or false if the context doesn't carry any span of the tracing library it supports.
+type TraceExtractor func(ctx context.Context) (traceID string, spanID string, ok bool)
+
+var (
+	traceExtractors   atomic.Value // []TraceExtractor
+	traceExtractorsMu sync.Mutex
+)
+
+func init() {
+	traceExtractors.Store([]TraceExtractor{openCensusSpan})
+}
+
+// RegisterTraceExtractor adds support for the spans of a tracing library, such as OpenTelemetry, to
+// FromContext. OpenCensus spans are supported out of the box. Extractors are tried in the order they
+// were registered, after the OpenCensus one.
+func RegisterTraceExtractor(extractor TraceExtractor) {
+	traceExtractorsMu.Lock()
+	defer traceExtractorsMu.Unlock()
+
+	old := traceExtractors.Load().([]TraceExtractor)
+	extractors := make([]TraceExtractor, 0, len(old)+1)
+	extractors = append(extractors, old...)
+	traceExtractors.Store(append(extractors, extractor))
+}
+
+func openCensusSpan(ctx context.Context) (string, string, bool) {
+	span := trace.FromContext(ctx)
+	if span == nil {
+		return "", "", false
+	}
+
+	sc := span.SpanContext()
+	return sc.TraceID.String(), sc.SpanID.String(), true
+}
+
+// traceLabels returns the trace and span fields of the span carried by ctx, if any.
+func traceLabels(ctx context.Context) []interface{} {
+	for _, extract := range traceExtractors.Load().([]TraceExtractor) {
+		if traceID, spanID, ok := extract(ctx); ok {
+			return []interface{}{traceIDKey, traceID, spanIDKey, spanID}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+type otherSpanKey struct{}
+
+func TestTraceInjection(t *testing.T) {
+	resetGlobals()
+
+	old := traceExtractors.Load()
+	defer traceExtractors.Store(old)
+	RegisterTraceExtractor(func(ctx context.Context) (string, string, bool) {
+		if id, ok := ctx.Value(otherSpanKey{}).(string); ok {
+			return "trace-" + id, "span-" + id, true
+		}
+		return "", "", false
+	})
+
+	o := DefaultOptions()
+	o.JSONEncoding = true
+
+	var root, child trace.SpanContext
+	lines, err := captureStdout(func() {
+		if err := Configure(o); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+
+		ctx, span := trace.StartSpan(context.Background(), "root")
+		root = span.SpanContext()
+		ctx = NewContext(ctx, nil, "request", "r1")
+		FromContext(ctx).Info("root")
+
+		ctx, span = trace.StartSpan(ctx, "child")
+		child = span.SpanContext()
+		FromContext(ctx).Info("child")
+
+		FromContext(context.WithValue(context.Background(), otherSpanKey{}, "1")).Info("other")
+		FromContext(context.Background()).Info("none")
+		_ = Sync()
+	})
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	_ = Configure(DefaultOptions())
+
+	expected := []map[string]interface{}{
+		{"msg": "root", "request": "r1", "trace_id": root.TraceID.String(), "span_id": root.SpanID.String()},
+		{"msg": "child", "request": "r1", "trace_id": child.TraceID.String(), "span_id": child.SpanID.String()},
+		{"msg": "other", "trace_id": "trace-1", "span_id": "span-1"},
+		{"msg": "none", "trace_id": nil, "span_id": nil},
+	}
+	for i, exp := range expected {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &m); err != nil {
+			t.Fatalf("Got %v, expecting %q to be JSON", err, lines[i])
+		}
+		for k, v := range exp {
+			if m[k] != v {
+				t.Errorf("Got %s=%v in line %d, expecting %v", k, m[k], i, v)
+			}
+		}
+	}
+	if root.TraceID != child.TraceID {
+		t.Error("Expecting the child span to belong to the trace of the root span")
+	}
+}
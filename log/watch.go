@@ -0,0 +1,244 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ghodss/yaml"
+
+	"istio.io/pkg/filewatcher"
+)
+
+// the options last passed to Configure
+var configuredOptions atomic.Value // *Options
+
+// can be replaced by tests
+var newFileWatcher filewatcher.NewFileWatcherFunc = filewatcher.NewWatcher
+
+// fileConfig is the format of the configuration files watched by WatchConfig.
+type fileConfig struct {
+	OutputLevels     map[string]string         `json:"outputLevels,omitempty"`
+	StackTraceLevels map[string]string         `json:"stackTraceLevels,omitempty"`
+	LogCallers       []string                  `json:"logCallers,omitempty"`
+	CallerLevels     map[string]string         `json:"callerLevels,omitempty"`
+	Sampling         map[string]SamplingConfig `json:"sampling,omitempty"`
+	RateLimits       map[string]RateLimit      `json:"rateLimits,omitempty"`
+	OutputPaths      []string                  `json:"outputPaths,omitempty"`
+	RotateOutputPath *string                   `json:"rotateOutputPath,omitempty"`
+	TargetLevels     map[string]string         `json:"targetLevels,omitempty"`
+	JSONEncoding     *bool                     `json:"jsonEncoding,omitempty"`
+}
+
+// WatchConfig applies the logging configuration held by a YAML or JSON file, and then watches the file
+// to apply its changes live, which lets operators adjust verbosity without restarting components.
+// The configuration applies on top of the options last passed to Configure, and holds any of:
+//
+//	outputLevels:           # per-scope output levels, keys may be patterns as for SetLevelForPattern
+//	  default: info
+//	  ads: debug
+//	stackTraceLevels:       # per-scope stack trace levels
+//	  ads: error
+//	logCallers: [ads]       # scopes outputting their callers
+//	callerLevels:           # per-scope levels from which callers are output
+//	  validation: debug
+//	sampling:               # per-scope sampling, see SamplingConfig
+//	  ads: {initial: 10, thereafter: 100}
+//	rateLimits:             # per-scope rate limits, see RateLimit
+//	  ads: {perSecond: 50, burst: 100}
+//	outputPaths: [stdout, /var/log/istio.log]
+//	rotateOutputPath: /var/log/istio-rotated.log
+//	targetLevels:           # see Options.SetTargetLevel
+//	  stdout: warn
+//	jsonEncoding: true
+//
+// The scopes no longer listed by the file once it changes return to the settings of the options. This
+// returns an error if the file can't be read or holds an invalid configuration. Later changes which
+// can't be applied are reported through the default scope, and leave the logging configuration
+// unchanged. The returned function stops watching the file, waiting for any change being applied.
+func WatchConfig(file string) (func(), error) {
+	w := &configWatcher{path: file}
+	if err := w.apply(); err != nil {
+		return nil, err
+	}
+
+	fw := newFileWatcher()
+	if err := fw.Add(file); err != nil {
+		_ = fw.Close()
+		return nil, fmt.Errorf("unable to watch logging configuration %s: %v", file, err)
+	}
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	var stop sync.Once
+	events, errs := fw.Events(file), fw.Errors(file)
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+				if err := w.apply(); err != nil {
+					Errorf("%v", err)
+				}
+			case err, ok := <-errs:
+				if !ok {
+					return
+				}
+				Errorf("unable to watch logging configuration %s: %v", file, err)
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		stop.Do(func() {
+			close(stopCh)
+			<-done
+			_ = fw.Close()
+		})
+	}, nil
+}
+
+type configWatcher struct {
+	path string
+
+	mu sync.Mutex
+	// the scopes configured by the file, which may be patterns
+	scopes map[string]bool
+}
+
+// apply reads the configuration file and configures logging with it.
+func (w *configWatcher) apply() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return fmt.Errorf("unable to read logging configuration %s: %v", w.path, err)
+	}
+
+	var fc fileConfig
+	if err := yaml.Unmarshal(b, &fc); err != nil {
+		return fmt.Errorf("unable to parse logging configuration %s: %v", w.path, err)
+	}
+
+	o := *configuredOptions.Load().(*Options)
+	scopes, err := fc.applyTo(&o)
+	if err != nil {
+		return fmt.Errorf("invalid logging configuration %s: %v", w.path, err)
+	}
+
+	// the scopes dropped from the file return to the settings of the options, which Configure reapplies
+	for name := range w.scopes {
+		if !scopes[name] {
+			resetScopes(name)
+		}
+	}
+
+	if err := configure(&o); err != nil {
+		return fmt.Errorf("unable to apply logging configuration %s: %v", w.path, err)
+	}
+
+	w.scopes = scopes
+	return nil
+}
+
+// applyTo overrides options with the configuration, and returns the scopes it configures.
+func (fc *fileConfig) applyTo(o *Options) (map[string]bool, error) {
+	scopes := make(map[string]bool)
+
+	for s, l := range fc.OutputLevels {
+		level, ok := stringToLevel[l]
+		if !ok {
+			return nil, fmt.Errorf("invalid output level '%s' for scope '%s'", l, s)
+		}
+		o.SetOutputLevel(s, level)
+		scopes[s] = true
+	}
+
+	for s, l := range fc.StackTraceLevels {
+		level, ok := stringToLevel[l]
+		if !ok {
+			return nil, fmt.Errorf("invalid stack trace level '%s' for scope '%s'", l, s)
+		}
+		o.SetStackTraceLevel(s, level)
+		scopes[s] = true
+	}
+
+	for _, s := range fc.LogCallers {
+		o.SetLogCallers(s, true)
+		scopes[s] = true
+	}
+
+	for s, l := range fc.CallerLevels {
+		level, ok := stringToLevel[l]
+		if !ok {
+			return nil, fmt.Errorf("invalid caller level '%s' for scope '%s'", l, s)
+		}
+		o.SetCallerLevel(s, level)
+		scopes[s] = true
+	}
+
+	for s, c := range fc.Sampling {
+		o.SetSampling(s, c)
+		scopes[s] = true
+	}
+
+	for s, l := range fc.RateLimits {
+		o.SetRateLimit(s, l)
+		scopes[s] = true
+	}
+
+	if fc.OutputPaths != nil {
+		o.OutputPaths = fc.OutputPaths
+	}
+	if fc.RotateOutputPath != nil {
+		o.RotateOutputPath = *fc.RotateOutputPath
+	}
+	if fc.JSONEncoding != nil {
+		o.JSONEncoding = *fc.JSONEncoding
+	}
+
+	for t, l := range fc.TargetLevels {
+		level, ok := stringToLevel[l]
+		if !ok {
+			return nil, fmt.Errorf("invalid output level '%s' for output target '%s'", l, t)
+		}
+		o.SetTargetLevel(t, level)
+	}
+
+	return scopes, nil
+}
+
+// resetScopes returns the scopes matching name to the settings they are registered with.
+func resetScopes(name string) {
+	for n, s := range Scopes() {
+		if ok, _ := path.Match(name, n); ok || n == name {
+			s.SetOutputLevel(InfoLevel)
+			s.SetStackTraceLevel(NoneLevel)
+			s.SetLogCallers(false)
+			s.SetSampling(SamplingConfig{})
+			s.SetRateLimit(RateLimit{})
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"istio.io/pkg/filewatcher"
+)
+
+func TestWatchConfig(t *testing.T) {
+	resetGlobals()
+	ads := RegisterScope("ads", "For testing", 0)
+	xds := RegisterScope("xds", "For testing", 0)
+
+	dir, _ := ioutil.TempDir("", "TestWatchConfig")
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "log.yaml")
+
+	oldWatcher := newFileWatcher
+	defer func() { newFileWatcher = oldWatcher }()
+	var fw *filewatcher.FakeWatcher
+	newFileWatcher, fw = filewatcher.NewFakeWatcher(nil)
+
+	o := DefaultOptions()
+	o.LogGrpc = false
+	o.SetOutputLevel("xds", WarnLevel)
+	if err := Configure(o); err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	defer func() { _ = Configure(DefaultOptions()) }()
+
+	if _, err := WatchConfig(file); err == nil {
+		t.Error("Got success, expecting failure for a missing file")
+	}
+
+	write := func(content string) {
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("Got %v, expecting success", err)
+		}
+	}
+
+	write(`
+outputLevels:
+  ads: debug
+  xds: error
+logCallers: [ads]
+sampling:
+  ads: {initial: 10, thereafter: 100}
+rateLimits:
+  ads: {perSecond: 50, burst: 100}
+`)
+	stop, err := WatchConfig(file)
+	if err != nil {
+		t.Fatalf("Got %v, expecting success", err)
+	}
+	defer stop()
+
+	if ads.GetOutputLevel() != DebugLevel || xds.GetOutputLevel() != ErrorLevel || !ads.GetLogCallers() {
+		t.Errorf("Got %v, %v, %v, expecting the file to apply", ads.GetOutputLevel(), xds.GetOutputLevel(), ads.GetLogCallers())
+	}
+	if ads.GetSampling() != (SamplingConfig{Initial: 10, Thereafter: 100}) || ads.GetRateLimit() != (RateLimit{PerSecond: 50, Burst: 100}) {
+		t.Errorf("Got %v, %v, expecting the file to apply", ads.GetSampling(), ads.GetRateLimit())
+	}
+
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for the configuration to apply")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// dropped scopes return to the settings of the options
+	write(`{"outputLevels": {"a*": "error"}}`)
+	fw.InjectEvent(file, fsnotify.Event{Name: file, Op: fsnotify.Write})
+	waitFor(func() bool { return ads.GetOutputLevel() == ErrorLevel })
+	if xds.GetOutputLevel() != WarnLevel || ads.GetLogCallers() || ads.GetSampling() != (SamplingConfig{}) || ads.GetRateLimit() != (RateLimit{}) {
+		t.Errorf("Got %v, %v, %v, %v, expecting the dropped settings to revert",
+			xds.GetOutputLevel(), ads.GetLogCallers(), ads.GetSampling(), ads.GetRateLimit())
+	}
+
+	// invalid changes leave the configuration unchanged
+	write(`outputLevels: {ads: loud}`)
+	fw.InjectEvent(file, fsnotify.Event{Name: file, Op: fsnotify.Write})
+	write(`outputLevels: {ads: warn}`)
+	fw.InjectEvent(file, fsnotify.Event{Name: file, Op: fsnotify.Write})
+	waitFor(func() bool { return ads.GetOutputLevel() == WarnLevel })
+
+	stop()
+	stop()
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// A Measurement is a value to record into a Metric, see RecordBatch.
+type Measurement struct {
+	Metric Metric
+	Value  float64
+}
+
+// RecordBatch records several Measurements at once, such as the metrics of a request. The Measurements
+// of the OpenCensus backend are recorded together with a single interaction with OpenCensus, as long as
+// they don't set different values for the same Label, which reduces the contention of hot paths. The
+// Measurements of gauges and of metrics with a maximum cardinality are recorded one at a time.
+func RecordBatch(measurements ...Measurement) {
+	var b ocBatch
+	for _, m := range measurements {
+		f, ok := m.Metric.(*float64Metric)
+		if !ok || f.gauge != nil || f.limit != nil {
+			m.Metric.Record(m.Value)
+			continue
+		}
+
+		values, ok := f.batchValues()
+		if !ok {
+			f.Record(m.Value)
+			continue
+		}
+		if b.conflicts(values) {
+			b.flush()
+		}
+		b.add(f, values, m.Value)
+	}
+	b.flush()
+}
+
+// ocBatch holds measurements of the OpenCensus backend recorded with the union of their tags.
+type ocBatch struct {
+	values       map[string]string
+	tags         []tag.Mutator
+	measurements []stats.Measurement
+}
+
+func (b *ocBatch) conflicts(values map[string]string) bool {
+	for n, v := range values {
+		if current, ok := b.values[n]; ok && current != v {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *ocBatch) add(f *float64Metric, values map[string]string, value float64) {
+	if d := diagnosing(); d != nil {
+		d.observe(f.Name(), f.tagNames(), f.labelValues())
+	}
+	if b.values == nil {
+		b.values = make(map[string]string, len(values))
+	}
+	for n, v := range values {
+		b.values[n] = v
+	}
+	b.tags = append(b.tags, f.tags...)
+	b.measurements = append(b.measurements, f.M(value))
+}
+
+func (b *ocBatch) flush() {
+	if len(b.measurements) > 0 {
+		stats.RecordWithTags(context.Background(), b.tags, b.measurements...) //nolint:errcheck
+	}
+	*b = ocBatch{}
+}
+
+// batchValues returns the values of the labels set by the tags, as long as they only set the labels of
+// the Metric, as the tags of a batch apply to all its measurements.
+func (f *float64Metric) batchValues() (map[string]string, bool) {
+	keys := make(map[string]bool, len(f.view.TagKeys))
+	for _, k := range f.view.TagKeys {
+		keys[k.Name()] = true
+	}
+
+	values := make(map[string]string, len(f.tags))
+	for _, t := range f.tags {
+		lv, ok := t.(labelValue)
+		if !ok || !keys[lv.name] {
+			return nil, false
+		}
+		values[lv.name] = lv.value
+	}
+	return values, true
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"testing"
+
+	"go.opencensus.io/stats/view"
+
+	"istio.io/pkg/monitoring"
+)
+
+func TestRecordBatch(t *testing.T) {
+	requests := monitoring.NewSum("oc_batch_requests_total", "Requests", monitoring.WithLabels(name))
+	bytes := monitoring.NewSum("oc_batch_bytes_total", "Bytes", monitoring.WithLabels(name, kind))
+	inflight := monitoring.NewGauge("oc_batch_inflight", "In flight requests")
+	monitoring.MustRegister(requests, bytes, inflight)
+
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	promRequests := monitoring.NewSum("prom_batch_requests_total", "Requests")
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(promRequests)
+
+	monitoring.RecordBatch(
+		monitoring.Measurement{Metric: requests.With(name.Value("foo")), Value: 1},
+		monitoring.Measurement{Metric: bytes.With(name.Value("foo"), kind.Value("in")), Value: 100},
+		monitoring.Measurement{Metric: bytes.With(name.Value("bar"), kind.Value("out")), Value: 50},
+		monitoring.Measurement{Metric: inflight, Value: 3},
+		monitoring.Measurement{Metric: promRequests, Value: 2},
+	)
+
+	check := func(name string, tags map[string]string, expected float64) {
+		t.Helper()
+		rows, err := view.RetrieveData(name)
+		if err != nil {
+			t.Fatalf("Unable to retrieve %s: %v", name, err)
+		}
+		for _, r := range rows {
+			match := true
+			for k, v := range tags {
+				match = match && findTagWithValue(k, v, r.Tags)
+			}
+			if match {
+				if v := value(r); v != expected {
+					t.Errorf("Got %v for %s%v, expecting %v", v, name, tags, expected)
+				}
+				return
+			}
+		}
+		t.Errorf("Got %v, expecting a row for %v", rows, tags)
+	}
+	check("oc_batch_requests_total", map[string]string{"name": "foo"}, 1)
+	check("oc_batch_bytes_total", map[string]string{"name": "foo", "kind": "in"}, 100)
+	check("oc_batch_bytes_total", map[string]string{"name": "bar", "kind": "out"}, 50)
+	check("oc_batch_inflight", nil, 3)
+
+	if v := gather(t, "prom_batch_requests_total").GetMetric()[0].GetCounter().GetValue(); v != 2 {
+		t.Errorf("Got %v, expecting 2", v)
+	}
+}
+
+func value(r *view.Row) float64 {
+	switch d := r.Data.(type) {
+	case *view.SumData:
+		return d.Value
+	case *view.LastValueData:
+		return d.Value
+	}
+	return 0
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"strings"
+	"sync"
+
+	"go.opencensus.io/stats/view"
+)
+
+// OverflowLabelValue is the value of all the labels of the values recorded by a Metric beyond its
+// maximum cardinality.
+const OverflowLabelValue = "other"
+
+var (
+	metricLabel = MustCreateLabel("metric")
+
+	overflowsOnce sync.Once
+	overflows     Metric
+)
+
+// WithMaxCardinality provides configuration options for a new Metric, limiting the number of
+// combinations of LabelValues it records values for. Once the limit is reached, the values of other
+// combinations are recorded with OverflowLabelValue as the value of all the labels, and counted by
+// the monitoring_label_overflows_total metric. This protects exporters from the explosion of series
+// caused by labels whose values are controlled by users. There is no limit by default.
+func WithMaxCardinality(n int) Options {
+	return func(opts *options) {
+		opts.maxCardinality = n
+	}
+}
+
+// recordOverflow counts a value recorded beyond the maximum cardinality of a metric. The metric counting
+// overflows is created with the backend selected by the time the first overflow happens.
+func recordOverflow(name string) {
+	overflowsOnce.Do(func() {
+		overflows = newMetric("monitoring_label_overflows_total",
+			"Number of values recorded beyond the maximum cardinality of a metric, by metric",
+			view.Sum(), WithLabels(metricLabel))
+		_ = overflows.Register()
+	})
+	overflows.With(metricLabel.Value(name)).Increment()
+}
+
+// overflowValues returns the label values of the values recorded beyond the maximum cardinality.
+func overflowValues(n int) []string {
+	values := make([]string, n)
+	for i := range values {
+		values[i] = OverflowLabelValue
+	}
+	return values
+}
+
+// cardinalityLimit keeps track of the combinations of label values recorded by a metric, for the
+// backends which don't hold the values of each combination themselves. The combinations are allocated
+// once the metric is recorded.
+type cardinalityLimit struct {
+	max int
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+
+	overflowOnce sync.Once
+	overflow     interface{}
+}
+
+func newCardinalityLimit(max int) *cardinalityLimit {
+	if max <= 0 {
+		return nil
+	}
+	return &cardinalityLimit{max: max}
+}
+
+// admit returns whether the values of a combination of label values may be recorded, which is the case
+// once they have been, or while fewer combinations than the maximum have been.
+func (c *cardinalityLimit) admit(labelValues []string) bool {
+	key := strings.Join(labelValues, "\xff")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.seen[key]; ok {
+		return true
+	}
+	if len(c.seen) >= c.max {
+		return false
+	}
+	if c.seen == nil {
+		c.seen = make(map[string]struct{})
+	}
+	c.seen[key] = struct{}{}
+	return true
+}
+
+// overflowState returns the state the backend records the values beyond the maximum cardinality with,
+// which is created on the first overflow.
+func (c *cardinalityLimit) overflowState(create func() interface{}) interface{} {
+	c.overflowOnce.Do(func() {
+		c.overflow = create()
+	})
+	return c.overflow
+}
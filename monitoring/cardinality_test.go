@@ -0,0 +1,93 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"testing"
+
+	"go.opencensus.io/stats/view"
+
+	"istio.io/pkg/monitoring"
+)
+
+// overflows returns the number of values recorded beyond the maximum cardinality of a metric.
+func overflows(t *testing.T, metric string) float64 {
+	t.Helper()
+	rows, err := view.RetrieveData("monitoring_label_overflows_total")
+	if err != nil {
+		t.Fatalf("Unable to retrieve the overflows: %v", err)
+	}
+	for _, r := range rows {
+		if findTagWithValue("metric", metric, r.Tags) {
+			return r.Data.(*view.SumData).Value
+		}
+	}
+	return 0
+}
+
+func TestPrometheusMaxCardinality(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	sum := monitoring.NewSum("prom_limited_total", "Number of events", monitoring.WithLabels(name), monitoring.WithMaxCardinality(2))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(sum)
+
+	for _, n := range []string{"foo", "bar", "baz", "qux", "foo"} {
+		sum.With(name.Value(n)).Increment()
+	}
+
+	expected := map[string]float64{"foo": 2, "bar": 1, monitoring.OverflowLabelValue: 2}
+	m := gather(t, "prom_limited_total").GetMetric()
+	if len(m) != len(expected) {
+		t.Fatalf("Got %v, expecting the series of foo, bar and the overflow", m)
+	}
+	for _, s := range m {
+		if v := s.GetCounter().GetValue(); v != expected[labelValue(s, "name")] {
+			t.Errorf("Got %v for %v, expecting %v", v, s.GetLabel(), expected[labelValue(s, "name")])
+		}
+	}
+
+	if o := overflows(t, "prom_limited_total"); o != 2 {
+		t.Errorf("Got %v overflows, expecting 2", o)
+	}
+}
+
+func TestOpenCensusMaxCardinality(t *testing.T) {
+	sum := monitoring.NewSum("oc_limited_total", "Number of events", monitoring.WithLabels(name), monitoring.WithMaxCardinality(1))
+	monitoring.MustRegister(sum)
+
+	for _, n := range []string{"foo", "bar", "foo"} {
+		sum.With(name.Value(n)).Increment()
+	}
+
+	rows, err := view.RetrieveData("oc_limited_total")
+	if err != nil {
+		t.Fatalf("Unable to retrieve the sum: %v", err)
+	}
+	expected := map[string]float64{"foo": 2, monitoring.OverflowLabelValue: 1}
+	if len(rows) != len(expected) {
+		t.Fatalf("Got %v, expecting the rows of foo and the overflow", rows)
+	}
+	for _, r := range rows {
+		for n, v := range expected {
+			if findTagWithValue("name", n, r.Tags) && r.Data.(*view.SumData).Value != v {
+				t.Errorf("Got %v for %v, expecting %v", r.Data, r.Tags, v)
+			}
+		}
+	}
+
+	if o := overflows(t, "oc_limited_total"); o != 1 {
+		t.Errorf("Got %v overflows, expecting 1", o)
+	}
+}
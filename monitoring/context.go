@@ -0,0 +1,69 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// A ContextExtractor returns the LabelValues carried by a context, such as the cluster or the pull
+// request a value is recorded for.
+type ContextExtractor func(ctx context.Context) []LabelValue
+
+var (
+	extractorsMu sync.Mutex
+	extractors   atomic.Value
+)
+
+// RegisterContextExtractor registers a ContextExtractor, whose LabelValues are added to the values
+// recorded with RecordWithContext, rather than calling With at each call site. The LabelValues only
+// apply to the Labels of each Metric, and those provided through With take precedence over them.
+func RegisterContextExtractor(e ContextExtractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	current, _ := extractors.Load().([]ContextExtractor)
+	extractors.Store(append(append([]ContextExtractor(nil), current...), e))
+}
+
+// BaggageExtractor returns a ContextExtractor supplying the values of the Labels from the members of the
+// OpenTelemetry baggage of the context with the same names.
+func BaggageExtractor(labels ...Label) ContextExtractor {
+	return func(ctx context.Context) []LabelValue {
+		b := baggage.FromContext(ctx)
+		var lvs []LabelValue
+		for _, l := range labels {
+			if m := b.Member(tag.Key(l).Name()); m.Key() != "" {
+				lvs = append(lvs, l.Value(m.Value()))
+			}
+		}
+		return lvs
+	}
+}
+
+// contextLabelValues returns the LabelValues the registered extractors supply for a context.
+func contextLabelValues(ctx context.Context) []LabelValue {
+	current, _ := extractors.Load().([]ContextExtractor)
+	var lvs []LabelValue
+	for _, e := range current {
+		lvs = append(lvs, e(ctx)...)
+	}
+	return lvs
+}
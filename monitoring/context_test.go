@@ -0,0 +1,80 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"context"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+	"go.opentelemetry.io/otel/baggage"
+
+	"istio.io/pkg/monitoring"
+)
+
+var cluster = monitoring.MustCreateLabel("cluster")
+
+type clusterKey struct{}
+
+func init() {
+	monitoring.RegisterContextExtractor(func(ctx context.Context) []monitoring.LabelValue {
+		if c, ok := ctx.Value(clusterKey{}).(string); ok {
+			return []monitoring.LabelValue{cluster.Value(c)}
+		}
+		return nil
+	})
+	monitoring.RegisterContextExtractor(monitoring.BaggageExtractor(kind))
+}
+
+func TestPrometheusContextExtractors(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	sum := monitoring.NewSum("prom_context_total", "Number of events", monitoring.WithLabels(cluster, name))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(sum)
+
+	ctx := context.WithValue(context.Background(), clusterKey{}, "east")
+	sum.With(name.Value("foo")).RecordWithContext(ctx, 1)
+	sum.With(name.Value("foo"), cluster.Value("west")).RecordWithContext(ctx, 2)
+	sum.With(name.Value("foo")).RecordWithContext(context.Background(), 4)
+
+	expected := map[string]float64{"east": 1, "west": 2, "": 4}
+	m := gather(t, "prom_context_total").GetMetric()
+	if len(m) != len(expected) {
+		t.Fatalf("Got %v, expecting a series for each cluster", m)
+	}
+	for _, s := range m {
+		if v := s.GetCounter().GetValue(); v != expected[labelValue(s, "cluster")] || labelValue(s, "name") != "foo" {
+			t.Errorf("Got %v for %v, expecting %v", v, s.GetLabel(), expected[labelValue(s, "cluster")])
+		}
+	}
+}
+
+func TestOpenCensusContextExtractors(t *testing.T) {
+	sum := monitoring.NewSum("oc_context_total", "Number of events", monitoring.WithLabels(kind, name))
+	monitoring.MustRegister(sum)
+
+	member, _ := baggage.NewMember("kind", "goofy")
+	b, _ := baggage.New(member)
+	ctx := baggage.ContextWithBaggage(context.Background(), b)
+	sum.With(name.Value("foo")).RecordWithContext(ctx, 3)
+
+	rows, err := view.RetrieveData("oc_context_total")
+	if err != nil {
+		t.Fatalf("Unable to retrieve the sum: %v", err)
+	}
+	if len(rows) != 1 || !findTagWithValue("kind", "goofy", rows[0].Tags) || !findTagWithValue("name", "foo", rows[0].Tags) {
+		t.Errorf("Got %v, expecting the kind of the baggage", rows)
+	}
+}
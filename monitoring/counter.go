@@ -0,0 +1,320 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/metric/metricproducer"
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// An Int64Counter counts integer values, such as the number of requests of a component. Unlike a Sum,
+// adding to an Int64Counter is an atomic addition to an integer, without converting the value to a
+// float64 nor allocating a measurement, and the counts are read by the backend when the metrics are
+// exported. This suits the counters incremented on hot paths.
+type Int64Counter interface {
+	// Name returns the name value of an Int64Counter.
+	Name() string
+
+	// Increment adds one to the count.
+	Increment()
+
+	// Add adds delta to the count. Counts only increase, delta must not be negative.
+	Add(delta int64)
+
+	// With creates a new Int64Counter, with the LabelValues provided. The LabelValues of the Labels
+	// previously provided are replaced, and the others are added.
+	With(labelValues ...LabelValue) Int64Counter
+
+	// Register registers the Int64Counter for export.
+	Register() error
+
+	// Unregister stops the export of the Int64Counter, whose counts are kept.
+	Unregister()
+}
+
+// NewInt64Counter creates a new Int64Counter with the backend selected by the time it is created. The
+// Labels, unit and maximum cardinality of the Options apply to it.
+func NewInt64Counter(name, description string, opts ...Options) Int64Counter {
+	o := createOptions(opts...)
+	f := &int64Family{name: name, maxCardinality: o.maxCardinality}
+	for _, l := range o.labels {
+		f.labelNames = append(f.labelNames, tag.Key(l).Name())
+	}
+
+	switch backend.Load().(Backend) {
+	case PrometheusBackend:
+		f.exporter = &promInt64Exporter{
+			int64Family: f,
+			desc:        prometheus.NewDesc(promName(name), description, f.labelNames, nil),
+		}
+	case OpenTelemetryBackend:
+		f.exporter = &otelInt64Exporter{int64Family: f, description: description, unit: o.unit}
+	default:
+		f.exporter = &ocInt64Exporter{int64Family: f, description: description, unit: o.unit}
+	}
+
+	return &int64Counter{family: f, labelValues: make([]string, len(f.labelNames))}
+}
+
+// int64Exporter exports the counts of an Int64Counter with a backend.
+type int64Exporter interface {
+	register() error
+	unregister()
+}
+
+// int64Family holds the counts of an Int64Counter, by combination of label values. The counts are
+// allocated once the Int64Counter is incremented.
+type int64Family struct {
+	name           string
+	labelNames     []string
+	maxCardinality int
+	exporter       int64Exporter
+
+	mu     sync.RWMutex
+	series map[string]*int64Series
+}
+
+type int64Series struct {
+	labelValues []string
+	start       time.Time
+	value       int64
+}
+
+// int64Lookup caches the series of the label values of an Int64Counter, and whether they exceed its
+// maximum cardinality.
+type int64Lookup struct {
+	series   *int64Series
+	overflow bool
+}
+
+// get returns the series of the label values, or the overflow series if they exceed the maximum
+// cardinality.
+func (f *int64Family) get(labelValues []string) *int64Lookup {
+	key := strings.Join(labelValues, "\xff")
+
+	f.mu.RLock()
+	s, ok := f.series[key]
+	f.mu.RUnlock()
+	if ok {
+		return &int64Lookup{series: s}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok = f.series[key]; ok {
+		return &int64Lookup{series: s}
+	}
+
+	overflow := f.maxCardinality > 0 && len(f.series) >= f.maxCardinality
+	if overflow {
+		labelValues = overflowValues(len(f.labelNames))
+		key = strings.Join(labelValues, "\xff")
+	}
+	if s, ok = f.series[key]; !ok {
+		s = &int64Series{labelValues: labelValues, start: time.Now()}
+		if f.series == nil {
+			f.series = make(map[string]*int64Series)
+		}
+		f.series[key] = s
+	}
+	return &int64Lookup{series: s, overflow: overflow}
+}
+
+// each calls fn with the count of each series.
+func (f *int64Family) each(fn func(s *int64Series, value int64)) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, s := range f.series {
+		fn(s, atomic.LoadInt64(&s.value))
+	}
+}
+
+type int64Counter struct {
+	family      *int64Family
+	labelValues []string
+	lookup      atomic.Value
+}
+
+func (c *int64Counter) Name() string {
+	return c.family.name
+}
+
+func (c *int64Counter) Increment() {
+	c.Add(1)
+}
+
+func (c *int64Counter) Add(delta int64) {
+	if d := diagnosing(); d != nil {
+		d.observe(c.family.name, c.family.labelNames, c.labelValues)
+	}
+
+	l, _ := c.lookup.Load().(*int64Lookup)
+	if l == nil {
+		l = c.family.get(c.labelValues)
+		c.lookup.Store(l)
+	}
+	if l.overflow {
+		recordOverflow(c.family.name)
+	}
+	atomic.AddInt64(&l.series.value, delta)
+}
+
+func (c *int64Counter) With(labelValues ...LabelValue) Int64Counter {
+	values := make([]string, len(c.labelValues))
+	copy(values, c.labelValues)
+
+	resolved := resolveLabelValues(labelValues, c.family.labelNames)
+	for i, n := range c.family.labelNames {
+		if v, ok := resolved[n]; ok {
+			values[i] = v
+		}
+	}
+	return &int64Counter{family: c.family, labelValues: values}
+}
+
+func (c *int64Counter) Register() error {
+	return c.family.exporter.register()
+}
+
+func (c *int64Counter) Unregister() {
+	c.family.exporter.unregister()
+}
+
+// promInt64Exporter collects the counts of an Int64Counter when Prometheus scrapes them.
+type promInt64Exporter struct {
+	*int64Family
+	desc *prometheus.Desc
+}
+
+func (e *promInt64Exporter) register() error {
+	err := prometheus.Register(e)
+	if are, ok := err.(prometheus.AlreadyRegisteredError); ok && are.ExistingCollector == e {
+		return nil
+	}
+	return err
+}
+
+func (e *promInt64Exporter) unregister() {
+	prometheus.Unregister(e)
+}
+
+func (e *promInt64Exporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.desc
+}
+
+func (e *promInt64Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.each(func(s *int64Series, value int64) {
+		ch <- prometheus.MustNewConstMetric(e.desc, prometheus.CounterValue, float64(value), s.labelValues...)
+	})
+}
+
+// otelInt64Exporter observes the counts of an Int64Counter when the readers of the meter provider
+// collect them.
+type otelInt64Exporter struct {
+	*int64Family
+	description string
+	unit        Unit
+
+	mu           sync.Mutex
+	registration metric.Registration
+}
+
+func (e *otelInt64Exporter) register() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.registration != nil {
+		return nil
+	}
+
+	meter := otel.GetMeterProvider().Meter(otelMeterName)
+	o, err := meter.Int64ObservableCounter(e.name, metric.WithDescription(e.description), metric.WithUnit(string(e.unit)))
+	if err != nil {
+		return err
+	}
+	e.registration, err = meter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+		e.each(func(s *int64Series, value int64) {
+			attrs := make([]attribute.KeyValue, len(s.labelValues))
+			for i, v := range s.labelValues {
+				attrs[i] = attribute.String(e.labelNames[i], v)
+			}
+			observer.ObserveInt64(o, value, metric.WithAttributes(attrs...))
+		})
+		return nil
+	}, o)
+	return err
+}
+
+// unregister stops observing the counts, as instruments can't be removed from meters.
+func (e *otelInt64Exporter) unregister() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.registration != nil {
+		_ = e.registration.Unregister()
+		e.registration = nil
+	}
+}
+
+// ocInt64Exporter produces the counts of an Int64Counter as a cumulative metric of OpenCensus, which is
+// exported by the exporters reading the metrics of the producers of the global OpenCensus manager.
+type ocInt64Exporter struct {
+	*int64Family
+	description string
+	unit        Unit
+}
+
+func (e *ocInt64Exporter) register() error {
+	metricproducer.GlobalManager().AddProducer(e)
+	return nil
+}
+
+func (e *ocInt64Exporter) unregister() {
+	metricproducer.GlobalManager().DeleteProducer(e)
+}
+
+func (e *ocInt64Exporter) Read() []*metricdata.Metric {
+	now := time.Now()
+	m := &metricdata.Metric{
+		Descriptor: metricdata.Descriptor{
+			Name:        e.name,
+			Description: e.description,
+			Unit:        metricdata.Unit(e.unit),
+			Type:        metricdata.TypeCumulativeInt64,
+			LabelKeys:   e.labelNames,
+		},
+	}
+	e.each(func(s *int64Series, value int64) {
+		labelValues := make([]metricdata.LabelValue, len(s.labelValues))
+		for i, v := range s.labelValues {
+			labelValues[i] = metricdata.NewLabelValue(v)
+		}
+		m.TimeSeries = append(m.TimeSeries, &metricdata.TimeSeries{
+			LabelValues: labelValues,
+			Points:      []metricdata.Point{metricdata.NewInt64Point(now, value)},
+			StartTime:   s.start,
+		})
+	})
+	return []*metricdata.Metric{m}
+}
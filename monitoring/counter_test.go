@@ -0,0 +1,92 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"istio.io/pkg/monitoring"
+)
+
+func TestPrometheusInt64Counter(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	requests := monitoring.NewInt64Counter("prom_int_requests_total", "Number of requests", monitoring.WithLabels(name, kind))
+	limited := monitoring.NewInt64Counter("prom_int_limited_total", "Number of requests", monitoring.WithLabels(name), monitoring.WithMaxCardinality(1))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	if err := requests.Register(); err != nil {
+		t.Fatalf("Unable to register the counter: %v", err)
+	}
+	if err := limited.Register(); err != nil {
+		t.Fatalf("Unable to register the counter: %v", err)
+	}
+	defer requests.Unregister()
+	defer limited.Unregister()
+
+	foo := requests.With(name.Value("foo"))
+	foo.Increment()
+	foo.Increment()
+	requests.With(kind.Value("goofy")).With(name.Value("bar")).Add(3)
+
+	for _, m := range gather(t, "prom_int_requests_total").GetMetric() {
+		expected := 2.0
+		if labelValue(m, "name") == "bar" {
+			expected = 3
+			if labelValue(m, "kind") != "goofy" {
+				t.Errorf("Got labels %v, expecting kind goofy", m.GetLabel())
+			}
+		}
+		if m.GetCounter().GetValue() != expected {
+			t.Errorf("Got %v for %v, expecting %v", m.GetCounter().GetValue(), m.GetLabel(), expected)
+		}
+	}
+
+	limited.With(name.Value("foo")).Increment()
+	limited.With(name.Value("bar")).Increment()
+	for _, m := range gather(t, "prom_int_limited_total").GetMetric() {
+		if v := labelValue(m, "name"); v != "foo" && v != monitoring.OverflowLabelValue {
+			t.Errorf("Got name %q, expecting the values beyond the maximum cardinality to overflow", v)
+		}
+	}
+
+	if allocs := testing.AllocsPerRun(100, foo.Increment); allocs != 0 {
+		t.Errorf("Got %v allocations incrementing the counter, expecting none", allocs)
+	}
+}
+
+func TestOpenCensusInt64Counter(t *testing.T) {
+	requests := monitoring.NewInt64Counter("oc_int_requests_total", "Number of requests", monitoring.WithLabels(name))
+	if err := requests.Register(); err != nil {
+		t.Fatalf("Unable to register the counter: %v", err)
+	}
+	requests.With(name.Value("foo")).Add(4)
+
+	s := httptest.NewServer(monitoring.ExporterHandler())
+	defer s.Close()
+
+	body, err := scrape(s.URL)
+	if err != nil {
+		t.Fatalf("Unable to scrape the metrics: %v", err)
+	}
+	if e := `oc_int_requests_total{name="foo"} 4`; !strings.Contains(body, e) {
+		t.Errorf("Got %s, expecting %s", body, e)
+	}
+
+	requests.Unregister()
+	if body, _ = scrape(s.URL); strings.Contains(body, "oc_int_requests_total") {
+		t.Errorf("Got %s, expecting the counter to be unregistered", body)
+	}
+}
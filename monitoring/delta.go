@@ -0,0 +1,140 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// DeltaTemporality is a temporality selector of the OpenTelemetry SDK exporting the sums and
+// distributions of the OpenTelemetry backend with delta temporality, for the backends expecting the
+// changes since the previous export rather than cumulative values. It is passed to readers and OTLP
+// exporters, as in sdkmetric.NewManualReader(sdkmetric.WithTemporalitySelector(monitoring.DeltaTemporality)).
+func DeltaTemporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case sdkmetric.InstrumentKindCounter, sdkmetric.InstrumentKindHistogram, sdkmetric.InstrumentKindObservableCounter:
+		return metricdata.DeltaTemporality
+	}
+	return metricdata.CumulativeTemporality
+}
+
+// deltaExporter exports the changes of the sums, counts and distributions of views since their
+// previous export.
+type deltaExporter struct {
+	exporter view.Exporter
+
+	mu       sync.Mutex
+	previous map[string]*view.Row
+	ends     map[string]time.Time
+}
+
+// NewDeltaExporter wraps a view exporter of the OpenCensus backend, such that the sums, counts and
+// distributions it exports are the changes since the previous export rather than cumulative values.
+// The minimum and maximum of distributions remain those of all the observations, as they can't be
+// computed for the observations since the previous export. Values which went down, such as after the
+// view was registered again, are exported as is.
+func NewDeltaExporter(exporter view.Exporter) view.Exporter {
+	return &deltaExporter{
+		exporter: exporter,
+		previous: make(map[string]*view.Row),
+		ends:     make(map[string]time.Time),
+	}
+}
+
+func (e *deltaExporter) ExportView(d *view.Data) {
+	delta := &view.Data{View: d.View, Start: d.Start, End: d.End, Rows: make([]*view.Row, 0, len(d.Rows))}
+
+	e.mu.Lock()
+	if end, ok := e.ends[d.View.Name]; ok && end.After(d.Start) {
+		delta.Start = end
+	}
+	e.ends[d.View.Name] = d.End
+
+	for _, r := range d.Rows {
+		key := rowKey(d.View.Name, r)
+		delta.Rows = append(delta.Rows, &view.Row{Tags: r.Tags, Data: deltaData(r.Data, e.previous[key])})
+		e.previous[key] = r
+	}
+	e.mu.Unlock()
+
+	e.exporter.ExportView(delta)
+}
+
+// rowKey identifies the row of a combination of tags of a view.
+func rowKey(name string, r *view.Row) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, t := range r.Tags {
+		b.WriteByte(0xff)
+		b.WriteString(t.Key.Name())
+		b.WriteByte('=')
+		b.WriteString(t.Value)
+	}
+	return b.String()
+}
+
+// deltaData returns the change of the data of a row since its previous export.
+func deltaData(current view.AggregationData, previous *view.Row) view.AggregationData {
+	if previous == nil {
+		return current
+	}
+
+	switch c := current.(type) {
+	case *view.SumData:
+		if p, ok := previous.Data.(*view.SumData); ok && c.Value >= p.Value {
+			return &view.SumData{Value: c.Value - p.Value}
+		}
+
+	case *view.CountData:
+		if p, ok := previous.Data.(*view.CountData); ok && c.Value >= p.Value {
+			return &view.CountData{Value: c.Value - p.Value}
+		}
+
+	case *view.DistributionData:
+		p, ok := previous.Data.(*view.DistributionData)
+		if !ok || c.Count < p.Count || len(c.CountPerBucket) != len(p.CountPerBucket) {
+			return current
+		}
+
+		d := &view.DistributionData{
+			Count:              c.Count - p.Count,
+			Min:                c.Min,
+			Max:                c.Max,
+			CountPerBucket:     make([]int64, len(c.CountPerBucket)),
+			ExemplarsPerBucket: c.ExemplarsPerBucket,
+		}
+		for i := range c.CountPerBucket {
+			d.CountPerBucket[i] = c.CountPerBucket[i] - p.CountPerBucket[i]
+		}
+		if d.Count > 0 {
+			// the sums of the values and of their squares are those of all the observations minus
+			// those of the previous ones
+			n := float64(d.Count)
+			sum := c.Mean*float64(c.Count) - p.Mean*float64(p.Count)
+			squares := c.SumOfSquaredDev + c.Mean*c.Mean*float64(c.Count) - p.SumOfSquaredDev - p.Mean*p.Mean*float64(p.Count)
+			d.Mean = sum / n
+			d.SumOfSquaredDev = squares - n*d.Mean*d.Mean
+		}
+		return d
+	}
+
+	return current
+}
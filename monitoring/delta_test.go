@@ -0,0 +1,99 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"istio.io/pkg/monitoring"
+)
+
+type lastExporter struct {
+	last *view.Data
+}
+
+func (e *lastExporter) ExportView(d *view.Data) {
+	e.last = d
+}
+
+func TestDeltaExporter(t *testing.T) {
+	exp := &lastExporter{}
+	delta := monitoring.NewDeltaExporter(exp)
+
+	k, _ := tag.NewKey("name")
+	tags := []tag.Tag{{Key: k, Value: "foo"}}
+	sumView := &view.View{Name: "delta_total"}
+	distView := &view.View{Name: "delta_buckets"}
+	start := time.Now()
+
+	delta.ExportView(&view.Data{View: sumView, Start: start, End: start.Add(time.Second), Rows: []*view.Row{
+		{Tags: tags, Data: &view.SumData{Value: 3}},
+	}})
+	if v := exp.last.Rows[0].Data.(*view.SumData).Value; v != 3 {
+		t.Errorf("Got %v, expecting the first export to be cumulative", v)
+	}
+
+	delta.ExportView(&view.Data{View: sumView, Start: start, End: start.Add(2 * time.Second), Rows: []*view.Row{
+		{Tags: tags, Data: &view.SumData{Value: 10}},
+		{Tags: []tag.Tag{{Key: k, Value: "bar"}}, Data: &view.SumData{Value: 2}},
+	}})
+	for _, r := range exp.last.Rows {
+		expected := 7.0
+		if r.Tags[0].Value == "bar" {
+			expected = 2
+		}
+		if v := r.Data.(*view.SumData).Value; v != expected {
+			t.Errorf("Got %v for %v, expecting %v", v, r.Tags, expected)
+		}
+	}
+	if !exp.last.Start.Equal(start.Add(time.Second)) {
+		t.Errorf("Got start %v, expecting the end of the previous export", exp.last.Start)
+	}
+
+	// observations of 1 and 3, followed by an observation of 8
+	delta.ExportView(&view.Data{View: distView, Rows: []*view.Row{
+		{Tags: tags, Data: &view.DistributionData{Count: 2, Min: 1, Max: 3, Mean: 2, SumOfSquaredDev: 2, CountPerBucket: []int64{1, 1, 0}}},
+	}})
+	delta.ExportView(&view.Data{View: distView, Rows: []*view.Row{
+		{Tags: tags, Data: &view.DistributionData{Count: 3, Min: 1, Max: 8, Mean: 4, SumOfSquaredDev: 26, CountPerBucket: []int64{1, 1, 1}}},
+	}})
+	d := exp.last.Rows[0].Data.(*view.DistributionData)
+	if d.Count != 1 || d.Mean != 8 || math.Abs(d.SumOfSquaredDev) > 1e-9 {
+		t.Errorf("Got %+v, expecting the observation of 8", d)
+	}
+	if d.CountPerBucket[0] != 0 || d.CountPerBucket[1] != 0 || d.CountPerBucket[2] != 1 {
+		t.Errorf("Got buckets %v, expecting the bucket of 8", d.CountPerBucket)
+	}
+}
+
+func TestDeltaTemporality(t *testing.T) {
+	for kind, expected := range map[sdkmetric.InstrumentKind]metricdata.Temporality{
+		sdkmetric.InstrumentKindCounter:         metricdata.DeltaTemporality,
+		sdkmetric.InstrumentKindHistogram:       metricdata.DeltaTemporality,
+		sdkmetric.InstrumentKindUpDownCounter:   metricdata.CumulativeTemporality,
+		sdkmetric.InstrumentKindObservableGauge: metricdata.CumulativeTemporality,
+	} {
+		if got := monitoring.DeltaTemporality(kind); got != expected {
+			t.Errorf("Got %v for %v, expecting %v", got, kind, expected)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ocmetric "go.opencensus.io/metric"
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/metric/metricproducer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// A DerivedGauge is a gauge whose values are computed by functions when metrics are exported, rather
+// than recorded. This suits values which are expensive or racy to record on every change, such as the
+// depth of a queue or the size of a cache.
+type DerivedGauge interface {
+	// Name returns the name value of a DerivedGauge.
+	Name() string
+
+	// ValueFrom sets the function computing the value of the DerivedGauge for the LabelValues
+	// provided, which replaces the function previously set for them.
+	ValueFrom(valueFn func() float64, labelValues ...LabelValue) DerivedGauge
+
+	// Unregister stops the export of the DerivedGauge.
+	Unregister()
+}
+
+// NewDerivedGauge creates a new DerivedGauge, whose value for the LabelValues provided is computed
+// by valueFn. The Labels of the LabelValues are those of the DerivedGauge, for which ValueFrom sets
+// the functions of other values. The DerivedGauge is registered for export when it is created, and
+// this panics if registration fails, as MustRegister does.
+func NewDerivedGauge(name, description string, valueFn func() float64, labelValues ...LabelValue) DerivedGauge {
+	var g DerivedGauge
+	var err error
+	switch backend.Load().(Backend) {
+	case PrometheusBackend:
+		g, err = newPrometheusDerivedGauge(name, description, labelNames(labelValues))
+	case OpenTelemetryBackend:
+		g, err = newOpenTelemetryDerivedGauge(name, description, labelNames(labelValues))
+	default:
+		g, err = newOpenCensusDerivedGauge(name, description, labelNames(labelValues))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	return g.ValueFrom(valueFn, labelValues...)
+}
+
+// derivedEntries holds the functions computing the values of a DerivedGauge, by label values.
+type derivedEntries struct {
+	name       string
+	labelNames []string
+
+	mu      sync.RWMutex
+	entries map[string]derivedEntry
+}
+
+type derivedEntry struct {
+	labelValues []string
+	valueFn     func() float64
+}
+
+func (d *derivedEntries) Name() string {
+	return d.name
+}
+
+// set sets the function of LabelValues, and returns the values of the labels.
+func (d *derivedEntries) set(valueFn func() float64, lvs []LabelValue) []string {
+	values := orderLabelValues(resolveLabelValues(lvs, d.labelNames), d.labelNames)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[strings.Join(values, "\xff")] = derivedEntry{labelValues: values, valueFn: valueFn}
+	return values
+}
+
+// each calls fn with the value of each entry.
+func (d *derivedEntries) each(fn func(labelValues []string, value float64)) {
+	d.mu.RLock()
+	entries := make([]derivedEntry, 0, len(d.entries))
+	for _, e := range d.entries {
+		entries = append(entries, e)
+	}
+	d.mu.RUnlock()
+
+	// the functions are called without holding the lock, as they may take a while
+	for _, e := range entries {
+		fn(e.labelValues, e.valueFn())
+	}
+}
+
+// promDerivedGauge collects the values of a DerivedGauge when Prometheus scrapes them.
+type promDerivedGauge struct {
+	*derivedEntries
+	desc *prometheus.Desc
+}
+
+func newPrometheusDerivedGauge(name, description string, labelNames []string) (*promDerivedGauge, error) {
+	g := &promDerivedGauge{
+		derivedEntries: &derivedEntries{name: name, labelNames: labelNames, entries: make(map[string]derivedEntry)},
+		desc:           prometheus.NewDesc(promName(name), description, labelNames, nil),
+	}
+	return g, prometheus.Register(g)
+}
+
+func (g *promDerivedGauge) ValueFrom(valueFn func() float64, labelValues ...LabelValue) DerivedGauge {
+	g.set(valueFn, labelValues)
+	return g
+}
+
+func (g *promDerivedGauge) Unregister() {
+	prometheus.Unregister(g)
+}
+
+func (g *promDerivedGauge) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+func (g *promDerivedGauge) Collect(ch chan<- prometheus.Metric) {
+	g.each(func(labelValues []string, value float64) {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, value, labelValues...)
+	})
+}
+
+// otelDerivedGauge observes the values of a DerivedGauge when the readers of the meter provider
+// collect them.
+type otelDerivedGauge struct {
+	*derivedEntries
+	registration metric.Registration
+}
+
+func newOpenTelemetryDerivedGauge(name, description string, labelNames []string) (*otelDerivedGauge, error) {
+	meter := otel.GetMeterProvider().Meter(otelMeterName)
+	g := &otelDerivedGauge{
+		derivedEntries: &derivedEntries{name: name, labelNames: labelNames, entries: make(map[string]derivedEntry)},
+	}
+
+	o, err := meter.Float64ObservableGauge(name, metric.WithDescription(description))
+	if err != nil {
+		return nil, err
+	}
+	g.registration, err = meter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+		g.each(func(labelValues []string, value float64) {
+			attrs := make([]attribute.KeyValue, len(labelValues))
+			for i, v := range labelValues {
+				attrs[i] = attribute.String(g.labelNames[i], v)
+			}
+			observer.ObserveFloat64(o, value, metric.WithAttributes(attrs...))
+		})
+		return nil
+	}, o)
+	return g, err
+}
+
+func (g *otelDerivedGauge) ValueFrom(valueFn func() float64, labelValues ...LabelValue) DerivedGauge {
+	g.set(valueFn, labelValues)
+	return g
+}
+
+// Unregister stops observing the values, as instruments can't be removed from meters.
+func (g *otelDerivedGauge) Unregister() {
+	_ = g.registration.Unregister()
+}
+
+// ocDerivedGauge is a derived gauge of OpenCensus, which is exported by the exporters reading the
+// metrics of the producers of the global OpenCensus manager rather than views.
+type ocDerivedGauge struct {
+	*derivedEntries
+	registry *ocmetric.Registry
+	gauge    *ocmetric.Float64DerivedGauge
+}
+
+func newOpenCensusDerivedGauge(name, description string, labelNames []string) (*ocDerivedGauge, error) {
+	registry := ocmetric.NewRegistry()
+	gauge, err := registry.AddFloat64DerivedGauge(name, ocmetric.WithDescription(description), ocmetric.WithLabelKeys(labelNames...))
+	if err != nil {
+		return nil, err
+	}
+	metricproducer.GlobalManager().AddProducer(registry)
+
+	return &ocDerivedGauge{
+		derivedEntries: &derivedEntries{name: name, labelNames: labelNames, entries: make(map[string]derivedEntry)},
+		registry:       registry,
+		gauge:          gauge,
+	}, nil
+}
+
+func (g *ocDerivedGauge) ValueFrom(valueFn func() float64, labelValues ...LabelValue) DerivedGauge {
+	values := g.set(valueFn, labelValues)
+	ocValues := make([]metricdata.LabelValue, len(values))
+	for i, v := range values {
+		ocValues[i] = metricdata.NewLabelValue(v)
+	}
+	_ = g.gauge.UpsertEntry(valueFn, ocValues...)
+	return g
+}
+
+func (g *ocDerivedGauge) Unregister() {
+	metricproducer.GlobalManager().DeleteProducer(g.registry)
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/metric/metricproducer"
+
+	"istio.io/pkg/monitoring"
+)
+
+func TestPrometheusDerivedGauge(t *testing.T) {
+	depth := 3.0
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	g := monitoring.NewDerivedGauge("prom_queue_depth", "Depth of the queue", func() float64 { return depth }, name.Value("foo"))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	g.ValueFrom(func() float64 { return 7 }, name.Value("bar"))
+
+	depth = 5
+	for _, m := range gather(t, "prom_queue_depth").GetMetric() {
+		expected := 5.0
+		if labelValue(m, "name") == "bar" {
+			expected = 7
+		}
+		if m.GetGauge().GetValue() != expected {
+			t.Errorf("Got %v for %v, expecting %v", m.GetGauge().GetValue(), m.GetLabel(), expected)
+		}
+	}
+
+	g.Unregister()
+	families, _ := prometheus.DefaultGatherer.Gather()
+	for _, f := range families {
+		if f.GetName() == "prom_queue_depth" {
+			t.Errorf("Got %v, expecting the derived gauge to be unregistered", f)
+		}
+	}
+}
+
+func TestOpenCensusDerivedGauge(t *testing.T) {
+	depth := 3.0
+	g := monitoring.NewDerivedGauge("oc_queue_depth", "Depth of the queue", func() float64 { return depth }, name.Value("foo"))
+	depth = 5
+
+	read := func() []float64 {
+		var values []float64
+		for _, p := range metricproducer.GlobalManager().GetAll() {
+			for _, m := range p.Read() {
+				if m.Descriptor.Name != "oc_queue_depth" {
+					continue
+				}
+				for _, ts := range m.TimeSeries {
+					if len(ts.LabelValues) != 1 || ts.LabelValues[0].Value != "foo" {
+						t.Errorf("Got label values %v, expecting foo", ts.LabelValues)
+					}
+					values = append(values, ts.Points[0].Value.(float64))
+				}
+			}
+		}
+		return values
+	}
+
+	if v := read(); len(v) != 1 || v[0] != 5 {
+		t.Errorf("Got %v, expecting the value of the derived gauge when read", v)
+	}
+
+	g.Unregister()
+	if v := read(); len(v) != 0 {
+		t.Errorf("Got %v, expecting the derived gauge to be unregistered", v)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultDiagnosticsTopN = 10
+
+var (
+	diagnosticsLabel = MustCreateLabel("label")
+
+	diagnostics atomic.Value
+)
+
+// DiagnosticsOptions configures the diagnostics of the cardinality of the metrics, see
+// EnableCardinalityDiagnostics.
+type DiagnosticsOptions struct {
+	// TopN is the number of the most recorded values of each label reported. This defaults to 10.
+	TopN int
+
+	// Threshold is the number of distinct values of a label beyond which it is reported to OnExplosion.
+	// Labels aren't reported if it is zero.
+	Threshold int
+
+	// OnExplosion is called once for each label whose number of distinct values exceeds the Threshold,
+	// such as to log it.
+	OnExplosion func(DimensionCardinality)
+}
+
+// DimensionCardinality reports the values recorded for a label of a metric.
+type DimensionCardinality struct {
+	// Metric is the name of the metric.
+	Metric string
+
+	// Label is the name of the label.
+	Label string
+
+	// Values is the number of distinct values recorded for the label.
+	Values int
+
+	// Top holds the most recorded values of the label, by decreasing number of values recorded.
+	Top []LabelValueCount
+}
+
+// LabelValueCount is the number of values recorded for a value of a label.
+type LabelValueCount struct {
+	Value string
+	Count int64
+}
+
+// EnableCardinalityDiagnostics tracks the values recorded for each label of each metric, to pinpoint
+// the labels exploding the cardinality of the metrics. The number of distinct values of each label is
+// exported by the monitoring_label_cardinality metric, created with the backend selected by the time the
+// first value is recorded, and the most recorded values are reported by CardinalityReport. The
+// diagnostics replace those previously enabled, and stop once the returned function is called. As every
+// distinct value recorded is kept and every recording contends on the diagnostics, they are meant for
+// debugging rather than production use.
+func EnableCardinalityDiagnostics(o DiagnosticsOptions) (disable func()) {
+	if o.TopN <= 0 {
+		o.TopN = defaultDiagnosticsTopN
+	}
+
+	d := &cardinalityDiagnostics{DiagnosticsOptions: o, dimensions: make(map[dimensionKey]*dimensionValues)}
+	if previous, _ := diagnostics.Swap(d).(*cardinalityDiagnostics); previous != nil {
+		previous.stop()
+	}
+
+	return func() {
+		if diagnostics.CompareAndSwap(d, (*cardinalityDiagnostics)(nil)) {
+			d.stop()
+		}
+	}
+}
+
+// CardinalityReport returns the values recorded for each label since the cardinality diagnostics were
+// enabled, by decreasing number of distinct values. It returns nothing unless they are enabled.
+func CardinalityReport() []DimensionCardinality {
+	d := diagnosing()
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	report := make([]DimensionCardinality, 0, len(d.dimensions))
+	for k, v := range d.dimensions {
+		report = append(report, d.dimensionCardinality(k, v))
+	}
+	d.mu.Unlock()
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Values != report[j].Values {
+			return report[i].Values > report[j].Values
+		}
+		if report[i].Metric != report[j].Metric {
+			return report[i].Metric < report[j].Metric
+		}
+		return report[i].Label < report[j].Label
+	})
+	return report
+}
+
+// diagnosing returns the cardinality diagnostics, or nil unless they are enabled.
+func diagnosing() *cardinalityDiagnostics {
+	d, _ := diagnostics.Load().(*cardinalityDiagnostics)
+	return d
+}
+
+type dimensionKey struct {
+	metric string
+	label  string
+}
+
+// dimensionValues counts the values recorded for each value of a label.
+type dimensionValues struct {
+	counts   map[string]int64
+	exploded bool
+}
+
+type cardinalityDiagnostics struct {
+	DiagnosticsOptions
+
+	mu         sync.Mutex
+	dimensions map[dimensionKey]*dimensionValues
+	gauge      DerivedGauge
+	stopped    bool
+}
+
+// stop stops the export of the diagnostics.
+func (d *cardinalityDiagnostics) stop() {
+	d.mu.Lock()
+	d.stopped = true
+	gauge := d.gauge
+	d.mu.Unlock()
+
+	// the gauge is unregistered without holding the lock, as its values are computed while holding it
+	if gauge != nil {
+		gauge.Unregister()
+	}
+}
+
+// observe counts a value recorded by a metric with the given label values.
+func (d *cardinalityDiagnostics) observe(metric string, labelNames, labelValues []string) {
+	var exploded []DimensionCardinality
+
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	for i, n := range labelNames {
+		k := dimensionKey{metric: metric, label: n}
+		v, ok := d.dimensions[k]
+		if !ok {
+			v = &dimensionValues{counts: make(map[string]int64)}
+			d.dimensions[k] = v
+			d.export(k)
+		}
+
+		v.counts[labelValues[i]]++
+		if d.Threshold > 0 && !v.exploded && len(v.counts) > d.Threshold {
+			v.exploded = true
+			exploded = append(exploded, d.dimensionCardinality(k, v))
+		}
+	}
+	d.mu.Unlock()
+
+	// the callback is called without holding the lock, as it may record metrics
+	if d.OnExplosion != nil {
+		for _, c := range exploded {
+			d.OnExplosion(c)
+		}
+	}
+}
+
+// export exports the number of distinct values of a label.
+func (d *cardinalityDiagnostics) export(k dimensionKey) {
+	valueFn := func() float64 {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return float64(len(d.dimensions[k].counts))
+	}
+	lvs := []LabelValue{metricLabel.Value(k.metric), diagnosticsLabel.Value(k.label)}
+
+	if d.gauge == nil {
+		d.gauge = NewDerivedGauge("monitoring_label_cardinality",
+			"Number of distinct values recorded for a label of a metric, by metric and label", valueFn, lvs...)
+		return
+	}
+	d.gauge.ValueFrom(valueFn, lvs...)
+}
+
+func (d *cardinalityDiagnostics) dimensionCardinality(k dimensionKey, v *dimensionValues) DimensionCardinality {
+	top := make([]LabelValueCount, 0, len(v.counts))
+	for value, count := range v.counts {
+		top = append(top, LabelValueCount{Value: value, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Value < top[j].Value
+	})
+	if len(top) > d.TopN {
+		top = top[:d.TopN]
+	}
+
+	return DimensionCardinality{Metric: k.metric, Label: k.label, Values: len(v.counts), Top: top}
+}
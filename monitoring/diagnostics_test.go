@@ -0,0 +1,76 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"istio.io/pkg/monitoring"
+)
+
+func TestCardinalityDiagnostics(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	defer monitoring.SetBackend(monitoring.OpenCensusBackend)
+	sum := monitoring.NewSum("prom_diagnosed_total", "Number of events", monitoring.WithLabels(name, kind))
+	monitoring.MustRegister(sum)
+
+	var exploded []monitoring.DimensionCardinality
+	disable := monitoring.EnableCardinalityDiagnostics(monitoring.DiagnosticsOptions{
+		TopN:        2,
+		Threshold:   3,
+		OnExplosion: func(c monitoring.DimensionCardinality) { exploded = append(exploded, c) },
+	})
+
+	for _, v := range []string{"a", "b", "a", "c", "a", "b", "d", "e"} {
+		sum.With(name.Value(v), kind.Value("event")).Increment()
+	}
+
+	expected := []monitoring.DimensionCardinality{
+		{Metric: "prom_diagnosed_total", Label: "name", Values: 5, Top: []monitoring.LabelValueCount{{"a", 3}, {"b", 2}}},
+		{Metric: "prom_diagnosed_total", Label: "kind", Values: 1, Top: []monitoring.LabelValueCount{{"event", 8}}},
+	}
+	if report := monitoring.CardinalityReport(); !reflect.DeepEqual(report, expected) {
+		t.Errorf("Got %v, expecting %v", report, expected)
+	}
+
+	if len(exploded) != 1 || exploded[0].Label != "name" || exploded[0].Values != 4 {
+		t.Errorf("Got %v, expecting the name label to be reported once as exploding", exploded)
+	}
+
+	values := map[string]float64{}
+	for _, m := range gather(t, "monitoring_label_cardinality").GetMetric() {
+		if labelValue(m, "metric") == "prom_diagnosed_total" {
+			values[labelValue(m, "label")] = m.GetGauge().GetValue()
+		}
+	}
+	if !reflect.DeepEqual(values, map[string]float64{"name": 5, "kind": 1}) {
+		t.Errorf("Got %v, expecting the number of distinct values by label", values)
+	}
+
+	disable()
+	sum.With(name.Value("f")).Increment()
+	if report := monitoring.CardinalityReport(); report != nil {
+		t.Errorf("Got %v, expecting no report once the diagnostics are disabled", report)
+	}
+	families, _ := prometheus.DefaultGatherer.Gather()
+	for _, f := range families {
+		if f.GetName() == "monitoring_label_cardinality" {
+			t.Errorf("Got %v, expecting the cardinality not to be exported once the diagnostics are disabled", f)
+		}
+	}
+}
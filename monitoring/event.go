@@ -0,0 +1,120 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+	"time"
+)
+
+// An Event records the measurements of a single occurrence, such as the size and duration of a request,
+// into several Metrics with the same LabelValues, such as the response code of the request. Each Metric
+// is recorded with the LabelValues of its own Labels, such that the dimensions of an Event are set once
+// rather than at each Metric, and are consistent across them. An Event isn't safe for concurrent use.
+//
+//	monitoring.NewEvent(method.Value("GET"), code.Value("200")).
+//		Measure(requests, 1).
+//		Measure(requestBytes, float64(size)).
+//		MeasureDuration(requestDuration, time.Since(start)).
+//		Record()
+type Event struct {
+	labelValues  []LabelValue
+	measurements []Measurement
+}
+
+// NewEvent creates a new Event with the LabelValues provided.
+func NewEvent(labelValues ...LabelValue) *Event {
+	return &Event{labelValues: labelValues}
+}
+
+// With adds LabelValues to the Event, which replace those previously provided for the same Labels.
+func (e *Event) With(labelValues ...LabelValue) *Event {
+	e.labelValues = append(e.labelValues, labelValues...)
+	return e
+}
+
+// Measure adds a value to record into a Metric.
+func (e *Event) Measure(m Metric, value float64) *Event {
+	e.measurements = append(e.measurements, Measurement{Metric: m, Value: value})
+	return e
+}
+
+// MeasureDuration adds a duration to record into a Metric, in the unit of the Metric. The duration isn't
+// recorded if the unit of the Metric isn't a duration, as with the stopwatches of Metric.Start.
+func (e *Event) MeasureDuration(m Metric, d time.Duration) *Event {
+	_, unit := eventMetricLabels(m)
+	v, err := DurationValue(d, unit)
+	if err != nil {
+		recordUnitMismatch(m.Name())
+		return e
+	}
+	return e.Measure(m, v)
+}
+
+// Record records the measurements of the Event, together when possible, see RecordBatch.
+func (e *Event) Record() {
+	measurements := make([]Measurement, len(e.measurements))
+	for i, m := range e.measurements {
+		measurements[i] = Measurement{Metric: e.with(m.Metric), Value: m.Value}
+	}
+	RecordBatch(measurements...)
+}
+
+// RecordWithContext records the measurements of the Event with a context, see
+// Metric.RecordWithContext. The LabelValues of the Event take precedence over those of the context.
+func (e *Event) RecordWithContext(ctx context.Context) {
+	for _, m := range e.measurements {
+		e.with(m.Metric).RecordWithContext(ctx, m.Value)
+	}
+}
+
+// with returns the Metric recording the values of the Event, with the LabelValues of its Labels. The
+// LabelValues of other Labels are left out, such that the Metrics of the OpenCensus backend are recorded
+// together.
+func (e *Event) with(m Metric) Metric {
+	names, _ := eventMetricLabels(m)
+	if names == nil {
+		return m.With(e.labelValues...)
+	}
+
+	known := make(map[string]bool, len(names))
+	for _, n := range names {
+		known[n] = true
+	}
+	lvs := make([]LabelValue, 0, len(e.labelValues))
+	for _, lv := range e.labelValues {
+		// the Labels of other LabelValues are only known through the tags they set
+		if v, ok := lv.(labelValue); !ok || known[v.name] {
+			lvs = append(lvs, lv)
+		}
+	}
+	return m.With(lvs...)
+}
+
+// eventMetricLabels returns the names of the Labels of a Metric and its unit, or no names for the
+// Metrics of other packages, which are recorded with all the LabelValues of the Events.
+func eventMetricLabels(m Metric) ([]string, Unit) {
+	switch m := m.(type) {
+	case *float64Metric:
+		return m.tagNames(), Unit(m.Unit())
+	case *promMetric:
+		return m.family.labelNames, m.family.unit
+	case *promSummary:
+		return m.labelNames, m.unit
+	case *otelMetric:
+		return m.labelNames, m.unit
+	}
+	return nil, None
+}
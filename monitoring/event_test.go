@@ -0,0 +1,77 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+
+	"istio.io/pkg/monitoring"
+	"istio.io/pkg/monitoring/monitortest"
+)
+
+func TestEvent(t *testing.T) {
+	requests := monitoring.NewSum("oc_event_requests_total", "Requests", monitoring.WithLabels(name))
+	bytes := monitoring.NewDistribution("oc_event_bytes", "Sizes", []float64{10, 100, 1000}, monitoring.WithLabels(name, kind))
+	duration := monitoring.NewDistribution("oc_event_milliseconds", "Durations", nil,
+		monitoring.WithLabels(kind), monitoring.WithUnit(monitoring.Milliseconds))
+	monitoring.MustRegister(requests, bytes, duration)
+
+	monitoring.NewEvent(name.Value("foo")).
+		With(kind.Value("in")).
+		Measure(requests, 1).
+		Measure(bytes, 100).
+		MeasureDuration(duration, 1500*time.Millisecond).
+		Record()
+
+	mt := monitortest.New(t)
+	mt.Assert("oc_event_requests_total", map[string]string{"name": "foo"}, monitortest.Exactly(1))
+	mt.Assert("oc_event_bytes", map[string]string{"name": "foo", "kind": "in"}, monitortest.Exactly(1))
+	mt.Assert("oc_event_milliseconds", map[string]string{"kind": "in"}, monitortest.Exactly(1))
+
+	rows, err := view.RetrieveData("oc_event_milliseconds")
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("Got %v, %v, expecting a single row", rows, err)
+	}
+	if d := rows[0].Data.(*view.DistributionData); d.Mean != 1500 {
+		t.Errorf("Got %v, expecting the duration in milliseconds", d.Mean)
+	}
+	if len(rows[0].Tags) != 1 || !findTagWithValue("kind", "in", rows[0].Tags) {
+		t.Errorf("Got tags %v, expecting only the labels of the metric", rows[0].Tags)
+	}
+}
+
+func TestPrometheusEvent(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	requests := monitoring.NewSum("prom_event_requests_total", "Requests", monitoring.WithLabels(name))
+	sizes := monitoring.NewDistribution("prom_event_bytes", "Sizes", nil, monitoring.WithLabels(kind), monitoring.WithUnit(monitoring.Bytes))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(requests, sizes)
+
+	// durations aren't recorded into metrics of other units
+	monitoring.NewEvent(name.Value("foo"), kind.Value("out")).
+		Measure(requests, 2).
+		MeasureDuration(sizes, time.Second).
+		RecordWithContext(context.Background())
+
+	m := gather(t, "prom_event_requests_total").GetMetric()
+	if len(m) != 1 || labelValue(m[0], "name") != "foo" || m[0].GetCounter().GetValue() != 2 {
+		t.Errorf("Got %v, expecting 2 requests for foo", m)
+	}
+	monitortest.New(t).AssertMissing("prom_event_bytes", nil)
+}
@@ -0,0 +1,43 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+
+	octrace "go.opencensus.io/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// The labels of exemplars holding the identifiers of the trace and span of observations.
+const (
+	traceIDLabel = "trace_id"
+	spanIDLabel  = "span_id"
+)
+
+// spanFromContext returns the identifiers of the trace and span carried by a context, in their hex
+// representation, whether the span is an OpenTelemetry or an OpenCensus one.
+func spanFromContext(ctx context.Context) (string, string, bool) {
+	if sc := oteltrace.SpanContextFromContext(ctx); sc.IsValid() {
+		return sc.TraceID().String(), sc.SpanID().String(), true
+	}
+
+	if span := octrace.FromContext(ctx); span != nil {
+		sc := span.SpanContext()
+		return sc.TraceID.String(), sc.SpanID.String(), true
+	}
+
+	return "", "", false
+}
@@ -0,0 +1,189 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/stats/view"
+)
+
+const (
+	// the scale of exponential distributions starts at the maximum schema of Prometheus native
+	// histograms, and decreases down to their minimum schema as observations spread out
+	maxExponentialScale = 8
+	minExponentialScale = -4
+
+	defaultMaxBuckets = 160
+)
+
+// exponentialBounds are the bounds of exponential distributions recorded with the OpenCensus backend,
+// which only supports static bounds: the powers of 2 from 2^-10 to 2^30.
+var exponentialBounds = func() []float64 {
+	var bounds []float64
+	for e := -10; e <= 30; e++ {
+		bounds = append(bounds, math.Ldexp(1, e))
+	}
+	return bounds
+}()
+
+// NewExponentialDistribution creates a new Metric with an aggregation type of Distribution, whose
+// buckets grow exponentially rather than having static bounds. The buckets are those of Prometheus
+// native histograms and of OTLP exponential histograms: their bounds are powers of 2^(2^-scale),
+// where the scale starts at 8 and decreases as observations spread out, such that the observations of
+// each sign fit in WithMaxBuckets buckets. The OpenCensus backend falls back to the powers of 2 from
+// 2^-10 to 2^30 as bounds.
+func NewExponentialDistribution(name, description string, opts ...Options) Metric {
+	opts = append(opts, func(o *options) { o.exponential = true })
+	return newMetric(name, description, view.Distribution(exponentialBounds...), opts...)
+}
+
+// WithMaxBuckets provides configuration options for a new exponential distribution, limiting the
+// number of buckets of the positive and of the negative observations. This defaults to 160.
+func WithMaxBuckets(n int) Options {
+	return func(opts *options) {
+		opts.maxBuckets = n
+	}
+}
+
+// expHistogram holds the observations of an exponential distribution. Bucket i of a scale counts the
+// observations whose absolute value is within (base^i, base^(i+1)], where base is 2^(2^-scale).
+type expHistogram struct {
+	mu         sync.Mutex
+	maxBuckets int
+	scale      int
+	count      uint64
+	sum        float64
+	zeroCount  uint64
+	positive   expBuckets
+	negative   expBuckets
+	exemplar   *prometheus.Exemplar
+}
+
+// expBuckets holds the counts of the buckets of one sign, along with the range of their indexes.
+type expBuckets struct {
+	counts   map[int]uint64
+	min, max int
+}
+
+func newExpHistogram(maxBuckets int) *expHistogram {
+	if maxBuckets <= 0 {
+		maxBuckets = defaultMaxBuckets
+	}
+	return &expHistogram{maxBuckets: maxBuckets, scale: maxExponentialScale}
+}
+
+// record makes an observation, keeping its exemplar when it has one.
+func (h *expHistogram) record(value float64, e *prometheus.Exemplar) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.count++
+	h.sum += value
+	if e != nil {
+		h.exemplar = e
+	}
+
+	switch {
+	case value > 0:
+		h.add(&h.positive, value)
+	case value < 0:
+		h.add(&h.negative, -value)
+	default:
+		h.zeroCount++
+	}
+}
+
+func (h *expHistogram) add(b *expBuckets, value float64) {
+	i := expIndex(value, h.scale)
+
+	// downscale until the buckets fit, merging pairs of buckets at each step
+	lo, hi := i, i
+	if b.counts != nil {
+		if b.min < lo {
+			lo = b.min
+		}
+		if b.max > hi {
+			hi = b.max
+		}
+	}
+	shift := 0
+	for h.scale-shift > minExponentialScale && (hi>>shift)-(lo>>shift)+1 > h.maxBuckets {
+		shift++
+	}
+	if shift > 0 {
+		h.scale -= shift
+		h.positive.downscale(shift)
+		h.negative.downscale(shift)
+		i >>= shift
+	}
+
+	if b.counts == nil {
+		b.counts = make(map[int]uint64)
+		b.min, b.max = i, i
+	}
+	b.counts[i]++
+	if i < b.min {
+		b.min = i
+	}
+	if i > b.max {
+		b.max = i
+	}
+}
+
+func (b *expBuckets) downscale(shift int) {
+	if b.counts == nil {
+		return
+	}
+
+	counts := make(map[int]uint64, len(b.counts))
+	for i, c := range b.counts {
+		counts[i>>shift] += c
+	}
+	b.counts = counts
+	b.min >>= shift
+	b.max >>= shift
+}
+
+// indexes returns the indexes of the buckets, in increasing order.
+func (b *expBuckets) indexes() []int {
+	indexes := make([]int, 0, len(b.counts))
+	for i := range b.counts {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// expIndex returns the index of the bucket of a positive value at a scale.
+func expIndex(value float64, scale int) int {
+	frac, exp := math.Frexp(value)
+
+	// powers of 2 are the upper bounds of their buckets
+	if frac == 0.5 {
+		if scale <= 0 {
+			return (exp - 2) >> -scale
+		}
+		return ((exp - 1) << scale) - 1
+	}
+
+	if scale <= 0 {
+		return (exp - 1) >> -scale
+	}
+	return int(math.Ceil(math.Log(value)*math.Ldexp(math.Log2E, scale))) - 1
+}
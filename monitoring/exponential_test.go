@@ -0,0 +1,59 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"math"
+	"testing"
+
+	"istio.io/pkg/monitoring"
+)
+
+func TestExponentialDistributionPrometheus(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	fine := monitoring.NewExponentialDistribution("prom_exp_fine", "Exponential distribution")
+	coarse := monitoring.NewExponentialDistribution("prom_exp_coarse", "Exponential distribution", monitoring.WithMaxBuckets(2))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(fine, coarse)
+
+	fine.Record(3)
+	fine.Record(0)
+	h := gather(t, "prom_exp_fine").GetMetric()[0].GetHistogram()
+	if h.GetSchema() != 8 || h.GetSampleCount() != 2 || h.GetZeroCount() != 1 {
+		t.Fatalf("Got %v, expecting schema 8 and a zero observation", h)
+	}
+
+	// bucket i holds the observations within (base^(i-1), base^i]
+	spans := h.GetPositiveSpan()
+	if len(spans) != 1 || spans[0].GetLength() != 1 || h.GetPositiveDelta()[0] != 1 {
+		t.Fatalf("Got %v, expecting a single bucket", h)
+	}
+	base := math.Pow(2, math.Pow(2, -8))
+	i := float64(spans[0].GetOffset())
+	if lo, hi := math.Pow(base, i-1), math.Pow(base, i); lo >= 3 || hi < 3 {
+		t.Errorf("Got bucket (%v, %v], expecting it to hold 3", lo, hi)
+	}
+
+	coarse.Record(1)
+	coarse.Record(1000)
+	coarse.Record(1000)
+	h = gather(t, "prom_exp_coarse").GetMetric()[0].GetHistogram()
+	if h.GetSchema() != -4 {
+		t.Errorf("Got schema %d, expecting the scale to decrease to fit 2 buckets", h.GetSchema())
+	}
+	if d := h.GetPositiveDelta(); len(d) != 2 || d[0] != 1 || d[1] != 1 {
+		t.Errorf("Got deltas %v, expecting buckets of 1 and 2 observations", d)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	ocmetric "go.opencensus.io/metric"
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/metric/metricproducer"
+	"go.opencensus.io/stats/view"
+)
+
+var (
+	exporterOnce     sync.Once
+	exporterGatherer prometheus.Gatherer
+
+	// ocViews holds the views registered by the Metrics of the OpenCensus backend, by name.
+	ocViews sync.Map
+)
+
+// ExporterHandler returns an http.Handler serving the current values of the metrics in the Prometheus
+// text format, or in the OpenMetrics format when the scraper accepts it, such that small tools can expose
+// their metrics without setting up an exporter and a registry. It serves the metrics of the OpenCensus
+// and Prometheus backends, along with those of the default Prometheus registry. The metrics of the
+// OpenTelemetry backend are served by the readers of the meter provider instead, such as the
+// OpenTelemetry Prometheus exporter.
+func ExporterHandler() http.Handler {
+	exporterOnce.Do(func() {
+		r := prometheus.NewRegistry()
+		r.MustRegister(ocCollector{})
+		exporterGatherer = prometheus.Gatherers{prometheus.DefaultGatherer, r}
+	})
+
+	return promhttp.HandlerFor(exporterGatherer, promhttp.HandlerOpts{
+		ErrorHandling:     promhttp.ContinueOnError,
+		EnableOpenMetrics: true,
+	})
+}
+
+// ocCollector collects the views of the OpenCensus backend, its Int64Counters and the metrics of the
+// OpenCensus registries, such as those of its derived gauges, when Prometheus scrapes them. It
+// describes no metrics, as the views are registered and unregistered over time.
+type ocCollector struct{}
+
+func (ocCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (ocCollector) Collect(ch chan<- prometheus.Metric) {
+	ocViews.Range(func(_, v interface{}) bool {
+		collectOpenCensusView(ch, v.(*view.View))
+		return true
+	})
+
+	// the views are retrieved from their worker rather than read from its producer, which races with
+	// the recording of their values
+	for _, p := range metricproducer.GlobalManager().GetAll() {
+		switch p.(type) {
+		case *ocmetric.Registry, *ocInt64Exporter:
+			for _, m := range p.Read() {
+				collectOpenCensusMetric(ch, m)
+			}
+		}
+	}
+}
+
+func collectOpenCensusView(ch chan<- prometheus.Metric, v *view.View) {
+	rows, err := view.RetrieveData(v.Name)
+	if err != nil {
+		return
+	}
+
+	labelNames := make([]string, len(v.TagKeys))
+	for i, k := range v.TagKeys {
+		labelNames[i] = k.Name()
+	}
+	desc := prometheus.NewDesc(promName(v.Name), v.Description, labelNames, nil)
+
+	for _, r := range rows {
+		values := make(map[string]string, len(r.Tags))
+		for _, t := range r.Tags {
+			values[t.Key.Name()] = t.Value
+		}
+		labelValues := orderLabelValues(values, labelNames)
+
+		var (
+			metric prometheus.Metric
+			err    error
+		)
+		switch d := r.Data.(type) {
+		case *view.SumData:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, d.Value, labelValues...)
+		case *view.CountData:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, float64(d.Value), labelValues...)
+		case *view.LastValueData:
+			metric, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, d.Value, labelValues...)
+		case *view.DistributionData:
+			buckets := cumulativeBuckets(v.Aggregation.Buckets, d.CountPerBucket)
+			metric, err = prometheus.NewConstHistogram(desc, uint64(d.Count), d.Mean*float64(d.Count), buckets, labelValues...)
+		default:
+			continue
+		}
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(desc, err)
+			continue
+		}
+		ch <- metric
+	}
+}
+
+func collectOpenCensusMetric(ch chan<- prometheus.Metric, m *metricdata.Metric) {
+	desc := prometheus.NewDesc(promName(m.Descriptor.Name), m.Descriptor.Description, m.Descriptor.LabelKeys, nil)
+	for _, ts := range m.TimeSeries {
+		if len(ts.Points) == 0 {
+			continue
+		}
+
+		labelValues := make([]string, len(ts.LabelValues))
+		for i, lv := range ts.LabelValues {
+			labelValues[i] = lv.Value
+		}
+
+		var (
+			metric prometheus.Metric
+			err    error
+		)
+		switch v := ts.Points[len(ts.Points)-1].Value.(type) {
+		case int64:
+			metric, err = prometheus.NewConstMetric(desc, valueType(m.Descriptor.Type), float64(v), labelValues...)
+		case float64:
+			metric, err = prometheus.NewConstMetric(desc, valueType(m.Descriptor.Type), v, labelValues...)
+		case *metricdata.Distribution:
+			var bounds []float64
+			if v.BucketOptions != nil {
+				bounds = v.BucketOptions.Bounds
+			}
+			counts := make([]int64, len(v.Buckets))
+			for i, b := range v.Buckets {
+				counts[i] = b.Count
+			}
+			metric, err = prometheus.NewConstHistogram(desc, uint64(v.Count), v.Sum, cumulativeBuckets(bounds, counts), labelValues...)
+		case *metricdata.Summary:
+			quantiles := make(map[float64]float64, len(v.Snapshot.Percentiles))
+			for p, q := range v.Snapshot.Percentiles {
+				quantiles[p/100] = q
+			}
+			metric, err = prometheus.NewConstSummary(desc, uint64(v.Count), v.Sum, quantiles, labelValues...)
+		default:
+			continue
+		}
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(desc, err)
+			continue
+		}
+		ch <- metric
+	}
+}
+
+func valueType(t metricdata.Type) prometheus.ValueType {
+	if t == metricdata.TypeCumulativeInt64 || t == metricdata.TypeCumulativeFloat64 {
+		return prometheus.CounterValue
+	}
+	return prometheus.GaugeValue
+}
+
+// cumulativeBuckets returns the number of observations up to each bound of a distribution, as Prometheus
+// counts them, from the number of observations of each bucket. The observations above the last bound are
+// counted in the +Inf bucket.
+func cumulativeBuckets(bounds []float64, counts []int64) map[float64]uint64 {
+	buckets := make(map[float64]uint64, len(bounds))
+	var count uint64
+	for i, bound := range bounds {
+		if i < len(counts) {
+			count += uint64(counts[i])
+		}
+		buckets[bound] = count
+	}
+	return buckets
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"istio.io/pkg/monitoring"
+)
+
+func TestExporterHandler(t *testing.T) {
+	sum := monitoring.NewSum("exported_events_total", "Number of events", monitoring.WithLabels(name))
+	dist := monitoring.NewDistribution("exported_sizes", "Sizes", []float64{1, 10})
+	monitoring.MustRegister(sum, dist)
+	defer sum.Unregister()
+	defer dist.Unregister()
+	depth := monitoring.NewDerivedGauge("exported_depth", "Depth", func() float64 { return 4 })
+	defer depth.Unregister()
+
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	gauge := monitoring.NewGauge("exported_prom_gauge", "Gauge")
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(gauge)
+	defer gauge.Unregister()
+
+	sum.With(name.Value("foo")).Increment()
+	dist.Record(5)
+	dist.Record(50)
+	gauge.Record(3)
+
+	s := httptest.NewServer(monitoring.ExporterHandler())
+	defer s.Close()
+
+	expected := []string{
+		`exported_events_total{name="foo"} 1`,
+		`exported_sizes_bucket{le="1"} 0`,
+		`exported_sizes_bucket{le="10"} 1`,
+		`exported_sizes_bucket{le="+Inf"} 2`,
+		`exported_sizes_sum 55`,
+		`exported_prom_gauge 3`,
+		`exported_depth 4`,
+	}
+	err := retry(func() error {
+		body, err := scrape(s.URL)
+		if err != nil {
+			return err
+		}
+		for _, e := range expected {
+			if !strings.Contains(body, e) {
+				return fmt.Errorf("got %s, expecting %s", body, e)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func scrape(url string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import "sync"
+
+// gaugeValues holds the current values of a gauge by combination of label values, for the backends
+// whose gauges only record the last value, such that values can be added to them atomically. The values
+// are allocated once the gauge is recorded.
+type gaugeValues struct {
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newGaugeValues() *gaugeValues {
+	return &gaugeValues{}
+}
+
+// set sets the value of a combination of label values. The value is recorded while holding the lock,
+// such that the last value recorded is the current one.
+func (g *gaugeValues) set(key string, value float64, record func(value float64)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.values == nil {
+		g.values = make(map[string]float64)
+	}
+	g.values[key] = value
+	record(value)
+}
+
+// add adds delta to the value of a combination of label values, and records the sum.
+func (g *gaugeValues) add(key string, delta float64, record func(value float64)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.values == nil {
+		g.values = make(map[string]float64)
+	}
+	value := g.values[key] + delta
+	g.values[key] = value
+	record(value)
+}
+
+// reset drops the values.
+func (g *gaugeValues) reset() {
+	g.mu.Lock()
+	g.values = nil
+	g.mu.Unlock()
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"sync"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+
+	"istio.io/pkg/monitoring"
+)
+
+// addConcurrently adds 1 and subtracts 0.5 from the gauge 100 times concurrently, after setting it to 10.
+func addConcurrently(g monitoring.Metric) {
+	g.Record(10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Add(1)
+			g.Sub(0.5)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPrometheusGaugeAdd(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	g := monitoring.NewGauge("prom_connections", "Open connections", monitoring.WithLabels(name))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(g)
+
+	addConcurrently(g.With(name.Value("foo")))
+	if v := gather(t, "prom_connections").GetMetric()[0].GetGauge().GetValue(); v != 60 {
+		t.Errorf("Got %v, expecting 60", v)
+	}
+}
+
+func TestOpenCensusGaugeAdd(t *testing.T) {
+	g := monitoring.NewGauge("oc_connections", "Open connections", monitoring.WithLabels(name))
+	monitoring.MustRegister(g)
+
+	addConcurrently(g.With(name.Value("foo")))
+	g.With(name.Value("bar")).Add(2)
+
+	rows, err := view.RetrieveData("oc_connections")
+	if err != nil {
+		t.Fatalf("Unable to retrieve the gauge: %v", err)
+	}
+	expected := map[string]float64{"foo": 60, "bar": 2}
+	if len(rows) != len(expected) {
+		t.Fatalf("Got %v, expecting the rows of foo and bar", rows)
+	}
+	for _, r := range rows {
+		for n, v := range expected {
+			if findTagWithValue("name", n, r.Tags) && r.Data.(*view.LastValueData).Value != v {
+				t.Errorf("Got %v for %v, expecting %v", r.Data, r.Tags, v)
+			}
+		}
+	}
+}
+
+func TestOpenCensusGaugeAllocations(t *testing.T) {
+	g := monitoring.NewGauge("oc_allocs_gauge", "Open connections", monitoring.WithLabels(name, kind))
+	s := monitoring.NewSum("oc_allocs_total", "Connections", monitoring.WithLabels(name, kind))
+	monitoring.MustRegister(g, s)
+	gauge := g.With(name.Value("foo"), kind.Value("in"))
+	sum := s.With(name.Value("foo"), kind.Value("in"))
+
+	// tracking the current values of gauges doesn't allocate more at each record
+	allocs := testing.AllocsPerRun(100, func() { gauge.Record(1) })
+	expected := testing.AllocsPerRun(100, func() { sum.Record(1) })
+	if allocs > expected {
+		t.Errorf("Got %v allocations recording the gauge, expecting at most the %v of a sum", allocs, expected)
+	}
+	if allocs = testing.AllocsPerRun(100, func() { gauge.Add(1) }); allocs > expected {
+		t.Errorf("Got %v allocations adding to the gauge, expecting at most the %v of a sum", allocs, expected)
+	}
+}
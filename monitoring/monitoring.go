@@ -17,6 +17,10 @@ package monitoring
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
@@ -38,12 +42,41 @@ type (
 		// this is equivalent to making an observation of value -1.
 		Decrement()
 
+		// Add adds delta to the current value of the measure. For Sums, this is equivalent to
+		// Record. For Gauges, this atomically adds delta to the last value, such that gauges of
+		// current things, such as open connections, can be maintained concurrently. For
+		// Distributions, this is equivalent to making an observation of delta.
+		Add(delta float64)
+
+		// Sub subtracts delta from the current value of the measure, as Add does with -delta.
+		Sub(delta float64)
+
 		// Name returns the name value of a Metric.
 		Name() string
 
 		// Record makes an observation of the provided value for the given measure.
 		Record(value float64)
 
+		// RecordWithContext makes an observation of the provided value for the given measure,
+		// attaching the trace and span carried by the context to the observation as an exemplar
+		// when the Metric is a Distribution. This lets dashboards link the buckets of a
+		// distribution to example traces. The LabelValues the registered ContextExtractors
+		// supply for the context are added to those of the Metric.
+		RecordWithContext(ctx context.Context, value float64)
+
+		// Start starts a Stopwatch, whose Stop records the duration elapsed since into the
+		// Metric, converted to its unit: in milliseconds for Milliseconds, and in seconds for
+		// Seconds or no unit. Metrics of other units, such as Bytes, record nothing, and the
+		// duration only increments monitoring_unit_mismatches_total.
+		//
+		//	sw := requestDuration.Start()
+		//	defer sw.Stop()
+		Start() Stopwatch
+
+		// Time calls fn and records the duration of the call into the Metric, as Start does. Nothing
+		// is recorded into Metrics whose unit isn't a duration, see Start.
+		Time(fn func())
+
 		// With creates a new Metric, with the LabelValues provided. This allows creating
 		// a set of pre-dimensioned data for recording purposes. This is primarily used
 		// for documentation and convenience. Metrics created with this method do not need
@@ -53,6 +86,18 @@ type (
 		// Register configures the Metric for export. It MUST be called before collection
 		// of values for the Metric. An error will be returned if registration fails.
 		Register() error
+
+		// Unregister stops the export of the Metric and drops the values collected so far, for all
+		// the LabelValues. The Metric can be registered again afterwards. This is not supported by the
+		// OpenTelemetry backend, as instruments can't be removed from meters.
+		Unregister()
+
+		// Delete drops the values collected for the LabelValues of the Metric, as well as for
+		// all the combinations of LabelValues including them, such that they stop being exported
+		// until values are recorded again. This lets long-running processes stop exporting series of
+		// entities which are gone, such as disconnected proxies. This is only supported by the
+		// Prometheus backend.
+		Delete()
 	}
 
 	// Options encode changes to the options passed to a Metric at creation time.
@@ -66,8 +111,14 @@ type (
 	LabelValue tag.Mutator
 
 	options struct {
-		unit   Unit
-		labels []Label
+		unit           Unit
+		labels         []Label
+		exponential    bool
+		maxBuckets     int
+		maxCardinality int
+		maxAge         time.Duration
+		ageBuckets     uint32
+		expiry         time.Duration
 	}
 )
 
@@ -79,6 +130,18 @@ func WithLabels(labels ...Label) Options {
 	}
 }
 
+// WithExpiry provides configuration options for a new Metric, such that the values of the
+// combinations of LabelValues which haven't been recorded for the given duration stop being exported,
+// and their state is freed. This keeps the exported series and the memory proportional to the active
+// entities, rather than to all those seen since the process started. This is only supported by the
+// Prometheus backend, as OpenCensus views and OpenTelemetry instruments can't drop values, and not
+// for summaries.
+func WithExpiry(expiry time.Duration) Options {
+	return func(opts *options) {
+		opts.expiry = expiry
+	}
+}
+
 // WithUnit provides configuration options for a new Metric, providing unit of measure
 // information for a new Metric.
 func WithUnit(unit Unit) Options {
@@ -89,7 +152,66 @@ func WithUnit(unit Unit) Options {
 
 // Value creates a new LabelValue for the Label.
 func (l Label) Value(value string) LabelValue {
-	return tag.Upsert(tag.Key(l), value)
+	return labelValue{Mutator: tag.Upsert(tag.Key(l), value), name: tag.Key(l).Name(), value: value}
+}
+
+// labelValue is the LabelValue of a Label, which holds the name and value of the Label for the
+// backends which don't record metrics through OpenCensus tags.
+type labelValue struct {
+	tag.Mutator
+	name  string
+	value string
+}
+
+// labelNames returns the names of the Labels of LabelValues, for those created with Label.Value.
+func labelNames(lvs []LabelValue) []string {
+	var names []string
+	for _, lv := range lvs {
+		if v, ok := lv.(labelValue); ok {
+			names = append(names, v.name)
+		}
+	}
+	return names
+}
+
+// resolveLabelValues returns the values LabelValues set for the Labels with the given names.
+func resolveLabelValues(lvs []LabelValue, names []string) map[string]string {
+	values := make(map[string]string, len(lvs))
+	mutators := make([]tag.Mutator, 0, len(lvs))
+	resolved := true
+	for _, lv := range lvs {
+		v, ok := lv.(labelValue)
+		resolved = resolved && ok
+		if ok {
+			values[v.name] = v.value
+		}
+		mutators = append(mutators, tag.Mutator(lv))
+	}
+	if resolved {
+		return values
+	}
+
+	// other LabelValues are only known through the tags they set
+	values = make(map[string]string, len(names))
+	if ctx, err := tag.New(context.Background(), mutators...); err == nil {
+		m := tag.FromContext(ctx)
+		for _, n := range names {
+			k, _ := tag.NewKey(n)
+			if v, ok := m.Value(k); ok {
+				values[n] = v
+			}
+		}
+	}
+	return values
+}
+
+// orderLabelValues returns the values of the Labels with the given names, in order.
+func orderLabelValues(values map[string]string, names []string) []string {
+	ordered := make([]string, len(names))
+	for i, n := range names {
+		ordered[i] = values[n]
+	}
+	return ordered
 }
 
 // MustCreateLabel will attempt to create a new Label. If
@@ -126,11 +248,60 @@ func NewGauge(name, description string, opts ...Options) Metric {
 
 // NewDistribution creates a new Metric with an aggregration type of Distribution. This means that the
 // data collected by the Metric will be collected and exported as a histogram, with the specified bounds.
+// Distributions created without bounds have the DefaultBounds of their unit, such that the
+// distributions of the same unit have consistent buckets.
 func NewDistribution(name, description string, bounds []float64, opts ...Options) Metric {
+	if len(bounds) == 0 {
+		bounds = DefaultBounds(createOptions(opts...).unit)
+	}
 	return newMetric(name, description, view.Distribution(bounds...), opts...)
 }
 
+// Backend is an implementation of the metrics of the package.
+type Backend string
+
+const (
+	// OpenCensusBackend records metrics through OpenCensus views, which are exported by the view
+	// exporters registered with OpenCensus. This is the default.
+	OpenCensusBackend Backend = "opencensus"
+
+	// PrometheusBackend records metrics directly as Prometheus collectors registered with the default
+	// Prometheus registerer, which avoids the overhead of OpenCensus views.
+	PrometheusBackend Backend = "prometheus"
+
+	// OpenTelemetryBackend records metrics as instruments of the global OpenTelemetry meter provider,
+	// which exports them through the readers it is configured with, such as those of the OTLP and
+	// Prometheus exporters. Instruments created before the meter provider is set are forwarded to it.
+	OpenTelemetryBackend Backend = "opentelemetry"
+)
+
+// BackendEnvVar is the environment variable selecting the backend of metrics when the process starts.
+const BackendEnvVar = "ISTIO_MONITORING_BACKEND"
+
+var backend atomic.Value
+
+func init() {
+	b := Backend(os.Getenv(BackendEnvVar))
+	if b != PrometheusBackend && b != OpenTelemetryBackend {
+		b = OpenCensusBackend
+	}
+	backend.Store(b)
+}
+
+// SetBackend selects the backend of the metrics created afterwards. As metrics are usually created
+// when packages are initialized, this must be called from the init function of a package initialized
+// before those defining metrics, otherwise the backend should be selected through BackendEnvVar.
+func SetBackend(b Backend) {
+	backend.Store(b)
+}
+
 func newMetric(name, description string, aggregation *view.Aggregation, opts ...Options) Metric {
+	switch backend.Load().(Backend) {
+	case PrometheusBackend:
+		return newPrometheusMetric(name, description, aggregation, opts...)
+	case OpenTelemetryBackend:
+		return newOpenTelemetryMetric(name, description, aggregation, opts...)
+	}
 	return newFloat64Metric(name, description, aggregation, opts...)
 }
 
@@ -139,6 +310,16 @@ type float64Metric struct {
 
 	tags []tag.Mutator
 	view *view.View
+
+	// the tags are replaced by the overflow tags when they exceed the maximum cardinality, which is
+	// checked on the first record
+	limit    *cardinalityLimit
+	admitted atomic.Value
+
+	// the current values of gauges, which views only hold the last recorded value of, and the key of
+	// the series of the metric among them, computed once rather than at each record
+	gauge  *gaugeValues
+	series string
 }
 
 func createOptions(opts ...Options) *options {
@@ -156,11 +337,17 @@ func newFloat64Metric(name, description string, aggregation *view.Aggregation, o
 	for _, l := range o.labels {
 		tagKeys = append(tagKeys, tag.Key(l))
 	}
-	return &float64Metric{
-		measure,
-		make([]tag.Mutator, 0),
-		&view.View{Measure: measure, TagKeys: tagKeys, Aggregation: aggregation},
+	f := &float64Metric{
+		Float64Measure: measure,
+		tags:           make([]tag.Mutator, 0),
+		view:           &view.View{Measure: measure, TagKeys: tagKeys, Aggregation: aggregation},
+		limit:          newCardinalityLimit(o.maxCardinality),
+	}
+	if aggregation.Type == view.AggTypeLastValue {
+		f.gauge = newGaugeValues()
+		f.series = f.seriesKey()
 	}
+	return f
 }
 
 func (f *float64Metric) Increment() {
@@ -176,7 +363,130 @@ func (f *float64Metric) Name() string {
 }
 
 func (f *float64Metric) Record(value float64) {
-	stats.RecordWithTags(context.Background(), f.tags, f.M(value)) //nolint:errcheck
+	f.recordWithContext(context.Background(), value)
+}
+
+// RecordWithContext records the value along with the tags of the context, as OpenCensus doesn't
+// support exemplars yet.
+func (f *float64Metric) RecordWithContext(ctx context.Context, value float64) {
+	f.withContext(ctx).recordWithContext(ctx, value)
+}
+
+func (f *float64Metric) recordWithContext(ctx context.Context, value float64) {
+	record := func(value float64) {
+		stats.RecordWithTags(ctx, f.recordedTags(), f.M(value)) //nolint:errcheck
+	}
+
+	if f.gauge != nil {
+		f.gauge.set(f.series, value, record)
+		return
+	}
+	record(value)
+}
+
+func (f *float64Metric) Add(delta float64) {
+	if f.gauge != nil {
+		f.gauge.add(f.series, delta, func(value float64) {
+			stats.RecordWithTags(context.Background(), f.recordedTags(), f.M(value)) //nolint:errcheck
+		})
+		return
+	}
+	f.Record(delta)
+}
+
+func (f *float64Metric) Sub(delta float64) {
+	f.Add(-delta)
+}
+
+// withContext returns the Metric recording the values of a context, whose tags are those of the
+// extractors of the context overridden by those of f.
+func (f *float64Metric) withContext(ctx context.Context) *float64Metric {
+	lvs := contextLabelValues(ctx)
+	if len(lvs) == 0 {
+		return f
+	}
+
+	t := make([]tag.Mutator, 0, len(lvs)+len(f.tags))
+	for _, lv := range lvs {
+		t = append(t, tag.Mutator(lv))
+	}
+	return f.withTags(append(t, f.tags...))
+}
+
+// withTags returns a Metric sharing the measure and view of f, which records values with tags.
+func (f *float64Metric) withTags(tags []tag.Mutator) *float64Metric {
+	m := &float64Metric{
+		Float64Measure: f.Float64Measure,
+		tags:           tags,
+		view:           f.view,
+		limit:          f.limit,
+		gauge:          f.gauge,
+	}
+	if m.gauge != nil {
+		m.series = m.seriesKey()
+	}
+	return m
+}
+
+// seriesKey returns the key of the series of the values of f among those of its gauge.
+func (f *float64Metric) seriesKey() string {
+	return strings.Join(f.labelValues(), "\xff")
+}
+
+// labelValues returns the values of the labels set by the tags, in the order of the labels.
+func (f *float64Metric) labelValues() []string {
+	lvs := make([]LabelValue, len(f.tags))
+	for i, t := range f.tags {
+		lvs[i] = LabelValue(t)
+	}
+	names := f.tagNames()
+	return orderLabelValues(resolveLabelValues(lvs, names), names)
+}
+
+// tagNames returns the names of the Labels of the Metric.
+func (f *float64Metric) tagNames() []string {
+	names := make([]string, len(f.view.TagKeys))
+	for i, k := range f.view.TagKeys {
+		names[i] = k.Name()
+	}
+	return names
+}
+
+// recordedTags returns the tags the values are recorded with, which are the overflow tags when the
+// label values exceed the maximum cardinality of the Metric.
+func (f *float64Metric) recordedTags() []tag.Mutator {
+	if d := diagnosing(); d != nil {
+		d.observe(f.Name(), f.tagNames(), f.labelValues())
+	}
+	if f.limit == nil {
+		return f.tags
+	}
+
+	admitted, ok := f.admitted.Load().(bool)
+	if !ok {
+		admitted = f.limit.admit(f.labelValues())
+		f.admitted.Store(admitted)
+	}
+	if admitted {
+		return f.tags
+	}
+
+	recordOverflow(f.Name())
+	return f.limit.overflowState(func() interface{} {
+		tags := make([]tag.Mutator, 0, len(f.view.TagKeys))
+		for _, k := range f.view.TagKeys {
+			tags = append(tags, tag.Upsert(k, OverflowLabelValue))
+		}
+		return tags
+	}).([]tag.Mutator)
+}
+
+func (f *float64Metric) Start() Stopwatch {
+	return startStopwatch(f, Unit(f.Unit()))
+}
+
+func (f *float64Metric) Time(fn func()) {
+	timeFunc(f, Unit(f.Unit()), fn)
 }
 
 func (f *float64Metric) With(labelValues ...LabelValue) Metric {
@@ -185,9 +495,25 @@ func (f *float64Metric) With(labelValues ...LabelValue) Metric {
 	for _, tagValue := range labelValues {
 		t = append(t, tag.Mutator(tagValue))
 	}
-	return &float64Metric{f.Float64Measure, t, f.view}
+	return f.withTags(t)
 }
 
 func (f *float64Metric) Register() error {
-	return view.Register(f.view)
+	if err := view.Register(f.view); err != nil {
+		return err
+	}
+	ocViews.Store(f.view.Name, f.view)
+	return nil
+}
+
+func (f *float64Metric) Unregister() {
+	view.Unregister(f.view)
+	ocViews.Delete(f.view.Name)
+	if f.gauge != nil {
+		f.gauge.reset()
+	}
+}
+
+// Delete does nothing, as OpenCensus views can't drop rows.
+func (f *float64Metric) Delete() {
 }
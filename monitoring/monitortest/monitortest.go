@@ -0,0 +1,213 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package monitortest reads the values of the metrics of the monitoring package in tests, without
+// standing up an exporter and scraping its output.
+package monitortest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opencensus.io/stats/view"
+)
+
+const (
+	defaultTimeout = 3 * time.Second
+	retryInterval  = 10 * time.Millisecond
+)
+
+// Test reads the values of metrics during a test. The values are those of the metrics recorded with the
+// OpenCensus and Prometheus backends, as those of the OpenTelemetry backend are only known to the readers
+// of the meter provider.
+type Test struct {
+	t       testing.TB
+	timeout time.Duration
+}
+
+// New creates a Test reading the values of metrics for t.
+func New(t testing.TB) *Test {
+	return &Test{t: t, timeout: defaultTimeout}
+}
+
+// WithTimeout returns a Test whose assertions retry for the given duration before failing, which
+// defaults to 3 seconds.
+func (m *Test) WithTimeout(timeout time.Duration) *Test {
+	return &Test{t: m.t, timeout: timeout}
+}
+
+// Compare returns an error unless the value of a metric is as expected.
+type Compare func(value float64) error
+
+// Exactly compares the value of a metric with v.
+func Exactly(v float64) Compare {
+	return func(value float64) error {
+		if value != v {
+			return fmt.Errorf("got %v, expecting exactly %v", value, v)
+		}
+		return nil
+	}
+}
+
+// AtLeast compares the value of a metric with a minimum of v.
+func AtLeast(v float64) Compare {
+	return func(value float64) error {
+		if value < v {
+			return fmt.Errorf("got %v, expecting at least %v", value, v)
+		}
+		return nil
+	}
+}
+
+// Value returns the current value of a metric for the series whose labels include the given ones, and
+// whether there is any. The value of a Sum is its total, of a Gauge its last value, and of distributions
+// and summaries their number of observations. The values of the series matching the labels are summed.
+func (m *Test) Value(name string, labels map[string]string) (float64, bool) {
+	if v, ok, err := openCensusValue(name, labels); err == nil {
+		return v, ok
+	}
+	return prometheusValue(name, labels)
+}
+
+// Assert fails the test unless the value of a metric for the given labels compares as expected, which is
+// retried until the timeout of the Test, as values are recorded asynchronously.
+func (m *Test) Assert(name string, labels map[string]string, compare Compare) {
+	m.t.Helper()
+
+	deadline := time.Now().Add(m.timeout)
+	for {
+		err := fmt.Errorf("no value for %s%v", name, labels)
+		if v, ok := m.Value(name, labels); ok {
+			err = compare(v)
+		}
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			m.t.Errorf("Metric %s%v: %v", name, labels, err)
+			return
+		}
+		time.Sleep(retryInterval)
+	}
+}
+
+// AssertMissing fails the test if the metric has a value for the given labels.
+func (m *Test) AssertMissing(name string, labels map[string]string) {
+	m.t.Helper()
+	if v, ok := m.Value(name, labels); ok {
+		m.t.Errorf("Metric %s%v: got %v, expecting no value", name, labels, v)
+	}
+}
+
+// openCensusValue returns the value of the view of a metric, or an error if there is no such view.
+func openCensusValue(name string, labels map[string]string) (float64, bool, error) {
+	rows, err := view.RetrieveData(name)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var value float64
+	var found bool
+	for _, r := range rows {
+		tags := make(map[string]string, len(r.Tags))
+		for _, t := range r.Tags {
+			tags[t.Key.Name()] = t.Value
+		}
+		if !matches(tags, labels) {
+			continue
+		}
+
+		found = true
+		switch d := r.Data.(type) {
+		case *view.SumData:
+			value += d.Value
+		case *view.CountData:
+			value += float64(d.Value)
+		case *view.LastValueData:
+			value += d.Value
+		case *view.DistributionData:
+			value += float64(d.Count)
+		}
+	}
+	return value, found, nil
+}
+
+// prometheusValue returns the value of a metric gathered by the default Prometheus gatherer.
+func prometheusValue(name string, labels map[string]string) (float64, bool) {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return 0, false
+	}
+
+	name = promName(name)
+	var value float64
+	var found bool
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+
+		for _, metric := range f.GetMetric() {
+			tags := make(map[string]string, len(metric.GetLabel()))
+			for _, l := range metric.GetLabel() {
+				tags[l.GetName()] = l.GetValue()
+			}
+			if !matches(tags, labels) {
+				continue
+			}
+
+			found = true
+			value += promValue(metric)
+		}
+	}
+	return value, found
+}
+
+func promValue(m *dto.Metric) float64 {
+	switch {
+	case m.Counter != nil:
+		return m.GetCounter().GetValue()
+	case m.Gauge != nil:
+		return m.GetGauge().GetValue()
+	case m.Histogram != nil:
+		return float64(m.GetHistogram().GetSampleCount())
+	case m.Summary != nil:
+		return float64(m.GetSummary().GetSampleCount())
+	}
+	return m.GetUntyped().GetValue()
+}
+
+func matches(tags, labels map[string]string) bool {
+	for k, v := range labels {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// promName replaces the characters Prometheus doesn't allow in metric names by underscores, as the
+// Prometheus backend does.
+func promName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitortest_test
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/pkg/monitoring"
+	"istio.io/pkg/monitoring/monitortest"
+)
+
+var (
+	name = monitoring.MustCreateLabel("name")
+
+	events = monitoring.NewSum("monitortest_events_total", "Number of events", monitoring.WithLabels(name))
+	sizes  = monitoring.NewDistribution("monitortest_sizes", "Sizes", []float64{1, 10}, monitoring.WithLabels(name))
+)
+
+func init() {
+	monitoring.MustRegister(events, sizes)
+}
+
+func TestOpenCensus(t *testing.T) {
+	mt := monitortest.New(t)
+
+	events.With(name.Value("foo")).Increment()
+	events.With(name.Value("foo")).Increment()
+	events.With(name.Value("bar")).Increment()
+	sizes.With(name.Value("foo")).Record(5)
+
+	mt.Assert("monitortest_events_total", map[string]string{"name": "foo"}, monitortest.Exactly(2))
+	mt.Assert("monitortest_events_total", nil, monitortest.Exactly(3))
+	mt.Assert("monitortest_sizes", map[string]string{"name": "foo"}, monitortest.AtLeast(1))
+	mt.AssertMissing("monitortest_events_total", map[string]string{"name": "baz"})
+}
+
+func TestPrometheus(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	gauge := monitoring.NewGauge("monitortest.gauge", "Gauge", monitoring.WithLabels(name))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(gauge)
+
+	gauge.With(name.Value("foo")).Record(7)
+
+	mt := monitortest.New(t)
+	mt.Assert("monitortest.gauge", map[string]string{"name": "foo"}, monitortest.Exactly(7))
+	if v, ok := mt.Value("monitortest.gauge", map[string]string{"name": "bar"}); ok {
+		t.Errorf("Got %v, expecting no value for bar", v)
+	}
+}
+
+func TestAssertFails(t *testing.T) {
+	ft := &failures{TB: t}
+	mt := monitortest.New(ft).WithTimeout(20 * time.Millisecond)
+
+	mt.Assert("monitortest_missing", nil, monitortest.Exactly(1))
+	events.With(name.Value("qux")).Increment()
+	mt.Assert("monitortest_events_total", map[string]string{"name": "qux"}, monitortest.AtLeast(2))
+	mt.AssertMissing("monitortest_events_total", map[string]string{"name": "qux"})
+
+	if ft.count != 3 {
+		t.Errorf("Got %d failures, expecting 3", ft.count)
+	}
+}
+
+// failures counts the failures of a test instead of failing it.
+type failures struct {
+	testing.TB
+	count int
+}
+
+func (f *failures) Errorf(string, ...interface{}) {
+	f.count++
+}
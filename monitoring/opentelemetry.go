@@ -0,0 +1,287 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// otelMeterName is the name of the meter the instruments of the OpenTelemetry backend are created with.
+const otelMeterName = "istio.io/pkg/monitoring"
+
+// otelExponential holds the maximum number of buckets of the exponential distributions, by name.
+var otelExponential sync.Map
+
+// OpenTelemetryView is a view of the OpenTelemetry SDK aggregating the exponential distributions of the
+// OpenTelemetry backend as exponential histograms, which can't be selected when creating instruments.
+// Meter providers exporting exponential distributions must be created with this view, as in
+// sdkmetric.NewMeterProvider(sdkmetric.WithView(monitoring.OpenTelemetryView), ...).
+func OpenTelemetryView(i sdkmetric.Instrument) (sdkmetric.Stream, bool) {
+	if i.Scope.Name != otelMeterName {
+		return sdkmetric.Stream{}, false
+	}
+
+	maxBuckets, ok := otelExponential.Load(i.Name)
+	if !ok {
+		return sdkmetric.Stream{}, false
+	}
+
+	return sdkmetric.Stream{
+		Name:        i.Name,
+		Description: i.Description,
+		Unit:        i.Unit,
+		Aggregation: sdkmetric.AggregationBase2ExponentialHistogram{
+			MaxSize:  int32(maxBuckets.(int)),
+			MaxScale: maxExponentialScale,
+		},
+	}, true
+}
+
+// otelInstrument records the values of a metric through an OpenTelemetry instrument.
+type otelInstrument struct {
+	name       string
+	unit       Unit
+	labelNames []string
+	record     func(ctx context.Context, value float64, opt metric.MeasurementOption)
+	err        error
+
+	limit *cardinalityLimit
+
+	// the current values of gauges, which instruments only hold the last recorded value of
+	gauge *gaugeValues
+}
+
+// otelMetric records the values of a metric with the attributes of its label values, or with the
+// overflow attributes when they exceed the maximum cardinality, which is checked on the first record.
+type otelMetric struct {
+	*otelInstrument
+	attrs    []attribute.KeyValue
+	opt      metric.MeasurementOption
+	admitted atomic.Value
+}
+
+func newOpenTelemetryMetric(name, description string, aggregation *view.Aggregation, opts ...Options) *otelMetric {
+	o := createOptions(opts...)
+	meter := otel.GetMeterProvider().Meter(otelMeterName)
+
+	i := &otelInstrument{name: name, unit: o.unit, limit: newCardinalityLimit(o.maxCardinality)}
+	for _, l := range o.labels {
+		i.labelNames = append(i.labelNames, tag.Key(l).Name())
+	}
+
+	switch aggregation.Type {
+	case view.AggTypeSum:
+		c, err := meter.Float64Counter(name, metric.WithDescription(description), metric.WithUnit(string(o.unit)))
+		i.err = err
+		i.record = func(ctx context.Context, value float64, opt metric.MeasurementOption) {
+			c.Add(ctx, value, opt)
+		}
+
+	case view.AggTypeDistribution:
+		histOpts := []metric.Float64HistogramOption{metric.WithDescription(description), metric.WithUnit(string(o.unit))}
+		if o.exponential {
+			maxBuckets := o.maxBuckets
+			if maxBuckets <= 0 {
+				maxBuckets = defaultMaxBuckets
+			}
+			otelExponential.Store(name, maxBuckets)
+		} else {
+			bounds := append([]float64(nil), aggregation.Buckets...)
+			sort.Float64s(bounds)
+			histOpts = append(histOpts, metric.WithExplicitBucketBoundaries(bounds...))
+		}
+		h, err := meter.Float64Histogram(name, histOpts...)
+		i.err = err
+		i.record = func(ctx context.Context, value float64, opt metric.MeasurementOption) {
+			h.Record(ctx, value, opt)
+		}
+
+	default:
+		g, err := meter.Float64Gauge(name, metric.WithDescription(description), metric.WithUnit(string(o.unit)))
+		i.err = err
+		i.gauge = newGaugeValues()
+		i.record = func(ctx context.Context, value float64, opt metric.MeasurementOption) {
+			g.Record(ctx, value, opt)
+		}
+	}
+
+	return &otelMetric{otelInstrument: i, opt: metric.WithAttributeSet(attribute.NewSet())}
+}
+
+func (m *otelMetric) Increment() {
+	m.Record(1)
+}
+
+func (m *otelMetric) Decrement() {
+	m.Record(-1)
+}
+
+func (m *otelMetric) Name() string {
+	return m.name
+}
+
+func (m *otelMetric) Record(value float64) {
+	m.recordWithContext(context.Background(), value)
+}
+
+// RecordWithContext passes the context to the instrument, whose exemplars depend on the configuration
+// of the OpenTelemetry SDK.
+func (m *otelMetric) RecordWithContext(ctx context.Context, value float64) {
+	m.withContext(ctx).recordWithContext(ctx, value)
+}
+
+func (m *otelMetric) recordWithContext(ctx context.Context, value float64) {
+	if m.record == nil {
+		return
+	}
+
+	opt := m.measurementOption()
+	if m.gauge != nil {
+		m.gauge.set(m.gaugeKey(), value, func(value float64) { m.record(ctx, value, opt) })
+		return
+	}
+	m.record(ctx, value, opt)
+}
+
+func (m *otelMetric) Add(delta float64) {
+	if m.gauge == nil {
+		m.Record(delta)
+		return
+	}
+	if m.record != nil {
+		opt := m.measurementOption()
+		m.gauge.add(m.gaugeKey(), delta, func(value float64) { m.record(context.Background(), value, opt) })
+	}
+}
+
+func (m *otelMetric) Sub(delta float64) {
+	m.Add(-delta)
+}
+
+// gaugeKey identifies the combination of attributes of the values of a gauge.
+func (m *otelMetric) gaugeKey() string {
+	return strings.Join(orderLabelValues(m.attributeValues(), m.labelNames), "\xff")
+}
+
+// attributeValues returns the values of the attributes, by name.
+func (m *otelMetric) attributeValues() map[string]string {
+	values := make(map[string]string, len(m.attrs))
+	for _, a := range m.attrs {
+		values[string(a.Key)] = a.Value.AsString()
+	}
+	return values
+}
+
+// withContext returns the metric recording the values of a context, whose attributes are those of the
+// extractors of the context overridden by those of m.
+func (m *otelMetric) withContext(ctx context.Context) *otelMetric {
+	lvs := contextLabelValues(ctx)
+	if len(lvs) == 0 {
+		return m
+	}
+
+	resolved := resolveLabelValues(lvs, m.labelNames)
+	attrs := make([]attribute.KeyValue, 0, len(resolved)+len(m.attrs))
+	for _, n := range m.labelNames {
+		if v, ok := resolved[n]; ok {
+			attrs = append(attrs, attribute.String(n, v))
+		}
+	}
+	attrs = append(attrs, m.attrs...)
+
+	return &otelMetric{
+		otelInstrument: m.otelInstrument,
+		attrs:          attrs,
+		opt:            metric.WithAttributeSet(attribute.NewSet(attrs...)),
+	}
+}
+
+// measurementOption returns the option holding the attributes the values are recorded with.
+func (m *otelMetric) measurementOption() metric.MeasurementOption {
+	if d := diagnosing(); d != nil {
+		d.observe(m.name, m.labelNames, orderLabelValues(m.attributeValues(), m.labelNames))
+	}
+	if m.limit == nil {
+		return m.opt
+	}
+
+	admitted, ok := m.admitted.Load().(bool)
+	if !ok {
+		admitted = m.limit.admit(orderLabelValues(m.attributeValues(), m.labelNames))
+		m.admitted.Store(admitted)
+	}
+	if admitted {
+		return m.opt
+	}
+
+	recordOverflow(m.name)
+	return m.limit.overflowState(func() interface{} {
+		attrs := make([]attribute.KeyValue, 0, len(m.labelNames))
+		for _, n := range m.labelNames {
+			attrs = append(attrs, attribute.String(n, OverflowLabelValue))
+		}
+		return metric.WithAttributeSet(attribute.NewSet(attrs...))
+	}).(metric.MeasurementOption)
+}
+
+func (m *otelMetric) Start() Stopwatch {
+	return startStopwatch(m, m.unit)
+}
+
+func (m *otelMetric) Time(fn func()) {
+	timeFunc(m, m.unit, fn)
+}
+
+func (m *otelMetric) With(labelValues ...LabelValue) Metric {
+	attrs := make([]attribute.KeyValue, len(m.attrs))
+	copy(attrs, m.attrs)
+	resolved := resolveLabelValues(labelValues, m.labelNames)
+	for _, n := range m.labelNames {
+		if v, ok := resolved[n]; ok {
+			attrs = append(attrs, attribute.String(n, v))
+		}
+	}
+
+	return &otelMetric{
+		otelInstrument: m.otelInstrument,
+		attrs:          attrs,
+		opt:            metric.WithAttributeSet(attribute.NewSet(attrs...)),
+	}
+}
+
+// Register returns the error creating the instrument, as instruments are exported by the meter provider
+// as soon as they are created.
+func (m *otelMetric) Register() error {
+	return m.err
+}
+
+// Unregister does nothing, as instruments can't be removed from meters.
+func (m *otelMetric) Unregister() {
+}
+
+// Delete does nothing, as instruments can't drop attribute sets.
+func (m *otelMetric) Delete() {
+}
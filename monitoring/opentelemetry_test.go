@@ -0,0 +1,140 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"istio.io/pkg/monitoring"
+)
+
+func TestOpenTelemetryBackend(t *testing.T) {
+	monitoring.SetBackend(monitoring.OpenTelemetryBackend)
+	sum := monitoring.NewSum("otel_events_total", "Number of events", monitoring.WithLabels(name, kind))
+	gauge := monitoring.NewGauge("otel_gauge", "Gauge")
+	dist := monitoring.NewDistribution("otel_buckets", "Distribution", []float64{10, 1, 5}, monitoring.WithUnit(monitoring.Seconds))
+	exp := monitoring.NewExponentialDistribution("otel_exp", "Exponential distribution", monitoring.WithMaxBuckets(20))
+	limited := monitoring.NewSum("otel_limited_total", "Number of events", monitoring.WithLabels(name), monitoring.WithMaxCardinality(1))
+	depth := 3.0
+	monitoring.NewDerivedGauge("otel_queue_depth", "Depth of the queue", func() float64 { return depth }, name.Value("foo"))
+	requests := monitoring.NewInt64Counter("otel_requests_total", "Number of requests", monitoring.WithLabels(name))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(sum, gauge, dist, exp, limited)
+	if err := requests.Register(); err != nil {
+		t.Fatalf("Unable to register the counter: %v", err)
+	}
+
+	// the instruments created before the meter provider is set are forwarded to it
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithView(monitoring.OpenTelemetryView)))
+
+	sum.With(kind.Value("goofy")).With(name.Value("baz")).Record(45)
+	sum.With(name.Value("foo"), kind.Value("bar")).Increment()
+	gauge.Record(42)
+	gauge.Record(75)
+	gauge.Add(3)
+	gauge.Sub(1)
+	dist.Record(0.5)
+	dist.Record(7)
+	exp.Record(1)
+	exp.Record(1000)
+
+	limited.With(name.Value("foo")).Increment()
+	limited.With(name.Value("bar")).Increment()
+	depth = 5
+	requests.With(name.Value("foo")).Increment()
+	requests.With(name.Value("foo")).Add(2)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Unable to collect the metrics: %v", err)
+	}
+
+	metrics := make(map[string]metricdata.Metrics)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+
+	s, ok := metrics["otel_events_total"].Data.(metricdata.Sum[float64])
+	if !ok || len(s.DataPoints) != 2 {
+		t.Fatalf("Got %v, expecting 2 data points for the sum", metrics["otel_events_total"])
+	}
+	for _, dp := range s.DataPoints {
+		expected := 1.0
+		if v, _ := dp.Attributes.Value(attribute.Key("kind")); v.AsString() == "goofy" {
+			expected = 45
+			if n, _ := dp.Attributes.Value(attribute.Key("name")); n.AsString() != "baz" {
+				t.Errorf("Got attributes %v, expecting name baz", dp.Attributes)
+			}
+		}
+		if dp.Value != expected {
+			t.Errorf("Got %v for %v, expecting %v", dp.Value, dp.Attributes, expected)
+		}
+	}
+
+	if g, ok := metrics["otel_gauge"].Data.(metricdata.Gauge[float64]); !ok || g.DataPoints[0].Value != 77 {
+		t.Errorf("Got %v, expecting the last value of the gauge", metrics["otel_gauge"])
+	}
+
+	h, ok := metrics["otel_buckets"].Data.(metricdata.Histogram[float64])
+	if !ok || h.DataPoints[0].Count != 2 || h.DataPoints[0].Sum != 7.5 {
+		t.Fatalf("Got %v, expecting 2 observations", metrics["otel_buckets"])
+	}
+	if b := h.DataPoints[0].Bounds; len(b) != 3 || b[0] != 1 || b[2] != 10 {
+		t.Errorf("Got bounds %v, expecting the sorted bounds", b)
+	}
+	if metrics["otel_buckets"].Unit != "s" {
+		t.Errorf("Got unit %s, expecting s", metrics["otel_buckets"].Unit)
+	}
+
+	e, ok := metrics["otel_exp"].Data.(metricdata.ExponentialHistogram[float64])
+	if !ok {
+		t.Fatalf("Got %v, expecting an exponential histogram", metrics["otel_exp"])
+	}
+	if dp := e.DataPoints[0]; dp.Count != 2 || dp.Scale > 8 || len(dp.PositiveBucket.Counts) > 20 {
+		t.Errorf("Got %+v, expecting 2 observations in at most 20 buckets of scale 8 or lower", dp)
+	}
+
+	d, ok := metrics["otel_queue_depth"].Data.(metricdata.Gauge[float64])
+	if !ok || len(d.DataPoints) != 1 || d.DataPoints[0].Value != 5 {
+		t.Fatalf("Got %v, expecting the value of the derived gauge when collected", metrics["otel_queue_depth"])
+	}
+	if v, _ := d.DataPoints[0].Attributes.Value(attribute.Key("name")); v.AsString() != "foo" {
+		t.Errorf("Got attributes %v, expecting name foo", d.DataPoints[0].Attributes)
+	}
+
+	l, ok := metrics["otel_limited_total"].Data.(metricdata.Sum[float64])
+	if !ok || len(l.DataPoints) != 2 {
+		t.Fatalf("Got %v, expecting the data points of foo and the overflow", metrics["otel_limited_total"])
+	}
+	for _, dp := range l.DataPoints {
+		if v, _ := dp.Attributes.Value(attribute.Key("name")); v.AsString() != "foo" && v.AsString() != monitoring.OverflowLabelValue {
+			t.Errorf("Got attributes %v, expecting name foo or %s", dp.Attributes, monitoring.OverflowLabelValue)
+		}
+	}
+
+	r, ok := metrics["otel_requests_total"].Data.(metricdata.Sum[int64])
+	if !ok || len(r.DataPoints) != 1 || r.DataPoints[0].Value != 3 || !r.IsMonotonic {
+		t.Errorf("Got %v, expecting the count of the counter", metrics["otel_requests_total"])
+	}
+}
@@ -0,0 +1,514 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// promFamily holds the series of a metric recorded with the Prometheus backend, one for each
+// combination of label values. It collects them directly, without going through OpenCensus views. The
+// series are allocated once they are recorded, such that the metrics which are registered but never
+// recorded only hold their description.
+type promFamily struct {
+	name           string
+	unit           Unit
+	aggType        view.AggType
+	bounds         []float64
+	exponential    bool
+	maxBuckets     int
+	maxCardinality int
+	expiry         time.Duration
+	labelNames     []string
+	desc           *prometheus.Desc
+
+	mu     sync.RWMutex
+	series map[string]*promSeries
+	swept  time.Time
+}
+
+// promSeries holds the value of a series, as the bits of a float64 for sums and gauges, or the
+// observations of distributions.
+type promSeries struct {
+	labelValues []string
+	bits        uint64
+	deleted     uint32
+	updated     int64
+	exp         *expHistogram
+
+	mu        sync.Mutex
+	count     uint64
+	sum       float64
+	counts    []uint64
+	exemplars []*prometheus.Exemplar
+}
+
+// promMetric records the values of a series of a family, which is looked up on the first record and
+// once it is deleted.
+type promMetric struct {
+	family      *promFamily
+	labelValues []string
+	labelSet    []bool
+	series      atomic.Value
+}
+
+// promLookup is the series of a promMetric, which is the overflow series when its label values exceed
+// the maximum cardinality of the family.
+type promLookup struct {
+	series   *promSeries
+	overflow bool
+}
+
+func newPrometheusMetric(name, description string, aggregation *view.Aggregation, opts ...Options) *promMetric {
+	o := createOptions(opts...)
+
+	f := &promFamily{
+		name:           name,
+		unit:           o.unit,
+		aggType:        aggregation.Type,
+		exponential:    o.exponential,
+		maxBuckets:     o.maxBuckets,
+		maxCardinality: o.maxCardinality,
+		expiry:         o.expiry,
+	}
+
+	if aggregation.Type == view.AggTypeDistribution {
+		f.bounds = append([]float64(nil), aggregation.Buckets...)
+		sort.Float64s(f.bounds)
+	}
+
+	for _, l := range o.labels {
+		f.labelNames = append(f.labelNames, tag.Key(l).Name())
+	}
+	f.desc = prometheus.NewDesc(promName(name), description, f.labelNames, nil)
+
+	return &promMetric{
+		family:      f,
+		labelValues: make([]string, len(f.labelNames)),
+		labelSet:    make([]bool, len(f.labelNames)),
+	}
+}
+
+func (p *promMetric) Increment() {
+	p.Record(1)
+}
+
+func (p *promMetric) Decrement() {
+	p.Record(-1)
+}
+
+func (p *promMetric) Name() string {
+	return p.family.name
+}
+
+func (p *promMetric) Record(value float64) {
+	p.get().record(p.family, value, nil)
+}
+
+func (p *promMetric) Add(delta float64) {
+	p.get().add(p.family, delta)
+}
+
+func (p *promMetric) Sub(delta float64) {
+	p.Add(-delta)
+}
+
+func (p *promMetric) RecordWithContext(ctx context.Context, value float64) {
+	var e *prometheus.Exemplar
+	if p.family.aggType == view.AggTypeDistribution {
+		if traceID, spanID, ok := spanFromContext(ctx); ok {
+			e = &prometheus.Exemplar{
+				Value:     value,
+				Labels:    prometheus.Labels{traceIDLabel: traceID, spanIDLabel: spanID},
+				Timestamp: time.Now(),
+			}
+		}
+	}
+	p.withContext(ctx).get().record(p.family, value, e)
+}
+
+// withContext returns the metric recording the values of a context, whose label values default to
+// those of the extractors of the context.
+func (p *promMetric) withContext(ctx context.Context) *promMetric {
+	lvs := contextLabelValues(ctx)
+	if len(lvs) == 0 {
+		return p
+	}
+
+	m := p.With(lvs...).(*promMetric)
+	for i, set := range p.labelSet {
+		if set {
+			m.labelValues[i] = p.labelValues[i]
+		}
+	}
+	return m
+}
+
+func (p *promMetric) get() *promSeries {
+	if d := diagnosing(); d != nil {
+		d.observe(p.family.name, p.family.labelNames, p.labelValues)
+	}
+	l, ok := p.series.Load().(promLookup)
+	if !ok || atomic.LoadUint32(&l.series.deleted) == 1 {
+		l.series, l.overflow = p.family.get(p.labelValues)
+		p.series.Store(l)
+	}
+	if l.overflow {
+		recordOverflow(p.family.name)
+	}
+	return l.series
+}
+
+func (p *promMetric) Start() Stopwatch {
+	return startStopwatch(p, p.family.unit)
+}
+
+func (p *promMetric) Time(fn func()) {
+	timeFunc(p, p.family.unit, fn)
+}
+
+func (p *promMetric) With(labelValues ...LabelValue) Metric {
+	values := make([]string, len(p.labelValues))
+	copy(values, p.labelValues)
+	set := make([]bool, len(p.labelSet))
+	copy(set, p.labelSet)
+
+	resolved := resolveLabelValues(labelValues, p.family.labelNames)
+	for i, n := range p.family.labelNames {
+		if v, ok := resolved[n]; ok {
+			values[i] = v
+			set[i] = true
+		}
+	}
+
+	return &promMetric{family: p.family, labelValues: values, labelSet: set}
+}
+
+func (p *promMetric) Register() error {
+	err := prometheus.Register(p.family)
+	if are, ok := err.(prometheus.AlreadyRegisteredError); ok && are.ExistingCollector == p.family {
+		return nil
+	}
+	return err
+}
+
+func (p *promMetric) Unregister() {
+	prometheus.Unregister(p.family)
+	p.family.delete(p.labelValues, make([]bool, len(p.labelSet)))
+}
+
+func (p *promMetric) Delete() {
+	p.family.delete(p.labelValues, p.labelSet)
+}
+
+// delete drops the series whose label values match the given ones, for the labels which are set.
+func (f *promFamily) delete(labelValues []string, labelSet []bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for key, s := range f.series {
+		match := true
+		for i, set := range labelSet {
+			if set && s.labelValues[i] != labelValues[i] {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			atomic.StoreUint32(&s.deleted, 1)
+			delete(f.series, key)
+		}
+	}
+}
+
+// get returns the series of a combination of label values, creating it if needed. Once the family holds
+// the maximum number of series, this returns the overflow series for other combinations.
+func (f *promFamily) get(labelValues []string) (*promSeries, bool) {
+	key := strings.Join(labelValues, "\xff")
+
+	f.mu.RLock()
+	s, ok := f.series[key]
+	f.mu.RUnlock()
+	if ok {
+		return s, false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok = f.series[key]; ok {
+		return s, false
+	}
+
+	// expired series are swept as new ones are created, such that they are freed even when the family
+	// isn't collected
+	if f.expiry > 0 && time.Since(f.swept) > f.expiry {
+		f.sweep(time.Now())
+	}
+
+	overflow := f.maxCardinality > 0 && len(f.series) >= f.maxCardinality
+	if overflow {
+		labelValues = overflowValues(len(f.labelNames))
+		key = strings.Join(labelValues, "\xff")
+	}
+	if s, ok = f.series[key]; !ok {
+		s = &promSeries{labelValues: labelValues, updated: time.Now().UnixNano()}
+		if f.exponential {
+			s.exp = newExpHistogram(f.maxBuckets)
+		} else if f.aggType == view.AggTypeDistribution {
+			s.counts = make([]uint64, len(f.bounds))
+		}
+		if f.series == nil {
+			f.series = make(map[string]*promSeries)
+		}
+		f.series[key] = s
+	}
+	return s, overflow
+}
+
+func (f *promFamily) Describe(ch chan<- *prometheus.Desc) {
+	ch <- f.desc
+}
+
+func (f *promFamily) Collect(ch chan<- prometheus.Metric) {
+	if f.expiry > 0 {
+		f.mu.Lock()
+		f.sweep(time.Now())
+		f.mu.Unlock()
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, s := range f.series {
+		ch <- s.metric(f)
+	}
+}
+
+// sweep drops the series which haven't been recorded since the expiry of the family. It must be called
+// while holding the lock of the family.
+func (f *promFamily) sweep(now time.Time) {
+	f.swept = now
+	cutoff := now.Add(-f.expiry).UnixNano()
+	for key, s := range f.series {
+		if atomic.LoadInt64(&s.updated) < cutoff {
+			atomic.StoreUint32(&s.deleted, 1)
+			delete(f.series, key)
+		}
+	}
+}
+
+func (s *promSeries) record(f *promFamily, value float64, e *prometheus.Exemplar) {
+	if f.expiry > 0 {
+		atomic.StoreInt64(&s.updated, time.Now().UnixNano())
+	}
+
+	switch f.aggType {
+	case view.AggTypeSum:
+		for {
+			old := atomic.LoadUint64(&s.bits)
+			if atomic.CompareAndSwapUint64(&s.bits, old, math.Float64bits(math.Float64frombits(old)+value)) {
+				return
+			}
+		}
+
+	case view.AggTypeLastValue:
+		atomic.StoreUint64(&s.bits, math.Float64bits(value))
+
+	case view.AggTypeDistribution:
+		if s.exp != nil {
+			s.exp.record(value, e)
+			return
+		}
+
+		// the buckets are the upper bounds of the observations they count, and keep the last exemplar
+		i := sort.SearchFloat64s(f.bounds, value)
+		s.mu.Lock()
+		s.count++
+		s.sum += value
+		if i < len(s.counts) {
+			s.counts[i]++
+		}
+		if e != nil {
+			// the exemplars are allocated once the series has one
+			if s.exemplars == nil {
+				s.exemplars = make([]*prometheus.Exemplar, len(f.bounds)+1)
+			}
+			s.exemplars[i] = e
+		}
+		s.mu.Unlock()
+	}
+}
+
+// add adds delta to the value of a gauge, or records it for the other aggregations.
+func (s *promSeries) add(f *promFamily, delta float64) {
+	if f.aggType != view.AggTypeLastValue {
+		s.record(f, delta, nil)
+		return
+	}
+	if f.expiry > 0 {
+		atomic.StoreInt64(&s.updated, time.Now().UnixNano())
+	}
+
+	for {
+		old := atomic.LoadUint64(&s.bits)
+		if atomic.CompareAndSwapUint64(&s.bits, old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (s *promSeries) metric(f *promFamily) prometheus.Metric {
+	switch f.aggType {
+	case view.AggTypeSum:
+		return prometheus.MustNewConstMetric(f.desc, prometheus.CounterValue, math.Float64frombits(atomic.LoadUint64(&s.bits)), s.labelValues...)
+
+	case view.AggTypeDistribution:
+		if s.exp != nil {
+			return &promNativeHistogram{
+				desc:   f.desc,
+				labels: prometheus.MakeLabelPairs(f.desc, s.labelValues),
+				h:      s.exp.native(),
+			}
+		}
+
+		s.mu.Lock()
+		buckets := make(map[float64]uint64, len(f.bounds))
+		var cumulative uint64
+		for i, b := range f.bounds {
+			cumulative += s.counts[i]
+			buckets[b] = cumulative
+		}
+		count, sum := s.count, s.sum
+		var exemplars []prometheus.Exemplar
+		for _, e := range s.exemplars {
+			if e != nil {
+				exemplars = append(exemplars, *e)
+			}
+		}
+		s.mu.Unlock()
+
+		m := prometheus.MustNewConstHistogram(f.desc, count, sum, buckets, s.labelValues...)
+		if len(exemplars) > 0 {
+			if withExemplars, err := prometheus.NewMetricWithExemplars(m, exemplars...); err == nil {
+				return withExemplars
+			}
+		}
+		return m
+	}
+
+	return prometheus.MustNewConstMetric(f.desc, prometheus.GaugeValue, math.Float64frombits(atomic.LoadUint64(&s.bits)), s.labelValues...)
+}
+
+// promNativeHistogram is a Prometheus native histogram holding the buckets of an exponential distribution.
+type promNativeHistogram struct {
+	desc   *prometheus.Desc
+	labels []*dto.LabelPair
+	h      *dto.Histogram
+}
+
+func (m *promNativeHistogram) Desc() *prometheus.Desc {
+	return m.desc
+}
+
+func (m *promNativeHistogram) Write(out *dto.Metric) error {
+	out.Label = m.labels
+	out.Histogram = m.h
+	return nil
+}
+
+// native returns the observations as a Prometheus native histogram, whose schema is the scale and whose
+// bucket i counts the observations within (base^(i-1), base^i].
+func (h *expHistogram) native() *dto.Histogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	count, sum, scale, zeroCount, zeroThreshold := h.count, h.sum, int32(h.scale), h.zeroCount, 0.0
+	nh := &dto.Histogram{
+		SampleCount:   &count,
+		SampleSum:     &sum,
+		Schema:        &scale,
+		ZeroThreshold: &zeroThreshold,
+		ZeroCount:     &zeroCount,
+	}
+	if h.exemplar != nil {
+		e := &dto.Exemplar{Value: &h.exemplar.Value, Timestamp: timestamppb.New(h.exemplar.Timestamp)}
+		for name, value := range h.exemplar.Labels {
+			name, value := name, value
+			e.Label = append(e.Label, &dto.LabelPair{Name: &name, Value: &value})
+		}
+		sort.Slice(e.Label, func(i, j int) bool { return e.Label[i].GetName() < e.Label[j].GetName() })
+		nh.Exemplars = []*dto.Exemplar{e}
+	}
+
+	nh.PositiveSpan, nh.PositiveDelta = nativeBuckets(&h.positive)
+	nh.NegativeSpan, nh.NegativeDelta = nativeBuckets(&h.negative)
+
+	// histograms without observations must have a span to be recognized as native histograms
+	if count == 0 {
+		offset, length := int32(0), uint32(0)
+		nh.PositiveSpan = []*dto.BucketSpan{{Offset: &offset, Length: &length}}
+	}
+
+	return nh
+}
+
+// nativeBuckets encodes buckets as spans of consecutive buckets, along with the difference between the
+// count of each bucket and the previous one.
+func nativeBuckets(b *expBuckets) ([]*dto.BucketSpan, []int64) {
+	var spans []*dto.BucketSpan
+	var deltas []int64
+	var prevIndex int
+	var prevCount int64
+	for n, i := range b.indexes() {
+		switch {
+		case n == 0:
+			offset, length := int32(i+1), uint32(1)
+			spans = append(spans, &dto.BucketSpan{Offset: &offset, Length: &length})
+		case i == prevIndex+1:
+			*spans[len(spans)-1].Length++
+		default:
+			offset, length := int32(i-prevIndex-1), uint32(1)
+			spans = append(spans, &dto.BucketSpan{Offset: &offset, Length: &length})
+		}
+
+		c := int64(b.counts[i])
+		deltas = append(deltas, c-prevCount)
+		prevIndex, prevCount = i, c
+	}
+	return spans, deltas
+}
+
+// promName replaces the characters Prometheus doesn't allow in metric names by underscores.
+func promName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,249 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"istio.io/pkg/monitoring"
+)
+
+func newPrometheusMetrics() (monitoring.Metric, monitoring.Metric, monitoring.Metric) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	defer monitoring.SetBackend(monitoring.OpenCensusBackend)
+
+	sum := monitoring.NewSum("prom_events_total", "Number of events", monitoring.WithLabels(name, kind))
+	gauge := monitoring.NewGauge("prom_gauge", "Gauge")
+	dist := monitoring.NewDistribution("prom_buckets", "Distribution", []float64{10, 1, 5}, monitoring.WithLabels(name))
+	monitoring.MustRegister(sum, gauge, dist)
+	return sum, gauge, dist
+}
+
+func gather(t *testing.T, name string) *dto.MetricFamily {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Unable to gather the metrics: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() == name {
+			return f
+		}
+	}
+	t.Fatalf("Metric %s not found", name)
+	return nil
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+func TestPrometheusBackend(t *testing.T) {
+	sum, gauge, dist := newPrometheusMetrics()
+
+	// registering again, including through dimensioned metrics, succeeds
+	if err := sum.With(kind.Value("goofy")).Register(); err != nil {
+		t.Errorf("Got %v, expecting registering again to succeed", err)
+	}
+
+	goofy := sum.With(kind.Value("goofy"))
+	goofy.With(name.Value("baz")).Record(45)
+	goofy.With(name.Value("baz")).Decrement()
+	sum.With(name.Value("foo"), kind.Value("bar")).Increment()
+
+	f := gather(t, "prom_events_total")
+	if f.GetType() != dto.MetricType_COUNTER || len(f.GetMetric()) != 2 {
+		t.Fatalf("Got %v, expecting 2 counters", f)
+	}
+	for _, m := range f.GetMetric() {
+		expected := 1.0
+		if labelValue(m, "kind") == "goofy" {
+			expected = 44
+			if labelValue(m, "name") != "baz" {
+				t.Errorf("Got labels %v, expecting name baz", m.GetLabel())
+			}
+		}
+		if m.GetCounter().GetValue() != expected {
+			t.Errorf("Got %v for %v, expecting %v", m.GetCounter().GetValue(), m.GetLabel(), expected)
+		}
+	}
+
+	gauge.Record(42)
+	gauge.Record(77)
+	if g := gather(t, "prom_gauge").GetMetric()[0].GetGauge().GetValue(); g != 77 {
+		t.Errorf("Got %v, expecting the last value of the gauge", g)
+	}
+
+	for _, v := range []float64{0.5, 1, 7, 12} {
+		dist.With(name.Value("foo")).Record(v)
+	}
+	h := gather(t, "prom_buckets").GetMetric()[0].GetHistogram()
+	if h.GetSampleCount() != 4 || h.GetSampleSum() != 20.5 {
+		t.Errorf("Got count %d and sum %v, expecting 4 and 20.5", h.GetSampleCount(), h.GetSampleSum())
+	}
+	expected := map[float64]uint64{1: 2, 5: 2, 10: 3}
+	for _, b := range h.GetBucket() {
+		if b.GetCumulativeCount() != expected[b.GetUpperBound()] {
+			t.Errorf("Got %d for bucket %v, expecting %d", b.GetCumulativeCount(), b.GetUpperBound(), expected[b.GetUpperBound()])
+		}
+	}
+}
+
+func TestPrometheusExemplars(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	dist := monitoring.NewDistribution("prom_exemplar_buckets", "Distribution", []float64{1, 5})
+	exp := monitoring.NewExponentialDistribution("prom_exemplar_exp", "Exponential distribution")
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(dist, exp)
+
+	sc := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    oteltrace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:     oteltrace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceFlags: oteltrace.FlagsSampled,
+	})
+	ctx := oteltrace.ContextWithSpanContext(context.Background(), sc)
+
+	dist.RecordWithContext(ctx, 3)
+	dist.RecordWithContext(context.Background(), 0.5)
+	exp.RecordWithContext(ctx, 3)
+
+	for _, b := range gather(t, "prom_exemplar_buckets").GetMetric()[0].GetHistogram().GetBucket() {
+		e := b.GetExemplar()
+		if b.GetUpperBound() != 5 {
+			if e != nil {
+				t.Errorf("Got exemplar %v in bucket %v, expecting none", e, b.GetUpperBound())
+			}
+			continue
+		}
+		if e.GetValue() != 3 || len(e.GetLabel()) != 2 {
+			t.Fatalf("Got exemplar %v, expecting the trace and span of the observation", e)
+		}
+		for _, l := range e.GetLabel() {
+			if (l.GetName() == "trace_id" && l.GetValue() != sc.TraceID().String()) ||
+				(l.GetName() == "span_id" && l.GetValue() != sc.SpanID().String()) {
+				t.Errorf("Got label %v, expecting the identifiers of the span", l)
+			}
+		}
+	}
+
+	if e := gather(t, "prom_exemplar_exp").GetMetric()[0].GetHistogram().GetExemplars(); len(e) != 1 || e[0].GetValue() != 3 {
+		t.Errorf("Got exemplars %v, expecting the exemplar of the observation", e)
+	}
+}
+
+func TestPrometheusDelete(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	sum := monitoring.NewSum("prom_deleted_total", "Number of events", monitoring.WithLabels(name, kind))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(sum)
+
+	foo := sum.With(name.Value("foo"), kind.Value("a"))
+	foo.Increment()
+	sum.With(name.Value("foo"), kind.Value("b")).Increment()
+	sum.With(name.Value("bar"), kind.Value("a")).Increment()
+
+	// deleting the series of foo drops those of all its kinds
+	sum.With(name.Value("foo")).Delete()
+	if m := gather(t, "prom_deleted_total").GetMetric(); len(m) != 1 || labelValue(m[0], "name") != "bar" {
+		t.Fatalf("Got %v, expecting only the series of bar", m)
+	}
+
+	// recording again starts from scratch
+	foo.Increment()
+	for _, m := range gather(t, "prom_deleted_total").GetMetric() {
+		if m.GetCounter().GetValue() != 1 {
+			t.Errorf("Got %v for %v, expecting 1", m.GetCounter().GetValue(), m.GetLabel())
+		}
+	}
+
+	sum.Unregister()
+	families, _ := prometheus.DefaultGatherer.Gather()
+	for _, f := range families {
+		if f.GetName() == "prom_deleted_total" {
+			t.Errorf("Got %v, expecting the metric to be unregistered", f)
+		}
+	}
+
+	if err := sum.Register(); err != nil {
+		t.Errorf("Got %v, expecting the metric to be registered again", err)
+	}
+	sum.Increment()
+	if m := gather(t, "prom_deleted_total").GetMetric(); len(m) != 1 || m[0].GetCounter().GetValue() != 1 {
+		t.Errorf("Got %v, expecting the values to be dropped", m)
+	}
+}
+
+func TestPrometheusUnrecorded(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	dist := monitoring.NewDistribution("prom_unrecorded_buckets", "Distribution", []float64{1, 5}, monitoring.WithLabels(name))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(dist)
+
+	// metrics which are registered but never recorded have no series
+	families, _ := prometheus.DefaultGatherer.Gather()
+	for _, f := range families {
+		if f.GetName() == "prom_unrecorded_buckets" {
+			t.Errorf("Got %v, expecting no series", f)
+		}
+	}
+
+	dist.With(name.Value("foo")).RecordWithContext(context.Background(), 3)
+	if h := gather(t, "prom_unrecorded_buckets").GetMetric()[0].GetHistogram(); h.GetSampleCount() != 1 {
+		t.Errorf("Got %v, expecting 1 observation", h)
+	}
+}
+
+func TestPrometheusExpiry(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	sum := monitoring.NewSum("prom_expiring_total", "Number of events", monitoring.WithLabels(name), monitoring.WithExpiry(50*time.Millisecond))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(sum)
+
+	foo := sum.With(name.Value("foo"))
+	foo.Increment()
+	sum.With(name.Value("bar")).Increment()
+	if m := gather(t, "prom_expiring_total").GetMetric(); len(m) != 2 {
+		t.Fatalf("Got %v, expecting the series of foo and bar", m)
+	}
+
+	// foo keeps being recorded while bar expires
+	for i := 0; i < 5; i++ {
+		time.Sleep(20 * time.Millisecond)
+		foo.Increment()
+	}
+	m := gather(t, "prom_expiring_total").GetMetric()
+	if len(m) != 1 || labelValue(m[0], "name") != "foo" || m[0].GetCounter().GetValue() != 6 {
+		t.Fatalf("Got %v, expecting only the series of foo", m)
+	}
+
+	// recording again starts from scratch
+	sum.With(name.Value("bar")).Increment()
+	for _, s := range gather(t, "prom_expiring_total").GetMetric() {
+		if labelValue(s, "name") == "bar" && s.GetCounter().GetValue() != 1 {
+			t.Errorf("Got %v, expecting the expired value to be dropped", s.GetCounter().GetValue())
+		}
+	}
+}
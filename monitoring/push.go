@@ -0,0 +1,104 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const defaultPushInterval = 15 * time.Second
+
+// PushOptions configures the push of metrics to a Prometheus Pushgateway, for short-lived jobs and
+// processes which can't be scraped. The metrics of the OpenTelemetry backend are pushed by the periodic
+// readers of the meter provider instead, such as those of the OTLP exporters.
+type PushOptions struct {
+	// URL is the URL of the Pushgateway.
+	URL string
+
+	// Job is the value of the job label of the pushed metrics.
+	Job string
+
+	// Grouping holds the labels grouping the pushed metrics along with the job, such as the instance.
+	Grouping map[string]string
+
+	// Interval is the interval between pushes. This defaults to 15 seconds.
+	Interval time.Duration
+
+	// Gatherer gathers the metrics to push. This defaults to the default Prometheus gatherer, which
+	// gathers the metrics of the Prometheus backend. The metrics of the OpenCensus backend are pushed
+	// by passing the registry of the OpenCensus Prometheus exporter.
+	Gatherer prometheus.Gatherer
+
+	// OnError is called with the errors of the pushes made on the interval.
+	OnError func(error)
+}
+
+// StartPush pushes the metrics to a Prometheus Pushgateway on an interval, replacing the metrics
+// previously pushed for the job and grouping. The pushes stop once the returned function is called,
+// which pushes the metrics one last time so that the final values of short-lived jobs are kept, and
+// returns the error of that push.
+func StartPush(o PushOptions) (stop func() error, err error) {
+	if o.URL == "" || o.Job == "" {
+		return nil, errors.New("the URL of the Pushgateway and the job of the metrics must be provided")
+	}
+	if o.Interval <= 0 {
+		o.Interval = defaultPushInterval
+	}
+	if o.Gatherer == nil {
+		o.Gatherer = prometheus.DefaultGatherer
+	}
+
+	p := push.New(o.URL, o.Job).Gatherer(o.Gatherer)
+	for name, value := range o.Grouping {
+		p = p.Grouping(name, value)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		t := time.NewTicker(o.Interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := p.PushContext(ctx); err != nil && ctx.Err() == nil && o.OnError != nil {
+					o.OnError(err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() error {
+		err := errors.New("the push of the metrics was already stopped")
+		once.Do(func() {
+			cancel()
+			wg.Wait()
+			err = p.Push()
+		})
+		return err
+	}, nil
+}
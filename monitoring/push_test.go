@@ -0,0 +1,115 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"istio.io/pkg/monitoring"
+)
+
+func TestStartPush(t *testing.T) {
+	var mu sync.Mutex
+	var pushes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		pushes = append(pushes, r.Method+" "+r.URL.Path+" "+string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	reg := prometheus.NewRegistry()
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "pushed_total", Help: "Number of events"})
+	reg.MustRegister(c)
+	c.Add(3)
+
+	stop, err := monitoring.StartPush(monitoring.PushOptions{
+		URL:      srv.URL,
+		Job:      "test",
+		Grouping: map[string]string{"instance": "foo"},
+		Interval: 10 * time.Millisecond,
+		Gatherer: reg,
+	})
+	if err != nil {
+		t.Fatalf("Unable to start pushing: %v", err)
+	}
+
+	err = retry(func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(pushes) == 0 {
+			return io.EOF
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Got no push on the interval: %v", err)
+	}
+
+	if err := stop(); err != nil {
+		t.Errorf("Got %v, expecting the last push to succeed", err)
+	}
+	if err := stop(); err == nil {
+		t.Error("Got no error, expecting stopping again to fail")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	last := pushes[len(pushes)-1]
+	if !strings.HasPrefix(last, "PUT /metrics/job/test/instance/foo ") || !strings.Contains(last, "pushed_total") {
+		t.Errorf("Got push %q, expecting the metrics of the job and instance", last)
+	}
+}
+
+func TestStartPushErrors(t *testing.T) {
+	if _, err := monitoring.StartPush(monitoring.PushOptions{URL: "http://localhost"}); err == nil {
+		t.Error("Got no error, expecting the job to be required")
+	}
+
+	errs := make(chan error, 1)
+	stop, err := monitoring.StartPush(monitoring.PushOptions{
+		URL:      "http://127.0.0.1:1",
+		Job:      "test",
+		Interval: 10 * time.Millisecond,
+		Gatherer: prometheus.NewRegistry(),
+		OnError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unable to start pushing: %v", err)
+	}
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Error("Got no error, expecting the pushes to fail")
+	}
+	if err := stop(); err == nil {
+		t.Error("Got no error, expecting the last push to fail")
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"math"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// runtimeMetric is a metric of the Go runtime exported by RegisterRuntimeMetrics.
+type runtimeMetric struct {
+	name        string
+	description string
+	sample      string
+}
+
+var (
+	runtimeMetrics = []runtimeMetric{
+		{"runtime_goroutines", "Number of live goroutines", "/sched/goroutines:goroutines"},
+		{"runtime_memory_bytes", "Memory mapped by the Go runtime, in bytes", "/memory/classes/total:bytes"},
+		{"runtime_heap_objects_bytes", "Memory occupied by live and unswept heap objects, in bytes", "/memory/classes/heap/objects:bytes"},
+		{"runtime_heap_goal_bytes", "Heap size targeted by the end of the GC cycle, in bytes", "/gc/heap/goal:bytes"},
+		{"runtime_gc_cycles", "Number of completed GC cycles", "/gc/cycles/total:gc-cycles"},
+	}
+
+	// the distributions are exported as their quantiles
+	runtimeDistributions = []runtimeMetric{
+		{"runtime_gc_pause_seconds", "Quantiles of the durations of the stop-the-world pauses of the GC, in seconds", "/gc/pauses:seconds"},
+		{"runtime_sched_latency_seconds", "Quantiles of the durations goroutines spent runnable before running, in seconds", "/sched/latencies:seconds"},
+	}
+
+	runtimeQuantiles = []float64{0.5, 0.9, 0.99, 1}
+
+	quantileLabel = MustCreateLabel("quantile")
+
+	registerRuntimeOnce sync.Once
+)
+
+// RegisterRuntimeMetrics registers derived gauges exporting the metrics of the Go runtime, such as the
+// number of goroutines, the memory of the heap and the quantiles of the GC pauses and of the scheduling
+// latency, with the backend selected when they are registered. The metrics of the runtime are read at
+// most once a second. Registering them again does nothing.
+func RegisterRuntimeMetrics() {
+	registerRuntimeOnce.Do(func() {
+		r := newRuntimeSampler(append(append([]runtimeMetric(nil), runtimeMetrics...), runtimeDistributions...))
+
+		for _, m := range runtimeMetrics {
+			if i, ok := r.index[m.sample]; ok {
+				NewDerivedGauge(m.name, m.description, func() float64 { return r.value(i) })
+			}
+		}
+
+		for _, m := range runtimeDistributions {
+			i, ok := r.index[m.sample]
+			if !ok {
+				continue
+			}
+
+			var g DerivedGauge
+			for _, q := range runtimeQuantiles {
+				q := q
+				fn := func() float64 { return r.quantile(i, q) }
+				lv := quantileLabel.Value(strconv.FormatFloat(q, 'f', -1, 64))
+				if g == nil {
+					g = NewDerivedGauge(m.name, m.description, fn, lv)
+				} else {
+					g.ValueFrom(fn, lv)
+				}
+			}
+		}
+	})
+}
+
+// runtimeSampler reads the samples of the metrics of the runtime, which are shared by the gauges read
+// for an export.
+type runtimeSampler struct {
+	index map[string]int
+
+	mu      sync.Mutex
+	samples []metrics.Sample
+	read    time.Time
+}
+
+func newRuntimeSampler(ms []runtimeMetric) *runtimeSampler {
+	supported := make(map[string]bool)
+	for _, d := range metrics.All() {
+		supported[d.Name] = true
+	}
+
+	r := &runtimeSampler{index: make(map[string]int)}
+	for _, m := range ms {
+		if supported[m.sample] {
+			r.index[m.sample] = len(r.samples)
+			r.samples = append(r.samples, metrics.Sample{Name: m.sample})
+		}
+	}
+	return r
+}
+
+// get computes a value from a sample, reading the samples if they are older than a second. The value is
+// computed while holding the lock, as reading the samples reuses their histograms.
+func (r *runtimeSampler) get(i int, fn func(v metrics.Value) float64) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.read) > time.Second {
+		metrics.Read(r.samples)
+		r.read = time.Now()
+	}
+	return fn(r.samples[i].Value)
+}
+
+func (r *runtimeSampler) value(i int) float64 {
+	return r.get(i, func(v metrics.Value) float64 {
+		switch v.Kind() {
+		case metrics.KindUint64:
+			return float64(v.Uint64())
+		case metrics.KindFloat64:
+			return v.Float64()
+		}
+		return 0
+	})
+}
+
+// quantile returns a quantile of a distribution.
+func (r *runtimeSampler) quantile(i int, q float64) float64 {
+	return r.get(i, func(v metrics.Value) float64 {
+		if v.Kind() != metrics.KindFloat64Histogram {
+			return 0
+		}
+		return histogramQuantile(v.Float64Histogram(), q)
+	})
+}
+
+// histogramQuantile returns the upper bound of the bucket of a quantile of a histogram of the runtime,
+// or its lower bound for the last bucket, which is unbounded.
+func histogramQuantile(h *metrics.Float64Histogram, q float64) float64 {
+	var total uint64
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(q * float64(total)))
+	var cumulative uint64
+	for b, c := range h.Counts {
+		cumulative += c
+		if cumulative >= rank && c > 0 {
+			if math.IsInf(h.Buckets[b+1], 1) {
+				return h.Buckets[b]
+			}
+			return h.Buckets[b+1]
+		}
+	}
+	return 0
+}
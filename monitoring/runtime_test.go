@@ -0,0 +1,50 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"runtime"
+	"testing"
+
+	"istio.io/pkg/monitoring"
+)
+
+func TestRegisterRuntimeMetrics(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	monitoring.RegisterRuntimeMetrics()
+	monitoring.RegisterRuntimeMetrics()
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+
+	runtime.GC()
+
+	if g := gather(t, "runtime_goroutines").GetMetric()[0].GetGauge().GetValue(); g < 1 {
+		t.Errorf("Got %v goroutines, expecting at least 1", g)
+	}
+	if g := gather(t, "runtime_heap_goal_bytes").GetMetric()[0].GetGauge().GetValue(); g <= 0 {
+		t.Errorf("Got a heap goal of %v, expecting a positive size", g)
+	}
+
+	m := gather(t, "runtime_sched_latency_seconds").GetMetric()
+	if len(m) != 4 {
+		t.Fatalf("Got %v, expecting 4 quantiles", m)
+	}
+	quantiles := make(map[string]float64)
+	for _, q := range m {
+		quantiles[labelValue(q, "quantile")] = q.GetGauge().GetValue()
+	}
+	if quantiles["0.5"] > quantiles["0.99"] || quantiles["0.99"] > quantiles["1"] {
+		t.Errorf("Got quantiles %v, expecting them to increase", quantiles)
+	}
+}
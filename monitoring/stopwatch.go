@@ -0,0 +1,48 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import "time"
+
+// A Stopwatch records the duration elapsed since it was started into a Metric, see Metric.Start.
+type Stopwatch struct {
+	metric Metric
+	unit   Unit
+	start  time.Time
+}
+
+func startStopwatch(m Metric, unit Unit) Stopwatch {
+	return Stopwatch{metric: m, unit: unit, start: time.Now()}
+}
+
+// Stop records the duration elapsed since the Stopwatch was started, in the unit of its Metric, and
+// returns it. The duration isn't recorded if the unit of the Metric isn't a duration, see DurationValue.
+func (s Stopwatch) Stop() time.Duration {
+	d := time.Since(s.start)
+	v, err := DurationValue(d, s.unit)
+	if err != nil {
+		recordUnitMismatch(s.metric.Name())
+		return d
+	}
+	s.metric.Record(v)
+	return d
+}
+
+// timeFunc records the duration of a call to fn into a Metric.
+func timeFunc(m Metric, unit Unit, fn func()) {
+	sw := startStopwatch(m, unit)
+	defer sw.Stop()
+	fn()
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"istio.io/pkg/monitoring"
+	"istio.io/pkg/monitoring/monitortest"
+)
+
+func TestStopwatch(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	millis := monitoring.NewDistribution("prom_timed_milliseconds", "Duration", nil, monitoring.WithUnit(monitoring.Milliseconds))
+	seconds := monitoring.NewDistribution("prom_timed_seconds", "Duration", nil, monitoring.WithUnit(monitoring.Seconds))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(millis, seconds)
+
+	millis.Time(func() { time.Sleep(20 * time.Millisecond) })
+
+	sw := seconds.Start()
+	time.Sleep(20 * time.Millisecond)
+	d := sw.Stop()
+
+	if s := gather(t, "prom_timed_milliseconds").GetMetric()[0].GetHistogram().GetSampleSum(); s < 20 || s > 10000 {
+		t.Errorf("Got %v, expecting the duration of the call in milliseconds", s)
+	}
+	if s := gather(t, "prom_timed_seconds").GetMetric()[0].GetHistogram().GetSampleSum(); s != d.Seconds() {
+		t.Errorf("Got %v, expecting %v seconds", s, d.Seconds())
+	}
+}
+
+func TestStopwatchUnitMismatch(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	sizes := monitoring.NewDistribution("prom_timed_bytes", "Sizes", nil, monitoring.WithUnit(monitoring.Bytes))
+	defer monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(sizes)
+
+	sizes.Time(func() {})
+
+	families, _ := prometheus.DefaultGatherer.Gather()
+	for _, f := range families {
+		if f.GetName() == "prom_timed_bytes" && len(f.GetMetric()) > 0 {
+			t.Errorf("Got %v, expecting no duration recorded in bytes", f)
+		}
+	}
+	// the metric counting mismatches is created with the backend selected by the time of the first one
+	monitortest.New(t).Assert("monitoring_unit_mismatches_total", map[string]string{"metric": "prom_timed_bytes"}, monitortest.Exactly(1))
+}
@@ -0,0 +1,189 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/tag"
+)
+
+// NewSummary creates a new Metric with an aggregation type of Summary. This means that the data
+// collected by the Metric will be exported as the quantiles of the observations made over a sliding
+// window, computed by the process, for the cases where the buckets of distributions are too coarse.
+// The objectives map each quantile to its allowed error, such as 0.99 to 0.001. The window defaults
+// to 10 minutes split in 5 buckets, and is configured with WithMaxAge and WithAgeBuckets. Summaries
+// are only supported by the Prometheus backend: the other backends fall back to an exponential
+// distribution, whose quantiles are computed by the backend the metrics are exported to.
+func NewSummary(name, description string, objectives map[float64]float64, opts ...Options) Metric {
+	if backend.Load().(Backend) == PrometheusBackend {
+		return newPrometheusSummary(name, description, objectives, opts...)
+	}
+	return NewExponentialDistribution(name, description, opts...)
+}
+
+// WithMaxAge provides configuration options for a new Summary, providing the duration for which the
+// observations are kept to compute the quantiles.
+func WithMaxAge(maxAge time.Duration) Options {
+	return func(opts *options) {
+		opts.maxAge = maxAge
+	}
+}
+
+// WithAgeBuckets provides configuration options for a new Summary, providing the number of buckets the
+// window of observations is split in, which are dropped one at a time as they expire.
+func WithAgeBuckets(n uint32) Options {
+	return func(opts *options) {
+		opts.ageBuckets = n
+	}
+}
+
+// promSummary records the observations of a Summary as a Prometheus summary.
+type promSummary struct {
+	name        string
+	unit        Unit
+	vec         *prometheus.SummaryVec
+	labelNames  []string
+	labelValues []string
+	labelSet    []bool
+	limit       *cardinalityLimit
+}
+
+func newPrometheusSummary(name, description string, objectives map[float64]float64, opts ...Options) *promSummary {
+	o := createOptions(opts...)
+
+	s := &promSummary{name: name, unit: o.unit, limit: newCardinalityLimit(o.maxCardinality)}
+	for _, l := range o.labels {
+		s.labelNames = append(s.labelNames, tag.Key(l).Name())
+	}
+	s.labelValues = make([]string, len(s.labelNames))
+	s.labelSet = make([]bool, len(s.labelNames))
+
+	s.vec = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       promName(name),
+		Help:       description,
+		Objectives: objectives,
+		MaxAge:     o.maxAge,
+		AgeBuckets: o.ageBuckets,
+	}, s.labelNames)
+	return s
+}
+
+func (s *promSummary) Increment() {
+	s.Record(1)
+}
+
+func (s *promSummary) Decrement() {
+	s.Record(-1)
+}
+
+func (s *promSummary) Name() string {
+	return s.name
+}
+
+func (s *promSummary) Record(value float64) {
+	values := s.labelValues
+	if d := diagnosing(); d != nil {
+		d.observe(s.name, s.labelNames, values)
+	}
+	if s.limit != nil && !s.limit.admit(values) {
+		recordOverflow(s.name)
+		values = overflowValues(len(values))
+	}
+	if o, err := s.vec.GetMetricWithLabelValues(values...); err == nil {
+		o.Observe(value)
+	}
+}
+
+// Add makes an observation of delta.
+func (s *promSummary) Add(delta float64) {
+	s.Record(delta)
+}
+
+func (s *promSummary) Sub(delta float64) {
+	s.Record(-delta)
+}
+
+// RecordWithContext makes an observation without exemplar, as Prometheus summaries don't support them.
+func (s *promSummary) RecordWithContext(ctx context.Context, value float64) {
+	lvs := contextLabelValues(ctx)
+	if len(lvs) == 0 {
+		s.Record(value)
+		return
+	}
+
+	// the label values of the context are defaults
+	m := s.With(lvs...).(*promSummary)
+	for i, set := range s.labelSet {
+		if set {
+			m.labelValues[i] = s.labelValues[i]
+		}
+	}
+	m.Record(value)
+}
+
+func (s *promSummary) Start() Stopwatch {
+	return startStopwatch(s, s.unit)
+}
+
+func (s *promSummary) Time(fn func()) {
+	timeFunc(s, s.unit, fn)
+}
+
+func (s *promSummary) With(labelValues ...LabelValue) Metric {
+	clone := *s
+	clone.labelValues = make([]string, len(s.labelValues))
+	copy(clone.labelValues, s.labelValues)
+	clone.labelSet = make([]bool, len(s.labelSet))
+	copy(clone.labelSet, s.labelSet)
+
+	resolved := resolveLabelValues(labelValues, s.labelNames)
+	for i, n := range s.labelNames {
+		if v, ok := resolved[n]; ok {
+			clone.labelValues[i] = v
+			clone.labelSet[i] = true
+		}
+	}
+	return &clone
+}
+
+func (s *promSummary) Register() error {
+	err := prometheus.Register(s.vec)
+	if are, ok := err.(prometheus.AlreadyRegisteredError); ok && are.ExistingCollector == s.vec {
+		return nil
+	}
+	return err
+}
+
+func (s *promSummary) Unregister() {
+	prometheus.Unregister(s.vec)
+	s.vec.Reset()
+}
+
+func (s *promSummary) Delete() {
+	labels := prometheus.Labels{}
+	for i, set := range s.labelSet {
+		if set {
+			labels[s.labelNames[i]] = s.labelValues[i]
+		}
+	}
+	if len(labels) == 0 {
+		s.vec.Reset()
+		return
+	}
+	s.vec.DeletePartialMatch(labels)
+}
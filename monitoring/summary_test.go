@@ -0,0 +1,67 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/pkg/monitoring"
+)
+
+func TestPrometheusSummary(t *testing.T) {
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	s := monitoring.NewSummary("prom_latency_seconds", "Latency", map[float64]float64{0.5: 0.05, 0.9: 0.01},
+		monitoring.WithLabels(name), monitoring.WithMaxAge(time.Minute), monitoring.WithAgeBuckets(3))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(s)
+
+	for i := 1; i <= 100; i++ {
+		s.With(name.Value("foo")).Record(float64(i))
+	}
+	s.With(name.Value("bar")).Record(1)
+
+	m := gather(t, "prom_latency_seconds").GetMetric()
+	if len(m) != 2 {
+		t.Fatalf("Got %v, expecting the summaries of foo and bar", m)
+	}
+	for _, series := range m {
+		if labelValue(series, "name") != "foo" {
+			continue
+		}
+		sm := series.GetSummary()
+		if sm.GetSampleCount() != 100 || sm.GetSampleSum() != 5050 {
+			t.Errorf("Got count %d and sum %v, expecting 100 and 5050", sm.GetSampleCount(), sm.GetSampleSum())
+		}
+		for _, q := range sm.GetQuantile() {
+			if q.GetQuantile() == 0.9 && (q.GetValue() < 89 || q.GetValue() > 91) {
+				t.Errorf("Got %v for the quantile 0.9, expecting about 90", q.GetValue())
+			}
+		}
+	}
+
+	s.With(name.Value("bar")).Delete()
+	if m := gather(t, "prom_latency_seconds").GetMetric(); len(m) != 1 || labelValue(m[0], "name") != "foo" {
+		t.Errorf("Got %v, expecting only the summary of foo", m)
+	}
+}
+
+func TestSummaryFallback(t *testing.T) {
+	s := monitoring.NewSummary("oc_latency_seconds", "Latency", map[float64]float64{0.5: 0.05})
+	if err := s.Register(); err != nil {
+		t.Fatalf("Got %v, expecting the fallback distribution to be registered", err)
+	}
+	s.Record(3)
+}
@@ -14,6 +14,14 @@
 
 package monitoring
 
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats/view"
+)
+
 // Unit encodes the standard name for describing the quantity
 // measured by a Metric (if applicable).
 type Unit string
@@ -25,3 +33,87 @@ const (
 	Seconds      Unit = "s"
 	Milliseconds Unit = "ms"
 )
+
+var (
+	// unitScales holds the size of the units relative to the base unit of the quantity they measure,
+	// such that values are converted between the units of a quantity.
+	unitScales = map[Unit]struct {
+		base  Unit
+		scale float64
+	}{
+		Seconds:      {Seconds, 1},
+		Milliseconds: {Seconds, 1e-3},
+		Bytes:        {Bytes, 1},
+	}
+
+	unitMismatchesOnce sync.Once
+	unitMismatches     Metric
+
+	defaultBoundsMu sync.RWMutex
+	defaultBounds   = map[Unit][]float64{
+		Seconds:      {0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		Milliseconds: {1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000},
+		Bytes:        {64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20, 64 << 20},
+	}
+)
+
+// DefaultBounds returns the bounds of the distributions of a unit created without bounds. The curated
+// bounds of durations range from a millisecond to a minute, and those of sizes from 64 bytes to 64
+// megabytes. There are no default bounds for other units.
+func DefaultBounds(unit Unit) []float64 {
+	defaultBoundsMu.RLock()
+	defer defaultBoundsMu.RUnlock()
+	return append([]float64(nil), defaultBounds[unit]...)
+}
+
+// SetDefaultBounds overrides the bounds of the distributions of a unit created without bounds
+// afterwards, or removes them when bounds is empty.
+func SetDefaultBounds(unit Unit, bounds []float64) {
+	defaultBoundsMu.Lock()
+	defer defaultBoundsMu.Unlock()
+	if len(bounds) == 0 {
+		delete(defaultBounds, unit)
+		return
+	}
+	defaultBounds[unit] = append([]float64(nil), bounds...)
+}
+
+// Convert converts a value from a unit to another unit of the same quantity, such as from seconds to
+// milliseconds. It returns an error if the units measure different quantities, or are unknown.
+func Convert(value float64, from, to Unit) (float64, error) {
+	if from == to {
+		return value, nil
+	}
+	f, fok := unitScales[from]
+	t, tok := unitScales[to]
+	if !fok || !tok || f.base != t.base {
+		return 0, fmt.Errorf("cannot convert values in %q to %q", from, to)
+	}
+	return value * f.scale / t.scale, nil
+}
+
+// DurationValue returns the value of a duration in a unit, for recording it into a Metric of that unit.
+// Durations are recorded in seconds into Metrics without unit. It returns an error if the unit isn't a
+// duration, rather than recording a value in seconds into a Metric of another unit, as the stopwatches
+// of Metric.Start and Metric.Time would otherwise do.
+func DurationValue(d time.Duration, unit Unit) (float64, error) {
+	switch unit {
+	case Seconds, None, "":
+		return d.Seconds(), nil
+	case Milliseconds:
+		return float64(d) / float64(time.Millisecond), nil
+	}
+	return 0, fmt.Errorf("cannot record durations in %q", unit)
+}
+
+// recordUnitMismatch counts a duration not recorded into a metric whose unit isn't a duration. The metric
+// counting mismatches is created with the backend selected by the time the first mismatch happens.
+func recordUnitMismatch(name string) {
+	unitMismatchesOnce.Do(func() {
+		unitMismatches = newMetric("monitoring_unit_mismatches_total",
+			"Number of durations not recorded as the unit of the metric isn't a duration, by metric",
+			view.Sum(), WithLabels(metricLabel))
+		_ = unitMismatches.Register()
+	})
+	unitMismatches.With(metricLabel.Value(name)).Increment()
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring_test
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/pkg/monitoring"
+)
+
+func TestDefaultBounds(t *testing.T) {
+	monitoring.SetDefaultBounds("req", []float64{1, 10, 100})
+	defer monitoring.SetDefaultBounds("req", nil)
+
+	monitoring.SetBackend(monitoring.PrometheusBackend)
+	latency := monitoring.NewDistribution("prom_default_seconds", "Latency", nil, monitoring.WithUnit(monitoring.Seconds))
+	requests := monitoring.NewDistribution("prom_default_requests", "Requests", nil, monitoring.WithUnit("req"))
+	sizes := monitoring.NewDistribution("prom_explicit_bytes", "Sizes", []float64{1, 2}, monitoring.WithUnit(monitoring.Bytes))
+	monitoring.SetBackend(monitoring.OpenCensusBackend)
+	monitoring.MustRegister(latency, requests, sizes)
+
+	for m, expected := range map[monitoring.Metric][]float64{
+		latency:  monitoring.DefaultBounds(monitoring.Seconds),
+		requests: {1, 10, 100},
+		sizes:    {1, 2},
+	} {
+		m.Record(1)
+		buckets := gather(t, m.Name()).GetMetric()[0].GetHistogram().GetBucket()
+		if len(buckets) != len(expected) {
+			t.Errorf("Got %d buckets for %s, expecting %d", len(buckets), m.Name(), len(expected))
+			continue
+		}
+		for i, b := range buckets {
+			if b.GetUpperBound() != expected[i] {
+				t.Errorf("Got bound %v for %s, expecting %v", b.GetUpperBound(), m.Name(), expected[i])
+			}
+		}
+	}
+
+	if b := monitoring.DefaultBounds(monitoring.None); len(b) != 0 {
+		t.Errorf("Got %v, expecting no default bounds without unit", b)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		value    float64
+		from, to monitoring.Unit
+		expected float64
+		err      bool
+	}{
+		{1.5, monitoring.Seconds, monitoring.Milliseconds, 1500, false},
+		{250, monitoring.Milliseconds, monitoring.Seconds, 0.25, false},
+		{3, monitoring.Bytes, monitoring.Bytes, 3, false},
+		{3, "req", "req", 3, false},
+		{3, monitoring.Seconds, monitoring.Bytes, 0, true},
+		{3, monitoring.None, monitoring.Seconds, 0, true},
+	}
+	for _, c := range cases {
+		v, err := monitoring.Convert(c.value, c.from, c.to)
+		if (err != nil) != c.err {
+			t.Errorf("Got error %v converting %v from %s to %s, expecting an error: %v", err, c.value, c.from, c.to, c.err)
+			continue
+		}
+		if v != c.expected {
+			t.Errorf("Got %v converting %v from %s to %s, expecting %v", v, c.value, c.from, c.to, c.expected)
+		}
+	}
+}
+
+func TestDurationValue(t *testing.T) {
+	for unit, expected := range map[monitoring.Unit]float64{
+		monitoring.Seconds:      1.5,
+		monitoring.Milliseconds: 1500,
+		monitoring.None:         1.5,
+	} {
+		if v, err := monitoring.DurationValue(1500*time.Millisecond, unit); err != nil || v != expected {
+			t.Errorf("Got %v, %v for %s, expecting %v", v, err, unit, expected)
+		}
+	}
+	if _, err := monitoring.DurationValue(time.Second, monitoring.Bytes); err == nil {
+		t.Error("Got no error, expecting durations not to be recorded in bytes")
+	}
+}